@@ -0,0 +1,12 @@
+-- Monotonic per-application sequence counter, decoupled from live event rows
+-- so deleting old events can never cause a sequence number to be reused
+CREATE TABLE application_sequences (
+    application_id TEXT PRIMARY KEY,
+    last_sequence BIGINT NOT NULL
+);
+
+INSERT INTO application_sequences (application_id, last_sequence)
+SELECT application_id, MAX(sequence_number)
+FROM events
+WHERE application_id IS NOT NULL AND sequence_number IS NOT NULL
+GROUP BY application_id;
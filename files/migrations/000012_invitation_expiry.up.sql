@@ -0,0 +1,2 @@
+-- Persist invitation expiry so it can be updated and enforced independently of the token
+ALTER TABLE invitations ADD COLUMN expires_at BIGINT;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_events_data_size;
+ALTER TABLE events DROP COLUMN IF EXISTS data_size;
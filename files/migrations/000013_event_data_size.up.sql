@@ -0,0 +1,4 @@
+-- Track serialized event data size for payload diagnostics
+ALTER TABLE events ADD COLUMN data_size INTEGER;
+UPDATE events SET data_size = OCTET_LENGTH(data) WHERE data IS NOT NULL;
+CREATE INDEX idx_events_data_size ON events(data_size);
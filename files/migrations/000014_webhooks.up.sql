@@ -0,0 +1,23 @@
+-- Per-application webhooks notified of accepted events
+CREATE TABLE webhooks (
+    id TEXT PRIMARY KEY,
+    application_id TEXT NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    event_types TEXT[] NOT NULL DEFAULT '{}',
+    created_by_public_key TEXT NOT NULL,
+    created_at BIGINT NOT NULL
+);
+CREATE INDEX idx_webhooks_application_id ON webhooks(application_id);
+
+-- Deliveries that still failed after every retry
+CREATE TABLE webhook_dead_letters (
+    id TEXT PRIMARY KEY,
+    webhook_id TEXT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    attempts INTEGER NOT NULL,
+    last_error TEXT NOT NULL,
+    created_at BIGINT NOT NULL
+);
+CREATE INDEX idx_webhook_dead_letters_webhook_id ON webhook_dead_letters(webhook_id);
@@ -0,0 +1,10 @@
+-- Short codes stand in for full invitation tokens in links and QR codes
+CREATE TABLE invitation_short_codes (
+    code TEXT PRIMARY KEY,
+    invitation_id TEXT NOT NULL REFERENCES invitations(id) ON DELETE CASCADE,
+    token TEXT NOT NULL,
+    expires_at BIGINT NOT NULL,
+    created_at BIGINT NOT NULL
+);
+CREATE INDEX idx_invitation_short_codes_invitation ON invitation_short_codes(invitation_id);
+CREATE INDEX idx_invitation_short_codes_expires_at ON invitation_short_codes(expires_at);
@@ -0,0 +1,12 @@
+-- Application-scoped API tokens for integrations; only a hash of each token is stored
+CREATE TABLE api_tokens (
+    id TEXT PRIMARY KEY,
+    application_id TEXT NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    role TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_by_public_key TEXT NOT NULL,
+    created_at BIGINT NOT NULL,
+    revoked_at BIGINT
+);
+CREATE INDEX idx_api_tokens_application_id ON api_tokens(application_id);
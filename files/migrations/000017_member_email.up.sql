@@ -0,0 +1,2 @@
+-- Optional contact email collected on join for applications that require it
+ALTER TABLE members ADD COLUMN email TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,12 @@
+-- Earlier versions of component data, kept to a bounded depth per component
+CREATE TABLE component_data_history (
+    id TEXT PRIMARY KEY,
+    component_id TEXT NOT NULL REFERENCES components(id) ON DELETE CASCADE,
+    application_id TEXT NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+    data TEXT,
+    event_id TEXT NOT NULL,
+    sequence_number BIGINT NOT NULL,
+    changed_by_public_key TEXT NOT NULL,
+    created_at BIGINT NOT NULL
+);
+CREATE INDEX idx_component_data_history_component ON component_data_history(component_id, sequence_number DESC);
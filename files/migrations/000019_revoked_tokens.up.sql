@@ -0,0 +1,6 @@
+-- JWT IDs invalidated by logout, kept until the token would have expired anyway
+CREATE TABLE revoked_tokens (
+    jti TEXT PRIMARY KEY,
+    expires_at BIGINT NOT NULL
+);
+CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
@@ -0,0 +1,8 @@
+-- Long-lived refresh tokens, stored as SHA-256 hashes and rotated on every use
+CREATE TABLE refresh_tokens (
+    token_hash TEXT PRIMARY KEY,
+    public_key TEXT NOT NULL REFERENCES users(public_key) ON DELETE CASCADE,
+    expires_at BIGINT NOT NULL,
+    created_at BIGINT NOT NULL
+);
+CREATE INDEX idx_refresh_tokens_public_key ON refresh_tokens(public_key);
@@ -0,0 +1,2 @@
+-- Last time the user was active, written at most once per throttle interval
+ALTER TABLE users ADD COLUMN last_seen_at BIGINT;
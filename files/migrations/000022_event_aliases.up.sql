@@ -0,0 +1,10 @@
+-- IDs of events merged into a later event by compaction, with the position they had,
+-- so sync cursors on them keep resolving and retries of them stay duplicates
+CREATE TABLE event_aliases (
+    id TEXT PRIMARY KEY,
+    event_id TEXT NOT NULL REFERENCES events(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    application_id TEXT,
+    sequence_number BIGINT,
+    created_at BIGINT NOT NULL
+);
+CREATE INDEX idx_event_aliases_event_id ON event_aliases(event_id);
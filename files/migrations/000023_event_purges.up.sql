@@ -0,0 +1,6 @@
+-- When each application's events were last purged, so sync cursors from before the
+-- purge are answered with a full resync instead of silently skipping the purged events
+CREATE TABLE event_purges (
+    application_id TEXT PRIMARY KEY,
+    purged_at BIGINT NOT NULL
+);
@@ -0,0 +1 @@
+ALTER TABLE application_sequences DROP COLUMN IF EXISTS event_count;
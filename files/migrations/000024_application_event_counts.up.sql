@@ -0,0 +1,10 @@
+-- Number of stored events per application, kept up to date in the transaction that
+-- stores or deletes events so the per-application event limit is enforced atomically
+ALTER TABLE application_sequences ADD COLUMN event_count BIGINT NOT NULL DEFAULT 0;
+
+INSERT INTO application_sequences (application_id, last_sequence, event_count)
+SELECT application_id, COALESCE(MAX(sequence_number), 0), COUNT(*)
+FROM events
+WHERE application_id IS NOT NULL
+GROUP BY application_id
+ON CONFLICT (application_id) DO UPDATE SET event_count = EXCLUDED.event_count;
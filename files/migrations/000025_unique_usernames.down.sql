@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_users_username;
+CREATE INDEX idx_users_username ON users(username);
@@ -0,0 +1,12 @@
+-- Usernames identify users at login, so they must be unique. Existing duplicates keep the
+-- name on the oldest account; later accounts get the start of their public key appended.
+UPDATE users
+SET username = users.username || '-' || LEFT(users.public_key, 8)
+FROM (
+    SELECT public_key, ROW_NUMBER() OVER (PARTITION BY username ORDER BY created_at, public_key) AS position
+    FROM users
+) ranked
+WHERE ranked.public_key = users.public_key AND ranked.position > 1;
+
+DROP INDEX IF EXISTS idx_users_username;
+CREATE UNIQUE INDEX idx_users_username ON users(username);
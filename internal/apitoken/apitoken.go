@@ -0,0 +1,70 @@
+package apitoken
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/prappser/prappser_server/internal/application"
+)
+
+const (
+	// TokenPrefix marks bearer credentials as API tokens rather than user JWTs
+	TokenPrefix = "prt_"
+
+	// principalPrefix forms the public key an API token acts as inside its application
+	principalPrefix = "apitoken:"
+)
+
+var (
+	ErrNotApplicationOwner = errors.New("not the owner of this application")
+	ErrInvalidToken        = errors.New("invalid api token")
+	ErrTokenNotFound       = errors.New("api token not found")
+	ErrTokenRevoked        = errors.New("api token revoked")
+)
+
+// APIToken is a long-lived credential bound to one application and a fixed role, used by
+// integrations instead of a member's interactive JWT. Only its hash is stored.
+type APIToken struct {
+	ID                 string                 `json:"id"`
+	ApplicationID      string                 `json:"applicationId"`
+	Name               string                 `json:"name"`
+	Role               application.MemberRole `json:"role"`
+	Token              string                 `json:"token,omitempty"`
+	TokenHash          string                 `json:"-"`
+	CreatedByPublicKey string                 `json:"createdByPublicKey"`
+	CreatedAt          int64                  `json:"createdAt"`
+	RevokedAt          *int64                 `json:"revokedAt,omitempty"`
+}
+
+// PrincipalPublicKey is the public key the token acts as. The token is registered as a
+// member of its application under this key, so event authorization treats it like any
+// other member holding its role.
+func (t *APIToken) PrincipalPublicKey() string {
+	return principalPrefix + t.ID
+}
+
+// IsPrincipal reports whether publicKey is the principal public key of an API token
+// rather than a user's key
+func IsPrincipal(publicKey string) bool {
+	return strings.HasPrefix(publicKey, principalPrefix)
+}
+
+// CreateAPITokenRequest represents the request body for POST /applications/{id}/tokens.
+// Role defaults to member; owner cannot be granted to a token.
+type CreateAPITokenRequest struct {
+	Name string                 `json:"name"`
+	Role application.MemberRole `json:"role,omitempty"`
+}
+
+// IsAPIToken reports whether a bearer credential is an API token
+func IsAPIToken(credential string) bool {
+	return strings.HasPrefix(credential, TokenPrefix)
+}
+
+// HashToken returns the stored form of a token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,111 @@
+package apitoken
+
+import (
+	"errors"
+
+	"github.com/goccy/go-json"
+	"github.com/prappser/prappser_server/internal/user"
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+)
+
+type APITokenEndpoints struct {
+	tokenService *APITokenService
+}
+
+func NewAPITokenEndpoints(tokenService *APITokenService) *APITokenEndpoints {
+	return &APITokenEndpoints{
+		tokenService: tokenService,
+	}
+}
+
+// CreateToken handles POST /applications/{appID}/tokens
+func (te *APITokenEndpoints) CreateToken(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	appID := ctx.UserValue("appID").(string)
+
+	var req CreateAPITokenRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		ctx.Error("Invalid request body", fasthttp.StatusBadRequest)
+		return
+	}
+
+	token, err := te.tokenService.CreateToken(appID, authenticatedUser.PublicKey, req)
+	if err != nil {
+		log.Error().Err(err).Str("appID", appID).Msg("Failed to create api token")
+		writeTokenError(ctx, err, "Failed to create api token")
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusCreated)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(token)
+}
+
+// ListTokens handles GET /applications/{appID}/tokens
+func (te *APITokenEndpoints) ListTokens(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	appID := ctx.UserValue("appID").(string)
+
+	tokens, err := te.tokenService.ListTokens(appID, authenticatedUser.PublicKey)
+	if err != nil {
+		log.Error().Err(err).Str("appID", appID).Msg("Failed to list api tokens")
+		writeTokenError(ctx, err, "Failed to list api tokens")
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(map[string]interface{}{
+		"tokens": tokens,
+	})
+}
+
+// RevokeToken handles DELETE /applications/{appID}/tokens/{tokenID}
+func (te *APITokenEndpoints) RevokeToken(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	appID := ctx.UserValue("appID").(string)
+	tokenID := ctx.UserValue("tokenID").(string)
+
+	if err := te.tokenService.RevokeToken(appID, tokenID, authenticatedUser.PublicKey); err != nil {
+		log.Error().Err(err).Str("tokenID", tokenID).Msg("Failed to revoke api token")
+		writeTokenError(ctx, err, "Failed to revoke api token")
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusNoContent)
+}
+
+func writeTokenError(ctx *fasthttp.RequestCtx, err error, fallback string) {
+	switch {
+	case errors.Is(err, ErrNotApplicationOwner):
+		ctx.Error("Only the application owner can manage api tokens", fasthttp.StatusForbidden)
+	case errors.Is(err, ErrInvalidToken):
+		ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+	case errors.Is(err, ErrTokenNotFound):
+		ctx.Error("API token not found", fasthttp.StatusNotFound)
+	case errors.Is(err, ErrTokenRevoked):
+		ctx.Error("API token already revoked", fasthttp.StatusConflict)
+	default:
+		ctx.Error(fallback, fasthttp.StatusInternalServerError)
+	}
+}
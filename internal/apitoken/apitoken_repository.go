@@ -0,0 +1,132 @@
+package apitoken
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/prappser/prappser_server/internal/application"
+)
+
+// APITokenRepository defines the interface for API token data access
+type APITokenRepository interface {
+	Create(token *APIToken) error
+	GetByID(id string) (*APIToken, error)
+	GetByHash(tokenHash string) (*APIToken, error)
+	GetByApplicationID(appID string) ([]*APIToken, error)
+	Revoke(id string, revokedAt int64) error
+}
+
+type apiTokenRepository struct {
+	db *sql.DB
+}
+
+func NewAPITokenRepository(db *sql.DB) *apiTokenRepository {
+	return &apiTokenRepository{db: db}
+}
+
+const apiTokenColumns = "id, application_id, name, role, token_hash, created_by_public_key, created_at, revoked_at"
+
+func (r *apiTokenRepository) Create(token *APIToken) error {
+	query := `
+		INSERT INTO api_tokens (
+			id, application_id, name, role, token_hash, created_by_public_key, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.Exec(query,
+		token.ID,
+		token.ApplicationID,
+		token.Name,
+		string(token.Role),
+		token.TokenHash,
+		token.CreatedByPublicKey,
+		token.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create api token: %w", err)
+	}
+	return nil
+}
+
+func (r *apiTokenRepository) GetByID(id string) (*APIToken, error) {
+	return r.getOne("SELECT "+apiTokenColumns+" FROM api_tokens WHERE id = $1", id)
+}
+
+func (r *apiTokenRepository) GetByHash(tokenHash string) (*APIToken, error) {
+	return r.getOne("SELECT "+apiTokenColumns+" FROM api_tokens WHERE token_hash = $1", tokenHash)
+}
+
+func (r *apiTokenRepository) getOne(query string, arg string) (*APIToken, error) {
+	token, err := scanAPIToken(r.db.QueryRow(query, arg))
+	if err == sql.ErrNoRows {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api token: %w", err)
+	}
+	return token, nil
+}
+
+func (r *apiTokenRepository) GetByApplicationID(appID string) ([]*APIToken, error) {
+	query := "SELECT " + apiTokenColumns + " FROM api_tokens WHERE application_id = $1 ORDER BY created_at"
+
+	rows, err := r.db.Query(query, appID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []*APIToken
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (r *apiTokenRepository) Revoke(id string, revokedAt int64) error {
+	result, err := r.db.Exec("UPDATE api_tokens SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL", id, revokedAt)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api token: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIToken(row rowScanner) (*APIToken, error) {
+	token := &APIToken{}
+	var role string
+	var revokedAt sql.NullInt64
+	err := row.Scan(
+		&token.ID,
+		&token.ApplicationID,
+		&token.Name,
+		&role,
+		&token.TokenHash,
+		&token.CreatedByPublicKey,
+		&token.CreatedAt,
+		&revokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	token.Role = application.MemberRole(role)
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Int64
+	}
+	return token, nil
+}
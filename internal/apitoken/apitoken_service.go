@@ -0,0 +1,206 @@
+package apitoken
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prappser/prappser_server/internal/application"
+	"github.com/prappser/prappser_server/internal/user"
+	"github.com/rs/zerolog/log"
+)
+
+// MembershipProducer adds and removes a token's membership through server-produced
+// member_added and member_removed events, so members and webhooks see tokens join and leave
+type MembershipProducer interface {
+	ProduceMemberAdded(ctx context.Context, appID, ownerPublicKey, memberPublicKey, memberName string, role application.MemberRole) error
+	ProduceMemberRemoved(ctx context.Context, appID, ownerPublicKey, memberPublicKey string) error
+}
+
+type APITokenService struct {
+	repo    APITokenRepository
+	appRepo application.ApplicationRepository
+	events  MembershipProducer
+}
+
+// NewAPITokenService creates the service. events may be nil, in which case memberships are
+// written directly without events.
+func NewAPITokenService(repo APITokenRepository, appRepo application.ApplicationRepository, events MembershipProducer) *APITokenService {
+	return &APITokenService{
+		repo:    repo,
+		appRepo: appRepo,
+		events:  events,
+	}
+}
+
+// CreateToken mints a token for the application and registers it as a member holding
+// the requested role. The returned token includes its secret value; later reads omit it.
+func (s *APITokenService) CreateToken(appID, requesterPublicKey string, req CreateAPITokenRequest) (*APIToken, error) {
+	if err := s.verifyOwner(appID, requesterPublicKey); err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidToken)
+	}
+	role := req.Role
+	if role == "" {
+		role = application.MemberRoleMember
+	}
+	if !role.IsValid() || role == application.MemberRoleOwner {
+		return nil, fmt.Errorf("%w: role must be admin, member or viewer", ErrInvalidToken)
+	}
+
+	secret, err := generateToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate api token: %w", err)
+	}
+
+	token := &APIToken{
+		ID:                 uuid.New().String(),
+		ApplicationID:      appID,
+		Name:               name,
+		Role:               role,
+		Token:              secret,
+		TokenHash:          HashToken(secret),
+		CreatedByPublicKey: requesterPublicKey,
+		CreatedAt:          time.Now().Unix(),
+	}
+
+	if err := s.repo.Create(token); err != nil {
+		return nil, err
+	}
+
+	if s.events != nil {
+		err = s.events.ProduceMemberAdded(context.Background(), appID, requesterPublicKey, token.PrincipalPublicKey(), name, role)
+	} else {
+		err = s.appRepo.CreateMember(&application.Member{
+			ID:            uuid.New().String(),
+			ApplicationID: appID,
+			Name:          name,
+			Role:          role,
+			PublicKey:     token.PrincipalPublicKey(),
+		})
+	}
+	if err != nil {
+		// A token without its membership could authenticate but never act; revoke it
+		s.repo.Revoke(token.ID, time.Now().Unix())
+		return nil, fmt.Errorf("failed to register api token as member: %w", err)
+	}
+
+	log.Info().
+		Str("tokenId", token.ID).
+		Str("applicationId", appID).
+		Str("role", string(role)).
+		Msg("[API_TOKEN] Token created")
+
+	return token, nil
+}
+
+// ListTokens returns the application's tokens, including revoked ones, without their values
+func (s *APITokenService) ListTokens(appID, requesterPublicKey string) ([]*APIToken, error) {
+	if err := s.verifyOwner(appID, requesterPublicKey); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.repo.GetByApplicationID(appID)
+	if err != nil {
+		return nil, err
+	}
+	if tokens == nil {
+		tokens = []*APIToken{}
+	}
+	return tokens, nil
+}
+
+// RevokeToken disables the token and removes its membership so it can no longer act
+// in the application
+func (s *APITokenService) RevokeToken(appID, tokenID, requesterPublicKey string) error {
+	if err := s.verifyOwner(appID, requesterPublicKey); err != nil {
+		return err
+	}
+
+	token, err := s.repo.GetByID(tokenID)
+	if err != nil {
+		return err
+	}
+	if token.ApplicationID != appID {
+		return ErrTokenNotFound
+	}
+	if token.RevokedAt != nil {
+		return ErrTokenRevoked
+	}
+
+	if err := s.repo.Revoke(tokenID, time.Now().Unix()); err != nil {
+		return err
+	}
+
+	if member, err := s.appRepo.GetMemberByPublicKey(appID, token.PrincipalPublicKey()); err == nil && member != nil {
+		if s.events != nil {
+			err = s.events.ProduceMemberRemoved(context.Background(), appID, requesterPublicKey, member.PublicKey)
+		} else {
+			err = s.appRepo.DeleteMember(member.ID)
+		}
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("tokenId", tokenID).
+				Msg("[API_TOKEN] Failed to remove membership of revoked token")
+		}
+	}
+
+	log.Info().
+		Str("tokenId", tokenID).
+		Str("applicationId", appID).
+		Msg("[API_TOKEN] Token revoked")
+
+	return nil
+}
+
+// IsAPIToken reports whether a bearer credential is an API token
+func (s *APITokenService) IsAPIToken(credential string) bool {
+	return IsAPIToken(credential)
+}
+
+// Authenticate resolves a bearer API token to the user it acts as and the application it
+// is bound to. The user carries no server role, so owner-only routes stay closed to it.
+func (s *APITokenService) Authenticate(credential string) (*user.User, string, error) {
+	if !IsAPIToken(credential) {
+		return nil, "", ErrInvalidToken
+	}
+
+	token, err := s.repo.GetByHash(HashToken(credential))
+	if err != nil {
+		return nil, "", err
+	}
+	if token.RevokedAt != nil {
+		return nil, "", ErrTokenRevoked
+	}
+
+	return &user.User{
+		PublicKey: token.PrincipalPublicKey(),
+		Username:  token.Name,
+		CreatedAt: token.CreatedAt,
+	}, token.ApplicationID, nil
+}
+
+// verifyOwner returns ErrNotApplicationOwner unless the public key belongs to the application's owner
+func (s *APITokenService) verifyOwner(appID, publicKey string) error {
+	if !application.HasMemberRole(s.appRepo, appID, publicKey, application.MemberRoleOwner) {
+		return ErrNotApplicationOwner
+	}
+	return nil
+}
+
+func generateToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return TokenPrefix + hex.EncodeToString(secret), nil
+}
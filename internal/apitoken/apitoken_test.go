@@ -0,0 +1,209 @@
+package apitoken
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prappser/prappser_server/internal/application"
+	"github.com/prappser/prappser_server/internal/middleware"
+	"github.com/prappser/prappser_server/internal/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	testAppID          = "test-app-id"
+	testOwnerPublicKey = "owner-public-key"
+	testMemberPubKey   = "member-public-key"
+)
+
+// mockAPITokenRepository for testing
+type mockAPITokenRepository struct {
+	tokens map[string]*APIToken
+}
+
+func newMockAPITokenRepository() *mockAPITokenRepository {
+	return &mockAPITokenRepository{tokens: make(map[string]*APIToken)}
+}
+
+func (m *mockAPITokenRepository) Create(token *APIToken) error {
+	copied := *token
+	copied.Token = ""
+	m.tokens[token.ID] = &copied
+	return nil
+}
+
+func (m *mockAPITokenRepository) GetByID(id string) (*APIToken, error) {
+	token, exists := m.tokens[id]
+	if !exists {
+		return nil, ErrTokenNotFound
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (m *mockAPITokenRepository) GetByHash(tokenHash string) (*APIToken, error) {
+	for _, token := range m.tokens {
+		if token.TokenHash == tokenHash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, ErrTokenNotFound
+}
+
+func (m *mockAPITokenRepository) GetByApplicationID(appID string) ([]*APIToken, error) {
+	var result []*APIToken
+	for _, token := range m.tokens {
+		if token.ApplicationID == appID {
+			copied := *token
+			result = append(result, &copied)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockAPITokenRepository) Revoke(id string, revokedAt int64) error {
+	token, exists := m.tokens[id]
+	if !exists || token.RevokedAt != nil {
+		return ErrTokenNotFound
+	}
+	token.RevokedAt = &revokedAt
+	return nil
+}
+
+// recordingMembershipProducer applies membership events to the repository and records
+// their types
+type recordingMembershipProducer struct {
+	appRepo *application.MemoryRepository
+	events  []string
+}
+
+func (p *recordingMembershipProducer) ProduceMemberAdded(ctx context.Context, appID, ownerPublicKey, memberPublicKey, memberName string, role application.MemberRole) error {
+	p.events = append(p.events, "member_added")
+	return p.appRepo.CreateMember(&application.Member{ID: "member-" + memberPublicKey, ApplicationID: appID, Name: memberName, Role: role, PublicKey: memberPublicKey})
+}
+
+func (p *recordingMembershipProducer) ProduceMemberRemoved(ctx context.Context, appID, ownerPublicKey, memberPublicKey string) error {
+	p.events = append(p.events, "member_removed")
+	member, err := p.appRepo.GetMemberByPublicKey(appID, memberPublicKey)
+	if err != nil {
+		return err
+	}
+	return p.appRepo.DeleteMember(member.ID)
+}
+
+func createTestAppRepository() *application.MemoryRepository {
+	appRepo := application.NewMemoryRepository()
+	appRepo.CreateApplication(&application.Application{ID: testAppID, Name: "Test App"})
+	appRepo.CreateMember(&application.Member{ID: "owner-member", ApplicationID: testAppID, Name: "owner", Role: application.MemberRoleOwner, PublicKey: testOwnerPublicKey})
+	appRepo.CreateMember(&application.Member{ID: "regular-member", ApplicationID: testAppID, Name: "member", Role: application.MemberRoleMember, PublicKey: testMemberPubKey})
+	return appRepo
+}
+
+// serveWithToken runs a request carrying the token through RequireAuth and returns the
+// response status and the user the handler saw
+func serveWithToken(service *APITokenService, path, token string) (int, *user.User) {
+	var seen *user.User
+	handler := middleware.NewAuthMiddleware(nil, service).RequireAuth(func(ctx *fasthttp.RequestCtx) {
+		seen, _ = ctx.UserValue("user").(*user.User)
+	})
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI(path)
+	ctx.Request.Header.Set("Authorization", "Bearer "+token)
+	handler(ctx)
+	return ctx.Response.StatusCode(), seen
+}
+
+func TestCreateToken_ShouldRegisterTokenAsMemberWithRole(t *testing.T) {
+	// given
+	appRepo := createTestAppRepository()
+	service := NewAPITokenService(newMockAPITokenRepository(), appRepo, nil)
+
+	// when
+	token, err := service.CreateToken(testAppID, testOwnerPublicKey, CreateAPITokenRequest{Name: "CI", Role: application.MemberRoleAdmin})
+
+	// then
+	assert.NoError(t, err)
+	assert.True(t, IsAPIToken(token.Token))
+	member, err := appRepo.GetMemberByPublicKey(testAppID, token.PrincipalPublicKey())
+	assert.NoError(t, err)
+	assert.Equal(t, application.MemberRoleAdmin, member.Role)
+	assert.True(t, IsPrincipal(member.PublicKey))
+	assert.False(t, IsPrincipal(testOwnerPublicKey))
+	listed, _ := service.ListTokens(testAppID, testOwnerPublicKey)
+	assert.Empty(t, listed[0].Token)
+}
+
+func TestCreateToken_ShouldRejectNonOwnerAndOwnerRole(t *testing.T) {
+	// given
+	service := NewAPITokenService(newMockAPITokenRepository(), createTestAppRepository(), nil)
+
+	// when
+	_, memberErr := service.CreateToken(testAppID, testMemberPubKey, CreateAPITokenRequest{Name: "CI"})
+	_, roleErr := service.CreateToken(testAppID, testOwnerPublicKey, CreateAPITokenRequest{Name: "CI", Role: application.MemberRoleOwner})
+
+	// then
+	assert.True(t, errors.Is(memberErr, ErrNotApplicationOwner))
+	assert.True(t, errors.Is(roleErr, ErrInvalidToken))
+}
+
+func TestRequireAuth_ShouldAcceptAPITokenWithinItsApplication(t *testing.T) {
+	// given
+	service := NewAPITokenService(newMockAPITokenRepository(), createTestAppRepository(), nil)
+	token, _ := service.CreateToken(testAppID, testOwnerPublicKey, CreateAPITokenRequest{Name: "CI"})
+
+	// when
+	eventsStatus, eventsUser := serveWithToken(service, "/events", token.Token)
+	otherStatus, _ := serveWithToken(service, "/applications/other-app/state", token.Token)
+	unknownStatus, _ := serveWithToken(service, "/events", TokenPrefix+"unknown")
+
+	// then
+	assert.Equal(t, fasthttp.StatusOK, eventsStatus)
+	assert.Equal(t, token.PrincipalPublicKey(), eventsUser.PublicKey)
+	assert.Empty(t, eventsUser.Role)
+	assert.Equal(t, fasthttp.StatusForbidden, otherStatus)
+	assert.Equal(t, fasthttp.StatusUnauthorized, unknownStatus)
+}
+
+func TestRevokeToken_ShouldRejectFurtherAuthentication(t *testing.T) {
+	// given
+	appRepo := createTestAppRepository()
+	service := NewAPITokenService(newMockAPITokenRepository(), appRepo, nil)
+	token, _ := service.CreateToken(testAppID, testOwnerPublicKey, CreateAPITokenRequest{Name: "CI"})
+
+	// when
+	err := service.RevokeToken(testAppID, token.ID, testOwnerPublicKey)
+
+	// then
+	assert.NoError(t, err)
+	_, _, authErr := service.Authenticate(token.Token)
+	assert.True(t, errors.Is(authErr, ErrTokenRevoked))
+	status, _ := serveWithToken(service, "/events", token.Token)
+	assert.Equal(t, fasthttp.StatusUnauthorized, status)
+	isMember, _ := appRepo.IsMember(testAppID, token.PrincipalPublicKey())
+	assert.False(t, isMember)
+	assert.True(t, errors.Is(service.RevokeToken(testAppID, token.ID, testOwnerPublicKey), ErrTokenRevoked))
+}
+
+func TestCreateAndRevokeToken_ShouldProduceMembershipEvents(t *testing.T) {
+	// given
+	appRepo := createTestAppRepository()
+	producer := &recordingMembershipProducer{appRepo: appRepo}
+	service := NewAPITokenService(newMockAPITokenRepository(), appRepo, producer)
+
+	// when
+	token, createErr := service.CreateToken(testAppID, testOwnerPublicKey, CreateAPITokenRequest{Name: "CI"})
+	isMemberAfterCreate, _ := appRepo.IsMember(testAppID, token.PrincipalPublicKey())
+	revokeErr := service.RevokeToken(testAppID, token.ID, testOwnerPublicKey)
+
+	// then
+	assert.NoError(t, createErr)
+	assert.NoError(t, revokeErr)
+	assert.True(t, isMemberAfterCreate)
+	assert.Equal(t, []string{"member_added", "member_removed"}, producer.events)
+	isMember, _ := appRepo.IsMember(testAppID, token.PrincipalPublicKey())
+	assert.False(t, isMember)
+}
@@ -1,8 +1,29 @@
 package application
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/prappser/prappser_server/internal/clock"
+)
+
+var (
+	ErrInvalidMemberRole = errors.New("invalid member role")
+	ErrTooManyAdmins     = errors.New("too many admin members")
+	ErrNotOwner          = errors.New("not the owner of this application")
+	ErrMemberNotFound    = errors.New("member not found")
+	ErrMemberExists      = errors.New("public key is already a member of this application")
+	ErrInvalidRekeyProof = errors.New("invalid rekey proof")
+	ErrInvalidAppID      = errors.New("invalid application ID")
+	ErrInvalidFields     = errors.New("invalid field selection")
+	ErrGroupNotFound     = errors.New("component group not found")
+	ErrInvalidUpdate     = errors.New("invalid application update")
+	ErrLastOwner         = errors.New("application would be left without an owner")
+	ErrInvalidTransfer   = errors.New("invalid ownership transfer")
+	// ErrTokenOwner rejects making an API token principal an owner of its application
+	ErrTokenOwner = fmt.Errorf("%w: an API token cannot be an owner", ErrInvalidMemberRole)
 )
 
 type Application struct {
@@ -16,6 +37,7 @@ type Application struct {
 	ComponentGroups []ComponentGroup `json:"componentGroups"`
 	Members         []Member         `json:"members"`
 	LastSequence    *int64           `json:"lastSequence,omitempty"`
+	PollingOnly     bool             `json:"pollingOnly,omitempty"`
 }
 
 type ComponentGroup struct {
@@ -35,10 +57,26 @@ type Component struct {
 	Index            int                    `json:"index"`
 }
 
+// ComponentDataVersion is an earlier state of a component's data, captured when a change
+// replaced it
+type ComponentDataVersion struct {
+	ID            string                 `json:"id"`
+	ComponentID   string                 `json:"componentId"`
+	ApplicationID string                 `json:"applicationId"`
+	Data          map[string]interface{} `json:"data"`
+	// EventID and SequenceNumber identify the change that replaced this data
+	EventID            string `json:"eventId"`
+	SequenceNumber     int64  `json:"sequenceNumber"`
+	ChangedByPublicKey string `json:"changedByPublicKey"`
+	CreatedAt          int64  `json:"createdAt"`
+}
+
 type ApplicationState struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	UpdatedAt int64  `json:"updatedAt"`
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	UpdatedAt    int64  `json:"updatedAt"`
+	LastSequence *int64 `json:"lastSequence,omitempty"`
+	PollingOnly  bool   `json:"pollingOnly,omitempty"`
 }
 
 type MemberRole string
@@ -50,13 +88,145 @@ const (
 	MemberRoleViewer MemberRole = "viewer"
 )
 
+// IsValid reports whether the role is one of the known member roles
+func (r MemberRole) IsValid() bool {
+	switch r {
+	case MemberRoleOwner, MemberRoleAdmin, MemberRoleMember, MemberRoleViewer:
+		return true
+	default:
+		return false
+	}
+}
+
+// PollingOnlyApps lists applications that get no WebSocket broadcasts; their clients
+// poll GET /events instead. The entry "*" matches every application.
+type PollingOnlyApps []string
+
+// Contains reports whether the application is polling-only
+func (p PollingOnlyApps) Contains(appID string) bool {
+	for _, id := range p {
+		if id == "*" || id == appID {
+			return true
+		}
+	}
+	return false
+}
+
+// AppIDPolicy decides where the ID of a registered application comes from
+type AppIDPolicy string
+
+const (
+	AppIDPolicyClient AppIDPolicy = "client" // the client's ID is used as sent
+	AppIDPolicyUUID   AppIDPolicy = "uuid"   // the client's ID must be a UUID
+	AppIDPolicyServer AppIDPolicy = "server" // the server generates the ID, ignoring the client's
+)
+
+func (p AppIDPolicy) IsValid() bool {
+	switch p {
+	case AppIDPolicyClient, AppIDPolicyUUID, AppIDPolicyServer:
+		return true
+	}
+	return false
+}
+
+// Config holds application registration policy
+type Config struct {
+	DefaultMemberRole MemberRole      // assigned to registered members that omit a role
+	MaxAdmins         int             // maximum admin members per application (0 = unlimited)
+	PollingOnlyApps   PollingOnlyApps // applications without real-time broadcasts
+	AppIDPolicy       AppIDPolicy     // how registered application IDs are chosen
+	Clock             clock.Clock     // tells the current time; nil selects clock.System
+}
+
+// RegisterApplicationResponse tells the client the ID its application was registered under
+type RegisterApplicationResponse struct {
+	ID string `json:"id"`
+}
+
+// ApplicationFields selects the parts of an application GET /applications/{id} returns.
+// The application's own fields are always included; unselected parts come back empty.
+type ApplicationFields struct {
+	Members bool
+	Groups  bool
+	GroupID string // limits Groups to this component group
+}
+
+// ParseApplicationFields parses the comma-separated fields query parameter ("members",
+// "groups") and the groupId parameter, which on its own selects just that group
+func ParseApplicationFields(fields, groupID string) (ApplicationFields, error) {
+	selection := ApplicationFields{GroupID: groupID, Groups: groupID != ""}
+	if fields == "" {
+		return selection, nil
+	}
+	for _, field := range strings.Split(fields, ",") {
+		switch strings.TrimSpace(field) {
+		case "members":
+			selection.Members = true
+		case "groups":
+			selection.Groups = true
+		default:
+			return ApplicationFields{}, fmt.Errorf("%w: unknown field %q", ErrInvalidFields, field)
+		}
+	}
+	return selection, nil
+}
+
 type Member struct {
 	ID              string     `json:"id,omitempty"`
 	ApplicationID   string     `json:"applicationId"`
 	Name            string     `json:"name"`
+	Email           string     `json:"email,omitempty"`
 	Role            MemberRole `json:"role"`
 	PublicKey       string     `json:"publicKey"`
 	AvatarStorageID *string    `json:"avatarStorageId,omitempty"`
+	// LastSeenAt is when the member's user was last active; only set in member listings
+	LastSeenAt *int64 `json:"lastSeenAt,omitempty"`
+}
+
+const (
+	DefaultMembersPageSize = 50
+	MaxMembersPageSize     = 200
+)
+
+// MembersPage is one page of an application's members
+type MembersPage struct {
+	Members []*Member `json:"members"`
+	Total   int       `json:"total"`
+	Limit   int       `json:"limit"`
+	Offset  int       `json:"offset"`
+}
+
+// UpdateApplicationRequest represents the request body for PATCH /applications/{id}.
+// Omitted fields are left unchanged.
+type UpdateApplicationRequest struct {
+	Name *string `json:"name,omitempty"`
+	Icon *string `json:"icon,omitempty"`
+}
+
+// ChangeMemberRoleRequest represents the request body for
+// PATCH /applications/{id}/members/{memberId}/role.
+type ChangeMemberRoleRequest struct {
+	Role MemberRole `json:"role"`
+}
+
+// TransferOwnershipRequest represents the request body for
+// POST /applications/{id}/transfer-ownership.
+type TransferOwnershipRequest struct {
+	NewOwnerPublicKey string `json:"newOwnerPublicKey"`
+}
+
+// RekeyMemberRequest represents the request body for
+// POST /applications/{id}/members/{publicKey}/rekey.
+// Signature is the base64 Ed25519 signature of RekeyProofMessage by the new key.
+type RekeyMemberRequest struct {
+	NewPublicKey string `json:"newPublicKey"`
+	Signature    string `json:"signature"`
+}
+
+// RekeyProofMessage returns the message the new key signs to prove possession when a
+// member is moved from oldPublicKey to newPublicKey
+func RekeyProofMessage(appID, oldPublicKey, newPublicKey string) []byte {
+	return []byte("prappser-rekey:" + appID + ":" + oldPublicKey + ":" + newPublicKey)
 }
 
 // AppVersionInfo holds version tracking data for an application.
@@ -65,6 +235,28 @@ type AppVersionInfo struct {
 	LastSequence *int64
 }
 
+// moveToIndex returns ids in their new order after moving id to index, clamped to the
+// list bounds. The position of each id in the result is its new, unique index.
+func moveToIndex(ids []string, id string, index int) []string {
+	reordered := make([]string, 0, len(ids))
+	for _, existing := range ids {
+		if existing != id {
+			reordered = append(reordered, existing)
+		}
+	}
+
+	index = min(max(index, 0), len(reordered))
+	reordered = append(reordered, "")
+	copy(reordered[index+1:], reordered[index:])
+	reordered[index] = id
+	return reordered
+}
+
+// keepOrder leaves ids in their current order, closing any gaps in their indices
+func keepOrder(ids []string) []string {
+	return ids
+}
+
 func (a *Application) UpdateTimestamp() {
 	a.UpdatedAt = time.Now().Unix()
 }
@@ -1,7 +1,13 @@
 package application
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/goccy/go-json"
+	"github.com/prappser/prappser_server/internal/middleware"
 	"github.com/prappser/prappser_server/internal/user"
 	"github.com/rs/zerolog/log"
 	"github.com/valyala/fasthttp"
@@ -37,11 +43,7 @@ func (ae *ApplicationEndpoints) RegisterApplication(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	// Validate request
-	if app.ID == "" {
-		ctx.Error("Application ID is required", fasthttp.StatusBadRequest)
-		return
-	}
+	// Validate request (the service validates the ID against the configured ID policy)
 	if app.Name == "" {
 		ctx.Error("Application name is required", fasthttp.StatusBadRequest)
 		return
@@ -56,15 +58,22 @@ func (ae *ApplicationEndpoints) RegisterApplication(ctx *fasthttp.RequestCtx) {
 	app.ServerPublicKey = &ae.serverPublicKey
 
 	// Register the application
-	_, err := ae.appService.RegisterApplication(authenticatedUser.PublicKey, &app)
+	registered, err := ae.appService.RegisterApplication(authenticatedUser.PublicKey, &app)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to register application")
+		if errors.Is(err, ErrInvalidMemberRole) || errors.Is(err, ErrTooManyAdmins) || errors.Is(err, ErrInvalidAppID) {
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+			return
+		}
 		ctx.Error("Failed to register application", fasthttp.StatusInternalServerError)
 		return
 	}
 
-	// Return created with no content
+	// Return the ID, which the server may have generated
+	response, _ := json.Marshal(RegisterApplicationResponse{ID: registered.ID})
+	ctx.SetContentType("application/json")
 	ctx.SetStatusCode(fasthttp.StatusCreated)
+	ctx.SetBody(response)
 }
 
 // ListApplications handles GET /applications
@@ -91,7 +100,32 @@ func (ae *ApplicationEndpoints) ListApplications(ctx *fasthttp.RequestCtx) {
 	json.NewEncoder(ctx).Encode(apps)
 }
 
+// CountApplications handles GET /applications/count
+func (ae *ApplicationEndpoints) CountApplications(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	count, err := ae.appService.CountApplications(authenticatedUser.PublicKey)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to count applications")
+		ctx.Error("Failed to count applications", fasthttp.StatusInternalServerError)
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(map[string]int{"count": count})
+}
+
 // GetApplication handles GET /applications/{id}
+// Query parameters:
+//   - members (optional): "false" omits members; page through GET /applications/{id}/members instead
+//   - fields (optional): comma-separated parts to return ("members", "groups"); others come back empty
+//   - groupId (optional): return only this component group
 func (ae *ApplicationEndpoints) GetApplication(ctx *fasthttp.RequestCtx) {
 	// Get authenticated user from context
 	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
@@ -109,12 +143,30 @@ func (ae *ApplicationEndpoints) GetApplication(ctx *fasthttp.RequestCtx) {
 	}
 
 	// Get the application
-	app, err := ae.appService.GetApplication(appID, authenticatedUser)
+	var app *Application
+	var err error
+	query := ctx.QueryArgs()
+	if query.Has("fields") || query.Has("groupId") {
+		fields, parseErr := ParseApplicationFields(string(query.Peek("fields")), string(query.Peek("groupId")))
+		if parseErr != nil {
+			ctx.Error(parseErr.Error(), fasthttp.StatusBadRequest)
+			return
+		}
+		app, err = ae.appService.GetApplicationFields(appID, authenticatedUser, fields)
+	} else if string(query.Peek("members")) == "false" {
+		app, err = ae.appService.GetApplicationWithoutMembers(appID, authenticatedUser)
+	} else {
+		app, err = ae.appService.GetApplication(appID, authenticatedUser)
+	}
 	if err != nil {
 		if err.Error() == "unauthorized" {
 			ctx.Error("Forbidden", fasthttp.StatusForbidden)
 			return
 		}
+		if errors.Is(err, ErrGroupNotFound) {
+			ctx.Error("Component group not found", fasthttp.StatusNotFound)
+			return
+		}
 		log.Error().Err(err).Msg("Failed to get application")
 		ctx.Error("Application not found", fasthttp.StatusNotFound)
 		return
@@ -155,12 +207,50 @@ func (ae *ApplicationEndpoints) GetApplicationState(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
+	// Let polling clients skip the body when nothing changed since their last fetch
+	etag := stateETag(state)
+	lastModified := time.Unix(state.UpdatedAt, 0)
+	ctx.Response.Header.Set("ETag", etag)
+	ctx.Response.Header.Set("Last-Modified", string(fasthttp.AppendHTTPDate(nil, lastModified)))
+	if isStateNotModified(&ctx.Request.Header, etag, lastModified) {
+		ctx.SetStatusCode(fasthttp.StatusNotModified)
+		return
+	}
+
 	// Return state
 	ctx.SetStatusCode(fasthttp.StatusOK)
 	ctx.SetContentType("application/json")
 	json.NewEncoder(ctx).Encode(state)
 }
 
+// stateETag derives a validator for the application state from its last event sequence,
+// which unlike the second-resolution updated_at changes with every applied event
+func stateETag(state *ApplicationState) string {
+	var lastSequence int64
+	if state.LastSequence != nil {
+		lastSequence = *state.LastSequence
+	}
+	return fmt.Sprintf(`"%s-%d"`, state.ID, lastSequence)
+}
+
+// isStateNotModified reports whether the request's conditional headers match the current state.
+// If-None-Match takes precedence over If-Modified-Since, as in RFC 9110.
+func isStateNotModified(header *fasthttp.RequestHeader, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := header.Peek("If-None-Match"); len(ifNoneMatch) > 0 {
+		return string(ifNoneMatch) == "*" || string(ifNoneMatch) == etag || string(ifNoneMatch) == "W/"+etag
+	}
+
+	if ifModifiedSince := header.Peek("If-Modified-Since"); len(ifModifiedSince) > 0 {
+		since, err := fasthttp.ParseHTTPDate(ifModifiedSince)
+		if err != nil {
+			return false
+		}
+		return !lastModified.After(since)
+	}
+
+	return false
+}
+
 // DeleteApplication handles DELETE /applications/{id}
 func (ae *ApplicationEndpoints) DeleteApplication(ctx *fasthttp.RequestCtx) {
 	// Get authenticated user from context
@@ -179,7 +269,7 @@ func (ae *ApplicationEndpoints) DeleteApplication(ctx *fasthttp.RequestCtx) {
 	}
 
 	// Delete the application
-	err := ae.appService.DeleteApplication(appID, authenticatedUser)
+	err := ae.appService.DeleteApplication(middleware.RequestContext(ctx), appID, authenticatedUser)
 	if err != nil {
 		if err.Error() == "unauthorized" {
 			ctx.Error("Forbidden", fasthttp.StatusForbidden)
@@ -240,3 +330,231 @@ func (ae *ApplicationEndpoints) LeaveApplication(ctx *fasthttp.RequestCtx) {
 	}
 	json.NewEncoder(ctx).Encode(response)
 }
+
+// ListMembers handles GET /applications/{id}/members
+// Query parameters:
+//   - limit (optional, default: 50, max: 200): Maximum members to return
+//   - offset (optional, default: 0): Number of members to skip
+//   - role (optional): Only return members with this role
+func (ae *ApplicationEndpoints) ListMembers(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	appID := ctx.UserValue("appID").(string)
+	if appID == "" {
+		log.Error().Msg("Missing application ID")
+		ctx.Error("Application ID is required", fasthttp.StatusBadRequest)
+		return
+	}
+
+	pagination := middleware.ParsePagination(ctx, DefaultMembersPageSize, MaxMembersPageSize)
+	role := MemberRole(ctx.QueryArgs().Peek("role"))
+
+	page, err := ae.appService.ListMembers(appID, authenticatedUser, pagination.Limit, pagination.Offset, role)
+	if err != nil {
+		log.Error().Err(err).Str("appID", appID).Msg("Failed to list members")
+		switch {
+		case errors.Is(err, ErrInvalidMemberRole):
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+		case strings.HasPrefix(err.Error(), "unauthorized"):
+			ctx.Error("Forbidden", fasthttp.StatusForbidden)
+		default:
+			ctx.Error("Failed to list members", fasthttp.StatusInternalServerError)
+		}
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(page)
+}
+
+// UpdateApplication handles PATCH /applications/{id}
+func (ae *ApplicationEndpoints) UpdateApplication(ctx *fasthttp.RequestCtx) {
+	// Get authenticated user from context
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	appID := ctx.UserValue("appID").(string)
+	if appID == "" {
+		ctx.Error("Application ID is required", fasthttp.StatusBadRequest)
+		return
+	}
+
+	var req UpdateApplicationRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		ctx.Error("Invalid request body", fasthttp.StatusBadRequest)
+		return
+	}
+
+	app, err := ae.appService.UpdateApplication(middleware.RequestContext(ctx), appID, req, authenticatedUser)
+	if err != nil {
+		log.Error().Err(err).Str("appID", appID).Msg("Failed to update application")
+		switch {
+		case errors.Is(err, ErrNotOwner):
+			ctx.Error("Only the application owner can update the application", fasthttp.StatusForbidden)
+		case errors.Is(err, ErrInvalidUpdate):
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+		case err.Error() == "application not found":
+			ctx.Error("Application not found", fasthttp.StatusNotFound)
+		default:
+			ctx.Error("Failed to update application", fasthttp.StatusInternalServerError)
+		}
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(app)
+}
+
+// ChangeMemberRole handles PATCH /applications/{id}/members/{memberId}/role
+func (ae *ApplicationEndpoints) ChangeMemberRole(ctx *fasthttp.RequestCtx) {
+	// Get authenticated user from context
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	appID := ctx.UserValue("appID").(string)
+	memberID := ctx.UserValue("memberID").(string)
+	if appID == "" || memberID == "" {
+		log.Error().Msg("Missing application ID or member ID")
+		ctx.Error("Application ID and member ID are required", fasthttp.StatusBadRequest)
+		return
+	}
+
+	var req ChangeMemberRoleRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		ctx.Error("Invalid request body", fasthttp.StatusBadRequest)
+		return
+	}
+
+	member, err := ae.appService.ChangeMemberRole(middleware.RequestContext(ctx), appID, memberID, req.Role, authenticatedUser)
+	if err != nil {
+		log.Error().Err(err).Str("appID", appID).Str("memberID", memberID).Msg("Failed to change member role")
+		switch {
+		case errors.Is(err, ErrNotOwner):
+			ctx.Error("Only the application owner can change member roles", fasthttp.StatusForbidden)
+		case errors.Is(err, ErrMemberNotFound):
+			ctx.Error("Member not found", fasthttp.StatusNotFound)
+		case errors.Is(err, ErrInvalidMemberRole):
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+		case errors.Is(err, ErrLastOwner), errors.Is(err, ErrTooManyAdmins):
+			ctx.Error(err.Error(), fasthttp.StatusConflict)
+		default:
+			ctx.Error("Failed to change member role", fasthttp.StatusInternalServerError)
+		}
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(member)
+}
+
+// TransferOwnership handles POST /applications/{id}/transfer-ownership
+func (ae *ApplicationEndpoints) TransferOwnership(ctx *fasthttp.RequestCtx) {
+	// Get authenticated user from context
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	appID := ctx.UserValue("appID").(string)
+	if appID == "" {
+		ctx.Error("Application ID is required", fasthttp.StatusBadRequest)
+		return
+	}
+
+	var req TransferOwnershipRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		ctx.Error("Invalid request body", fasthttp.StatusBadRequest)
+		return
+	}
+
+	app, err := ae.appService.TransferOwnership(middleware.RequestContext(ctx), appID, req, authenticatedUser)
+	if err != nil {
+		log.Error().Err(err).Str("appID", appID).Msg("Failed to transfer ownership")
+		switch {
+		case errors.Is(err, ErrNotOwner):
+			ctx.Error("Only the application owner can transfer ownership", fasthttp.StatusForbidden)
+		case errors.Is(err, ErrMemberNotFound):
+			ctx.Error("New owner must be a member of the application", fasthttp.StatusNotFound)
+		case errors.Is(err, ErrInvalidTransfer), errors.Is(err, ErrTokenOwner):
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+		case errors.Is(err, ErrTooManyAdmins):
+			ctx.Error(err.Error(), fasthttp.StatusConflict)
+		default:
+			ctx.Error("Failed to transfer ownership", fasthttp.StatusInternalServerError)
+		}
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(app)
+}
+
+// RekeyMember handles POST /applications/{id}/members/{publicKey}/rekey
+func (ae *ApplicationEndpoints) RekeyMember(ctx *fasthttp.RequestCtx) {
+	// Get authenticated user from context
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	appID := ctx.UserValue("appID").(string)
+	memberPublicKey := ctx.UserValue("memberPublicKey").(string)
+	if appID == "" || memberPublicKey == "" {
+		log.Error().Msg("Missing application ID or member public key")
+		ctx.Error("Application ID and member public key are required", fasthttp.StatusBadRequest)
+		return
+	}
+
+	var req RekeyMemberRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		ctx.Error("Invalid request body", fasthttp.StatusBadRequest)
+		return
+	}
+
+	member, err := ae.appService.RekeyMember(middleware.RequestContext(ctx), appID, memberPublicKey, req, authenticatedUser)
+	if err != nil {
+		log.Error().Err(err).Str("appID", appID).Msg("Failed to rekey member")
+		switch {
+		case errors.Is(err, ErrNotOwner):
+			ctx.Error("Only the application owner can rekey members", fasthttp.StatusForbidden)
+		case errors.Is(err, ErrMemberNotFound):
+			ctx.Error("Member not found", fasthttp.StatusNotFound)
+		case errors.Is(err, ErrMemberExists):
+			ctx.Error(err.Error(), fasthttp.StatusConflict)
+		case errors.Is(err, ErrInvalidRekeyProof):
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+		default:
+			ctx.Error("Failed to rekey member", fasthttp.StatusInternalServerError)
+		}
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(member)
+}
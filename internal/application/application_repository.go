@@ -1,8 +1,16 @@
 package application
 
+import "database/sql"
+
 type ApplicationRepository interface {
 	CreateApplication(app *Application) error
 	GetApplicationByID(id string) (*Application, error)
+	// GetApplicationByIDWithoutMembers loads the application like GetApplicationByID but
+	// leaves Members empty, for large applications whose members are paged separately
+	GetApplicationByIDWithoutMembers(id string) (*Application, error)
+	// GetApplicationMetadata loads only the application's own fields, without component
+	// groups or members, for callers that assemble the parts they need themselves
+	GetApplicationMetadata(id string) (*Application, error)
 	GetApplicationState(id string) (*ApplicationState, error)
 	UpdateApplicationTimestamp(id string) error
 	DeleteApplication(id string) error
@@ -17,6 +25,12 @@ type ApplicationRepository interface {
 	UpdateComponentData(componentID string, data map[string]interface{}) error
 	UpdateComponentIndex(componentID string, index int) error
 	DeleteComponent(componentID string) error
+	// AddComponentDataVersion stores an earlier version of a component's data and drops
+	// the component's oldest versions beyond keep
+	AddComponentDataVersion(version *ComponentDataVersion, keep int) error
+	// GetComponentDataHistory returns up to limit earlier versions, most recent first
+	GetComponentDataHistory(componentID string, limit int) ([]*ComponentDataVersion, error)
+	GetComponentDataVersion(versionID string) (*ComponentDataVersion, error)
 
 	GetComponentGroupByID(groupID string) (*ComponentGroup, error)
 	UpdateComponentGroupIndex(groupID string, index int) error
@@ -24,6 +38,9 @@ type ApplicationRepository interface {
 	
 	CreateMember(member *Member) error
 	GetMembersByApplicationID(appID string) ([]*Member, error)
+	// GetMembersByApplicationIDPaged returns one page of members, optionally limited to a
+	// role (empty matches every role), and the total number of matching members
+	GetMembersByApplicationIDPaged(appID string, limit, offset int, roleFilter MemberRole) ([]*Member, int, error)
 	GetMemberByID(memberID string) (*Member, error)
 	GetMemberByPublicKey(appID, publicKey string) (*Member, error)
 	UpdateMember(member *Member) error
@@ -32,6 +49,9 @@ type ApplicationRepository interface {
 
 	// Invitation-related methods
 	GetApplicationsByMemberPublicKey(publicKey string) ([]*Application, error)
+	// CountApplicationsByMemberPublicKey counts the non-deleted apps the user is a member
+	// of without loading them
+	CountApplicationsByMemberPublicKey(publicKey string) (int, error)
 	// GetAppVersionsByMemberPublicKey returns a lightweight map of app ID → version info
 	// for all non-deleted apps the user is a member of. Used by the poll path to avoid
 	// full N+1 application loads.
@@ -41,4 +61,21 @@ type ApplicationRepository interface {
 	UpdateApplicationMetadata(id, name string, icon *string) error
 	// UpdateLastSequence stores the last processed sequence number for drift detection.
 	UpdateLastSequence(appID string, sequence int64) error
+	// WithTx returns a repository whose changes are part of tx
+	WithTx(tx *sql.Tx) ApplicationRepository
+}
+
+// HasMemberRole reports whether publicKey belongs to a member of the application that
+// holds one of roles. A failed lookup counts as not holding the role.
+func HasMemberRole(repo ApplicationRepository, appID, publicKey string, roles ...MemberRole) bool {
+	member, err := repo.GetMemberByPublicKey(appID, publicKey)
+	if err != nil || member == nil {
+		return false
+	}
+	for _, role := range roles {
+		if member.Role == role {
+			return true
+		}
+	}
+	return false
 }
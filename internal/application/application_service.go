@@ -1,10 +1,16 @@
 package application
 
 import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
 	"fmt"
-	"time"
+	"strings"
 
+	"github.com/google/uuid"
+	"github.com/prappser/prappser_server/internal/clock"
 	"github.com/prappser/prappser_server/internal/user"
+	"github.com/rs/zerolog/log"
 )
 
 // Event represents a domain event (avoiding import cycle with event package)
@@ -18,28 +24,60 @@ type Event struct {
 	SequenceNumber   int64
 }
 
-// EventProducer interface removed - events are now client-produced via POST /events
-// Server only validates, sequences, and applies events
+// EventProducer applies changes made over REST through server-produced events, so they
+// are sequenced and broadcast to subscribers and syncing clients like events submitted
+// via POST /events
+type EventProducer interface {
+	ProduceApplicationCreated(ctx context.Context, appID, ownerPublicKey, name string) error
+	ProduceApplicationDeleted(ctx context.Context, appID, ownerPublicKey string) error
+	ProduceApplicationDataChanged(ctx context.Context, appID, ownerPublicKey, name string, icon *string) error
+	ProduceMemberRoleChanged(ctx context.Context, appID, ownerPublicKey, memberPublicKey string, oldRole, newRole MemberRole) error
+	ProduceOwnershipTransfer(ctx context.Context, appID, ownerPublicKey, newOwnerPublicKey string, newOwnerRole MemberRole) error
+	ProduceMemberRekeyed(ctx context.Context, appID, ownerPublicKey, oldPublicKey, newPublicKey string) error
+}
 
 type ApplicationService struct {
-	appRepo ApplicationRepository
+	appRepo  ApplicationRepository
+	userRepo user.UserRepository
+	events   EventProducer
+	config   Config
+	clock    clock.Clock
 }
 
-func NewApplicationService(appRepo ApplicationRepository) *ApplicationService {
+// NewApplicationService creates the service. Without an event producer, changes made over
+// REST are written to the repository directly and not broadcast.
+func NewApplicationService(appRepo ApplicationRepository, userRepo user.UserRepository, events EventProducer, config Config) *ApplicationService {
+	if config.DefaultMemberRole == "" {
+		config.DefaultMemberRole = MemberRoleMember
+	}
+	if config.AppIDPolicy == "" {
+		config.AppIDPolicy = AppIDPolicyClient
+	}
+	if config.Clock == nil {
+		config.Clock = clock.System
+	}
 	return &ApplicationService{
-		appRepo: appRepo,
+		appRepo:  appRepo,
+		userRepo: userRepo,
+		events:   events,
+		config:   config,
+		clock:    config.Clock,
 	}
 }
 
 func (s *ApplicationService) RegisterApplication(ownerPublicKey string, app *Application) (*Application, error) {
 	// Validate application
-	if app.ID == "" {
-		return nil, fmt.Errorf("application ID cannot be empty")
+	if err := s.assignApplicationID(app); err != nil {
+		return nil, err
 	}
 	if app.Name == "" {
 		return nil, fmt.Errorf("application name cannot be empty")
 	}
 
+	if err := s.validateMemberRoles(app.Members); err != nil {
+		return nil, err
+	}
+
 	// Validate that there is exactly one owner in members
 	ownerCount := 0
 	for _, member := range app.Members {
@@ -55,7 +93,7 @@ func (s *ApplicationService) RegisterApplication(ownerPublicKey string, app *App
 	}
 
 	// Set timestamps
-	now := time.Now().Unix()
+	now := s.clock.Now().Unix()
 	app.CreatedAt = now
 	app.UpdatedAt = now
 
@@ -101,8 +139,62 @@ func (s *ApplicationService) RegisterApplication(ownerPublicKey string, app *App
 		}
 	}
 
+	// Let the owner's other devices learn about the new application. It is already
+	// registered, so a failure here is logged rather than failing the registration.
+	if s.events != nil {
+		if err := s.events.ProduceApplicationCreated(context.Background(), app.ID, ownerPublicKey, app.Name); err != nil {
+			log.Error().Err(err).Str("applicationId", app.ID).Msg("Failed to produce application_created event")
+		}
+	}
+
 	// Return the complete application
-	return s.appRepo.GetApplicationByID(app.ID)
+	registered, err := s.appRepo.GetApplicationByID(app.ID)
+	if err != nil {
+		return nil, err
+	}
+	registered.PollingOnly = s.config.PollingOnlyApps.Contains(registered.ID)
+	return registered, nil
+}
+
+// assignApplicationID applies the configured ID policy: the server policy replaces the
+// client's ID with a generated UUID, the others validate the client's ID.
+func (s *ApplicationService) assignApplicationID(app *Application) error {
+	switch s.config.AppIDPolicy {
+	case AppIDPolicyServer:
+		app.ID = uuid.NewString()
+	case AppIDPolicyUUID:
+		if len(app.ID) != 36 || uuid.Validate(app.ID) != nil {
+			return fmt.Errorf("%w: %q is not a UUID", ErrInvalidAppID, app.ID)
+		}
+	default:
+		if app.ID == "" {
+			return fmt.Errorf("%w: application ID cannot be empty", ErrInvalidAppID)
+		}
+	}
+	return nil
+}
+
+// validateMemberRoles assigns the default role to members without one, then rejects unknown
+// roles and enforces the configured admin cap.
+func (s *ApplicationService) validateMemberRoles(members []Member) error {
+	adminCount := 0
+	for i := range members {
+		if members[i].Role == "" {
+			members[i].Role = s.config.DefaultMemberRole
+		}
+		if !members[i].Role.IsValid() {
+			return fmt.Errorf("%w: %q for member %s", ErrInvalidMemberRole, members[i].Role, members[i].ID)
+		}
+		if members[i].Role == MemberRoleAdmin {
+			adminCount++
+		}
+	}
+
+	if s.config.MaxAdmins > 0 && adminCount > s.config.MaxAdmins {
+		return fmt.Errorf("%w: %d admins (max: %d)", ErrTooManyAdmins, adminCount, s.config.MaxAdmins)
+	}
+
+	return nil
 }
 
 func (s *ApplicationService) GetApplication(appID string, requestingUser *user.User) (*Application, error) {
@@ -112,40 +204,212 @@ func (s *ApplicationService) GetApplication(appID string, requestingUser *user.U
 	}
 
 	// Verify membership - user must be a member of the application
-	isMember, err := s.appRepo.IsMember(appID, requestingUser.PublicKey)
+	if err := s.requireMember(appID, requestingUser); err != nil {
+		return nil, err
+	}
+
+	if !s.canReadEmails(appID, requestingUser) {
+		redactEmails(app.Members)
+	}
+	app.PollingOnly = s.config.PollingOnlyApps.Contains(app.ID)
+	return app, nil
+}
+
+// GetApplicationWithoutMembers returns the application without hydrating its members,
+// which clients of large applications page through ListMembers instead
+func (s *ApplicationService) GetApplicationWithoutMembers(appID string, requestingUser *user.User) (*Application, error) {
+	app, err := s.appRepo.GetApplicationByIDWithoutMembers(appID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check membership: %w", err)
+		return nil, err
 	}
-	if !isMember {
-		return nil, fmt.Errorf("unauthorized: not a member of this application")
+
+	if err := s.requireMember(appID, requestingUser); err != nil {
+		return nil, err
 	}
 
+	app.PollingOnly = s.config.PollingOnlyApps.Contains(app.ID)
 	return app, nil
 }
 
-func (s *ApplicationService) GetApplicationState(appID string, requestingUser *user.User) (*ApplicationState, error) {
-	state, err := s.appRepo.GetApplicationState(appID)
+// GetApplicationFields returns only the selected parts of the application, loading each
+// with its own query instead of hydrating the whole application
+func (s *ApplicationService) GetApplicationFields(appID string, requestingUser *user.User, fields ApplicationFields) (*Application, error) {
+	app, err := s.appRepo.GetApplicationMetadata(appID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Verify membership - user must be a member of the application
+	if err := s.requireMember(appID, requestingUser); err != nil {
+		return nil, err
+	}
+
+	app.Members = []Member{}
+	if fields.Members {
+		members, err := s.appRepo.GetMembersByApplicationID(appID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get members: %w", err)
+		}
+		app.Members = make([]Member, len(members))
+		for i, member := range members {
+			app.Members[i] = *member
+		}
+		if !s.canReadEmails(appID, requestingUser) {
+			redactEmails(app.Members)
+		}
+	}
+
+	app.ComponentGroups = []ComponentGroup{}
+	if fields.Groups {
+		groups, err := s.selectedComponentGroups(appID, fields.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			components, err := s.appRepo.GetComponentsByGroupID(group.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get components: %w", err)
+			}
+			selected := *group
+			selected.Components = make([]Component, len(components))
+			for i, component := range components {
+				selected.Components[i] = *component
+			}
+			app.ComponentGroups = append(app.ComponentGroups, selected)
+		}
+	}
+
+	app.PollingOnly = s.config.PollingOnlyApps.Contains(app.ID)
+	return app, nil
+}
+
+// selectedComponentGroups returns the application's component groups, or only groupID
+// when set
+func (s *ApplicationService) selectedComponentGroups(appID, groupID string) ([]*ComponentGroup, error) {
+	if groupID == "" {
+		groups, err := s.appRepo.GetComponentGroupsByApplicationID(appID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get component groups: %w", err)
+		}
+		return groups, nil
+	}
+
+	group, err := s.appRepo.GetComponentGroupByID(groupID)
+	if err != nil || group.ApplicationID != appID {
+		return nil, fmt.Errorf("%w: %s", ErrGroupNotFound, groupID)
+	}
+	return []*ComponentGroup{group}, nil
+}
+
+// ListMembers returns one page of the application's members, optionally filtered by role.
+// The limit is clamped to MaxMembersPageSize.
+func (s *ApplicationService) ListMembers(appID string, requestingUser *user.User, limit, offset int, roleFilter MemberRole) (*MembersPage, error) {
+	if roleFilter != "" && !roleFilter.IsValid() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidMemberRole, roleFilter)
+	}
+
+	if err := s.requireMember(appID, requestingUser); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = DefaultMembersPageSize
+	}
+	limit = min(limit, MaxMembersPageSize)
+	offset = max(offset, 0)
+
+	members, total, err := s.appRepo.GetMembersByApplicationIDPaged(appID, limit, offset, roleFilter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+	if !s.canReadEmails(appID, requestingUser) {
+		for i, member := range members {
+			redacted := *member
+			redacted.Email = ""
+			members[i] = &redacted
+		}
+	}
+
+	return &MembersPage{
+		Members: members,
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+	}, nil
+}
+
+// requireMember returns an unauthorized error unless the user is a member of the application
+func (s *ApplicationService) requireMember(appID string, requestingUser *user.User) error {
 	isMember, err := s.appRepo.IsMember(appID, requestingUser.PublicKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check membership: %w", err)
+		return fmt.Errorf("failed to check membership: %w", err)
 	}
 	if !isMember {
-		return nil, fmt.Errorf("unauthorized: not a member of this application")
+		return fmt.Errorf("unauthorized: not a member of this application")
+	}
+	return nil
+}
+
+// canReadEmails reports whether the user may see the members' contact emails, which
+// only owners and admins can
+func (s *ApplicationService) canReadEmails(appID string, requestingUser *user.User) bool {
+	return HasMemberRole(s.appRepo, appID, requestingUser.PublicKey, MemberRoleOwner, MemberRoleAdmin)
+}
+
+// isOwnerOrAdmin reports whether publicKey is an owner or admin among members
+func isOwnerOrAdmin(members []Member, publicKey string) bool {
+	for _, member := range members {
+		if member.PublicKey == publicKey {
+			return member.Role == MemberRoleOwner || member.Role == MemberRoleAdmin
+		}
+	}
+	return false
+}
+
+// redactEmails clears the members' contact emails
+func redactEmails(members []Member) {
+	for i := range members {
+		members[i].Email = ""
+	}
+}
+
+func (s *ApplicationService) GetApplicationState(appID string, requestingUser *user.User) (*ApplicationState, error) {
+	state, err := s.appRepo.GetApplicationState(appID)
+	if err != nil {
+		return nil, err
 	}
 
+	// Verify membership - user must be a member of the application
+	if err := s.requireMember(appID, requestingUser); err != nil {
+		return nil, err
+	}
+
+	state.PollingOnly = s.config.PollingOnlyApps.Contains(state.ID)
 	return state, nil
 }
 
 func (s *ApplicationService) ListApplications(memberPublicKey string) ([]*Application, error) {
-	return s.appRepo.GetApplicationsByMemberPublicKey(memberPublicKey)
+	apps, err := s.appRepo.GetApplicationsByMemberPublicKey(memberPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, app := range apps {
+		app.PollingOnly = s.config.PollingOnlyApps.Contains(app.ID)
+		if !isOwnerOrAdmin(app.Members, memberPublicKey) {
+			redactEmails(app.Members)
+		}
+	}
+	return apps, nil
+}
+
+// CountApplications returns how many applications the member belongs to, for clients that
+// only show a count and do not need ListApplications
+func (s *ApplicationService) CountApplications(memberPublicKey string) (int, error) {
+	return s.appRepo.CountApplicationsByMemberPublicKey(memberPublicKey)
 }
 
-func (s *ApplicationService) DeleteApplication(appID string, requestingUser *user.User) error {
+// DeleteApplication deletes the application for its owner. The deletion goes through the
+// event producer when one is set, which broadcasts it to the application's members.
+func (s *ApplicationService) DeleteApplication(ctx context.Context, appID string, requestingUser *user.User) error {
 	// First verify the application exists and the user owns it
 	app, err := s.appRepo.GetApplicationByID(appID)
 	if err != nil {
@@ -161,8 +425,13 @@ func (s *ApplicationService) DeleteApplication(appID string, requestingUser *use
 		return fmt.Errorf("unauthorized")
 	}
 
-	// Delete the application
-	// Note: Client will submit application_deleted event via POST /events
+	if s.events != nil {
+		if err := s.events.ProduceApplicationDeleted(ctx, appID, ownerPublicKey); err != nil {
+			return fmt.Errorf("failed to delete application: %w", err)
+		}
+		return nil
+	}
+
 	if err := s.appRepo.DeleteApplication(appID); err != nil {
 		// TODO: Consider compensating event if deletion fails
 		return fmt.Errorf("failed to delete application: %w", err)
@@ -171,6 +440,148 @@ func (s *ApplicationService) DeleteApplication(appID string, requestingUser *use
 	return nil
 }
 
+// UpdateApplication changes the application's name and icon for its owner. Omitted fields
+// keep their current value; an empty icon removes it.
+func (s *ApplicationService) UpdateApplication(ctx context.Context, appID string, req UpdateApplicationRequest, requestingUser *user.User) (*Application, error) {
+	app, err := s.appRepo.GetApplicationMetadata(appID)
+	if err != nil {
+		return nil, err
+	}
+
+	requester, err := s.appRepo.GetMemberByPublicKey(appID, requestingUser.PublicKey)
+	if err != nil || requester == nil || requester.Role != MemberRoleOwner {
+		return nil, ErrNotOwner
+	}
+
+	name := app.Name
+	if req.Name != nil {
+		name = strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, fmt.Errorf("%w: name cannot be empty", ErrInvalidUpdate)
+		}
+	}
+	icon := app.Icon
+	if req.Icon != nil {
+		icon = req.Icon
+		if *req.Icon == "" {
+			icon = nil
+		}
+	}
+
+	if s.events != nil {
+		err = s.events.ProduceApplicationDataChanged(ctx, appID, requestingUser.PublicKey, name, icon)
+	} else {
+		err = s.appRepo.UpdateApplicationMetadata(appID, name, icon)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update application: %w", err)
+	}
+
+	return s.appRepo.GetApplicationByID(appID)
+}
+
+// ChangeMemberRole changes a member's role for the application owner. The change goes
+// through the event producer when one is set, so it is sequenced and broadcast like a
+// client-submitted member_role_changed event, and validated like one: an API token
+// cannot be made an owner (ErrTokenOwner) and the last owner cannot be demoted
+// (ErrLastOwner).
+func (s *ApplicationService) ChangeMemberRole(ctx context.Context, appID, memberID string, role MemberRole, requestingUser *user.User) (*Member, error) {
+	requester, err := s.appRepo.GetMemberByPublicKey(appID, requestingUser.PublicKey)
+	if err != nil || requester == nil || requester.Role != MemberRoleOwner {
+		return nil, ErrNotOwner
+	}
+
+	member, err := s.appRepo.GetMemberByID(memberID)
+	if err != nil || member == nil || member.ApplicationID != appID {
+		return nil, ErrMemberNotFound
+	}
+
+	if !role.IsValid() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidMemberRole, role)
+	}
+	if role == member.Role {
+		return member, nil
+	}
+
+	if role == MemberRoleAdmin && s.config.MaxAdmins > 0 {
+		_, admins, err := s.appRepo.GetMembersByApplicationIDPaged(appID, 1, 0, MemberRoleAdmin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count admins: %w", err)
+		}
+		if admins >= s.config.MaxAdmins {
+			return nil, fmt.Errorf("%w: max %d admins", ErrTooManyAdmins, s.config.MaxAdmins)
+		}
+	}
+
+	if s.events != nil {
+		err = s.events.ProduceMemberRoleChanged(ctx, appID, requestingUser.PublicKey, member.PublicKey, member.Role, role)
+	} else {
+		updated := *member
+		updated.Role = role
+		err = s.appRepo.UpdateMember(&updated)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to change member role: %w", err)
+	}
+
+	return s.appRepo.GetMemberByID(memberID)
+}
+
+// TransferOwnership hands the application over to another member, who becomes owner while
+// the current owner becomes an admin. With an event producer both role changes are
+// committed together, and an API token cannot become the owner (ErrTokenOwner); without
+// one the new owner is promoted before the old one is demoted, so the application is never
+// left without an owner.
+func (s *ApplicationService) TransferOwnership(ctx context.Context, appID string, req TransferOwnershipRequest, requestingUser *user.User) (*Application, error) {
+	requester, err := s.appRepo.GetMemberByPublicKey(appID, requestingUser.PublicKey)
+	if err != nil || requester == nil || requester.Role != MemberRoleOwner {
+		return nil, ErrNotOwner
+	}
+
+	if req.NewOwnerPublicKey == "" || req.NewOwnerPublicKey == requestingUser.PublicKey {
+		return nil, fmt.Errorf("%w: new owner must be another member", ErrInvalidTransfer)
+	}
+	newOwner, err := s.appRepo.GetMemberByPublicKey(appID, req.NewOwnerPublicKey)
+	if err != nil || newOwner == nil {
+		return nil, ErrMemberNotFound
+	}
+
+	if s.config.MaxAdmins > 0 {
+		_, admins, err := s.appRepo.GetMembersByApplicationIDPaged(appID, 1, 0, MemberRoleAdmin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count admins: %w", err)
+		}
+		if newOwner.Role == MemberRoleAdmin {
+			admins--
+		}
+		if admins >= s.config.MaxAdmins {
+			return nil, fmt.Errorf("%w: max %d admins", ErrTooManyAdmins, s.config.MaxAdmins)
+		}
+	}
+
+	if s.events != nil {
+		err = s.events.ProduceOwnershipTransfer(ctx, appID, requestingUser.PublicKey, newOwner.PublicKey, newOwner.Role)
+	} else {
+		err = s.swapOwner(newOwner, requester)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to transfer ownership: %w", err)
+	}
+
+	return s.appRepo.GetApplicationByID(appID)
+}
+
+func (s *ApplicationService) swapOwner(newOwner, oldOwner *Member) error {
+	promoted := *newOwner
+	promoted.Role = MemberRoleOwner
+	if err := s.appRepo.UpdateMember(&promoted); err != nil {
+		return err
+	}
+	demoted := *oldOwner
+	demoted.Role = MemberRoleAdmin
+	return s.appRepo.UpdateMember(&demoted)
+}
+
 // LeaveApplication validates that the user is a member of the application.
 // In the client-produced events architecture, this endpoint is deprecated.
 // Clients should submit member_removed or application_deleted events via POST /events.
@@ -190,3 +601,81 @@ func (s *ApplicationService) LeaveApplication(appID string, requestingUser *user
 	return nil
 }
 
+// RekeyMember moves a member to a new public key after the member's key changed.
+// Only the application owner may rekey, and the request must carry a signature by the
+// new key over RekeyProofMessage. The member keeps its ID, name and role, so events and
+// storage that reference the member stay attached to it. The change goes through the event
+// producer when one is set, which broadcasts it to the application's members.
+func (s *ApplicationService) RekeyMember(ctx context.Context, appID, oldPublicKey string, req RekeyMemberRequest, requestingUser *user.User) (*Member, error) {
+	requester, err := s.appRepo.GetMemberByPublicKey(appID, requestingUser.PublicKey)
+	if err != nil || requester == nil || requester.Role != MemberRoleOwner {
+		return nil, ErrNotOwner
+	}
+
+	member, err := s.appRepo.GetMemberByPublicKey(appID, oldPublicKey)
+	if err != nil || member == nil {
+		return nil, ErrMemberNotFound
+	}
+
+	if req.NewPublicKey == "" || req.NewPublicKey == oldPublicKey {
+		return nil, fmt.Errorf("%w: new public key must differ from the current one", ErrInvalidRekeyProof)
+	}
+	if err := verifyRekeyProof(appID, oldPublicKey, req); err != nil {
+		return nil, err
+	}
+
+	isMember, err := s.appRepo.IsMember(appID, req.NewPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check membership: %w", err)
+	}
+	if isMember {
+		return nil, ErrMemberExists
+	}
+
+	// The new key needs a user record to authenticate
+	if s.userRepo != nil {
+		if existing, err := s.userRepo.GetUserByPublicKey(req.NewPublicKey); err != nil || existing == nil {
+			newUser := &user.User{
+				PublicKey: req.NewPublicKey,
+				Username:  member.Name,
+				Role:      "member",
+				CreatedAt: s.clock.Now().Unix(),
+			}
+			if err := user.CreateMemberUser(s.userRepo, newUser); err != nil {
+				return nil, fmt.Errorf("failed to create user: %w", err)
+			}
+		}
+	}
+
+	if s.events != nil {
+		if err := s.events.ProduceMemberRekeyed(ctx, appID, requestingUser.PublicKey, oldPublicKey, req.NewPublicKey); err != nil {
+			return nil, fmt.Errorf("failed to update member: %w", err)
+		}
+		return s.appRepo.GetMemberByPublicKey(appID, req.NewPublicKey)
+	}
+
+	member.PublicKey = req.NewPublicKey
+	if err := s.appRepo.UpdateMember(member); err != nil {
+		return nil, fmt.Errorf("failed to update member: %w", err)
+	}
+
+	return member, nil
+}
+
+// verifyRekeyProof checks that the request is signed by the private key of the new public key
+func verifyRekeyProof(appID, oldPublicKey string, req RekeyMemberRequest) error {
+	publicKeyBytes, err := base64.StdEncoding.DecodeString(req.NewPublicKey)
+	if err != nil || len(publicKeyBytes) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: new public key must be a base64 Ed25519 key", ErrInvalidRekeyProof)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(req.Signature)
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: signature must be a base64 Ed25519 signature", ErrInvalidRekeyProof)
+	}
+
+	if !ed25519.Verify(publicKeyBytes, RekeyProofMessage(appID, oldPublicKey, req.NewPublicKey), signature) {
+		return fmt.Errorf("%w: signature does not match the new public key", ErrInvalidRekeyProof)
+	}
+	return nil
+}
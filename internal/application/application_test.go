@@ -1,10 +1,18 @@
 package application
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/goccy/go-json"
 	"github.com/prappser/prappser_server/internal/user"
+	"github.com/valyala/fasthttp"
 )
 
 func strPtr(s string) *string { return &s }
@@ -46,7 +54,7 @@ func TestApplicationService_RegisterApplication_ShouldCreateApplicationWithCompo
 	// given
 	testUser := createTestUser()
 	appRepo := NewMemoryRepository()
-	appService := NewApplicationService(appRepo)
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
 
 	app := &Application{
 		ID:   "test-app-complex-id",
@@ -122,7 +130,7 @@ func TestApplicationService_GetApplication_ShouldReturnCompleteApplicationData(t
 	// given
 	testUser := createTestUser()
 	appRepo := NewMemoryRepository()
-	appService := NewApplicationService(appRepo)
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
 
 	app := createBasicApplication(testUser, "Test App", "test-app-get-id")
 	app.ComponentGroups[0].Name = "Data Components"
@@ -172,7 +180,7 @@ func TestApplicationService_GetApplication_ShouldReturnErrorForUnauthorizedUser(
 	}
 
 	appRepo := NewMemoryRepository()
-	appService := NewApplicationService(appRepo)
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
 
 	app := createBasicApplication(owner, "Owner App", "owner-app-id")
 
@@ -198,7 +206,7 @@ func TestApplicationService_ListApplications_ShouldReturnUserApplicationsOnly(t
 	// given
 	testUser := createTestUser()
 	appRepo := NewMemoryRepository()
-	appService := NewApplicationService(appRepo)
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
 
 	app1 := createBasicApplication(testUser, "App 1", "test-app-id-1")
 
@@ -231,7 +239,7 @@ func TestApplicationService_GetApplicationState_ShouldReturnStateWithCorrectTime
 	// given
 	testUser := createTestUser()
 	appRepo := NewMemoryRepository()
-	appService := NewApplicationService(appRepo)
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
 
 	app := createBasicApplication(testUser, "State Test App", "state-test-app-id")
 
@@ -265,7 +273,7 @@ func TestApplicationService_RegisterApplication_ShouldReturnErrorForEmptyName(t
 	// given
 	testUser := createTestUser()
 	appRepo := NewMemoryRepository()
-	appService := NewApplicationService(appRepo)
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
 
 	app := createBasicApplication(testUser, "", "empty-name-test-id")
 	app.Name = "" // Explicitly set empty name to test validation
@@ -283,7 +291,7 @@ func TestApplicationService_DeleteApplication_ShouldDeleteApplicationSuccessfull
 	// given
 	testUser := createTestUser()
 	appRepo := NewMemoryRepository()
-	appService := NewApplicationService(appRepo)
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
 
 	app := createBasicApplication(testUser, "App to Delete", "delete-test-app-id")
 	app.ComponentGroups[0].Components = []Component{
@@ -303,7 +311,7 @@ func TestApplicationService_DeleteApplication_ShouldDeleteApplicationSuccessfull
 	}
 
 	// when
-	err = appService.DeleteApplication(registeredApp.ID, testUser)
+	err = appService.DeleteApplication(context.Background(), registeredApp.ID, testUser)
 
 	// then
 	if err != nil {
@@ -317,6 +325,344 @@ func TestApplicationService_DeleteApplication_ShouldDeleteApplicationSuccessfull
 	}
 }
 
+// recordingEventProducer records the changes it is asked to produce events for. With an
+// application repository it also moves a rekeyed member, as executing the event would.
+type recordingEventProducer struct {
+	appRepo        ApplicationRepository
+	createdAppID   string
+	deletedAppID   string
+	ownerPublicKey string
+	name           string
+	icon           *string
+	memberKey      string
+	newRole        MemberRole
+	newOwnerKey    string
+	rekeyedFrom    string
+	roleChangeErr  error
+}
+
+func (p *recordingEventProducer) ProduceApplicationCreated(ctx context.Context, appID, ownerPublicKey, name string) error {
+	p.createdAppID = appID
+	p.ownerPublicKey = ownerPublicKey
+	p.name = name
+	return nil
+}
+
+func (p *recordingEventProducer) ProduceApplicationDeleted(ctx context.Context, appID, ownerPublicKey string) error {
+	p.deletedAppID = appID
+	p.ownerPublicKey = ownerPublicKey
+	return nil
+}
+
+func (p *recordingEventProducer) ProduceApplicationDataChanged(ctx context.Context, appID, ownerPublicKey, name string, icon *string) error {
+	p.ownerPublicKey = ownerPublicKey
+	p.name = name
+	p.icon = icon
+	return nil
+}
+
+func (p *recordingEventProducer) ProduceMemberRoleChanged(ctx context.Context, appID, ownerPublicKey, memberPublicKey string, oldRole, newRole MemberRole) error {
+	p.ownerPublicKey = ownerPublicKey
+	p.memberKey = memberPublicKey
+	p.newRole = newRole
+	return p.roleChangeErr
+}
+
+func (p *recordingEventProducer) ProduceOwnershipTransfer(ctx context.Context, appID, ownerPublicKey, newOwnerPublicKey string, newOwnerRole MemberRole) error {
+	p.ownerPublicKey = ownerPublicKey
+	p.newOwnerKey = newOwnerPublicKey
+	return nil
+}
+
+func TestApplicationService_RegisterApplication_ShouldProduceApplicationCreated(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	events := &recordingEventProducer{}
+	appService := NewApplicationService(NewMemoryRepository(), nil, events, Config{})
+
+	// when
+	registeredApp, err := appService.RegisterApplication(testUser.PublicKey, createBasicApplication(testUser, "New App", "produced-create-app-id"))
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+	if events.createdAppID != registeredApp.ID || events.ownerPublicKey != testUser.PublicKey || events.name != "New App" {
+		t.Errorf("Expected application_created for %s by the owner, got %s by %s named %q", registeredApp.ID, events.createdAppID, events.ownerPublicKey, events.name)
+	}
+}
+
+func (p *recordingEventProducer) ProduceMemberRekeyed(ctx context.Context, appID, ownerPublicKey, oldPublicKey, newPublicKey string) error {
+	p.ownerPublicKey = ownerPublicKey
+	p.rekeyedFrom = oldPublicKey
+	p.memberKey = newPublicKey
+	if p.appRepo != nil {
+		member, err := p.appRepo.GetMemberByPublicKey(appID, oldPublicKey)
+		if err != nil {
+			return err
+		}
+		member.PublicKey = newPublicKey
+		return p.appRepo.UpdateMember(member)
+	}
+	return nil
+}
+
+func TestApplicationService_DeleteApplication_ShouldDeleteThroughEventProducer(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appRepo := NewMemoryRepository()
+	deletions := &recordingEventProducer{}
+	appService := NewApplicationService(appRepo, nil, deletions, Config{})
+	registeredApp, err := appService.RegisterApplication(testUser.PublicKey, createBasicApplication(testUser, "App to Delete", "produced-delete-app-id"))
+	if err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+
+	// when
+	err = appService.DeleteApplication(context.Background(), registeredApp.ID, testUser)
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if deletions.deletedAppID != registeredApp.ID || deletions.ownerPublicKey != testUser.PublicKey {
+		t.Errorf("Expected deletion of %s by the owner to be produced, got %+v", registeredApp.ID, deletions)
+	}
+	if _, err := appRepo.GetApplicationByID(registeredApp.ID); err != nil {
+		t.Errorf("Expected the repository deletion to be left to the application_deleted event, got: %v", err)
+	}
+}
+
+func TestApplicationService_UpdateApplication_ShouldRenameAndKeepIcon(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appRepo := NewMemoryRepository()
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
+	app := createBasicApplication(testUser, "Old Name", "update-app-id")
+	app.Icon = strPtr("star")
+	if _, err := appService.RegisterApplication(testUser.PublicKey, app); err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+
+	// when
+	updated, err := appService.UpdateApplication(context.Background(), "update-app-id", UpdateApplicationRequest{Name: strPtr("New Name")}, testUser)
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if updated.Name != "New Name" {
+		t.Errorf("Expected name 'New Name', got '%s'", updated.Name)
+	}
+	if updated.Icon == nil || *updated.Icon != "star" {
+		t.Errorf("Expected icon to be kept, got %v", updated.Icon)
+	}
+}
+
+func TestApplicationService_UpdateApplication_ShouldProduceDataChangedEvent(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	events := &recordingEventProducer{}
+	appService := NewApplicationService(NewMemoryRepository(), nil, events, Config{})
+	if _, err := appService.RegisterApplication(testUser.PublicKey, createBasicApplication(testUser, "Old Name", "update-app-id")); err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+
+	// when
+	_, err := appService.UpdateApplication(context.Background(), "update-app-id", UpdateApplicationRequest{Icon: strPtr("rocket")}, testUser)
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if events.name != "Old Name" || events.icon == nil || *events.icon != "rocket" || events.ownerPublicKey != testUser.PublicKey {
+		t.Errorf("Expected an application_data_changed event keeping the name, got %+v", events)
+	}
+}
+
+func TestApplicationService_UpdateApplication_ShouldRejectEmptyName(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appService := NewApplicationService(NewMemoryRepository(), nil, nil, Config{})
+	if _, err := appService.RegisterApplication(testUser.PublicKey, createBasicApplication(testUser, "Old Name", "update-app-id")); err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+
+	// when
+	_, err := appService.UpdateApplication(context.Background(), "update-app-id", UpdateApplicationRequest{Name: strPtr("  ")}, testUser)
+
+	// then
+	if !errors.Is(err, ErrInvalidUpdate) {
+		t.Errorf("Expected ErrInvalidUpdate, got: %v", err)
+	}
+}
+
+func TestApplicationService_UpdateApplication_ShouldRejectNonOwner(t *testing.T) {
+	// given
+	owner := createTestUser()
+	appService := NewApplicationService(NewMemoryRepository(), nil, nil, Config{})
+	app := createBasicApplication(owner, "Old Name", "update-app-id")
+	app.Members = append(app.Members, Member{ID: "update-app-id-member-2", Name: "member", Role: MemberRoleMember, PublicKey: "member-public-key"})
+	if _, err := appService.RegisterApplication(owner.PublicKey, app); err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+
+	// when
+	_, err := appService.UpdateApplication(context.Background(), "update-app-id", UpdateApplicationRequest{Name: strPtr("Taken Over")}, &user.User{PublicKey: "member-public-key"})
+
+	// then
+	if !errors.Is(err, ErrNotOwner) {
+		t.Errorf("Expected ErrNotOwner, got: %v", err)
+	}
+}
+
+// registerAppWithMember registers an application owned by owner with one extra member
+func registerAppWithMember(t *testing.T, appService *ApplicationService, owner *user.User, appID string) {
+	app := createBasicApplication(owner, "App", appID)
+	app.Members = append(app.Members, Member{ID: appID + "-member-2", Name: "member", Role: MemberRoleMember, PublicKey: "member-public-key"})
+	if _, err := appService.RegisterApplication(owner.PublicKey, app); err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+}
+
+func TestApplicationService_ChangeMemberRole_ShouldUpdateRole(t *testing.T) {
+	// given
+	owner := createTestUser()
+	appService := NewApplicationService(NewMemoryRepository(), nil, nil, Config{})
+	registerAppWithMember(t, appService, owner, "role-app-id")
+
+	// when
+	member, err := appService.ChangeMemberRole(context.Background(), "role-app-id", "role-app-id-member-2", MemberRoleAdmin, owner)
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if member.Role != MemberRoleAdmin {
+		t.Errorf("Expected role admin, got %s", member.Role)
+	}
+}
+
+func TestApplicationService_ChangeMemberRole_ShouldProduceRoleChangedEvent(t *testing.T) {
+	// given
+	owner := createTestUser()
+	events := &recordingEventProducer{}
+	appService := NewApplicationService(NewMemoryRepository(), nil, events, Config{})
+	registerAppWithMember(t, appService, owner, "role-app-id")
+
+	// when
+	_, err := appService.ChangeMemberRole(context.Background(), "role-app-id", "role-app-id-member-2", MemberRoleViewer, owner)
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if events.memberKey != "member-public-key" || events.newRole != MemberRoleViewer || events.ownerPublicKey != owner.PublicKey {
+		t.Errorf("Expected a member_role_changed event for the member, got %+v", events)
+	}
+}
+
+func TestApplicationService_ChangeMemberRole_ShouldReturnLastOwnerErrorOfEvent(t *testing.T) {
+	// given
+	owner := createTestUser()
+	events := &recordingEventProducer{roleChangeErr: fmt.Errorf("validation error: %w", ErrLastOwner)}
+	appService := NewApplicationService(NewMemoryRepository(), nil, events, Config{})
+	registerAppWithMember(t, appService, owner, "role-app-id")
+
+	// when
+	_, err := appService.ChangeMemberRole(context.Background(), "role-app-id", "role-app-id-member-1", MemberRoleMember, owner)
+
+	// then
+	if !errors.Is(err, ErrLastOwner) {
+		t.Errorf("Expected ErrLastOwner, got: %v", err)
+	}
+}
+
+func TestApplicationService_ChangeMemberRole_ShouldRejectUnknownRoleAndNonOwner(t *testing.T) {
+	// given
+	owner := createTestUser()
+	appService := NewApplicationService(NewMemoryRepository(), nil, nil, Config{})
+	registerAppWithMember(t, appService, owner, "role-app-id")
+
+	// when
+	_, unknownRoleErr := appService.ChangeMemberRole(context.Background(), "role-app-id", "role-app-id-member-2", MemberRole("superuser"), owner)
+	_, nonOwnerErr := appService.ChangeMemberRole(context.Background(), "role-app-id", "role-app-id-member-2", MemberRoleAdmin, &user.User{PublicKey: "member-public-key"})
+
+	// then
+	if !errors.Is(unknownRoleErr, ErrInvalidMemberRole) {
+		t.Errorf("Expected ErrInvalidMemberRole, got: %v", unknownRoleErr)
+	}
+	if !errors.Is(nonOwnerErr, ErrNotOwner) {
+		t.Errorf("Expected ErrNotOwner, got: %v", nonOwnerErr)
+	}
+}
+
+func TestApplicationService_TransferOwnership_ShouldSwapOwnerAndAdmin(t *testing.T) {
+	// given
+	owner := createTestUser()
+	appRepo := NewMemoryRepository()
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
+	registerAppWithMember(t, appService, owner, "transfer-app-id")
+
+	// when
+	app, err := appService.TransferOwnership(context.Background(), "transfer-app-id", TransferOwnershipRequest{NewOwnerPublicKey: "member-public-key"}, owner)
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	ownerPublicKey, _ := app.GetOwnerPublicKey()
+	if ownerPublicKey != "member-public-key" {
+		t.Errorf("Expected member-public-key to own the application, got %s", ownerPublicKey)
+	}
+	formerOwner, _ := appRepo.GetMemberByPublicKey("transfer-app-id", owner.PublicKey)
+	if formerOwner.Role != MemberRoleAdmin {
+		t.Errorf("Expected the former owner to be admin, got %s", formerOwner.Role)
+	}
+	_, owners, _ := appRepo.GetMembersByApplicationIDPaged("transfer-app-id", 10, 0, MemberRoleOwner)
+	if owners != 1 {
+		t.Errorf("Expected exactly one owner, got %d", owners)
+	}
+}
+
+func TestApplicationService_TransferOwnership_ShouldProduceEvents(t *testing.T) {
+	// given
+	owner := createTestUser()
+	events := &recordingEventProducer{}
+	appService := NewApplicationService(NewMemoryRepository(), nil, events, Config{})
+	registerAppWithMember(t, appService, owner, "transfer-app-id")
+
+	// when
+	_, err := appService.TransferOwnership(context.Background(), "transfer-app-id", TransferOwnershipRequest{NewOwnerPublicKey: "member-public-key"}, owner)
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if events.newOwnerKey != "member-public-key" || events.ownerPublicKey != owner.PublicKey {
+		t.Errorf("Expected an ownership transfer to member-public-key, got %+v", events)
+	}
+}
+
+func TestApplicationService_TransferOwnership_ShouldRejectNonMemberAndSelf(t *testing.T) {
+	// given
+	owner := createTestUser()
+	appService := NewApplicationService(NewMemoryRepository(), nil, nil, Config{})
+	registerAppWithMember(t, appService, owner, "transfer-app-id")
+
+	// when
+	_, nonMemberErr := appService.TransferOwnership(context.Background(), "transfer-app-id", TransferOwnershipRequest{NewOwnerPublicKey: "stranger-public-key"}, owner)
+	_, selfErr := appService.TransferOwnership(context.Background(), "transfer-app-id", TransferOwnershipRequest{NewOwnerPublicKey: owner.PublicKey}, owner)
+
+	// then
+	if !errors.Is(nonMemberErr, ErrMemberNotFound) {
+		t.Errorf("Expected ErrMemberNotFound, got: %v", nonMemberErr)
+	}
+	if !errors.Is(selfErr, ErrInvalidTransfer) {
+		t.Errorf("Expected ErrInvalidTransfer, got: %v", selfErr)
+	}
+}
+
 func TestApplicationService_DeleteApplication_ShouldReturnErrorForUnauthorizedUser(t *testing.T) {
 	// given
 	owner := createTestUser()
@@ -328,7 +674,7 @@ func TestApplicationService_DeleteApplication_ShouldReturnErrorForUnauthorizedUs
 	}
 
 	appRepo := NewMemoryRepository()
-	appService := NewApplicationService(appRepo)
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
 
 	app := createBasicApplication(owner, "Owner's App", "owner-delete-app-id")
 
@@ -338,7 +684,7 @@ func TestApplicationService_DeleteApplication_ShouldReturnErrorForUnauthorizedUs
 	}
 
 	// when
-	err = appService.DeleteApplication(registeredApp.ID, otherUser)
+	err = appService.DeleteApplication(context.Background(), registeredApp.ID, otherUser)
 
 	// then
 	if err == nil {
@@ -354,10 +700,10 @@ func TestApplicationService_DeleteApplication_ShouldReturnErrorForNonExistentApp
 	// given
 	testUser := createTestUser()
 	appRepo := NewMemoryRepository()
-	appService := NewApplicationService(appRepo)
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
 
 	// when
-	err := appService.DeleteApplication("non-existent-id", testUser)
+	err := appService.DeleteApplication(context.Background(), "non-existent-id", testUser)
 
 	// then
 	if err == nil {
@@ -373,7 +719,7 @@ func TestApplicationService_RegisterApplication_ShouldRoundTripMemberWithNilAvat
 	// given
 	testUser := createTestUser()
 	appRepo := NewMemoryRepository()
-	appService := NewApplicationService(appRepo)
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
 
 	app := &Application{
 		ID:   "nil-avatar-test-id",
@@ -418,3 +764,853 @@ func TestApplicationService_RegisterApplication_ShouldRoundTripMemberWithNilAvat
 		t.Errorf("Expected AvatarStorageID to be nil, got %v", retrievedApp.Members[0].AvatarStorageID)
 	}
 }
+
+func createStateRequestCtx(testUser *user.User, appID string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodGet)
+	ctx.SetUserValue("user", testUser)
+	ctx.SetUserValue("appID", appID)
+	return ctx
+}
+
+func TestApplicationEndpoints_GetApplicationState_ShouldReturnNotModifiedWhenUnchanged(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appRepo := NewMemoryRepository()
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
+	endpoints := NewApplicationEndpoints(appService, "server-public-key")
+
+	registeredApp, err := appService.RegisterApplication(testUser.PublicKey, createBasicApplication(testUser, "Poll App", "poll-app-id"))
+	if err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+
+	first := createStateRequestCtx(testUser, registeredApp.ID)
+	endpoints.GetApplicationState(first)
+	etag := string(first.Response.Header.Peek("ETag"))
+	lastModified := string(first.Response.Header.Peek("Last-Modified"))
+
+	// when
+	byETag := createStateRequestCtx(testUser, registeredApp.ID)
+	byETag.Request.Header.Set("If-None-Match", etag)
+	endpoints.GetApplicationState(byETag)
+
+	byDate := createStateRequestCtx(testUser, registeredApp.ID)
+	byDate.Request.Header.Set("If-Modified-Since", lastModified)
+	endpoints.GetApplicationState(byDate)
+
+	// then
+	if first.Response.StatusCode() != fasthttp.StatusOK || etag == "" || lastModified == "" {
+		t.Fatalf("Expected 200 with validators, got %d (etag=%q, last-modified=%q)", first.Response.StatusCode(), etag, lastModified)
+	}
+
+	if byETag.Response.StatusCode() != fasthttp.StatusNotModified {
+		t.Errorf("Expected 304 for matching If-None-Match, got %d", byETag.Response.StatusCode())
+	}
+
+	if len(byETag.Response.Body()) != 0 {
+		t.Errorf("Expected empty body for 304, got %q", byETag.Response.Body())
+	}
+
+	if byDate.Response.StatusCode() != fasthttp.StatusNotModified {
+		t.Errorf("Expected 304 for If-Modified-Since, got %d", byDate.Response.StatusCode())
+	}
+}
+
+func TestApplicationEndpoints_GetApplicationState_ShouldReturnOKWhenChanged(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appRepo := NewMemoryRepository()
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
+	endpoints := NewApplicationEndpoints(appService, "server-public-key")
+
+	registeredApp, err := appService.RegisterApplication(testUser.PublicKey, createBasicApplication(testUser, "Poll App", "poll-app-id"))
+	if err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+
+	first := createStateRequestCtx(testUser, registeredApp.ID)
+	endpoints.GetApplicationState(first)
+	etag := string(first.Response.Header.Peek("ETag"))
+	lastModified := string(first.Response.Header.Peek("Last-Modified"))
+
+	// an applied event advances the sequence; updated_at has second resolution, so move
+	// it forward explicitly
+	appRepo.UpdateLastSequence(registeredApp.ID, 1)
+	appRepo.applications[registeredApp.ID].UpdatedAt += 10
+
+	// when
+	byETag := createStateRequestCtx(testUser, registeredApp.ID)
+	byETag.Request.Header.Set("If-None-Match", etag)
+	endpoints.GetApplicationState(byETag)
+
+	byDate := createStateRequestCtx(testUser, registeredApp.ID)
+	byDate.Request.Header.Set("If-Modified-Since", lastModified)
+	endpoints.GetApplicationState(byDate)
+
+	// then
+	if byETag.Response.StatusCode() != fasthttp.StatusOK {
+		t.Errorf("Expected 200 for stale If-None-Match, got %d", byETag.Response.StatusCode())
+	}
+
+	if len(byETag.Response.Body()) == 0 {
+		t.Error("Expected state body for changed application")
+	}
+
+	if byDate.Response.StatusCode() != fasthttp.StatusOK {
+		t.Errorf("Expected 200 for stale If-Modified-Since, got %d", byDate.Response.StatusCode())
+	}
+}
+
+
+func TestApplicationEndpoints_GetApplicationState_ShouldChangeETagForEventInSameSecond(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appRepo := NewMemoryRepository()
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
+	endpoints := NewApplicationEndpoints(appService, "server-public-key")
+
+	registeredApp, err := appService.RegisterApplication(testUser.PublicKey, createBasicApplication(testUser, "Poll App", "poll-app-id"))
+	if err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+
+	first := createStateRequestCtx(testUser, registeredApp.ID)
+	endpoints.GetApplicationState(first)
+	etag := string(first.Response.Header.Peek("ETag"))
+	updatedAt := appRepo.applications[registeredApp.ID].UpdatedAt
+
+	// when - an event is applied within the same second
+	appRepo.UpdateLastSequence(registeredApp.ID, 1)
+	appRepo.applications[registeredApp.ID].UpdatedAt = updatedAt
+
+	byETag := createStateRequestCtx(testUser, registeredApp.ID)
+	byETag.Request.Header.Set("If-None-Match", etag)
+	endpoints.GetApplicationState(byETag)
+
+	// then
+	if byETag.Response.StatusCode() != fasthttp.StatusOK {
+		t.Errorf("Expected 200 for If-None-Match from before the event, got %d", byETag.Response.StatusCode())
+	}
+}
+
+func TestApplicationService_RegisterApplication_ShouldRejectInvalidMemberRole(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appService := NewApplicationService(NewMemoryRepository(), nil, nil, Config{})
+
+	app := createBasicApplication(testUser, "Role App", "role-app-id")
+	app.Members = append(app.Members, Member{ID: "role-app-id-member-2", Name: "other", Role: "superuser", PublicKey: "other-public-key"})
+
+	// when
+	_, err := appService.RegisterApplication(testUser.PublicKey, app)
+
+	// then
+	if !errors.Is(err, ErrInvalidMemberRole) {
+		t.Errorf("Expected ErrInvalidMemberRole, got: %v", err)
+	}
+}
+
+func TestApplicationService_RegisterApplication_ShouldRejectNonUUIDIDUnderUUIDPolicy(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appService := NewApplicationService(NewMemoryRepository(), nil, nil, Config{AppIDPolicy: AppIDPolicyUUID})
+
+	for _, appID := range []string{"", "my-app", "{9b2f1c1e-3d4a-4f5b-8c6d-7e8f9a0b1c2d}", "9b2f1c1e3d4a4f5b8c6d7e8f9a0b1c2d"} {
+		// when
+		_, err := appService.RegisterApplication(testUser.PublicKey, createBasicApplication(testUser, "UUID App", appID))
+
+		// then
+		if !errors.Is(err, ErrInvalidAppID) {
+			t.Errorf("Expected ErrInvalidAppID for %q, got: %v", appID, err)
+		}
+	}
+}
+
+func TestApplicationService_RegisterApplication_ShouldAcceptUUIDUnderUUIDPolicy(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appService := NewApplicationService(NewMemoryRepository(), nil, nil, Config{AppIDPolicy: AppIDPolicyUUID})
+	appID := "9b2f1c1e-3d4a-4f5b-8c6d-7e8f9a0b1c2d"
+
+	// when
+	registered, err := appService.RegisterApplication(testUser.PublicKey, createBasicApplication(testUser, "UUID App", appID))
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected UUID to be accepted, got: %v", err)
+	}
+	if registered.ID != appID {
+		t.Errorf("Expected ID %s, got %s", appID, registered.ID)
+	}
+}
+
+func TestApplicationService_RegisterApplication_ShouldGenerateIDUnderServerPolicy(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appRepo := NewMemoryRepository()
+	appService := NewApplicationService(appRepo, nil, nil, Config{AppIDPolicy: AppIDPolicyServer})
+	app := createBasicApplication(testUser, "Server App", "client-chosen-id")
+
+	// when
+	registered, err := appService.RegisterApplication(testUser.PublicKey, app)
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+	if registered.ID == "client-chosen-id" || len(registered.ID) != 36 {
+		t.Errorf("Expected a server-generated UUID, got %q", registered.ID)
+	}
+	if _, err := appRepo.GetApplicationByID("client-chosen-id"); err == nil {
+		t.Error("Expected no application under the client-chosen ID")
+	}
+	isMember, err := appRepo.IsMember(registered.ID, testUser.PublicKey)
+	if err != nil || !isMember {
+		t.Errorf("Expected owner to be a member of the generated ID, got %v, %v", isMember, err)
+	}
+}
+
+func TestApplicationEndpoints_RegisterApplication_ShouldReturnRegisteredID(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appService := NewApplicationService(NewMemoryRepository(), nil, nil, Config{AppIDPolicy: AppIDPolicyServer})
+	endpoints := NewApplicationEndpoints(appService, "server-public-key")
+	body, _ := json.Marshal(createBasicApplication(testUser, "Server App", ""))
+	ctx := &fasthttp.RequestCtx{}
+	ctx.SetUserValue("user", testUser)
+	ctx.Request.SetBody(body)
+
+	// when
+	endpoints.RegisterApplication(ctx)
+
+	// then
+	if ctx.Response.StatusCode() != fasthttp.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	var response RegisterApplicationResponse
+	if err := json.Unmarshal(ctx.Response.Body(), &response); err != nil || len(response.ID) != 36 {
+		t.Errorf("Expected generated ID in response, got %s", ctx.Response.Body())
+	}
+}
+
+func TestApplicationService_RegisterApplication_ShouldAcceptMultipleRoles(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appService := NewApplicationService(NewMemoryRepository(), nil, nil, Config{MaxAdmins: 1})
+
+	app := createBasicApplication(testUser, "Role App", "role-app-id")
+	app.Members = append(app.Members,
+		Member{ID: "role-app-id-admin", Name: "admin", Role: MemberRoleAdmin, PublicKey: "admin-public-key"},
+		Member{ID: "role-app-id-viewer", Name: "viewer", Role: MemberRoleViewer, PublicKey: "viewer-public-key"},
+		Member{ID: "role-app-id-default", Name: "default", PublicKey: "default-public-key"},
+	)
+
+	// when
+	registeredApp, err := appService.RegisterApplication(testUser.PublicKey, app)
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	roles := make(map[string]MemberRole)
+	for _, member := range registeredApp.Members {
+		roles[member.PublicKey] = member.Role
+	}
+
+	if roles["admin-public-key"] != MemberRoleAdmin || roles["viewer-public-key"] != MemberRoleViewer {
+		t.Errorf("Expected admin and viewer roles to be kept, got %v", roles)
+	}
+
+	if roles["default-public-key"] != MemberRoleMember {
+		t.Errorf("Expected member without role to get the default role, got '%s'", roles["default-public-key"])
+	}
+}
+
+func TestApplicationService_RegisterApplication_ShouldRejectTooManyAdmins(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appService := NewApplicationService(NewMemoryRepository(), nil, nil, Config{MaxAdmins: 1})
+
+	app := createBasicApplication(testUser, "Role App", "role-app-id")
+	app.Members = append(app.Members,
+		Member{ID: "role-app-id-admin-1", Name: "admin1", Role: MemberRoleAdmin, PublicKey: "admin-1-public-key"},
+		Member{ID: "role-app-id-admin-2", Name: "admin2", Role: MemberRoleAdmin, PublicKey: "admin-2-public-key"},
+	)
+
+	// when
+	_, err := appService.RegisterApplication(testUser.PublicKey, app)
+
+	// then
+	if !errors.Is(err, ErrTooManyAdmins) {
+		t.Errorf("Expected ErrTooManyAdmins, got: %v", err)
+	}
+}
+
+func createRekeyRequest(t *testing.T, appID, oldPublicKey string, signWith ed25519.PrivateKey) RekeyMemberRequest {
+	newPublicKey, newPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	encodedPublicKey := base64.StdEncoding.EncodeToString(newPublicKey)
+	if signWith == nil {
+		signWith = newPrivateKey
+	}
+	signature := ed25519.Sign(signWith, RekeyProofMessage(appID, oldPublicKey, encodedPublicKey))
+	return RekeyMemberRequest{
+		NewPublicKey: encodedPublicKey,
+		Signature:    base64.StdEncoding.EncodeToString(signature),
+	}
+}
+
+func TestApplicationService_RekeyMember_ShouldMoveMemberToNewKey(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appRepo := NewMemoryRepository()
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
+
+	app := createBasicApplication(testUser, "Rekey App", "rekey-app-id")
+	app.Members = append(app.Members, Member{ID: "rekey-app-id-admin", Name: "admin", Role: MemberRoleAdmin, PublicKey: "old-public-key"})
+	if _, err := appService.RegisterApplication(testUser.PublicKey, app); err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+	req := createRekeyRequest(t, "rekey-app-id", "old-public-key", nil)
+
+	// when
+	member, err := appService.RekeyMember(context.Background(), "rekey-app-id", "old-public-key", req, testUser)
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if member.ID != "rekey-app-id-admin" || member.Role != MemberRoleAdmin {
+		t.Errorf("Expected member ID and role to be kept, got %s/%s", member.ID, member.Role)
+	}
+	if isMember, _ := appRepo.IsMember("rekey-app-id", "old-public-key"); isMember {
+		t.Error("Expected old public key to no longer be a member")
+	}
+	if isMember, _ := appRepo.IsMember("rekey-app-id", req.NewPublicKey); !isMember {
+		t.Error("Expected new public key to be a member")
+	}
+}
+
+func TestApplicationService_RekeyMember_ShouldRekeyThroughEventProducer(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appRepo := NewMemoryRepository()
+	events := &recordingEventProducer{appRepo: appRepo}
+	appService := NewApplicationService(appRepo, nil, events, Config{})
+
+	app := createBasicApplication(testUser, "Rekey App", "rekey-app-id")
+	app.Members = append(app.Members, Member{ID: "rekey-app-id-admin", Name: "admin", Role: MemberRoleAdmin, PublicKey: "old-public-key"})
+	if _, err := appService.RegisterApplication(testUser.PublicKey, app); err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+	req := createRekeyRequest(t, "rekey-app-id", "old-public-key", nil)
+
+	// when
+	member, err := appService.RekeyMember(context.Background(), "rekey-app-id", "old-public-key", req, testUser)
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if events.rekeyedFrom != "old-public-key" || events.memberKey != req.NewPublicKey || events.ownerPublicKey != testUser.PublicKey {
+		t.Errorf("Expected member_rekeyed from old-public-key by the owner, got from %s to %s by %s", events.rekeyedFrom, events.memberKey, events.ownerPublicKey)
+	}
+	if member.ID != "rekey-app-id-admin" || member.PublicKey != req.NewPublicKey {
+		t.Errorf("Expected the rekeyed member to be returned, got %s with key %s", member.ID, member.PublicKey)
+	}
+}
+
+func TestApplicationService_RekeyMember_ShouldRequireProofFromNewKey(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appRepo := NewMemoryRepository()
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
+
+	app := createBasicApplication(testUser, "Rekey App", "rekey-app-id")
+	app.Members = append(app.Members, Member{ID: "rekey-app-id-member", Name: "member", Role: MemberRoleMember, PublicKey: "old-public-key"})
+	if _, err := appService.RegisterApplication(testUser.PublicKey, app); err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+	_, otherPrivateKey, _ := ed25519.GenerateKey(rand.Reader)
+	forged := createRekeyRequest(t, "rekey-app-id", "old-public-key", otherPrivateKey)
+	unsigned := createRekeyRequest(t, "rekey-app-id", "old-public-key", nil)
+	unsigned.Signature = ""
+
+	// when
+	_, forgedErr := appService.RekeyMember(context.Background(), "rekey-app-id", "old-public-key", forged, testUser)
+	_, unsignedErr := appService.RekeyMember(context.Background(), "rekey-app-id", "old-public-key", unsigned, testUser)
+
+	// then
+	if !errors.Is(forgedErr, ErrInvalidRekeyProof) {
+		t.Errorf("Expected ErrInvalidRekeyProof for forged signature, got: %v", forgedErr)
+	}
+	if !errors.Is(unsignedErr, ErrInvalidRekeyProof) {
+		t.Errorf("Expected ErrInvalidRekeyProof for missing signature, got: %v", unsignedErr)
+	}
+	if isMember, _ := appRepo.IsMember("rekey-app-id", "old-public-key"); !isMember {
+		t.Error("Expected member to keep the old public key")
+	}
+}
+
+func TestApplicationService_RekeyMember_ShouldRejectNonOwner(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appRepo := NewMemoryRepository()
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
+
+	app := createBasicApplication(testUser, "Rekey App", "rekey-app-id")
+	app.Members = append(app.Members, Member{ID: "rekey-app-id-member", Name: "member", Role: MemberRoleMember, PublicKey: "old-public-key"})
+	if _, err := appService.RegisterApplication(testUser.PublicKey, app); err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+	req := createRekeyRequest(t, "rekey-app-id", "old-public-key", nil)
+
+	// when
+	_, err := appService.RekeyMember(context.Background(), "rekey-app-id", "old-public-key", req, &user.User{PublicKey: "old-public-key"})
+
+	// then
+	if !errors.Is(err, ErrNotOwner) {
+		t.Errorf("Expected ErrNotOwner, got: %v", err)
+	}
+}
+
+func TestApplicationService_GetApplication_ShouldFlagPollingOnlyApplications(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appRepo := NewMemoryRepository()
+	appService := NewApplicationService(appRepo, nil, nil, Config{PollingOnlyApps: PollingOnlyApps{"polling-app-id"}})
+
+	if _, err := appService.RegisterApplication(testUser.PublicKey, createBasicApplication(testUser, "Polling App", "polling-app-id")); err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+	if _, err := appService.RegisterApplication(testUser.PublicKey, createBasicApplication(testUser, "Realtime App", "realtime-app-id")); err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+
+	// when
+	pollingApp, _ := appService.GetApplication("polling-app-id", testUser)
+	realtimeApp, _ := appService.GetApplication("realtime-app-id", testUser)
+
+	// then
+	if !pollingApp.PollingOnly {
+		t.Error("Expected polling-only application to be flagged")
+	}
+	if realtimeApp.PollingOnly {
+		t.Error("Expected real-time application not to be flagged")
+	}
+}
+
+func createAppWithManyMembers(t *testing.T, appRepo *MemoryRepository, testUser *user.User) {
+	app := createBasicApplication(testUser, "Large App", "large-app-id")
+	for i := 0; i < 5; i++ {
+		app.Members = append(app.Members,
+			Member{ID: fmt.Sprintf("large-app-id-regular-%d", i), Name: fmt.Sprintf("member-%d", i), Role: MemberRoleMember, PublicKey: fmt.Sprintf("member-public-key-%d", i)},
+			Member{ID: fmt.Sprintf("large-app-id-viewer-%d", i), Name: fmt.Sprintf("viewer-%d", i), Role: MemberRoleViewer, PublicKey: fmt.Sprintf("viewer-public-key-%d", i)},
+		)
+	}
+	if _, err := NewApplicationService(appRepo, nil, nil, Config{}).RegisterApplication(testUser.PublicKey, app); err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+}
+
+func TestApplicationService_ListMembers_ShouldPageThroughMembers(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appRepo := NewMemoryRepository()
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
+	createAppWithManyMembers(t, appRepo, testUser)
+
+	// when
+	first, err := appService.ListMembers("large-app-id", testUser, 4, 0, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	last, err := appService.ListMembers("large-app-id", testUser, 4, 8, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// then
+	if first.Total != 11 || len(first.Members) != 4 {
+		t.Errorf("Expected 4 of 11 members on the first page, got %d of %d", len(first.Members), first.Total)
+	}
+	if len(last.Members) != 3 {
+		t.Errorf("Expected 3 members on the last page, got %d", len(last.Members))
+	}
+	seen := make(map[string]bool)
+	for _, member := range append(first.Members, last.Members...) {
+		if seen[member.ID] {
+			t.Errorf("Expected pages not to overlap, got %s twice", member.ID)
+		}
+		seen[member.ID] = true
+	}
+}
+
+func TestApplicationService_ListMembers_ShouldShowEmailsOnlyToOwnersAndAdmins(t *testing.T) {
+	// given
+	owner := createTestUser()
+	regular := &user.User{PublicKey: "member-public-key", Username: "member", Role: user.RoleOwner}
+	appService := NewApplicationService(NewMemoryRepository(), nil, nil, Config{})
+	app := createBasicApplication(owner, "App", "email-app-id")
+	app.Members[0].Email = "owner@example.com"
+	app.Members = append(app.Members, Member{ID: "email-app-id-member-2", Name: "member", Email: "member@example.com", Role: MemberRoleMember, PublicKey: regular.PublicKey})
+	if _, err := appService.RegisterApplication(owner.PublicKey, app); err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+
+	// when
+	ownerView, err := appService.ListMembers("email-app-id", owner, 0, 0, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	memberView, err := appService.ListMembers("email-app-id", regular, 0, 0, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	memberApp, err := appService.GetApplication("email-app-id", regular)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// then
+	for _, member := range ownerView.Members {
+		if member.Email == "" {
+			t.Errorf("Expected owner to see the email of %s", member.ID)
+		}
+	}
+	for _, member := range memberView.Members {
+		if member.Email != "" {
+			t.Errorf("Expected member not to see the email of %s, got %q", member.ID, member.Email)
+		}
+	}
+	for _, member := range memberApp.Members {
+		if member.Email != "" {
+			t.Errorf("Expected member not to see the email of %s, got %q", member.ID, member.Email)
+		}
+	}
+}
+
+func TestApplicationService_ListMembers_ShouldFilterByRole(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appRepo := NewMemoryRepository()
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
+	createAppWithManyMembers(t, appRepo, testUser)
+
+	// when
+	page, err := appService.ListMembers("large-app-id", testUser, 0, 0, MemberRoleViewer)
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if page.Total != 5 || len(page.Members) != 5 || page.Limit != DefaultMembersPageSize {
+		t.Errorf("Expected 5 viewers with the default limit, got %d of %d (limit %d)", len(page.Members), page.Total, page.Limit)
+	}
+	for _, member := range page.Members {
+		if member.Role != MemberRoleViewer {
+			t.Errorf("Expected only viewers, got %s", member.Role)
+		}
+	}
+
+	if _, err := appService.ListMembers("large-app-id", testUser, 10, 0, "superuser"); !errors.Is(err, ErrInvalidMemberRole) {
+		t.Errorf("Expected ErrInvalidMemberRole for unknown role filter, got: %v", err)
+	}
+}
+
+func TestApplicationService_GetApplicationWithoutMembers_ShouldSkipMembers(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appRepo := NewMemoryRepository()
+	appService := NewApplicationService(appRepo, nil, nil, Config{})
+	createAppWithManyMembers(t, appRepo, testUser)
+
+	// when
+	app, err := appService.GetApplicationWithoutMembers("large-app-id", testUser)
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(app.Members) != 0 {
+		t.Errorf("Expected no members, got %d", len(app.Members))
+	}
+	if len(app.ComponentGroups) == 0 {
+		t.Error("Expected component groups to still be loaded")
+	}
+}
+
+func registerAppWithTwoGroups(t *testing.T, appService *ApplicationService, testUser *user.User) *Application {
+	app := createBasicApplication(testUser, "Fields App", "fields-app-id")
+	app.ComponentGroups[0].Components = []Component{{ID: "comp-1", Name: "First", Index: 0}}
+	app.ComponentGroups = append(app.ComponentGroups, ComponentGroup{
+		ID:         "fields-app-id-group-2",
+		Name:       "Second Group",
+		Index:      1,
+		Components: []Component{{ID: "comp-2", Name: "Second", Index: 0}},
+	})
+	registeredApp, err := appService.RegisterApplication(testUser.PublicKey, app)
+	if err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+	return registeredApp
+}
+
+func TestApplicationService_GetApplicationFields_ShouldReturnOnlyMembers(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appService := NewApplicationService(NewMemoryRepository(), nil, nil, Config{})
+	app := registerAppWithTwoGroups(t, appService, testUser)
+
+	// when
+	result, err := appService.GetApplicationFields(app.ID, testUser, ApplicationFields{Members: true})
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(result.Members) != 1 || result.Members[0].PublicKey != testUser.PublicKey {
+		t.Errorf("Expected the owner as only member, got %+v", result.Members)
+	}
+	if len(result.ComponentGroups) != 0 {
+		t.Errorf("Expected no component groups, got %d", len(result.ComponentGroups))
+	}
+	if result.Name != "Fields App" {
+		t.Errorf("Expected application metadata to be returned, got name %q", result.Name)
+	}
+}
+
+func TestApplicationService_GetApplicationFields_ShouldReturnOnlyGroups(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appService := NewApplicationService(NewMemoryRepository(), nil, nil, Config{})
+	app := registerAppWithTwoGroups(t, appService, testUser)
+
+	// when
+	result, err := appService.GetApplicationFields(app.ID, testUser, ApplicationFields{Groups: true})
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(result.Members) != 0 {
+		t.Errorf("Expected no members, got %d", len(result.Members))
+	}
+	if len(result.ComponentGroups) != 2 {
+		t.Fatalf("Expected 2 component groups, got %d", len(result.ComponentGroups))
+	}
+	for _, group := range result.ComponentGroups {
+		if len(group.Components) != 1 {
+			t.Errorf("Expected group %s to have 1 component, got %d", group.ID, len(group.Components))
+		}
+	}
+}
+
+func TestApplicationService_GetApplicationFields_ShouldReturnOnlySelectedGroup(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appService := NewApplicationService(NewMemoryRepository(), nil, nil, Config{})
+	app := registerAppWithTwoGroups(t, appService, testUser)
+	fields, err := ParseApplicationFields("", "fields-app-id-group-2")
+	if err != nil {
+		t.Fatalf("Failed to parse fields: %v", err)
+	}
+
+	// when
+	result, err := appService.GetApplicationFields(app.ID, testUser, fields)
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(result.ComponentGroups) != 1 || result.ComponentGroups[0].ID != "fields-app-id-group-2" {
+		t.Fatalf("Expected only the selected group, got %+v", result.ComponentGroups)
+	}
+	if len(result.ComponentGroups[0].Components) != 1 || result.ComponentGroups[0].Components[0].ID != "comp-2" {
+		t.Errorf("Expected the selected group's component, got %+v", result.ComponentGroups[0].Components)
+	}
+	if len(result.Members) != 0 {
+		t.Errorf("Expected no members, got %d", len(result.Members))
+	}
+}
+
+func TestApplicationService_GetApplicationFields_ShouldRejectGroupOfAnotherApplication(t *testing.T) {
+	// given
+	testUser := createTestUser()
+	appService := NewApplicationService(NewMemoryRepository(), nil, nil, Config{})
+	app := registerAppWithTwoGroups(t, appService, testUser)
+	other := createBasicApplication(testUser, "Other App", "other-app-id")
+	if _, err := appService.RegisterApplication(testUser.PublicKey, other); err != nil {
+		t.Fatalf("Failed to register application: %v", err)
+	}
+
+	// when
+	_, err := appService.GetApplicationFields(app.ID, testUser, ApplicationFields{Groups: true, GroupID: "other-app-id-group-1"})
+
+	// then
+	if !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("Expected ErrGroupNotFound, got: %v", err)
+	}
+}
+
+func TestParseApplicationFields_ShouldRejectUnknownField(t *testing.T) {
+	// when
+	_, err := ParseApplicationFields("members,secrets", "")
+
+	// then
+	if !errors.Is(err, ErrInvalidFields) {
+		t.Errorf("Expected ErrInvalidFields, got: %v", err)
+	}
+}
+
+func createGroupWithComponents(appRepo *MemoryRepository, indices ...int) {
+	appRepo.CreateComponentGroup(&ComponentGroup{ID: "group-1", ApplicationID: "app-1", Name: "Group"})
+	for i, index := range indices {
+		appRepo.CreateComponent(&Component{ID: fmt.Sprintf("component-%d", i), ComponentGroupID: "group-1", ApplicationID: "app-1", Index: index})
+	}
+}
+
+func componentIndices(t *testing.T, appRepo *MemoryRepository) map[string]int {
+	components, err := appRepo.GetComponentsByGroupID("group-1")
+	if err != nil {
+		t.Fatalf("Failed to get components: %v", err)
+	}
+	indices := make(map[string]int)
+	for _, component := range components {
+		indices[component.ID] = component.Index
+	}
+	return indices
+}
+
+func TestApplicationService_CountApplications_ShouldCountOnlyMembershipsOfLiveApplications(t *testing.T) {
+	// given
+	appRepo := NewMemoryRepository()
+	for _, appID := range []string{"app-1", "app-2", "app-3"} {
+		appRepo.CreateApplication(&Application{ID: appID, Name: appID})
+	}
+	appRepo.CreateMember(&Member{ID: "member-1", ApplicationID: "app-1", Name: "me", Role: MemberRoleOwner, PublicKey: "my-key"})
+	appRepo.CreateMember(&Member{ID: "member-2", ApplicationID: "app-2", Name: "me", Role: MemberRoleMember, PublicKey: "my-key"})
+	appRepo.CreateMember(&Member{ID: "member-3", ApplicationID: "app-3", Name: "other", Role: MemberRoleOwner, PublicKey: "other-key"})
+	appRepo.DeleteApplication("app-2")
+	service := NewApplicationService(appRepo, nil, nil, Config{})
+
+	// when
+	count, err := service.CountApplications("my-key")
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 application, got %d", count)
+	}
+}
+
+func TestMemoryRepository_UpdateComponentIndex_ShouldKeepIndicesUniqueAndContiguous(t *testing.T) {
+	// given
+	appRepo := NewMemoryRepository()
+	createGroupWithComponents(appRepo, 0, 1, 2, 3)
+
+	// when - move the last component onto an index already in use
+	err := appRepo.UpdateComponentIndex("component-3", 1)
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := map[string]int{"component-0": 0, "component-3": 1, "component-1": 2, "component-2": 3}
+	for id, index := range componentIndices(t, appRepo) {
+		if expected[id] != index {
+			t.Errorf("Expected %s at index %d, got %d", id, expected[id], index)
+		}
+	}
+}
+
+func TestMemoryRepository_UpdateComponentIndex_ShouldNormalizeDuplicateAndOutOfRangeIndices(t *testing.T) {
+	// given - components created with colliding and out-of-range indices
+	appRepo := NewMemoryRepository()
+	createGroupWithComponents(appRepo, 5, 5, 9)
+
+	// when
+	err := appRepo.UpdateComponentIndex("component-0", 100)
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	seen := make(map[int]bool)
+	for id, index := range componentIndices(t, appRepo) {
+		if seen[index] {
+			t.Errorf("Expected unique indices, got %d twice", index)
+		}
+		seen[index] = true
+		if index < 0 || index > 2 {
+			t.Errorf("Expected contiguous indices 0-2, got %d for %s", index, id)
+		}
+	}
+	if componentIndices(t, appRepo)["component-0"] != 2 {
+		t.Error("Expected component moved past the end to be last")
+	}
+}
+
+func TestMemoryRepository_CreateComponent_ShouldInsertAtIndexAndShiftFollowingComponents(t *testing.T) {
+	// given
+	appRepo := NewMemoryRepository()
+	createGroupWithComponents(appRepo, 0, 1, 2)
+
+	// when - add a component onto an index already in use
+	err := appRepo.CreateComponent(&Component{ID: "component-new", ComponentGroupID: "group-1", ApplicationID: "app-1", Index: 1})
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := map[string]int{"component-0": 0, "component-new": 1, "component-1": 2, "component-2": 3}
+	for id, index := range componentIndices(t, appRepo) {
+		if expected[id] != index {
+			t.Errorf("Expected %s at index %d, got %d", id, expected[id], index)
+		}
+	}
+}
+
+func TestMemoryRepository_DeleteComponent_ShouldCloseGapInIndices(t *testing.T) {
+	// given
+	appRepo := NewMemoryRepository()
+	createGroupWithComponents(appRepo, 0, 1, 2)
+
+	// when
+	err := appRepo.DeleteComponent("component-1")
+
+	// then
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := map[string]int{"component-0": 0, "component-2": 1}
+	indices := componentIndices(t, appRepo)
+	if len(indices) != len(expected) {
+		t.Fatalf("Expected %d components, got %d", len(expected), len(indices))
+	}
+	for id, index := range indices {
+		if expected[id] != index {
+			t.Errorf("Expected %s at index %d, got %d", id, expected[id], index)
+		}
+	}
+}
+
+func TestMoveToIndex_ShouldClampIndex(t *testing.T) {
+	// when
+	first := moveToIndex([]string{"a", "b", "c"}, "c", -3)
+	last := moveToIndex([]string{"a", "b", "c"}, "a", 10)
+
+	// then
+	if fmt.Sprint(first) != "[c a b]" {
+		t.Errorf("Expected [c a b], got %v", first)
+	}
+	if fmt.Sprint(last) != "[b c a]" {
+		t.Errorf("Expected [b c a], got %v", last)
+	}
+}
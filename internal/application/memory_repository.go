@@ -1,6 +1,7 @@
 package application
 
 import (
+	"database/sql"
 	"fmt"
 	"sort"
 	"time"
@@ -11,6 +12,7 @@ type MemoryRepository struct {
 	componentGroups map[string]*ComponentGroup
 	components      map[string]*Component
 	members         map[string]*Member
+	dataHistory     map[string][]*ComponentDataVersion
 }
 
 func NewMemoryRepository() *MemoryRepository {
@@ -19,6 +21,7 @@ func NewMemoryRepository() *MemoryRepository {
 		componentGroups: make(map[string]*ComponentGroup),
 		components:      make(map[string]*Component),
 		members:         make(map[string]*Member),
+		dataHistory:     make(map[string][]*ComponentDataVersion),
 	}
 }
 
@@ -28,6 +31,14 @@ func (r *MemoryRepository) CreateApplication(app *Application) error {
 }
 
 func (r *MemoryRepository) GetApplicationByID(id string) (*Application, error) {
+	return r.getApplication(id, true)
+}
+
+func (r *MemoryRepository) GetApplicationByIDWithoutMembers(id string) (*Application, error) {
+	return r.getApplication(id, false)
+}
+
+func (r *MemoryRepository) GetApplicationMetadata(id string) (*Application, error) {
 	app, exists := r.applications[id]
 	if !exists {
 		return nil, fmt.Errorf("application not found")
@@ -37,7 +48,19 @@ func (r *MemoryRepository) GetApplicationByID(id string) (*Application, error) {
 		return nil, fmt.Errorf("application not found")
 	}
 
-	// Create a copy and load component groups
+	result := *app
+	result.ComponentGroups = nil
+	result.Members = nil
+	return &result, nil
+}
+
+func (r *MemoryRepository) getApplication(id string, includeMembers bool) (*Application, error) {
+	app, err := r.GetApplicationMetadata(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load component groups
 	result := *app
 	groups, err := r.GetComponentGroupsByApplicationID(id)
 	if err != nil {
@@ -59,6 +82,11 @@ func (r *MemoryRepository) GetApplicationByID(id string) (*Application, error) {
 		}
 	}
 
+	if !includeMembers {
+		result.Members = []Member{}
+		return &result, nil
+	}
+
 	// Load members
 	members, err := r.GetMembersByApplicationID(id)
 	if err != nil {
@@ -80,9 +108,10 @@ func (r *MemoryRepository) GetApplicationState(id string) (*ApplicationState, er
 	}
 
 	return &ApplicationState{
-		ID:        app.ID,
-		Name:      app.Name,
-		UpdatedAt: app.UpdatedAt,
+		ID:           app.ID,
+		Name:         app.Name,
+		UpdatedAt:    app.UpdatedAt,
+		LastSequence: app.LastSequence,
 	}, nil
 }
 
@@ -148,6 +177,9 @@ func (r *MemoryRepository) GetComponentGroupsByApplicationID(appID string) ([]*C
 
 func (r *MemoryRepository) CreateComponent(component *Component) error {
 	r.components[component.ID] = component
+	r.reindexComponents(component.ComponentGroupID, func(ids []string) []string {
+		return moveToIndex(ids, component.ID, component.Index)
+	})
 	return nil
 }
 
@@ -205,19 +237,75 @@ func (r *MemoryRepository) UpdateComponentIndex(componentID string, index int) e
 	if !exists {
 		return fmt.Errorf("component not found")
 	}
-	comp.Index = index
+
+	r.reindexComponents(comp.ComponentGroupID, func(ids []string) []string {
+		return moveToIndex(ids, componentID, index)
+	})
 	return nil
 }
 
+// reindexComponents renumbers the group's components in the order returned by order
+func (r *MemoryRepository) reindexComponents(groupID string, order func(ids []string) []string) {
+	siblings, _ := r.GetComponentsByGroupID(groupID)
+	sort.Slice(siblings, func(i, j int) bool {
+		if siblings[i].Index != siblings[j].Index {
+			return siblings[i].Index < siblings[j].Index
+		}
+		return siblings[i].ID < siblings[j].ID
+	})
+
+	ids := make([]string, len(siblings))
+	for i, sibling := range siblings {
+		ids[i] = sibling.ID
+	}
+	for newIndex, id := range order(ids) {
+		r.components[id].Index = newIndex
+	}
+}
+
 func (r *MemoryRepository) DeleteComponent(componentID string) error {
-	_, exists := r.components[componentID]
+	comp, exists := r.components[componentID]
 	if !exists {
 		return fmt.Errorf("component not found")
 	}
 	delete(r.components, componentID)
+	delete(r.dataHistory, componentID)
+	r.reindexComponents(comp.ComponentGroupID, keepOrder)
 	return nil
 }
 
+func (r *MemoryRepository) AddComponentDataVersion(version *ComponentDataVersion, keep int) error {
+	if _, exists := r.components[version.ComponentID]; !exists {
+		return fmt.Errorf("component not found")
+	}
+	// History is kept most recent first
+	versions := append([]*ComponentDataVersion{version}, r.dataHistory[version.ComponentID]...)
+	if keep > 0 && len(versions) > keep {
+		versions = versions[:keep]
+	}
+	r.dataHistory[version.ComponentID] = versions
+	return nil
+}
+
+func (r *MemoryRepository) GetComponentDataHistory(componentID string, limit int) ([]*ComponentDataVersion, error) {
+	versions := r.dataHistory[componentID]
+	if limit > 0 && len(versions) > limit {
+		versions = versions[:limit]
+	}
+	return append([]*ComponentDataVersion{}, versions...), nil
+}
+
+func (r *MemoryRepository) GetComponentDataVersion(versionID string) (*ComponentDataVersion, error) {
+	for _, versions := range r.dataHistory {
+		for _, version := range versions {
+			if version.ID == versionID {
+				return version, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("component data version not found")
+}
+
 func (r *MemoryRepository) GetComponentGroupByID(groupID string) (*ComponentGroup, error) {
 	group, exists := r.componentGroups[groupID]
 	if !exists {
@@ -283,6 +371,33 @@ func (r *MemoryRepository) GetMembersByApplicationID(appID string) ([]*Member, e
 	return result, nil
 }
 
+func (r *MemoryRepository) GetMembersByApplicationIDPaged(appID string, limit, offset int, roleFilter MemberRole) ([]*Member, int, error) {
+	var matching []*Member
+	for _, member := range r.members {
+		if member.ApplicationID == appID && (roleFilter == "" || member.Role == roleFilter) {
+			matching = append(matching, member)
+		}
+	}
+
+	// Same order as the SQL repository so pages never overlap
+	sort.Slice(matching, func(i, j int) bool {
+		if matching[i].Role != matching[j].Role {
+			return matching[i].Role < matching[j].Role
+		}
+		if matching[i].Name != matching[j].Name {
+			return matching[i].Name < matching[j].Name
+		}
+		return matching[i].ID < matching[j].ID
+	})
+
+	total := len(matching)
+	if offset >= total {
+		return []*Member{}, total, nil
+	}
+	end := min(offset+limit, total)
+	return matching[offset:end], total, nil
+}
+
 func (r *MemoryRepository) GetMemberByID(memberID string) (*Member, error) {
 	member, exists := r.members[memberID]
 	if !exists {
@@ -359,6 +474,24 @@ func (r *MemoryRepository) GetApplicationsByMemberPublicKey(publicKey string) ([
 	return result, nil
 }
 
+// CountApplicationsByMemberPublicKey counts the non-deleted applications where the user is a member
+func (r *MemoryRepository) CountApplicationsByMemberPublicKey(publicKey string) (int, error) {
+	appIDSet := make(map[string]bool)
+	for _, member := range r.members {
+		if member.PublicKey == publicKey {
+			appIDSet[member.ApplicationID] = true
+		}
+	}
+
+	count := 0
+	for appID := range appIDSet {
+		if app, ok := r.applications[appID]; ok && app.DeletedAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
 // GetAppVersionsByMemberPublicKey returns a lightweight map of app ID → version info
 // for all non-deleted apps the user is a member of.
 func (r *MemoryRepository) GetAppVersionsByMemberPublicKey(publicKey string) (map[string]AppVersionInfo, error) {
@@ -408,4 +541,9 @@ func (r *MemoryRepository) GetMemberCount(appID string) (int, error) {
 		}
 	}
 	return count, nil
-}
\ No newline at end of file
+}
+
+// WithTx returns the repository itself, as in-memory changes are not transactional
+func (r *MemoryRepository) WithTx(tx *sql.Tx) ApplicationRepository {
+	return r
+}
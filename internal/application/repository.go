@@ -8,13 +8,38 @@ import (
 )
 
 type Repository struct {
-	db *sql.DB
+	db queryer
+}
+
+// queryer is the subset of *sql.DB and *sql.Tx the repository runs statements on
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
 func NewRepository(db *sql.DB) *Repository {
 	return &Repository{db: db}
 }
 
+// WithTx returns a repository that runs its statements within tx
+func (r *Repository) WithTx(tx *sql.Tx) ApplicationRepository {
+	return &Repository{db: tx}
+}
+
+// begin returns a transaction for a multi-statement change. A repository already bound to
+// a transaction joins it, leaving commit and rollback to the transaction's owner.
+func (r *Repository) begin() (tx *sql.Tx, commit func() error, rollback func(), err error) {
+	if current, ok := r.db.(*sql.Tx); ok {
+		return current, func() error { return nil }, func() {}, nil
+	}
+	tx, err = r.db.(*sql.DB).Begin()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return tx, tx.Commit, func() { tx.Rollback() }, nil
+}
+
 func (r *Repository) CreateApplication(app *Application) error {
 	query := `INSERT INTO applications (id, name, icon, server_public_key, created_at, updated_at)
 			  VALUES ($1, $2, $3, $4, $5, $6)
@@ -29,6 +54,15 @@ func (r *Repository) CreateApplication(app *Application) error {
 }
 
 func (r *Repository) GetApplicationByID(id string) (*Application, error) {
+	return r.getApplication(id, true)
+}
+
+func (r *Repository) GetApplicationByIDWithoutMembers(id string) (*Application, error) {
+	return r.getApplication(id, false)
+}
+
+// GetApplicationMetadata loads only the applications row, leaving ComponentGroups and Members nil
+func (r *Repository) GetApplicationMetadata(id string) (*Application, error) {
 	query := `SELECT id, name, icon, server_public_key, created_at, updated_at, last_sequence
 			  FROM applications WHERE id = $1 AND deleted_at IS NULL`
 
@@ -50,6 +84,15 @@ func (r *Repository) GetApplicationByID(id string) (*Application, error) {
 		app.LastSequence = &lastSequence.Int64
 	}
 
+	return app, nil
+}
+
+func (r *Repository) getApplication(id string, includeMembers bool) (*Application, error) {
+	app, err := r.GetApplicationMetadata(id)
+	if err != nil {
+		return nil, err
+	}
+
 	// Load component groups
 	groups, err := r.GetComponentGroupsByApplicationID(id)
 	if err != nil {
@@ -73,6 +116,11 @@ func (r *Repository) GetApplicationByID(id string) (*Application, error) {
 		}
 	}
 
+	if !includeMembers {
+		app.Members = []Member{}
+		return app, nil
+	}
+
 	// Load members
 	members, err := r.GetMembersByApplicationID(id)
 	if err != nil {
@@ -89,16 +137,24 @@ func (r *Repository) GetApplicationByID(id string) (*Application, error) {
 }
 
 func (r *Repository) GetApplicationState(id string) (*ApplicationState, error) {
-	query := `SELECT id, name, updated_at FROM applications WHERE id = $1`
+	query := `SELECT id, name, updated_at, last_sequence FROM applications WHERE id = $1`
 
 	state := &ApplicationState{}
-	err := r.db.QueryRow(query, id).Scan(&state.ID, &state.Name, &state.UpdatedAt)
+	var lastSequence sql.NullInt64
+	err := r.db.QueryRow(query, id).Scan(&state.ID, &state.Name, &state.UpdatedAt, &lastSequence)
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("application not found")
 	}
+	if err != nil {
+		return nil, err
+	}
 
-	return state, err
+	if lastSequence.Valid {
+		state.LastSequence = &lastSequence.Int64
+	}
+
+	return state, nil
 }
 
 func (r *Repository) UpdateLastSequence(appID string, sequence int64) error {
@@ -198,6 +254,8 @@ func (r *Repository) GetComponentGroupsByApplicationID(appID string) ([]*Compone
 	return groups, rows.Err()
 }
 
+// CreateComponent stores the component and moves it to its index within its group,
+// renumbering the group's components so their indices stay unique and contiguous
 func (r *Repository) CreateComponent(component *Component) error {
 	query := `INSERT INTO components (id, component_group_id, application_id, name, data, index_order)
 			  VALUES ($1, $2, $3, $4, $5, $6)
@@ -215,7 +273,13 @@ func (r *Repository) CreateComponent(component *Component) error {
 		dataJSON = string(dataBytes)
 	}
 
-	_, err := r.db.Exec(query,
+	tx, commit, rollback, err := r.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer rollback()
+
+	_, err = tx.Exec(query,
 		component.ID,
 		component.ComponentGroupID,
 		component.ApplicationID,
@@ -223,7 +287,18 @@ func (r *Repository) CreateComponent(component *Component) error {
 		dataJSON,
 		component.Index,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	err = reindexComponents(tx, component.ComponentGroupID, func(ids []string) []string {
+		return moveToIndex(ids, component.ID, component.Index)
+	})
+	if err != nil {
+		return err
+	}
+
+	return commit()
 }
 
 func (r *Repository) GetComponentsByGroupID(groupID string) ([]*Component, error) {
@@ -364,44 +439,194 @@ func (r *Repository) UpdateComponentData(componentID string, data map[string]int
 	return nil
 }
 
+// UpdateComponentIndex moves the component to index within its group and renumbers the
+// group's components so their indices stay unique and contiguous. The rows are locked
+// for the duration, so concurrent reorders of the same group apply one after the other.
 func (r *Repository) UpdateComponentIndex(componentID string, index int) error {
-	query := `UPDATE components SET index_order = $1 WHERE id = $2`
-	result, err := r.db.Exec(query, index, componentID)
+	tx, commit, rollback, err := r.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer rollback()
+
+	var groupID string
+	err = tx.QueryRow(`SELECT component_group_id FROM components WHERE id = $1 FOR UPDATE`, componentID).Scan(&groupID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("component not found")
+	}
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	err = reindexComponents(tx, groupID, func(ids []string) []string {
+		return moveToIndex(ids, componentID, index)
+	})
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("component not found")
+	return commit()
+}
+
+// reindexComponents locks the group's components and renumbers them in the order returned
+// by order, writing only the indices that change
+func reindexComponents(tx *sql.Tx, groupID string, order func(ids []string) []string) error {
+	rows, err := tx.Query(`SELECT id, index_order FROM components
+			  WHERE component_group_id = $1 ORDER BY index_order, id FOR UPDATE`, groupID)
+	if err != nil {
+		return err
 	}
 
+	var ids []string
+	currentIndex := make(map[string]int)
+	for rows.Next() {
+		var id string
+		var indexOrder int
+		if err := rows.Scan(&id, &indexOrder); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+		currentIndex[id] = indexOrder
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for newIndex, id := range order(ids) {
+		if currentIndex[id] == newIndex {
+			continue
+		}
+		if _, err := tx.Exec(`UPDATE components SET index_order = $1 WHERE id = $2`, newIndex, id); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// DeleteComponent removes the component and closes the gap it leaves in its group's indices
 func (r *Repository) DeleteComponent(componentID string) error {
-	query := `DELETE FROM components WHERE id = $1`
-	result, err := r.db.Exec(query, componentID)
+	tx, commit, rollback, err := r.begin()
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	var groupID string
+	err = tx.QueryRow(`DELETE FROM components WHERE id = $1 RETURNING component_group_id`, componentID).Scan(&groupID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("component not found")
+	}
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("component not found")
+	if err := reindexComponents(tx, groupID, keepOrder); err != nil {
+		return err
 	}
 
+	return commit()
+}
+
+func (r *Repository) AddComponentDataVersion(version *ComponentDataVersion, keep int) error {
+	var dataJSON string
+	if version.Data != nil {
+		dataBytes, err := json.Marshal(version.Data)
+		if err != nil {
+			return fmt.Errorf("failed to marshal component data: %w", err)
+		}
+		dataJSON = string(dataBytes)
+	}
+
+	query := `INSERT INTO component_data_history
+			  (id, component_id, application_id, data, event_id, sequence_number, changed_by_public_key, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := r.db.Exec(query, version.ID, version.ComponentID, version.ApplicationID, dataJSON,
+		version.EventID, version.SequenceNumber, version.ChangedByPublicKey, version.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store component data version: %w", err)
+	}
+
+	if keep <= 0 {
+		return nil
+	}
+	pruneQuery := `DELETE FROM component_data_history
+				   WHERE component_id = $1 AND id NOT IN (
+				       SELECT id FROM component_data_history
+				       WHERE component_id = $1
+				       ORDER BY sequence_number DESC, created_at DESC
+				       LIMIT $2)`
+	if _, err := r.db.Exec(pruneQuery, version.ComponentID, keep); err != nil {
+		return fmt.Errorf("failed to prune component data history: %w", err)
+	}
 	return nil
 }
 
+func (r *Repository) GetComponentDataHistory(componentID string, limit int) ([]*ComponentDataVersion, error) {
+	query := `SELECT id, component_id, application_id, data, event_id, sequence_number, changed_by_public_key, created_at
+			  FROM component_data_history
+			  WHERE component_id = $1
+			  ORDER BY sequence_number DESC, created_at DESC
+			  LIMIT $2`
+
+	rows, err := r.db.Query(query, componentID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query component data history: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []*ComponentDataVersion{}
+	for rows.Next() {
+		version, err := scanComponentDataVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, rows.Err()
+}
+
+func (r *Repository) GetComponentDataVersion(versionID string) (*ComponentDataVersion, error) {
+	query := `SELECT id, component_id, application_id, data, event_id, sequence_number, changed_by_public_key, created_at
+			  FROM component_data_history
+			  WHERE id = $1`
+
+	version, err := scanComponentDataVersion(r.db.QueryRow(query, versionID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("component data version not found")
+	}
+	return version, err
+}
+
+// scanComponentDataVersion reads a component_data_history row from a *sql.Row or *sql.Rows
+func scanComponentDataVersion(row interface{ Scan(dest ...any) error }) (*ComponentDataVersion, error) {
+	version := &ComponentDataVersion{}
+	var dataJSON sql.NullString
+	err := row.Scan(
+		&version.ID,
+		&version.ComponentID,
+		&version.ApplicationID,
+		&dataJSON,
+		&version.EventID,
+		&version.SequenceNumber,
+		&version.ChangedByPublicKey,
+		&version.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if dataJSON.Valid && dataJSON.String != "" {
+		if err := json.Unmarshal([]byte(dataJSON.String), &version.Data); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal component data version: %w", err)
+		}
+	}
+
+	return version, nil
+}
+
 func (r *Repository) GetComponentGroupByID(groupID string) (*ComponentGroup, error) {
 	query := `SELECT id, application_id, name, index_order
 			  FROM component_groups WHERE id = $1`
@@ -463,19 +688,20 @@ func (r *Repository) DeleteComponentGroup(groupID string) error {
 }
 
 func (r *Repository) CreateMember(member *Member) error {
-	query := `INSERT INTO members (id, application_id, name, role, public_key, avatar_storage_id)
-			  VALUES ($1, $2, $3, $4, $5, $6)
+	query := `INSERT INTO members (id, application_id, name, role, public_key, avatar_storage_id, email)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)
 			  ON CONFLICT (id) DO UPDATE SET
 			    name = EXCLUDED.name,
 			    role = EXCLUDED.role,
-			    avatar_storage_id = EXCLUDED.avatar_storage_id`
+			    avatar_storage_id = EXCLUDED.avatar_storage_id,
+			    email = EXCLUDED.email`
 
-	_, err := r.db.Exec(query, member.ID, member.ApplicationID, member.Name, string(member.Role), member.PublicKey, member.AvatarStorageID)
+	_, err := r.db.Exec(query, member.ID, member.ApplicationID, member.Name, string(member.Role), member.PublicKey, member.AvatarStorageID, member.Email)
 	return err
 }
 
 func (r *Repository) GetMembersByApplicationID(appID string) ([]*Member, error) {
-	query := `SELECT id, application_id, name, role, public_key, avatar_storage_id
+	query := `SELECT id, application_id, name, role, public_key, avatar_storage_id, email
 			  FROM members WHERE application_id = $1 ORDER BY role, name`
 
 	rows, err := r.db.Query(query, appID)
@@ -496,6 +722,7 @@ func (r *Repository) GetMembersByApplicationID(appID string) ([]*Member, error)
 			&roleStr,
 			&member.PublicKey,
 			&member.AvatarStorageID,
+			&member.Email,
 		)
 		if err != nil {
 			return nil, err
@@ -509,8 +736,54 @@ func (r *Repository) GetMembersByApplicationID(appID string) ([]*Member, error)
 	return members, rows.Err()
 }
 
+func (r *Repository) GetMembersByApplicationIDPaged(appID string, limit, offset int, roleFilter MemberRole) ([]*Member, int, error) {
+	var total int
+	countQuery := `SELECT COUNT(*) FROM members WHERE application_id = $1 AND ($2 = '' OR role = $2)`
+	if err := r.db.QueryRow(countQuery, appID, string(roleFilter)).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT m.id, m.application_id, m.name, m.role, m.public_key, m.avatar_storage_id, m.email, u.last_seen_at
+			  FROM members m LEFT JOIN users u ON u.public_key = m.public_key
+			  WHERE m.application_id = $1 AND ($2 = '' OR m.role = $2)
+			  ORDER BY m.role, m.name, m.id
+			  LIMIT $3 OFFSET $4`
+
+	rows, err := r.db.Query(query, appID, string(roleFilter), limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	members := []*Member{}
+	for rows.Next() {
+		member := &Member{}
+		var roleStr string
+
+		err := rows.Scan(
+			&member.ID,
+			&member.ApplicationID,
+			&member.Name,
+			&roleStr,
+			&member.PublicKey,
+			&member.AvatarStorageID,
+			&member.Email,
+			&member.LastSeenAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		member.Role = MemberRole(roleStr)
+
+		members = append(members, member)
+	}
+
+	return members, total, rows.Err()
+}
+
 func (r *Repository) GetMemberByID(memberID string) (*Member, error) {
-	query := `SELECT id, application_id, name, role, public_key, avatar_storage_id
+	query := `SELECT id, application_id, name, role, public_key, avatar_storage_id, email
 			  FROM members WHERE id = $1`
 
 	member := &Member{}
@@ -523,6 +796,7 @@ func (r *Repository) GetMemberByID(memberID string) (*Member, error) {
 		&roleStr,
 		&member.PublicKey,
 		&member.AvatarStorageID,
+		&member.Email,
 	)
 
 	if err == sql.ErrNoRows {
@@ -538,10 +812,10 @@ func (r *Repository) GetMemberByID(memberID string) (*Member, error) {
 }
 
 func (r *Repository) UpdateMember(member *Member) error {
-	query := `UPDATE members SET name = $1, role = $2, public_key = $3, avatar_storage_id = $4
-			  WHERE id = $5`
+	query := `UPDATE members SET name = $1, role = $2, public_key = $3, avatar_storage_id = $4, email = $5
+			  WHERE id = $6`
 
-	result, err := r.db.Exec(query, member.Name, string(member.Role), member.PublicKey, member.AvatarStorageID, member.ID)
+	result, err := r.db.Exec(query, member.Name, string(member.Role), member.PublicKey, member.AvatarStorageID, member.Email, member.ID)
 	if err != nil {
 		return err
 	}
@@ -585,7 +859,7 @@ func (r *Repository) DeleteMember(memberID string) error {
 }
 
 func (r *Repository) GetMemberByPublicKey(appID, publicKey string) (*Member, error) {
-	query := `SELECT id, application_id, name, role, public_key, avatar_storage_id
+	query := `SELECT id, application_id, name, role, public_key, avatar_storage_id, email
 			  FROM members WHERE application_id = $1 AND public_key = $2`
 
 	member := &Member{}
@@ -598,6 +872,7 @@ func (r *Repository) GetMemberByPublicKey(appID, publicKey string) (*Member, err
 		&roleStr,
 		&member.PublicKey,
 		&member.AvatarStorageID,
+		&member.Email,
 	)
 
 	if err == sql.ErrNoRows {
@@ -678,6 +953,19 @@ func (r *Repository) GetApplicationsByMemberPublicKey(publicKey string) ([]*Appl
 	return applications, rows.Err()
 }
 
+func (r *Repository) CountApplicationsByMemberPublicKey(publicKey string) (int, error) {
+	query := `SELECT COUNT(DISTINCT a.id)
+			  FROM applications a
+			  INNER JOIN members m ON a.id = m.application_id
+			  WHERE m.public_key = $1 AND a.deleted_at IS NULL`
+
+	var count int
+	if err := r.db.QueryRow(query, publicKey).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 func (r *Repository) GetAppVersionsByMemberPublicKey(publicKey string) (map[string]AppVersionInfo, error) {
 	query := `SELECT DISTINCT a.id, a.last_sequence
 			  FROM applications a
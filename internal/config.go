@@ -3,42 +3,91 @@ package internal
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/prappser/prappser_server/internal/application"
+	"github.com/prappser/prappser_server/internal/event"
+	"github.com/prappser/prappser_server/internal/invitation"
+	"github.com/prappser/prappser_server/internal/middleware"
+	"github.com/prappser/prappser_server/internal/setup"
+	"github.com/prappser/prappser_server/internal/storage"
 	"github.com/prappser/prappser_server/internal/user"
+	"github.com/prappser/prappser_server/internal/webhook"
+	"github.com/prappser/prappser_server/internal/websocket"
 )
 
+// ErrInsecureExternalURL is returned in production when the external URL is not https,
+// since it is embedded in invite links and tokens
+var ErrInsecureExternalURL = errors.New("external URL must use https in production")
+
 type Config struct {
 	Users          user.Config
+	Events         event.Config
+	Applications   application.Config
+	Invitations    invitation.Config
+	WebSocket      websocket.Config
+	Webhooks       webhook.Config
 	Storage        StorageConfig
+	Landing        setup.LandingConfig
 	Port           string
 	ExternalURL    string
 	AllowedOrigins []string
-	MasterPassword string
+	// AppAllowedOrigins are extra origins allowed only for the given application
+	AppAllowedOrigins map[string][]string
+	MasterPassword    string
+	// RequestTimeouts bounds request handling per route class; zero disables a class
+	RequestTimeouts map[middleware.RouteClass]time.Duration
+	// MaxConcurrentRequests caps in-flight requests, shedding the rest with 503; zero disables it
+	MaxConcurrentRequests int
+	// Production requires an https ExternalURL; development also allows http
+	Production bool
 }
 
 type StorageConfig struct {
-	StorageType  string
-	LocalPath    string
-	S3Endpoint   string
-	S3Bucket     string
-	S3AccessKey  string
-	S3SecretKey  string
-	S3Region     string
-	S3UseSSL     bool
-	MaxFileSize  int64
-	ChunkSize    int64
+	StorageType              string
+	FallbackType             string
+	FailoverMode             string
+	LocalPath                string
+	S3Endpoint               string
+	S3Bucket                 string
+	S3AccessKey              string
+	S3SecretKey              string
+	S3Region                 string
+	S3UseSSL                 bool
+	S3URLExpiry              time.Duration
+	MaxFileSize              int64
+	ChunkSize                int64
+	AppQuota                 int64
+	MaxPendingUploadsPerUser int
+	MaxAvatarDimension       int
+	AllowOctetStream         bool
+	OrphanGracePeriod        time.Duration
+	MediaHeaders             storage.MediaHeaders
 }
 
 // Defaults
 const (
-	defaultPort                    = "4545"
-	defaultJWTExpirationHours      = 24
-	defaultChallengeTTLSec         = 300
-	defaultRegistrationTokenTTLSec = 10
+	defaultPort                     = "4545"
+	defaultJWTExpirationHours       = 24
+	defaultRefreshTokenTTLHours     = 30 * 24
+	defaultChallengeTTLSec          = 300
+	defaultChallengeRateLimitPerMin = 5
+	defaultMaxStoredChallenges      = 10000
+	defaultLastSeenThrottleSec      = 5 * 60
+	defaultServerKeyCacheMaxAgeSec  = 24 * 60 * 60
+	defaultRegistrationTokenTTLSec  = 10
+	defaultClockSkewSec             = 5
+	defaultMaxAuthHeaderBytes       = 16 * 1024
+	defaultMaxPendingUploadsPerUser = 10
+	defaultMaxAvatarDimension       = 256
+	defaultS3URLExpirySec           = 60 * 60
+	defaultOrphanGraceHours         = 24
+	defaultMaxConcurrentRequests    = 512
 )
 
 var defaultAllowedOrigins = []string{"https://prappser.app", "http://localhost:*", "https://localhost:*"}
@@ -50,6 +99,88 @@ func getEnvOrDefault(key, defaultVal string) string {
 	return defaultVal
 }
 
+func parseEventTypes(value string) []event.EventType {
+	var eventTypes []event.EventType
+	for _, item := range parseList(value) {
+		eventTypes = append(eventTypes, event.EventType(item))
+	}
+	return eventTypes
+}
+
+func parseList(value string) []string {
+	var items []string
+	for _, part := range strings.Split(value, ",") {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			items = append(items, trimmed)
+		}
+	}
+	return items
+}
+
+// parseAppOrigins parses "appId=origin|origin;appId=origin" into origins per application,
+// skipping malformed entries
+func parseAppOrigins(value string) map[string][]string {
+	appOrigins := make(map[string][]string)
+	for _, entry := range strings.Split(value, ";") {
+		appID, origins, ok := strings.Cut(entry, "=")
+		appID = strings.TrimSpace(appID)
+		if !ok || appID == "" {
+			continue
+		}
+		for _, origin := range strings.Split(origins, "|") {
+			if trimmed := strings.TrimSpace(origin); trimmed != "" {
+				appOrigins[appID] = append(appOrigins[appID], trimmed)
+			}
+		}
+	}
+	return appOrigins
+}
+
+// parseAuthorizationRoles parses "eventType=role|role;eventType=role" into the roles allowed
+// to submit each event type, skipping unknown roles and malformed entries
+func parseAuthorizationRoles(value string) map[event.EventType][]application.MemberRole {
+	eventRoles := make(map[event.EventType][]application.MemberRole)
+	for _, entry := range strings.Split(value, ";") {
+		eventType, roles, ok := strings.Cut(entry, "=")
+		eventType = strings.TrimSpace(eventType)
+		if !ok || eventType == "" {
+			continue
+		}
+		for _, role := range strings.Split(roles, "|") {
+			if memberRole := application.MemberRole(strings.TrimSpace(role)); memberRole.IsValid() {
+				eventRoles[event.EventType(eventType)] = append(eventRoles[event.EventType(eventType)], memberRole)
+			}
+		}
+	}
+	return eventRoles
+}
+
+// requestTimeoutEnvVars maps each route class to the env var overriding its timeout
+var requestTimeoutEnvVars = map[middleware.RouteClass]string{
+	middleware.RouteClassAuth:            "REQUEST_TIMEOUT_AUTH_SEC",
+	middleware.RouteClassEvent:           "REQUEST_TIMEOUT_EVENT_SEC",
+	middleware.RouteClassStorageUpload:   "REQUEST_TIMEOUT_STORAGE_UPLOAD_SEC",
+	middleware.RouteClassStorageDownload: "REQUEST_TIMEOUT_STORAGE_DOWNLOAD_SEC",
+	middleware.RouteClassWebSocket:       "REQUEST_TIMEOUT_WEBSOCKET_SEC",
+}
+
+// parseRequestTimeouts starts from the default timeouts and applies env overrides in
+// seconds; 0 disables the timeout of a class
+func parseRequestTimeouts() map[middleware.RouteClass]time.Duration {
+	timeouts := make(map[middleware.RouteClass]time.Duration, len(middleware.DefaultRequestTimeouts))
+	for class, timeout := range middleware.DefaultRequestTimeouts {
+		timeouts[class] = timeout
+	}
+	for class, envVar := range requestTimeoutEnvVars {
+		if value := os.Getenv(envVar); value != "" {
+			if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+				timeouts[class] = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return timeouts
+}
+
 func resolveExternalURL(externalURL, hostingProvider, port string) string {
 	if externalURL == "" {
 		return fmt.Sprintf("http://localhost:%s", port)
@@ -86,6 +217,7 @@ func LoadConfig() (*Config, error) {
 	envJWTExpirationHours := os.Getenv("JWT_EXPIRATION_HOURS")
 	envChallengeTTLSec := os.Getenv("CHALLENGE_TTL_SEC")
 	envRegistrationTokenTTLSec := os.Getenv("REGISTRATION_TOKEN_TTL_SEC")
+	envMaxAuthHeaderBytes := os.Getenv("MAX_AUTH_HEADER_BYTES")
 
 	// Validate required config
 	if envMasterPassword == "" {
@@ -104,6 +236,23 @@ func LoadConfig() (*Config, error) {
 
 	// External URL
 	config.ExternalURL = resolveExternalURL(envExternalURL, envHostingProvider, config.Port)
+	switch envEnvironment := os.Getenv("ENVIRONMENT"); envEnvironment {
+	case "", "development":
+	case "production":
+		config.Production = true
+	default:
+		return nil, fmt.Errorf("ENVIRONMENT must be development or production, got %q", envEnvironment)
+	}
+	if config.Production && !strings.HasPrefix(config.ExternalURL, "https://") {
+		return nil, fmt.Errorf("EXTERNAL_URL %q: %w", config.ExternalURL, ErrInsecureExternalURL)
+	}
+
+	// Setup landing page
+	config.Landing.Path = setup.DefaultLandingPath
+	if envLandingPath := os.Getenv("LANDING_PATH"); strings.HasPrefix(envLandingPath, "/") {
+		config.Landing.Path = envLandingPath
+	}
+	config.Landing.RedirectURL = os.Getenv("LANDING_REDIRECT_URL")
 
 	// Allowed Origins
 	if envAllowedOrigins != "" {
@@ -115,6 +264,16 @@ func LoadConfig() (*Config, error) {
 	} else {
 		config.AllowedOrigins = defaultAllowedOrigins
 	}
+	config.AppAllowedOrigins = parseAppOrigins(os.Getenv("APP_ALLOWED_ORIGINS"))
+
+	config.RequestTimeouts = parseRequestTimeouts()
+
+	config.MaxConcurrentRequests = defaultMaxConcurrentRequests
+	if envMaxConcurrentRequests := os.Getenv("MAX_CONCURRENT_REQUESTS"); envMaxConcurrentRequests != "" {
+		if maxRequests, err := strconv.Atoi(envMaxConcurrentRequests); err == nil && maxRequests >= 0 {
+			config.MaxConcurrentRequests = maxRequests
+		}
+	}
 
 	// User config
 	hash := md5.Sum([]byte(envMasterPassword))
@@ -127,6 +286,13 @@ func LoadConfig() (*Config, error) {
 		}
 	}
 
+	config.Users.RefreshTokenTTLHours = defaultRefreshTokenTTLHours
+	if envRefreshTTL := os.Getenv("REFRESH_TOKEN_TTL_HOURS"); envRefreshTTL != "" {
+		if hours, err := strconv.Atoi(envRefreshTTL); err == nil && hours > 0 {
+			config.Users.RefreshTokenTTLHours = hours
+		}
+	}
+
 	config.Users.ChallengeTTLSec = defaultChallengeTTLSec
 	if envChallengeTTLSec != "" {
 		if seconds, err := strconv.Atoi(envChallengeTTLSec); err == nil {
@@ -134,6 +300,34 @@ func LoadConfig() (*Config, error) {
 		}
 	}
 
+	config.Users.ChallengeRateLimitPerMin = defaultChallengeRateLimitPerMin
+	if envRateLimit := os.Getenv("CHALLENGE_RATE_LIMIT_PER_MIN"); envRateLimit != "" {
+		if limit, err := strconv.Atoi(envRateLimit); err == nil && limit >= 0 {
+			config.Users.ChallengeRateLimitPerMin = limit
+		}
+	}
+
+	config.Users.MaxStoredChallenges = defaultMaxStoredChallenges
+	if envMaxChallenges := os.Getenv("CHALLENGE_MAX_STORED"); envMaxChallenges != "" {
+		if maxChallenges, err := strconv.Atoi(envMaxChallenges); err == nil && maxChallenges >= 0 {
+			config.Users.MaxStoredChallenges = maxChallenges
+		}
+	}
+
+	config.Users.LastSeenThrottleSec = defaultLastSeenThrottleSec
+	if envThrottle := os.Getenv("USER_LAST_SEEN_THROTTLE_SEC"); envThrottle != "" {
+		if seconds, err := strconv.Atoi(envThrottle); err == nil && seconds >= 0 {
+			config.Users.LastSeenThrottleSec = seconds
+		}
+	}
+
+	config.Users.ServerKeyCacheMaxAgeSec = defaultServerKeyCacheMaxAgeSec
+	if envKeyMaxAge := os.Getenv("SERVER_KEY_CACHE_MAX_AGE_SEC"); envKeyMaxAge != "" {
+		if seconds, err := strconv.Atoi(envKeyMaxAge); err == nil && seconds >= 0 {
+			config.Users.ServerKeyCacheMaxAgeSec = seconds
+		}
+	}
+
 	config.Users.RegistrationTokenTTLSec = defaultRegistrationTokenTTLSec
 	if envRegistrationTokenTTLSec != "" {
 		if seconds, err := strconv.Atoi(envRegistrationTokenTTLSec); err == nil {
@@ -141,8 +335,190 @@ func LoadConfig() (*Config, error) {
 		}
 	}
 
+	config.Users.ClockSkewSec = defaultClockSkewSec
+	if envClockSkew := os.Getenv("AUTH_CLOCK_SKEW_SEC"); envClockSkew != "" {
+		if seconds, err := strconv.Atoi(envClockSkew); err == nil && seconds >= 0 {
+			config.Users.ClockSkewSec = seconds
+		}
+	}
+
+	config.Users.MaxAuthHeaderBytes = defaultMaxAuthHeaderBytes
+	if envMaxAuthHeaderBytes != "" {
+		if size, err := strconv.Atoi(envMaxAuthHeaderBytes); err == nil {
+			config.Users.MaxAuthHeaderBytes = size
+		}
+	}
+
+	if envGraceDays := os.Getenv("ORPHANED_USER_GRACE_DAYS"); envGraceDays != "" {
+		if days, err := strconv.Atoi(envGraceDays); err == nil && days > 0 {
+			config.Users.OrphanedUserGraceDays = days
+		}
+	}
+
+	config.Events.ServerOnlyTypes = event.DefaultServerOnlyTypes
+	if envServerOnlyTypes, ok := os.LookupEnv("EVENT_SERVER_ONLY_TYPES"); ok {
+		config.Events.ServerOnlyTypes = parseEventTypes(envServerOnlyTypes)
+	}
+
+	config.WebSocket.BroadcastQueueSize = websocket.DefaultBroadcastQueueSize
+	if envQueueSize := os.Getenv("WS_BROADCAST_QUEUE_SIZE"); envQueueSize != "" {
+		if size, err := strconv.Atoi(envQueueSize); err == nil && size > 0 {
+			config.WebSocket.BroadcastQueueSize = size
+		}
+	}
+	if envHighWater := os.Getenv("WS_BROADCAST_QUEUE_HIGH_WATER"); envHighWater != "" {
+		if mark, err := strconv.Atoi(envHighWater); err == nil && mark > 0 {
+			config.WebSocket.QueueHighWaterMark = mark
+		}
+	}
+
+	// Polling-only applications are shared by the hub (no broadcasts) and the
+	// application responses (so clients know to poll)
+	pollingOnlyApps := application.PollingOnlyApps(parseList(os.Getenv("WS_POLLING_ONLY_APPS")))
+	config.WebSocket.PollingOnlyApps = pollingOnlyApps
+	config.Applications.PollingOnlyApps = pollingOnlyApps
+
+	config.WebSocket.AutoSubscribe = os.Getenv("WS_AUTO_SUBSCRIBE") == "true"
+
+	config.WebSocket.MaxSubscriptionsPerClient = websocket.DefaultMaxSubscriptionsPerClient
+	if envMaxSubscriptions := os.Getenv("WS_MAX_SUBSCRIPTIONS_PER_CLIENT"); envMaxSubscriptions != "" {
+		if limit, err := strconv.Atoi(envMaxSubscriptions); err == nil && limit > 0 {
+			config.WebSocket.MaxSubscriptionsPerClient = limit
+		}
+	}
+
+	config.WebSocket.SubscriptionRateLimitPerMin = websocket.DefaultSubscriptionRateLimitPerMin
+	if envRateLimit := os.Getenv("WS_SUBSCRIPTION_RATE_LIMIT_PER_MIN"); envRateLimit != "" {
+		if limit, err := strconv.Atoi(envRateLimit); err == nil && limit > 0 {
+			config.WebSocket.SubscriptionRateLimitPerMin = limit
+		}
+	}
+
+	config.Webhooks.MaxAttempts = webhook.DefaultMaxAttempts
+	if envMaxAttempts := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); envMaxAttempts != "" {
+		if attempts, err := strconv.Atoi(envMaxAttempts); err == nil && attempts > 0 {
+			config.Webhooks.MaxAttempts = attempts
+		}
+	}
+	config.Webhooks.RetryBackoff = webhook.DefaultRetryBackoff
+	config.Webhooks.Timeout = webhook.DefaultTimeout
+	if envTimeout := os.Getenv("WEBHOOK_TIMEOUT_SEC"); envTimeout != "" {
+		if seconds, err := strconv.Atoi(envTimeout); err == nil && seconds > 0 {
+			config.Webhooks.Timeout = time.Duration(seconds) * time.Second
+		}
+	}
+	config.Webhooks.Workers = webhook.DefaultWorkers
+	if envWorkers := os.Getenv("WEBHOOK_WORKERS"); envWorkers != "" {
+		if workers, err := strconv.Atoi(envWorkers); err == nil && workers > 0 {
+			config.Webhooks.Workers = workers
+		}
+	}
+	config.Webhooks.QueueSize = webhook.DefaultQueueSize
+	if envQueueSize := os.Getenv("WEBHOOK_QUEUE_SIZE"); envQueueSize != "" {
+		if size, err := strconv.Atoi(envQueueSize); err == nil && size > 0 {
+			config.Webhooks.QueueSize = size
+		}
+	}
+	config.Webhooks.SecretKey = envMasterPassword
+	config.Webhooks.AllowPrivateNetworks = os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true"
+
+	config.Events.LogRedactedFields = event.DefaultLogRedactedFields
+	if envRedactedFields, ok := os.LookupEnv("EVENT_LOG_REDACTED_FIELDS"); ok {
+		config.Events.LogRedactedFields = parseList(envRedactedFields)
+	}
+
+	config.Events.MaxComponentDataDepth = event.DefaultMaxComponentDataDepth
+	if envMaxDepth := os.Getenv("EVENT_MAX_COMPONENT_DATA_DEPTH"); envMaxDepth != "" {
+		if depth, err := strconv.Atoi(envMaxDepth); err == nil && depth > 0 {
+			config.Events.MaxComponentDataDepth = depth
+		}
+	}
+	config.Events.MaxComponentDataKeys = event.DefaultMaxComponentDataKeys
+	if envMaxKeys := os.Getenv("EVENT_MAX_COMPONENT_DATA_KEYS"); envMaxKeys != "" {
+		if keys, err := strconv.Atoi(envMaxKeys); err == nil && keys > 0 {
+			config.Events.MaxComponentDataKeys = keys
+		}
+	}
+	if envHistoryDepth := os.Getenv("EVENT_COMPONENT_HISTORY_DEPTH"); envHistoryDepth != "" {
+		if depth, err := strconv.Atoi(envHistoryDepth); err == nil && depth >= 0 {
+			config.Events.ComponentHistoryDepth = depth
+		}
+	}
+	if envMaxAppEvents := os.Getenv("EVENT_MAX_PER_APPLICATION"); envMaxAppEvents != "" {
+		if maxEvents, err := strconv.ParseInt(envMaxAppEvents, 10, 64); err == nil && maxEvents >= 0 {
+			config.Events.MaxEventsPerApplication = maxEvents
+		}
+	}
+	if envCompactionWindow := os.Getenv("EVENT_COMPACTION_WINDOW_SEC"); envCompactionWindow != "" {
+		if seconds, err := strconv.Atoi(envCompactionWindow); err == nil && seconds >= 0 {
+			config.Events.CompactionWindow = time.Duration(seconds) * time.Second
+		}
+	}
+	if envMaxAge := os.Getenv("EVENT_MAX_AGE_SEC"); envMaxAge != "" {
+		if seconds, err := strconv.Atoi(envMaxAge); err == nil && seconds >= 0 {
+			config.Events.MaxEventAge = time.Duration(seconds) * time.Second
+		}
+	}
+	config.Events.AllowEventPurge = os.Getenv("EVENT_PURGE_ENABLED") == "true"
+	config.Events.MemberUserPolicy = event.MemberUserPolicyReject
+	switch policy := event.MemberUserPolicy(os.Getenv("EVENT_MEMBER_USER_POLICY")); policy {
+	case event.MemberUserPolicyCreate, event.MemberUserPolicyReject, event.MemberUserPolicyNone:
+		config.Events.MemberUserPolicy = policy
+	}
+
+	// Role overrides replace the built-in rule of each listed event type
+	if envRoles := os.Getenv("EVENT_AUTHORIZATION_ROLES"); envRoles != "" {
+		policies := event.DefaultAuthorizationPolicies()
+		for eventType, roles := range parseAuthorizationRoles(envRoles) {
+			policies[eventType] = event.RequireRoles(roles...)
+		}
+		config.Events.AuthorizationPolicies = policies
+	}
+
+	// Owners are only assigned explicitly, never as the fallback role
+	config.Applications.DefaultMemberRole = application.MemberRoleMember
+	if role := application.MemberRole(os.Getenv("APP_DEFAULT_MEMBER_ROLE")); role.IsValid() && role != application.MemberRoleOwner {
+		config.Applications.DefaultMemberRole = role
+	}
+
+	if maxAdminsStr := os.Getenv("APP_MAX_ADMINS"); maxAdminsStr != "" {
+		if maxAdmins, err := strconv.Atoi(maxAdminsStr); err == nil {
+			config.Applications.MaxAdmins = maxAdmins
+		}
+	}
+
+	config.Applications.AppIDPolicy = application.AppIDPolicyClient
+	if policy := application.AppIDPolicy(os.Getenv("APP_ID_POLICY")); policy.IsValid() {
+		config.Applications.AppIDPolicy = policy
+	}
+
+	// Deep link scheme must be a registered custom scheme; a bad value is a startup error
+	// rather than a silent fallback so invite links never point somewhere unexpected
+	config.Invitations.DeepLinkScheme = getEnvOrDefault("INVITE_DEEP_LINK_SCHEME", invitation.DefaultDeepLinkScheme)
+	allowedSchemes := []string{invitation.DefaultDeepLinkScheme}
+	if envAllowedSchemes := os.Getenv("INVITE_ALLOWED_DEEP_LINK_SCHEMES"); envAllowedSchemes != "" {
+		allowedSchemes = strings.Split(envAllowedSchemes, ",")
+		for i := range allowedSchemes {
+			allowedSchemes[i] = strings.TrimSpace(allowedSchemes[i])
+		}
+	}
+	if err := invitation.ValidateDeepLinkScheme(config.Invitations.DeepLinkScheme, allowedSchemes); err != nil {
+		return nil, fmt.Errorf("INVITE_DEEP_LINK_SCHEME: %w", err)
+	}
+
+	config.Invitations.ShortCodeTTL = invitation.DefaultShortCodeTTL
+	if envShortCodeTTL := os.Getenv("INVITE_SHORT_CODE_TTL_HOURS"); envShortCodeTTL != "" {
+		if hours, err := strconv.Atoi(envShortCodeTTL); err == nil && hours > 0 {
+			config.Invitations.ShortCodeTTL = time.Duration(hours) * time.Hour
+		}
+	}
+
+	config.Invitations.RequireEmailApps = parseList(os.Getenv("INVITE_REQUIRE_EMAIL_APPS"))
+
 	config.Storage.StorageType = getEnvOrDefault("STORAGE_TYPE", "local")
 	config.Storage.LocalPath = getEnvOrDefault("STORAGE_PATH", "./storage")
+	config.Storage.FallbackType = os.Getenv("STORAGE_FALLBACK_TYPE")
+	config.Storage.FailoverMode = getEnvOrDefault("STORAGE_FAILOVER_MODE", string(storage.FailoverModeFallback))
 
 	config.Storage.S3Endpoint = os.Getenv("STORAGE_S3_ENDPOINT")
 	config.Storage.S3Bucket = os.Getenv("STORAGE_S3_BUCKET")
@@ -151,6 +527,13 @@ func LoadConfig() (*Config, error) {
 	config.Storage.S3Region = getEnvOrDefault("STORAGE_S3_REGION", "us-east-1")
 	config.Storage.S3UseSSL = os.Getenv("STORAGE_S3_USE_SSL") != "false"
 
+	config.Storage.S3URLExpiry = defaultS3URLExpirySec * time.Second
+	if expiryStr := os.Getenv("STORAGE_S3_URL_EXPIRY_SEC"); expiryStr != "" {
+		if seconds, err := strconv.Atoi(expiryStr); err == nil && seconds > 0 {
+			config.Storage.S3URLExpiry = time.Duration(seconds) * time.Second
+		}
+	}
+
 	maxFileSizeMBStr := os.Getenv("STORAGE_MAX_FILE_SIZE_MB")
 	if maxFileSizeMBStr != "" {
 		if sizeMB, err := strconv.ParseInt(maxFileSizeMBStr, 10, 64); err == nil {
@@ -171,5 +554,41 @@ func LoadConfig() (*Config, error) {
 		config.Storage.ChunkSize = 5 * 1024 * 1024 // 5MB default
 	}
 
+	if quotaMBStr := os.Getenv("STORAGE_APP_QUOTA_MB"); quotaMBStr != "" {
+		if quotaMB, err := strconv.ParseInt(quotaMBStr, 10, 64); err == nil && quotaMB > 0 {
+			config.Storage.AppQuota = quotaMB * 1024 * 1024
+		}
+	}
+
+	config.Storage.MaxPendingUploadsPerUser = defaultMaxPendingUploadsPerUser
+	if maxPendingStr := os.Getenv("STORAGE_MAX_PENDING_UPLOADS_PER_USER"); maxPendingStr != "" {
+		if maxPending, err := strconv.Atoi(maxPendingStr); err == nil {
+			config.Storage.MaxPendingUploadsPerUser = maxPending
+		}
+	}
+
+	config.Storage.MaxAvatarDimension = defaultMaxAvatarDimension
+	if maxAvatarStr := os.Getenv("STORAGE_AVATAR_MAX_DIMENSION"); maxAvatarStr != "" {
+		if dimension, err := strconv.Atoi(maxAvatarStr); err == nil && dimension > 0 {
+			config.Storage.MaxAvatarDimension = dimension
+		}
+	}
+
+	config.Storage.AllowOctetStream = os.Getenv("STORAGE_ALLOW_OCTET_STREAM") == "true"
+
+	config.Storage.OrphanGracePeriod = defaultOrphanGraceHours * time.Hour
+	if graceStr := os.Getenv("STORAGE_ORPHAN_GRACE_HOURS"); graceStr != "" {
+		if hours, err := strconv.Atoi(graceStr); err == nil && hours >= 0 {
+			config.Storage.OrphanGracePeriod = time.Duration(hours) * time.Hour
+		}
+	}
+
+	config.Storage.MediaHeaders.InlineContentTypes = storage.DefaultInlineContentTypes
+	if envInlineTypes, ok := os.LookupEnv("STORAGE_INLINE_CONTENT_TYPES"); ok {
+		// Non-nil even when empty, so an empty value serves every file as an attachment
+		config.Storage.MediaHeaders.InlineContentTypes = append([]string{}, parseList(envInlineTypes)...)
+	}
+	config.Storage.MediaHeaders.ContentSecurityPolicy = getEnvOrDefault("STORAGE_CONTENT_SECURITY_POLICY", storage.DefaultContentSecurityPolicy)
+
 	return config, nil
 }
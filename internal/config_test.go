@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prappser/prappser_server/internal/application"
+	"github.com/prappser/prappser_server/internal/event"
+	"github.com/prappser/prappser_server/internal/invitation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig_ShouldRejectInvalidDeepLinkScheme(t *testing.T) {
+	// given
+	t.Setenv("MASTER_PASSWORD", "test-password")
+	t.Setenv("INVITE_DEEP_LINK_SCHEME", "https")
+
+	// when
+	_, err := LoadConfig()
+
+	// then
+	assert.True(t, errors.Is(err, invitation.ErrInvalidDeepLinkScheme))
+}
+
+func TestLoadConfig_ShouldAcceptAllowedDeepLinkScheme(t *testing.T) {
+	// given
+	t.Setenv("MASTER_PASSWORD", "test-password")
+	t.Setenv("INVITE_DEEP_LINK_SCHEME", "acme-app")
+	t.Setenv("INVITE_ALLOWED_DEEP_LINK_SCHEMES", "prappser, acme-app")
+
+	// when
+	config, err := LoadConfig()
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "acme-app", config.Invitations.DeepLinkScheme)
+}
+
+func TestLoadConfig_ShouldRejectHTTPExternalURLInProduction(t *testing.T) {
+	// given
+	t.Setenv("MASTER_PASSWORD", "test-password")
+	t.Setenv("ENVIRONMENT", "production")
+	t.Setenv("EXTERNAL_URL", "http://prappser.example.com")
+
+	// when
+	_, err := LoadConfig()
+
+	// then
+	assert.True(t, errors.Is(err, ErrInsecureExternalURL))
+}
+
+func TestLoadConfig_ShouldRejectLocalhostFallbackInProduction(t *testing.T) {
+	// given
+	t.Setenv("MASTER_PASSWORD", "test-password")
+	t.Setenv("ENVIRONMENT", "production")
+	t.Setenv("EXTERNAL_URL", "")
+
+	// when
+	_, err := LoadConfig()
+
+	// then
+	assert.True(t, errors.Is(err, ErrInsecureExternalURL))
+}
+
+func TestLoadConfig_ShouldAllowHTTPExternalURLInDevelopment(t *testing.T) {
+	// given
+	t.Setenv("MASTER_PASSWORD", "test-password")
+	t.Setenv("EXTERNAL_URL", "http://localhost:4545")
+
+	// when
+	config, err := LoadConfig()
+
+	// then
+	assert.NoError(t, err)
+	assert.False(t, config.Production)
+	assert.Equal(t, "http://localhost:4545", config.ExternalURL)
+}
+
+func TestParseAppOrigins_ShouldGroupOriginsByApplication(t *testing.T) {
+	// when
+	appOrigins := parseAppOrigins("app-1=https://a.example| https://b.example ; app-2=https://c.example;malformed")
+
+	// then
+	assert.Equal(t, map[string][]string{
+		"app-1": {"https://a.example", "https://b.example"},
+		"app-2": {"https://c.example"},
+	}, appOrigins)
+}
+
+func TestParseAuthorizationRoles_ShouldGroupValidRolesByEventType(t *testing.T) {
+	// when
+	eventRoles := parseAuthorizationRoles("application_deleted=owner| admin ; invite_revoked=superuser|admin;malformed")
+
+	// then
+	assert.Equal(t, map[event.EventType][]application.MemberRole{
+		event.EventTypeApplicationDeleted: {application.MemberRoleOwner, application.MemberRoleAdmin},
+		event.EventTypeInviteRevoked:      {application.MemberRoleAdmin},
+	}, eventRoles)
+}
@@ -2,6 +2,9 @@ package event
 
 import (
 	"encoding/json"
+	"time"
+
+	"github.com/prappser/prappser_server/internal/clock"
 )
 
 // EventType represents the type of event
@@ -21,6 +24,65 @@ const (
 	EventTypeApplicationCreated             EventType = "application_created"
 	EventTypeApplicationFileCreated         EventType = "application_file_created"
 	EventTypeApplicationFileDeleted         EventType = "application_file_deleted"
+	EventTypeMemberRekeyed                  EventType = "member_rekeyed"
+)
+
+// DefaultServerOnlyTypes are event types only the server may produce. Clients submitting
+// them over POST /events are rejected so they cannot forge server-side facts.
+var DefaultServerOnlyTypes = []EventType{
+	EventTypeApplicationCreated,
+	EventTypeInviteRevoked,
+	EventTypeApplicationFileCreated,
+	EventTypeApplicationFileDeleted,
+	EventTypeMemberRekeyed,
+}
+
+// Config holds event submission and logging settings
+type Config struct {
+	ServerOnlyTypes   []EventType
+	LogRedactedFields []string
+	// MaxComponentDataDepth and MaxComponentDataKeys bound the shape of component data
+	// submitted by clients; zero selects the defaults
+	MaxComponentDataDepth int
+	MaxComponentDataKeys  int
+	// MaxEventsPerApplication caps the events stored per application; zero means unlimited
+	MaxEventsPerApplication int64
+	// ComponentHistoryDepth is how many earlier data versions are kept per component;
+	// zero disables component data history
+	ComponentHistoryDepth int
+	// MemberUserPolicy decides what happens when a member_added event names a public key
+	// that has no user account; empty leaves it unchecked
+	MemberUserPolicy MemberUserPolicy
+	// CompactionWindow collapses a component_data_changed event into the previous event of
+	// the application when that event changed the same component fields, came from the same
+	// creator and is younger than the window. The stored event is replaced under the new
+	// event's ID, so poll cursors still pointing at it start over. Zero disables compaction.
+	CompactionWindow time.Duration
+	// AuthorizationPolicies decides who may submit which application event types; nil
+	// selects DefaultAuthorizationPolicies
+	AuthorizationPolicies AuthorizationPolicies
+	// MaxEventAge rejects client events whose createdAt, the time the client made the
+	// change, is older than this, so clients long offline resync before their edits are
+	// applied. Events without a createdAt are not checked. Zero disables the check.
+	MaxEventAge time.Duration
+	// AllowEventPurge lets owners delete all events of an application through
+	// PurgeApplicationEvents; disabled by default
+	AllowEventPurge bool
+	// Clock tells the current time; nil selects clock.System
+	Clock clock.Clock
+}
+
+// MemberUserPolicy is how member_added handles a member without a user account, who
+// could otherwise never authenticate
+type MemberUserPolicy string
+
+const (
+	// MemberUserPolicyCreate creates a minimal member user for the public key
+	MemberUserPolicyCreate MemberUserPolicy = "create"
+	// MemberUserPolicyReject rejects the event
+	MemberUserPolicyReject MemberUserPolicy = "reject"
+	// MemberUserPolicyNone adds the member without checking for a user
+	MemberUserPolicyNone MemberUserPolicy = "none"
 )
 
 // IsUserScoped returns true for event types that are user-scoped (no applicationId)
@@ -160,6 +222,15 @@ type MemberDetailsChangedData struct {
 	MemberPublicKey string `json:"memberPublicKey"`
 }
 
+// MemberRekeyedData represents the data for a member_rekeyed event, produced when the
+// owner moves a member to a new public key
+type MemberRekeyedData struct {
+	Version         int    `json:"version"`
+	ApplicationID   string `json:"applicationId"`
+	MemberPublicKey string `json:"memberPublicKey"`
+	NewPublicKey    string `json:"newPublicKey"`
+}
+
 // AppVersion holds the last known sequence number for an application.
 // Clients use this to detect local state drift and trigger a full resync if needed.
 type AppVersion struct {
@@ -175,6 +246,94 @@ type EventsResponse struct {
 	AppVersions        map[string]AppVersion `json:"appVersions,omitempty"`
 }
 
+// StateVersion identifies an application's state after an event was applied.
+// Clients compare it with their local state to confirm they converged with the server.
+type StateVersion struct {
+	LastSequence int64 `json:"lastSequence"`
+	UpdatedAt    int64 `json:"updatedAt"`
+}
+
+// AppSyncState is the latest sequence and update time of one application, used by
+// clients polling for changes without holding a WebSocket open
+type AppSyncState struct {
+	ApplicationID string `json:"applicationId"`
+	LastSequence  int64  `json:"lastSequence"`
+	UpdatedAt     int64  `json:"updatedAt"`
+}
+
+// SyncStateResponse represents the response for GET /sync/state
+type SyncStateResponse struct {
+	Applications []*AppSyncState `json:"applications"`
+}
+
+// AppSyncEstimate is the number and approximate serialized size of the events a client
+// would fetch to bring one application up to date
+type AppSyncEstimate struct {
+	ApplicationID string `json:"applicationId"`
+	EventCount    int64  `json:"eventCount"`
+	ApproxBytes   int64  `json:"approxBytes"`
+}
+
+// SyncEstimateResponse represents the response for GET /sync/estimate.
+// FullResyncRequired is set when the since cursor can no longer be resumed; the
+// estimate then covers every stored event.
+type SyncEstimateResponse struct {
+	Applications       []*AppSyncEstimate `json:"applications"`
+	TotalEvents        int64              `json:"totalEvents"`
+	TotalBytes         int64              `json:"totalBytes"`
+	FullResyncRequired bool               `json:"fullResyncRequired,omitempty"`
+}
+
+// ChangeCreator identifies who submitted a change. Name and Role are empty when the
+// creator is no longer a member of the application.
+type ChangeCreator struct {
+	PublicKey string `json:"publicKey"`
+	Name      string `json:"name,omitempty"`
+	Role      string `json:"role,omitempty"`
+}
+
+// LastChangeResponse represents the response for
+// GET /applications/{id}/components/{componentId}/last-change
+type LastChangeResponse struct {
+	Event   *Event         `json:"event"`
+	Creator *ChangeCreator `json:"creator"`
+}
+
+// EventSizeInfo describes the serialized data size of a single stored event
+type EventSizeInfo struct {
+	ID             string    `json:"id"`
+	ApplicationID  string    `json:"applicationId,omitempty"`
+	SequenceNumber int64     `json:"sequenceNumber,omitempty"`
+	Type           EventType `json:"type"`
+	CreatedAt      int64     `json:"createdAt"`
+	DataSize       int64     `json:"dataSize"`
+}
+
+// EventTypeSizeStats aggregates serialized data sizes for one event type
+type EventTypeSizeStats struct {
+	Type       EventType `json:"type"`
+	Count      int64     `json:"count"`
+	TotalBytes int64     `json:"totalBytes"`
+	AvgBytes   int64     `json:"avgBytes"`
+	MaxBytes   int64     `json:"maxBytes"`
+}
+
+// ApplicationEventCount is the number of events stored for one application
+type ApplicationEventCount struct {
+	ApplicationID string `json:"applicationId"`
+	Count         int64  `json:"count"`
+}
+
+// DataSizeReport is the response for the owner-only event data size report
+type DataSizeReport struct {
+	LargestEvents []*EventSizeInfo      `json:"largestEvents"`
+	ByType        []*EventTypeSizeStats `json:"byType"`
+	// ByApplication lists the applications with the most stored events, most first
+	ByApplication []*ApplicationEventCount `json:"byApplication"`
+	// MaxEventsPerApplication is the configured per-application cap; zero means unlimited
+	MaxEventsPerApplication int64 `json:"maxEventsPerApplication"`
+}
+
 // NewEvent creates a new event with the given parameters
 func NewEvent(id string, eventType EventType, creatorPublicKey string, data map[string]interface{}) *Event {
 	return &Event{
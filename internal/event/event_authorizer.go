@@ -12,23 +12,108 @@ var (
 	ErrUnauthorized = errors.New("unauthorized")
 )
 
-// AuthorizeEvent checks if the submitter has permission to submit the given event for the application.
-//
-// Authorization Rules by Event Type:
+// AuthorizationPolicy decides whether a member may submit an event of the type it is
+// registered for. It returns an error wrapping ErrUnauthorized to reject the event.
+type AuthorizationPolicy func(event *Event, submitter *user.User, member *application.Member) error
+
+// AuthorizationPolicies holds the policy of every event type clients may submit to an
+// application. Event types without a policy are rejected.
+type AuthorizationPolicies map[EventType]AuthorizationPolicy
+
+// defaultAuthorizationPolicies backs AuthorizeEvent and services configured without policies
+var defaultAuthorizationPolicies = DefaultAuthorizationPolicies()
+
+// DefaultAuthorizationPolicies returns the built-in rules by event type:
 //   - application_deleted: Only the application owner can delete the entire application
 //   - member_removed: Members can remove themselves; owners can remove any member
 //   - member_added: Any member can add others (authorization handled by invitation system)
 //   - member_role_changed: Only owners can change member roles
 //   - application_data_changed: Any member can update application data
 //   - invite_revoked: Only owners can revoke invitations
+//   - component_data_changed, application_after_edit_mode_changed: Any member
+//   - member_details_changed: Members can only update their own details
+//   - application_file_created, application_file_deleted: Server-produced only
+//
+// Each call returns a new map, so deployments can override entries without affecting others.
+func DefaultAuthorizationPolicies() AuthorizationPolicies {
+	return AuthorizationPolicies{
+		EventTypeApplicationDeleted: requireOwner("only owner can delete application"),
+		EventTypeMemberRemoved: func(event *Event, submitter *user.User, member *application.Member) error {
+			memberKey, ok := event.Data["memberPublicKey"].(string)
+			if !ok {
+				return fmt.Errorf("%w: memberPublicKey not found in event data", ErrUnauthorized)
+			}
+			if memberKey != submitter.PublicKey && member.Role != application.MemberRoleOwner {
+				return fmt.Errorf("%w: can only remove self unless owner", ErrUnauthorized)
+			}
+			return nil
+		},
+		// Authorization is implicitly granted by the invite system
+		EventTypeMemberAdded:                     AllowAnyMember,
+		EventTypeMemberRoleChanged:               requireOwner("only owner can change member roles"),
+		EventTypeApplicationDataChanged:          AllowAnyMember,
+		EventTypeInviteRevoked:                   requireOwner("only owner can revoke invites"),
+		EventTypeComponentDataChanged:            AllowAnyMember,
+		EventTypeApplicationAfterEditModeChanged: AllowAnyMember,
+		EventTypeMemberDetailsChanged: func(event *Event, submitter *user.User, member *application.Member) error {
+			memberKey, ok := event.Data["memberPublicKey"].(string)
+			if !ok || memberKey != submitter.PublicKey {
+				return fmt.Errorf("%w: can only update own member details", ErrUnauthorized)
+			}
+			return nil
+		},
+		EventTypeApplicationFileCreated: rejectServerProduced,
+		EventTypeApplicationFileDeleted: rejectServerProduced,
+		EventTypeMemberRekeyed:          rejectServerProduced,
+	}
+}
+
+// AllowAnyMember lets every member of the application submit the event
+func AllowAnyMember(event *Event, submitter *user.User, member *application.Member) error {
+	return nil
+}
+
+// RequireRoles only lets members with one of the given roles submit the event
+func RequireRoles(roles ...application.MemberRole) AuthorizationPolicy {
+	return func(event *Event, submitter *user.User, member *application.Member) error {
+		for _, role := range roles {
+			if member.Role == role {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s requires role %v, member is %s", ErrUnauthorized, event.Type, roles, member.Role)
+	}
+}
+
+func requireOwner(reason string) AuthorizationPolicy {
+	return func(event *Event, submitter *user.User, member *application.Member) error {
+		if member.Role != application.MemberRoleOwner {
+			return fmt.Errorf("%w: %s", ErrUnauthorized, reason)
+		}
+		return nil
+	}
+}
+
+func rejectServerProduced(event *Event, submitter *user.User, member *application.Member) error {
+	return fmt.Errorf("%w: file events are server-produced and cannot be submitted by clients", ErrUnauthorized)
+}
+
+// AuthorizeEvent checks the submitter's permission with the default policies.
+func AuthorizeEvent(event *Event, submitter *user.User, app *application.Application) error {
+	return defaultAuthorizationPolicies.Authorize(event, submitter, app)
+}
+
+// Authorize checks if the submitter has permission to submit the given event for the application.
 //
 // Returns ErrUnauthorized if:
 //   - Submitter is nil
 //   - Application is nil
 //   - Submitter is not a member of the application
-//   - Submitter lacks required role for the event type
-//   - Event type is unknown
-func AuthorizeEvent(event *Event, submitter *user.User, app *application.Application) error {
+//   - The policy of the event type rejects the submitter
+//   - Event type has no policy
+//
+// Returns application.ErrLastOwner if the event would remove or demote the application's only owner.
+func (p AuthorizationPolicies) Authorize(event *Event, submitter *user.User, app *application.Application) error {
 	if submitter == nil {
 		return fmt.Errorf("%w: submitter is required", ErrUnauthorized)
 	}
@@ -41,61 +126,42 @@ func AuthorizeEvent(event *Event, submitter *user.User, app *application.Applica
 		return fmt.Errorf("%w: user is not a member of this application", ErrUnauthorized)
 	}
 
-	isOwner := member.Role == application.MemberRoleOwner
-
-	switch event.Type {
-	case EventTypeApplicationDeleted:
-		if !isOwner {
-			return fmt.Errorf("%w: only owner can delete application", ErrUnauthorized)
-		}
-
-	case EventTypeMemberRemoved:
-		memberKey, ok := event.Data["memberPublicKey"].(string)
-		if !ok {
-			return fmt.Errorf("%w: memberPublicKey not found in event data", ErrUnauthorized)
-		}
-
-		if memberKey != submitter.PublicKey && !isOwner {
-			return fmt.Errorf("%w: can only remove self unless owner", ErrUnauthorized)
-		}
-
-	case EventTypeMemberAdded:
-		// Any member can add other members (via invite)
-		// Authorization is implicitly granted by the invite system
-
-	case EventTypeMemberRoleChanged:
-		if !isOwner {
-			return fmt.Errorf("%w: only owner can change member roles", ErrUnauthorized)
-		}
+	policy, ok := p[event.Type]
+	if !ok {
+		return fmt.Errorf("%w: unknown event type: %s", ErrUnauthorized, event.Type)
+	}
+	if err := policy(event, submitter, member); err != nil {
+		return err
+	}
+	return checkOwnerRemains(event, app.Members)
+}
 
-	case EventTypeApplicationDataChanged:
-		// Any member can update application data
+// checkOwnerRemains returns application.ErrLastOwner when a member_removed or member_role_changed
+// event targets the only owner among members. Other event types always pass.
+func checkOwnerRemains(event *Event, members []application.Member) error {
+	if event.Type != EventTypeMemberRemoved && event.Type != EventTypeMemberRoleChanged {
+		return nil
+	}
+	newRole, _ := event.Data["newRole"].(string)
+	if event.Type == EventTypeMemberRoleChanged && application.MemberRole(newRole) == application.MemberRoleOwner {
+		return nil
+	}
 
-	case EventTypeInviteRevoked:
-		if !isOwner {
-			return fmt.Errorf("%w: only owner can revoke invites", ErrUnauthorized)
+	memberKey, _ := event.Data["memberPublicKey"].(string)
+	targetIsOwner := false
+	owners := 0
+	for i := range members {
+		if members[i].Role != application.MemberRoleOwner {
+			continue
 		}
-
-	case EventTypeComponentDataChanged:
-		// Any member can update component data
-
-	case EventTypeApplicationAfterEditModeChanged:
-		// Any member can modify application structure
-
-	case EventTypeMemberDetailsChanged:
-		// Members can only update their own details within an application
-		memberKey, ok := event.Data["memberPublicKey"].(string)
-		if !ok || memberKey != submitter.PublicKey {
-			return fmt.Errorf("%w: can only update own member details", ErrUnauthorized)
+		owners++
+		if members[i].PublicKey == memberKey {
+			targetIsOwner = true
 		}
-
-	case EventTypeApplicationFileCreated, EventTypeApplicationFileDeleted:
-		return fmt.Errorf("%w: file events are server-produced and cannot be submitted by clients", ErrUnauthorized)
-
-	default:
-		return fmt.Errorf("%w: unknown event type: %s", ErrUnauthorized, event.Type)
 	}
-
+	if targetIsOwner && owners <= 1 {
+		return fmt.Errorf("%w: %w", ErrValidation, application.ErrLastOwner)
+	}
 	return nil
 }
 
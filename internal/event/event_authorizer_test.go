@@ -0,0 +1,119 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/prappser/prappser_server/internal/application"
+	"github.com/prappser/prappser_server/internal/user"
+	"github.com/stretchr/testify/assert"
+)
+
+func createAuthorizationTestApp() *application.Application {
+	return &application.Application{
+		ID: "app-1",
+		Members: []application.Member{
+			{PublicKey: "owner-key", Role: application.MemberRoleOwner},
+			{PublicKey: "admin-key", Role: application.MemberRoleAdmin},
+			{PublicKey: "member-key", Role: application.MemberRoleMember},
+		},
+	}
+}
+
+func TestAuthorizeEvent_ShouldOnlyLetOwnerDeleteApplicationByDefault(t *testing.T) {
+	// given
+	app := createAuthorizationTestApp()
+	deletion := &Event{Type: EventTypeApplicationDeleted}
+
+	// when
+	ownerErr := AuthorizeEvent(deletion, &user.User{PublicKey: "owner-key"}, app)
+	adminErr := AuthorizeEvent(deletion, &user.User{PublicKey: "admin-key"}, app)
+
+	// then
+	assert.NoError(t, ownerErr)
+	assert.True(t, errors.Is(adminErr, ErrUnauthorized))
+}
+
+func TestAuthorizationPolicies_ShouldEnforceRegisteredRoleOverride(t *testing.T) {
+	// given - admins may delete applications, members still may not
+	policies := DefaultAuthorizationPolicies()
+	policies[EventTypeApplicationDeleted] = RequireRoles(application.MemberRoleOwner, application.MemberRoleAdmin)
+	app := createAuthorizationTestApp()
+	deletion := &Event{Type: EventTypeApplicationDeleted}
+
+	// when
+	adminErr := policies.Authorize(deletion, &user.User{PublicKey: "admin-key"}, app)
+	memberErr := policies.Authorize(deletion, &user.User{PublicKey: "member-key"}, app)
+
+	// then
+	assert.NoError(t, adminErr)
+	assert.True(t, errors.Is(memberErr, ErrUnauthorized))
+	assert.Error(t, AuthorizeEvent(deletion, &user.User{PublicKey: "admin-key"}, app), "defaults must be unaffected")
+}
+
+func TestAuthorizationPolicies_ShouldEnforceCustomPolicy(t *testing.T) {
+	// given - component data may only be changed while not marked as locked
+	policies := DefaultAuthorizationPolicies()
+	policies[EventTypeComponentDataChanged] = func(event *Event, submitter *user.User, member *application.Member) error {
+		if locked, _ := event.Data["locked"].(bool); locked {
+			return fmt.Errorf("%w: component is locked", ErrUnauthorized)
+		}
+		return nil
+	}
+	app := createAuthorizationTestApp()
+	submitter := &user.User{PublicKey: "member-key"}
+
+	// when
+	lockedErr := policies.Authorize(&Event{Type: EventTypeComponentDataChanged, Data: map[string]interface{}{"locked": true}}, submitter, app)
+	unlockedErr := policies.Authorize(&Event{Type: EventTypeComponentDataChanged, Data: map[string]interface{}{}}, submitter, app)
+
+	// then
+	assert.True(t, errors.Is(lockedErr, ErrUnauthorized))
+	assert.NoError(t, unlockedErr)
+}
+
+func TestAuthorizationPolicies_ShouldRejectEventTypeWithoutPolicy(t *testing.T) {
+	// given
+	policies := DefaultAuthorizationPolicies()
+	delete(policies, EventTypeApplicationDataChanged)
+
+	// when
+	err := policies.Authorize(&Event{Type: EventTypeApplicationDataChanged}, &user.User{PublicKey: "owner-key"}, createAuthorizationTestApp())
+
+	// then
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+	assert.Contains(t, err.Error(), "unknown event type")
+}
+
+func TestAuthorizeEvent_ShouldRejectRemovingOrDemotingLastOwner(t *testing.T) {
+	// given
+	app := createAuthorizationTestApp()
+	owner := &user.User{PublicKey: "owner-key"}
+	removal := &Event{Type: EventTypeMemberRemoved, Data: map[string]interface{}{"memberPublicKey": "owner-key"}}
+	demotion := &Event{Type: EventTypeMemberRoleChanged, Data: map[string]interface{}{"memberPublicKey": "owner-key", "newRole": "admin"}}
+	memberRemoval := &Event{Type: EventTypeMemberRemoved, Data: map[string]interface{}{"memberPublicKey": "member-key"}}
+
+	// when
+	removalErr := AuthorizeEvent(removal, owner, app)
+	demotionErr := AuthorizeEvent(demotion, owner, app)
+	memberRemovalErr := AuthorizeEvent(memberRemoval, owner, app)
+
+	// then
+	assert.ErrorIs(t, removalErr, application.ErrLastOwner)
+	assert.ErrorIs(t, demotionErr, application.ErrLastOwner)
+	assert.NoError(t, memberRemovalErr)
+}
+
+func TestAuthorizeEvent_ShouldAllowDemotingOwnerWhenAnotherOwnerRemains(t *testing.T) {
+	// given
+	app := createAuthorizationTestApp()
+	app.Members[1].Role = application.MemberRoleOwner
+	demotion := &Event{Type: EventTypeMemberRoleChanged, Data: map[string]interface{}{"memberPublicKey": "owner-key", "newRole": "member"}}
+
+	// when
+	err := AuthorizeEvent(demotion, &user.User{PublicKey: "admin-key"}, app)
+
+	// then
+	assert.NoError(t, err)
+}
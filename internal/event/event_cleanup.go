@@ -1,6 +1,8 @@
 package event
 
 import (
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -10,8 +12,10 @@ import (
 type CleanupScheduler struct {
 	eventService  *EventService
 	retentionDays int
-	ticker        *time.Ticker
-	done          chan bool
+	done          chan struct{}
+	stopped       chan struct{}
+	stopOnce      sync.Once
+	started       atomic.Bool
 }
 
 // NewCleanupScheduler creates a new cleanup scheduler
@@ -23,7 +27,8 @@ func NewCleanupScheduler(eventService *EventService, retentionDays int) *Cleanup
 	return &CleanupScheduler{
 		eventService:  eventService,
 		retentionDays: retentionDays,
-		done:          make(chan bool),
+		done:          make(chan struct{}),
+		stopped:       make(chan struct{}),
 	}
 }
 
@@ -43,24 +48,30 @@ func (cs *CleanupScheduler) Start() {
 		Str("nextRun", nextRun.Format("2006-01-02 15:04:05")).
 		Msg("Event cleanup scheduler started")
 
-	// Start timer for first run
-	time.AfterFunc(durationUntilFirstRun, func() {
-		cs.runCleanup()
-
-		// After first run, run every 24 hours
-		cs.ticker = time.NewTicker(24 * time.Hour)
-		go cs.loop()
-	})
+	cs.started.Store(true)
+	go cs.loop(durationUntilFirstRun)
 }
 
-// loop runs the cleanup task on a schedule
-func (cs *CleanupScheduler) loop() {
+// loop waits for the first run, then runs the cleanup task every 24 hours until stopped
+func (cs *CleanupScheduler) loop(durationUntilFirstRun time.Duration) {
+	defer close(cs.stopped)
+
+	timer := time.NewTimer(durationUntilFirstRun)
+	select {
+	case <-timer.C:
+	case <-cs.done:
+		timer.Stop()
+		return
+	}
+	cs.runCleanup()
+
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
 	for {
 		select {
-		case <-cs.ticker.C:
+		case <-ticker.C:
 			cs.runCleanup()
 		case <-cs.done:
-			cs.ticker.Stop()
 			return
 		}
 	}
@@ -85,11 +96,13 @@ func (cs *CleanupScheduler) runCleanup() {
 		Msg("Event cleanup completed successfully")
 }
 
-// Stop stops the cleanup scheduler
+// Stop stops the cleanup scheduler and waits for its goroutine to exit. It is safe to
+// call more than once and before Start.
 func (cs *CleanupScheduler) Stop() {
 	log.Info().Msg("Stopping event cleanup scheduler")
-	if cs.ticker != nil {
-		cs.done <- true
+	cs.stopOnce.Do(func() { close(cs.done) })
+	if cs.started.Load() {
+		<-cs.stopped
 	}
 }
 
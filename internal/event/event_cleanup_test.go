@@ -0,0 +1,38 @@
+package event
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCleanupScheduler_StopShouldEndSchedulerGoroutine(t *testing.T) {
+	// given
+	scheduler := NewCleanupScheduler(nil, 7)
+	scheduler.Start()
+
+	// when
+	scheduler.Stop()
+
+	// then
+	select {
+	case <-scheduler.stopped:
+	case <-time.After(time.Second):
+		t.Fatal("cleanup scheduler goroutine did not exit after Stop")
+	}
+}
+
+func TestCleanupScheduler_StopShouldBeSafeBeforeStartAndWhenRepeated(t *testing.T) {
+	// given
+	scheduler := NewCleanupScheduler(nil, 7)
+
+	// when
+	scheduler.Stop()
+	scheduler.Stop()
+
+	// then
+	select {
+	case <-scheduler.done:
+	default:
+		t.Fatal("expected the scheduler to be marked as stopped")
+	}
+}
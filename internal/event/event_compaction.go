@@ -0,0 +1,128 @@
+package event
+
+import (
+	"errors"
+
+	"github.com/rs/zerolog/log"
+)
+
+// compactionTarget returns the latest event of the application when event merely continues
+// it, so the two can be stored as one. Returns nil when compaction is disabled or the event
+// must be appended.
+func (s *EventService) compactionTarget(event *Event) *Event {
+	if s.compactionWindow <= 0 || event.Type != EventTypeComponentDataChanged {
+		return nil
+	}
+
+	latest, err := s.repo.GetLatestByApplicationID(event.ApplicationID)
+	if err != nil {
+		if !errors.Is(err, ErrEventNotFound) {
+			log.Error().
+				Str("applicationId", event.ApplicationID).
+				Err(err).
+				Msg("[EVENT] Failed to load latest event for compaction")
+		}
+		return nil
+	}
+
+	// A retried submission of the latest event is a duplicate, not a continuation
+	if latest.ID == event.ID {
+		return nil
+	}
+
+	notBefore := s.clock.Now().Add(-s.compactionWindow).Unix()
+	if !canCompact(latest, event, notBefore) {
+		return nil
+	}
+	return latest
+}
+
+// persistCompacted stores the event, replacing previous with the merged change when
+// compacting. If previous was already replaced by a concurrent change, the event is
+// appended instead.
+func (s *EventService) persistCompacted(event, previous *Event) error {
+	if previous != nil {
+		data := event.Data
+		event.Data = compactComponentChanges(previous, event)
+		err := s.repo.Replace(previous.ID, event)
+		if err == nil {
+			log.Debug().
+				Str("eventId", event.ID).
+				Str("replacedEventId", previous.ID).
+				Msg("[EVENT] Compacted into previous component change")
+			return nil
+		}
+		if !errors.Is(err, ErrEventNotFound) {
+			return err
+		}
+		event.Data = data
+	}
+	return s.repo.Create(event)
+}
+
+// canCompact reports whether next is a component_data_changed event from the creator of
+// previous that changes exactly the same fields of the same component, and previous was
+// created at or after notBefore
+func canCompact(previous, next *Event, notBefore int64) bool {
+	if previous.Type != EventTypeComponentDataChanged || next.Type != EventTypeComponentDataChanged {
+		return false
+	}
+	if previous.CreatorPublicKey != next.CreatorPublicKey || previous.CreatedAt < notBefore {
+		return false
+	}
+
+	previousComponent, _ := previous.Data["componentId"].(string)
+	nextComponent, _ := next.Data["componentId"].(string)
+	if previousComponent == "" || previousComponent != nextComponent {
+		return false
+	}
+
+	previousFields, ok := previous.Data["changedFields"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	nextFields, ok := next.Data["changedFields"].(map[string]interface{})
+	if !ok || len(previousFields) != len(nextFields) {
+		return false
+	}
+	for field := range nextFields {
+		if _, ok := previousFields[field]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compactComponentChanges returns the data of next with each field change starting from
+// the oldValue recorded in previous, so the merged event spans both changes and ends at
+// next's newValue
+func compactComponentChanges(previous, next *Event) map[string]interface{} {
+	previousFields, _ := previous.Data["changedFields"].(map[string]interface{})
+	nextFields, _ := next.Data["changedFields"].(map[string]interface{})
+
+	mergedFields := make(map[string]interface{}, len(nextFields))
+	for field, changeRaw := range nextFields {
+		change, ok := changeRaw.(map[string]interface{})
+		if !ok {
+			mergedFields[field] = changeRaw
+			continue
+		}
+		merged := make(map[string]interface{}, len(change))
+		for key, value := range change {
+			merged[key] = value
+		}
+		if previousChange, ok := previousFields[field].(map[string]interface{}); ok {
+			if oldValue, exists := previousChange["oldValue"]; exists {
+				merged["oldValue"] = oldValue
+			}
+		}
+		mergedFields[field] = merged
+	}
+
+	data := make(map[string]interface{}, len(next.Data))
+	for key, value := range next.Data {
+		data[key] = value
+	}
+	data["changedFields"] = mergedFields
+	return data
+}
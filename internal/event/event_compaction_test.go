@@ -0,0 +1,73 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestComponentChange(id, creator string, createdAt int64, oldValue, newValue interface{}) *Event {
+	return &Event{
+		ID:               id,
+		Type:             EventTypeComponentDataChanged,
+		CreatorPublicKey: creator,
+		CreatedAt:        createdAt,
+		Data: map[string]interface{}{
+			"applicationId": "app-1",
+			"componentId":   "slider-1",
+			"changedFields": map[string]interface{}{
+				"value": map[string]interface{}{"oldValue": oldValue, "newValue": newValue},
+			},
+			"version": 1,
+		},
+	}
+}
+
+func TestCanCompact_ShouldAcceptConsecutiveChangeOfSameFieldBySameCreator(t *testing.T) {
+	// given
+	previous := createTestComponentChange("event-1", "creator", 100, 1.0, 2.0)
+	next := createTestComponentChange("event-2", "creator", 0, 2.0, 3.0)
+
+	// then
+	assert.True(t, canCompact(previous, next, 99))
+}
+
+func TestCanCompact_ShouldRejectChangesThatCannotBeCollapsed(t *testing.T) {
+	// given
+	previous := createTestComponentChange("event-1", "creator", 100, 1.0, 2.0)
+	otherCreator := createTestComponentChange("event-2", "other-creator", 0, 2.0, 3.0)
+	otherComponent := createTestComponentChange("event-3", "creator", 0, 2.0, 3.0)
+	otherComponent.Data["componentId"] = "slider-2"
+	otherField := createTestComponentChange("event-4", "creator", 0, 2.0, 3.0)
+	otherField.Data["changedFields"] = map[string]interface{}{
+		"label": map[string]interface{}{"oldValue": "a", "newValue": "b"},
+	}
+	extraField := createTestComponentChange("event-5", "creator", 0, 2.0, 3.0)
+	extraField.Data["changedFields"].(map[string]interface{})["label"] = map[string]interface{}{"oldValue": "a", "newValue": "b"}
+	sameChange := createTestComponentChange("event-6", "creator", 0, 2.0, 3.0)
+
+	// then
+	assert.False(t, canCompact(previous, otherCreator, 99))
+	assert.False(t, canCompact(previous, otherComponent, 99))
+	assert.False(t, canCompact(previous, otherField, 99))
+	assert.False(t, canCompact(previous, extraField, 99))
+	assert.False(t, canCompact(previous, sameChange, 101), "previous change is outside the window")
+}
+
+func TestCompactComponentChanges_ShouldSpanFromFirstOldValueToLatestNewValue(t *testing.T) {
+	// given
+	first := createTestComponentChange("event-1", "creator", 100, 1.0, 2.0)
+	second := createTestComponentChange("event-2", "creator", 100, 2.0, 3.0)
+	third := createTestComponentChange("event-3", "creator", 100, 3.0, 4.0)
+
+	// when
+	second.Data = compactComponentChanges(first, second)
+	merged := compactComponentChanges(second, third)
+
+	// then
+	change := merged["changedFields"].(map[string]interface{})["value"].(map[string]interface{})
+	assert.Equal(t, 1.0, change["oldValue"])
+	assert.Equal(t, 4.0, change["newValue"])
+	assert.Equal(t, "slider-1", merged["componentId"])
+	assert.Equal(t, 3.0, third.Data["changedFields"].(map[string]interface{})["value"].(map[string]interface{})["oldValue"], "next event data is left untouched")
+}
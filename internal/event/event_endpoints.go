@@ -1,9 +1,11 @@
 package event
 
 import (
-	"strconv"
+	"errors"
 
 	"github.com/goccy/go-json"
+	"github.com/prappser/prappser_server/internal/application"
+	"github.com/prappser/prappser_server/internal/middleware"
 	"github.com/prappser/prappser_server/internal/user"
 	"github.com/rs/zerolog/log"
 	"github.com/valyala/fasthttp"
@@ -35,17 +37,7 @@ func (ee *EventEndpoints) GetEvents(ctx *fasthttp.RequestCtx) {
 	// Parse query parameters
 	sinceEventID := string(ctx.QueryArgs().Peek("since"))
 
-	limitStr := string(ctx.QueryArgs().Peek("limit"))
-	limit := 100 // Default
-	if limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil {
-			if parsedLimit > 0 && parsedLimit <= 500 {
-				limit = parsedLimit
-			} else if parsedLimit > 500 {
-				limit = 500 // Max limit
-			}
-		}
-	}
+	limit := middleware.ParsePagination(ctx, 100, 500).Limit
 
 	// Get events for the authenticated user's applications
 	response, err := ee.eventService.GetEventsSince(authenticatedUser.PublicKey, sinceEventID, limit)
@@ -65,6 +57,35 @@ func (ee *EventEndpoints) GetEvents(ctx *fasthttp.RequestCtx) {
 	}
 }
 
+// GetEvent handles GET /events/{eventID}
+// Returns the event if the caller is a member of its application. Unknown events and
+// events of other applications both return 404.
+func (ee *EventEndpoints) GetEvent(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	eventID := ctx.UserValue("eventID").(string)
+
+	event, err := ee.eventService.GetEvent(eventID, authenticatedUser.PublicKey)
+	if err != nil {
+		log.Error().Err(err).Str("eventID", eventID).Msg("Failed to get event")
+		if errors.Is(err, ErrEventNotFound) {
+			ctx.Error("Event not found", fasthttp.StatusNotFound)
+		} else {
+			ctx.Error("Failed to get event", fasthttp.StatusInternalServerError)
+		}
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(event)
+}
+
 // SubmitEvent handles POST /events
 func (ee *EventEndpoints) SubmitEvent(ctx *fasthttp.RequestCtx) {
 	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
@@ -100,7 +121,7 @@ func (ee *EventEndpoints) SubmitEvent(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	acceptedEvent, err := ee.eventService.AcceptEvent(ctx, req.Event, authenticatedUser)
+	acceptedEvent, err := ee.eventService.AcceptEvent(middleware.RequestContext(ctx), req.Event, authenticatedUser)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to accept event")
 
@@ -108,12 +129,24 @@ func (ee *EventEndpoints) SubmitEvent(ctx *fasthttp.RequestCtx) {
 		var reason string
 
 		switch {
-		case err == ErrUnauthorized || err.Error() == "unauthorized":
+		case errors.Is(err, ErrUnauthorized):
 			statusCode = fasthttp.StatusForbidden
 			reason = "unauthorized"
-		case err == ErrValidation || err.Error() == "validation error":
+		case errors.Is(err, application.ErrLastOwner):
+			statusCode = fasthttp.StatusConflict
+			reason = "last_owner"
+		case errors.Is(err, ErrValidation):
 			statusCode = fasthttp.StatusBadRequest
 			reason = "validation_failed"
+		case errors.Is(err, ErrEventLimitReached):
+			statusCode = fasthttp.StatusInsufficientStorage
+			reason = "event_limit_reached"
+		case errors.Is(err, ErrExecutionFailed):
+			statusCode = fasthttp.StatusUnprocessableEntity
+			reason = "execution_failed"
+		case errors.Is(err, ErrStaleEvent):
+			statusCode = fasthttp.StatusConflict
+			reason = "stale_event"
 		default:
 			statusCode = fasthttp.StatusInternalServerError
 			reason = "internal_error"
@@ -129,12 +162,233 @@ func (ee *EventEndpoints) SubmitEvent(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	ctx.SetStatusCode(fasthttp.StatusOK)
-	ctx.SetContentType("application/json")
-	json.NewEncoder(ctx).Encode(map[string]interface{}{
+	response := map[string]interface{}{
 		"accepted":  true,
 		"event":     acceptedEvent,
 		"sequence":  acceptedEvent.SequenceNumber,
 		"timestamp": acceptedEvent.CreatedAt,
+	}
+
+	// Report the post-execute state version so the client can verify it converged
+	if acceptedEvent.ApplicationID != "" {
+		stateVersion, err := ee.eventService.GetStateVersion(acceptedEvent.ApplicationID)
+		if err != nil {
+			log.Warn().Err(err).Str("eventId", acceptedEvent.ID).Msg("[EVENT] Failed to load state version for response")
+		} else {
+			response["stateVersion"] = stateVersion
+		}
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(response)
+}
+
+// GetDataSizeReport handles GET /events/report (server owner only)
+// Query parameters:
+//   - limit (optional, default: 20, max: 100): Number of largest events to list
+func (ee *EventEndpoints) GetDataSizeReport(ctx *fasthttp.RequestCtx) {
+	limit := middleware.ParsePagination(ctx, 20, 100).Limit
+
+	report, err := ee.eventService.GetDataSizeReport(limit)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to build event data size report")
+		ctx.Error("Failed to build report", fasthttp.StatusInternalServerError)
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(report)
+}
+
+// GetSyncState handles GET /sync/state
+// Returns the latest sequence and updatedAt of each of the user's applications so
+// background clients can tell which applications need a delta fetch.
+func (ee *EventEndpoints) GetSyncState(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	response, err := ee.eventService.GetSyncState(authenticatedUser.PublicKey)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get sync state")
+		ctx.Error("Failed to get sync state", fasthttp.StatusInternalServerError)
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(response)
+}
+
+// GetSyncEstimate handles GET /sync/estimate
+// Query parameters:
+//   - since (optional): Last event ID client received; omit to estimate a full sync
+//
+// Returns the number and approximate size of the events per application the client
+// would fetch, so it can choose a snapshot over replay on metered connections.
+func (ee *EventEndpoints) GetSyncEstimate(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	sinceEventID := string(ctx.QueryArgs().Peek("since"))
+
+	response, err := ee.eventService.GetSyncEstimate(authenticatedUser.PublicKey, sinceEventID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get sync estimate")
+		ctx.Error("Failed to get sync estimate", fasthttp.StatusInternalServerError)
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(response)
+}
+
+// GetLastComponentChange handles GET /applications/{appID}/components/{componentID}/last-change
+// Query parameters:
+//   - field (optional): Only consider events that changed this data field
+func (ee *EventEndpoints) GetLastComponentChange(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	appID := ctx.UserValue("appID").(string)
+	componentID := ctx.UserValue("componentID").(string)
+	field := string(ctx.QueryArgs().Peek("field"))
+
+	response, err := ee.eventService.GetLastComponentChange(appID, componentID, field, authenticatedUser.PublicKey)
+	if err != nil {
+		log.Error().Err(err).Str("appID", appID).Str("componentID", componentID).Msg("Failed to get last component change")
+		switch {
+		case errors.Is(err, ErrUnauthorized):
+			ctx.Error("Only the application owner can look up changes", fasthttp.StatusForbidden)
+		case errors.Is(err, ErrChangeNotFound):
+			ctx.Error("No change found for this component", fasthttp.StatusNotFound)
+		default:
+			ctx.Error("Failed to get last component change", fasthttp.StatusInternalServerError)
+		}
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(response)
+}
+
+// PurgeApplicationEvents handles DELETE /applications/{appID}/events
+func (ee *EventEndpoints) PurgeApplicationEvents(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	appID := ctx.UserValue("appID").(string)
+
+	deleted, err := ee.eventService.PurgeApplicationEvents(appID, authenticatedUser.PublicKey)
+	if err != nil {
+		log.Error().Err(err).Str("appID", appID).Msg("Failed to purge application events")
+		switch {
+		case errors.Is(err, ErrEventPurgeDisabled):
+			ctx.Error("Event purge is disabled on this server", fasthttp.StatusForbidden)
+		case errors.Is(err, ErrUnauthorized):
+			ctx.Error("Only the application owner can purge events", fasthttp.StatusForbidden)
+		default:
+			ctx.Error("Failed to purge application events", fasthttp.StatusInternalServerError)
+		}
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(map[string]int64{"deletedEvents": deleted})
+}
+
+// GetComponentHistory handles GET /applications/{appID}/components/{componentID}/history
+// Query parameters:
+//   - limit (optional, default: 20, max: 100): Number of earlier versions to return
+func (ee *EventEndpoints) GetComponentHistory(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	appID := ctx.UserValue("appID").(string)
+	componentID := ctx.UserValue("componentID").(string)
+
+	limit := middleware.ParsePagination(ctx, 20, 100).Limit
+
+	versions, err := ee.eventService.GetComponentDataHistory(appID, componentID, authenticatedUser.PublicKey, limit)
+	if err != nil {
+		log.Error().Err(err).Str("appID", appID).Str("componentID", componentID).Msg("Failed to get component history")
+		writeComponentHistoryError(ctx, err, "Failed to get component history")
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(map[string]interface{}{
+		"versions": versions,
 	})
 }
+
+// RevertComponent handles POST /applications/{appID}/components/{componentID}/history/{versionID}/revert
+// Restores the component's data to the version through a new component_data_changed event.
+func (ee *EventEndpoints) RevertComponent(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	appID := ctx.UserValue("appID").(string)
+	componentID := ctx.UserValue("componentID").(string)
+	versionID := ctx.UserValue("versionID").(string)
+
+	acceptedEvent, err := ee.eventService.RevertComponentData(middleware.RequestContext(ctx), appID, componentID, versionID, authenticatedUser)
+	if err != nil {
+		log.Error().Err(err).Str("componentID", componentID).Str("versionID", versionID).Msg("Failed to revert component")
+		writeComponentHistoryError(ctx, err, "Failed to revert component")
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(map[string]interface{}{
+		"event":    acceptedEvent,
+		"sequence": acceptedEvent.SequenceNumber,
+	})
+}
+
+func writeComponentHistoryError(ctx *fasthttp.RequestCtx, err error, fallback string) {
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		ctx.Error("Forbidden", fasthttp.StatusForbidden)
+	case errors.Is(err, ErrComponentVersionNotFound):
+		ctx.Error("Component version not found", fasthttp.StatusNotFound)
+	case errors.Is(err, ErrValidation):
+		ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+	case errors.Is(err, ErrEventLimitReached):
+		ctx.Error(err.Error(), fasthttp.StatusInsufficientStorage)
+	case errors.Is(err, ErrExecutionFailed):
+		ctx.Error(err.Error(), fasthttp.StatusUnprocessableEntity)
+	default:
+		ctx.Error(fallback, fasthttp.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,64 @@
+package event
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultLogRedactedFields are event data keys whose values are hashed before the
+// payload is written to debug logs. They carry component contents or member PII.
+var DefaultLogRedactedFields = []string{
+	"name",
+	"applicationName",
+	"memberName",
+	"data",
+	"changedFields",
+	"changes",
+	"icon",
+	"email",
+}
+
+// RedactData returns a copy of data with the values of the given keys replaced by a
+// short hash, at any nesting depth. The hash keeps equal values correlatable across
+// log lines without revealing them. The original map is not modified.
+func RedactData(data map[string]interface{}, fields map[string]bool) map[string]interface{} {
+	if data == nil {
+		return nil
+	}
+
+	redacted := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if fields[key] {
+			redacted[key] = hashLogValue(value)
+			continue
+		}
+		redacted[key] = redactValue(value, fields)
+	}
+	return redacted
+}
+
+func redactValue(value interface{}, fields map[string]bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return RedactData(v, fields)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = redactValue(item, fields)
+		}
+		return items
+	default:
+		return value
+	}
+}
+
+func hashLogValue(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded = []byte(fmt.Sprint(value))
+	}
+	sum := sha256.Sum256(encoded)
+	return "redacted:" + hex.EncodeToString(sum[:])[:12]
+}
@@ -3,30 +3,89 @@ package event
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
+
+	"github.com/lib/pq"
 )
 
+// pqUniqueViolation is the Postgres error code for a unique constraint violation
+const pqUniqueViolation = "23505"
+
+// ErrDuplicateEvent is returned when an event with the same ID is already stored
+var ErrDuplicateEvent = errors.New("event already stored")
+
+// ErrSinceEventInaccessible is returned when the since cursor belongs to an application
+// the user is no longer a member of, so the cursor cannot be resumed.
+var ErrSinceEventInaccessible = errors.New("since event belongs to an inaccessible application")
+
+// ErrSincePurged is returned when one of the user's applications had its events purged
+// after the since cursor, so the events following the cursor are incomplete.
+var ErrSincePurged = errors.New("application events were purged after the since event")
+
+// ErrEventLimitReached is returned when an application already stores the maximum number
+// of events allowed per application
+var ErrEventLimitReached = errors.New("application event limit reached")
+
+// ErrComponentVersionNotFound is returned when a component data version does not exist
+// or belongs to another component
+var ErrComponentVersionNotFound = errors.New("component data version not found")
+
+// ErrEventNotFound is returned when an event does not exist or the requester may not read it
+var ErrEventNotFound = errors.New("event not found")
+
+// ErrChangeNotFound is returned when no stored event changed the requested component field
+var ErrChangeNotFound = errors.New("no event changed this component")
+
+// queryer is the subset of *sql.DB and *sql.Tx the repository runs statements on
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 type EventRepository struct {
-	db *sql.DB
+	db queryer
 }
 
 func NewEventRepository(db *sql.DB) *EventRepository {
 	return &EventRepository{db: db}
 }
 
-func (r *EventRepository) GetNextSequence(applicationID string) (int64, error) {
-	var maxSeq int64
-	query := `SELECT COALESCE(MAX(sequence_number), 0)
-			  FROM events
-			  WHERE application_id = $1`
+// Begin starts a transaction for use with WithTx
+func (r *EventRepository) Begin() (*sql.Tx, error) {
+	db, ok := r.db.(*sql.DB)
+	if !ok {
+		return nil, errors.New("event repository is already bound to a transaction")
+	}
+	return db.Begin()
+}
 
-	err := r.db.QueryRow(query, applicationID).Scan(&maxSeq)
+// WithTx returns a repository that runs its statements within tx
+func (r *EventRepository) WithTx(tx *sql.Tx) *EventRepository {
+	return &EventRepository{db: tx}
+}
+
+// GetNextSequence atomically advances the per-application counter and returns the new value.
+// The counter never decreases, so sequences stay strictly increasing even after old events are
+// deleted. It is also kept above the current max of live rows in case data is restored.
+func (r *EventRepository) GetNextSequence(applicationID string) (int64, error) {
+	var nextSeq int64
+	query := `INSERT INTO application_sequences (application_id, last_sequence)
+			  VALUES ($1, (SELECT COALESCE(MAX(sequence_number), 0) FROM events WHERE application_id = $1) + 1)
+			  ON CONFLICT (application_id) DO UPDATE SET last_sequence = GREATEST(
+			      application_sequences.last_sequence,
+			      (SELECT COALESCE(MAX(sequence_number), 0) FROM events WHERE application_id = $1)
+			  ) + 1
+			  RETURNING last_sequence`
+
+	err := r.db.QueryRow(query, applicationID).Scan(&nextSeq)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get next sequence: %w", err)
 	}
 
-	return maxSeq + 1, nil
+	return nextSeq, nil
 }
 
 func (r *EventRepository) Create(event *Event) error {
@@ -61,8 +120,17 @@ func (r *EventRepository) Create(event *Event) error {
 		appID = event.ApplicationID
 	}
 
-	query := `INSERT INTO events (id, created_at, application_id, sequence_number, type, creator_public_key, version, data)
-			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	// The ID of an event merged away by compaction stays taken by its alias
+	var aliased bool
+	if err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM event_aliases WHERE id = $1)", event.ID).Scan(&aliased); err != nil {
+		return fmt.Errorf("failed to check event aliases: %w", err)
+	}
+	if aliased {
+		return fmt.Errorf("%w: %s", ErrDuplicateEvent, event.ID)
+	}
+
+	query := `INSERT INTO events (id, created_at, application_id, sequence_number, type, creator_public_key, version, data, data_size)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
 
 	_, err = r.db.Exec(query,
 		event.ID,
@@ -73,8 +141,12 @@ func (r *EventRepository) Create(event *Event) error {
 		event.CreatorPublicKey,
 		event.Version,
 		string(dataJSON),
+		len(dataJSON),
 	)
 
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: %s", ErrDuplicateEvent, event.ID)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to insert event: %w", err)
 	}
@@ -82,9 +154,11 @@ func (r *EventRepository) Create(event *Event) error {
 	return nil
 }
 
+// GetByID returns the event with the given ID. The ID of an event merged away by
+// compaction returns the event it was merged into.
 func (r *EventRepository) GetByID(id string) (*Event, error) {
 	query := `SELECT id, created_at, application_id, sequence_number, type, creator_public_key, version, data
-			  FROM events WHERE id = $1`
+			  FROM events WHERE id = COALESCE((SELECT event_id FROM event_aliases WHERE id = $1), $1)`
 
 	event := &Event{}
 	var eventType string
@@ -103,7 +177,7 @@ func (r *EventRepository) GetByID(id string) (*Event, error) {
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("event not found")
+		return nil, ErrEventNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to query event: %w", err)
@@ -122,6 +196,136 @@ func (r *EventRepository) GetByID(id string) (*Event, error) {
 	return event, nil
 }
 
+// GetLatestByApplicationID returns the application's event with the highest sequence
+func (r *EventRepository) GetLatestByApplicationID(appID string) (*Event, error) {
+	query := `SELECT id, created_at, application_id, sequence_number, type, creator_public_key, version, data
+			  FROM events
+			  WHERE application_id = $1
+			  ORDER BY sequence_number DESC
+			  LIMIT 1`
+
+	event := &Event{}
+	var eventType string
+	var dataJSON string
+	var appIDNull sql.NullString
+
+	err := r.db.QueryRow(query, appID).Scan(
+		&event.ID,
+		&event.CreatedAt,
+		&appIDNull,
+		&event.SequenceNumber,
+		&eventType,
+		&event.CreatorPublicKey,
+		&event.Version,
+		&dataJSON,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest event: %w", err)
+	}
+
+	if appIDNull.Valid {
+		event.ApplicationID = appIDNull.String
+	}
+
+	event.Type = EventType(eventType)
+
+	if err := json.Unmarshal([]byte(dataJSON), &event.Data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event data: %w", err)
+	}
+
+	return event, nil
+}
+
+// Replace overwrites the stored event previousID with event, including its ID, so the row
+// takes the new event's place at the end of the log. previousID is kept as an alias of
+// the row that holds its original sequence number, so cursors on it still resolve and a
+// retried submission of it is still a duplicate. Must run in a transaction. Returns
+// ErrEventNotFound when previousID no longer exists.
+func (r *EventRepository) Replace(previousID string, event *Event) error {
+	dataJSON, err := json.Marshal(event.Data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event data: %w", err)
+	}
+
+	// The alias follows the row to its new ID through the foreign key's ON UPDATE CASCADE,
+	// as do aliases of events compacted into previousID earlier
+	aliasQuery := `INSERT INTO event_aliases (id, event_id, application_id, sequence_number, created_at)
+				   SELECT id, id, application_id, sequence_number, created_at FROM events WHERE id = $1`
+	aliasResult, err := r.db.Exec(aliasQuery, previousID)
+	if err != nil {
+		return fmt.Errorf("failed to alias replaced event: %w", err)
+	}
+	aliased, err := aliasResult.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if aliased == 0 {
+		return fmt.Errorf("%w: %s", ErrEventNotFound, previousID)
+	}
+
+	query := `UPDATE events
+			  SET id = $1, created_at = $2, sequence_number = $3, creator_public_key = $4,
+			      version = $5, data = $6, data_size = $7
+			  WHERE id = $8`
+
+	result, err := r.db.Exec(query,
+		event.ID,
+		event.CreatedAt,
+		event.SequenceNumber,
+		event.CreatorPublicKey,
+		event.Version,
+		string(dataJSON),
+		len(dataJSON),
+		previousID,
+	)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: %s", ErrDuplicateEvent, event.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to replace event: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrEventNotFound, previousID)
+	}
+
+	return nil
+}
+
+// sinceEventQuery resolves a sync cursor to its position. A cursor on an event merged away
+// by compaction resolves through its alias to the position the event had.
+const sinceEventQuery = `SELECT application_id, sequence_number, created_at FROM events WHERE id = $1
+						 UNION ALL
+						 SELECT application_id, sequence_number, created_at FROM event_aliases WHERE id = $1
+						 LIMIT 1`
+
+// checkPurgedSince returns ErrSincePurged when an application the user is a member of had
+// its events purged at or after the given unix time
+func (r *EventRepository) checkPurgedSince(userPublicKey string, since int64) error {
+	var purged bool
+	err := r.db.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM event_purges p
+		               INNER JOIN members m ON m.application_id = p.application_id
+		               WHERE m.public_key = $1 AND p.purged_at >= $2)`,
+		userPublicKey, since,
+	).Scan(&purged)
+	if err != nil {
+		return fmt.Errorf("failed to check event purges: %w", err)
+	}
+	if purged {
+		return ErrSincePurged
+	}
+	return nil
+}
+
 func (r *EventRepository) GetSince(userPublicKey string, sinceEventID string, limit int) ([]*Event, bool, error) {
 	if limit <= 0 || limit > 500 {
 		limit = 100
@@ -149,15 +353,33 @@ func (r *EventRepository) GetSince(userPublicKey string, sinceEventID string, li
 		var sinceAppID sql.NullString
 		var sinceSequence int64
 		var sinceCreatedAt int64
-		err := r.db.QueryRow("SELECT application_id, sequence_number, created_at FROM events WHERE id = $1", sinceEventID).Scan(&sinceAppID, &sinceSequence, &sinceCreatedAt)
+		err := r.db.QueryRow(sinceEventQuery, sinceEventID).Scan(&sinceAppID, &sinceSequence, &sinceCreatedAt)
 		if err == sql.ErrNoRows {
+			// The cursor may itself have been purged, in which case any purge is after it
+			if err := r.checkPurgedSince(userPublicKey, 0); err != nil {
+				return nil, false, err
+			}
 			return r.GetSince(userPublicKey, "", limit)
 		}
 		if err != nil {
 			return nil, false, fmt.Errorf("failed to get since event: %w", err)
 		}
+		if err := r.checkPurgedSince(userPublicKey, sinceCreatedAt); err != nil {
+			return nil, false, err
+		}
 
 		if sinceAppID.Valid {
+			// The member join below would silently drop the cursor's application, leaving the
+			// client with a partial view; report it so the client can resync instead
+			var isMember bool
+			err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM members WHERE application_id = $1 AND public_key = $2)", sinceAppID.String, userPublicKey).Scan(&isMember)
+			if err != nil {
+				return nil, false, fmt.Errorf("failed to check since event access: %w", err)
+			}
+			if !isMember {
+				return nil, false, fmt.Errorf("%w: %s", ErrSinceEventInaccessible, sinceAppID.String)
+			}
+
 			// The sinceEvent was an app-scoped event
 			query = `(SELECT DISTINCT e.id, e.created_at, e.application_id, e.sequence_number,
 					         e.type, e.creator_public_key, e.version, e.data
@@ -270,6 +492,81 @@ func (r *EventRepository) GetSince(userPublicKey string, sinceEventID string, li
 	return events, hasMore, nil
 }
 
+// GetApplicationEventsSince returns up to limit events of one application in sequence
+// order, after sinceEventID when given, and whether more follow. A since event that no
+// longer exists or belongs to another application returns ErrEventNotFound.
+func (r *EventRepository) GetApplicationEventsSince(appID, sinceEventID string, limit int) ([]*Event, bool, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 500
+	}
+
+	var sinceSequence int64
+	if sinceEventID != "" {
+		var sinceAppID sql.NullString
+		var sinceCreatedAt int64
+		err := r.db.QueryRow(sinceEventQuery, sinceEventID).Scan(&sinceAppID, &sinceSequence, &sinceCreatedAt)
+		if err == sql.ErrNoRows || (err == nil && sinceAppID.String != appID) {
+			return nil, false, fmt.Errorf("%w: %s", ErrEventNotFound, sinceEventID)
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get since event: %w", err)
+		}
+	}
+
+	query := `SELECT id, created_at, application_id, sequence_number, type, creator_public_key, version, data
+			  FROM events
+			  WHERE application_id = $1 AND sequence_number > $2
+			  ORDER BY sequence_number ASC, id ASC
+			  LIMIT $3`
+
+	rows, err := r.db.Query(query, appID, sinceSequence, limit+1)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		event := &Event{}
+		var eventType string
+		var dataJSON string
+		var appIDNull sql.NullString
+
+		err := rows.Scan(
+			&event.ID,
+			&event.CreatedAt,
+			&appIDNull,
+			&event.SequenceNumber,
+			&eventType,
+			&event.CreatorPublicKey,
+			&event.Version,
+			&dataJSON,
+		)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to scan event: %w", err)
+		}
+
+		event.ApplicationID = appIDNull.String
+		event.Type = EventType(eventType)
+
+		if err := json.Unmarshal([]byte(dataJSON), &event.Data); err != nil {
+			return nil, false, fmt.Errorf("failed to unmarshal event data: %w", err)
+		}
+
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("error iterating events: %w", err)
+	}
+
+	hasMore := len(events) > limit
+	if hasMore {
+		events = events[:limit]
+	}
+
+	return events, hasMore, nil
+}
+
 func (r *EventRepository) GetByApplicationID(appID string, limit int) ([]*Event, error) {
 	if limit <= 0 {
 		limit = 100
@@ -324,19 +621,235 @@ func (r *EventRepository) GetByApplicationID(appID string, limit int) ([]*Event,
 	return events, rows.Err()
 }
 
-func (r *EventRepository) DeleteOlderThan(timestamp int64) (int64, error) {
-	query := `DELETE FROM events WHERE created_at < $1`
+// GetLastComponentChange returns the highest-sequence component_data_changed event of the
+// application that touched the component, limited to events that changed field when
+// field is non-empty. Event data is stored as text, so it is cast to jsonb for the match.
+func (r *EventRepository) GetLastComponentChange(appID, componentID, field string) (*Event, error) {
+	query := `SELECT id, created_at, application_id, sequence_number, type, creator_public_key, version, data
+			  FROM events
+			  WHERE application_id = $1
+			    AND type = $2
+			    AND data::jsonb->>'componentId' = $3
+			    AND ($4 = '' OR data::jsonb->'changedFields' ? $4)
+			  ORDER BY sequence_number DESC
+			  LIMIT 1`
+
+	event := &Event{}
+	var eventType string
+	var dataJSON string
+	var appIDNull sql.NullString
 
-	result, err := r.db.Exec(query, timestamp)
+	err := r.db.QueryRow(query, appID, string(EventTypeComponentDataChanged), componentID, field).Scan(
+		&event.ID,
+		&event.CreatedAt,
+		&appIDNull,
+		&event.SequenceNumber,
+		&eventType,
+		&event.CreatorPublicKey,
+		&event.Version,
+		&dataJSON,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrChangeNotFound
+	}
 	if err != nil {
+		return nil, fmt.Errorf("failed to query last component change: %w", err)
+	}
+
+	if appIDNull.Valid {
+		event.ApplicationID = appIDNull.String
+	}
+
+	event.Type = EventType(eventType)
+
+	if err := json.Unmarshal([]byte(dataJSON), &event.Data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event data: %w", err)
+	}
+
+	return event, nil
+}
+
+// GetSyncState returns the latest sequence and update time of every application the
+// user is a member of. Sequences come from application_sequences, which holds the
+// highest sequence issued per application, so no scan over events is needed.
+func (r *EventRepository) GetSyncState(userPublicKey string) ([]*AppSyncState, error) {
+	query := `SELECT a.id, a.updated_at, COALESCE(s.last_sequence, 0)
+			  FROM members m
+			  INNER JOIN applications a ON a.id = m.application_id AND a.deleted_at IS NULL
+			  LEFT JOIN application_sequences s ON s.application_id = a.id
+			  WHERE m.public_key = $1
+			  ORDER BY a.id`
+
+	rows, err := r.db.Query(query, userPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sync state: %w", err)
+	}
+	defer rows.Close()
+
+	states := []*AppSyncState{}
+	for rows.Next() {
+		state := &AppSyncState{}
+		if err := rows.Scan(&state.ApplicationID, &state.UpdatedAt, &state.LastSequence); err != nil {
+			return nil, fmt.Errorf("failed to scan sync state: %w", err)
+		}
+		states = append(states, state)
+	}
+
+	return states, rows.Err()
+}
+
+// GetSyncEstimate counts the events of every application the user is a member of that
+// come after sinceEventID, in the same order GetSince pages through them, and sums their
+// serialized size. An empty sinceEventID counts all stored events. Returns ErrEventNotFound
+// when the cursor no longer exists, ErrSinceEventInaccessible when it belongs to an
+// application the user left and ErrSincePurged when events were purged after it.
+func (r *EventRepository) GetSyncEstimate(userPublicKey string, sinceEventID string) ([]*AppSyncEstimate, error) {
+	cursor := ""
+	args := []interface{}{userPublicKey}
+
+	if sinceEventID != "" {
+		var sinceAppID sql.NullString
+		var sinceSequence int64
+		var sinceCreatedAt int64
+		err := r.db.QueryRow(sinceEventQuery, sinceEventID).Scan(&sinceAppID, &sinceSequence, &sinceCreatedAt)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", ErrEventNotFound, sinceEventID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get since event: %w", err)
+		}
+		if err := r.checkPurgedSince(userPublicKey, sinceCreatedAt); err != nil {
+			return nil, err
+		}
+
+		if sinceAppID.Valid {
+			var isMember bool
+			err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM members WHERE application_id = $1 AND public_key = $2)", sinceAppID.String, userPublicKey).Scan(&isMember)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check since event access: %w", err)
+			}
+			if !isMember {
+				return nil, fmt.Errorf("%w: %s", ErrSinceEventInaccessible, sinceAppID.String)
+			}
+
+			cursor = ` AND (
+				(e.application_id = $2 AND (
+					e.sequence_number > $3
+					OR (e.sequence_number = $3 AND e.created_at > $4)
+					OR (e.sequence_number = $3 AND e.created_at = $4 AND e.id > $5)
+				))
+				OR (e.application_id <> $2 AND (
+					e.created_at > $4
+					OR (e.created_at = $4 AND e.id > $5)
+				))
+			)`
+			args = append(args, sinceAppID.String, sinceSequence, sinceCreatedAt, sinceEventID)
+		} else {
+			cursor = ` AND e.created_at > $2`
+			args = append(args, sinceCreatedAt)
+		}
+	}
+
+	query := `SELECT a.id, COUNT(e.id), COALESCE(SUM(COALESCE(e.data_size, OCTET_LENGTH(e.data), 0)), 0)
+			  FROM members m
+			  INNER JOIN applications a ON a.id = m.application_id AND a.deleted_at IS NULL
+			  LEFT JOIN events e ON e.application_id = a.id` + cursor + `
+			  WHERE m.public_key = $1
+			  GROUP BY a.id
+			  ORDER BY a.id`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sync estimate: %w", err)
+	}
+	defer rows.Close()
+
+	estimates := []*AppSyncEstimate{}
+	for rows.Next() {
+		estimate := &AppSyncEstimate{}
+		if err := rows.Scan(&estimate.ApplicationID, &estimate.EventCount, &estimate.ApproxBytes); err != nil {
+			return nil, fmt.Errorf("failed to scan sync estimate: %w", err)
+		}
+		estimates = append(estimates, estimate)
+	}
+
+	return estimates, rows.Err()
+}
+
+// DeleteOlderThan deletes events created before timestamp and takes them off their
+// applications' event counts in the same statement
+func (r *EventRepository) DeleteOlderThan(timestamp int64) (int64, error) {
+	query := `WITH deleted AS (
+				  DELETE FROM events WHERE created_at < $1 RETURNING application_id
+			  ), counts AS (
+				  UPDATE application_sequences s
+				  SET event_count = GREATEST(s.event_count - d.deleted_count, 0)
+				  FROM (SELECT application_id, COUNT(*) AS deleted_count
+				        FROM deleted WHERE application_id IS NOT NULL
+				        GROUP BY application_id) d
+				  WHERE s.application_id = d.application_id
+			  )
+			  SELECT COUNT(*) FROM deleted`
+
+	var deleted int64
+	if err := r.db.QueryRow(query, timestamp).Scan(&deleted); err != nil {
 		return 0, fmt.Errorf("failed to delete old events: %w", err)
 	}
 
+	return deleted, nil
+}
+
+// IncrementEventCount counts one more stored event for the application. The row is created
+// by GetNextSequence in the same transaction.
+func (r *EventRepository) IncrementEventCount(appID string) error {
+	_, err := r.db.Exec(`UPDATE application_sequences SET event_count = event_count + 1 WHERE application_id = $1`, appID)
+	if err != nil {
+		return fmt.Errorf("failed to count event: %w", err)
+	}
+	return nil
+}
+
+// GetEventCount returns the number of events counted for the application
+func (r *EventRepository) GetEventCount(appID string) (int64, error) {
+	var count int64
+	err := r.db.QueryRow(`SELECT event_count FROM application_sequences WHERE application_id = $1`, appID).Scan(&count)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get event count: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteByApplicationID deletes every event of the application and records purgedAt as
+// the time of the purge, so earlier sync cursors are sent to resync. The application's
+// sequence counter is left alone, so sequences are never reused.
+func (r *EventRepository) DeleteByApplicationID(appID string, purgedAt int64) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM events WHERE application_id = $1`, appID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete application events: %w", err)
+	}
+
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return 0, fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
+	if _, err := r.db.Exec(`UPDATE application_sequences SET event_count = 0 WHERE application_id = $1`, appID); err != nil {
+		return 0, fmt.Errorf("failed to reset event count: %w", err)
+	}
+
+	_, err = r.db.Exec(
+		`INSERT INTO event_purges (application_id, purged_at) VALUES ($1, $2)
+		 ON CONFLICT (application_id) DO UPDATE SET purged_at = EXCLUDED.purged_at`,
+		appID, purgedAt,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record event purge: %w", err)
+	}
+
 	return rowsAffected, nil
 }
 
@@ -355,6 +868,45 @@ func (r *EventRepository) GetOldestEventID() (string, error) {
 	return id, nil
 }
 
+// CountByApplicationID returns the number of events stored for the application
+func (r *EventRepository) CountByApplicationID(appID string) (int64, error) {
+	query := `SELECT COUNT(*) FROM events WHERE application_id = $1`
+
+	var count int64
+	if err := r.db.QueryRow(query, appID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count application events: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetEventCountsByApplication returns the applications with the most stored events, most first
+func (r *EventRepository) GetEventCountsByApplication(limit int) ([]*ApplicationEventCount, error) {
+	query := `SELECT application_id, COUNT(*)
+			  FROM events
+			  WHERE application_id IS NOT NULL AND application_id <> ''
+			  GROUP BY application_id
+			  ORDER BY 2 DESC, application_id
+			  LIMIT $1`
+
+	rows, err := r.db.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query event counts by application: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*ApplicationEventCount
+	for rows.Next() {
+		count := &ApplicationEventCount{}
+		if err := rows.Scan(&count.ApplicationID, &count.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan application event count: %w", err)
+		}
+		result = append(result, count)
+	}
+
+	return result, rows.Err()
+}
+
 func (r *EventRepository) Count() (int64, error) {
 	query := `SELECT COUNT(*) FROM events`
 
@@ -366,3 +918,80 @@ func (r *EventRepository) Count() (int64, error) {
 
 	return count, nil
 }
+
+// GetLargestEvents returns metadata for the events with the largest serialized data, biggest first
+func (r *EventRepository) GetLargestEvents(limit int) ([]*EventSizeInfo, error) {
+	query := `SELECT id, application_id, sequence_number, type, created_at, COALESCE(data_size, OCTET_LENGTH(data), 0)
+			  FROM events
+			  ORDER BY COALESCE(data_size, OCTET_LENGTH(data), 0) DESC, created_at DESC
+			  LIMIT $1`
+
+	rows, err := r.db.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query largest events: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*EventSizeInfo
+	for rows.Next() {
+		info := &EventSizeInfo{}
+		var eventType string
+		var appID sql.NullString
+		var seq sql.NullInt64
+
+		if err := rows.Scan(&info.ID, &appID, &seq, &eventType, &info.CreatedAt, &info.DataSize); err != nil {
+			return nil, fmt.Errorf("failed to scan event size: %w", err)
+		}
+
+		if appID.Valid {
+			info.ApplicationID = appID.String
+		}
+		if seq.Valid {
+			info.SequenceNumber = seq.Int64
+		}
+		info.Type = EventType(eventType)
+		result = append(result, info)
+	}
+
+	return result, rows.Err()
+}
+
+// GetDataSizeByType aggregates serialized event data sizes per event type
+func (r *EventRepository) GetDataSizeByType() ([]*EventTypeSizeStats, error) {
+	query := `SELECT type,
+			         COUNT(*),
+			         COALESCE(SUM(COALESCE(data_size, OCTET_LENGTH(data), 0)), 0),
+			         COALESCE(AVG(COALESCE(data_size, OCTET_LENGTH(data), 0)), 0),
+			         COALESCE(MAX(COALESCE(data_size, OCTET_LENGTH(data), 0)), 0)
+			  FROM events
+			  GROUP BY type
+			  ORDER BY 3 DESC`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query event sizes by type: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*EventTypeSizeStats
+	for rows.Next() {
+		stats := &EventTypeSizeStats{}
+		var eventType string
+		var avg float64
+
+		if err := rows.Scan(&eventType, &stats.Count, &stats.TotalBytes, &avg, &stats.MaxBytes); err != nil {
+			return nil, fmt.Errorf("failed to scan event type sizes: %w", err)
+		}
+
+		stats.Type = EventType(eventType)
+		stats.AvgBytes = int64(avg)
+		result = append(result, stats)
+	}
+
+	return result, rows.Err()
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation
+}
@@ -2,41 +2,142 @@ package event
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"reflect"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/prappser/prappser_server/internal/apitoken"
 	"github.com/prappser/prappser_server/internal/application"
+	"github.com/prappser/prappser_server/internal/clock"
 	"github.com/prappser/prappser_server/internal/user"
 	"github.com/rs/zerolog/log"
 )
 
+// ErrExecutionFailed is returned when an event could not be applied to the application
+// state. The event is rolled back rather than stored.
+var ErrExecutionFailed = errors.New("event execution failed")
+
+// ErrStaleEvent is returned for a client event made longer ago than the configured maximum
+// event age. The client should resync before submitting its changes again.
+var ErrStaleEvent = errors.New("event is too old")
+
+// ErrEventPurgeDisabled is returned when purging an application's events is not enabled
+var ErrEventPurgeDisabled = errors.New("event purge is disabled")
+
 // EventBroadcaster broadcasts events to connected WebSocket clients
 type EventBroadcaster interface {
 	BroadcastToApplication(applicationID string, event *Event)
 	BroadcastToUser(userPublicKey string, event *Event)
+	// EvictUserFromApp stops broadcasts of the application to the user's connections
+	EvictUserFromApp(applicationID, userPublicKey string)
+}
+
+// EventDispatcher delivers accepted application events to external integrations
+type EventDispatcher interface {
+	Dispatch(event *Event)
+}
+
+// MemberUserStore looks up and creates the user accounts behind application members
+type MemberUserStore interface {
+	GetUserByPublicKey(publicKey string) (*user.User, error)
+	CreateUser(user *user.User) error
+}
+
+// txMemberUserStore is a MemberUserStore whose changes can join a transaction, so a user
+// created for a member_added event is rolled back with the event
+type txMemberUserStore interface {
+	WithTx(tx *sql.Tx) user.UserRepository
 }
 
 type EventService struct {
-	repo        *EventRepository
-	appRepo     application.ApplicationRepository
-	broadcaster EventBroadcaster
+	repo              *EventRepository
+	appRepo           application.ApplicationRepository
+	users             MemberUserStore
+	broadcaster       EventBroadcaster
+	dispatcher        EventDispatcher
+	serverOnlyTypes   map[EventType]bool
+	logRedactedFields map[string]bool
+	maxDataDepth      int
+	maxDataKeys       int
+	maxAppEvents      int64
+	historyDepth      int
+	memberUserPolicy  MemberUserPolicy
+	compactionWindow  time.Duration
+	authorization     AuthorizationPolicies
+	maxEventAge       time.Duration
+	allowEventPurge   bool
+	clock             clock.Clock
 }
 
-func NewEventService(repo *EventRepository, appRepo application.ApplicationRepository, broadcaster EventBroadcaster) *EventService {
+func NewEventService(repo *EventRepository, appRepo application.ApplicationRepository, users MemberUserStore, broadcaster EventBroadcaster, dispatcher EventDispatcher, config Config) *EventService {
+	serverOnlyTypes := make(map[EventType]bool, len(config.ServerOnlyTypes))
+	for _, eventType := range config.ServerOnlyTypes {
+		serverOnlyTypes[eventType] = true
+	}
+
+	logRedactedFields := make(map[string]bool, len(config.LogRedactedFields))
+	for _, field := range config.LogRedactedFields {
+		logRedactedFields[field] = true
+	}
+
+	if config.MaxComponentDataDepth <= 0 {
+		config.MaxComponentDataDepth = DefaultMaxComponentDataDepth
+	}
+	if config.MaxComponentDataKeys <= 0 {
+		config.MaxComponentDataKeys = DefaultMaxComponentDataKeys
+	}
+	if config.AuthorizationPolicies == nil {
+		config.AuthorizationPolicies = defaultAuthorizationPolicies
+	}
+	if config.Clock == nil {
+		config.Clock = clock.System
+	}
+
 	return &EventService{
-		repo:        repo,
-		appRepo:     appRepo,
-		broadcaster: broadcaster,
+		repo:              repo,
+		appRepo:           appRepo,
+		users:             users,
+		broadcaster:       broadcaster,
+		dispatcher:        dispatcher,
+		serverOnlyTypes:   serverOnlyTypes,
+		logRedactedFields: logRedactedFields,
+		maxDataDepth:      config.MaxComponentDataDepth,
+		maxDataKeys:       config.MaxComponentDataKeys,
+		maxAppEvents:      config.MaxEventsPerApplication,
+		historyDepth:      config.ComponentHistoryDepth,
+		memberUserPolicy:  config.MemberUserPolicy,
+		compactionWindow:  config.CompactionWindow,
+		authorization:     config.AuthorizationPolicies,
+		maxEventAge:       config.MaxEventAge,
+		allowEventPurge:   config.AllowEventPurge,
+		clock:             config.Clock,
+	}
+}
+
+// logPayload writes the event data at debug level with configured fields redacted
+func (s *EventService) logPayload(event *Event) {
+	if e := log.Debug(); e.Enabled() {
+		e.Str("eventId", event.ID).
+			Interface("data", RedactData(event.Data, s.logRedactedFields)).
+			Msg("[EVENT] Payload")
 	}
 }
 
+// IsClientSubmittable reports whether clients may submit the event type over POST /events
+func (s *EventService) IsClientSubmittable(eventType EventType) bool {
+	return !s.serverOnlyTypes[eventType]
+}
+
 func (s *EventService) AcceptEvent(ctx context.Context, event *Event, submitter *user.User) (*Event, error) {
 	log.Debug().
 		Str("eventId", event.ID).
 		Str("type", string(event.Type)).
 		Str("submitter", submitter.Username).
 		Msg("[EVENT] Received from client")
+	s.logPayload(event)
 
 	if err := ValidateEvent(event); err != nil {
 		log.Debug().
@@ -45,8 +146,41 @@ func (s *EventService) AcceptEvent(ctx context.Context, event *Event, submitter
 			Msg("[EVENT] Validation failed")
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
+	// Bound component data before it reaches the merge executors
+	if carriesComponentData(event.Type) {
+		if err := ValidateDataShape(event.Data, s.maxDataDepth, s.maxDataKeys); err != nil {
+			log.Debug().
+				Str("eventId", event.ID).
+				Err(err).
+				Msg("[EVENT] Validation failed")
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+	}
 	log.Debug().Str("eventId", event.ID).Msg("[EVENT] Validation passed")
 
+	if !s.IsClientSubmittable(event.Type) {
+		log.Debug().
+			Str("eventId", event.ID).
+			Str("type", string(event.Type)).
+			Msg("[EVENT] Rejected server-only event type from client")
+		return nil, fmt.Errorf("authorization failed: %w: %s events are server-produced and cannot be submitted by clients", ErrUnauthorized, event.Type)
+	}
+
+	// A retry of a stored event is answered with the stored event before the age check,
+	// application lookup and authorization, which the event itself may since have made
+	// fail: a deleted application, or a member who removed themselves
+	if stored, err := s.storedDuplicate(event, ErrDuplicateEvent); err == nil {
+		return stored, nil
+	}
+
+	if err := s.checkEventAge(event); err != nil {
+		log.Debug().
+			Str("eventId", event.ID).
+			Err(err).
+			Msg("[EVENT] Rejected stale event")
+		return nil, err
+	}
+
 	// User-scoped events bypass application lookup and use a separate authorization path
 	if IsUserScoped(event.Type) {
 		return s.acceptUserScopedEvent(ctx, event, submitter)
@@ -65,7 +199,7 @@ func (s *EventService) AcceptEvent(ctx context.Context, event *Event, submitter
 		return nil, fmt.Errorf("application not found: %w", err)
 	}
 
-	if err := AuthorizeEvent(event, submitter, app); err != nil {
+	if err := s.authorization.Authorize(event, submitter, app); err != nil {
 		log.Debug().
 			Str("eventId", event.ID).
 			Err(err).
@@ -74,47 +208,40 @@ func (s *EventService) AcceptEvent(ctx context.Context, event *Event, submitter
 	}
 	log.Debug().Str("eventId", event.ID).Msg("[EVENT] Authorization passed")
 
-	seq, err := s.repo.GetNextSequence(appID)
-	if err != nil {
-		return nil, fmt.Errorf("sequence generation failed: %w", err)
+	// Check before persisting so a rejected member never reaches the event log. A missing
+	// user is only created when the event executes, inside its transaction.
+	if event.Type == EventTypeMemberAdded && s.memberUserPolicy == MemberUserPolicyReject {
+		if err := s.ensureMemberUser(event); err != nil {
+			log.Debug().
+				Str("eventId", event.ID).
+				Err(err).
+				Msg("[EVENT] Rejected member without user account")
+			return nil, err
+		}
 	}
-	event.SequenceNumber = seq
-
-	event.CreatedAt = time.Now().Unix()
 
-	log.Debug().
-		Str("eventId", event.ID).
-		Int64("sequence", event.SequenceNumber).
-		Msg("[EVENT] Persisting to database")
-
-	if err := s.repo.Create(event); err != nil {
-		log.Error().
+	// A compacted event replaces an existing one, so it never grows the log. The limit is
+	// checked once the event is stored and counted, so a retry of a stored event is still
+	// recognised at the cap and concurrent submissions cannot overshoot it.
+	previous := s.compactionTarget(event)
+	err = s.inTx(func(txService *EventService) error {
+		if err := txService.applyEvent(ctx, event, previous); err != nil {
+			return err
+		}
+		if previous != nil {
+			return nil
+		}
+		return txService.checkEventLimit(appID, event.Type)
+	})
+	if errors.Is(err, ErrEventLimitReached) {
+		log.Debug().
 			Str("eventId", event.ID).
 			Err(err).
-			Msg("[EVENT] Persistence failed")
-		return nil, fmt.Errorf("persistence failed: %w", err)
+			Msg("[EVENT] Rejected by application event limit")
+		return nil, err
 	}
-
-	log.Debug().
-		Str("eventId", event.ID).
-		Str("type", string(event.Type)).
-		Msg("[EVENT] Executing")
-
-	// Execute event to update database state
-	if err := s.executeEvent(ctx, event); err != nil {
-		// Log error but don't fail event acceptance
-		// Event is already persisted and sequenced
-		log.Error().
-			Str("eventId", event.ID).
-			Str("type", string(event.Type)).
-			Err(err).
-			Msg("[EVENT] Execution failed - event persisted but database state not updated")
-	} else {
-		log.Debug().
-			Str("eventId", event.ID).
-			Str("type", string(event.Type)).
-			Msg("[EVENT] Execution complete")
-		s.updateAppVersion(event)
+	if err != nil {
+		return s.storedDuplicate(event, err)
 	}
 
 	log.Info().
@@ -126,9 +253,26 @@ func (s *EventService) AcceptEvent(ctx context.Context, event *Event, submitter
 	// Broadcast to WebSocket clients
 	s.broadcastEvent(event)
 
+	if s.dispatcher != nil {
+		s.dispatcher.Dispatch(event)
+	}
+
 	return event, nil
 }
 
+// checkEventAge rejects an event whose client-supplied createdAt is older than the maximum
+// event age. The stored createdAt is always set by the server when the event is committed.
+func (s *EventService) checkEventAge(event *Event) error {
+	if s.maxEventAge <= 0 || event.CreatedAt <= 0 {
+		return nil
+	}
+	age := s.clock.Now().Sub(time.Unix(event.CreatedAt, 0))
+	if age > s.maxEventAge {
+		return fmt.Errorf("%w: made %s ago, maximum is %s; resync before submitting", ErrStaleEvent, age.Truncate(time.Second), s.maxEventAge)
+	}
+	return nil
+}
+
 // acceptUserScopedEvent handles the user-scoped event path (no application context).
 func (s *EventService) acceptUserScopedEvent(ctx context.Context, event *Event, submitter *user.User) (*Event, error) {
 	if err := AuthorizeUserScopedEvent(event, submitter); err != nil {
@@ -140,38 +284,8 @@ func (s *EventService) acceptUserScopedEvent(ctx context.Context, event *Event,
 	}
 	log.Debug().Str("eventId", event.ID).Msg("[EVENT] Authorization passed")
 
-	// User-scoped events have no sequence number
-	event.SequenceNumber = 0
-	event.CreatedAt = time.Now().Unix()
-
-	log.Debug().
-		Str("eventId", event.ID).
-		Msg("[EVENT] Persisting user-scoped event to database")
-
-	if err := s.repo.Create(event); err != nil {
-		log.Error().
-			Str("eventId", event.ID).
-			Err(err).
-			Msg("[EVENT] Persistence failed")
-		return nil, fmt.Errorf("persistence failed: %w", err)
-	}
-
-	log.Debug().
-		Str("eventId", event.ID).
-		Str("type", string(event.Type)).
-		Msg("[EVENT] Executing")
-
-	if err := s.executeEvent(ctx, event); err != nil {
-		log.Error().
-			Str("eventId", event.ID).
-			Str("type", string(event.Type)).
-			Err(err).
-			Msg("[EVENT] Execution failed - event persisted but database state not updated")
-	} else {
-		log.Debug().
-			Str("eventId", event.ID).
-			Str("type", string(event.Type)).
-			Msg("[EVENT] Execution complete")
+	if err := s.commitEvent(ctx, event, nil); err != nil {
+		return s.storedDuplicate(event, err)
 	}
 
 	log.Info().
@@ -194,6 +308,7 @@ func (s *EventService) ProduceEvent(ctx context.Context, event *Event) (*Event,
 		Str("eventId", event.ID).
 		Str("type", string(event.Type)).
 		Msg("[EVENT] Server-produced event received")
+	s.logPayload(event)
 
 	if err := ValidateEvent(event); err != nil {
 		log.Debug().
@@ -217,78 +332,267 @@ func (s *EventService) ProduceEvent(ctx context.Context, event *Event) (*Event,
 	// Set ApplicationID field from data
 	event.ApplicationID = appID
 
-	seq, err := s.repo.GetNextSequence(appID)
-	if err != nil {
-		return nil, fmt.Errorf("sequence generation failed: %w", err)
+	if err := s.commitEvent(ctx, event, nil); err != nil {
+		return nil, err
 	}
-	event.SequenceNumber = seq
 
-	event.CreatedAt = time.Now().Unix()
+	s.publishProduced(event)
+	return event, nil
+}
 
-	log.Debug().
+// publishProduced logs a committed server-produced application event and delivers it to
+// WebSocket clients and external integrations
+func (s *EventService) publishProduced(event *Event) {
+	log.Info().
 		Str("eventId", event.ID).
+		Str("type", string(event.Type)).
 		Int64("sequence", event.SequenceNumber).
-		Msg("[EVENT] Persisting to database")
+		Msg("[EVENT] Server-produced event accepted successfully")
 
-	if err := s.repo.Create(event); err != nil {
-		log.Error().
-			Str("eventId", event.ID).
-			Err(err).
-			Msg("[EVENT] Persistence failed")
-		return nil, fmt.Errorf("persistence failed: %w", err)
+	// Broadcast to WebSocket clients
+	s.broadcastEvent(event)
+
+	if s.dispatcher != nil {
+		s.dispatcher.Dispatch(event)
 	}
+}
 
-	log.Debug().
-		Str("eventId", event.ID).
-		Str("type", string(event.Type)).
-		Msg("[EVENT] Executing")
+// ProduceApplicationCreated records the registration of an application as a user-scoped
+// application_created event, sent to the owner's other connected devices. The
+// application itself is already stored, so executing the event changes nothing.
+func (s *EventService) ProduceApplicationCreated(ctx context.Context, appID, ownerPublicKey, name string) error {
+	evt := &Event{
+		ID:               uuid.New().String(),
+		Type:             EventTypeApplicationCreated,
+		CreatorPublicKey: ownerPublicKey,
+		Version:          1,
+		Data: map[string]interface{}{
+			"version":         1,
+			"userPublicKey":   ownerPublicKey,
+			"applicationId":   appID,
+			"applicationName": name,
+		},
+	}
+
+	_, err := s.ProduceEvent(ctx, evt)
+	return err
+}
 
-	// Execute event to update database state
-	if err := s.executeEvent(ctx, event); err != nil {
-		// Log error but don't fail event acceptance
-		// Event is already persisted and sequenced
-		log.Error().
-			Str("eventId", event.ID).
-			Str("type", string(event.Type)).
-			Str("applicationId", event.ApplicationID).
-			Err(err).
-			Msg("[EVENT] Execution failed - event persisted but database state not updated")
-	} else {
-		log.Debug().
-			Str("eventId", event.ID).
-			Str("type", string(event.Type)).
-			Str("applicationId", event.ApplicationID).
-			Msg("[EVENT] Execution complete")
-		s.updateAppVersion(event)
+// ProduceApplicationDeleted deletes the application through an application_deleted event,
+// so the deletion is sequenced and broadcast like one submitted by the owner's client
+func (s *EventService) ProduceApplicationDeleted(ctx context.Context, appID, ownerPublicKey string) error {
+	deletedAt := s.clock.Now().Unix()
+	evt := &Event{
+		ID:               uuid.New().String(),
+		Type:             EventTypeApplicationDeleted,
+		CreatorPublicKey: ownerPublicKey,
+		Version:          1,
+		Data: map[string]interface{}{
+			"version":       1,
+			"applicationId": appID,
+			"deletedAt":     deletedAt,
+		},
+	}
+
+	_, err := s.ProduceEvent(ctx, evt)
+	return err
+}
+
+// ProduceApplicationDataChanged renames the application or changes its icon through an
+// application_data_changed event, so every member syncs the new metadata
+func (s *EventService) ProduceApplicationDataChanged(ctx context.Context, appID, ownerPublicKey, name string, icon *string) error {
+	data := map[string]interface{}{
+		"version":       1,
+		"applicationId": appID,
+		"name":          name,
+	}
+	if icon != nil {
+		data["icon"] = *icon
+	}
+	evt := &Event{
+		ID:               uuid.New().String(),
+		Type:             EventTypeApplicationDataChanged,
+		CreatorPublicKey: ownerPublicKey,
+		Version:          1,
+		Data:             data,
+	}
+
+	_, err := s.ProduceEvent(ctx, evt)
+	return err
+}
+
+// ProduceMemberRoleChanged changes a member's role through a member_role_changed event, so
+// every member syncs the change
+func (s *EventService) ProduceMemberRoleChanged(ctx context.Context, appID, ownerPublicKey, memberPublicKey string, oldRole, newRole application.MemberRole) error {
+	_, err := s.ProduceEvent(ctx, newMemberRoleChangedEvent(appID, ownerPublicKey, memberPublicKey, oldRole, newRole))
+	return err
+}
+
+// ProduceOwnershipTransfer promotes newOwnerPublicKey to owner and demotes the current
+// owner to admin through two member_role_changed events. Both events are committed in one
+// transaction, so other requests never see the application with two owners or none.
+func (s *EventService) ProduceOwnershipTransfer(ctx context.Context, appID, ownerPublicKey, newOwnerPublicKey string, newOwnerRole application.MemberRole) error {
+	events := []*Event{
+		newMemberRoleChangedEvent(appID, ownerPublicKey, newOwnerPublicKey, newOwnerRole, application.MemberRoleOwner),
+		newMemberRoleChangedEvent(appID, ownerPublicKey, ownerPublicKey, application.MemberRoleOwner, application.MemberRoleAdmin),
+	}
+	for _, evt := range events {
+		if err := ValidateEvent(evt); err != nil {
+			return fmt.Errorf("validation failed: %w", err)
+		}
+		evt.ApplicationID = appID
+	}
+
+	err := s.inTx(func(txService *EventService) error {
+		for _, evt := range events {
+			if err := txService.applyEvent(ctx, evt, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, evt := range events {
+		s.publishProduced(evt)
+	}
+	return nil
+}
+
+// ProduceMemberRekeyed moves a member to a new public key through a member_rekeyed event,
+// so members sync the key change and the old key stops receiving the application's events
+func (s *EventService) ProduceMemberRekeyed(ctx context.Context, appID, ownerPublicKey, oldPublicKey, newPublicKey string) error {
+	evt := &Event{
+		ID:               uuid.New().String(),
+		Type:             EventTypeMemberRekeyed,
+		CreatorPublicKey: ownerPublicKey,
+		Version:          1,
+		Data: map[string]interface{}{
+			"version":         1,
+			"applicationId":   appID,
+			"memberPublicKey": oldPublicKey,
+			"newPublicKey":    newPublicKey,
+		},
+	}
+
+	_, err := s.ProduceEvent(ctx, evt)
+	return err
+}
+
+// ProduceMemberAdded adds a member through a member_added event. API tokens register their
+// principal this way, so members and webhooks see the token join.
+func (s *EventService) ProduceMemberAdded(ctx context.Context, appID, ownerPublicKey, memberPublicKey, memberName string, role application.MemberRole) error {
+	evt := &Event{
+		ID:               uuid.New().String(),
+		Type:             EventTypeMemberAdded,
+		CreatorPublicKey: ownerPublicKey,
+		Version:          1,
+		Data: map[string]interface{}{
+			"version":         1,
+			"applicationId":   appID,
+			"memberPublicKey": memberPublicKey,
+			"memberName":      memberName,
+			"role":            string(role),
+		},
+	}
+
+	_, err := s.ProduceEvent(ctx, evt)
+	return err
+}
+
+// ProduceMemberRemoved removes a member through a member_removed event
+func (s *EventService) ProduceMemberRemoved(ctx context.Context, appID, ownerPublicKey, memberPublicKey string) error {
+	evt := &Event{
+		ID:               uuid.New().String(),
+		Type:             EventTypeMemberRemoved,
+		CreatorPublicKey: ownerPublicKey,
+		Version:          1,
+		Data: map[string]interface{}{
+			"version":         1,
+			"applicationId":   appID,
+			"memberPublicKey": memberPublicKey,
+		},
+	}
+
+	_, err := s.ProduceEvent(ctx, evt)
+	return err
+}
+
+func newMemberRoleChangedEvent(appID, ownerPublicKey, memberPublicKey string, oldRole, newRole application.MemberRole) *Event {
+	return &Event{
+		ID:               uuid.New().String(),
+		Type:             EventTypeMemberRoleChanged,
+		CreatorPublicKey: ownerPublicKey,
+		Version:          1,
+		Data: map[string]interface{}{
+			"version":         1,
+			"applicationId":   appID,
+			"memberPublicKey": memberPublicKey,
+			"oldRole":         string(oldRole),
+			"newRole":         string(newRole),
+		},
+	}
+}
+
+// produceUserScopedEvent handles the user-scoped path for server-produced events.
+func (s *EventService) produceUserScopedEvent(ctx context.Context, event *Event) (*Event, error) {
+	if err := s.commitEvent(ctx, event, nil); err != nil {
+		return nil, err
 	}
 
 	log.Info().
 		Str("eventId", event.ID).
 		Str("type", string(event.Type)).
-		Int64("sequence", event.SequenceNumber).
-		Msg("[EVENT] Server-produced event accepted successfully")
+		Msg("[EVENT] Server-produced user-scoped event accepted successfully")
 
-	// Broadcast to WebSocket clients
-	s.broadcastEvent(event)
+	if s.broadcaster != nil {
+		s.broadcaster.BroadcastToUser(event.CreatorPublicKey, event)
+	}
 
 	return event, nil
 }
 
-// produceUserScopedEvent handles the user-scoped path for server-produced events.
-func (s *EventService) produceUserScopedEvent(ctx context.Context, event *Event) (*Event, error) {
+// commitEvent sequences (application events only), persists and executes the event in
+// one database transaction, replacing previous when compacting. A failed execution rolls
+// the transaction back, so the event log never holds an event whose changes were not
+// applied.
+func (s *EventService) commitEvent(ctx context.Context, event, previous *Event) error {
+	return s.inTx(func(txService *EventService) error {
+		return txService.applyEvent(ctx, event, previous)
+	})
+}
+
+// applyEvent sequences, persists and executes the event on a service bound to a transaction
+func (s *EventService) applyEvent(ctx context.Context, event, previous *Event) error {
+	// User-scoped events have no sequence number
 	event.SequenceNumber = 0
-	event.CreatedAt = time.Now().Unix()
+	if !IsUserScoped(event.Type) {
+		seq, err := s.repo.GetNextSequence(event.ApplicationID)
+		if err != nil {
+			return fmt.Errorf("sequence generation failed: %w", err)
+		}
+		event.SequenceNumber = seq
+	}
+	event.CreatedAt = s.clock.Now().Unix()
 
 	log.Debug().
 		Str("eventId", event.ID).
-		Msg("[EVENT] Persisting user-scoped server-produced event to database")
+		Int64("sequence", event.SequenceNumber).
+		Msg("[EVENT] Persisting to database")
 
-	if err := s.repo.Create(event); err != nil {
+	if err := s.persistCompacted(event, previous); err != nil {
 		log.Error().
 			Str("eventId", event.ID).
 			Err(err).
 			Msg("[EVENT] Persistence failed")
-		return nil, fmt.Errorf("persistence failed: %w", err)
+		return fmt.Errorf("persistence failed: %w", err)
+	}
+	if !IsUserScoped(event.Type) && previous == nil {
+		if err := s.repo.IncrementEventCount(event.ApplicationID); err != nil {
+			return fmt.Errorf("persistence failed: %w", err)
+		}
 	}
 
 	log.Debug().
@@ -300,34 +604,90 @@ func (s *EventService) produceUserScopedEvent(ctx context.Context, event *Event)
 		log.Error().
 			Str("eventId", event.ID).
 			Str("type", string(event.Type)).
+			Str("applicationId", event.ApplicationID).
 			Err(err).
-			Msg("[EVENT] Execution failed - event persisted but database state not updated")
-	} else {
-		log.Debug().
-			Str("eventId", event.ID).
-			Str("type", string(event.Type)).
-			Msg("[EVENT] Execution complete")
+			Msg("[EVENT] Execution failed - event rolled back")
+		return fmt.Errorf("%w: %w", ErrExecutionFailed, err)
 	}
 
-	log.Info().
+	log.Debug().
 		Str("eventId", event.ID).
 		Str("type", string(event.Type)).
-		Msg("[EVENT] Server-produced user-scoped event accepted successfully")
+		Msg("[EVENT] Execution complete")
+	if !IsUserScoped(event.Type) {
+		if err := s.updateAppVersion(event); err != nil {
+			return fmt.Errorf("persistence failed: %w", err)
+		}
+	}
+	return nil
+}
 
-	if s.broadcaster != nil {
-		s.broadcaster.BroadcastToUser(event.CreatorPublicKey, event)
+// storedDuplicate resolves a failed commit of a client-submitted event. When the event ID
+// is already stored by the same creator, the submission is a retry and the stored event is
+// returned as accepted, without broadcasting it again. Any other error is returned as is.
+func (s *EventService) storedDuplicate(event *Event, commitErr error) (*Event, error) {
+	if !errors.Is(commitErr, ErrDuplicateEvent) {
+		return nil, commitErr
 	}
 
-	return event, nil
+	stored, err := s.repo.GetByID(event.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stored event: %w", err)
+	}
+	if stored.CreatorPublicKey != event.CreatorPublicKey || stored.Type != event.Type {
+		return nil, fmt.Errorf("%w: event id %s is already in use", ErrValidation, event.ID)
+	}
+
+	log.Info().
+		Str("eventId", stored.ID).
+		Int64("sequence", stored.SequenceNumber).
+		Msg("[EVENT] Duplicate submission - returning stored event")
+	return stored, nil
+}
+
+// inTx runs fn with a copy of the service whose repositories are bound to one database
+// transaction, committing only when fn succeeds
+func (s *EventService) inTx(fn func(txService *EventService) error) error {
+	tx, err := s.repo.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	txService := *s
+	txService.repo = s.repo.WithTx(tx)
+	txService.appRepo = s.appRepo.WithTx(tx)
+	if users, ok := s.users.(txMemberUserStore); ok {
+		txService.users = users.WithTx(tx)
+	}
+	if err := fn(&txService); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
 }
 
 // broadcastEvent sends an event to all relevant WebSocket clients.
 // For application_deleted events, it additionally broadcasts to each member's user channel
 // so all devices receive the deletion regardless of which app they have focused.
+// Members of a deleted application are evicted from its subscribers the same way.
+// A removed member is evicted from the application's subscribers and told of the removal
+// on their user channel instead, so they receive nothing of the application afterwards.
 func (s *EventService) broadcastEvent(event *Event) {
 	if s.broadcaster == nil {
 		return
 	}
+
+	if event.Type == EventTypeMemberRemoved || event.Type == EventTypeMemberRekeyed {
+		if memberPublicKey, ok := event.Data["memberPublicKey"].(string); ok && memberPublicKey != "" {
+			s.broadcaster.EvictUserFromApp(event.ApplicationID, memberPublicKey)
+			s.broadcaster.BroadcastToUser(memberPublicKey, event)
+		}
+	}
+
 	s.broadcaster.BroadcastToApplication(event.ApplicationID, event)
 
 	if event.Type == EventTypeApplicationDeleted {
@@ -337,6 +697,7 @@ func (s *EventService) broadcastEvent(event *Event) {
 		} else {
 			for _, member := range members {
 				s.broadcaster.BroadcastToUser(member.PublicKey, event)
+				s.broadcaster.EvictUserFromApp(event.ApplicationID, member.PublicKey)
 			}
 		}
 	}
@@ -354,6 +715,26 @@ func (s *EventService) GetEventsSince(userPublicKey string, sinceEventID string,
 				AppVersions:        s.loadAppVersions(userPublicKey),
 			}, nil
 		}
+		if errors.Is(err, ErrSinceEventInaccessible) {
+			log.Info().
+				Str("sinceEventId", sinceEventID).
+				Msg("[EVENT] Since cursor belongs to an application the user can no longer access")
+			return &EventsResponse{
+				FullResyncRequired: true,
+				Reason:             "Since event belongs to an application you are no longer a member of",
+				AppVersions:        s.loadAppVersions(userPublicKey),
+			}, nil
+		}
+		if errors.Is(err, ErrSincePurged) {
+			log.Info().
+				Str("sinceEventId", sinceEventID).
+				Msg("[EVENT] Since cursor predates a purge of application events")
+			return &EventsResponse{
+				FullResyncRequired: true,
+				Reason:             "Application events were purged",
+				AppVersions:        s.loadAppVersions(userPublicKey),
+			}, nil
+		}
 		return nil, fmt.Errorf("failed to get events: %w", err)
 	}
 
@@ -371,6 +752,70 @@ func (s *EventService) GetEventsSince(userPublicKey string, sinceEventID string,
 	}, nil
 }
 
+// GetApplicationEventsSince returns the next page of one application's events after
+// sinceEventID for a member of the application. A since event that is gone is reported as
+// requiring a full resync.
+func (s *EventService) GetApplicationEventsSince(userPublicKey, appID, sinceEventID string, limit int) (*EventsResponse, error) {
+	isMember, err := s.appRepo.IsMember(appID, userPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check membership: %w", err)
+	}
+	if !isMember {
+		return nil, fmt.Errorf("%w: not a member of application %s", ErrUnauthorized, appID)
+	}
+
+	events, hasMore, err := s.repo.GetApplicationEventsSince(appID, sinceEventID, limit)
+	if errors.Is(err, ErrEventNotFound) {
+		return &EventsResponse{
+			FullResyncRequired: true,
+			Reason:             "Events expired or gap detected",
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events: %w", err)
+	}
+
+	return &EventsResponse{
+		Events:  events,
+		HasMore: hasMore,
+	}, nil
+}
+
+// GetEvent returns a single event if the requester may read it. Events of applications
+// the requester is not a member of are reported as not found, so event IDs cannot be
+// used to probe other applications.
+func (s *EventService) GetEvent(eventID, requesterPublicKey string) (*Event, error) {
+	event, err := s.repo.GetByID(eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkEventReadAccess(event, requesterPublicKey); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// checkEventReadAccess allows members to read application-scoped events and only the
+// creator to read user-scoped ones
+func (s *EventService) checkEventReadAccess(event *Event, requesterPublicKey string) error {
+	if event.ApplicationID == "" {
+		if event.CreatorPublicKey != requesterPublicKey {
+			return fmt.Errorf("%w: %s", ErrEventNotFound, event.ID)
+		}
+		return nil
+	}
+
+	isMember, err := s.appRepo.IsMember(event.ApplicationID, requesterPublicKey)
+	if err != nil {
+		return fmt.Errorf("failed to check membership: %w", err)
+	}
+	if !isMember {
+		return fmt.Errorf("%w: %s", ErrEventNotFound, event.ID)
+	}
+	return nil
+}
+
 // loadAppVersions fetches the last sequence number for all apps the user is a member of.
 // Uses a lightweight query (id, last_sequence only) to avoid N+1 full-app loads.
 func (s *EventService) loadAppVersions(userPublicKey string) map[string]AppVersion {
@@ -397,18 +842,182 @@ func (s *EventService) loadAppVersions(userPublicKey string) map[string]AppVersi
 }
 
 // updateAppVersion persists the last processed sequence number for an app-scoped event.
-// Errors are logged but do not fail the event pipeline.
-func (s *EventService) updateAppVersion(event *Event) {
+// It runs in the event's transaction, so a failure rolls the event back.
+func (s *EventService) updateAppVersion(event *Event) error {
 	if event.ApplicationID == "" {
-		return
+		return nil
 	}
 	if err := s.appRepo.UpdateLastSequence(event.ApplicationID, event.SequenceNumber); err != nil {
-		log.Error().
-			Str("eventId", event.ID).
-			Str("applicationId", event.ApplicationID).
+		return fmt.Errorf("failed to update app version: %w", err)
+	}
+	return nil
+}
+
+// GetSyncState returns the latest per-application sequence for the user's applications
+func (s *EventService) GetSyncState(userPublicKey string) (*SyncStateResponse, error) {
+	states, err := s.repo.GetSyncState(userPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync state: %w", err)
+	}
+	return &SyncStateResponse{Applications: states}, nil
+}
+
+// GetSyncEstimate returns how many events, and roughly how many bytes, fetching from
+// sinceEventID would transfer per application, so clients can choose between a
+// snapshot and replaying events. A cursor that can no longer be resumed is estimated
+// as a full resync.
+func (s *EventService) GetSyncEstimate(userPublicKey string, sinceEventID string) (*SyncEstimateResponse, error) {
+	response := &SyncEstimateResponse{}
+
+	estimates, err := s.repo.GetSyncEstimate(userPublicKey, sinceEventID)
+	if errors.Is(err, ErrEventNotFound) || errors.Is(err, ErrSinceEventInaccessible) || errors.Is(err, ErrSincePurged) {
+		log.Info().
+			Str("sinceEventId", sinceEventID).
 			Err(err).
-			Msg("[EVENT] Failed to update app version")
+			Msg("[EVENT] Since cursor cannot be resumed, estimating a full resync")
+		response.FullResyncRequired = true
+		estimates, err = s.repo.GetSyncEstimate(userPublicKey, "")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync estimate: %w", err)
+	}
+
+	response.Applications = estimates
+	for _, estimate := range estimates {
+		response.TotalEvents += estimate.EventCount
+		response.TotalBytes += estimate.ApproxBytes
+	}
+	return response, nil
+}
+
+// GetLastComponentChange finds the event that last changed the component (or one field of
+// it) and who submitted it. Only the application owner may look changes up.
+func (s *EventService) GetLastComponentChange(appID, componentID, field, requesterPublicKey string) (*LastChangeResponse, error) {
+	requester, err := s.appRepo.GetMemberByPublicKey(appID, requesterPublicKey)
+	if err != nil || requester == nil || requester.Role != application.MemberRoleOwner {
+		return nil, fmt.Errorf("%w: only the application owner can look up changes", ErrUnauthorized)
+	}
+
+	event, err := s.repo.GetLastComponentChange(appID, componentID, field)
+	if err != nil {
+		return nil, err
+	}
+
+	creator := &ChangeCreator{PublicKey: event.CreatorPublicKey}
+	if member, err := s.appRepo.GetMemberByPublicKey(appID, event.CreatorPublicKey); err == nil && member != nil {
+		creator.Name = member.Name
+		creator.Role = string(member.Role)
+	}
+
+	return &LastChangeResponse{Event: event, Creator: creator}, nil
+}
+
+// PurgeApplicationEvents deletes every stored event of the application for its owner and
+// returns how many were deleted. The application's state and sequence counter are kept, so
+// the snapshot from GET /applications/{id} stays current and new events continue the
+// sequence. The purge is recorded, so clients syncing from a cursor made before it are
+// told to resync from that snapshot.
+func (s *EventService) PurgeApplicationEvents(appID, requesterPublicKey string) (int64, error) {
+	if !s.allowEventPurge {
+		return 0, ErrEventPurgeDisabled
+	}
+
+	requester, err := s.appRepo.GetMemberByPublicKey(appID, requesterPublicKey)
+	if err != nil || requester == nil || requester.Role != application.MemberRoleOwner {
+		return 0, fmt.Errorf("%w: only the application owner can purge events", ErrUnauthorized)
+	}
+
+	var deleted int64
+	err = s.inTx(func(txService *EventService) error {
+		deleted, err = txService.repo.DeleteByApplicationID(appID, s.clock.Now().Unix())
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	log.Info().
+		Str("applicationId", appID).
+		Int64("deletedEvents", deleted).
+		Msg("[EVENT] Purged application events")
+	return deleted, nil
+}
+
+// GetComponentDataHistory returns up to limit earlier data versions of a component, most
+// recent first. Any member of the application may read them.
+func (s *EventService) GetComponentDataHistory(appID, componentID, requesterPublicKey string, limit int) ([]*application.ComponentDataVersion, error) {
+	if isMember, err := s.appRepo.IsMember(appID, requesterPublicKey); err != nil || !isMember {
+		return nil, fmt.Errorf("%w: user is not a member of this application", ErrUnauthorized)
+	}
+
+	component, err := s.appRepo.GetComponentByID(componentID)
+	if err != nil || component.ApplicationID != appID {
+		return nil, fmt.Errorf("%w: component %s not found", ErrComponentVersionNotFound, componentID)
+	}
+
+	return s.appRepo.GetComponentDataHistory(componentID, limit)
+}
+
+// RevertComponentData restores a component's data to an earlier version. The revert is
+// submitted as a component_data_changed event on behalf of the submitter, so it is
+// authorized, sequenced and broadcast like any other change and can itself be reverted.
+func (s *EventService) RevertComponentData(ctx context.Context, appID, componentID, versionID string, submitter *user.User) (*Event, error) {
+	version, err := s.appRepo.GetComponentDataVersion(versionID)
+	if err != nil || version.ComponentID != componentID || version.ApplicationID != appID {
+		return nil, fmt.Errorf("%w: %s", ErrComponentVersionNotFound, versionID)
+	}
+
+	component, err := s.appRepo.GetComponentByID(componentID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: component %s not found", ErrComponentVersionNotFound, componentID)
+	}
+
+	event := &Event{
+		ID:               uuid.New().String(),
+		Type:             EventTypeComponentDataChanged,
+		CreatorPublicKey: submitter.PublicKey,
+		Version:          1,
+		Data: map[string]interface{}{
+			"applicationId":     appID,
+			"componentId":       componentID,
+			"changedFields":     revertChanges(component.Data, version.Data),
+			"revertedToVersion": versionID,
+			"version":           1,
+		},
+	}
+
+	return s.AcceptEvent(ctx, event, submitter)
+}
+
+// revertChanges returns the changedFields that take component data from current back to
+// target. Fields missing from target are set to null.
+func revertChanges(current, target map[string]interface{}) map[string]interface{} {
+	changedFields := make(map[string]interface{})
+	for field, value := range current {
+		if targetValue, ok := target[field]; !ok || !reflect.DeepEqual(value, targetValue) {
+			changedFields[field] = map[string]interface{}{"oldValue": value, "newValue": target[field]}
+		}
+	}
+	for field, targetValue := range target {
+		if _, ok := current[field]; !ok {
+			changedFields[field] = map[string]interface{}{"oldValue": nil, "newValue": targetValue}
+		}
+	}
+	return changedFields
+}
+
+// GetStateVersion returns the application's current state version
+func (s *EventService) GetStateVersion(appID string) (*StateVersion, error) {
+	state, err := s.appRepo.GetApplicationState(appID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get application state: %w", err)
 	}
+
+	version := &StateVersion{UpdatedAt: state.UpdatedAt}
+	if state.LastSequence != nil {
+		version.LastSequence = *state.LastSequence
+	}
+	return version, nil
 }
 
 // CleanupOldEvents deletes events older than the retention period (7 days)
@@ -417,10 +1026,70 @@ func (s *EventService) CleanupOldEvents(retentionDays int) (int64, error) {
 		retentionDays = 7 // Default 7 days
 	}
 
-	cutoffTime := time.Now().AddDate(0, 0, -retentionDays).Unix()
+	cutoffTime := s.clock.Now().AddDate(0, 0, -retentionDays).Unix()
 	return s.repo.DeleteOlderThan(cutoffTime)
 }
 
+// GetDataSizeReport returns the largest stored events and per-type data size distribution
+func (s *EventService) GetDataSizeReport(limit int) (*DataSizeReport, error) {
+	largest, err := s.repo.GetLargestEvents(limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get largest events: %w", err)
+	}
+
+	byType, err := s.repo.GetDataSizeByType()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event sizes by type: %w", err)
+	}
+
+	if largest == nil {
+		largest = []*EventSizeInfo{}
+	}
+	if byType == nil {
+		byType = []*EventTypeSizeStats{}
+	}
+
+	byApplication, err := s.repo.GetEventCountsByApplication(limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event counts by application: %w", err)
+	}
+	if byApplication == nil {
+		byApplication = []*ApplicationEventCount{}
+	}
+
+	return &DataSizeReport{
+		LargestEvents:           largest,
+		ByType:                  byType,
+		ByApplication:           byApplication,
+		MaxEventsPerApplication: s.maxAppEvents,
+	}, nil
+}
+
+// checkEventLimit rejects a client event that was just stored and counted, within its
+// transaction, when the application already held the configured maximum number of events
+// before it. Deleting the application stays possible at the cap. Old events removed by
+// the retention cleanup, or all events removed by a purge, free room for new ones.
+func (s *EventService) checkEventLimit(appID string, eventType EventType) error {
+	if s.maxAppEvents <= 0 || eventType == EventTypeApplicationDeleted {
+		return nil
+	}
+
+	count, err := s.repo.GetEventCount(appID)
+	if err != nil {
+		return fmt.Errorf("event limit check failed: %w", err)
+	}
+	return eventLimitError(count-1, s.maxAppEvents)
+}
+
+// eventLimitError returns ErrEventLimitReached when storing one more event would take the
+// application past max
+func eventLimitError(count, max int64) error {
+	if max > 0 && count >= max {
+		return fmt.Errorf("%w: application already stores %d of %d allowed events; new events are accepted again once old events are cleaned up or the owner purges the application's events", ErrEventLimitReached, count, max)
+	}
+	return nil
+}
+
 // executeEvent executes an event by updating the database state
 func (s *EventService) executeEvent(ctx context.Context, event *Event) error {
 	log.Debug().
@@ -441,6 +1110,9 @@ func (s *EventService) executeEvent(ctx context.Context, event *Event) error {
 	case "member_role_changed":
 		log.Debug().Str("eventId", event.ID).Msg("[EVENT] Handler: member_role_changed")
 		return s.executeMemberRoleChanged(ctx, event)
+	case EventTypeMemberRekeyed:
+		log.Debug().Str("eventId", event.ID).Msg("[EVENT] Handler: member_rekeyed")
+		return s.executeMemberRekeyed(ctx, event)
 	case "component_data_changed":
 		log.Debug().Str("eventId", event.ID).Msg("[EVENT] Handler: component_data_changed")
 		return s.executeComponentDataChanged(ctx, event)
@@ -457,10 +1129,14 @@ func (s *EventService) executeEvent(ctx context.Context, event *Event) error {
 	case EventTypeApplicationDataChanged:
 		log.Debug().Str("eventId", event.ID).Msg("[EVENT] Handler: application_data_changed")
 		return s.executeApplicationDataChanged(ctx, event)
-	case "application_created":
-		// No server-side state change: application was already registered by the creator device
+	case EventTypeApplicationCreated:
+		// No server-side state change: the application was registered before the event was produced
 		log.Debug().Str("eventId", event.ID).Msg("[EVENT] Handler: application_created (no-op)")
 		return nil
+	case EventTypeInviteRevoked:
+		// Invitation was already deleted by the invitation service; event is for client sync only
+		log.Debug().Str("eventId", event.ID).Msg("[EVENT] Handler: invite_revoked (no-op)")
+		return nil
 	case EventTypeApplicationFileCreated, EventTypeApplicationFileDeleted:
 		// Storage already managed by storage service; event is for client sync only
 		log.Debug().Str("eventId", event.ID).Msg("[EVENT] Handler: application_file event (no-op)")
@@ -497,6 +1173,10 @@ func (s *EventService) executeMemberAdded(ctx context.Context, event *Event) err
 		roleStr = "member" // Default role
 	}
 
+	if err := s.ensureMemberUser(event); err != nil {
+		return err
+	}
+
 	member := &application.Member{
 		ID:            uuid.New().String(),
 		ApplicationID: appID,
@@ -508,6 +1188,48 @@ func (s *EventService) executeMemberAdded(ctx context.Context, event *Event) err
 	return s.appRepo.CreateMember(member)
 }
 
+// ensureMemberUser applies the member user policy to a member_added event, creating or
+// requiring a user account for the member's public key. API token principals never have a
+// user account and are exempt.
+func (s *EventService) ensureMemberUser(event *Event) error {
+	if s.users == nil || s.memberUserPolicy == "" || s.memberUserPolicy == MemberUserPolicyNone {
+		return nil
+	}
+
+	memberPublicKey, _ := event.Data["memberPublicKey"].(string)
+	memberName, _ := event.Data["memberName"].(string)
+	if memberPublicKey == "" {
+		return fmt.Errorf("%w: missing memberPublicKey in member_added event", ErrValidation)
+	}
+	if apitoken.IsPrincipal(memberPublicKey) {
+		return nil
+	}
+
+	existing, err := s.users.GetUserByPublicKey(memberPublicKey)
+	if err != nil {
+		return fmt.Errorf("failed to look up member user: %w", err)
+	}
+	if existing != nil {
+		return nil
+	}
+
+	if s.memberUserPolicy == MemberUserPolicyReject {
+		return fmt.Errorf("%w: member has no user account", ErrValidation)
+	}
+
+	log.Debug().
+		Str("eventId", event.ID).
+		Str("memberPublicKey", memberPublicKey[:min(20, len(memberPublicKey))]+"...").
+		Msg("[EVENT] Creating user for added member")
+
+	return user.CreateMemberUser(s.users, &user.User{
+		PublicKey: memberPublicKey,
+		Username:  memberName,
+		Role:      "member",
+		CreatedAt: s.clock.Now().Unix(),
+	})
+}
+
 // executeMemberRemoved deletes a member record from the database
 func (s *EventService) executeMemberRemoved(ctx context.Context, event *Event) error {
 	appID, ok := event.Data["applicationId"].(string)
@@ -525,6 +1247,10 @@ func (s *EventService) executeMemberRemoved(ctx context.Context, event *Event) e
 		Str("memberPublicKey", memberPublicKey[:20]+"...").
 		Msg("[MEMBER_REMOVED] Executing member_removed event - looking up member")
 
+	if err := s.checkOwnerRemains(appID, event); err != nil {
+		return err
+	}
+
 	// Get member by publicKey to get the member ID
 	member, err := s.appRepo.GetMemberByPublicKey(appID, memberPublicKey)
 	if err != nil {
@@ -606,6 +1332,20 @@ func (s *EventService) executeApplicationDeleted(ctx context.Context, event *Eve
 	return s.appRepo.DeleteApplication(appID)
 }
 
+// checkOwnerRemains checks the application's current members, since the members seen at
+// authorization may have changed by the time the event executes
+func (s *EventService) checkOwnerRemains(appID string, event *Event) error {
+	members, err := s.appRepo.GetMembersByApplicationID(appID)
+	if err != nil {
+		return fmt.Errorf("failed to load members: %w", err)
+	}
+	values := make([]application.Member, len(members))
+	for i, member := range members {
+		values[i] = *member
+	}
+	return checkOwnerRemains(event, values)
+}
+
 // executeMemberRoleChanged updates a member's role in the database
 func (s *EventService) executeMemberRoleChanged(ctx context.Context, event *Event) error {
 	appID, ok := event.Data["applicationId"].(string)
@@ -623,6 +1363,10 @@ func (s *EventService) executeMemberRoleChanged(ctx context.Context, event *Even
 		return fmt.Errorf("missing newRole in member_role_changed event")
 	}
 
+	if err := s.checkOwnerRemains(appID, event); err != nil {
+		return err
+	}
+
 	// Get member by publicKey
 	member, err := s.appRepo.GetMemberByPublicKey(appID, memberPublicKey)
 	if err != nil {
@@ -634,6 +1378,40 @@ func (s *EventService) executeMemberRoleChanged(ctx context.Context, event *Even
 	return s.appRepo.UpdateMember(member)
 }
 
+// executeMemberRekeyed moves a member to a new public key, keeping its ID, name and role
+func (s *EventService) executeMemberRekeyed(ctx context.Context, event *Event) error {
+	appID, ok := event.Data["applicationId"].(string)
+	if !ok || appID == "" {
+		return fmt.Errorf("missing applicationId in member_rekeyed event")
+	}
+
+	memberPublicKey, ok := event.Data["memberPublicKey"].(string)
+	if !ok || memberPublicKey == "" {
+		return fmt.Errorf("missing memberPublicKey in member_rekeyed event")
+	}
+
+	newPublicKey, ok := event.Data["newPublicKey"].(string)
+	if !ok || newPublicKey == "" {
+		return fmt.Errorf("missing newPublicKey in member_rekeyed event")
+	}
+
+	isMember, err := s.appRepo.IsMember(appID, newPublicKey)
+	if err != nil {
+		return fmt.Errorf("failed to check membership: %w", err)
+	}
+	if isMember {
+		return fmt.Errorf("%w: new public key is already a member", application.ErrMemberExists)
+	}
+
+	member, err := s.appRepo.GetMemberByPublicKey(appID, memberPublicKey)
+	if err != nil || member == nil {
+		return fmt.Errorf("member not found: %w", err)
+	}
+
+	member.PublicKey = newPublicKey
+	return s.appRepo.UpdateMember(member)
+}
+
 // executeComponentDataChanged applies delta changes to a component's data
 func (s *EventService) executeComponentDataChanged(ctx context.Context, event *Event) error {
 	componentID, ok := event.Data["componentId"].(string)
@@ -652,6 +1430,8 @@ func (s *EventService) executeComponentDataChanged(ctx context.Context, event *E
 		return fmt.Errorf("component not found: %w", err)
 	}
 
+	previous := copyComponentData(component.Data)
+
 	// Apply delta: extract newValue from each field change
 	if component.Data == nil {
 		component.Data = make(map[string]interface{})
@@ -667,10 +1447,15 @@ func (s *EventService) executeComponentDataChanged(ctx context.Context, event *E
 	}
 
 	// Update component data in database
-	return s.appRepo.UpdateComponentData(componentID, component.Data)
+	if err := s.appRepo.UpdateComponentData(componentID, component.Data); err != nil {
+		return err
+	}
+
+	return s.recordComponentDataVersion(component, previous, event)
 }
 
-// executeApplicationAfterEditModeChanged applies a batch of structural changes
+// executeApplicationAfterEditModeChanged applies a batch of structural changes. Any
+// change that can't be applied fails the whole batch so it rolls back.
 func (s *EventService) executeApplicationAfterEditModeChanged(ctx context.Context, event *Event) error {
 	changesRaw, ok := event.Data["changes"].([]interface{})
 	if !ok {
@@ -690,35 +1475,35 @@ func (s *EventService) executeApplicationAfterEditModeChanged(ctx context.Contex
 
 		switch changeType {
 		case "component_added":
-			if err := s.executeComponentAdded(change); err != nil {
-				log.Error().Err(err).Str("entityId", entityID).Msg("[EDIT_MODE] Failed to add component")
+			if err := s.executeComponentAdded(event.ApplicationID, change); err != nil {
+				return fmt.Errorf("change %d: %w", i, err)
 			}
 		case "component_removed":
 			if err := s.appRepo.DeleteComponent(entityID); err != nil {
-				log.Error().Err(err).Str("entityId", entityID).Msg("[EDIT_MODE] Failed to remove component")
+				return fmt.Errorf("change %d: %w", i, err)
 			}
 		case "component_reordered":
 			if indexRaw, ok := change["index"].(float64); ok {
 				if err := s.appRepo.UpdateComponentIndex(entityID, int(indexRaw)); err != nil {
-					log.Error().Err(err).Str("entityId", entityID).Msg("[EDIT_MODE] Failed to reorder component")
+					return fmt.Errorf("change %d: %w", i, err)
 				}
 			}
 		case "component_data_changed":
-			if err := s.executeComponentDataDelta(entityID, change); err != nil {
-				log.Error().Err(err).Str("entityId", entityID).Msg("[EDIT_MODE] Failed to update component data")
+			if err := s.executeComponentDataDelta(event, entityID, change); err != nil {
+				return fmt.Errorf("change %d: %w", i, err)
 			}
 		case "component_group_added":
-			if err := s.executeComponentGroupAdded(change); err != nil {
-				log.Error().Err(err).Str("entityId", entityID).Msg("[EDIT_MODE] Failed to add component group")
+			if err := s.executeComponentGroupAdded(event.ApplicationID, change); err != nil {
+				return fmt.Errorf("change %d: %w", i, err)
 			}
 		case "component_group_removed":
 			if err := s.appRepo.DeleteComponentGroup(entityID); err != nil {
-				log.Error().Err(err).Str("entityId", entityID).Msg("[EDIT_MODE] Failed to remove component group")
+				return fmt.Errorf("change %d: %w", i, err)
 			}
 		case "component_group_reordered":
 			if indexRaw, ok := change["index"].(float64); ok {
 				if err := s.appRepo.UpdateComponentGroupIndex(entityID, int(indexRaw)); err != nil {
-					log.Error().Err(err).Str("entityId", entityID).Msg("[EDIT_MODE] Failed to reorder component group")
+					return fmt.Errorf("change %d: %w", i, err)
 				}
 			}
 		default:
@@ -732,17 +1517,31 @@ func (s *EventService) executeApplicationAfterEditModeChanged(ctx context.Contex
 	return nil
 }
 
-// executeComponentAdded creates a new component from change data
-func (s *EventService) executeComponentAdded(change map[string]interface{}) error {
+// executeComponentAdded creates a new component from change data. The referenced group
+// must exist in the event's application, so a client can neither create orphaned
+// components nor attach them to another application's group.
+func (s *EventService) executeComponentAdded(appID string, change map[string]interface{}) error {
 	data, ok := change["data"].(map[string]interface{})
 	if !ok {
 		return fmt.Errorf("missing data for component_added")
 	}
 
+	groupID := getString(data, "componentGroupId")
+	group, err := s.appRepo.GetComponentGroupByID(groupID)
+	if err != nil || group == nil {
+		return fmt.Errorf("component group %s not found", groupID)
+	}
+	if group.ApplicationID != appID {
+		return fmt.Errorf("component group %s does not belong to application %s", groupID, appID)
+	}
+	if dataAppID := getString(data, "applicationId"); dataAppID != "" && dataAppID != appID {
+		return fmt.Errorf("component applicationId %s does not match event application %s", dataAppID, appID)
+	}
+
 	component := &application.Component{
 		ID:               getString(data, "id"),
-		ComponentGroupID: getString(data, "componentGroupId"),
-		ApplicationID:    getString(data, "applicationId"),
+		ComponentGroupID: groupID,
+		ApplicationID:    appID,
 		Name:             getString(data, "name"),
 		Index:            getInt(data, "index"),
 	}
@@ -754,16 +1553,21 @@ func (s *EventService) executeComponentAdded(change map[string]interface{}) erro
 	return s.appRepo.CreateComponent(component)
 }
 
-// executeComponentGroupAdded creates a new component group from change data
-func (s *EventService) executeComponentGroupAdded(change map[string]interface{}) error {
+// executeComponentGroupAdded creates a new component group in the event's application.
+// A client-supplied applicationId must match it, so a group can't be planted in another
+// application.
+func (s *EventService) executeComponentGroupAdded(appID string, change map[string]interface{}) error {
 	data, ok := change["data"].(map[string]interface{})
 	if !ok {
 		return fmt.Errorf("missing data for component_group_added")
 	}
+	if dataAppID := getString(data, "applicationId"); dataAppID != "" && dataAppID != appID {
+		return fmt.Errorf("component group applicationId %s does not match event application %s", dataAppID, appID)
+	}
 
 	group := &application.ComponentGroup{
 		ID:            getString(data, "id"),
-		ApplicationID: getString(data, "applicationId"),
+		ApplicationID: appID,
 		Name:          getString(data, "name"),
 		Index:         getInt(data, "index"),
 	}
@@ -772,7 +1576,7 @@ func (s *EventService) executeComponentGroupAdded(change map[string]interface{})
 }
 
 // executeComponentDataDelta applies delta changes to a component from a structure change
-func (s *EventService) executeComponentDataDelta(componentID string, change map[string]interface{}) error {
+func (s *EventService) executeComponentDataDelta(event *Event, componentID string, change map[string]interface{}) error {
 	changedFieldsRaw, ok := change["changedFields"].(map[string]interface{})
 	if !ok {
 		return fmt.Errorf("missing changedFields for component_data_changed")
@@ -784,6 +1588,8 @@ func (s *EventService) executeComponentDataDelta(componentID string, change map[
 		return err
 	}
 
+	previous := copyComponentData(component.Data)
+
 	// Apply delta
 	if component.Data == nil {
 		component.Data = make(map[string]interface{})
@@ -797,7 +1603,45 @@ func (s *EventService) executeComponentDataDelta(componentID string, change map[
 		component.Data[fieldName] = fieldChange["newValue"]
 	}
 
-	return s.appRepo.UpdateComponentData(componentID, component.Data)
+	if err := s.appRepo.UpdateComponentData(componentID, component.Data); err != nil {
+		return err
+	}
+
+	return s.recordComponentDataVersion(component, previous, event)
+}
+
+// recordComponentDataVersion keeps the component's data from before the event in its
+// history. It runs in the event's transaction, where a failed statement aborts every later
+// one, so a failure fails the change rather than surfacing as a commit error.
+func (s *EventService) recordComponentDataVersion(component *application.Component, previous map[string]interface{}, event *Event) error {
+	if s.historyDepth <= 0 {
+		return nil
+	}
+
+	version := &application.ComponentDataVersion{
+		ID:                 uuid.New().String(),
+		ComponentID:        component.ID,
+		ApplicationID:      component.ApplicationID,
+		Data:               previous,
+		EventID:            event.ID,
+		SequenceNumber:     event.SequenceNumber,
+		ChangedByPublicKey: event.CreatorPublicKey,
+		CreatedAt:          s.clock.Now().Unix(),
+	}
+	if err := s.appRepo.AddComponentDataVersion(version, s.historyDepth); err != nil {
+		return fmt.Errorf("failed to record component data history: %w", err)
+	}
+	return nil
+}
+
+// copyComponentData returns a copy of the top-level data map, since changes replace
+// whole top-level fields
+func copyComponentData(data map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		copied[key] = value
+	}
+	return copied
 }
 
 // Helper functions for safe type extraction
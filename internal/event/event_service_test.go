@@ -0,0 +1,856 @@
+package event
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prappser/prappser_server/internal/application"
+	"github.com/prappser/prappser_server/internal/clock"
+	"github.com/prappser/prappser_server/internal/user"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestSubmitter() *user.User {
+	return &user.User{PublicKey: "submitter-public-key", Username: "submitter", Role: "owner"}
+}
+
+func TestAcceptEvent_ShouldRejectServerOnlyUserScopedType(t *testing.T) {
+	// given
+	service := NewEventService(nil, application.NewMemoryRepository(), nil, nil, nil, Config{ServerOnlyTypes: DefaultServerOnlyTypes})
+	submitter := createTestSubmitter()
+	forged := &Event{
+		ID:               "event-1",
+		Type:             EventTypeApplicationCreated,
+		CreatorPublicKey: submitter.PublicKey,
+		Data: map[string]interface{}{
+			"applicationId":   "app-1",
+			"applicationName": "Forged App",
+			"userPublicKey":   submitter.PublicKey,
+		},
+	}
+
+	// when
+	_, err := service.AcceptEvent(context.Background(), forged, submitter)
+
+	// then
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+}
+
+func TestAcceptEvent_ShouldRejectServerOnlyApplicationScopedType(t *testing.T) {
+	// given
+	service := NewEventService(nil, application.NewMemoryRepository(), nil, nil, nil, Config{ServerOnlyTypes: DefaultServerOnlyTypes})
+	submitter := createTestSubmitter()
+	forged := &Event{
+		ID:               "event-1",
+		Type:             EventTypeInviteRevoked,
+		CreatorPublicKey: submitter.PublicKey,
+		Data: map[string]interface{}{
+			"applicationId": "app-1",
+			"inviteId":      "invite-1",
+		},
+	}
+
+	// when
+	_, err := service.AcceptEvent(context.Background(), forged, submitter)
+
+	// then
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+}
+
+func TestIsClientSubmittable_ShouldFollowConfiguredServerOnlyTypes(t *testing.T) {
+	// given
+	service := NewEventService(nil, nil, nil, nil, nil, Config{ServerOnlyTypes: []EventType{EventTypeInviteRevoked}})
+
+	// when / then
+	assert.False(t, service.IsClientSubmittable(EventTypeInviteRevoked))
+	assert.True(t, service.IsClientSubmittable(EventTypeApplicationCreated))
+	assert.True(t, service.IsClientSubmittable(EventTypeComponentDataChanged))
+}
+
+func TestGetStateVersion_ShouldReflectAdvancedSequence(t *testing.T) {
+	// given
+	appRepo := application.NewMemoryRepository()
+	appRepo.CreateApplication(&application.Application{ID: "app-1", Name: "App"})
+	service := NewEventService(nil, appRepo, nil, nil, nil, Config{})
+	appRepo.UpdateLastSequence("app-1", 7)
+
+	// when
+	version, err := service.GetStateVersion("app-1")
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), version.LastSequence)
+	assert.NotZero(t, version.UpdatedAt)
+}
+
+func TestAcceptEvent_ShouldRedactConfiguredFieldsInLogs(t *testing.T) {
+	// given
+	var buf bytes.Buffer
+	originalLogger, originalLevel := log.Logger, zerolog.GlobalLevel()
+	log.Logger = zerolog.New(&buf)
+	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	defer func() {
+		log.Logger = originalLogger
+		zerolog.SetGlobalLevel(originalLevel)
+	}()
+
+	service := NewEventService(nil, application.NewMemoryRepository(), nil, nil, nil, Config{
+		ServerOnlyTypes:   DefaultServerOnlyTypes,
+		LogRedactedFields: []string{"applicationName"},
+	})
+	submitter := createTestSubmitter()
+	event := &Event{
+		ID:               "event-1",
+		Type:             EventTypeApplicationCreated,
+		CreatorPublicKey: submitter.PublicKey,
+		Data: map[string]interface{}{
+			"applicationId":   "app-1",
+			"applicationName": "Secret Project",
+			"userPublicKey":   submitter.PublicKey,
+		},
+	}
+
+	// when
+	service.AcceptEvent(context.Background(), event, submitter)
+
+	// then
+	output := buf.String()
+	assert.Contains(t, output, "[EVENT] Payload")
+	assert.Contains(t, output, "app-1")
+	assert.NotContains(t, output, "Secret Project")
+	assert.Equal(t, "Secret Project", event.Data["applicationName"])
+}
+
+func TestRedactData_ShouldHashNestedFields(t *testing.T) {
+	// given
+	fields := map[string]bool{"newValue": true, "memberName": true}
+	data := map[string]interface{}{
+		"componentId": "component-1",
+		"memberName":  "Alice",
+		"changedFields": map[string]interface{}{
+			"title": map[string]interface{}{"newValue": "private note"},
+		},
+		"changes": []interface{}{
+			map[string]interface{}{"newValue": "private note"},
+		},
+	}
+
+	// when
+	redacted := RedactData(data, fields)
+
+	// then
+	assert.Equal(t, "component-1", redacted["componentId"])
+	assert.NotEqual(t, "Alice", redacted["memberName"])
+	title := redacted["changedFields"].(map[string]interface{})["title"].(map[string]interface{})
+	change := redacted["changes"].([]interface{})[0].(map[string]interface{})
+	assert.NotEqual(t, "private note", title["newValue"])
+	assert.Equal(t, title["newValue"], change["newValue"])
+	assert.Equal(t, "private note", data["changedFields"].(map[string]interface{})["title"].(map[string]interface{})["newValue"])
+}
+
+func createComponentAddedChange(groupID string) map[string]interface{} {
+	return map[string]interface{}{
+		"changeType": "component_added",
+		"entityType": "component",
+		"entityId":   "component-1",
+		"data": map[string]interface{}{
+			"id":               "component-1",
+			"componentGroupId": groupID,
+			"name":             "Component",
+			"index":            float64(0),
+		},
+	}
+}
+
+func TestExecuteComponentAdded_ShouldCreateComponentInOwnGroup(t *testing.T) {
+	// given
+	appRepo := application.NewMemoryRepository()
+	appRepo.CreateComponentGroup(&application.ComponentGroup{ID: "group-1", ApplicationID: "app-1", Name: "Group"})
+	service := NewEventService(nil, appRepo, nil, nil, nil, Config{})
+
+	// when
+	err := service.executeComponentAdded("app-1", createComponentAddedChange("group-1"))
+
+	// then
+	assert.NoError(t, err)
+	component, err := appRepo.GetComponentByID("component-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "app-1", component.ApplicationID)
+}
+
+func createOwnedTestApplication() *application.MemoryRepository {
+	appRepo := application.NewMemoryRepository()
+	appRepo.CreateApplication(&application.Application{ID: "app-1", Name: "App"})
+	appRepo.CreateMember(&application.Member{ID: "member-1", ApplicationID: "app-1", Name: "owner", Role: application.MemberRoleOwner, PublicKey: "owner-public-key-0123456789"})
+	appRepo.CreateMember(&application.Member{ID: "member-2", ApplicationID: "app-1", Name: "member", Role: application.MemberRoleMember, PublicKey: "member-public-key-0123456789"})
+	return appRepo
+}
+
+func TestExecuteMemberRemoved_ShouldKeepLastOwner(t *testing.T) {
+	// given
+	appRepo := createOwnedTestApplication()
+	service := NewEventService(nil, appRepo, nil, nil, nil, Config{})
+	ev := &Event{
+		Type: EventTypeMemberRemoved,
+		Data: map[string]interface{}{"applicationId": "app-1", "memberPublicKey": "owner-public-key-0123456789"},
+	}
+
+	// when
+	err := service.executeMemberRemoved(context.Background(), ev)
+
+	// then
+	assert.ErrorIs(t, err, application.ErrLastOwner)
+	isMember, _ := appRepo.IsMember("app-1", "owner-public-key-0123456789")
+	assert.True(t, isMember)
+}
+
+func TestExecuteMemberRoleChanged_ShouldKeepLastOwner(t *testing.T) {
+	// given
+	appRepo := createOwnedTestApplication()
+	service := NewEventService(nil, appRepo, nil, nil, nil, Config{})
+	ev := &Event{
+		Type: EventTypeMemberRoleChanged,
+		Data: map[string]interface{}{"applicationId": "app-1", "memberPublicKey": "owner-public-key-0123456789", "oldRole": "owner", "newRole": "viewer"},
+	}
+
+	// when
+	err := service.executeMemberRoleChanged(context.Background(), ev)
+
+	// then
+	assert.ErrorIs(t, err, application.ErrLastOwner)
+	member, _ := appRepo.GetMemberByPublicKey("app-1", "owner-public-key-0123456789")
+	assert.Equal(t, application.MemberRoleOwner, member.Role)
+}
+
+// memberUserStore is an in-memory MemberUserStore
+type memberUserStore map[string]*user.User
+
+func (m memberUserStore) GetUserByPublicKey(publicKey string) (*user.User, error) {
+	return m[publicKey], nil
+}
+
+func (m memberUserStore) CreateUser(u *user.User) error {
+	m[u.PublicKey] = u
+	return nil
+}
+
+func createMemberAddedEvent() *Event {
+	return &Event{
+		Type: EventTypeMemberAdded,
+		Data: map[string]interface{}{
+			"applicationId":   "app-1",
+			"memberPublicKey": "member-public-key-0123456789",
+			"memberName":      "Alice",
+			"role":            "member",
+		},
+	}
+}
+
+func TestExecuteMemberRekeyed_ShouldMoveMemberToNewKey(t *testing.T) {
+	// given
+	appRepo := createOwnedTestApplication()
+	appRepo.CreateMember(&application.Member{ID: "member-2", ApplicationID: "app-1", Name: "alice", Role: application.MemberRoleAdmin, PublicKey: "old-public-key-0123456789"})
+	service := NewEventService(nil, appRepo, nil, nil, nil, Config{})
+	event := &Event{
+		Type: EventTypeMemberRekeyed,
+		Data: map[string]interface{}{
+			"applicationId":   "app-1",
+			"memberPublicKey": "old-public-key-0123456789",
+			"newPublicKey":    "new-public-key-0123456789",
+		},
+	}
+
+	// when
+	err := service.executeMemberRekeyed(context.Background(), event)
+
+	// then
+	assert.NoError(t, err)
+	member, _ := appRepo.GetMemberByPublicKey("app-1", "new-public-key-0123456789")
+	if assert.NotNil(t, member) {
+		assert.Equal(t, "member-2", member.ID)
+		assert.Equal(t, application.MemberRoleAdmin, member.Role)
+	}
+	isMember, _ := appRepo.IsMember("app-1", "old-public-key-0123456789")
+	assert.False(t, isMember)
+}
+
+func TestExecuteMemberRekeyed_ShouldRejectKeyOfExistingMember(t *testing.T) {
+	// given
+	appRepo := createOwnedTestApplication()
+	appRepo.CreateMember(&application.Member{ID: "member-2", ApplicationID: "app-1", Name: "alice", Role: application.MemberRoleMember, PublicKey: "old-public-key-0123456789"})
+	service := NewEventService(nil, appRepo, nil, nil, nil, Config{})
+	event := &Event{
+		Type: EventTypeMemberRekeyed,
+		Data: map[string]interface{}{
+			"applicationId":   "app-1",
+			"memberPublicKey": "old-public-key-0123456789",
+			"newPublicKey":    "owner-public-key-0123456789",
+		},
+	}
+
+	// when
+	err := service.executeMemberRekeyed(context.Background(), event)
+
+	// then
+	assert.ErrorIs(t, err, application.ErrMemberExists)
+}
+
+func TestExecuteMemberAdded_ShouldCreateMissingUserWithCreatePolicy(t *testing.T) {
+	// given
+	appRepo := application.NewMemoryRepository()
+	users := memberUserStore{}
+	service := NewEventService(nil, appRepo, users, nil, nil, Config{MemberUserPolicy: MemberUserPolicyCreate})
+
+	// when
+	err := service.executeMemberAdded(context.Background(), createMemberAddedEvent())
+
+	// then
+	assert.NoError(t, err)
+	created := users["member-public-key-0123456789"]
+	if assert.NotNil(t, created) {
+		assert.Equal(t, "Alice", created.Username)
+		assert.Equal(t, "member", created.Role)
+	}
+	isMember, _ := appRepo.IsMember("app-1", "member-public-key-0123456789")
+	assert.True(t, isMember)
+}
+
+func TestExecuteMemberAdded_ShouldRejectMissingUserWithRejectPolicy(t *testing.T) {
+	// given
+	appRepo := application.NewMemoryRepository()
+	users := memberUserStore{}
+	service := NewEventService(nil, appRepo, users, nil, nil, Config{MemberUserPolicy: MemberUserPolicyReject})
+
+	// when
+	err := service.executeMemberAdded(context.Background(), createMemberAddedEvent())
+
+	// then
+	assert.ErrorIs(t, err, ErrValidation)
+	assert.Empty(t, users)
+	isMember, _ := appRepo.IsMember("app-1", "member-public-key-0123456789")
+	assert.False(t, isMember)
+}
+
+func TestExecuteMemberAdded_ShouldAcceptExistingUserWithRejectPolicy(t *testing.T) {
+	// given
+	appRepo := application.NewMemoryRepository()
+	users := memberUserStore{"member-public-key-0123456789": {PublicKey: "member-public-key-0123456789"}}
+	service := NewEventService(nil, appRepo, users, nil, nil, Config{MemberUserPolicy: MemberUserPolicyReject})
+
+	// when
+	err := service.executeMemberAdded(context.Background(), createMemberAddedEvent())
+
+	// then
+	assert.NoError(t, err)
+	isMember, _ := appRepo.IsMember("app-1", "member-public-key-0123456789")
+	assert.True(t, isMember)
+}
+
+func TestExecuteMemberAdded_ShouldAddAPITokenPrincipalWithRejectPolicy(t *testing.T) {
+	// given
+	appRepo := application.NewMemoryRepository()
+	users := memberUserStore{}
+	service := NewEventService(nil, appRepo, users, nil, nil, Config{MemberUserPolicy: MemberUserPolicyReject})
+	event := createMemberAddedEvent()
+	event.Data["memberPublicKey"] = "apitoken:token-1"
+
+	// when
+	err := service.executeMemberAdded(context.Background(), event)
+
+	// then
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+	isMember, _ := appRepo.IsMember("app-1", "apitoken:token-1")
+	assert.True(t, isMember)
+}
+
+func TestPurgeApplicationEvents_ShouldRejectWhenDisabled(t *testing.T) {
+	// given
+	service := NewEventService(nil, createOwnedTestApplication(), nil, nil, nil, Config{})
+
+	// when
+	_, err := service.PurgeApplicationEvents("app-1", "owner-public-key-0123456789")
+
+	// then
+	assert.ErrorIs(t, err, ErrEventPurgeDisabled)
+}
+
+func TestPurgeApplicationEvents_ShouldRejectNonOwner(t *testing.T) {
+	// given
+	service := NewEventService(nil, createOwnedTestApplication(), nil, nil, nil, Config{AllowEventPurge: true})
+
+	// when
+	_, err := service.PurgeApplicationEvents("app-1", "member-public-key-0123456789")
+
+	// then
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestExecuteComponentAdded_ShouldRejectMissingGroup(t *testing.T) {
+	// given
+	appRepo := application.NewMemoryRepository()
+	service := NewEventService(nil, appRepo, nil, nil, nil, Config{})
+
+	// when
+	err := service.executeComponentAdded("app-1", createComponentAddedChange("missing-group"))
+
+	// then
+	assert.Error(t, err)
+	_, lookupErr := appRepo.GetComponentByID("component-1")
+	assert.Error(t, lookupErr)
+}
+
+func TestExecuteComponentAdded_ShouldRejectGroupOfAnotherApplication(t *testing.T) {
+	// given
+	appRepo := application.NewMemoryRepository()
+	appRepo.CreateComponentGroup(&application.ComponentGroup{ID: "foreign-group", ApplicationID: "app-2", Name: "Group"})
+	service := NewEventService(nil, appRepo, nil, nil, nil, Config{})
+
+	// when
+	err := service.executeComponentAdded("app-1", createComponentAddedChange("foreign-group"))
+
+	// then
+	assert.Error(t, err)
+	_, lookupErr := appRepo.GetComponentByID("component-1")
+	assert.Error(t, lookupErr)
+}
+
+func TestExecuteComponentGroupAdded_ShouldUseEventApplication(t *testing.T) {
+	// given
+	appRepo := application.NewMemoryRepository()
+	service := NewEventService(nil, appRepo, nil, nil, nil, Config{})
+	change := map[string]interface{}{
+		"data": map[string]interface{}{"id": "group-1", "name": "Group", "index": float64(0)},
+	}
+
+	// when
+	err := service.executeComponentGroupAdded("app-1", change)
+
+	// then
+	assert.NoError(t, err)
+	group, err := appRepo.GetComponentGroupByID("group-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "app-1", group.ApplicationID)
+}
+
+func TestExecuteComponentGroupAdded_ShouldRejectAnotherApplication(t *testing.T) {
+	// given
+	appRepo := application.NewMemoryRepository()
+	service := NewEventService(nil, appRepo, nil, nil, nil, Config{})
+	change := map[string]interface{}{
+		"data": map[string]interface{}{"id": "group-1", "applicationId": "app-2", "name": "Group"},
+	}
+
+	// when
+	err := service.executeComponentGroupAdded("app-1", change)
+
+	// then
+	assert.Error(t, err)
+	group, _ := appRepo.GetComponentGroupByID("group-1")
+	assert.Nil(t, group)
+}
+
+func TestExecuteApplicationAfterEditModeChanged_ShouldFailBatchWhenComponentCannotBeAdded(t *testing.T) {
+	// given
+	appRepo := application.NewMemoryRepository()
+	service := NewEventService(nil, appRepo, nil, nil, nil, Config{})
+	ev := &Event{
+		ID:            "event-1",
+		ApplicationID: "app-1",
+		Type:          "application_after_edit_mode_changed",
+		Data: map[string]interface{}{
+			"changes": []interface{}{createComponentAddedChange("missing-group")},
+		},
+	}
+
+	// when
+	err := service.executeApplicationAfterEditModeChanged(context.Background(), ev)
+
+	// then
+	assert.Error(t, err)
+}
+
+func TestExecuteApplicationAfterEditModeChanged_ShouldFailBatchWhenComponentCannotBeRemoved(t *testing.T) {
+	// given
+	appRepo := application.NewMemoryRepository()
+	service := NewEventService(nil, appRepo, nil, nil, nil, Config{})
+	ev := &Event{
+		ID:            "event-1",
+		ApplicationID: "app-1",
+		Type:          "application_after_edit_mode_changed",
+		Data: map[string]interface{}{
+			"changes": []interface{}{
+				map[string]interface{}{"changeType": "component_removed", "entityType": "component", "entityId": "missing-component"},
+			},
+		},
+	}
+
+	// when
+	err := service.executeApplicationAfterEditModeChanged(context.Background(), ev)
+
+	// then
+	assert.ErrorContains(t, err, "component not found")
+}
+
+func TestGetLastComponentChange_ShouldRejectNonOwner(t *testing.T) {
+	// given
+	appRepo := application.NewMemoryRepository()
+	appRepo.CreateMember(&application.Member{ID: "owner-member", ApplicationID: "app-1", Name: "owner", Role: application.MemberRoleOwner, PublicKey: "owner-key"})
+	appRepo.CreateMember(&application.Member{ID: "regular-member", ApplicationID: "app-1", Name: "member", Role: application.MemberRoleMember, PublicKey: "member-key"})
+	service := NewEventService(nil, appRepo, nil, nil, nil, Config{})
+
+	// when
+	_, memberErr := service.GetLastComponentChange("app-1", "component-1", "", "member-key")
+	_, outsiderErr := service.GetLastComponentChange("app-1", "component-1", "", "outsider-key")
+
+	// then
+	assert.True(t, errors.Is(memberErr, ErrUnauthorized))
+	assert.True(t, errors.Is(outsiderErr, ErrUnauthorized))
+}
+
+func createComponentDataChangedEvent(submitter *user.User, value interface{}) *Event {
+	return &Event{
+		ID:               "event-1",
+		Type:             EventTypeComponentDataChanged,
+		CreatorPublicKey: submitter.PublicKey,
+		Data: map[string]interface{}{
+			"applicationId": "app-1",
+			"componentId":   "component-1",
+			"changedFields": map[string]interface{}{
+				"content": map[string]interface{}{"new": value},
+			},
+		},
+	}
+}
+
+func TestAcceptEvent_ShouldRejectOverDeepComponentData(t *testing.T) {
+	// given
+	service := NewEventService(nil, application.NewMemoryRepository(), nil, nil, nil, Config{MaxComponentDataDepth: 8})
+	submitter := createTestSubmitter()
+	var nested interface{} = "leaf"
+	for i := 0; i < 10; i++ {
+		nested = map[string]interface{}{"child": nested}
+	}
+
+	// when
+	_, err := service.AcceptEvent(context.Background(), createComponentDataChangedEvent(submitter, nested), submitter)
+
+	// then
+	assert.True(t, errors.Is(err, ErrValidation))
+	assert.Contains(t, err.Error(), "maximum depth of 8")
+}
+
+func TestAcceptEvent_ShouldRejectOverWideComponentData(t *testing.T) {
+	// given
+	service := NewEventService(nil, application.NewMemoryRepository(), nil, nil, nil, Config{MaxComponentDataKeys: 100})
+	submitter := createTestSubmitter()
+	wide := make(map[string]interface{}, 200)
+	for i := 0; i < 200; i++ {
+		wide[fmt.Sprintf("key-%d", i)] = i
+	}
+
+	// when
+	_, err := service.AcceptEvent(context.Background(), createComponentDataChangedEvent(submitter, wide), submitter)
+
+	// then
+	assert.True(t, errors.Is(err, ErrValidation))
+	assert.Contains(t, err.Error(), "maximum of 100 keys")
+}
+
+func TestCheckEventAge_ShouldRejectStaleOfflineEdit(t *testing.T) {
+	// given
+	now := time.Unix(1_700_000_000, 0)
+	service := NewEventService(nil, application.NewMemoryRepository(), nil, nil, nil, Config{MaxEventAge: 24 * time.Hour, Clock: clock.NewFake(now)})
+	submitter := createTestSubmitter()
+	stale := createComponentDataChangedEvent(submitter, "offline edit")
+	stale.CreatedAt = now.Add(-48 * time.Hour).Unix()
+
+	// when
+	err := service.checkEventAge(stale)
+
+	// then
+	assert.True(t, errors.Is(err, ErrStaleEvent))
+	assert.Contains(t, err.Error(), "resync")
+}
+
+func TestCheckEventAge_ShouldAcceptRecentAndUndatedEvents(t *testing.T) {
+	// given
+	now := time.Unix(1_700_000_000, 0)
+	service := NewEventService(nil, nil, nil, nil, nil, Config{MaxEventAge: 24 * time.Hour, Clock: clock.NewFake(now)})
+	recent := &Event{ID: "event-1", CreatedAt: now.Add(-time.Hour).Unix()}
+	undated := &Event{ID: "event-2"}
+
+	// when / then
+	assert.NoError(t, service.checkEventAge(recent))
+	assert.NoError(t, service.checkEventAge(undated))
+}
+
+func TestCheckEventAge_ShouldNotCheckWhenDisabled(t *testing.T) {
+	// given
+	service := NewEventService(nil, nil, nil, nil, nil, Config{})
+	old := &Event{ID: "event-1", CreatedAt: 1}
+
+	// when / then
+	assert.NoError(t, service.checkEventAge(old))
+}
+
+func TestValidateDataShape_ShouldAcceptDataWithinLimits(t *testing.T) {
+	// given
+	data := map[string]interface{}{
+		"changedFields": map[string]interface{}{
+			"items": []interface{}{"a", "b", map[string]interface{}{"c": 1}},
+		},
+	}
+
+	// when
+	err := ValidateDataShape(data, 4, 6)
+
+	// then
+	assert.NoError(t, err)
+}
+
+func TestValidateEvent_ShouldRejectUnsupportedDataVersion(t *testing.T) {
+	// given
+	ev := createComponentDataChangedEvent(createTestSubmitter(), "value")
+	ev.Data["version"] = float64(2)
+
+	// when
+	err := ValidateEvent(ev)
+
+	// then
+	assert.True(t, errors.Is(err, ErrValidation))
+	assert.Contains(t, err.Error(), "upgrade the client")
+}
+
+func TestValidateEvent_ShouldRejectUnsupportedEventVersion(t *testing.T) {
+	// given
+	ev := createComponentDataChangedEvent(createTestSubmitter(), "value")
+	ev.Version = 3
+
+	// when
+	err := ValidateEvent(ev)
+
+	// then
+	assert.True(t, errors.Is(err, ErrValidation))
+	assert.Contains(t, err.Error(), "event.version 3")
+}
+
+func TestValidateEvent_ShouldAcceptSupportedVersion(t *testing.T) {
+	// given
+	ev := createComponentDataChangedEvent(createTestSubmitter(), "value")
+	ev.Version = 1
+	ev.Data["version"] = float64(1)
+	unversioned := createComponentDataChangedEvent(createTestSubmitter(), "value")
+
+	// then
+	assert.NoError(t, ValidateEvent(ev))
+	assert.NoError(t, ValidateEvent(unversioned))
+}
+
+func TestValidateEvent_ShouldRejectTokenPrincipalAsOwner(t *testing.T) {
+	// given
+	promotion := newMemberRoleChangedEvent("app-1", "owner-public-key-0123456789", "apitoken:token-1", application.MemberRoleAdmin, application.MemberRoleOwner)
+	demotion := newMemberRoleChangedEvent("app-1", "owner-public-key-0123456789", "apitoken:token-1", application.MemberRoleAdmin, application.MemberRoleMember)
+
+	// when
+	err := ValidateEvent(promotion)
+
+	// then
+	assert.True(t, errors.Is(err, ErrValidation))
+	assert.True(t, errors.Is(err, application.ErrTokenOwner))
+	assert.NoError(t, ValidateEvent(demotion))
+}
+
+func TestProduceOwnershipTransfer_ShouldRejectTokenPrincipalAsNewOwner(t *testing.T) {
+	// given
+	service := NewEventService(nil, createOwnedTestApplication(), nil, nil, nil, Config{})
+
+	// when
+	err := service.ProduceOwnershipTransfer(context.Background(), "app-1", "owner-public-key-0123456789", "apitoken:token-1", application.MemberRoleAdmin)
+
+	// then
+	assert.True(t, errors.Is(err, application.ErrTokenOwner))
+}
+
+func TestEventLimitError_ShouldAllowEventsBelowCap(t *testing.T) {
+	assert.NoError(t, eventLimitError(0, 100))
+	assert.NoError(t, eventLimitError(99, 100))
+}
+
+func TestEventLimitError_ShouldRejectEventsAtCap(t *testing.T) {
+	// when
+	atCap := eventLimitError(100, 100)
+	overCap := eventLimitError(150, 100)
+
+	// then
+	assert.True(t, errors.Is(atCap, ErrEventLimitReached))
+	assert.True(t, errors.Is(overCap, ErrEventLimitReached))
+}
+
+func TestEventLimitError_ShouldNotLimitWhenCapIsZero(t *testing.T) {
+	assert.NoError(t, eventLimitError(1000000, 0))
+}
+
+func TestCheckEventLimit_ShouldAllowApplicationDeletedAtCap(t *testing.T) {
+	// given
+	service := NewEventService(nil, application.NewMemoryRepository(), nil, nil, nil, Config{MaxEventsPerApplication: 1})
+
+	// when
+	err := service.checkEventLimit("app-1", EventTypeApplicationDeleted)
+
+	// then
+	assert.NoError(t, err)
+}
+
+func createHistoryTestRepository() *application.MemoryRepository {
+	appRepo := application.NewMemoryRepository()
+	appRepo.CreateApplication(&application.Application{ID: "app-1", Name: "App"})
+	appRepo.CreateMember(&application.Member{ID: "member-1", ApplicationID: "app-1", Name: "member", Role: application.MemberRoleMember, PublicKey: "member-key"})
+	appRepo.CreateComponent(&application.Component{ID: "component-1", ApplicationID: "app-1", Data: map[string]interface{}{"title": "v1"}})
+	return appRepo
+}
+
+func createTitleChangedEvent(id string, sequence int64, title string) *Event {
+	return &Event{
+		ID:               id,
+		SequenceNumber:   sequence,
+		Type:             EventTypeComponentDataChanged,
+		CreatorPublicKey: "member-key",
+		Data: map[string]interface{}{
+			"applicationId": "app-1",
+			"componentId":   "component-1",
+			"changedFields": map[string]interface{}{
+				"title": map[string]interface{}{"newValue": title},
+			},
+		},
+	}
+}
+
+func TestExecuteComponentDataChanged_ShouldCaptureBoundedHistory(t *testing.T) {
+	// given
+	appRepo := createHistoryTestRepository()
+	service := NewEventService(nil, appRepo, nil, nil, nil, Config{ComponentHistoryDepth: 2})
+
+	// when
+	for i, title := range []string{"v2", "v3", "v4"} {
+		err := service.executeComponentDataChanged(context.Background(), createTitleChangedEvent(fmt.Sprintf("event-%d", i+2), int64(i+2), title))
+		assert.NoError(t, err)
+	}
+
+	// then
+	versions, err := service.GetComponentDataHistory("app-1", "component-1", "member-key", 10)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, "v3", versions[0].Data["title"])
+	assert.Equal(t, "event-4", versions[0].EventID)
+	assert.Equal(t, "v2", versions[1].Data["title"])
+}
+
+func TestExecuteComponentDataChanged_ShouldNotCaptureHistoryWhenDisabled(t *testing.T) {
+	// given
+	appRepo := createHistoryTestRepository()
+	service := NewEventService(nil, appRepo, nil, nil, nil, Config{})
+
+	// when
+	err := service.executeComponentDataChanged(context.Background(), createTitleChangedEvent("event-2", 2, "v2"))
+
+	// then
+	assert.NoError(t, err)
+	versions, _ := appRepo.GetComponentDataHistory("component-1", 10)
+	assert.Empty(t, versions)
+}
+
+func TestRevertChanges_ShouldRestoreVersionWhenApplied(t *testing.T) {
+	// given
+	appRepo := createHistoryTestRepository()
+	service := NewEventService(nil, appRepo, nil, nil, nil, Config{ComponentHistoryDepth: 5})
+	change := createTitleChangedEvent("event-2", 2, "v2")
+	change.Data["changedFields"].(map[string]interface{})["subtitle"] = map[string]interface{}{"newValue": "added"}
+	assert.NoError(t, service.executeComponentDataChanged(context.Background(), change))
+	versions, _ := service.GetComponentDataHistory("app-1", "component-1", "member-key", 1)
+
+	// when
+	component, _ := appRepo.GetComponentByID("component-1")
+	revert := createTitleChangedEvent("event-3", 3, "")
+	revert.Data["changedFields"] = revertChanges(component.Data, versions[0].Data)
+	err := service.executeComponentDataChanged(context.Background(), revert)
+
+	// then
+	assert.NoError(t, err)
+	component, _ = appRepo.GetComponentByID("component-1")
+	assert.Equal(t, "v1", component.Data["title"])
+	assert.Nil(t, component.Data["subtitle"])
+	history, _ := service.GetComponentDataHistory("app-1", "component-1", "member-key", 10)
+	assert.Equal(t, "v2", history[0].Data["title"])
+}
+
+func TestRevertComponentData_ShouldRejectVersionOfAnotherComponent(t *testing.T) {
+	// given
+	appRepo := createHistoryTestRepository()
+	appRepo.CreateComponent(&application.Component{ID: "component-2", ApplicationID: "app-1"})
+	appRepo.AddComponentDataVersion(&application.ComponentDataVersion{ID: "version-1", ComponentID: "component-2", ApplicationID: "app-1"}, 5)
+	service := NewEventService(nil, appRepo, nil, nil, nil, Config{ComponentHistoryDepth: 5})
+
+	// when
+	_, err := service.RevertComponentData(context.Background(), "app-1", "component-1", "version-1", &user.User{PublicKey: "member-key"})
+
+	// then
+	assert.True(t, errors.Is(err, ErrComponentVersionNotFound))
+}
+
+func TestGetComponentDataHistory_ShouldRejectNonMember(t *testing.T) {
+	// given
+	service := NewEventService(nil, createHistoryTestRepository(), nil, nil, nil, Config{ComponentHistoryDepth: 5})
+
+	// when
+	_, err := service.GetComponentDataHistory("app-1", "component-1", "outsider-key", 10)
+
+	// then
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+}
+
+func TestCheckEventReadAccess_ShouldAllowMember(t *testing.T) {
+	// given
+	service := NewEventService(nil, createHistoryTestRepository(), nil, nil, nil, Config{})
+	event := createTitleChangedEvent("event-1", 1, "v2")
+	event.ApplicationID = "app-1"
+
+	// when
+	err := service.checkEventReadAccess(event, "member-key")
+
+	// then
+	assert.NoError(t, err)
+}
+
+func TestCheckEventReadAccess_ShouldHideEventFromNonMember(t *testing.T) {
+	// given
+	service := NewEventService(nil, createHistoryTestRepository(), nil, nil, nil, Config{})
+	event := createTitleChangedEvent("event-1", 1, "v2")
+	event.ApplicationID = "app-1"
+
+	// when
+	err := service.checkEventReadAccess(event, "outsider-key")
+
+	// then
+	assert.True(t, errors.Is(err, ErrEventNotFound))
+}
+
+func TestCheckEventReadAccess_ShouldOnlyAllowCreatorForUserScopedEvent(t *testing.T) {
+	// given
+	service := NewEventService(nil, createHistoryTestRepository(), nil, nil, nil, Config{})
+	event := &Event{ID: "event-1", Type: EventTypeUserSettingsChanged, CreatorPublicKey: "member-key"}
+
+	// when
+	creatorErr := service.checkEventReadAccess(event, "member-key")
+	otherErr := service.checkEventReadAccess(event, "outsider-key")
+
+	// then
+	assert.NoError(t, creatorErr)
+	assert.True(t, errors.Is(otherErr, ErrEventNotFound))
+}
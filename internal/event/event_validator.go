@@ -3,12 +3,132 @@ package event
 import (
 	"errors"
 	"fmt"
+
+	"github.com/prappser/prappser_server/internal/apitoken"
+	"github.com/prappser/prappser_server/internal/application"
 )
 
-var (
-	ErrValidation = errors.New("validation error")
+var ErrValidation = errors.New("validation error")
+
+const (
+	DefaultMaxComponentDataDepth = 32
+	DefaultMaxComponentDataKeys  = 10000
 )
 
+// VersionRange is the inclusive range of schema versions the server can execute for an event type
+type VersionRange struct {
+	Min int
+	Max int
+}
+
+// SupportedEventVersions lists the schema versions accepted per event type, for both the
+// event envelope and the version carried in its data
+var SupportedEventVersions = map[EventType]VersionRange{
+	EventTypeMemberAdded:                     {Min: 1, Max: 1},
+	EventTypeMemberRemoved:                   {Min: 1, Max: 1},
+	EventTypeMemberRoleChanged:               {Min: 1, Max: 1},
+	EventTypeApplicationDataChanged:          {Min: 1, Max: 1},
+	EventTypeApplicationDeleted:              {Min: 1, Max: 1},
+	EventTypeInviteRevoked:                   {Min: 1, Max: 1},
+	EventTypeComponentDataChanged:            {Min: 1, Max: 1},
+	EventTypeApplicationAfterEditModeChanged: {Min: 1, Max: 1},
+	EventTypeUserSettingsChanged:             {Min: 1, Max: 1},
+	EventTypeMemberDetailsChanged:            {Min: 1, Max: 1},
+	EventTypeApplicationCreated:              {Min: 1, Max: 1},
+	EventTypeApplicationFileCreated:          {Min: 1, Max: 1},
+	EventTypeApplicationFileDeleted:          {Min: 1, Max: 1},
+	EventTypeMemberRekeyed:                   {Min: 1, Max: 1},
+}
+
+// validateEventVersion rejects events whose envelope or data version falls outside the
+// type's supported range. An omitted version (zero or absent) predates versioning and
+// is treated as version 1.
+func validateEventVersion(event *Event) error {
+	supported, ok := SupportedEventVersions[event.Type]
+	if !ok {
+		return nil
+	}
+
+	if err := checkVersionInRange(event.Type, "event.version", event.Version, supported); err != nil {
+		return err
+	}
+
+	rawVersion, present := event.Data["version"]
+	if !present {
+		return nil
+	}
+	var dataVersion int
+	switch v := rawVersion.(type) {
+	case int:
+		dataVersion = v
+	case int64:
+		dataVersion = int(v)
+	case float64:
+		if v != float64(int(v)) {
+			return fmt.Errorf("%w: data.version must be an integer", ErrValidation)
+		}
+		dataVersion = int(v)
+	default:
+		return fmt.Errorf("%w: data.version must be an integer", ErrValidation)
+	}
+	return checkVersionInRange(event.Type, "data.version", dataVersion, supported)
+}
+
+func checkVersionInRange(eventType EventType, field string, version int, supported VersionRange) error {
+	if version == 0 {
+		version = 1
+	}
+	if version < supported.Min || version > supported.Max {
+		return fmt.Errorf("%w: %s %d of %s is not supported (supported versions %d-%d); upgrade the client to a compatible version",
+			ErrValidation, field, version, eventType, supported.Min, supported.Max)
+	}
+	return nil
+}
+
+// carriesComponentData reports whether the event type embeds client-shaped component
+// data that is later merged into stored components
+func carriesComponentData(eventType EventType) bool {
+	return eventType == EventTypeComponentDataChanged || eventType == EventTypeApplicationAfterEditModeChanged
+}
+
+// ValidateDataShape rejects data nested deeper than maxDepth levels or holding more than
+// maxKeys entries in total, counting object keys and array elements at every level.
+// The data map itself is level one.
+func ValidateDataShape(data map[string]interface{}, maxDepth, maxKeys int) error {
+	keys := 0
+	return walkDataShape(data, 1, maxDepth, maxKeys, &keys)
+}
+
+func walkDataShape(value interface{}, depth, maxDepth, maxKeys int, keys *int) error {
+	var children []interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		*keys += len(v)
+		for _, child := range v {
+			children = append(children, child)
+		}
+	case []interface{}:
+		*keys += len(v)
+		children = v
+	default:
+		return nil
+	}
+
+	if depth > maxDepth {
+		return fmt.Errorf("%w: data nesting exceeds the maximum depth of %d", ErrValidation, maxDepth)
+	}
+	if *keys > maxKeys {
+		return fmt.Errorf("%w: data exceeds the maximum of %d keys", ErrValidation, maxKeys)
+	}
+
+	for _, child := range children {
+		if err := walkDataShape(child, depth+1, maxDepth, maxKeys, keys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func ValidateEvent(event *Event) error {
 	if event.ID == "" {
 		return fmt.Errorf("%w: event.id is required", ErrValidation)
@@ -22,6 +142,9 @@ func ValidateEvent(event *Event) error {
 	if event.Data == nil {
 		return fmt.Errorf("%w: event.data is required", ErrValidation)
 	}
+	if err := validateEventVersion(event); err != nil {
+		return err
+	}
 
 	switch event.Type {
 	case EventTypeMemberAdded:
@@ -50,6 +173,8 @@ func ValidateEvent(event *Event) error {
 		return validateApplicationFileCreatedData(event.Data)
 	case EventTypeApplicationFileDeleted:
 		return validateApplicationFileDeletedData(event.Data)
+	case EventTypeMemberRekeyed:
+		return validateMemberRekeyedData(event.Data)
 	default:
 		return fmt.Errorf("%w: unknown event type: %s", ErrValidation, event.Type)
 	}
@@ -68,7 +193,7 @@ func validateMemberAddedData(data map[string]interface{}) error {
 	if _, ok := data["role"].(string); !ok || data["role"] == "" {
 		return fmt.Errorf("%w: role is required", ErrValidation)
 	}
-	return nil
+	return checkTokenNotOwner(data["memberPublicKey"].(string), data["role"].(string))
 }
 
 func validateMemberRemovedData(data map[string]interface{}) error {
@@ -94,6 +219,15 @@ func validateMemberRoleChangedData(data map[string]interface{}) error {
 	if _, ok := data["newRole"].(string); !ok || data["newRole"] == "" {
 		return fmt.Errorf("%w: newRole is required", ErrValidation)
 	}
+	return checkTokenNotOwner(data["memberPublicKey"].(string), data["newRole"].(string))
+}
+
+// checkTokenNotOwner rejects giving the owner role to an API token principal, which would
+// let a leaked token delete the application or hand it over
+func checkTokenNotOwner(memberPublicKey, role string) error {
+	if application.MemberRole(role) == application.MemberRoleOwner && apitoken.IsPrincipal(memberPublicKey) {
+		return fmt.Errorf("%w: %w", ErrValidation, application.ErrTokenOwner)
+	}
 	return nil
 }
 
@@ -151,6 +285,19 @@ func validateMemberDetailsChangedData(data map[string]interface{}) error {
 	return nil
 }
 
+func validateMemberRekeyedData(data map[string]interface{}) error {
+	if _, ok := data["applicationId"].(string); !ok || data["applicationId"] == "" {
+		return fmt.Errorf("%w: applicationId is required", ErrValidation)
+	}
+	if _, ok := data["memberPublicKey"].(string); !ok || data["memberPublicKey"] == "" {
+		return fmt.Errorf("%w: memberPublicKey is required", ErrValidation)
+	}
+	if _, ok := data["newPublicKey"].(string); !ok || data["newPublicKey"] == "" {
+		return fmt.Errorf("%w: newPublicKey is required", ErrValidation)
+	}
+	return nil
+}
+
 func validateApplicationFileCreatedData(data map[string]interface{}) error {
 	if _, ok := data["applicationId"].(string); !ok || data["applicationId"] == "" {
 		return fmt.Errorf("%w: applicationId is required", ErrValidation)
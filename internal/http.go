@@ -1,8 +1,10 @@
 package internal
 
 import (
+	"net/url"
 	"strings"
 
+	"github.com/prappser/prappser_server/internal/apitoken"
 	"github.com/prappser/prappser_server/internal/application"
 	"github.com/prappser/prappser_server/internal/event"
 	"github.com/prappser/prappser_server/internal/health"
@@ -12,13 +14,16 @@ import (
 	"github.com/prappser/prappser_server/internal/setup"
 	"github.com/prappser/prappser_server/internal/status"
 	"github.com/prappser/prappser_server/internal/user"
+	"github.com/prappser/prappser_server/internal/webhook"
 	"github.com/prappser/prappser_server/internal/websocket"
 	"github.com/valyala/fasthttp"
 )
 
-func NewRequestHandler(config *Config, userEndpoints *user.UserEndpoints, statusEndpoints *status.StatusEndpoints, healthEndpoints *health.HealthEndpoints, userService *user.UserService, appEndpoints *application.ApplicationEndpoints, invitationEndpoints *invitation.InvitationEndpoints, eventEndpoints *event.EventEndpoints, setupEndpoints *setup.SetupEndpoints, storageEndpoints *storage.Endpoints, wsHandler *websocket.Handler) fasthttp.RequestHandler {
-	authMiddleware := middleware.NewAuthMiddleware(userService)
-	corsMiddleware := middleware.NewCORSMiddleware(config.AllowedOrigins)
+func NewRequestHandler(config *Config, userEndpoints *user.UserEndpoints, statusEndpoints *status.StatusEndpoints, healthEndpoints *health.HealthEndpoints, userService *user.UserService, appEndpoints *application.ApplicationEndpoints, invitationEndpoints *invitation.InvitationEndpoints, eventEndpoints *event.EventEndpoints, setupEndpoints *setup.SetupEndpoints, storageEndpoints *storage.Endpoints, webhookEndpoints *webhook.WebhookEndpoints, apiTokenService *apitoken.APITokenService, apiTokenEndpoints *apitoken.APITokenEndpoints, wsHandler *websocket.Handler, origins *middleware.OriginPolicy) fasthttp.RequestHandler {
+	authMiddleware := middleware.NewAuthMiddleware(userService, apiTokenService)
+	corsMiddleware := middleware.NewCORSMiddleware(origins)
+	timeoutMiddleware := middleware.NewTimeoutMiddleware(config.RequestTimeouts)
+	concurrencyLimiter := middleware.NewConcurrencyLimiter(config.MaxConcurrentRequests)
 
 	handler := func(ctx *fasthttp.RequestCtx) {
 		path := string(ctx.Path())
@@ -38,10 +43,26 @@ func NewRequestHandler(config *Config, userEndpoints *user.UserEndpoints, status
 			userEndpoints.GetChallenge(ctx)
 		case path == "/users/auth":
 			userEndpoints.UserAuth(ctx)
+		case path == "/users/refresh":
+			method := string(ctx.Method())
+			if method == "POST" {
+				userEndpoints.Refresh(ctx)
+			} else {
+				ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+			}
+		case path == "/users/logout":
+			method := string(ctx.Method())
+			if method == "POST" {
+				authMiddleware.RequireAuth(userEndpoints.Logout)(ctx)
+			} else {
+				ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+			}
 		case path == "/users/me":
 			method := string(ctx.Method())
 			if method == "GET" {
 				authMiddleware.RequireAuth(userEndpoints.GetProfile)(ctx)
+			} else if method == "PATCH" {
+				authMiddleware.RequireAuth(userEndpoints.UpdateProfile)(ctx)
 			} else {
 				ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
 			}
@@ -61,6 +82,13 @@ func NewRequestHandler(config *Config, userEndpoints *user.UserEndpoints, status
 			authMiddleware.RequireRole(user.RoleOwner, appEndpoints.RegisterApplication)(ctx)
 		case path == "/applications":
 			authMiddleware.RequireRole(user.RoleOwner, appEndpoints.ListApplications)(ctx)
+		case path == "/applications/count":
+			method := string(ctx.Method())
+			if method == "GET" {
+				authMiddleware.RequireRole(user.RoleOwner, appEndpoints.CountApplications)(ctx)
+			} else {
+				ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+			}
 		case strings.HasPrefix(path, "/applications/") && strings.HasSuffix(path, "/state"):
 			parts := strings.Split(path, "/")
 			if len(parts) == 4 && parts[3] == "state" {
@@ -78,17 +106,90 @@ func NewRequestHandler(config *Config, userEndpoints *user.UserEndpoints, status
 					method := string(ctx.Method())
 					switch method {
 					case "POST":
-						authMiddleware.RequireRole(user.RoleOwner, invitationEndpoints.CreateInvite)(ctx)
+						authMiddleware.RequireAuth(invitationEndpoints.CreateInvite)(ctx)
 					case "GET":
-						authMiddleware.RequireRole(user.RoleOwner, invitationEndpoints.ListInvites)(ctx)
+						authMiddleware.RequireAuth(invitationEndpoints.ListInvites)(ctx)
 					default:
 						ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
 					}
 				} else if len(parts) == 5 {
 					ctx.SetUserValue("inviteID", parts[4])
 					method := string(ctx.Method())
+					switch method {
+					case "DELETE":
+						authMiddleware.RequireAuth(invitationEndpoints.RevokeInvite)(ctx)
+					case "PATCH":
+						authMiddleware.RequireAuth(invitationEndpoints.UpdateInvite)(ctx)
+					default:
+						ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+					}
+				} else if len(parts) == 6 && parts[5] == "preview" {
+					ctx.SetUserValue("inviteID", parts[4])
+					if string(ctx.Method()) == "GET" {
+						authMiddleware.RequireAuth(invitationEndpoints.PreviewInvite)(ctx)
+					} else {
+						ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+					}
+				} else {
+					ctx.Error("Not Found", fasthttp.StatusNotFound)
+				}
+			} else {
+				ctx.Error("Not Found", fasthttp.StatusNotFound)
+			}
+		case strings.HasPrefix(path, "/applications/") && strings.Contains(path, "/webhooks"):
+			parts := strings.Split(path, "/")
+			if len(parts) >= 4 && parts[3] == "webhooks" {
+				ctx.SetUserValue("appID", parts[2])
+				method := string(ctx.Method())
+
+				if len(parts) == 4 {
+					switch method {
+					case "POST":
+						authMiddleware.RequireRole(user.RoleOwner, webhookEndpoints.CreateWebhook)(ctx)
+					case "GET":
+						authMiddleware.RequireRole(user.RoleOwner, webhookEndpoints.ListWebhooks)(ctx)
+					default:
+						ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+					}
+				} else if len(parts) == 5 {
+					ctx.SetUserValue("webhookID", parts[4])
+					if method == "DELETE" {
+						authMiddleware.RequireRole(user.RoleOwner, webhookEndpoints.DeleteWebhook)(ctx)
+					} else {
+						ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+					}
+				} else if len(parts) == 6 && parts[5] == "dead-letters" {
+					ctx.SetUserValue("webhookID", parts[4])
+					if method == "GET" {
+						authMiddleware.RequireRole(user.RoleOwner, webhookEndpoints.ListDeadLetters)(ctx)
+					} else {
+						ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+					}
+				} else {
+					ctx.Error("Not Found", fasthttp.StatusNotFound)
+				}
+			} else {
+				ctx.Error("Not Found", fasthttp.StatusNotFound)
+			}
+		case strings.HasPrefix(path, "/applications/") && strings.Contains(path, "/tokens"):
+			parts := strings.Split(path, "/")
+			if len(parts) >= 4 && parts[3] == "tokens" {
+				ctx.SetUserValue("appID", parts[2])
+				method := string(ctx.Method())
+
+				if len(parts) == 4 {
+					switch method {
+					case "POST":
+						authMiddleware.RequireRole(user.RoleOwner, apiTokenEndpoints.CreateToken)(ctx)
+					case "GET":
+						authMiddleware.RequireRole(user.RoleOwner, apiTokenEndpoints.ListTokens)(ctx)
+					default:
+						ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+					}
+				} else if len(parts) == 5 {
+					ctx.SetUserValue("tokenID", parts[4])
 					if method == "DELETE" {
-						authMiddleware.RequireRole(user.RoleOwner, invitationEndpoints.RevokeInvite)(ctx)
+						authMiddleware.RequireRole(user.RoleOwner, apiTokenEndpoints.RevokeToken)(ctx)
 					} else {
 						ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
 					}
@@ -98,6 +199,126 @@ func NewRequestHandler(config *Config, userEndpoints *user.UserEndpoints, status
 			} else {
 				ctx.Error("Not Found", fasthttp.StatusNotFound)
 			}
+		case strings.HasPrefix(path, "/applications/") && strings.HasSuffix(path, "/events"):
+			parts := strings.Split(path, "/")
+			if len(parts) == 4 && parts[3] == "events" {
+				ctx.SetUserValue("appID", parts[2])
+				method := string(ctx.Method())
+				if method == "DELETE" {
+					authMiddleware.RequireAuth(eventEndpoints.PurgeApplicationEvents)(ctx)
+				} else {
+					ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+				}
+			} else {
+				ctx.Error("Not Found", fasthttp.StatusNotFound)
+			}
+		case strings.HasPrefix(path, "/applications/") && strings.HasSuffix(path, "/last-change"):
+			parts := strings.Split(path, "/")
+			if len(parts) == 6 && parts[3] == "components" && parts[5] == "last-change" {
+				ctx.SetUserValue("appID", parts[2])
+				ctx.SetUserValue("componentID", parts[4])
+				method := string(ctx.Method())
+				if method == "GET" {
+					authMiddleware.RequireRole(user.RoleOwner, eventEndpoints.GetLastComponentChange)(ctx)
+				} else {
+					ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+				}
+			} else {
+				ctx.Error("Not Found", fasthttp.StatusNotFound)
+			}
+		case strings.HasPrefix(path, "/applications/") && strings.Contains(path, "/history"):
+			parts := strings.Split(path, "/")
+			if len(parts) >= 6 && parts[3] == "components" && parts[5] == "history" {
+				ctx.SetUserValue("appID", parts[2])
+				ctx.SetUserValue("componentID", parts[4])
+				method := string(ctx.Method())
+
+				if len(parts) == 6 && method == "GET" {
+					authMiddleware.RequireAuth(eventEndpoints.GetComponentHistory)(ctx)
+				} else if len(parts) == 8 && parts[7] == "revert" && method == "POST" {
+					ctx.SetUserValue("versionID", parts[6])
+					authMiddleware.RequireAuth(eventEndpoints.RevertComponent)(ctx)
+				} else if len(parts) == 6 || (len(parts) == 8 && parts[7] == "revert") {
+					ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+				} else {
+					ctx.Error("Not Found", fasthttp.StatusNotFound)
+				}
+			} else {
+				ctx.Error("Not Found", fasthttp.StatusNotFound)
+			}
+		case strings.HasPrefix(path, "/applications/") && strings.HasSuffix(path, "/storage/delete"):
+			parts := strings.Split(path, "/")
+			if len(parts) == 5 && parts[3] == "storage" && parts[4] == "delete" {
+				ctx.SetUserValue("appID", parts[2])
+				method := string(ctx.Method())
+				if method == "POST" {
+					authMiddleware.RequireAuth(storageEndpoints.BulkDelete)(ctx)
+				} else {
+					ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+				}
+			} else {
+				ctx.Error("Not Found", fasthttp.StatusNotFound)
+			}
+		case strings.HasPrefix(path, "/applications/") && strings.HasSuffix(path, "/rekey"):
+			// Public keys are standard base64 and may contain '/', so clients percent-encode
+			// them and the segment is split from the undecoded path
+			parts := strings.Split(string(ctx.URI().PathOriginal()), "/")
+			memberPublicKey := ""
+			if len(parts) == 6 {
+				memberPublicKey, _ = url.PathUnescape(parts[4])
+			}
+			if len(parts) == 6 && parts[3] == "members" && parts[5] == "rekey" && memberPublicKey != "" {
+				ctx.SetUserValue("appID", parts[2])
+				ctx.SetUserValue("memberPublicKey", memberPublicKey)
+				method := string(ctx.Method())
+				if method == "POST" {
+					authMiddleware.RequireAuth(appEndpoints.RekeyMember)(ctx)
+				} else {
+					ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+				}
+			} else {
+				ctx.Error("Not Found", fasthttp.StatusNotFound)
+			}
+		case strings.HasPrefix(path, "/applications/") && strings.HasSuffix(path, "/members"):
+			parts := strings.Split(path, "/")
+			if len(parts) == 4 && parts[3] == "members" {
+				ctx.SetUserValue("appID", parts[2])
+				method := string(ctx.Method())
+				if method == "GET" {
+					authMiddleware.RequireAuth(appEndpoints.ListMembers)(ctx)
+				} else {
+					ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+				}
+			} else {
+				ctx.Error("Not Found", fasthttp.StatusNotFound)
+			}
+		case strings.HasPrefix(path, "/applications/") && strings.HasSuffix(path, "/transfer-ownership"):
+			parts := strings.Split(path, "/")
+			if len(parts) == 4 && parts[3] == "transfer-ownership" {
+				ctx.SetUserValue("appID", parts[2])
+				method := string(ctx.Method())
+				if method == "POST" {
+					authMiddleware.RequireAuth(appEndpoints.TransferOwnership)(ctx)
+				} else {
+					ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+				}
+			} else {
+				ctx.Error("Not Found", fasthttp.StatusNotFound)
+			}
+		case strings.HasPrefix(path, "/applications/") && strings.HasSuffix(path, "/role"):
+			parts := strings.Split(path, "/")
+			if len(parts) == 6 && parts[3] == "members" && parts[4] != "" && parts[5] == "role" {
+				ctx.SetUserValue("appID", parts[2])
+				ctx.SetUserValue("memberID", parts[4])
+				method := string(ctx.Method())
+				if method == "PATCH" {
+					authMiddleware.RequireAuth(appEndpoints.ChangeMemberRole)(ctx)
+				} else {
+					ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+				}
+			} else {
+				ctx.Error("Not Found", fasthttp.StatusNotFound)
+			}
 		case strings.HasPrefix(path, "/applications/") && strings.HasSuffix(path, "/members/me"):
 			parts := strings.Split(path, "/")
 			if len(parts) == 5 && parts[3] == "members" && parts[4] == "me" {
@@ -119,8 +340,10 @@ func NewRequestHandler(config *Config, userEndpoints *user.UserEndpoints, status
 				switch method {
 				case "GET":
 					authMiddleware.RequireAuth(appEndpoints.GetApplication)(ctx)
+				case "PATCH":
+					authMiddleware.RequireAuth(appEndpoints.UpdateApplication)(ctx)
 				case "DELETE":
-					authMiddleware.RequireRole(user.RoleOwner, appEndpoints.DeleteApplication)(ctx)
+					authMiddleware.RequireAuth(appEndpoints.DeleteApplication)(ctx)
 				default:
 					ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
 				}
@@ -167,6 +390,44 @@ func NewRequestHandler(config *Config, userEndpoints *user.UserEndpoints, status
 				ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
 			}
 
+		case path == "/events/report":
+			method := string(ctx.Method())
+			if method == "GET" {
+				authMiddleware.RequireRole(user.RoleOwner, eventEndpoints.GetDataSizeReport)(ctx)
+			} else {
+				ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+			}
+
+		case strings.HasPrefix(path, "/events/"):
+			parts := strings.Split(path, "/")
+			if len(parts) == 3 && parts[2] != "" {
+				ctx.SetUserValue("eventID", parts[2])
+				method := string(ctx.Method())
+				if method == "GET" {
+					authMiddleware.RequireAuth(eventEndpoints.GetEvent)(ctx)
+				} else {
+					ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+				}
+			} else {
+				ctx.Error("Not Found", fasthttp.StatusNotFound)
+			}
+
+		case path == "/sync/state":
+			method := string(ctx.Method())
+			if method == "GET" {
+				authMiddleware.RequireAuth(eventEndpoints.GetSyncState)(ctx)
+			} else {
+				ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+			}
+
+		case path == "/sync/estimate":
+			method := string(ctx.Method())
+			if method == "GET" {
+				authMiddleware.RequireAuth(eventEndpoints.GetSyncEstimate)(ctx)
+			} else {
+				ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+			}
+
 		case path == "/storage/upload":
 			method := string(ctx.Method())
 			if method == "POST" {
@@ -208,6 +469,32 @@ func NewRequestHandler(config *Config, userEndpoints *user.UserEndpoints, status
 			} else {
 				ctx.Error("Not Found", fasthttp.StatusNotFound)
 			}
+		case strings.HasPrefix(path, "/storage/") && strings.HasSuffix(path, "/chunks"):
+			parts := strings.Split(path, "/")
+			if len(parts) == 4 && parts[3] == "chunks" {
+				ctx.SetUserValue("storageID", parts[2])
+				method := string(ctx.Method())
+				if method == "GET" {
+					authMiddleware.RequireAuth(storageEndpoints.GetChunkedUploadProgress)(ctx)
+				} else {
+					ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+				}
+			} else {
+				ctx.Error("Not Found", fasthttp.StatusNotFound)
+			}
+		case strings.HasPrefix(path, "/storage/") && strings.HasSuffix(path, "/regenerate-thumbnail"):
+			parts := strings.Split(path, "/")
+			if len(parts) == 4 && parts[3] == "regenerate-thumbnail" {
+				ctx.SetUserValue("storageID", parts[2])
+				method := string(ctx.Method())
+				if method == "POST" {
+					authMiddleware.RequireAuth(storageEndpoints.RegenerateThumbnail)(ctx)
+				} else {
+					ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+				}
+			} else {
+				ctx.Error("Not Found", fasthttp.StatusNotFound)
+			}
 		case strings.HasPrefix(path, "/storage/") && strings.HasSuffix(path, "/thumb"):
 			parts := strings.Split(path, "/")
 			if len(parts) == 4 && parts[3] == "thumb" {
@@ -236,10 +523,19 @@ func NewRequestHandler(config *Config, userEndpoints *user.UserEndpoints, status
 		case path == "/ws":
 			wsHandler.HandleFastHTTP(ctx)
 
+		case setupEndpoints.IsLandingPath(path):
+			method := string(ctx.Method())
+			if method == "GET" || method == "HEAD" {
+				setupEndpoints.ServeLanding(ctx)
+			} else {
+				ctx.Error("Method Not Allowed", fasthttp.StatusMethodNotAllowed)
+			}
+
 		default:
 			ctx.Error("Not Found", fasthttp.StatusNotFound)
 		}
 	}
 
-	return corsMiddleware.Handle(handler)
+	// The limiter runs inside the timeout so a timed out handler keeps its slot until it returns
+	return corsMiddleware.Handle(timeoutMiddleware.Handle(concurrencyLimiter.Handle(handler)))
 }
@@ -1,9 +1,109 @@
 package invitation
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"slices"
 	"time"
+
+	"github.com/prappser/prappser_server/internal/clock"
+)
+
+const (
+	// DefaultDeepLinkScheme is the custom URL scheme registered by the Prappser app
+	DefaultDeepLinkScheme = "prappser"
+
+	// DefaultShortCodeTTL caps how long a short code resolves, whatever the invitation's expiry
+	DefaultShortCodeTTL = 48 * time.Hour
+
+	// shortCodeLength is long enough to make guessing a live code impractical while
+	// keeping links short enough for a sparse, easily scanned QR code
+	shortCodeLength = 10
+
+	DefaultInvitesPageSize = 50
+	MaxInvitesPageSize     = 200
+)
+
+var (
+	ErrInvalidDeepLinkScheme = errors.New("invalid deep link scheme")
+	ErrShortCodeNotFound     = errors.New("invitation code not found")
+	ErrShortCodeExpired      = errors.New("invitation code expired")
+	ErrEmailRequired         = errors.New("email is required to join this application")
+	ErrInvalidEmail          = errors.New("invalid email")
+	ErrInvalidJoinProof      = errors.New("invalid join proof")
+)
+
+var (
+	deepLinkSchemePattern = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+
+	// reservedSchemes would turn a deep link into a web, script or local-file link
+	reservedSchemes = []string{"http", "https", "javascript", "data", "file", "vbscript", "about", "blob"}
 )
 
+// Config holds invitation link settings
+type Config struct {
+	DeepLinkScheme string
+	// ShortCodeTTL is the longest a short code stays valid; codes of invitations that
+	// expire sooner expire with the invitation
+	ShortCodeTTL time.Duration
+	// RequireEmailApps lists applications whose joiners must give a contact email;
+	// "*" requires it for every application
+	RequireEmailApps []string
+	// Clock tells the current time; nil selects clock.System
+	Clock clock.Clock
+}
+
+// RequiresEmail reports whether joining the application requires a contact email
+func (c Config) RequiresEmail(appID string) bool {
+	return slices.Contains(c.RequireEmailApps, "*") || slices.Contains(c.RequireEmailApps, appID)
+}
+
+// ValidateEmail checks that email is a bare address such as "user@example.com"
+func ValidateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return fmt.Errorf("%w: %q is not a valid email address", ErrInvalidEmail, email)
+	}
+	return nil
+}
+
+// VerifyJoinProof checks that signature is a base64 Ed25519 signature of the invitation
+// token made with the private key of publicKey, proving the joiner controls the key
+func VerifyJoinProof(publicKey, token, signature string) error {
+	publicKeyBytes, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(publicKeyBytes) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: public key is not a base64 Ed25519 key", ErrInvalidJoinProof)
+	}
+
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(signatureBytes) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: signature is not a base64 Ed25519 signature", ErrInvalidJoinProof)
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(publicKeyBytes), []byte(token), signatureBytes) {
+		return fmt.Errorf("%w: signature does not match the public key", ErrInvalidJoinProof)
+	}
+	return nil
+}
+
+// ValidateDeepLinkScheme checks that the scheme is a well-formed custom scheme listed in allowed
+func ValidateDeepLinkScheme(scheme string, allowed []string) error {
+	if !deepLinkSchemePattern.MatchString(scheme) {
+		return fmt.Errorf("%w: %q is not a valid URL scheme", ErrInvalidDeepLinkScheme, scheme)
+	}
+	if slices.Contains(reservedSchemes, scheme) {
+		return fmt.Errorf("%w: %q is not a custom scheme", ErrInvalidDeepLinkScheme, scheme)
+	}
+	if !slices.Contains(allowed, scheme) {
+		return fmt.Errorf("%w: %q is not in the allowed schemes %v", ErrInvalidDeepLinkScheme, scheme, allowed)
+	}
+	return nil
+}
+
 // Invitation represents an invitation to join an application
 type Invitation struct {
 	ID                 string  `json:"id"`
@@ -11,7 +111,9 @@ type Invitation struct {
 	CreatedByPublicKey string  `json:"createdByPublicKey"`
 	Role               string  `json:"role"`
 	MaxUses            *int    `json:"maxUses,omitempty"`
+	// UsedCount is the number of distinct users who joined through the invitation
 	UsedCount          int     `json:"usedCount"`
+	ExpiresAt          *int64  `json:"expiresAt,omitempty"`
 	CreatedAt          int64   `json:"createdAt"`
 }
 
@@ -23,14 +125,35 @@ type InvitationUse struct {
 	UsedAt         int64  `json:"usedAt"`
 }
 
-// InvitationResponse is returned when creating an invitation
+// ShortCode maps a short random code to a full invitation token, so shared links and
+// QR codes carry the code instead of the long signed token
+type ShortCode struct {
+	Code         string `json:"code"`
+	InvitationID string `json:"invitationId"`
+	Token        string `json:"-"`
+	ExpiresAt    int64  `json:"expiresAt"`
+	CreatedAt    int64  `json:"createdAt"`
+}
+
+// IsExpired checks if the short code no longer resolves at now
+func (c *ShortCode) IsExpired(now time.Time) bool {
+	return now.Unix() > c.ExpiresAt
+}
+
+// InvitationResponse is returned when creating an invitation.
+// ShortURL and ShortDeepLink carry the short code instead of the token and are
+// preferred for QR codes; the code is accepted wherever a token is.
 type InvitationResponse struct {
-	ID        string  `json:"id"`
-	Token     string  `json:"token"`
-	URL       string  `json:"url"`
-	DeepLink  string  `json:"deepLink"`
-	ExpiresAt *int64  `json:"expiresAt,omitempty"`
-	CreatedAt int64   `json:"createdAt"`
+	ID            string `json:"id"`
+	Token         string `json:"token"`
+	URL           string `json:"url"`
+	DeepLink      string `json:"deepLink"`
+	Code          string `json:"code"`
+	ShortURL      string `json:"shortUrl"`
+	ShortDeepLink string `json:"shortDeepLink"`
+	CodeExpiresAt int64  `json:"codeExpiresAt"`
+	ExpiresAt     *int64 `json:"expiresAt,omitempty"`
+	CreatedAt     int64  `json:"createdAt"`
 }
 
 // InvitationOptions contains options for creating an invitation
@@ -40,6 +163,24 @@ type InvitationOptions struct {
 	MaxUses        *int   `json:"maxUses,omitempty"`
 }
 
+// UpdateInvitationRequest contains the fields an owner may change on an existing invitation
+type UpdateInvitationRequest struct {
+	MaxUses        *int `json:"maxUses,omitempty"`
+	ExpiresInHours *int `json:"expiresInHours,omitempty"`
+}
+
+// UpdateInvitationResponse is returned after updating an invitation, with a re-issued token
+type UpdateInvitationResponse struct {
+	Invitation    *Invitation `json:"invitation"`
+	Token         string      `json:"token"`
+	URL           string      `json:"url"`
+	DeepLink      string      `json:"deepLink"`
+	Code          string      `json:"code"`
+	ShortURL      string      `json:"shortUrl"`
+	ShortDeepLink string      `json:"shortDeepLink"`
+	CodeExpiresAt int64       `json:"codeExpiresAt"`
+}
+
 // InviteInfo is public information about an invitation
 type InviteInfo struct {
 	InviteID        string  `json:"inviteId"`
@@ -79,17 +220,25 @@ type JoinResponse struct {
 	Member      map[string]interface{} `json:"member"`
 }
 
-// UpdateTimestamp updates the created timestamp to current time
-func (i *Invitation) UpdateTimestamp() {
-	i.CreatedAt = time.Now().Unix()
+// UpdateTimestamp sets the created timestamp to now
+func (i *Invitation) UpdateTimestamp(now time.Time) {
+	i.CreatedAt = now.Unix()
 }
 
-// IsExpired checks if the invitation has expired based on JWT expiration
-func (c *InviteTokenClaims) IsExpired() bool {
+// IsExpired checks if the invitation has expired at now based on JWT expiration
+func (c *InviteTokenClaims) IsExpired(now time.Time) bool {
 	if c.ExpiresAt == nil {
 		return false
 	}
-	return time.Now().Unix() > *c.ExpiresAt
+	return now.Unix() > *c.ExpiresAt
+}
+
+// IsExpired checks if the invitation has passed its persisted expiry at now
+func (i *Invitation) IsExpired(now time.Time) bool {
+	if i.ExpiresAt == nil {
+		return false
+	}
+	return now.Unix() > *i.ExpiresAt
 }
 
 // IsMaxUsesReached checks if invitation has reached max uses
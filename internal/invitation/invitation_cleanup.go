@@ -0,0 +1,75 @@
+package invitation
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// CleanupScheduler periodically removes invitations that expired or reached their max
+// uses, which are otherwise only filtered out when read
+type CleanupScheduler struct {
+	invitationService *InvitationService
+	ticker            *time.Ticker
+	done              chan bool
+}
+
+// NewCleanupScheduler creates a new invitation cleanup scheduler
+func NewCleanupScheduler(invitationService *InvitationService) *CleanupScheduler {
+	return &CleanupScheduler{
+		invitationService: invitationService,
+		done:              make(chan bool),
+	}
+}
+
+// Start runs the cleanup once and then every 24 hours
+func (cs *CleanupScheduler) Start() {
+	log.Info().Msg("[INVITE] Invitation cleanup scheduler started")
+
+	cs.ticker = time.NewTicker(24 * time.Hour)
+	go func() {
+		cs.runCleanup()
+		cs.loop()
+	}()
+}
+
+// loop runs the cleanup task on a schedule
+func (cs *CleanupScheduler) loop() {
+	for {
+		select {
+		case <-cs.ticker.C:
+			cs.runCleanup()
+		case <-cs.done:
+			cs.ticker.Stop()
+			return
+		}
+	}
+}
+
+// runCleanup executes the cleanup task
+func (cs *CleanupScheduler) runCleanup() {
+	deletedCount, err := cs.invitationService.CleanupExpiredInvitations()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[INVITE] Failed to cleanup invitations")
+		return
+	}
+
+	log.Info().
+		Int64("deletedCount", deletedCount).
+		Msg("[INVITE] Invitation cleanup completed")
+}
+
+// Stop stops the cleanup scheduler
+func (cs *CleanupScheduler) Stop() {
+	log.Info().Msg("[INVITE] Stopping invitation cleanup scheduler")
+	if cs.ticker != nil {
+		cs.done <- true
+	}
+}
+
+// RunNow executes cleanup immediately
+func (cs *CleanupScheduler) RunNow() {
+	cs.runCleanup()
+}
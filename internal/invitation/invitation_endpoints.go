@@ -1,7 +1,10 @@
 package invitation
 
 import (
+	"errors"
+
 	"github.com/goccy/go-json"
+	"github.com/prappser/prappser_server/internal/middleware"
 	"github.com/prappser/prappser_server/internal/user"
 	"github.com/rs/zerolog/log"
 	"github.com/valyala/fasthttp"
@@ -54,8 +57,6 @@ func (ie *InvitationEndpoints) CreateInvite(ctx *fasthttp.RequestCtx) {
 		req.Role = "member"
 	}
 
-	// TODO: Verify user is owner of the application
-
 	// Create invitation
 	opts := CreateInvitationOptions{
 		ApplicationID:      appID,
@@ -68,6 +69,10 @@ func (ie *InvitationEndpoints) CreateInvite(ctx *fasthttp.RequestCtx) {
 	response, err := ie.invitationService.CreateInvitation(opts)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create invitation")
+		if errors.Is(err, ErrNotInvitationManager) {
+			ctx.Error("Only application owners and admins can create invitations", fasthttp.StatusForbidden)
+			return
+		}
 		ctx.Error("Failed to create invitation", fasthttp.StatusInternalServerError)
 		return
 	}
@@ -167,6 +172,10 @@ func (ie *InvitationEndpoints) CheckInvitation(ctx *fasthttp.RequestCtx) {
 type JoinRequest struct {
 	PublicKey string `json:"publicKey"`
 	Username  string `json:"username"`
+	Email     string `json:"email,omitempty"`
+	// Signature is the base64 Ed25519 signature of the invitation token made with the
+	// private key of PublicKey
+	Signature string `json:"signature"`
 }
 
 // JoinApplication handles POST /invites/{token}/join
@@ -202,17 +211,26 @@ func (ie *InvitationEndpoints) JoinApplication(ctx *fasthttp.RequestCtx) {
 		ctx.Error("Username is required", fasthttp.StatusBadRequest)
 		return
 	}
+	if req.Signature == "" {
+		log.Error().Msg("[JOIN] Signature is missing")
+		ctx.Error("Signature is required", fasthttp.StatusBadRequest)
+		return
+	}
 
 	log.Debug().Str("username", req.Username).Str("token", token).Msg("[JOIN] Joining application")
 
 	// Join via invitation service (handles user creation, validation, transaction, event production)
-	result, err := ie.invitationService.Join(token, req.PublicKey, req.Username)
+	result, err := ie.invitationService.Join(token, req.PublicKey, req.Username, req.Email, req.Signature)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to join application")
 
 		// Determine appropriate status code based on error message
 		errorMsg := err.Error()
 		switch {
+		case errors.Is(err, ErrEmailRequired), errors.Is(err, ErrInvalidEmail):
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+		case errors.Is(err, ErrInvalidJoinProof):
+			ctx.Error("Invalid join proof", fasthttp.StatusUnauthorized)
 		case errorMsg == "invalid token: failed to parse token: token is expired":
 			ctx.Error("Invitation expired", fasthttp.StatusGone)
 		case errorMsg == "invitation expired":
@@ -252,37 +270,117 @@ func (ie *InvitationEndpoints) RevokeInvite(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	// TODO: Verify user is owner of the application using authenticatedUser.PublicKey
-	_ = authenticatedUser // Will be used in owner verification TODO
+	if err := ie.invitationService.verifyInviteManager(appID, authenticatedUser.PublicKey); err != nil {
+		log.Error().Err(err).Str("appID", appID).Msg("Rejected invitation revoke")
+		ctx.Error("Only application owners and admins can revoke invitations", fasthttp.StatusForbidden)
+		return
+	}
+
+	// Revoke invitation (hard delete)
+	if err := ie.invitationService.RevokeInvitation(appID, inviteID, authenticatedUser.PublicKey); err != nil {
+		log.Error().Err(err).Msg("Failed to revoke invitation")
+		if err.Error() == "invitation not found" {
+			ctx.Error("Invitation not found", fasthttp.StatusNotFound)
+			return
+		}
+		ctx.Error("Failed to revoke invitation", fasthttp.StatusInternalServerError)
+		return
+	}
+
+	// Return success (204 No Content)
+	ctx.SetStatusCode(fasthttp.StatusNoContent)
+}
+
+// UpdateInvite handles PATCH /applications/{appID}/invites/{inviteID}
+func (ie *InvitationEndpoints) UpdateInvite(ctx *fasthttp.RequestCtx) {
+	// Get authenticated user from context
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	// Extract IDs from path
+	appID := ctx.UserValue("appID").(string)
+	inviteID := ctx.UserValue("inviteID").(string)
+
+	if appID == "" || inviteID == "" {
+		ctx.Error("Application ID and Invite ID are required", fasthttp.StatusBadRequest)
+		return
+	}
+
+	// Parse request body
+	var req UpdateInvitationRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		ctx.Error("Invalid request body", fasthttp.StatusBadRequest)
+		return
+	}
 
-	// Get invitation to verify it exists
-	invite, err := ie.invitationService.repo.GetByID(inviteID)
+	response, err := ie.invitationService.UpdateInvitation(appID, inviteID, authenticatedUser.PublicKey, req)
 	if err != nil {
-		log.Error().Err(err).Msg("Invitation not found")
-		ctx.Error("Invitation not found", fasthttp.StatusNotFound)
+		log.Error().Err(err).Str("inviteID", inviteID).Msg("Failed to update invitation")
+		switch {
+		case errors.Is(err, ErrNotInvitationManager):
+			ctx.Error("Only application owners and admins can update invitations", fasthttp.StatusForbidden)
+		case errors.Is(err, ErrInvalidInvitationUpdate):
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+		case err.Error() == "invitation not found":
+			ctx.Error("Invitation not found", fasthttp.StatusNotFound)
+		default:
+			ctx.Error("Failed to update invitation", fasthttp.StatusInternalServerError)
+		}
 		return
 	}
 
-	// Verify invite belongs to the application
-	if invite.ApplicationID != appID {
-		ctx.Error("Invitation does not belong to this application", fasthttp.StatusBadRequest)
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(response)
+}
+
+// PreviewInvite handles GET /applications/{appID}/invites/{inviteID}/preview
+// Returns the info a recipient of the invitation would see, including when it is no
+// longer valid, so the owner can verify it before sharing.
+func (ie *InvitationEndpoints) PreviewInvite(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
 		return
 	}
 
-	// Revoke invitation (hard delete)
-	if err := ie.invitationService.RevokeInvitation(inviteID); err != nil {
-		log.Error().Err(err).Msg("Failed to revoke invitation")
-		ctx.Error("Failed to revoke invitation", fasthttp.StatusInternalServerError)
+	appID := ctx.UserValue("appID").(string)
+	inviteID := ctx.UserValue("inviteID").(string)
+
+	if appID == "" || inviteID == "" {
+		ctx.Error("Application ID and Invite ID are required", fasthttp.StatusBadRequest)
 		return
 	}
 
-	// TODO: Produce invite_revoked event
+	info, err := ie.invitationService.PreviewInvitation(appID, inviteID, authenticatedUser.PublicKey)
+	if err != nil {
+		log.Error().Err(err).Str("inviteID", inviteID).Msg("Failed to preview invitation")
+		switch {
+		case errors.Is(err, ErrNotInvitationManager):
+			ctx.Error("Only application owners and admins can preview invitations", fasthttp.StatusForbidden)
+		case err.Error() == "invitation not found":
+			ctx.Error("Invitation not found", fasthttp.StatusNotFound)
+		default:
+			ctx.Error("Failed to preview invitation", fasthttp.StatusInternalServerError)
+		}
+		return
+	}
 
-	// Return success (204 No Content)
-	ctx.SetStatusCode(fasthttp.StatusNoContent)
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(info)
 }
 
 // ListInvites handles GET /applications/{appID}/invites
+// Query parameters:
+//   - limit (optional, default: 50, max: 200): Maximum invitations to return
+//   - offset (optional, default: 0): Number of invitations to skip
 func (ie *InvitationEndpoints) ListInvites(ctx *fasthttp.RequestCtx) {
 	// Get authenticated user from context
 	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
@@ -299,12 +397,15 @@ func (ie *InvitationEndpoints) ListInvites(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	// TODO: Verify user is owner or admin of the application
-
-	_ = authenticatedUser // Will be used in TODO above
+	if err := ie.invitationService.verifyInviteManager(appID, authenticatedUser.PublicKey); err != nil {
+		log.Error().Err(err).Str("appID", appID).Msg("Rejected invitation listing")
+		ctx.Error("Only application owners and admins can list invitations", fasthttp.StatusForbidden)
+		return
+	}
 
 	// Get invites for application
-	invites, err := ie.invitationService.GetInvitesForApp(appID)
+	pagination := middleware.ParsePagination(ctx, DefaultInvitesPageSize, MaxInvitesPageSize)
+	invites, err := ie.invitationService.GetInvitesForApp(appID, pagination.Limit, pagination.Offset)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to get invites")
 		ctx.Error("Failed to get invites", fasthttp.StatusInternalServerError)
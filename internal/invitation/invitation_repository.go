@@ -10,10 +10,14 @@ type InvitationRepository interface {
 	Create(invite *Invitation) error
 	GetByID(id string) (*Invitation, error)
 	Delete(id string) error
+	Update(invite *Invitation) error
 	IncrementUseCount(id string) error
 	RecordUse(inviteID, userPublicKey string, useID string) error
-	GetByApplicationID(appID string) ([]*Invitation, error)
+	GetByApplicationID(appID string, limit, offset int) ([]*Invitation, error)
 	HasBeenUsedBy(inviteID, userPublicKey string) (bool, error)
+	CreateShortCode(shortCode *ShortCode) error
+	GetShortCode(code string) (*ShortCode, error)
+	DeleteExpired(now int64) (int64, error)
 }
 
 type invitationRepository struct {
@@ -28,8 +32,8 @@ func (r *invitationRepository) Create(invite *Invitation) error {
 	query := `
 		INSERT INTO invitations (
 			id, application_id, created_by_public_key,
-			role, max_uses, used_count, created_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+			role, max_uses, used_count, expires_at, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`
 
 	_, err := r.db.Exec(query,
@@ -39,6 +43,7 @@ func (r *invitationRepository) Create(invite *Invitation) error {
 		invite.Role,
 		invite.MaxUses,
 		invite.UsedCount,
+		invite.ExpiresAt,
 		invite.CreatedAt,
 	)
 
@@ -48,7 +53,7 @@ func (r *invitationRepository) Create(invite *Invitation) error {
 func (r *invitationRepository) GetByID(id string) (*Invitation, error) {
 	query := `
 		SELECT id, application_id, created_by_public_key,
-		       role, max_uses, used_count, created_at
+		       role, max_uses, used_count, expires_at, created_at
 		FROM invitations
 		WHERE id = $1
 	`
@@ -61,6 +66,7 @@ func (r *invitationRepository) GetByID(id string) (*Invitation, error) {
 		&invite.Role,
 		&invite.MaxUses,
 		&invite.UsedCount,
+		&invite.ExpiresAt,
 		&invite.CreatedAt,
 	)
 
@@ -94,6 +100,30 @@ func (r *invitationRepository) Delete(id string) error {
 	return nil
 }
 
+func (r *invitationRepository) Update(invite *Invitation) error {
+	query := `
+		UPDATE invitations
+		SET max_uses = $1, expires_at = $2
+		WHERE id = $3
+	`
+
+	result, err := r.db.Exec(query, invite.MaxUses, invite.ExpiresAt, invite.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("invitation not found")
+	}
+
+	return nil
+}
+
 func (r *invitationRepository) IncrementUseCount(id string) error {
 	query := `
 		UPDATE invitations
@@ -128,16 +158,17 @@ func (r *invitationRepository) RecordUse(inviteID, userPublicKey string, useID s
 	return err
 }
 
-func (r *invitationRepository) GetByApplicationID(appID string) ([]*Invitation, error) {
+func (r *invitationRepository) GetByApplicationID(appID string, limit, offset int) ([]*Invitation, error) {
 	query := `
 		SELECT id, application_id, created_by_public_key,
-		       role, max_uses, used_count, created_at
+		       role, max_uses, used_count, expires_at, created_at
 		FROM invitations
 		WHERE application_id = $1
-		ORDER BY created_at DESC
+		ORDER BY created_at DESC, id
+		LIMIT $2 OFFSET $3
 	`
 
-	rows, err := r.db.Query(query, appID)
+	rows, err := r.db.Query(query, appID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -153,6 +184,7 @@ func (r *invitationRepository) GetByApplicationID(appID string) ([]*Invitation,
 			&invite.Role,
 			&invite.MaxUses,
 			&invite.UsedCount,
+			&invite.ExpiresAt,
 			&invite.CreatedAt,
 		)
 		if err != nil {
@@ -183,3 +215,64 @@ func (r *invitationRepository) HasBeenUsedBy(inviteID, userPublicKey string) (bo
 
 	return count > 0, nil
 }
+
+func (r *invitationRepository) CreateShortCode(shortCode *ShortCode) error {
+	query := `
+		INSERT INTO invitation_short_codes (
+			code, invitation_id, token, expires_at, created_at
+		) VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err := r.db.Exec(query,
+		shortCode.Code,
+		shortCode.InvitationID,
+		shortCode.Token,
+		shortCode.ExpiresAt,
+		shortCode.CreatedAt,
+	)
+
+	return err
+}
+
+func (r *invitationRepository) GetShortCode(code string) (*ShortCode, error) {
+	query := `
+		SELECT code, invitation_id, token, expires_at, created_at
+		FROM invitation_short_codes
+		WHERE code = $1
+	`
+
+	shortCode := &ShortCode{}
+	err := r.db.QueryRow(query, code).Scan(
+		&shortCode.Code,
+		&shortCode.InvitationID,
+		&shortCode.Token,
+		&shortCode.ExpiresAt,
+		&shortCode.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrShortCodeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return shortCode, nil
+}
+
+// DeleteExpired removes invitations that expired before now, with their uses and short
+// codes. Invitations that reached their max uses are kept, since their recorded uses let
+// earlier joiners rejoin; revoked invitations are already deleted on revocation.
+func (r *invitationRepository) DeleteExpired(now int64) (int64, error) {
+	query := `
+		DELETE FROM invitations
+		WHERE expires_at IS NOT NULL AND expires_at < $1
+	`
+
+	result, err := r.db.Exec(query, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired invitations: %w", err)
+	}
+
+	return result.RowsAffected()
+}
@@ -3,13 +3,16 @@ package invitation
 import (
 	"context"
 	"crypto/ed25519"
-	"database/sql"
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/prappser/prappser_server/internal/application"
+	"github.com/prappser/prappser_server/internal/clock"
 	"github.com/prappser/prappser_server/internal/event"
 	"github.com/prappser/prappser_server/internal/user"
 	"github.com/rs/zerolog/log"
@@ -19,6 +22,11 @@ const (
 	MaxExpirationHours = 48
 )
 
+var (
+	ErrInvalidInvitationUpdate = errors.New("invalid invitation update")
+	ErrNotInvitationManager    = errors.New("not an owner or admin of this application")
+)
+
 type EventService interface {
 	AcceptEvent(ctx context.Context, e *event.Event, submitter *user.User) (*event.Event, error)
 	ProduceEvent(ctx context.Context, e *event.Event) (*event.Event, error)
@@ -29,22 +37,33 @@ type InvitationService struct {
 	privateKey     ed25519.PrivateKey
 	publicKey      ed25519.PublicKey
 	appRepo        application.ApplicationRepository
-	db             *sql.DB
 	externalURL    string
 	userRepository user.UserRepository
 	eventService   EventService
+	config         Config
+	clock          clock.Clock
 }
 
-func NewInvitationService(repo InvitationRepository, privateKey ed25519.PrivateKey, publicKey ed25519.PublicKey, appRepo application.ApplicationRepository, db *sql.DB, externalURL string, userRepository user.UserRepository, eventService EventService) *InvitationService {
+func NewInvitationService(repo InvitationRepository, privateKey ed25519.PrivateKey, publicKey ed25519.PublicKey, appRepo application.ApplicationRepository, externalURL string, userRepository user.UserRepository, eventService EventService, config Config) *InvitationService {
+	if config.DeepLinkScheme == "" {
+		config.DeepLinkScheme = DefaultDeepLinkScheme
+	}
+	if config.ShortCodeTTL <= 0 {
+		config.ShortCodeTTL = DefaultShortCodeTTL
+	}
+	if config.Clock == nil {
+		config.Clock = clock.System
+	}
 	return &InvitationService{
 		repo:           repo,
 		privateKey:     privateKey,
 		publicKey:      publicKey,
 		appRepo:        appRepo,
-		db:             db,
 		externalURL:    externalURL,
 		userRepository: userRepository,
 		eventService:   eventService,
+		config:         config,
+		clock:          config.Clock,
 	}
 }
 
@@ -70,10 +89,10 @@ func (s *InvitationService) CreateInvitation(opts CreateInvitationOptions) (*Inv
 		opts.Role = "member" // default
 	}
 
-	// Validate expiration
+	// Validate expiration; an invitation without one never expires
 	if opts.ExpiresInHours != nil {
-		if *opts.ExpiresInHours < 0 || *opts.ExpiresInHours > MaxExpirationHours {
-			return nil, fmt.Errorf("expiration hours must be between 0 and %d", MaxExpirationHours)
+		if *opts.ExpiresInHours < 1 || *opts.ExpiresInHours > MaxExpirationHours {
+			return nil, fmt.Errorf("expiration hours must be between 1 and %d", MaxExpirationHours)
 		}
 	}
 
@@ -82,8 +101,19 @@ func (s *InvitationService) CreateInvitation(opts CreateInvitationOptions) (*Inv
 		return nil, fmt.Errorf("max uses must be at least 1")
 	}
 
+	if err := s.verifyInviteManager(opts.ApplicationID, opts.CreatedByPublicKey); err != nil {
+		return nil, err
+	}
+
+	// Compute expiry up front so it is persisted alongside the invitation
+	var expiresAt *int64
+	if opts.ExpiresInHours != nil {
+		exp := s.clock.Now().Add(time.Duration(*opts.ExpiresInHours) * time.Hour).Unix()
+		expiresAt = &exp
+	}
+
 	// Create invitation
-	now := time.Now().Unix()
+	now := s.clock.Now().Unix()
 	invite := &Invitation{
 		ID:                 uuid.New().String(), // TODO: Use UUID v7
 		ApplicationID:      opts.ApplicationID,
@@ -91,6 +121,7 @@ func (s *InvitationService) CreateInvitation(opts CreateInvitationOptions) (*Inv
 		Role:               opts.Role,
 		MaxUses:            opts.MaxUses,
 		UsedCount:          0,
+		ExpiresAt:          expiresAt,
 		CreatedAt:          now,
 	}
 
@@ -100,34 +131,176 @@ func (s *InvitationService) CreateInvitation(opts CreateInvitationOptions) (*Inv
 	}
 
 	// Generate JWT token
-	var expiresAt *int64
-	if opts.ExpiresInHours != nil {
-		exp := time.Now().Add(time.Duration(*opts.ExpiresInHours) * time.Hour).Unix()
-		expiresAt = &exp
-	}
-
 	token, err := s.GenerateToken(invite.ID, s.externalURL, expiresAt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
-	// Build response - use HTTPS PWA URL for sharing
-	pwaURL := "https://prappser-app.netlify.app"
+	shortCode, err := s.createShortCode(invite, token)
+	if err != nil {
+		return nil, err
+	}
+
+	url, deepLink := s.buildInvitationLinks("token", token)
+	shortURL, shortDeepLink := s.buildInvitationLinks("code", shortCode.Code)
 	response := &InvitationResponse{
-		ID:        invite.ID,
-		Token:     token,
-		URL:       fmt.Sprintf("%s/join?token=%s", pwaURL, token),
-		DeepLink:  fmt.Sprintf("prappser://join?token=%s", token),
-		ExpiresAt: expiresAt,
-		CreatedAt: now,
+		ID:            invite.ID,
+		Token:         token,
+		URL:           url,
+		DeepLink:      deepLink,
+		Code:          shortCode.Code,
+		ShortURL:      shortURL,
+		ShortDeepLink: shortDeepLink,
+		CodeExpiresAt: shortCode.ExpiresAt,
+		ExpiresAt:     expiresAt,
+		CreatedAt:     now,
 	}
 
 	return response, nil
 }
 
+// buildInvitationLinks returns the shareable HTTPS PWA URL and the app deep link carrying
+// the token or short code in the given query parameter
+func (s *InvitationService) buildInvitationLinks(param, value string) (url, deepLink string) {
+	pwaURL := "https://prappser-app.netlify.app"
+	return fmt.Sprintf("%s/join?%s=%s", pwaURL, param, value), fmt.Sprintf("%s://join?%s=%s", s.config.DeepLinkScheme, param, value)
+}
+
+// createShortCode stores a short code for the token. The code expires with the invitation,
+// but never later than ShortCodeTTL from now.
+func (s *InvitationService) createShortCode(invite *Invitation, token string) (*ShortCode, error) {
+	now := s.clock.Now()
+	expiresAt := now.Add(s.config.ShortCodeTTL).Unix()
+	if invite.ExpiresAt != nil && *invite.ExpiresAt < expiresAt {
+		expiresAt = *invite.ExpiresAt
+	}
+
+	code, err := generateShortCode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate invitation code: %w", err)
+	}
+
+	shortCode := &ShortCode{
+		Code:         code,
+		InvitationID: invite.ID,
+		Token:        token,
+		ExpiresAt:    expiresAt,
+		CreatedAt:    now.Unix(),
+	}
+	if err := s.repo.CreateShortCode(shortCode); err != nil {
+		return nil, fmt.Errorf("failed to store invitation code: %w", err)
+	}
+	return shortCode, nil
+}
+
+// ResolveShortCode returns the full invitation token behind a short code
+func (s *InvitationService) ResolveShortCode(code string) (string, error) {
+	shortCode, err := s.repo.GetShortCode(code)
+	if err != nil {
+		return "", err
+	}
+	if shortCode.IsExpired(s.clock.Now()) {
+		return "", ErrShortCodeExpired
+	}
+	return shortCode.Token, nil
+}
+
+// generateShortCode returns a random code from an alphabet without look-alike characters,
+// so codes survive being read aloud or typed from a screen
+func generateShortCode() (string, error) {
+	const alphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+	bytes := make([]byte, shortCodeLength)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	for i, b := range bytes {
+		bytes[i] = alphabet[int(b)%len(alphabet)]
+	}
+	return string(bytes), nil
+}
+
+// UpdateInvitation changes an invitation's max uses and/or expiry and re-issues its token.
+// A new expiry is at least an hour from now; an existing expiry can be moved but not
+// removed. Owners and admins may update an invitation, like they may create and revoke one.
+func (s *InvitationService) UpdateInvitation(appID, inviteID, requesterPublicKey string, req UpdateInvitationRequest) (*UpdateInvitationResponse, error) {
+	if req.MaxUses == nil && req.ExpiresInHours == nil {
+		return nil, fmt.Errorf("%w: maxUses or expiresInHours is required", ErrInvalidInvitationUpdate)
+	}
+
+	if err := s.verifyInviteManager(appID, requesterPublicKey); err != nil {
+		return nil, err
+	}
+
+	invite, err := s.repo.GetByID(inviteID)
+	if err != nil {
+		return nil, err
+	}
+	if invite.ApplicationID != appID {
+		return nil, fmt.Errorf("invitation not found")
+	}
+
+	if req.MaxUses != nil {
+		if *req.MaxUses < 1 {
+			return nil, fmt.Errorf("%w: max uses must be at least 1", ErrInvalidInvitationUpdate)
+		}
+		if *req.MaxUses < invite.UsedCount {
+			return nil, fmt.Errorf("%w: max uses cannot be lower than used count (%d)", ErrInvalidInvitationUpdate, invite.UsedCount)
+		}
+		invite.MaxUses = req.MaxUses
+	}
+
+	if req.ExpiresInHours != nil {
+		if *req.ExpiresInHours < 1 || *req.ExpiresInHours > MaxExpirationHours {
+			return nil, fmt.Errorf("%w: expiration hours must be between 1 and %d", ErrInvalidInvitationUpdate, MaxExpirationHours)
+		}
+		exp := s.clock.Now().Add(time.Duration(*req.ExpiresInHours) * time.Hour).Unix()
+		invite.ExpiresAt = &exp
+	}
+
+	if err := s.repo.Update(invite); err != nil {
+		return nil, fmt.Errorf("failed to update invitation: %w", err)
+	}
+
+	token, err := s.GenerateToken(invite.ID, s.externalURL, invite.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	shortCode, err := s.createShortCode(invite, token)
+	if err != nil {
+		return nil, err
+	}
+
+	url, deepLink := s.buildInvitationLinks("token", token)
+	shortURL, shortDeepLink := s.buildInvitationLinks("code", shortCode.Code)
+	return &UpdateInvitationResponse{
+		Invitation:    invite,
+		Token:         token,
+		URL:           url,
+		DeepLink:      deepLink,
+		Code:          shortCode.Code,
+		ShortURL:      shortURL,
+		ShortDeepLink: shortDeepLink,
+		CodeExpiresAt: shortCode.ExpiresAt,
+	}, nil
+}
+
+// inviteManagerRoles are the member roles that manage an application's invitations:
+// creating, listing, updating, previewing and revoking them
+var inviteManagerRoles = []application.MemberRole{application.MemberRoleOwner, application.MemberRoleAdmin}
+
+// verifyInviteManager returns ErrNotInvitationManager unless the public key belongs to a
+// member holding one of inviteManagerRoles
+func (s *InvitationService) verifyInviteManager(appID, publicKey string) error {
+	if !application.HasMemberRole(s.appRepo, appID, publicKey, inviteManagerRoles...) {
+		return ErrNotInvitationManager
+	}
+	return nil
+}
+
 // GenerateToken creates a signed JWT token for an invitation
 func (s *InvitationService) GenerateToken(inviteID, serverURL string, expiresAt *int64) (string, error) {
-	now := time.Now()
+	now := s.clock.Now()
 
 	issuedAt := now.Unix()
 	notBefore := now.Unix()
@@ -155,8 +328,18 @@ func (s *InvitationService) GenerateToken(inviteID, serverURL string, expiresAt
 	return tokenString, nil
 }
 
-// ValidateToken verifies a JWT token and returns the claims
+// ValidateToken verifies a JWT token and returns the claims. A short code is accepted in
+// place of the token and resolved to the token it stands for.
 func (s *InvitationService) ValidateToken(tokenString string) (*InviteTokenClaims, error) {
+	// Signed tokens always contain dots; short codes never do
+	if !strings.Contains(tokenString, ".") {
+		resolved, err := s.ResolveShortCode(tokenString)
+		if err != nil {
+			return nil, err
+		}
+		tokenString = resolved
+	}
+
 	// Parse token
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		// Verify signing method - accept EdDSA
@@ -164,7 +347,7 @@ func (s *InvitationService) ValidateToken(tokenString string) (*InviteTokenClaim
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return s.publicKey, nil
-	})
+	}, jwt.WithTimeFunc(s.clock.Now))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -216,12 +399,6 @@ func (s *InvitationService) GetInviteInfo(tokenString string) (*InviteInfo, erro
 		Str("inviteId", claims.InviteID).
 		Msg("[INVITE] Token validated")
 
-	// Check expiration from JWT
-	isExpired := false
-	if claims.ExpiresAt != nil {
-		isExpired = time.Now().Unix() > *claims.ExpiresAt
-	}
-
 	// Get invitation from database
 	invite, err := s.repo.GetByID(claims.InviteID)
 	if err != nil {
@@ -229,6 +406,7 @@ func (s *InvitationService) GetInviteInfo(tokenString string) (*InviteInfo, erro
 			Str("inviteId", claims.InviteID).
 			Err(err).
 			Msg("[INVITE] Invite not found in database")
+		isExpired := claims.ExpiresAt != nil && s.clock.Now().Unix() > *claims.ExpiresAt
 		return &InviteInfo{
 			InviteID:  claims.InviteID,
 			IsExpired: isExpired,
@@ -239,6 +417,44 @@ func (s *InvitationService) GetInviteInfo(tokenString string) (*InviteInfo, erro
 		Str("inviteId", invite.ID).
 		Msg("[INVITE] Invite found in database")
 
+	return s.buildInviteInfo(invite, claims.ExpiresAt), nil
+}
+
+// PreviewInvitation returns the information a recipient of the invitation would see, so
+// an owner or admin can check an invitation before sharing it. Unlike the public
+// info endpoint it also describes expired and exhausted invitations.
+func (s *InvitationService) PreviewInvitation(appID, inviteID, requesterPublicKey string) (*InviteInfo, error) {
+	if err := s.verifyInviteManager(appID, requesterPublicKey); err != nil {
+		return nil, err
+	}
+
+	invite, err := s.repo.GetByID(inviteID)
+	if err != nil {
+		return nil, err
+	}
+	if invite.ApplicationID != appID {
+		return nil, fmt.Errorf("invitation not found")
+	}
+
+	return s.buildInviteInfo(invite, invite.ExpiresAt), nil
+}
+
+// buildInviteInfo describes a stored invitation as shown to recipients. tokenExpiresAt is
+// the expiry carried by the presented token; the persisted expiry takes precedence.
+func (s *InvitationService) buildInviteInfo(invite *Invitation, tokenExpiresAt *int64) *InviteInfo {
+	// Check expiration from JWT
+	isExpired := false
+	if tokenExpiresAt != nil {
+		isExpired = s.clock.Now().Unix() > *tokenExpiresAt
+	}
+
+	// Persisted expiry takes precedence, since it may have been updated after the token was issued
+	expiresAt := tokenExpiresAt
+	if invite.ExpiresAt != nil {
+		expiresAt = invite.ExpiresAt
+		isExpired = invite.IsExpired(s.clock.Now())
+	}
+
 	// Check max uses
 	isMaxUsesReached := false
 	if invite.MaxUses != nil && invite.UsedCount >= *invite.MaxUses {
@@ -287,7 +503,7 @@ func (s *InvitationService) GetInviteInfo(tokenString string) (*InviteInfo, erro
 		ApplicationIcon: applicationIcon,
 		CreatorUsername: creatorUsername,
 		Role:            invite.Role,
-		ExpiresAt:       claims.ExpiresAt,
+		ExpiresAt:       expiresAt,
 		IsExpired:       isExpired,
 		IsValid:         !isExpired && !isMaxUsesReached,
 	}
@@ -297,9 +513,9 @@ func (s *InvitationService) GetInviteInfo(tokenString string) (*InviteInfo, erro
 		Bool("isValid", info.IsValid).
 		Bool("isExpired", isExpired).
 		Bool("isMaxUsesReached", isMaxUsesReached).
-		Msg("[INVITE] GetInviteInfo complete")
+		Msg("[INVITE] Invite info built")
 
-	return info, nil
+	return info
 }
 
 // CheckInvitationUsage checks if an invitation can be used by a specific user
@@ -320,7 +536,7 @@ func (s *InvitationService) CheckInvitationUsage(tokenString, userPublicKey stri
 	}
 
 	// Check expiration
-	if claims.ExpiresAt != nil && time.Now().Unix() > *claims.ExpiresAt {
+	if claims.ExpiresAt != nil && s.clock.Now().Unix() > *claims.ExpiresAt {
 		result.IsExpired = true
 		result.Message = "This invitation has expired"
 		return result, nil
@@ -333,6 +549,12 @@ func (s *InvitationService) CheckInvitationUsage(tokenString, userPublicKey stri
 		return result, nil
 	}
 
+	if invite.IsExpired(s.clock.Now()) {
+		result.IsExpired = true
+		result.Message = "This invitation has expired"
+		return result, nil
+	}
+
 	// Get application info
 	app, err := s.appRepo.GetApplicationByID(invite.ApplicationID)
 	if err == nil && app != nil {
@@ -340,19 +562,19 @@ func (s *InvitationService) CheckInvitationUsage(tokenString, userPublicKey stri
 	}
 	result.Role = invite.Role
 
-	// Check max uses
-	if invite.MaxUses != nil && invite.UsedCount >= *invite.MaxUses {
-		result.MaxUsesReached = true
-		result.Message = "This invitation has reached its maximum number of uses"
-		return result, nil
-	}
-
 	// Check if user has already used this invitation
 	alreadyUsed, err := s.repo.HasBeenUsedBy(invite.ID, userPublicKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check invitation usage: %w", err)
 	}
 
+	// Check max uses; a previous user's use is already counted, so it never exhausts for them
+	if !alreadyUsed && invite.MaxUses != nil && invite.UsedCount >= *invite.MaxUses {
+		result.MaxUsesReached = true
+		result.Message = "This invitation has reached its maximum number of uses"
+		return result, nil
+	}
+
 	if alreadyUsed {
 		// Check if still a member
 		isMember, _ := s.appRepo.IsMember(invite.ApplicationID, userPublicKey)
@@ -383,25 +605,71 @@ func (s *InvitationService) CheckInvitationUsage(tokenString, userPublicKey stri
 	return result, nil
 }
 
-// RevokeInvitation deletes an invitation (hard delete)
-func (s *InvitationService) RevokeInvitation(inviteID string) error {
-	return s.repo.Delete(inviteID)
+// RevokeInvitation deletes an invitation (hard delete) and produces an invite_revoked event
+// so connected members learn the invitation is gone. An invitation of another application
+// is reported as not found. The revocation stands even if the event cannot be produced.
+func (s *InvitationService) RevokeInvitation(appID, inviteID, revokerPublicKey string) error {
+	invite, err := s.repo.GetByID(inviteID)
+	if err != nil {
+		return err
+	}
+	if invite.ApplicationID != appID {
+		return fmt.Errorf("invitation not found")
+	}
+
+	if err := s.repo.Delete(inviteID); err != nil {
+		return err
+	}
+
+	evt := &event.Event{
+		ID:               uuid.New().String(),
+		Type:             event.EventTypeInviteRevoked,
+		CreatorPublicKey: revokerPublicKey,
+		Data: map[string]interface{}{
+			"applicationId": appID,
+			"inviteId":      inviteID,
+			"version":       1,
+		},
+		CreatedAt:     s.clock.Now().Unix(),
+		ApplicationID: appID,
+	}
+
+	if _, err := s.eventService.ProduceEvent(context.Background(), evt); err != nil {
+		log.Error().
+			Str("inviteId", inviteID).
+			Err(err).
+			Msg("[INVITE] Failed to produce invite_revoked event")
+	}
+	return nil
 }
 
-// GetInvitesForApp returns all active invitations for an application
-func (s *InvitationService) GetInvitesForApp(appID string) ([]*Invitation, error) {
-	return s.repo.GetByApplicationID(appID)
+// CleanupExpiredInvitations deletes invitations that have expired and returns how many
+// were deleted
+func (s *InvitationService) CleanupExpiredInvitations() (int64, error) {
+	deleted, err := s.repo.DeleteExpired(s.clock.Now().Unix())
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup invitations: %w", err)
+	}
+	return deleted, nil
+}
+
+// GetInvitesForApp returns a page of an application's invitations, newest first
+func (s *InvitationService) GetInvitesForApp(appID string, limit, offset int) ([]*Invitation, error) {
+	return s.repo.GetByApplicationID(appID, limit, offset)
 }
 
 // JoinResult contains the result of a successful join operation
 type JoinResult struct {
-	ApplicationID string `json:"applicationId"`
-	MemberID      string `json:"memberId"`
-	IsNewMember   bool   `json:"isNewMember"`
+	ApplicationID string                 `json:"applicationId"`
+	MemberID      string                 `json:"memberId"`
+	MemberName    string                 `json:"memberName"`
+	Role          application.MemberRole `json:"role"`
+	IsNewMember   bool                   `json:"isNewMember"`
 }
 
-// Join handles the complete join flow with transaction
-func (s *InvitationService) Join(tokenString, userPublicKey, userName string) (*JoinResult, error) {
+// Join handles the complete join flow with transaction. proof is the joiner's signature
+// of the token, see VerifyJoinProof.
+func (s *InvitationService) Join(tokenString, userPublicKey, userName, email, proof string) (*JoinResult, error) {
 	log.Debug().
 		Str("username", userName).
 		Str("publicKey", userPublicKey[:20]+"...").
@@ -417,8 +685,17 @@ func (s *InvitationService) Join(tokenString, userPublicKey, userName string) (*
 		Str("inviteId", claims.InviteID).
 		Msg("[INVITE] Token validated")
 
+	// The joiner must prove they control the key they are adding as a member
+	if err := VerifyJoinProof(userPublicKey, tokenString, proof); err != nil {
+		log.Debug().
+			Str("inviteId", claims.InviteID).
+			Err(err).
+			Msg("[INVITE] Join failed: invalid join proof")
+		return nil, err
+	}
+
 	// Check expiration
-	if claims.ExpiresAt != nil && time.Now().Unix() > *claims.ExpiresAt {
+	if claims.ExpiresAt != nil && s.clock.Now().Unix() > *claims.ExpiresAt {
 		log.Debug().
 			Str("inviteId", claims.InviteID).
 			Msg("[INVITE] Join failed: invitation expired")
@@ -439,8 +716,22 @@ func (s *InvitationService) Join(tokenString, userPublicKey, userName string) (*
 		Str("appId", invite.ApplicationID).
 		Msg("[INVITE] Invitation found")
 
+	if invite.IsExpired(s.clock.Now()) {
+		log.Debug().
+			Str("inviteId", invite.ID).
+			Msg("[INVITE] Join failed: invitation expired")
+		return nil, fmt.Errorf("invitation expired")
+	}
+
+	// Users who joined through this invitation before are already counted, so a member
+	// who left may rejoin even once the invitation is exhausted
+	rejoining, err := s.repo.HasBeenUsedBy(invite.ID, userPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check invitation usage: %w", err)
+	}
+
 	// Check max uses
-	if invite.MaxUses != nil && invite.UsedCount >= *invite.MaxUses {
+	if !rejoining && invite.MaxUses != nil && invite.UsedCount >= *invite.MaxUses {
 		log.Debug().
 			Str("inviteId", invite.ID).
 			Int("usedCount", invite.UsedCount).
@@ -449,6 +740,15 @@ func (s *InvitationService) Join(tokenString, userPublicKey, userName string) (*
 		return nil, fmt.Errorf("invitation has reached maximum uses")
 	}
 
+	// Check contact email for applications that require one
+	if err := s.checkJoinEmail(invite.ApplicationID, email); err != nil {
+		log.Debug().
+			Str("inviteId", invite.ID).
+			Err(err).
+			Msg("[INVITE] Join failed: contact email rejected")
+		return nil, err
+	}
+
 	// Create user if doesn't exist (for member authentication)
 	log.Debug().Str("publicKey", userPublicKey[:20]+"...").Str("username", userName).Msg("[JOIN_SERVICE] Checking if user exists")
 	existingUser, err := s.userRepository.GetUserByPublicKey(userPublicKey)
@@ -465,10 +765,10 @@ func (s *InvitationService) Join(tokenString, userPublicKey, userName string) (*
 			PublicKey: userPublicKey,
 			Username:  userName,
 			Role:      "member",
-			CreatedAt: time.Now().Unix(),
+			CreatedAt: s.clock.Now().Unix(),
 		}
 
-		if err := s.userRepository.CreateUser(newUser); err != nil {
+		if err := user.CreateMemberUser(s.userRepository, newUser); err != nil {
 			log.Error().Err(err).Str("username", userName).Msg("[JOIN_SERVICE] Failed to create user")
 			return nil, fmt.Errorf("failed to create user: %w", err)
 		}
@@ -496,9 +796,16 @@ func (s *InvitationService) Join(tokenString, userPublicKey, userName string) (*
 			return nil, fmt.Errorf("failed to get member: %w", err)
 		}
 
+		// A retry after a join that failed once the member existed repairs what is missing
+		if err := s.completeJoin(invite.ID, member, email); err != nil {
+			return nil, err
+		}
+
 		return &JoinResult{
 			ApplicationID: invite.ApplicationID,
 			MemberID:      member.ID,
+			MemberName:    member.Name,
+			Role:          member.Role,
 			IsNewMember:   false,
 		}, nil
 	}
@@ -517,7 +824,7 @@ func (s *InvitationService) Join(tokenString, userPublicKey, userName string) (*
 			"inviteId":        invite.ID,
 			"version":         1,
 		},
-		CreatedAt:     time.Now().Unix(),
+		CreatedAt:     s.clock.Now().Unix(),
 		ApplicationID: invite.ApplicationID,
 	}
 
@@ -542,27 +849,18 @@ func (s *InvitationService) Join(tokenString, userPublicKey, userName string) (*
 		Str("userPublicKey", userPublicKey[:20]+"...").
 		Msg("[INVITE] member_added event produced and executed")
 
-	// Begin transaction for invitation usage tracking
-	tx, err := s.db.Begin()
-	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	// Increment invitation used count
-	if err := s.repo.IncrementUseCount(invite.ID); err != nil {
-		return nil, fmt.Errorf("failed to increment use count: %w", err)
-	}
-
-	// Record usage in invitation_uses table
-	useID := uuid.New().String()
-	if err := s.repo.RecordUse(invite.ID, userPublicKey, useID); err != nil {
-		return nil, fmt.Errorf("failed to record invitation use: %w", err)
+	// The member was created by executing the event; load it so the client learns its ID
+	member, err := s.appRepo.GetMemberByPublicKey(invite.ApplicationID, userPublicKey)
+	if err != nil || member == nil {
+		log.Error().
+			Str("inviteId", invite.ID).
+			Err(err).
+			Msg("[INVITE] Member missing after member_added event")
+		return nil, fmt.Errorf("failed to get created member: %w", err)
 	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	if err := s.completeJoin(invite.ID, member, email); err != nil {
+		return nil, err
 	}
 
 	log.Info().
@@ -575,7 +873,65 @@ func (s *InvitationService) Join(tokenString, userPublicKey, userName string) (*
 
 	return &JoinResult{
 		ApplicationID: invite.ApplicationID,
-		MemberID:      "", // Member ID generated by event execution
+		MemberID:      member.ID,
+		MemberName:    member.Name,
+		Role:          member.Role,
 		IsNewMember:   true,
 	}, nil
 }
+
+// completeJoin stores the member's contact email and counts the use of the invitation.
+// Both happen after member_added is committed, so a joining client's retry, which finds
+// the member already added, runs it again to fill in whatever did not get written.
+func (s *InvitationService) completeJoin(inviteID string, member *application.Member, email string) error {
+	// The contact email stays out of the member_added event, which every member and
+	// webhook receives; it is stored on the member only for owners and admins to read
+	if email != "" && member.Email == "" {
+		member.Email = email
+		if err := s.appRepo.UpdateMember(member); err != nil {
+			return fmt.Errorf("failed to store member email: %w", err)
+		}
+	}
+
+	// Count the use once per user
+	return s.recordInvitationUse(inviteID, member.PublicKey)
+}
+
+// recordInvitationUse counts a join against the invitation. Each user counts once, so
+// leave and rejoin cycles through the same invitation do not use it up.
+func (s *InvitationService) recordInvitationUse(inviteID, userPublicKey string) error {
+	alreadyUsed, err := s.repo.HasBeenUsedBy(inviteID, userPublicKey)
+	if err != nil {
+		return fmt.Errorf("failed to check invitation usage: %w", err)
+	}
+	if alreadyUsed {
+		log.Debug().
+			Str("inviteId", inviteID).
+			Msg("[INVITE] Rejoin through a previously used invitation, use not counted again")
+		return nil
+	}
+
+	// Increment invitation used count
+	if err := s.repo.IncrementUseCount(inviteID); err != nil {
+		return fmt.Errorf("failed to increment use count: %w", err)
+	}
+
+	// Record usage in invitation_uses table
+	useID := uuid.New().String()
+	if err := s.repo.RecordUse(inviteID, userPublicKey, useID); err != nil {
+		return fmt.Errorf("failed to record invitation use: %w", err)
+	}
+	return nil
+}
+
+// checkJoinEmail enforces the application's contact email requirement. An email given
+// to an application that does not require one must still be well-formed.
+func (s *InvitationService) checkJoinEmail(appID, email string) error {
+	if email == "" {
+		if s.config.RequiresEmail(appID) {
+			return ErrEmailRequired
+		}
+		return nil
+	}
+	return ValidateEmail(email)
+}
@@ -0,0 +1,981 @@
+package invitation
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/prappser/prappser_server/internal/application"
+	"github.com/prappser/prappser_server/internal/clock"
+	"github.com/prappser/prappser_server/internal/event"
+	"github.com/prappser/prappser_server/internal/user"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testAppID          = "test-app-id"
+	testOwnerPublicKey = "owner-public-key"
+	testMemberPubKey   = "member-public-key"
+)
+
+// mockInvitationRepository for testing
+type mockInvitationRepository struct {
+	invitations map[string]*Invitation
+	uses        map[string]map[string]bool // inviteID -> userPublicKey -> used
+	shortCodes  map[string]*ShortCode
+	updateCalls int
+}
+
+func newMockInvitationRepository() *mockInvitationRepository {
+	return &mockInvitationRepository{
+		invitations: make(map[string]*Invitation),
+		uses:        make(map[string]map[string]bool),
+		shortCodes:  make(map[string]*ShortCode),
+	}
+}
+
+func (m *mockInvitationRepository) Create(invite *Invitation) error {
+	m.invitations[invite.ID] = invite
+	return nil
+}
+
+func (m *mockInvitationRepository) GetByID(id string) (*Invitation, error) {
+	invite, exists := m.invitations[id]
+	if !exists {
+		return nil, fmt.Errorf("invitation not found")
+	}
+	copied := *invite
+	return &copied, nil
+}
+
+func (m *mockInvitationRepository) Delete(id string) error {
+	if _, exists := m.invitations[id]; !exists {
+		return fmt.Errorf("invitation not found")
+	}
+	delete(m.invitations, id)
+	return nil
+}
+
+func (m *mockInvitationRepository) Update(invite *Invitation) error {
+	m.updateCalls++
+	if _, exists := m.invitations[invite.ID]; !exists {
+		return fmt.Errorf("invitation not found")
+	}
+	copied := *invite
+	m.invitations[invite.ID] = &copied
+	return nil
+}
+
+func (m *mockInvitationRepository) IncrementUseCount(id string) error {
+	invite, exists := m.invitations[id]
+	if !exists {
+		return fmt.Errorf("invitation not found")
+	}
+	invite.UsedCount++
+	return nil
+}
+
+func (m *mockInvitationRepository) RecordUse(inviteID, userPublicKey string, useID string) error {
+	if m.uses[inviteID] == nil {
+		m.uses[inviteID] = make(map[string]bool)
+	}
+	m.uses[inviteID][userPublicKey] = true
+	return nil
+}
+
+func (m *mockInvitationRepository) GetByApplicationID(appID string, limit, offset int) ([]*Invitation, error) {
+	var result []*Invitation
+	for _, invite := range m.invitations {
+		if invite.ApplicationID == appID {
+			result = append(result, invite)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt != result[j].CreatedAt {
+			return result[i].CreatedAt > result[j].CreatedAt
+		}
+		return result[i].ID < result[j].ID
+	})
+	if offset >= len(result) {
+		return nil, nil
+	}
+	return result[offset:min(offset+limit, len(result))], nil
+}
+
+func (m *mockInvitationRepository) HasBeenUsedBy(inviteID, userPublicKey string) (bool, error) {
+	return m.uses[inviteID][userPublicKey], nil
+}
+
+func (m *mockInvitationRepository) CreateShortCode(shortCode *ShortCode) error {
+	m.shortCodes[shortCode.Code] = shortCode
+	return nil
+}
+
+func (m *mockInvitationRepository) GetShortCode(code string) (*ShortCode, error) {
+	shortCode, exists := m.shortCodes[code]
+	if !exists {
+		return nil, ErrShortCodeNotFound
+	}
+	return shortCode, nil
+}
+
+func (m *mockInvitationRepository) DeleteExpired(now int64) (int64, error) {
+	var deleted int64
+	for id, invite := range m.invitations {
+		if invite.ExpiresAt != nil && *invite.ExpiresAt < now {
+			delete(m.invitations, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func intPtr(i int) *int { return &i }
+
+func createTestAppRepository() *application.MemoryRepository {
+	appRepo := application.NewMemoryRepository()
+	appRepo.CreateApplication(&application.Application{ID: testAppID, Name: "Test App"})
+	appRepo.CreateMember(&application.Member{
+		ID:            "owner-member",
+		ApplicationID: testAppID,
+		Name:          "owner",
+		Role:          application.MemberRoleOwner,
+		PublicKey:     testOwnerPublicKey,
+	})
+	appRepo.CreateMember(&application.Member{
+		ID:            "regular-member",
+		ApplicationID: testAppID,
+		Name:          "member",
+		Role:          application.MemberRoleMember,
+		PublicKey:     testMemberPubKey,
+	})
+	return appRepo
+}
+
+func createTestInvitationService(t *testing.T, repo InvitationRepository, appRepo application.ApplicationRepository) *InvitationService {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return NewInvitationService(repo, priv, pub, appRepo, "https://server.example.com", nil, nil, Config{})
+}
+
+func TestUpdateInvitation_ShouldUpdateMaxUsesAndReissueToken(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	repo.Create(&Invitation{ID: "invite-1", ApplicationID: testAppID, Role: "member", MaxUses: intPtr(2), UsedCount: 1})
+	service := createTestInvitationService(t, repo, createTestAppRepository())
+
+	// when
+	response, err := service.UpdateInvitation(testAppID, "invite-1", testOwnerPublicKey, UpdateInvitationRequest{
+		MaxUses:        intPtr(5),
+		ExpiresInHours: intPtr(24),
+	})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 5, *response.Invitation.MaxUses)
+	assert.NotNil(t, response.Invitation.ExpiresAt)
+	assert.Contains(t, response.DeepLink, response.Token)
+
+	stored, _ := repo.GetByID("invite-1")
+	assert.Equal(t, 5, *stored.MaxUses)
+	assert.Equal(t, response.Invitation.ExpiresAt, stored.ExpiresAt)
+
+	claims, err := service.ValidateToken(response.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "invite-1", claims.InviteID)
+	assert.Equal(t, *stored.ExpiresAt, *claims.ExpiresAt)
+}
+
+func TestUpdateInvitation_ShouldRejectMaxUsesBelowUsedCount(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	repo.Create(&Invitation{ID: "invite-1", ApplicationID: testAppID, Role: "member", MaxUses: intPtr(5), UsedCount: 3})
+	service := createTestInvitationService(t, repo, createTestAppRepository())
+
+	// when
+	_, err := service.UpdateInvitation(testAppID, "invite-1", testOwnerPublicKey, UpdateInvitationRequest{MaxUses: intPtr(2)})
+
+	// then
+	assert.True(t, errors.Is(err, ErrInvalidInvitationUpdate))
+	assert.Equal(t, 0, repo.updateCalls)
+}
+
+func TestUpdateInvitation_ShouldRejectExpiryBeyondMaximum(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	repo.Create(&Invitation{ID: "invite-1", ApplicationID: testAppID, Role: "member"})
+	service := createTestInvitationService(t, repo, createTestAppRepository())
+
+	// when
+	_, err := service.UpdateInvitation(testAppID, "invite-1", testOwnerPublicKey, UpdateInvitationRequest{ExpiresInHours: intPtr(MaxExpirationHours + 1)})
+
+	// then
+	assert.True(t, errors.Is(err, ErrInvalidInvitationUpdate))
+}
+
+func TestUpdateInvitation_ShouldRejectZeroExpiry(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	repo.Create(&Invitation{ID: "invite-1", ApplicationID: testAppID, Role: "member"})
+	service := createTestInvitationService(t, repo, createTestAppRepository())
+
+	// when
+	_, err := service.UpdateInvitation(testAppID, "invite-1", testOwnerPublicKey, UpdateInvitationRequest{ExpiresInHours: intPtr(0)})
+
+	// then
+	assert.True(t, errors.Is(err, ErrInvalidInvitationUpdate))
+	assert.Nil(t, repo.invitations["invite-1"].ExpiresAt)
+}
+
+func TestCreateInvitation_ShouldRejectZeroExpiry(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	service := createTestInvitationService(t, repo, createTestAppRepository())
+
+	// when
+	_, err := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testOwnerPublicKey, ExpiresInHours: intPtr(0)})
+
+	// then
+	assert.ErrorContains(t, err, "between 1 and")
+	assert.Empty(t, repo.invitations)
+}
+
+func TestUpdateInvitation_ShouldRejectNonManager(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	repo.Create(&Invitation{ID: "invite-1", ApplicationID: testAppID, Role: "member"})
+	service := createTestInvitationService(t, repo, createTestAppRepository())
+
+	// when
+	_, err := service.UpdateInvitation(testAppID, "invite-1", testMemberPubKey, UpdateInvitationRequest{MaxUses: intPtr(3)})
+
+	// then
+	assert.True(t, errors.Is(err, ErrNotInvitationManager))
+}
+
+func TestUpdateInvitation_ShouldRejectInviteFromAnotherApplication(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	repo.Create(&Invitation{ID: "invite-1", ApplicationID: "other-app", Role: "member"})
+	service := createTestInvitationService(t, repo, createTestAppRepository())
+
+	// when
+	_, err := service.UpdateInvitation(testAppID, "invite-1", testOwnerPublicKey, UpdateInvitationRequest{MaxUses: intPtr(3)})
+
+	// then
+	assert.EqualError(t, err, "invitation not found")
+}
+
+func TestCheckInvitationUsage_ShouldReportPersistedExpiry(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	expired := time.Now().Add(-time.Hour).Unix()
+	repo.Create(&Invitation{ID: "invite-1", ApplicationID: testAppID, Role: "member", ExpiresAt: &expired})
+	service := createTestInvitationService(t, repo, createTestAppRepository())
+	token, _ := service.GenerateToken("invite-1", "https://server.example.com", nil)
+
+	// when
+	result, err := service.CheckInvitationUsage(token, "new-user-public-key")
+
+	// then
+	assert.NoError(t, err)
+	assert.False(t, result.Valid)
+	assert.True(t, result.IsExpired)
+}
+
+func TestValidateDeepLinkScheme_ShouldAcceptAllowedCustomScheme(t *testing.T) {
+	// when
+	err := ValidateDeepLinkScheme("acme-app", []string{"prappser", "acme-app"})
+
+	// then
+	assert.NoError(t, err)
+}
+
+func TestValidateDeepLinkScheme_ShouldRejectSchemeNotInAllowlist(t *testing.T) {
+	// when
+	err := ValidateDeepLinkScheme("other", []string{"prappser"})
+
+	// then
+	assert.True(t, errors.Is(err, ErrInvalidDeepLinkScheme))
+}
+
+func TestValidateDeepLinkScheme_ShouldRejectWebScheme(t *testing.T) {
+	// when
+	err := ValidateDeepLinkScheme("javascript", []string{"javascript"})
+
+	// then
+	assert.True(t, errors.Is(err, ErrInvalidDeepLinkScheme))
+}
+
+func TestValidateDeepLinkScheme_ShouldRejectMalformedScheme(t *testing.T) {
+	// when
+	err := ValidateDeepLinkScheme("bad scheme://", []string{"bad scheme://"})
+
+	// then
+	assert.True(t, errors.Is(err, ErrInvalidDeepLinkScheme))
+}
+
+func TestCreateInvitation_ShouldUseConfiguredDeepLinkScheme(t *testing.T) {
+	// given
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	service := NewInvitationService(newMockInvitationRepository(), priv, pub, createTestAppRepository(), "https://server.example.com", nil, nil, Config{DeepLinkScheme: "acme-app"})
+
+	// when
+	response, err := service.CreateInvitation(CreateInvitationOptions{
+		ApplicationID:      testAppID,
+		CreatedByPublicKey: testOwnerPublicKey,
+		Role:               "member",
+	})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "acme-app://join?token="+response.Token, response.DeepLink)
+}
+
+func TestCreateInvitation_ShouldRejectOwnerRoleUserWhoIsNotMemberOfApplication(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	appRepo := createTestAppRepository()
+	appRepo.CreateApplication(&application.Application{ID: "other-app", Name: "Other App"})
+	appRepo.CreateMember(&application.Member{ID: "other-owner", ApplicationID: "other-app", Name: "mallory", Role: application.MemberRoleOwner, PublicKey: "other-owner-public-key"})
+	service := createTestInvitationService(t, repo, appRepo)
+
+	// when
+	response, err := service.CreateInvitation(CreateInvitationOptions{
+		ApplicationID:      testAppID,
+		CreatedByPublicKey: "other-owner-public-key",
+		Role:               "member",
+	})
+
+	// then
+	assert.Nil(t, response)
+	assert.ErrorIs(t, err, ErrNotInvitationManager)
+	assert.Empty(t, repo.invitations)
+}
+
+func TestVerifyInviteManager_ShouldAllowOwnersAndAdminsOnly(t *testing.T) {
+	// given
+	appRepo := createTestAppRepository()
+	appRepo.CreateMember(&application.Member{ID: "admin-member", ApplicationID: testAppID, Name: "admin", Role: application.MemberRoleAdmin, PublicKey: "admin-public-key"})
+	service := createTestInvitationService(t, newMockInvitationRepository(), appRepo)
+
+	// then
+	assert.NoError(t, service.verifyInviteManager(testAppID, testOwnerPublicKey))
+	assert.NoError(t, service.verifyInviteManager(testAppID, "admin-public-key"))
+	assert.ErrorIs(t, service.verifyInviteManager(testAppID, testMemberPubKey), ErrNotInvitationManager)
+	assert.ErrorIs(t, service.verifyInviteManager(testAppID, "stranger-public-key"), ErrNotInvitationManager)
+}
+
+func TestCreateInvitation_ShouldIssueShortCodeResolvingToToken(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	service := createTestInvitationService(t, repo, createTestAppRepository())
+
+	// when
+	response, err := service.CreateInvitation(CreateInvitationOptions{
+		ApplicationID:      testAppID,
+		CreatedByPublicKey: testOwnerPublicKey,
+		Role:               "member",
+	})
+
+	// then
+	assert.NoError(t, err)
+	assert.Len(t, response.Code, shortCodeLength)
+	assert.Equal(t, "prappser://join?code="+response.Code, response.ShortDeepLink)
+	assert.Less(t, len(response.ShortURL), len(response.URL))
+
+	token, err := service.ResolveShortCode(response.Code)
+	assert.NoError(t, err)
+	assert.Equal(t, response.Token, token)
+
+	claims, err := service.ValidateToken(response.Code)
+	assert.NoError(t, err)
+	assert.Equal(t, response.ID, claims.InviteID)
+}
+
+func TestResolveShortCode_ShouldRejectExpiredCode(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	service := createTestInvitationService(t, repo, createTestAppRepository())
+	repo.CreateShortCode(&ShortCode{Code: "EXPIRED234", InvitationID: "invite-1", Token: "a.b.c", ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+
+	// when
+	_, err := service.ResolveShortCode("EXPIRED234")
+	_, unknownErr := service.ResolveShortCode("UNKNOWN234")
+
+	// then
+	assert.True(t, errors.Is(err, ErrShortCodeExpired))
+	assert.True(t, errors.Is(unknownErr, ErrShortCodeNotFound))
+}
+
+func TestInvitation_ShouldExpireWhenClockPassesExpiry(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	service := createTestInvitationService(t, repo, createTestAppRepository())
+	fakeClock := clock.NewFake(time.Unix(1700000000, 0))
+	service.clock = fakeClock
+	created, err := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testOwnerPublicKey, ExpiresInHours: intPtr(1)})
+	assert.NoError(t, err)
+	_, validErr := service.ValidateToken(created.Token)
+	_, validCodeErr := service.ResolveShortCode(created.Code)
+
+	// when
+	fakeClock.Advance(time.Hour + time.Second)
+
+	// then
+	assert.NoError(t, validErr)
+	assert.NoError(t, validCodeErr)
+	_, expiredErr := service.ValidateToken(created.Token)
+	_, expiredCodeErr := service.ResolveShortCode(created.Code)
+	assert.Error(t, expiredErr)
+	assert.True(t, errors.Is(expiredCodeErr, ErrShortCodeExpired))
+	assert.True(t, repo.invitations[created.ID].IsExpired(fakeClock.Now()))
+}
+
+func TestCreateInvitation_ShouldCapShortCodeExpiry(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	service := NewInvitationService(repo, priv, pub, createTestAppRepository(), "https://server.example.com", nil, nil, Config{ShortCodeTTL: 2 * time.Hour})
+
+	// when
+	unbounded, _ := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testOwnerPublicKey})
+	shortLived, _ := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testOwnerPublicKey, ExpiresInHours: intPtr(1)})
+
+	// then
+	assert.InDelta(t, time.Now().Add(2*time.Hour).Unix(), unbounded.CodeExpiresAt, 2)
+	assert.Equal(t, *shortLived.ExpiresAt, shortLived.CodeExpiresAt)
+}
+
+// mockUserRepository for testing
+type mockUserRepository struct {
+	users map[string]*user.User
+}
+
+func (m *mockUserRepository) CreateUser(u *user.User) error {
+	m.users[u.PublicKey] = u
+	return nil
+}
+
+func (m *mockUserRepository) GetUserByPublicKey(publicKey string) (*user.User, error) {
+	if u, exists := m.users[publicKey]; exists {
+		return u, nil
+	}
+	return nil, errors.New("user not found")
+}
+
+func (m *mockUserRepository) GetUserByUsername(username string) (*user.User, error) {
+	return nil, nil
+}
+
+func (m *mockUserRepository) UpdateUserRole(publicKey string, role string) error { return nil }
+
+func (m *mockUserRepository) UpdateUsername(publicKey string, username string) error { return nil }
+
+func (m *mockUserRepository) UpdateAvatarStorageID(publicKey string, avatarStorageID *string) error {
+	return nil
+}
+
+func (m *mockUserRepository) UpdateLastSeen(publicKey string, seenAt int64) error { return nil }
+
+func (m *mockUserRepository) DeleteOrphanedUsers(inactiveSince int64) (int64, error) { return 0, nil }
+
+func (m *mockUserRepository) RevokeToken(jti string, expiresAt int64) error { return nil }
+
+func (m *mockUserRepository) IsTokenRevoked(jti string) (bool, error) { return false, nil }
+
+func (m *mockUserRepository) DeleteExpiredRevokedTokens(expiredBefore int64) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockUserRepository) CreateRefreshToken(tokenHash, publicKey string, expiresAt int64) error {
+	return nil
+}
+
+func (m *mockUserRepository) ConsumeRefreshToken(tokenHash string) (string, int64, error) {
+	return "", 0, nil
+}
+
+func (m *mockUserRepository) DeleteRefreshTokensByPublicKey(publicKey string) error { return nil }
+
+func (m *mockUserRepository) DeleteExpiredRefreshTokens(expiredBefore int64) (int64, error) {
+	return 0, nil
+}
+
+// errStopAfterProduce ends a join once its member_added event is captured, before the
+// usage tracking that needs a database
+var errStopAfterProduce = errors.New("stop after produce")
+
+// mockEventService records produced events. With an application repository it also
+// adds the member of a produced member_added event, as executing it would.
+type mockEventService struct {
+	produced []*event.Event
+	appRepo  application.ApplicationRepository
+}
+
+func (m *mockEventService) AcceptEvent(ctx context.Context, e *event.Event, submitter *user.User) (*event.Event, error) {
+	return e, nil
+}
+
+func (m *mockEventService) ProduceEvent(ctx context.Context, e *event.Event) (*event.Event, error) {
+	m.produced = append(m.produced, e)
+	if m.appRepo == nil {
+		return nil, errStopAfterProduce
+	}
+	role, _ := e.Data["role"].(string)
+	err := m.appRepo.CreateMember(&application.Member{
+		ID:            e.ID,
+		ApplicationID: e.ApplicationID,
+		Name:          e.Data["memberName"].(string),
+		Role:          application.MemberRole(role),
+		PublicKey:     e.Data["memberPublicKey"].(string),
+	})
+	return e, err
+}
+
+// Fixed joiner key pairs, so tests can sign join proofs for known public keys
+var (
+	testJoinerKey         = ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	testJoinerPublicKey   = base64.StdEncoding.EncodeToString(testJoinerKey.Public().(ed25519.PublicKey))
+	testNewcomerKey       = ed25519.NewKeyFromSeed(bytes.Repeat([]byte{2}, ed25519.SeedSize))
+	testNewcomerPublicKey = base64.StdEncoding.EncodeToString(testNewcomerKey.Public().(ed25519.PublicKey))
+)
+
+func signJoinProof(privateKey ed25519.PrivateKey, token string) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(token)))
+}
+
+func createEmailRequiringService(t *testing.T, repo InvitationRepository, eventService EventService) *InvitationService {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	userRepo := &mockUserRepository{users: make(map[string]*user.User)}
+	return NewInvitationService(repo, priv, pub, createTestAppRepository(), "https://server.example.com", userRepo, eventService, Config{RequireEmailApps: []string{testAppID}})
+}
+
+func TestJoin_ShouldRejectMissingEmailWhenRequired(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	eventService := &mockEventService{}
+	service := createEmailRequiringService(t, repo, eventService)
+	invite, _ := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testOwnerPublicKey})
+
+	// when
+	_, err := service.Join(invite.Token, testJoinerPublicKey, "joiner", "", signJoinProof(testJoinerKey, invite.Token))
+
+	// then
+	assert.True(t, errors.Is(err, ErrEmailRequired))
+	assert.Empty(t, eventService.produced)
+}
+
+func TestJoin_ShouldRejectMalformedEmailWhenRequired(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	eventService := &mockEventService{}
+	service := createEmailRequiringService(t, repo, eventService)
+	invite, _ := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testOwnerPublicKey})
+
+	// when
+	_, err := service.Join(invite.Token, testJoinerPublicKey, "joiner", "Joiner <joiner@example.com>", signJoinProof(testJoinerKey, invite.Token))
+
+	// then
+	assert.True(t, errors.Is(err, ErrInvalidEmail))
+	assert.Empty(t, eventService.produced)
+}
+
+func TestJoin_ShouldStoreValidEmailOnMemberWhenRequired(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	appRepo := createTestAppRepository()
+	eventService := &mockEventService{appRepo: appRepo}
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	userRepo := &mockUserRepository{users: make(map[string]*user.User)}
+	service := NewInvitationService(repo, priv, pub, appRepo, "https://server.example.com", userRepo, eventService, Config{RequireEmailApps: []string{testAppID}})
+	invite, _ := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testOwnerPublicKey})
+
+	// when
+	service.Join(invite.Token, testJoinerPublicKey, "joiner", "joiner@example.com", signJoinProof(testJoinerKey, invite.Token))
+
+	// then
+	assert.Len(t, eventService.produced, 1)
+	assert.NotContains(t, eventService.produced[0].Data, "memberEmail")
+	member, err := appRepo.GetMemberByPublicKey(testAppID, testJoinerPublicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "joiner@example.com", member.Email)
+}
+
+func TestJoin_ShouldRepairEmailAndUseOnRetryAfterMemberWasAdded(t *testing.T) {
+	// given - an earlier attempt added the member but failed before storing the rest
+	repo := newMockInvitationRepository()
+	appRepo := createTestAppRepository()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	userRepo := &mockUserRepository{users: make(map[string]*user.User)}
+	eventService := &mockEventService{appRepo: appRepo}
+	service := NewInvitationService(repo, priv, pub, appRepo, "https://server.example.com", userRepo, eventService, Config{RequireEmailApps: []string{testAppID}})
+	invite, _ := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testOwnerPublicKey})
+	appRepo.CreateMember(&application.Member{ID: "joiner-member", ApplicationID: testAppID, Name: "joiner", Role: application.MemberRoleMember, PublicKey: testJoinerPublicKey})
+
+	// when
+	result, err := service.Join(invite.Token, testJoinerPublicKey, "joiner", "joiner@example.com", signJoinProof(testJoinerKey, invite.Token))
+
+	// then
+	assert.NoError(t, err)
+	assert.False(t, result.IsNewMember)
+	assert.Empty(t, eventService.produced)
+	member, _ := appRepo.GetMemberByPublicKey(testAppID, testJoinerPublicKey)
+	assert.Equal(t, "joiner@example.com", member.Email)
+	used, _ := repo.HasBeenUsedBy(invite.ID, testJoinerPublicKey)
+	assert.True(t, used)
+}
+
+func TestConfigRequiresEmail_ShouldFollowConfiguredApplications(t *testing.T) {
+	assert.False(t, Config{}.RequiresEmail(testAppID))
+	assert.True(t, Config{RequireEmailApps: []string{testAppID}}.RequiresEmail(testAppID))
+	assert.False(t, Config{RequireEmailApps: []string{"other-app"}}.RequiresEmail(testAppID))
+	assert.True(t, Config{RequireEmailApps: []string{"*"}}.RequiresEmail(testAppID))
+}
+
+const testLongOwnerPublicKey = "preview-owner-public-key-0123456789"
+
+func createPreviewTestService(t *testing.T, repo InvitationRepository) *InvitationService {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	appRepo := createTestAppRepository()
+	appRepo.CreateMember(&application.Member{ID: "preview-owner", ApplicationID: testAppID, Name: "alice", Role: application.MemberRoleOwner, PublicKey: testLongOwnerPublicKey})
+	userRepo := &mockUserRepository{users: map[string]*user.User{
+		testLongOwnerPublicKey: {PublicKey: testLongOwnerPublicKey, Username: "alice", Role: user.RoleOwner},
+	}}
+	return NewInvitationService(repo, priv, pub, appRepo, "https://server.example.com", userRepo, nil, Config{})
+}
+
+func TestPreviewInvitation_ShouldMatchPublicInviteInfo(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	service := createPreviewTestService(t, repo)
+	invite, err := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testLongOwnerPublicKey, Role: "member", MaxUses: intPtr(3)})
+	assert.NoError(t, err)
+
+	// when
+	preview, previewErr := service.PreviewInvitation(testAppID, invite.ID, testLongOwnerPublicKey)
+	info, infoErr := service.GetInviteInfo(invite.Token)
+
+	// then
+	assert.NoError(t, previewErr)
+	assert.NoError(t, infoErr)
+	assert.Equal(t, info, preview)
+	assert.Equal(t, "Test App", preview.ApplicationName)
+	assert.Equal(t, "alice", preview.CreatorUsername)
+	assert.True(t, preview.IsValid)
+}
+
+func TestPreviewInvitation_ShouldReportExhaustedInvitation(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	service := createPreviewTestService(t, repo)
+	invite, _ := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testLongOwnerPublicKey, MaxUses: intPtr(1)})
+	repo.IncrementUseCount(invite.ID)
+
+	// when
+	preview, err := service.PreviewInvitation(testAppID, invite.ID, testLongOwnerPublicKey)
+
+	// then
+	assert.NoError(t, err)
+	assert.False(t, preview.IsValid)
+	assert.False(t, preview.IsExpired)
+}
+
+func TestPreviewInvitation_ShouldRejectNonManager(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	service := createPreviewTestService(t, repo)
+	invite, _ := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testLongOwnerPublicKey})
+
+	// when
+	_, err := service.PreviewInvitation(testAppID, invite.ID, testMemberPubKey)
+
+	// then
+	assert.True(t, errors.Is(err, ErrNotInvitationManager))
+}
+
+func TestRecordInvitationUse_ShouldNotCountRejoinAfterLeaving(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	service := createTestInvitationService(t, repo, createTestAppRepository())
+	invite, _ := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testOwnerPublicKey, MaxUses: intPtr(2)})
+
+	// when
+	firstErr := service.recordInvitationUse(invite.ID, testJoinerPublicKey)
+	rejoinErr := service.recordInvitationUse(invite.ID, testJoinerPublicKey)
+	otherErr := service.recordInvitationUse(invite.ID, "other-joiner-public-key")
+
+	// then
+	assert.NoError(t, firstErr)
+	assert.NoError(t, rejoinErr)
+	assert.NoError(t, otherErr)
+	assert.Equal(t, 2, repo.invitations[invite.ID].UsedCount)
+}
+
+func TestJoin_ShouldAllowRejoinThroughExhaustedInvitation(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	eventService := &mockEventService{}
+	service := createEmailRequiringService(t, repo, eventService)
+	invite, _ := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testOwnerPublicKey, MaxUses: intPtr(1)})
+	service.recordInvitationUse(invite.ID, testJoinerPublicKey)
+
+	// when
+	_, rejoinErr := service.Join(invite.Token, testJoinerPublicKey, "joiner", "joiner@example.com", signJoinProof(testJoinerKey, invite.Token))
+	_, newcomerErr := service.Join(invite.Token, testNewcomerPublicKey, "newcomer", "newcomer@example.com", signJoinProof(testNewcomerKey, invite.Token))
+
+	// then
+	assert.True(t, errors.Is(rejoinErr, errStopAfterProduce))
+	assert.EqualError(t, newcomerErr, "invitation has reached maximum uses")
+	assert.Len(t, eventService.produced, 1)
+}
+
+func TestCheckInvitationUsage_ShouldAllowRejoinThroughExhaustedInvitation(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	service := createTestInvitationService(t, repo, createTestAppRepository())
+	invite, _ := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testOwnerPublicKey, MaxUses: intPtr(1)})
+	service.recordInvitationUse(invite.ID, testJoinerPublicKey)
+
+	// when
+	rejoin, rejoinErr := service.CheckInvitationUsage(invite.Token, testJoinerPublicKey)
+	newcomer, newcomerErr := service.CheckInvitationUsage(invite.Token, "newcomer-public-key")
+
+	// then
+	assert.NoError(t, rejoinErr)
+	assert.NoError(t, newcomerErr)
+	assert.True(t, rejoin.Valid)
+	assert.False(t, newcomer.Valid)
+	assert.True(t, newcomer.MaxUsesReached)
+}
+
+func TestUpdateInvitation_ShouldRechargeExhaustedInvitation(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	service := createTestInvitationService(t, repo, createTestAppRepository())
+	invite, _ := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testOwnerPublicKey, MaxUses: intPtr(1)})
+	service.recordInvitationUse(invite.ID, testJoinerPublicKey)
+
+	// when
+	updated, err := service.UpdateInvitation(testAppID, invite.ID, testOwnerPublicKey, UpdateInvitationRequest{MaxUses: intPtr(2)})
+
+	// then
+	assert.NoError(t, err)
+	result, _ := service.CheckInvitationUsage(updated.Token, "newcomer-public-key")
+	assert.True(t, result.Valid)
+}
+
+func TestJoin_ShouldAcceptValidJoinProof(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	eventService := &mockEventService{}
+	service := createEmailRequiringService(t, repo, eventService)
+	invite, _ := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testOwnerPublicKey})
+
+	// when
+	_, err := service.Join(invite.Token, testJoinerPublicKey, "joiner", "joiner@example.com", signJoinProof(testJoinerKey, invite.Token))
+
+	// then
+	assert.True(t, errors.Is(err, errStopAfterProduce))
+	assert.Len(t, eventService.produced, 1)
+	assert.Equal(t, testJoinerPublicKey, eventService.produced[0].Data["memberPublicKey"])
+}
+
+func TestJoin_ShouldRejectProofSignedByAnotherKey(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	eventService := &mockEventService{}
+	service := createEmailRequiringService(t, repo, eventService)
+	invite, _ := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testOwnerPublicKey})
+
+	// when
+	_, err := service.Join(invite.Token, testJoinerPublicKey, "joiner", "joiner@example.com", signJoinProof(testNewcomerKey, invite.Token))
+
+	// then
+	assert.True(t, errors.Is(err, ErrInvalidJoinProof))
+	assert.Empty(t, eventService.produced)
+	assert.Equal(t, 0, repo.invitations[invite.ID].UsedCount)
+}
+
+func TestJoin_ShouldRejectProofOfAnotherToken(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	eventService := &mockEventService{}
+	service := createEmailRequiringService(t, repo, eventService)
+	invite, _ := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testOwnerPublicKey})
+	otherInvite, _ := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testOwnerPublicKey})
+
+	// when
+	_, err := service.Join(invite.Token, testJoinerPublicKey, "joiner", "joiner@example.com", signJoinProof(testJoinerKey, otherInvite.Token))
+
+	// then
+	assert.True(t, errors.Is(err, ErrInvalidJoinProof))
+	assert.Empty(t, eventService.produced)
+}
+
+func TestVerifyJoinProof_ShouldRejectMalformedInput(t *testing.T) {
+	// given
+	validProof := signJoinProof(testJoinerKey, "token")
+
+	// then
+	assert.NoError(t, VerifyJoinProof(testJoinerPublicKey, "token", validProof))
+	assert.True(t, errors.Is(VerifyJoinProof("not-a-key", "token", validProof), ErrInvalidJoinProof))
+	assert.True(t, errors.Is(VerifyJoinProof(testJoinerPublicKey, "token", "not-a-signature"), ErrInvalidJoinProof))
+	assert.True(t, errors.Is(VerifyJoinProof(testJoinerPublicKey, "token", ""), ErrInvalidJoinProof))
+}
+
+func TestRevokeInvitation_ShouldDeleteInvitationAndProduceInviteRevokedEvent(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	eventService := &mockEventService{}
+	service := NewInvitationService(repo, nil, nil, createTestAppRepository(), "https://server.example.com", nil, eventService, Config{})
+	repo.invitations["invite-1"] = &Invitation{ID: "invite-1", ApplicationID: testAppID}
+
+	// when
+	err := service.RevokeInvitation(testAppID, "invite-1", testOwnerPublicKey)
+
+	// then
+	assert.NoError(t, err)
+	assert.NotContains(t, repo.invitations, "invite-1")
+	assert.Len(t, eventService.produced, 1)
+	produced := eventService.produced[0]
+	assert.Equal(t, event.EventTypeInviteRevoked, produced.Type)
+	assert.Equal(t, testOwnerPublicKey, produced.CreatorPublicKey)
+	assert.Equal(t, testAppID, produced.Data["applicationId"])
+	assert.Equal(t, "invite-1", produced.Data["inviteId"])
+	assert.NoError(t, event.ValidateEvent(produced))
+}
+
+func TestRevokeInvitation_ShouldNotProduceEventForMissingInvitation(t *testing.T) {
+	// given
+	eventService := &mockEventService{}
+	service := NewInvitationService(newMockInvitationRepository(), nil, nil, createTestAppRepository(), "https://server.example.com", nil, eventService, Config{})
+
+	// when
+	err := service.RevokeInvitation(testAppID, "missing-invite", testOwnerPublicKey)
+
+	// then
+	assert.Error(t, err)
+	assert.Empty(t, eventService.produced)
+}
+
+func TestRevokeInvitation_ShouldRejectInviteFromAnotherApplication(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	eventService := &mockEventService{}
+	service := NewInvitationService(repo, nil, nil, createTestAppRepository(), "https://server.example.com", nil, eventService, Config{})
+	repo.invitations["invite-1"] = &Invitation{ID: "invite-1", ApplicationID: "other-app"}
+
+	// when
+	err := service.RevokeInvitation(testAppID, "invite-1", testOwnerPublicKey)
+
+	// then
+	assert.EqualError(t, err, "invitation not found")
+	assert.Contains(t, repo.invitations, "invite-1")
+	assert.Empty(t, eventService.produced)
+}
+
+func TestCleanupExpiredInvitations_ShouldDeleteExpiredInvitationsOnly(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	service := createTestInvitationService(t, repo, createTestAppRepository())
+	past := time.Now().Add(-time.Hour).Unix()
+	future := time.Now().Add(time.Hour).Unix()
+	repo.invitations["expired"] = &Invitation{ID: "expired", ApplicationID: testAppID, ExpiresAt: &past}
+	repo.invitations["exhausted"] = &Invitation{ID: "exhausted", ApplicationID: testAppID, MaxUses: intPtr(2), UsedCount: 2}
+	repo.invitations["active"] = &Invitation{ID: "active", ApplicationID: testAppID, ExpiresAt: &future, MaxUses: intPtr(2), UsedCount: 1}
+	repo.invitations["unbounded"] = &Invitation{ID: "unbounded", ApplicationID: testAppID, UsedCount: 50}
+
+	// when
+	deleted, err := service.CleanupExpiredInvitations()
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	assert.Contains(t, repo.invitations, "active")
+	assert.Contains(t, repo.invitations, "unbounded")
+	assert.Contains(t, repo.invitations, "exhausted")
+	assert.NotContains(t, repo.invitations, "expired")
+}
+
+func TestGetInvitesForApp_ShouldReturnRequestedPageNewestFirst(t *testing.T) {
+	// given
+	repo := newMockInvitationRepository()
+	service := createTestInvitationService(t, repo, createTestAppRepository())
+	repo.invitations["oldest"] = &Invitation{ID: "oldest", ApplicationID: testAppID, CreatedAt: 1}
+	repo.invitations["middle"] = &Invitation{ID: "middle", ApplicationID: testAppID, CreatedAt: 2}
+	repo.invitations["newest"] = &Invitation{ID: "newest", ApplicationID: testAppID, CreatedAt: 3}
+	repo.invitations["other"] = &Invitation{ID: "other", ApplicationID: "other-app", CreatedAt: 4}
+
+	// when
+	invites, err := service.GetInvitesForApp(testAppID, 2, 1)
+
+	// then
+	assert.NoError(t, err)
+	assert.Len(t, invites, 2)
+	assert.Equal(t, "middle", invites[0].ID)
+	assert.Equal(t, "oldest", invites[1].ID)
+}
+
+// memberCreatingEventService executes member_added events against the application repository
+type memberCreatingEventService struct {
+	appRepo *application.MemoryRepository
+}
+
+func (m *memberCreatingEventService) AcceptEvent(ctx context.Context, e *event.Event, submitter *user.User) (*event.Event, error) {
+	return e, nil
+}
+
+func (m *memberCreatingEventService) ProduceEvent(ctx context.Context, e *event.Event) (*event.Event, error) {
+	err := m.appRepo.CreateMember(&application.Member{
+		ID:            "created-member-id",
+		ApplicationID: e.Data["applicationId"].(string),
+		Name:          e.Data["memberName"].(string),
+		Role:          application.MemberRole(e.Data["role"].(string)),
+		PublicKey:     e.Data["memberPublicKey"].(string),
+	})
+	return e, err
+}
+
+func TestJoin_ShouldReturnCreatedMember(t *testing.T) {
+	// given
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	repo := newMockInvitationRepository()
+	appRepo := createTestAppRepository()
+	userRepo := &mockUserRepository{users: make(map[string]*user.User)}
+	service := NewInvitationService(repo, priv, pub, appRepo, "https://server.example.com", userRepo, &memberCreatingEventService{appRepo: appRepo}, Config{})
+	invite, _ := service.CreateInvitation(CreateInvitationOptions{ApplicationID: testAppID, CreatedByPublicKey: testOwnerPublicKey, Role: "admin"})
+
+	// when
+	result, err := service.Join(invite.Token, testJoinerPublicKey, "joiner", "", signJoinProof(testJoinerKey, invite.Token))
+
+	// then
+	assert.NoError(t, err)
+	stored, _ := appRepo.GetMemberByPublicKey(testAppID, testJoinerPublicKey)
+	assert.NotNil(t, stored)
+	assert.Equal(t, stored.ID, result.MemberID)
+	assert.Equal(t, "joiner", result.MemberName)
+	assert.Equal(t, application.MemberRoleAdmin, result.Role)
+	assert.True(t, result.IsNewMember)
+}
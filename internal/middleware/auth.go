@@ -1,23 +1,44 @@
 package middleware
 
 import (
+	"strings"
+
 	"github.com/prappser/prappser_server/internal/user"
 	"github.com/rs/zerolog/log"
 	"github.com/valyala/fasthttp"
 )
 
+// APITokenAuthenticator resolves an application-scoped API token to the user it acts as
+// and the ID of the application it is bound to. IsAPIToken recognises API tokens among
+// bearer credentials; the token format lives in the apitoken package, which imports
+// packages that depend on this one.
+type APITokenAuthenticator interface {
+	IsAPIToken(credential string) bool
+	Authenticate(token string) (*user.User, string, error)
+}
+
 type AuthMiddleware struct {
 	userService *user.UserService
+	apiTokens   APITokenAuthenticator
 }
 
-func NewAuthMiddleware(userService *user.UserService) *AuthMiddleware {
+// NewAuthMiddleware creates the auth middleware. apiTokens may be nil, in which case only
+// user JWTs are accepted.
+func NewAuthMiddleware(userService *user.UserService, apiTokens APITokenAuthenticator) *AuthMiddleware {
 	return &AuthMiddleware{
 		userService: userService,
+		apiTokens:   apiTokens,
 	}
 }
 
 func (am *AuthMiddleware) RequireAuth(handler fasthttp.RequestHandler) fasthttp.RequestHandler {
 	return func(ctx *fasthttp.RequestCtx) {
+		authHeader := string(ctx.Request.Header.Peek("Authorization"))
+		if credential, ok := strings.CutPrefix(authHeader, "Bearer "); ok && am.apiTokens != nil && am.apiTokens.IsAPIToken(credential) {
+			am.authenticateAPIToken(ctx, credential, handler)
+			return
+		}
+
 		authenticatedUser, err := am.userService.ValidateJWTFromRequest(ctx)
 		if err != nil {
 			log.Error().Err(err).Msg("Authentication failed")
@@ -26,11 +47,35 @@ func (am *AuthMiddleware) RequireAuth(handler fasthttp.RequestHandler) fasthttp.
 		}
 
 		ctx.SetUserValue("user", authenticatedUser)
+		am.userService.MarkSeen(authenticatedUser.PublicKey)
 
 		handler(ctx)
 	}
 }
 
+// authenticateAPIToken admits an API token only to the event endpoints and its own
+// application's routes
+func (am *AuthMiddleware) authenticateAPIToken(ctx *fasthttp.RequestCtx, token string, handler fasthttp.RequestHandler) {
+	tokenUser, appID, err := am.apiTokens.Authenticate(token)
+	if err != nil {
+		log.Error().Err(err).Msg("API token authentication failed")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	path := string(ctx.Path())
+	appPath := "/applications/" + appID
+	if path != "/events" && path != appPath && !strings.HasPrefix(path, appPath+"/") {
+		log.Error().Str("path", path).Str("appID", appID).Msg("API token used outside its application")
+		ctx.Error("Forbidden", fasthttp.StatusForbidden)
+		return
+	}
+
+	ctx.SetUserValue("user", tokenUser)
+
+	handler(ctx)
+}
+
 func (am *AuthMiddleware) RequireRole(role string, handler fasthttp.RequestHandler) fasthttp.RequestHandler {
 	return am.RequireAuth(func(ctx *fasthttp.RequestCtx) {
 		authenticatedUser, ok := ctx.UserValue("user").(*user.User)
@@ -42,4 +87,4 @@ func (am *AuthMiddleware) RequireRole(role string, handler fasthttp.RequestHandl
 
 		handler(ctx)
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+)
+
+// ConcurrencyLimiter caps the number of requests handled at the same time, so a flood of
+// requests cannot exhaust the memory of a small instance
+type ConcurrencyLimiter struct {
+	slots chan struct{}
+}
+
+// NewConcurrencyLimiter creates a limiter allowing maxRequests in-flight requests. A
+// maxRequests of zero or less disables the limit.
+func NewConcurrencyLimiter(maxRequests int) *ConcurrencyLimiter {
+	if maxRequests <= 0 {
+		return &ConcurrencyLimiter{}
+	}
+	return &ConcurrencyLimiter{
+		slots: make(chan struct{}, maxRequests),
+	}
+}
+
+// Handle sheds requests with 503 while every slot is taken instead of queueing them. The
+// health check is never shed, so a busy instance is not reported as down. Websocket
+// connections only hold a slot during the upgrade handshake.
+func (cl *ConcurrencyLimiter) Handle(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	if cl.slots == nil {
+		return next
+	}
+
+	return func(ctx *fasthttp.RequestCtx) {
+		if string(ctx.Path()) == "/health" {
+			next(ctx)
+			return
+		}
+
+		select {
+		case cl.slots <- struct{}{}:
+			defer func() { <-cl.slots }()
+			next(ctx)
+		default:
+			log.Warn().
+				Str("path", string(ctx.Path())).
+				Int("maxRequests", cap(cl.slots)).
+				Msg("Request rejected: server at capacity")
+			ctx.Error("Service Unavailable: server at capacity", fasthttp.StatusServiceUnavailable)
+			ctx.Response.Header.Set("Retry-After", "1")
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestConcurrencyLimiter_ShouldShedRequestsBeyondLimit(t *testing.T) {
+	// given
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	handler := func(ctx *fasthttp.RequestCtx) {
+		if string(ctx.Path()) == "/slow" {
+			close(entered)
+			<-release
+		}
+		ctx.SetStatusCode(fasthttp.StatusOK)
+	}
+	limited := NewConcurrencyLimiter(1).Handle(handler)
+	slowDone := make(chan int)
+	go func() {
+		slowDone <- serve(t, limited, "GET", "/slow").StatusCode()
+	}()
+	<-entered
+
+	// when
+	shedResp := serve(t, limited, "GET", "/applications")
+	healthResp := serve(t, limited, "GET", "/health")
+	close(release)
+	slowStatus := <-slowDone
+	afterResp := serve(t, limited, "GET", "/applications")
+
+	// then
+	assert.Equal(t, fasthttp.StatusServiceUnavailable, shedResp.StatusCode())
+	assert.Equal(t, "1", string(shedResp.Header.Peek("Retry-After")))
+	assert.Equal(t, fasthttp.StatusOK, healthResp.StatusCode())
+	assert.Equal(t, fasthttp.StatusOK, slowStatus)
+	assert.Equal(t, fasthttp.StatusOK, afterResp.StatusCode())
+}
+
+func TestConcurrencyLimiter_ShouldNotLimitWhenDisabled(t *testing.T) {
+	// given
+	handler := func(ctx *fasthttp.RequestCtx) { ctx.SetStatusCode(fasthttp.StatusOK) }
+
+	// when
+	resp := serve(t, NewConcurrencyLimiter(0).Handle(handler), "GET", "/applications")
+
+	// then
+	assert.Equal(t, fasthttp.StatusOK, resp.StatusCode())
+}
@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"regexp"
 	"strings"
 
 	"github.com/rs/zerolog/log"
@@ -9,20 +8,12 @@ import (
 )
 
 type CORSMiddleware struct {
-	allowedOrigins []string
-	localhostRegex *regexp.Regexp
+	origins *OriginPolicy
 }
 
-func NewCORSMiddleware(allowedOrigins []string) *CORSMiddleware {
-	if len(allowedOrigins) == 0 {
-		// Default: allow prappser.app and localhost for development
-		allowedOrigins = []string{"https://prappser.app", "http://localhost:*", "https://localhost:*"}
-	}
-	// Compile regex for localhost with any port
-	localhostRegex := regexp.MustCompile(`^https?://localhost:\d+$`)
+func NewCORSMiddleware(origins *OriginPolicy) *CORSMiddleware {
 	return &CORSMiddleware{
-		allowedOrigins: allowedOrigins,
-		localhostRegex: localhostRegex,
+		origins: origins,
 	}
 }
 
@@ -38,7 +29,7 @@ func (cm *CORSMiddleware) Handle(next fasthttp.RequestHandler) fasthttp.RequestH
 		}
 		origin = strings.TrimSpace(origin)
 
-		isAllowed := cm.isOriginAllowed(origin)
+		isAllowed := cm.isOriginAllowed(ctx, origin)
 
 		log.Info().
 			Str("origin", origin).
@@ -65,13 +56,13 @@ func (cm *CORSMiddleware) setCORSHeaders(ctx *fasthttp.RequestCtx, origin string
 	if isAllowed && origin != "" {
 		ctx.Response.Header.Set("Access-Control-Allow-Origin", origin)
 		ctx.Response.Header.Set("Access-Control-Allow-Credentials", "true")
-	} else if len(cm.allowedOrigins) == 1 && cm.allowedOrigins[0] == "*" {
+	} else if cm.origins.IsWildcard() {
 		ctx.Response.Header.Set("Access-Control-Allow-Origin", "*")
 	}
 
-	ctx.Response.Header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	ctx.Response.Header.Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
-	ctx.Response.Header.Set("Access-Control-Expose-Headers", "Authorization, Content-Type")
+	ctx.Response.Header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+	ctx.Response.Header.Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-None-Match, If-Modified-Since")
+	ctx.Response.Header.Set("Access-Control-Expose-Headers", "Authorization, Content-Type, ETag, Last-Modified")
 	ctx.Response.Header.Set("Access-Control-Max-Age", "86400")
 }
 
@@ -87,23 +78,18 @@ func extractOriginFromURL(url string) string {
 	return ""
 }
 
-func (cm *CORSMiddleware) isOriginAllowed(origin string) bool {
-	// Wildcard allows all origins
-	if len(cm.allowedOrigins) == 1 && cm.allowedOrigins[0] == "*" {
-		return true
+// isOriginAllowed checks the global allowlist, and for requests naming an application also
+// the origins configured for that application. The application is taken from the
+// /applications/{id} path, the applicationId query argument (storage uploads) or the body
+// of POST /events. Event preflights carry no body, so they are allowed for origins of any
+// application and the submitted event decides. Other shared routes, such as /sync,
+// GET /events and /storage/{id}, span applications and use only the global allowlist.
+func (cm *CORSMiddleware) isOriginAllowed(ctx *fasthttp.RequestCtx, origin string) bool {
+	if appID := applicationIDFromRequest(ctx); appID != "" {
+		return cm.origins.IsAllowedForApp(appID, origin)
 	}
-
-	// Check exact match or localhost pattern
-	for _, allowed := range cm.allowedOrigins {
-		if allowed == origin {
-			return true
-		}
-		// Check if allowed origin is a localhost pattern
-		if allowed == "http://localhost:*" || allowed == "https://localhost:*" {
-			if cm.localhostRegex.MatchString(origin) {
-				return true
-			}
-		}
+	if string(ctx.Path()) == "/events" && string(ctx.Method()) == "OPTIONS" {
+		return cm.origins.IsAllowedForAnyApp(origin)
 	}
-	return false
+	return cm.origins.IsAllowed(origin)
 }
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/fasthttp"
+)
+
+// OriginPolicy decides which web origins may use the API. Origins in the global allowlist
+// may use every application; per-application origins only the application they are
+// configured for.
+type OriginPolicy struct {
+	allowedOrigins []string
+	appOrigins     map[string][]string // applicationId -> additional origins
+	localhostRegex *regexp.Regexp
+}
+
+func NewOriginPolicy(allowedOrigins []string, appOrigins map[string][]string) *OriginPolicy {
+	if len(allowedOrigins) == 0 {
+		// Default: allow prappser.app and localhost for development
+		allowedOrigins = []string{"https://prappser.app", "http://localhost:*", "https://localhost:*"}
+	}
+	return &OriginPolicy{
+		allowedOrigins: allowedOrigins,
+		appOrigins:     appOrigins,
+		// Compile regex for localhost with any port
+		localhostRegex: regexp.MustCompile(`^https?://localhost:\d+$`),
+	}
+}
+
+// IsWildcard reports whether every origin is allowed
+func (p *OriginPolicy) IsWildcard() bool {
+	return len(p.allowedOrigins) == 1 && p.allowedOrigins[0] == "*"
+}
+
+// IsAllowed reports whether the origin is in the global allowlist
+func (p *OriginPolicy) IsAllowed(origin string) bool {
+	if p.IsWildcard() {
+		return true
+	}
+	return p.matchesAny(p.allowedOrigins, origin)
+}
+
+// IsAllowedForApp reports whether the origin may use the given application
+func (p *OriginPolicy) IsAllowedForApp(applicationID, origin string) bool {
+	return p.IsAllowed(origin) || p.matchesAny(p.appOrigins[applicationID], origin)
+}
+
+// IsAllowedForAnyApp reports whether the origin may use at least one application
+func (p *OriginPolicy) IsAllowedForAnyApp(origin string) bool {
+	if p.IsAllowed(origin) {
+		return true
+	}
+	for _, origins := range p.appOrigins {
+		if p.matchesAny(origins, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *OriginPolicy) matchesAny(allowedOrigins []string, origin string) bool {
+	// Check exact match or localhost pattern
+	for _, allowed := range allowedOrigins {
+		if allowed == origin {
+			return true
+		}
+		// Check if allowed origin is a localhost pattern
+		if allowed == "http://localhost:*" || allowed == "https://localhost:*" {
+			if p.localhostRegex.MatchString(origin) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applicationIDFromRequest returns the application a request is for, or "" when the
+// route isn't scoped to a single application
+func applicationIDFromRequest(ctx *fasthttp.RequestCtx) string {
+	path := string(ctx.Path())
+	if appID := applicationIDFromPath(path); appID != "" {
+		return appID
+	}
+	if appID := string(ctx.QueryArgs().Peek("applicationId")); appID != "" {
+		return appID
+	}
+	if path == "/events" && string(ctx.Method()) == "POST" {
+		var body struct {
+			Data struct {
+				ApplicationID string `json:"applicationId"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(ctx.PostBody(), &body); err == nil {
+			return body.Data.ApplicationID
+		}
+	}
+	return ""
+}
+
+// applicationIDFromPath returns the application ID of /applications/{id} routes
+func applicationIDFromPath(path string) string {
+	rest, ok := strings.CutPrefix(path, "/applications/")
+	if !ok {
+		return ""
+	}
+	appID, _, _ := strings.Cut(rest, "/")
+	return appID
+}
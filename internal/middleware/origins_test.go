@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func createTestOriginPolicy() *OriginPolicy {
+	return NewOriginPolicy(
+		[]string{"https://prappser.app"},
+		map[string][]string{"app-1": {"https://tenant-one.example"}},
+	)
+}
+
+func TestOriginPolicy_ShouldAllowAppOriginOnlyForItsApplication(t *testing.T) {
+	// given
+	policy := createTestOriginPolicy()
+
+	// then
+	assert.True(t, policy.IsAllowedForApp("app-1", "https://tenant-one.example"))
+	assert.False(t, policy.IsAllowedForApp("app-2", "https://tenant-one.example"))
+	assert.False(t, policy.IsAllowed("https://tenant-one.example"))
+	assert.True(t, policy.IsAllowedForAnyApp("https://tenant-one.example"))
+	assert.True(t, policy.IsAllowedForApp("app-2", "https://prappser.app"))
+	assert.False(t, policy.IsAllowedForAnyApp("https://unknown.example"))
+}
+
+func TestCORSMiddleware_ShouldAllowAppOriginOnApplicationRoutesOfThatApp(t *testing.T) {
+	// given
+	cors := NewCORSMiddleware(createTestOriginPolicy())
+	handler := cors.Handle(func(ctx *fasthttp.RequestCtx) {})
+	request := func(path string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(path)
+		ctx.Request.Header.Set("Origin", "https://tenant-one.example")
+		handler(ctx)
+		return ctx
+	}
+
+	// when
+	ownApp := request("/applications/app-1/state")
+	otherApp := request("/applications/app-2/state")
+	global := request("/users/me")
+
+	// then
+	assert.Equal(t, "https://tenant-one.example", string(ownApp.Response.Header.Peek("Access-Control-Allow-Origin")))
+	assert.Empty(t, otherApp.Response.Header.Peek("Access-Control-Allow-Origin"))
+	assert.Empty(t, global.Response.Header.Peek("Access-Control-Allow-Origin"))
+}
+
+func TestCORSMiddleware_ShouldResolveApplicationOfSharedRoutes(t *testing.T) {
+	// given
+	cors := NewCORSMiddleware(createTestOriginPolicy())
+	handler := cors.Handle(func(ctx *fasthttp.RequestCtx) {})
+	request := func(method, uri, body string) string {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(method)
+		ctx.Request.SetRequestURI(uri)
+		ctx.Request.SetBodyString(body)
+		ctx.Request.Header.Set("Origin", "https://tenant-one.example")
+		handler(ctx)
+		return string(ctx.Response.Header.Peek("Access-Control-Allow-Origin"))
+	}
+
+	// when
+	ownEvent := request("POST", "/events", `{"type":"component_data_changed","data":{"applicationId":"app-1"}}`)
+	otherEvent := request("POST", "/events", `{"type":"component_data_changed","data":{"applicationId":"app-2"}}`)
+	eventPreflight := request("OPTIONS", "/events", "")
+	ownUpload := request("POST", "/storage/upload?applicationId=app-1", "")
+	otherUpload := request("POST", "/storage/upload?applicationId=app-2", "")
+	sync := request("GET", "/sync/state", "")
+	file := request("GET", "/storage/storage-1", "")
+
+	// then
+	assert.Equal(t, "https://tenant-one.example", ownEvent)
+	assert.Empty(t, otherEvent)
+	assert.Equal(t, "https://tenant-one.example", eventPreflight)
+	assert.Equal(t, "https://tenant-one.example", ownUpload)
+	assert.Empty(t, otherUpload)
+	// routes spanning applications only honour the global allowlist
+	assert.Empty(t, sync)
+	assert.Empty(t, file)
+}
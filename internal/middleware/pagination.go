@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Pagination is the parsed limit and offset of a list request
+type Pagination struct {
+	Limit  int
+	Offset int
+}
+
+// ParsePagination reads the limit and offset query parameters of a list request. A
+// missing, malformed or non-positive limit falls back to defaultLimit and larger limits
+// are clamped to maxLimit, so every list is bounded. A missing, malformed or negative
+// offset is 0.
+func ParsePagination(ctx *fasthttp.RequestCtx, defaultLimit, maxLimit int) Pagination {
+	limit := defaultLimit
+	if parsedLimit, err := strconv.Atoi(string(ctx.QueryArgs().Peek("limit"))); err == nil && parsedLimit > 0 {
+		limit = parsedLimit
+	}
+
+	offset := 0
+	if parsedOffset, err := strconv.Atoi(string(ctx.QueryArgs().Peek("offset"))); err == nil && parsedOffset > 0 {
+		offset = parsedOffset
+	}
+
+	return Pagination{Limit: min(limit, maxLimit), Offset: offset}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func createListRequest(query string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/list?" + query)
+	return ctx
+}
+
+func TestParsePagination_ShouldUseDefaultsWhenMissing(t *testing.T) {
+	// when
+	page := ParsePagination(createListRequest(""), 20, 100)
+
+	// then
+	assert.Equal(t, Pagination{Limit: 20, Offset: 0}, page)
+}
+
+func TestParsePagination_ShouldUseRequestedValuesWithinCap(t *testing.T) {
+	// when
+	page := ParsePagination(createListRequest("limit=50&offset=10"), 20, 100)
+
+	// then
+	assert.Equal(t, Pagination{Limit: 50, Offset: 10}, page)
+}
+
+func TestParsePagination_ShouldClampLimitToMax(t *testing.T) {
+	// when
+	page := ParsePagination(createListRequest("limit=1000"), 20, 100)
+
+	// then
+	assert.Equal(t, 100, page.Limit)
+}
+
+func TestParsePagination_ShouldFallBackToDefaultForInvalidValues(t *testing.T) {
+	// when
+	zero := ParsePagination(createListRequest("limit=0&offset=-5"), 20, 100)
+	negative := ParsePagination(createListRequest("limit=-3"), 20, 100)
+	malformed := ParsePagination(createListRequest("limit=abc&offset=xyz"), 20, 100)
+
+	// then
+	assert.Equal(t, Pagination{Limit: 20, Offset: 0}, zero)
+	assert.Equal(t, 20, negative.Limit)
+	assert.Equal(t, Pagination{Limit: 20, Offset: 0}, malformed)
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+)
+
+// RouteClass groups routes that share a request timeout
+type RouteClass string
+
+const (
+	RouteClassAuth            RouteClass = "auth"
+	RouteClassEvent           RouteClass = "event"
+	RouteClassStorageUpload   RouteClass = "storage-upload"
+	RouteClassStorageDownload RouteClass = "storage-download"
+	RouteClassWebSocket       RouteClass = "websocket"
+
+	requestContextKey = "requestContext"
+)
+
+// DefaultRequestTimeouts keep interactive endpoints short while leaving room for large
+// transfers. For websockets the timeout bounds the upgrade handshake only.
+var DefaultRequestTimeouts = map[RouteClass]time.Duration{
+	RouteClassAuth:            10 * time.Second,
+	RouteClassEvent:           30 * time.Second,
+	RouteClassStorageUpload:   10 * time.Minute,
+	RouteClassStorageDownload: 10 * time.Minute,
+	RouteClassWebSocket:       10 * time.Second,
+}
+
+type TimeoutMiddleware struct {
+	timeouts map[RouteClass]time.Duration
+}
+
+// NewTimeoutMiddleware creates the timeout middleware. Route classes without a positive
+// timeout, and routes outside every class, run without a deadline.
+func NewTimeoutMiddleware(timeouts map[RouteClass]time.Duration) *TimeoutMiddleware {
+	return &TimeoutMiddleware{
+		timeouts: timeouts,
+	}
+}
+
+// Handle runs each request under the timeout of its route class. A request that exceeds
+// it is answered with 504, and the context returned by RequestContext is cancelled so
+// storage and database calls made with it stop.
+func (tm *TimeoutMiddleware) Handle(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	handlers := make(map[RouteClass]fasthttp.RequestHandler, len(tm.timeouts))
+	for class, timeout := range tm.timeouts {
+		if timeout > 0 {
+			handlers[class] = withTimeout(class, timeout, next)
+		}
+	}
+
+	return func(ctx *fasthttp.RequestCtx) {
+		if handler, ok := handlers[ClassifyRoute(string(ctx.Method()), string(ctx.Path()))]; ok {
+			handler(ctx)
+			return
+		}
+		next(ctx)
+	}
+}
+
+func withTimeout(class RouteClass, timeout time.Duration, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		requestCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		ctx.SetUserValue(requestContextKey, requestCtx)
+		path := string(ctx.Path())
+
+		done := make(chan struct{})
+		go func() {
+			next(ctx)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-requestCtx.Done():
+			log.Error().
+				Str("routeClass", string(class)).
+				Str("path", path).
+				Dur("timeout", timeout).
+				Msg("Request timed out")
+			// The handler may still hold ctx; TimeoutErrorWithCode makes the server
+			// answer from a copy and leave ctx alone until the handler returns
+			ctx.TimeoutErrorWithCode("Request timed out", fasthttp.StatusGatewayTimeout)
+		}
+	}
+}
+
+// ClassifyRoute returns the timeout class of a route, or an empty class for routes that
+// have no dedicated timeout
+func ClassifyRoute(method, path string) RouteClass {
+	switch {
+	case path == "/users/challenge" || path == "/users/auth" || path == "/users/refresh" || path == "/users/owners/register":
+		return RouteClassAuth
+	case path == "/events" || strings.HasPrefix(path, "/events/") || strings.HasPrefix(path, "/sync/"):
+		return RouteClassEvent
+	case path == "/users/me/avatar" && method == "POST":
+		return RouteClassStorageUpload
+	case strings.HasPrefix(path, "/storage/"):
+		if method == "GET" {
+			return RouteClassStorageDownload
+		}
+		return RouteClassStorageUpload
+	case path == "/ws":
+		return RouteClassWebSocket
+	default:
+		return ""
+	}
+}
+
+// RequestContext returns the context to pass to services for this request. It carries
+// the route class deadline when one applies, otherwise it is the request itself.
+func RequestContext(ctx *fasthttp.RequestCtx) context.Context {
+	if requestCtx, ok := ctx.UserValue(requestContextKey).(context.Context); ok {
+		return requestCtx
+	}
+	return ctx
+}
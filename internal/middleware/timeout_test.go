@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// serve runs one request through handler on an in-memory server and returns the response
+func serve(t *testing.T, handler fasthttp.RequestHandler, method, path string) *fasthttp.Response {
+	listener := fasthttputil.NewInmemoryListener()
+	defer listener.Close()
+	go fasthttp.Serve(listener, handler)
+
+	client := &fasthttp.Client{Dial: func(addr string) (net.Conn, error) { return listener.Dial() }}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetMethod(method)
+	req.SetRequestURI("http://test" + path)
+
+	resp := &fasthttp.Response{}
+	if err := client.Do(req, resp); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	return resp
+}
+
+func TestTimeoutMiddleware_ShouldAbortSlowHandlerWith504(t *testing.T) {
+	// given
+	cancelled := make(chan bool, 1)
+	slow := func(ctx *fasthttp.RequestCtx) {
+		select {
+		case <-RequestContext(ctx).Done():
+			cancelled <- true
+		case <-time.After(2 * time.Second):
+			cancelled <- false
+		}
+		ctx.SetStatusCode(fasthttp.StatusOK)
+	}
+	middleware := NewTimeoutMiddleware(map[RouteClass]time.Duration{RouteClassEvent: 50 * time.Millisecond})
+
+	// when
+	resp := serve(t, middleware.Handle(slow), "POST", "/events")
+
+	// then
+	assert.Equal(t, fasthttp.StatusGatewayTimeout, resp.StatusCode())
+	assert.True(t, <-cancelled)
+}
+
+func TestTimeoutMiddleware_ShouldApplyTimeoutOfRouteClassOnly(t *testing.T) {
+	// given
+	slow := func(ctx *fasthttp.RequestCtx) {
+		time.Sleep(100 * time.Millisecond)
+		ctx.SetStatusCode(fasthttp.StatusOK)
+	}
+	middleware := NewTimeoutMiddleware(map[RouteClass]time.Duration{
+		RouteClassAuth:            50 * time.Millisecond,
+		RouteClassStorageDownload: time.Second,
+	})
+
+	// when
+	authResp := serve(t, middleware.Handle(slow), "POST", "/users/auth")
+	downloadResp := serve(t, middleware.Handle(slow), "GET", "/storage/file-1")
+	unclassifiedResp := serve(t, middleware.Handle(slow), "GET", "/applications")
+
+	// then
+	assert.Equal(t, fasthttp.StatusGatewayTimeout, authResp.StatusCode())
+	assert.Equal(t, fasthttp.StatusOK, downloadResp.StatusCode())
+	assert.Equal(t, fasthttp.StatusOK, unclassifiedResp.StatusCode())
+}
+
+func TestClassifyRoute_ShouldSeparateUploadsFromDownloads(t *testing.T) {
+	// then
+	assert.Equal(t, RouteClassStorageUpload, ClassifyRoute("POST", "/storage/upload"))
+	assert.Equal(t, RouteClassStorageUpload, ClassifyRoute("PUT", "/storage/chunks/file-1/0"))
+	assert.Equal(t, RouteClassStorageDownload, ClassifyRoute("GET", "/storage/file-1/thumb"))
+	assert.Equal(t, RouteClassEvent, ClassifyRoute("GET", "/sync/state"))
+	assert.Equal(t, RouteClassWebSocket, ClassifyRoute("GET", "/ws"))
+	assert.Equal(t, RouteClass(""), ClassifyRoute("GET", "/health"))
+}
+
+func TestRequestContext_ShouldFallBackToRequestWithoutTimeout(t *testing.T) {
+	// given
+	ctx := &fasthttp.RequestCtx{}
+
+	// when
+	requestCtx := RequestContext(ctx)
+
+	// then
+	_, hasDeadline := requestCtx.Deadline()
+	assert.False(t, hasDeadline)
+	assert.Equal(t, context.Context(ctx), requestCtx)
+}
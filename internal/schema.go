@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/prappser/prappser_server/internal/status"
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+)
+
+// RequiredSchemaVersion is the migration version the binary's queries are written against.
+// Bump it together with every new file in files/migrations.
+const RequiredSchemaVersion uint = 25
+
+var (
+	ErrSchemaBehind = errors.New("database schema is behind the version this binary requires")
+	ErrSchemaDirty  = errors.New("database schema is dirty after a failed migration")
+)
+
+// SchemaChecker reads the migration state golang-migrate records in schema_migrations
+type SchemaChecker struct {
+	db *sql.DB
+}
+
+func NewSchemaChecker(db *sql.DB) *SchemaChecker {
+	return &SchemaChecker{db: db}
+}
+
+// SchemaStatus returns the current and required schema versions
+func (c *SchemaChecker) SchemaStatus() (status.SchemaStatus, error) {
+	schema := status.SchemaStatus{RequiredVersion: RequiredSchemaVersion}
+
+	var version int64
+	err := c.db.QueryRow("SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &schema.Dirty)
+	if err == sql.ErrNoRows {
+		return schema, nil
+	}
+	if err != nil {
+		return schema, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	schema.Version = uint(version)
+	return schema, nil
+}
+
+// CheckSchema returns an error when the database cannot serve this binary: its schema is
+// older than required or a migration was left half-applied. A newer schema is accepted
+// so that rolling back the binary keeps working.
+func CheckSchema(schema status.SchemaStatus) error {
+	if schema.Dirty {
+		return fmt.Errorf("%w: version %d", ErrSchemaDirty, schema.Version)
+	}
+	if schema.Version < schema.RequiredVersion {
+		return fmt.Errorf("%w: have %d, need %d", ErrSchemaBehind, schema.Version, schema.RequiredVersion)
+	}
+	return nil
+}
+
+// SchemaGate refuses all traffic, health checks included, with 503 when schemaErr is set,
+// so a partial deploy is never marked ready
+func SchemaGate(schemaErr error, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	if schemaErr == nil {
+		return next
+	}
+	return func(ctx *fasthttp.RequestCtx) {
+		log.Error().Err(schemaErr).Str("path", string(ctx.Path())).Msg("[SCHEMA] Refusing request")
+		ctx.Error("Service Unavailable: database schema is not ready", fasthttp.StatusServiceUnavailable)
+	}
+}
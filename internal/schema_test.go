@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prappser/prappser_server/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestCheckSchema_ShouldRejectVersionBehindRequired(t *testing.T) {
+	// when
+	err := CheckSchema(status.SchemaStatus{Version: 14, RequiredVersion: 16})
+
+	// then
+	assert.True(t, errors.Is(err, ErrSchemaBehind))
+}
+
+func TestCheckSchema_ShouldRejectDirtySchema(t *testing.T) {
+	// when
+	err := CheckSchema(status.SchemaStatus{Version: 16, RequiredVersion: 16, Dirty: true})
+
+	// then
+	assert.True(t, errors.Is(err, ErrSchemaDirty))
+}
+
+func TestCheckSchema_ShouldAcceptCurrentAndNewerSchema(t *testing.T) {
+	// then
+	assert.NoError(t, CheckSchema(status.SchemaStatus{Version: 16, RequiredVersion: 16}))
+	assert.NoError(t, CheckSchema(status.SchemaStatus{Version: 17, RequiredVersion: 16}))
+}
+
+func TestSchemaGate_ShouldRefuseTrafficOnVersionMismatch(t *testing.T) {
+	// given
+	called := false
+	next := func(ctx *fasthttp.RequestCtx) { called = true }
+	schemaErr := CheckSchema(status.SchemaStatus{Version: 14, RequiredVersion: 16})
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/health")
+
+	// when
+	SchemaGate(schemaErr, next)(ctx)
+
+	// then
+	assert.False(t, called)
+	assert.Equal(t, fasthttp.StatusServiceUnavailable, ctx.Response.StatusCode())
+}
+
+func TestSchemaGate_ShouldPassTrafficWhenSchemaIsReady(t *testing.T) {
+	// given
+	called := false
+	next := func(ctx *fasthttp.RequestCtx) { called = true }
+
+	// when
+	SchemaGate(nil, next)(&fasthttp.RequestCtx{})
+
+	// then
+	assert.True(t, called)
+}
+
+func TestRequiredSchemaVersion_ShouldMatchLatestMigration(t *testing.T) {
+	// given
+	entries, err := os.ReadDir("../files/migrations")
+	assert.NoError(t, err)
+
+	// when
+	var latest uint
+	for _, entry := range entries {
+		prefix, _, _ := strings.Cut(entry.Name(), "_")
+		if version, err := strconv.ParseUint(prefix, 10, 32); err == nil && uint(version) > latest {
+			latest = uint(version)
+		}
+	}
+
+	// then
+	assert.Equal(t, latest, RequiredSchemaVersion)
+}
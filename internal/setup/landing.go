@@ -0,0 +1,73 @@
+package setup
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+)
+
+// DefaultLandingPath is where the setup landing page is served when no path is configured
+const DefaultLandingPath = "/"
+
+// LandingConfig controls the landing page shown on the bare domain
+type LandingConfig struct {
+	// Path serves the setup page while no owner is registered
+	Path string
+	// RedirectURL is where the landing path and / send visitors once an owner is
+	// registered. Empty answers them with 404.
+	RedirectURL string
+}
+
+const landingPageHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Prappser Server</title>
+</head>
+<body>
+<h1>Prappser Server</h1>
+<p>This server has no owner yet. Open the Prappser app, add this server and register as its owner with the master password.</p>
+</body>
+</html>
+`
+
+// ServeLanding handles GET on the landing path and on /. Before an owner is registered
+// the landing path shows the setup page. Afterwards both redirect to the configured URL,
+// or answer 404 when none is configured.
+func (s *SetupEndpoints) ServeLanding(ctx *fasthttp.RequestCtx) {
+	hasOwner, err := s.hasOwner()
+	if err != nil {
+		log.Error().Err(err).Msg("[SETUP] Failed to check owner for landing page")
+		ctx.Error("Internal server error", fasthttp.StatusInternalServerError)
+		return
+	}
+
+	if !hasOwner {
+		if string(ctx.Path()) != s.landingPath() {
+			ctx.Error("Not Found", fasthttp.StatusNotFound)
+			return
+		}
+		ctx.SetStatusCode(fasthttp.StatusOK)
+		ctx.SetContentType("text/html; charset=utf-8")
+		ctx.SetBodyString(landingPageHTML)
+		return
+	}
+
+	if s.landing.RedirectURL == "" {
+		ctx.Error("Not Found", fasthttp.StatusNotFound)
+		return
+	}
+	ctx.Redirect(s.landing.RedirectURL, fasthttp.StatusFound)
+}
+
+// IsLandingPath reports whether the path is answered by ServeLanding
+func (s *SetupEndpoints) IsLandingPath(path string) bool {
+	return path == "/" || path == s.landingPath()
+}
+
+func (s *SetupEndpoints) landingPath() string {
+	if s.landing.Path == "" {
+		return DefaultLandingPath
+	}
+	return s.landing.Path
+}
@@ -0,0 +1,69 @@
+package setup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func createLandingEndpoints(landing LandingConfig, hasOwner bool) *SetupEndpoints {
+	return &SetupEndpoints{
+		landing:  landing,
+		hasOwner: func() (bool, error) { return hasOwner, nil },
+	}
+}
+
+func requestLanding(endpoints *SetupEndpoints, path string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI(path)
+	endpoints.ServeLanding(ctx)
+	return ctx
+}
+
+func TestServeLanding_ShouldServeSetupPageBeforeOwnerExists(t *testing.T) {
+	// given
+	endpoints := createLandingEndpoints(LandingConfig{Path: "/welcome", RedirectURL: "https://prappser.app"}, false)
+
+	// when
+	landingCtx := requestLanding(endpoints, "/welcome")
+	rootCtx := requestLanding(endpoints, "/")
+
+	// then
+	assert.Equal(t, fasthttp.StatusOK, landingCtx.Response.StatusCode())
+	assert.Contains(t, string(landingCtx.Response.Body()), "no owner yet")
+	assert.Equal(t, fasthttp.StatusNotFound, rootCtx.Response.StatusCode())
+}
+
+func TestServeLanding_ShouldRedirectAfterOwnerExistsWhenConfigured(t *testing.T) {
+	// given
+	endpoints := createLandingEndpoints(LandingConfig{RedirectURL: "https://prappser.app"}, true)
+
+	// when
+	ctx := requestLanding(endpoints, "/")
+
+	// then
+	assert.Equal(t, fasthttp.StatusFound, ctx.Response.StatusCode())
+	assert.Equal(t, "https://prappser.app/", string(ctx.Response.Header.Peek("Location")))
+}
+
+func TestServeLanding_ShouldReturnNotFoundAfterOwnerExistsWithoutRedirect(t *testing.T) {
+	// given
+	endpoints := createLandingEndpoints(LandingConfig{}, true)
+
+	// when
+	ctx := requestLanding(endpoints, "/")
+
+	// then
+	assert.Equal(t, fasthttp.StatusNotFound, ctx.Response.StatusCode())
+}
+
+func TestIsLandingPath_ShouldMatchRootAndConfiguredPath(t *testing.T) {
+	// given
+	endpoints := createLandingEndpoints(LandingConfig{Path: "/welcome"}, false)
+
+	// then
+	assert.True(t, endpoints.IsLandingPath("/"))
+	assert.True(t, endpoints.IsLandingPath("/welcome"))
+	assert.False(t, endpoints.IsLandingPath("/status"))
+}
@@ -10,13 +10,19 @@ import (
 )
 
 type SetupEndpoints struct {
-	db *sql.DB
+	db      *sql.DB
+	landing LandingConfig
+	// hasOwner reports whether an owner is registered; it is HasOwner outside of tests
+	hasOwner func() (bool, error)
 }
 
-func NewSetupEndpoints(db *sql.DB) *SetupEndpoints {
-	return &SetupEndpoints{
-		db: db,
+func NewSetupEndpoints(db *sql.DB, landing LandingConfig) *SetupEndpoints {
+	s := &SetupEndpoints{
+		db:      db,
+		landing: landing,
 	}
+	s.hasOwner = s.HasOwner
+	return s
 }
 
 // HasOwner checks if an owner has been registered
@@ -2,6 +2,7 @@ package status
 
 import (
 	"github.com/goccy/go-json"
+	"github.com/prappser/prappser_server/internal/user"
 	"github.com/rs/zerolog/log"
 	"github.com/valyala/fasthttp"
 )
@@ -10,28 +11,52 @@ type StorageUsageGetter interface {
 	GetTotalUsedBytes() (int64, error)
 }
 
+type BroadcastStatsGetter interface {
+	DroppedBroadcasts() int64
+	QueueDepth() int
+}
+
+// SchemaStatus is the database migration state compared to what the binary requires
+type SchemaStatus struct {
+	Version         uint `json:"version"`
+	RequiredVersion uint `json:"requiredVersion"`
+	Dirty           bool `json:"dirty"`
+}
+
+type SchemaStatusGetter interface {
+	SchemaStatus() (SchemaStatus, error)
+}
+
 type StatusEndpoints struct {
 	version          string
 	maxFileSizeBytes int64
 	chunkSizeBytes   int64
 	storageRepo      StorageUsageGetter
+	broadcastStats   BroadcastStatsGetter
+	schemaStatus     SchemaStatusGetter
 }
 
-func NewEndpoints(version string, maxFileSizeBytes, chunkSizeBytes int64, storageRepo StorageUsageGetter) *StatusEndpoints {
+func NewEndpoints(version string, maxFileSizeBytes, chunkSizeBytes int64, storageRepo StorageUsageGetter, broadcastStats BroadcastStatsGetter, schemaStatus SchemaStatusGetter) *StatusEndpoints {
 	return &StatusEndpoints{
 		version:          version,
 		maxFileSizeBytes: maxFileSizeBytes,
 		chunkSizeBytes:   chunkSizeBytes,
 		storageRepo:      storageRepo,
+		broadcastStats:   broadcastStats,
+		schemaStatus:     schemaStatus,
 	}
 }
 
 type StatusResponse struct {
-	Health           string `json:"health"`
-	Version          string `json:"version"`
-	MaxFileSizeBytes int64  `json:"maxFileSizeBytes"`
-	ChunkSizeBytes   int64  `json:"chunkSizeBytes"`
-	StorageUsedBytes int64  `json:"storageUsedBytes"`
+	Health              string `json:"health"`
+	Version             string `json:"version"`
+	MaxFileSizeBytes    int64  `json:"maxFileSizeBytes"`
+	ChunkSizeBytes      int64  `json:"chunkSizeBytes"`
+	StorageUsedBytes    int64  `json:"storageUsedBytes"`
+	DroppedBroadcasts   int64  `json:"droppedBroadcasts"`
+	BroadcastQueueDepth int    `json:"broadcastQueueDepth"`
+	// Schema is only reported to owners
+	Schema *SchemaStatus `json:"schema,omitempty"`
 }
 
 func (se *StatusEndpoints) Status(ctx *fasthttp.RequestCtx) {
@@ -45,12 +70,30 @@ func (se *StatusEndpoints) Status(ctx *fasthttp.RequestCtx) {
 		}
 	}
 
+	var droppedBroadcasts int64
+	var broadcastQueueDepth int
+	if se.broadcastStats != nil {
+		droppedBroadcasts = se.broadcastStats.DroppedBroadcasts()
+		broadcastQueueDepth = se.broadcastStats.QueueDepth()
+	}
+
 	response := StatusResponse{
-		Health:           "OK",
-		Version:          se.version,
-		MaxFileSizeBytes: se.maxFileSizeBytes,
-		ChunkSizeBytes:   se.chunkSizeBytes,
-		StorageUsedBytes: storageUsedBytes,
+		Health:              "OK",
+		Version:             se.version,
+		MaxFileSizeBytes:    se.maxFileSizeBytes,
+		ChunkSizeBytes:      se.chunkSizeBytes,
+		StorageUsedBytes:    storageUsedBytes,
+		DroppedBroadcasts:   droppedBroadcasts,
+		BroadcastQueueDepth: broadcastQueueDepth,
+	}
+
+	if authenticatedUser, ok := ctx.UserValue("user").(*user.User); ok && authenticatedUser.Role == user.RoleOwner && se.schemaStatus != nil {
+		schema, err := se.schemaStatus.SchemaStatus()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to get schema status")
+		} else {
+			response.Schema = &schema
+		}
 	}
 
 	ctx.SetContentType("application/json")
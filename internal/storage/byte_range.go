@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrRangeNotSatisfiable is returned for a byte range that lies outside the file
+var ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// byteRange is a single range of bytes within a file of known size
+type byteRange struct {
+	offset int64
+	length int64
+}
+
+// end is the position of the range's last byte, as used in Content-Range
+func (r byteRange) end() int64 {
+	return r.offset + r.length - 1
+}
+
+// parseByteRange parses a Range header of the form bytes=start-end, bytes=start- or
+// bytes=-suffixLength against a file of the given size. It reports ok=false for headers it
+// does not support, such as multiple ranges or other units, which are served as the full
+// file. A range starting beyond the end of the file returns ErrRangeNotSatisfiable.
+func parseByteRange(header string, size int64) (r byteRange, ok bool, err error) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return byteRange{}, false, nil
+	}
+	startStr, endStr, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return byteRange{}, false, nil
+	}
+
+	if startStr == "" {
+		suffix, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || suffix < 0 {
+			return byteRange{}, false, nil
+		}
+		if suffix == 0 || size == 0 {
+			return byteRange{}, true, ErrRangeNotSatisfiable
+		}
+		suffix = min(suffix, size)
+		return byteRange{offset: size - suffix, length: suffix}, true, nil
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return byteRange{}, false, nil
+	}
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return byteRange{}, false, nil
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return byteRange{}, true, ErrRangeNotSatisfiable
+	}
+	return byteRange{offset: start, length: end - start + 1}, true, nil
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseByteRange_ShouldParseClosedRange(t *testing.T) {
+	// when
+	r, ok, err := parseByteRange("bytes=2-5", 10)
+
+	// then
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, byteRange{offset: 2, length: 4}, r)
+}
+
+func TestParseByteRange_ShouldClampOpenAndOverlongRanges(t *testing.T) {
+	// when
+	open, _, openErr := parseByteRange("bytes=7-", 10)
+	overlong, _, overlongErr := parseByteRange("bytes=7-100", 10)
+	suffix, _, suffixErr := parseByteRange("bytes=-3", 10)
+
+	// then
+	assert.NoError(t, openErr)
+	assert.NoError(t, overlongErr)
+	assert.NoError(t, suffixErr)
+	assert.Equal(t, byteRange{offset: 7, length: 3}, open)
+	assert.Equal(t, byteRange{offset: 7, length: 3}, overlong)
+	assert.Equal(t, byteRange{offset: 7, length: 3}, suffix)
+}
+
+func TestParseByteRange_ShouldRejectRangeBeyondFile(t *testing.T) {
+	// when
+	_, ok, err := parseByteRange("bytes=10-20", 10)
+
+	// then
+	assert.True(t, ok)
+	assert.ErrorIs(t, err, ErrRangeNotSatisfiable)
+}
+
+func TestParseByteRange_ShouldIgnoreUnsupportedRanges(t *testing.T) {
+	for _, header := range []string{"bytes=0-1,4-5", "items=0-1", "bytes=5-2", "bytes=abc"} {
+		// when
+		_, ok, err := parseByteRange(header, 10)
+
+		// then
+		assert.False(t, ok, header)
+		assert.NoError(t, err, header)
+	}
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+)
+
+// sniffLength is how many leading bytes content sniffing looks at
+const sniffLength = 512
+
+// quickTimeAtoms are the top-level atoms a QuickTime file without an ftyp box may start with
+var quickTimeAtoms = [][]byte{[]byte("moov"), []byte("mdat"), []byte("wide"), []byte("free"), []byte("skip")}
+
+// sniffContentType detects the content type of a file from its leading bytes. It extends
+// http.DetectContentType with QuickTime movies, which the standard sniffer does not know.
+func sniffContentType(data []byte) string {
+	if len(data) > sniffLength {
+		data = data[:sniffLength]
+	}
+	if isQuickTime(data) {
+		return "video/mov"
+	}
+	contentType := http.DetectContentType(data)
+	if i := bytes.IndexByte([]byte(contentType), ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return contentType
+}
+
+func isQuickTime(data []byte) bool {
+	if len(data) < 12 {
+		return false
+	}
+	if bytes.Equal(data[4:8], []byte("ftyp")) {
+		return bytes.Equal(data[8:12], []byte("qt  "))
+	}
+	for _, atom := range quickTimeAtoms {
+		if bytes.Equal(data[4:8], atom) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSniffedContentType rejects a file whose content does not match its declared content
+// type, so a client cannot store e.g. an executable labeled image/png. Files declared as
+// application/octet-stream are not checked; they are only ever served as downloads.
+func checkSniffedContentType(declared string, data []byte) error {
+	if declared == octetStreamContentType {
+		return nil
+	}
+	sniffed := sniffContentType(data)
+	if sameContainer(declared, sniffed) {
+		return nil
+	}
+	return fmt.Errorf("%w: declared %s, content is %s", ErrContentTypeMismatch, declared, sniffed)
+}
+
+// sameContainer treats MP4 and QuickTime as one type, since both use the same container
+// format and clients label them inconsistently
+func sameContainer(declared, sniffed string) bool {
+	if declared == sniffed {
+		return true
+	}
+	isoMedia := map[string]bool{"video/mp4": true, "video/mov": true}
+	return isoMedia[declared] && isoMedia[sniffed]
+}
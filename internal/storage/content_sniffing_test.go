@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestJPEG(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestUpload_ShouldRejectJPEGLabeledAsPNG(t *testing.T) {
+	// given
+	service := NewService(nil, nil, Config{MaxFileSize: 1024 * 1024, ExternalURL: "http://localhost"})
+	req := &UploadRequest{ID: "file-1", Filename: "photo.png", ContentType: "image/png"}
+
+	// when
+	_, err := service.Upload(context.Background(), nil, "user-key", req, bytes.NewReader(createTestJPEG(t)))
+
+	// then
+	assert.True(t, errors.Is(err, ErrContentTypeMismatch))
+	assert.ErrorContains(t, err, "declared image/png, content is image/jpeg")
+}
+
+func TestCheckSniffedContentType_ShouldRejectExecutableLabeledAsImage(t *testing.T) {
+	// given - the header of a Windows executable
+	executable := append([]byte("MZ\x90\x00\x03\x00\x00\x00"), make([]byte, 64)...)
+
+	// when
+	err := checkSniffedContentType("image/png", executable)
+
+	// then
+	assert.True(t, errors.Is(err, ErrContentTypeMismatch))
+}
+
+func TestCheckSniffedContentType_ShouldAcceptMatchingContent(t *testing.T) {
+	// given
+	mp4 := []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom")
+	mov := []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00qt  ")
+
+	// then
+	assert.NoError(t, checkSniffedContentType("image/jpeg", createTestJPEG(t)))
+	assert.NoError(t, checkSniffedContentType("image/png", createTestPNG(t, 4, 4)))
+	assert.NoError(t, checkSniffedContentType("video/mp4", mp4))
+	assert.NoError(t, checkSniffedContentType("video/mov", mov))
+	assert.NoError(t, checkSniffedContentType("video/mp4", mov), "MP4 and QuickTime share a container")
+	assert.NoError(t, checkSniffedContentType(octetStreamContentType, []byte("anything")))
+}
+
+func TestSniffContentType_ShouldDetectWebP(t *testing.T) {
+	// given
+	webp := []byte("RIFF\x24\x00\x00\x00WEBPVP8 ")
+
+	// when
+	contentType := sniffContentType(webp)
+
+	// then
+	assert.Equal(t, "image/webp", contentType)
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -12,6 +13,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/prappser/prappser_server/internal/application"
 	"github.com/prappser/prappser_server/internal/event"
+	"github.com/prappser/prappser_server/internal/middleware"
 	"github.com/prappser/prappser_server/internal/user"
 	"github.com/rs/zerolog/log"
 	"github.com/valyala/fasthttp"
@@ -27,14 +29,16 @@ type Endpoints struct {
 	appRepo      *application.Repository
 	eventService EventService
 	userRepo     user.UserRepository
+	mediaHeaders mediaHeaderPolicy
 }
 
-func NewEndpoints(service *Service, appRepo *application.Repository, eventService EventService, userRepo user.UserRepository) *Endpoints {
+func NewEndpoints(service *Service, appRepo *application.Repository, eventService EventService, userRepo user.UserRepository, mediaHeaders MediaHeaders) *Endpoints {
 	return &Endpoints{
 		service:      service,
 		appRepo:      appRepo,
 		eventService: eventService,
 		userRepo:     userRepo,
+		mediaHeaders: newMediaHeaderPolicy(mediaHeaders),
 	}
 }
 
@@ -67,6 +71,10 @@ func (e *Endpoints) Upload(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
+	if !e.checkUploadSize(ctx, appID) {
+		return
+	}
+
 	form, err := ctx.MultipartForm()
 	if err != nil {
 		ctx.Error("Failed to parse multipart form", fasthttp.StatusBadRequest)
@@ -109,13 +117,17 @@ func (e *Endpoints) Upload(ctx *fasthttp.RequestCtx) {
 		Checksum:    checksum,
 	}
 
-	if req.ContentType == "" || req.ContentType == "application/octet-stream" {
+	if req.ContentType == "" || req.ContentType == octetStreamContentType {
 		req.ContentType = detectContentType(fileHeader.Filename)
 	}
 
-	stored, err := e.service.Upload(ctx, appID, publicKey, req, file)
+	stored, err := e.service.Upload(middleware.RequestContext(ctx), appID, publicKey, req, file)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to upload file")
+		if errors.Is(err, ErrQuotaExceeded) {
+			ctx.Error(err.Error(), fasthttp.StatusRequestEntityTooLarge)
+			return
+		}
 		ctx.Error(err.Error(), fasthttp.StatusBadRequest)
 		return
 	}
@@ -147,6 +159,29 @@ func (e *Endpoints) Upload(ctx *fasthttp.RequestCtx) {
 	ctx.SetBody(response)
 }
 
+// checkUploadSize refuses with 413 an upload whose declared Content-Length is over the file
+// size limit or cannot fit in the application's remaining quota, before the multipart form
+// is parsed and the file stored. Bodies over the server's MaxRequestBodySize never reach
+// the handler.
+func (e *Endpoints) checkUploadSize(ctx *fasthttp.RequestCtx, appID *string) bool {
+	contentLength := ctx.Request.Header.ContentLength()
+	if e.service.UploadTooLarge(contentLength) {
+		log.Error().Int("contentLength", contentLength).Msg("[STORAGE] Upload rejected: request body too large")
+		ctx.Error("File too large", fasthttp.StatusRequestEntityTooLarge)
+		return false
+	}
+	if err := e.service.CheckUploadQuota(appID, contentLength); err != nil {
+		log.Error().Err(err).Int("contentLength", contentLength).Msg("[STORAGE] Upload rejected by application quota")
+		if errors.Is(err, ErrQuotaExceeded) {
+			ctx.Error(err.Error(), fasthttp.StatusRequestEntityTooLarge)
+			return false
+		}
+		ctx.Error("Failed to check storage quota", fasthttp.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
 func (e *Endpoints) UploadUserAvatar(ctx *fasthttp.RequestCtx) {
 	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
 	if !ok || authenticatedUser == nil {
@@ -161,6 +196,10 @@ func (e *Endpoints) UploadUserAvatar(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
+	if !e.checkUploadSize(ctx, nil) {
+		return
+	}
+
 	form, err := ctx.MultipartForm()
 	if err != nil {
 		ctx.Error("Failed to parse multipart form", fasthttp.StatusBadRequest)
@@ -203,13 +242,17 @@ func (e *Endpoints) UploadUserAvatar(ctx *fasthttp.RequestCtx) {
 		Checksum:    checksum,
 	}
 
-	if req.ContentType == "" || req.ContentType == "application/octet-stream" {
+	if req.ContentType == "" || req.ContentType == octetStreamContentType {
 		req.ContentType = detectContentType(fileHeader.Filename)
 	}
 
-	stored, err := e.service.Upload(ctx, nil, publicKey, req, file)
+	stored, err := e.service.UploadAvatar(middleware.RequestContext(ctx), publicKey, req, file)
 	if err != nil {
 		log.Error().Err(err).Msg("[STORAGE] Failed to upload avatar")
+		if errors.Is(err, ErrInvalidAvatar) {
+			ctx.Error("Avatar must be a JPEG, PNG, GIF or WebP image", fasthttp.StatusBadRequest)
+			return
+		}
 		ctx.Error("Failed to upload avatar", fasthttp.StatusInternalServerError)
 		return
 	}
@@ -222,7 +265,7 @@ func (e *Endpoints) UploadUserAvatar(ctx *fasthttp.RequestCtx) {
 
 	// Delete previous avatar after successful upload and DB update (best-effort)
 	if authenticatedUser.AvatarStorageID != nil {
-		if delErr := e.service.Delete(ctx, *authenticatedUser.AvatarStorageID, publicKey); delErr != nil {
+		if delErr := e.service.Delete(ctx, *authenticatedUser.AvatarStorageID, publicKey, false); delErr != nil {
 			log.Warn().Err(delErr).Str("storageId", *authenticatedUser.AvatarStorageID).Msg("[STORAGE] Failed to delete previous avatar")
 		}
 	}
@@ -247,6 +290,15 @@ func (e *Endpoints) InitChunkedUpload(ctx *fasthttp.RequestCtx) {
 
 	response, err := e.service.InitChunkedUpload(ctx, &appID, publicKey, &req)
 	if err != nil {
+		log.Error().Err(err).Msg("[STORAGE] Failed to initialize chunked upload")
+		if errors.Is(err, ErrTooManyPendingUploads) {
+			ctx.Error(err.Error(), fasthttp.StatusTooManyRequests)
+			return
+		}
+		if errors.Is(err, ErrQuotaExceeded) {
+			ctx.Error(err.Error(), fasthttp.StatusRequestEntityTooLarge)
+			return
+		}
 		ctx.Error(err.Error(), fasthttp.StatusBadRequest)
 		return
 	}
@@ -278,7 +330,7 @@ func (e *Endpoints) UploadChunk(ctx *fasthttp.RequestCtx) {
 	}
 
 	chunkIndex, err := strconv.Atoi(chunkIndexStr)
-	if err != nil {
+	if err != nil || chunkIndex < 0 {
 		ctx.Error("Invalid chunk index", fasthttp.StatusBadRequest)
 		return
 	}
@@ -295,12 +347,50 @@ func (e *Endpoints) UploadChunk(ctx *fasthttp.RequestCtx) {
 	}
 
 	body := ctx.PostBody()
-	if err := e.service.UploadChunk(ctx, storageID, chunkIndex, bytes.NewReader(body)); err != nil {
+	if err := e.service.UploadChunk(middleware.RequestContext(ctx), storageID, chunkIndex, bytes.NewReader(body)); err != nil {
+		ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+}
+
+func (e *Endpoints) GetChunkedUploadProgress(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+	publicKey := authenticatedUser.PublicKey
+
+	storageID, ok := ctx.UserValue("storageID").(string)
+	if !ok || storageID == "" {
+		ctx.Error("Storage ID is required", fasthttp.StatusBadRequest)
+		return
+	}
+
+	stored, err := e.service.Get(ctx, storageID)
+	if err != nil {
+		ctx.Error("Storage not found", fasthttp.StatusNotFound)
+		return
+	}
+
+	if stored.UploaderPublicKey != publicKey {
+		ctx.Error("Not authorized", fasthttp.StatusForbidden)
+		return
+	}
+
+	progress, err := e.service.GetChunkedUploadProgress(ctx, storageID)
+	if err != nil {
+		log.Error().Err(err).Str("storageId", storageID).Msg("[STORAGE] Failed to get chunked upload progress")
 		ctx.Error(err.Error(), fasthttp.StatusBadRequest)
 		return
 	}
 
+	response, _ := json.Marshal(progress)
+	ctx.SetContentType("application/json")
 	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBody(response)
 }
 
 func (e *Endpoints) CompleteChunkedUpload(ctx *fasthttp.RequestCtx) {
@@ -328,8 +418,17 @@ func (e *Endpoints) CompleteChunkedUpload(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	completedStorage, err := e.service.CompleteChunkedUpload(ctx, storageID)
+	completedStorage, err := e.service.CompleteChunkedUpload(middleware.RequestContext(ctx), storageID)
 	if err != nil {
+		log.Error().Err(err).Str("storageId", storageID).Msg("[STORAGE] Failed to complete chunked upload")
+		if errors.Is(err, ErrMissingChunks) {
+			ctx.Error(err.Error(), fasthttp.StatusConflict)
+			return
+		}
+		if errors.Is(err, ErrQuotaExceeded) {
+			ctx.Error(err.Error(), fasthttp.StatusRequestEntityTooLarge)
+			return
+		}
 		ctx.Error(err.Error(), fasthttp.StatusBadRequest)
 		return
 	}
@@ -367,16 +466,22 @@ func (e *Endpoints) GetFile(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
+	if rangeHeader := ctx.Request.Header.Peek("Range"); len(rangeHeader) > 0 {
+		if e.serveRange(ctx, stored, string(rangeHeader)) {
+			return
+		}
+	}
+
 	storageID := stored.ID
-	reader, stored, err := e.service.GetData(ctx, storageID)
+	reader, stored, err := e.service.GetData(middleware.RequestContext(ctx), storageID)
 	if err != nil {
 		ctx.Error("Failed to retrieve file", fasthttp.StatusInternalServerError)
 		return
 	}
 	defer reader.Close()
 
-	ctx.SetContentType(stored.ContentType)
-	ctx.Response.Header.Set("Content-Disposition", "inline; filename=\""+stored.Filename+"\"")
+	e.mediaHeaders.apply(ctx, stored.ContentType, stored.Filename)
+	ctx.Response.Header.Set("Accept-Ranges", "bytes")
 	ctx.Response.Header.Set("Content-Length", strconv.FormatInt(stored.SizeBytes, 10))
 
 	if _, err := io.Copy(ctx, reader); err != nil {
@@ -384,6 +489,40 @@ func (e *Endpoints) GetFile(ctx *fasthttp.RequestCtx) {
 	}
 }
 
+// serveRange responds with the part of the file the Range header asks for: 206 with
+// Content-Range, or 416 when the range lies outside the file. It returns false without
+// responding for Range headers it does not support, which are served the full file.
+func (e *Endpoints) serveRange(ctx *fasthttp.RequestCtx, stored *Storage, rangeHeader string) bool {
+	r, ok, err := parseByteRange(rangeHeader, stored.SizeBytes)
+	if !ok {
+		return false
+	}
+	if err != nil {
+		ctx.Error(err.Error(), fasthttp.StatusRequestedRangeNotSatisfiable)
+		ctx.Response.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", stored.SizeBytes))
+		return true
+	}
+
+	reader, err := e.service.GetDataRange(middleware.RequestContext(ctx), stored, r.offset, r.length)
+	if err != nil {
+		log.Error().Err(err).Str("storageId", stored.ID).Msg("[STORAGE] Failed to retrieve file range")
+		ctx.Error("Failed to retrieve file", fasthttp.StatusInternalServerError)
+		return true
+	}
+	defer reader.Close()
+
+	e.mediaHeaders.apply(ctx, stored.ContentType, stored.Filename)
+	ctx.SetStatusCode(fasthttp.StatusPartialContent)
+	ctx.Response.Header.Set("Accept-Ranges", "bytes")
+	ctx.Response.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", r.offset, r.end(), stored.SizeBytes))
+	ctx.Response.Header.Set("Content-Length", strconv.FormatInt(r.length, 10))
+
+	if _, err := io.Copy(ctx, reader); err != nil {
+		log.Error().Err(err).Msg("Failed to stream file range")
+	}
+	return true
+}
+
 func (e *Endpoints) GetThumbnail(ctx *fasthttp.RequestCtx) {
 	stored, _, ok := e.getStorageAndCheckAccess(ctx)
 	if !ok {
@@ -391,7 +530,7 @@ func (e *Endpoints) GetThumbnail(ctx *fasthttp.RequestCtx) {
 	}
 
 	storageID := stored.ID
-	reader, _, err := e.service.GetThumbnail(ctx, storageID)
+	reader, _, err := e.service.GetThumbnail(middleware.RequestContext(ctx), storageID)
 	if err != nil {
 		ctx.Error("Thumbnail not available", fasthttp.StatusNotFound)
 		return
@@ -399,12 +538,48 @@ func (e *Endpoints) GetThumbnail(ctx *fasthttp.RequestCtx) {
 	defer reader.Close()
 
 	ctx.SetContentType("image/jpeg")
+	ctx.Response.Header.Set("X-Content-Type-Options", "nosniff")
 
 	if _, err := io.Copy(ctx, reader); err != nil {
 		log.Error().Err(err).Msg("Failed to stream thumbnail")
 	}
 }
 
+func (e *Endpoints) RegenerateThumbnail(ctx *fasthttp.RequestCtx) {
+	stored, publicKey, ok := e.getStorageAndCheckAccess(ctx)
+	if !ok {
+		return
+	}
+
+	allowed, err := e.isUploaderOrOwner(stored, publicKey)
+	if err != nil {
+		log.Error().Err(err).Str("storageId", stored.ID).Msg("[STORAGE] Failed to verify thumbnail regeneration access")
+		ctx.Error("Failed to verify membership", fasthttp.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		log.Error().Str("storageId", stored.ID).Msg("[STORAGE] Thumbnail regeneration denied")
+		ctx.Error("Not authorized", fasthttp.StatusForbidden)
+		return
+	}
+
+	regenerated, err := e.service.RegenerateThumbnail(ctx, stored.ID)
+	if err != nil {
+		log.Error().Err(err).Str("storageId", stored.ID).Msg("[STORAGE] Failed to regenerate thumbnail")
+		if errors.Is(err, ErrThumbnailNotSupported) {
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+			return
+		}
+		ctx.Error("Failed to regenerate thumbnail", fasthttp.StatusInternalServerError)
+		return
+	}
+
+	response, _ := json.Marshal(regenerated)
+	ctx.SetContentType("application/json")
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBody(response)
+}
+
 func (e *Endpoints) DeleteFile(ctx *fasthttp.RequestCtx) {
 	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
 	if !ok || authenticatedUser == nil {
@@ -427,7 +602,15 @@ func (e *Endpoints) DeleteFile(ctx *fasthttp.RequestCtx) {
 	}
 	appID := stored.ApplicationID
 
-	if err := e.service.Delete(ctx, storageID, publicKey); err != nil {
+	canDeleteAny := false
+	if appID != nil && stored.UploaderPublicKey != publicKey {
+		if member, err := e.appRepo.GetMemberByPublicKey(*appID, publicKey); err == nil {
+			canDeleteAny = canDeleteAnyFile(member.Role)
+		}
+	}
+
+	if err := e.service.Delete(ctx, storageID, publicKey, canDeleteAny); err != nil {
+		log.Error().Err(err).Str("storageId", storageID).Msg("[STORAGE] Failed to delete file")
 		errMsg := err.Error()
 		switch {
 		case strings.Contains(errMsg, "not authorized"):
@@ -460,6 +643,72 @@ func (e *Endpoints) DeleteFile(ctx *fasthttp.RequestCtx) {
 	ctx.SetStatusCode(fasthttp.StatusNoContent)
 }
 
+// BulkDelete handles POST /applications/{appID}/storage/delete
+func (e *Endpoints) BulkDelete(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+	publicKey := authenticatedUser.PublicKey
+
+	appID, ok := ctx.UserValue("appID").(string)
+	if !ok || appID == "" {
+		ctx.Error("Application ID is required", fasthttp.StatusBadRequest)
+		return
+	}
+
+	var req BulkDeleteRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		log.Error().Err(err).Msg("[STORAGE] Failed to parse bulk delete request")
+		ctx.Error("Invalid request body", fasthttp.StatusBadRequest)
+		return
+	}
+
+	member, err := e.appRepo.GetMemberByPublicKey(appID, publicKey)
+	if err != nil {
+		log.Error().Err(err).Str("applicationId", appID).Msg("[STORAGE] Bulk delete by non-member")
+		ctx.Error("Not a member of this application", fasthttp.StatusForbidden)
+		return
+	}
+
+	results, err := e.service.BulkDelete(ctx, appID, publicKey, canDeleteAnyFile(member.Role), req.StorageIDs)
+	if err != nil {
+		log.Error().Err(err).Str("applicationId", appID).Msg("[STORAGE] Bulk delete failed")
+		if errors.Is(err, ErrInvalidBulkDelete) {
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+			return
+		}
+		ctx.Error("Failed to delete files", fasthttp.StatusInternalServerError)
+		return
+	}
+
+	for _, result := range results {
+		if !result.Deleted {
+			continue
+		}
+		evt := &event.Event{
+			ID:               newEventID(),
+			Type:             event.EventTypeApplicationFileDeleted,
+			CreatorPublicKey: publicKey,
+			ApplicationID:    appID,
+			Data: map[string]interface{}{
+				"version":       1,
+				"applicationId": appID,
+				"fileId":        result.ID,
+			},
+		}
+		if _, err := e.eventService.ProduceEvent(ctx, evt); err != nil {
+			log.Error().Err(err).Str("fileId", result.ID).Msg("[STORAGE] Failed to produce application_file_deleted event")
+		}
+	}
+
+	response, _ := json.Marshal(map[string]interface{}{"results": results})
+	ctx.SetContentType("application/json")
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBody(response)
+}
+
 func (e *Endpoints) checkAuthorization(ctx *fasthttp.RequestCtx) (appID, publicKey string, ok bool) {
 	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
 	if !ok || authenticatedUser == nil {
@@ -526,6 +775,28 @@ func (e *Endpoints) getStorageAndCheckAccess(ctx *fasthttp.RequestCtx) (stored *
 	return stored, publicKey, true
 }
 
+// canDeleteAnyFile reports whether a member role may delete files uploaded by other members
+func canDeleteAnyFile(role application.MemberRole) bool {
+	return role == application.MemberRoleOwner || role == application.MemberRoleAdmin
+}
+
+// isUploaderOrOwner reports whether the user uploaded the file or owns the application it belongs to
+func (e *Endpoints) isUploaderOrOwner(stored *Storage, publicKey string) (bool, error) {
+	if stored.UploaderPublicKey == publicKey {
+		return true, nil
+	}
+	if stored.ApplicationID == nil {
+		return false, nil
+	}
+
+	// Membership was already verified by getStorageAndCheckAccess
+	member, err := e.appRepo.GetMemberByPublicKey(*stored.ApplicationID, publicKey)
+	if err != nil {
+		return false, err
+	}
+	return member.Role == application.MemberRoleOwner, nil
+}
+
 // newEventID generates a UUID v7 (time-ordered) for event IDs, falling back to v4 on clock error.
 func newEventID() string {
 	id, err := uuid.NewV7()
@@ -538,7 +809,7 @@ func newEventID() string {
 func detectContentType(filename string) string {
 	dotIndex := strings.LastIndex(filename, ".")
 	if dotIndex == -1 || dotIndex == len(filename)-1 {
-		return "application/octet-stream"
+		return octetStreamContentType
 	}
 	ext := strings.ToLower(filename[dotIndex+1:])
 	switch ext {
@@ -557,6 +828,6 @@ func detectContentType(filename string) string {
 	case "mov":
 		return "video/mov"
 	default:
-		return "application/octet-stream"
+		return octetStreamContentType
 	}
 }
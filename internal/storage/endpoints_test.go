@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prappser/prappser_server/internal/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestUpload_ShouldRejectOversizedContentLengthBeforeParsing(t *testing.T) {
+	// given
+	service := NewService(nil, nil, Config{MaxFileSize: 1024, ExternalURL: "http://localhost"})
+	endpoints := NewEndpoints(service, nil, nil, nil, MediaHeaders{})
+	ctx := &fasthttp.RequestCtx{}
+	ctx.SetUserValue("user", &user.User{PublicKey: "user-key"})
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.Header.SetContentType("multipart/form-data; boundary=upload")
+	ctx.Request.Header.SetContentLength(1024 + maxMultipartOverhead + 1)
+
+	// when
+	endpoints.Upload(ctx)
+
+	// then
+	assert.Equal(t, fasthttp.StatusRequestEntityTooLarge, ctx.Response.StatusCode())
+}
+
+func createRangeTestEndpoints(t *testing.T) (*Endpoints, *Storage) {
+	backend := createTestLocalStorage(t, "http://localhost")
+	stored := &Storage{ID: "video-1", ContentType: "video/mp4", Filename: "clip.mp4", SizeBytes: 10, StoragePath: "apps/video-1.mp4"}
+	if err := backend.Store(context.Background(), stored.StoragePath, strings.NewReader("0123456789")); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+	service := NewService(nil, backend, Config{MaxFileSize: 1024, ExternalURL: "http://localhost"})
+	return NewEndpoints(service, nil, nil, nil, MediaHeaders{}), stored
+}
+
+func TestServeRange_ShouldReturnPartialContent(t *testing.T) {
+	// given
+	endpoints, stored := createRangeTestEndpoints(t)
+	ctx := &fasthttp.RequestCtx{}
+
+	// when
+	handled := endpoints.serveRange(ctx, stored, "bytes=2-5")
+
+	// then
+	assert.True(t, handled)
+	assert.Equal(t, fasthttp.StatusPartialContent, ctx.Response.StatusCode())
+	assert.Equal(t, "bytes 2-5/10", string(ctx.Response.Header.Peek("Content-Range")))
+	assert.Equal(t, "2345", string(ctx.Response.Body()))
+}
+
+func TestServeRange_ShouldRejectUnsatisfiableRange(t *testing.T) {
+	// given
+	endpoints, stored := createRangeTestEndpoints(t)
+	ctx := &fasthttp.RequestCtx{}
+
+	// when
+	handled := endpoints.serveRange(ctx, stored, "bytes=20-30")
+
+	// then
+	assert.True(t, handled)
+	assert.Equal(t, fasthttp.StatusRequestedRangeNotSatisfiable, ctx.Response.StatusCode())
+	assert.Equal(t, "bytes */10", string(ctx.Response.Header.Peek("Content-Range")))
+}
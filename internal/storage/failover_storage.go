@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+type FailoverMode string
+
+const (
+	// FailoverModeFallback writes to the secondary backend only when the primary fails
+	FailoverModeFallback FailoverMode = "fallback"
+	// FailoverModeReplicate writes every file to both backends
+	FailoverModeReplicate FailoverMode = "replicate"
+)
+
+// FailoverStorage combines two backends: writes go to the primary and fall back to, or are
+// replicated on, the secondary, and reads try the primary before the secondary. It does not
+// implement MultipartBackend, so chunked uploads are assembled in memory.
+type FailoverStorage struct {
+	primary   StorageBackend
+	secondary StorageBackend
+	mode      FailoverMode
+
+	// secondaryOnly holds the paths known to be only on the secondary, written there after
+	// the primary failed or found there by GetURL, so their URLs need no probing
+	mu            sync.Mutex
+	secondaryOnly map[string]bool
+}
+
+func NewFailoverStorage(primary, secondary StorageBackend, mode FailoverMode) *FailoverStorage {
+	if mode != FailoverModeReplicate {
+		mode = FailoverModeFallback
+	}
+	return &FailoverStorage{
+		primary:       primary,
+		secondary:     secondary,
+		mode:          mode,
+		secondaryOnly: make(map[string]bool),
+	}
+}
+
+// Store rewinds the reader to write the file a second time. Callers pass in-memory
+// io.ReadSeekers, so only other readers are buffered. In replicate mode a failure of
+// either backend is tolerated as long as one copy was written.
+func (s *FailoverStorage) Store(ctx context.Context, path string, reader io.Reader) error {
+	seeker, ok := reader.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+		seeker = bytes.NewReader(data)
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	primaryErr := s.primary.Store(ctx, path, seeker)
+	if primaryErr == nil {
+		s.setSecondaryOnly(path, false)
+		if s.mode != FailoverModeReplicate {
+			return nil
+		}
+	} else {
+		log.Warn().Err(primaryErr).Str("path", path).Msg("[STORAGE] Primary backend failed to store file, using secondary")
+	}
+
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind file: %w", errors.Join(primaryErr, err))
+	}
+	secondaryErr := s.secondary.Store(ctx, path, seeker)
+	if secondaryErr != nil && primaryErr == nil {
+		log.Warn().Err(secondaryErr).Str("path", path).Msg("[STORAGE] Secondary backend failed to replicate file")
+		return nil
+	}
+	if secondaryErr != nil {
+		return fmt.Errorf("all storage backends failed: %w", errors.Join(primaryErr, secondaryErr))
+	}
+	if primaryErr != nil {
+		s.setSecondaryOnly(path, true)
+	}
+	return nil
+}
+
+func (s *FailoverStorage) setSecondaryOnly(path string, secondaryOnly bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if secondaryOnly {
+		s.secondaryOnly[path] = true
+	} else {
+		delete(s.secondaryOnly, path)
+	}
+}
+
+func (s *FailoverStorage) isSecondaryOnly(path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.secondaryOnly[path]
+}
+
+func (s *FailoverStorage) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	reader, primaryErr := s.primary.Get(ctx, path)
+	if primaryErr == nil {
+		return reader, nil
+	}
+
+	reader, secondaryErr := s.secondary.Get(ctx, path)
+	if secondaryErr != nil {
+		return nil, primaryErr
+	}
+	return reader, nil
+}
+
+func (s *FailoverStorage) GetRange(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	reader, primaryErr := s.primary.GetRange(ctx, path, offset, length)
+	if primaryErr == nil {
+		return reader, nil
+	}
+
+	reader, secondaryErr := s.secondary.GetRange(ctx, path, offset, length)
+	if secondaryErr != nil {
+		return nil, primaryErr
+	}
+	return reader, nil
+}
+
+// Delete removes the file from both backends, since a fallback write may have put it on either
+func (s *FailoverStorage) Delete(ctx context.Context, path string) error {
+	primaryErr := s.primary.Delete(ctx, path)
+	secondaryErr := s.secondary.Delete(ctx, path)
+	// Only the secondary's copy is tracked, and the primary usually fails to delete a file
+	// it never received
+	if secondaryErr == nil {
+		s.setSecondaryOnly(path, false)
+	}
+	return errors.Join(primaryErr, secondaryErr)
+}
+
+func (s *FailoverStorage) Exists(ctx context.Context, path string) (bool, error) {
+	exists, primaryErr := s.primary.Exists(ctx, path)
+	if primaryErr == nil && exists {
+		return true, nil
+	}
+
+	exists, secondaryErr := s.secondary.Exists(ctx, path)
+	if secondaryErr != nil {
+		return false, errors.Join(primaryErr, secondaryErr)
+	}
+	return exists, nil
+}
+
+// GetURL returns the secondary URL for files known to be only on the secondary and the
+// primary URL otherwise. Files that fell back before a restart are unknown, so a file the
+// primary does not hold is looked for on the secondary and remembered there. When the
+// primary cannot build a URL the secondary's is used.
+func (s *FailoverStorage) GetURL(ctx context.Context, path string) (string, error) {
+	if s.isSecondaryOnly(path) {
+		return s.secondary.GetURL(ctx, path)
+	}
+	if exists, err := s.primary.Exists(ctx, path); err == nil && !exists {
+		if exists, err := s.secondary.Exists(ctx, path); err == nil && exists {
+			s.setSecondaryOnly(path, true)
+			return s.secondary.GetURL(ctx, path)
+		}
+	}
+
+	url, primaryErr := s.primary.GetURL(ctx, path)
+	if primaryErr == nil {
+		return url, nil
+	}
+	url, secondaryErr := s.secondary.GetURL(ctx, path)
+	if secondaryErr != nil {
+		return "", primaryErr
+	}
+	return url, nil
+}
@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errBackendDown = errors.New("backend down")
+
+// failingBackend fails every operation, like an unreachable S3 endpoint
+type failingBackend struct{}
+
+func (failingBackend) Store(ctx context.Context, path string, reader io.Reader) error {
+	return errBackendDown
+}
+
+func (failingBackend) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	return nil, errBackendDown
+}
+
+func (failingBackend) GetRange(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	return nil, errBackendDown
+}
+
+func (failingBackend) Delete(ctx context.Context, path string) error {
+	return errBackendDown
+}
+
+func (failingBackend) Exists(ctx context.Context, path string) (bool, error) {
+	return false, errBackendDown
+}
+
+func (failingBackend) GetURL(ctx context.Context, path string) (string, error) {
+	return "https://primary.invalid/" + path, nil
+}
+
+func createTestLocalStorage(t *testing.T, externalURL string) *LocalStorage {
+	backend, err := NewLocalStorage(&BackendConfig{LocalPath: t.TempDir(), ExternalURL: externalURL})
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	return backend
+}
+
+func TestFailoverStorage_ShouldStoreAndReadFromSecondaryWhenPrimaryFails(t *testing.T) {
+	// given
+	ctx := context.Background()
+	secondary := createTestLocalStorage(t, "http://localhost")
+	backend := NewFailoverStorage(failingBackend{}, secondary, FailoverModeFallback)
+
+	// when
+	storeErr := backend.Store(ctx, "files/file-1.txt", strings.NewReader("content"))
+	reader, getErr := backend.Get(ctx, "files/file-1.txt")
+
+	// then
+	assert.NoError(t, storeErr)
+	assert.NoError(t, getErr)
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, "content", string(data))
+	url, _ := backend.GetURL(ctx, "files/file-1.txt")
+	assert.Equal(t, "http://localhost/storage/file-1", url, "file only on the secondary must be served from there")
+}
+
+func TestFailoverStorage_ShouldFailWhenEveryBackendFails(t *testing.T) {
+	// given
+	backend := NewFailoverStorage(failingBackend{}, failingBackend{}, FailoverModeFallback)
+
+	// when
+	err := backend.Store(context.Background(), "files/file-1.txt", strings.NewReader("content"))
+
+	// then
+	assert.ErrorIs(t, err, errBackendDown)
+}
+
+func TestFailoverStorage_ShouldReplicateToBothBackends(t *testing.T) {
+	// given
+	ctx := context.Background()
+	primary := createTestLocalStorage(t, "http://primary")
+	secondary := createTestLocalStorage(t, "http://secondary")
+	backend := NewFailoverStorage(primary, secondary, FailoverModeReplicate)
+
+	// when
+	err := backend.Store(ctx, "files/file-1.txt", strings.NewReader("content"))
+
+	// then
+	assert.NoError(t, err)
+	for _, replica := range []*LocalStorage{primary, secondary} {
+		exists, _ := replica.Exists(ctx, "files/file-1.txt")
+		assert.True(t, exists)
+	}
+}
+
+func TestFailoverStorage_ShouldOnlyWritePrimaryInFallbackMode(t *testing.T) {
+	// given
+	ctx := context.Background()
+	primary := createTestLocalStorage(t, "http://primary")
+	secondary := createTestLocalStorage(t, "http://secondary")
+	backend := NewFailoverStorage(primary, secondary, FailoverModeFallback)
+
+	// when
+	err := backend.Store(ctx, "files/file-1.txt", strings.NewReader("content"))
+
+	// then
+	assert.NoError(t, err)
+	exists, _ := secondary.Exists(ctx, "files/file-1.txt")
+	assert.False(t, exists)
+}
+
+// halfReadingBackend consumes part of the file before failing, like a dropped connection
+type halfReadingBackend struct {
+	failingBackend
+}
+
+func (halfReadingBackend) Store(ctx context.Context, path string, reader io.Reader) error {
+	io.CopyN(io.Discard, reader, 3)
+	return errBackendDown
+}
+
+// existsCountingBackend counts Exists probes on top of a local backend
+type existsCountingBackend struct {
+	*LocalStorage
+	existsCalls int
+}
+
+func (b *existsCountingBackend) Exists(ctx context.Context, path string) (bool, error) {
+	b.existsCalls++
+	return b.LocalStorage.Exists(ctx, path)
+}
+
+func TestFailoverStorage_ShouldWriteWholeFileToSecondaryAfterPartialPrimaryWrite(t *testing.T) {
+	// given
+	ctx := context.Background()
+	secondary := createTestLocalStorage(t, "http://secondary")
+	backend := NewFailoverStorage(halfReadingBackend{}, secondary, FailoverModeFallback)
+
+	// when
+	err := backend.Store(ctx, "files/file-1.txt", strings.NewReader("content"))
+
+	// then
+	assert.NoError(t, err)
+	reader, _ := secondary.Get(ctx, "files/file-1.txt")
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, "content", string(data))
+}
+
+func TestFailoverStorage_ShouldNotProbeSecondaryForFileOnPrimary(t *testing.T) {
+	// given
+	ctx := context.Background()
+	primary := &existsCountingBackend{LocalStorage: createTestLocalStorage(t, "http://primary")}
+	secondary := &existsCountingBackend{LocalStorage: createTestLocalStorage(t, "http://secondary")}
+	backend := NewFailoverStorage(primary, secondary, FailoverModeFallback)
+	backend.Store(ctx, "files/file-1.txt", strings.NewReader("content"))
+
+	// when
+	url, err := backend.GetURL(ctx, "files/file-1.txt")
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "http://primary/storage/file-1", url)
+	assert.Equal(t, 1, primary.existsCalls)
+	assert.Zero(t, secondary.existsCalls)
+}
+
+func TestFailoverStorage_ShouldFindFileOnSecondaryAfterRestart(t *testing.T) {
+	// given - a file that fell back to the secondary before the process restarted
+	ctx := context.Background()
+	primary := createTestLocalStorage(t, "http://primary")
+	secondary := &existsCountingBackend{LocalStorage: createTestLocalStorage(t, "http://secondary")}
+	secondary.Store(ctx, "files/file-1.txt", strings.NewReader("content"))
+	backend := NewFailoverStorage(primary, secondary, FailoverModeFallback)
+
+	// when
+	first, _ := backend.GetURL(ctx, "files/file-1.txt")
+	second, _ := backend.GetURL(ctx, "files/file-1.txt")
+
+	// then
+	assert.Equal(t, "http://secondary/storage/file-1", first)
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, secondary.existsCalls, "a file found on the secondary is remembered")
+}
+
+func TestFailoverStorage_ShouldForgetFallbackOnceSecondaryCopyIsDeleted(t *testing.T) {
+	// given
+	ctx := context.Background()
+	secondary := createTestLocalStorage(t, "http://secondary")
+	backend := NewFailoverStorage(failingBackend{}, secondary, FailoverModeFallback)
+	backend.Store(ctx, "files/file-1.txt", strings.NewReader("content"))
+
+	// when
+	err := backend.Delete(ctx, "files/file-1.txt")
+
+	// then
+	assert.ErrorIs(t, err, errBackendDown)
+	assert.False(t, backend.isSecondaryOnly("files/file-1.txt"))
+}
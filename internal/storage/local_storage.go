@@ -53,6 +53,22 @@ func (s *LocalStorage) Store(ctx context.Context, path string, reader io.Reader)
 }
 
 func (s *LocalStorage) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	return s.open(path)
+}
+
+func (s *LocalStorage) GetRange(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	file, err := s.open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(file, offset, length), file}, nil
+}
+
+func (s *LocalStorage) open(path string) (*os.File, error) {
 	fullPath := filepath.Join(s.basePath, path)
 
 	file, err := os.Open(fullPath)
@@ -90,8 +106,12 @@ func (s *LocalStorage) Exists(ctx context.Context, path string) (bool, error) {
 	return true, nil
 }
 
+// GetURL returns the server route that serves the file, or its thumbnail for thumbnail paths
 func (s *LocalStorage) GetURL(ctx context.Context, path string) (string, error) {
 	base := filepath.Base(path)
+	if storageID, ok := strings.CutSuffix(base, thumbnailSuffix); ok {
+		return fmt.Sprintf("%s/storage/%s/thumb", s.externalURL, storageID), nil
+	}
 	ext := filepath.Ext(base)
 	storageID := strings.TrimSuffix(base, ext)
 	return fmt.Sprintf("%s/storage/%s", s.externalURL, storageID), nil
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestMediaHeaderPolicy_ShouldKeepImagesInline(t *testing.T) {
+	// given
+	policy := newMediaHeaderPolicy(MediaHeaders{})
+	ctx := &fasthttp.RequestCtx{}
+
+	// when
+	policy.apply(ctx, "image/png", "photo.png")
+
+	// then
+	assert.Equal(t, "image/png", string(ctx.Response.Header.ContentType()))
+	assert.Equal(t, `inline; filename="photo.png"`, string(ctx.Response.Header.Peek("Content-Disposition")))
+	assert.Equal(t, "nosniff", string(ctx.Response.Header.Peek("X-Content-Type-Options")))
+	assert.Equal(t, DefaultContentSecurityPolicy, string(ctx.Response.Header.Peek("Content-Security-Policy")))
+}
+
+func TestMediaHeaderPolicy_ShouldServeSVGAsSandboxedAttachment(t *testing.T) {
+	// given
+	policy := newMediaHeaderPolicy(MediaHeaders{})
+	ctx := &fasthttp.RequestCtx{}
+
+	// when
+	policy.apply(ctx, "image/svg+xml", `evil".svg`)
+
+	// then
+	assert.Equal(t, `attachment; filename="evil_.svg"`, string(ctx.Response.Header.Peek("Content-Disposition")))
+	assert.Equal(t, "nosniff", string(ctx.Response.Header.Peek("X-Content-Type-Options")))
+	assert.Contains(t, string(ctx.Response.Header.Peek("Content-Security-Policy")), "sandbox")
+	assert.Contains(t, string(ctx.Response.Header.Peek("Content-Security-Policy")), "default-src 'none'")
+}
+
+func TestMediaHeaderPolicy_ShouldFollowConfiguredInlineTypes(t *testing.T) {
+	// given
+	policy := newMediaHeaderPolicy(MediaHeaders{InlineContentTypes: []string{"application/pdf"}, ContentSecurityPolicy: "sandbox"})
+	ctx := &fasthttp.RequestCtx{}
+
+	// when
+	inlinePDF := policy.isInline("application/pdf; charset=binary")
+	policy.apply(ctx, "text/html", "page.html")
+
+	// then
+	assert.True(t, inlinePDF)
+	assert.False(t, policy.isInline("image/png"))
+	assert.Equal(t, `attachment; filename="page.html"`, string(ctx.Response.Header.Peek("Content-Disposition")))
+	assert.Equal(t, "sandbox", string(ctx.Response.Header.Peek("Content-Security-Policy")))
+}
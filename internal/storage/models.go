@@ -60,6 +60,25 @@ type ChunkedUploadInitResponse struct {
 	StoragePath string `json:"-"`
 }
 
+type ChunkedUploadProgress struct {
+	StorageID      string `json:"storageId"`
+	ReceivedChunks []int  `json:"receivedChunks"`
+	MissingChunks  []int  `json:"missingChunks"`
+	TotalChunks    int    `json:"totalChunks"`
+	ChunkSize      int64  `json:"chunkSize"`
+	TotalSize      int64  `json:"totalSize"`
+}
+
+type BulkDeleteRequest struct {
+	StorageIDs []string `json:"storageIds"`
+}
+
+type BulkDeleteResult struct {
+	ID      string `json:"id"`
+	Deleted bool   `json:"deleted"`
+	Error   string `json:"error,omitempty"`
+}
+
 type StorageResponse struct {
 	ID           string `json:"id"`
 	URL          string `json:"url"`
@@ -68,3 +87,9 @@ type StorageResponse struct {
 	SizeBytes    int64  `json:"sizeBytes"`
 	Checksum     string `json:"checksum"`
 }
+
+// OrphanCleanupResult reports what a run of the orphaned storage cleanup reclaimed
+type OrphanCleanupResult struct {
+	DeletedFiles   int
+	ReclaimedBytes int64
+}
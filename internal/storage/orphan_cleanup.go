@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// OrphanCleanupScheduler periodically removes the files of deleted applications, which
+// deleting an application leaves behind
+type OrphanCleanupScheduler struct {
+	service     *Service
+	gracePeriod time.Duration
+	done        chan struct{}
+	stopped     chan struct{}
+	stopOnce    sync.Once
+	started     atomic.Bool
+}
+
+// NewOrphanCleanupScheduler creates a new orphaned storage cleanup scheduler
+func NewOrphanCleanupScheduler(service *Service, gracePeriod time.Duration) *OrphanCleanupScheduler {
+	return &OrphanCleanupScheduler{
+		service:     service,
+		gracePeriod: gracePeriod,
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+}
+
+// Start runs the cleanup once and then every 24 hours
+func (cs *OrphanCleanupScheduler) Start() {
+	log.Info().
+		Dur("gracePeriod", cs.gracePeriod).
+		Msg("[STORAGE] Orphaned storage cleanup scheduler started")
+
+	cs.started.Store(true)
+	go cs.loop()
+}
+
+// loop runs the cleanup task on a schedule until stopped
+func (cs *OrphanCleanupScheduler) loop() {
+	defer close(cs.stopped)
+
+	cs.runCleanup()
+
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cs.runCleanup()
+		case <-cs.done:
+			return
+		}
+	}
+}
+
+// runCleanup executes the cleanup task
+func (cs *OrphanCleanupScheduler) runCleanup() {
+	result, err := cs.service.CleanupOrphanedStorage(context.Background(), cs.gracePeriod)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[STORAGE] Failed to cleanup orphaned storage")
+		return
+	}
+
+	log.Info().
+		Int("deletedFiles", result.DeletedFiles).
+		Int64("reclaimedBytes", result.ReclaimedBytes).
+		Msg("[STORAGE] Orphaned storage cleanup completed")
+}
+
+// Stop stops the cleanup scheduler and waits for its goroutine to exit. It is safe to
+// call more than once and before Start.
+func (cs *OrphanCleanupScheduler) Stop() {
+	log.Info().Msg("[STORAGE] Stopping orphaned storage cleanup scheduler")
+	cs.stopOnce.Do(func() { close(cs.done) })
+	if cs.started.Load() {
+		<-cs.stopped
+	}
+}
+
+// RunNow executes cleanup immediately
+func (cs *OrphanCleanupScheduler) RunNow() {
+	cs.runCleanup()
+}
@@ -3,6 +3,8 @@ package storage
 import (
 	"database/sql"
 	"fmt"
+
+	"github.com/lib/pq"
 )
 
 type Repository struct {
@@ -98,7 +100,24 @@ func (r *Repository) GetByApplicationID(appID string) ([]*Storage, error) {
 	query := `SELECT id, application_id, uploader_public_key, filename, content_type, size_bytes, storage_path, thumbnail_path, width, height, duration_ms, checksum, created_at, status
 			  FROM storage WHERE application_id = $1 ORDER BY created_at DESC`
 
-	rows, err := r.db.Query(query, appID)
+	return r.queryStorageList(query, appID)
+}
+
+// GetOrphaned returns application files created before cutoff whose application no
+// longer exists or was deleted before cutoff. Files of an application that is still being
+// registered, or was deleted recently enough to be registered again, are left alone.
+func (r *Repository) GetOrphaned(cutoff int64) ([]*Storage, error) {
+	query := `SELECT s.id, s.application_id, s.uploader_public_key, s.filename, s.content_type, s.size_bytes, s.storage_path, s.thumbnail_path, s.width, s.height, s.duration_ms, s.checksum, s.created_at, s.status
+			  FROM storage s LEFT JOIN applications a ON a.id = s.application_id
+			  WHERE s.application_id IS NOT NULL AND s.created_at < $1
+			    AND (a.id IS NULL OR a.deleted_at < $1)
+			  ORDER BY s.created_at`
+
+	return r.queryStorageList(query, cutoff)
+}
+
+func (r *Repository) queryStorageList(query string, args ...interface{}) ([]*Storage, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -157,6 +176,30 @@ func (r *Repository) Delete(id string) error {
 	return r.execWithRowCheck(`DELETE FROM storage WHERE id = $1`, id)
 }
 
+// DeleteMany removes all given storage records in one transaction, failing if any is missing
+func (r *Repository) DeleteMany(ids []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM storage WHERE id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected != int64(len(ids)) {
+		return fmt.Errorf("storage not found: deleted %d of %d records", rowsAffected, len(ids))
+	}
+
+	return tx.Commit()
+}
+
 func (r *Repository) execWithRowCheck(query string, args ...interface{}) error {
 	result, err := r.db.Exec(query, args...)
 	if err != nil {
@@ -224,3 +267,25 @@ func (r *Repository) GetTotalUsedBytes() (int64, error) {
 	}
 	return total.Int64, nil
 }
+
+// GetUsedBytesByApplication sums the size of an application's files, counting pending
+// chunked uploads at their declared size
+func (r *Repository) GetUsedBytesByApplication(appID string) (int64, error) {
+	var total sql.NullInt64
+	err := r.db.QueryRow(`SELECT COALESCE(SUM(size_bytes), 0) FROM storage WHERE application_id = $1`, appID).Scan(&total)
+	if err != nil {
+		return 0, err
+	}
+	return total.Int64, nil
+}
+
+// CountPendingByUploader counts pending (chunked, not yet completed) uploads by a user created at or after since.
+func (r *Repository) CountPendingByUploader(uploaderPublicKey string, since int64) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM storage WHERE uploader_public_key = $1 AND status = $2 AND created_at >= $3`
+	err := r.db.QueryRow(query, uploaderPublicKey, string(StorageStatusPending), since).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
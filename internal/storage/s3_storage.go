@@ -8,12 +8,21 @@ import (
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultPresignedURLExpiry is how long presigned GET URLs stay valid when no expiry is configured
+	defaultPresignedURLExpiry = time.Hour
+	// maxPresignedURLExpiry is the longest validity S3 accepts for a presigned URL
+	maxPresignedURLExpiry = 7 * 24 * time.Hour
 )
 
 type S3Storage struct {
 	client      *minio.Client
 	bucket      string
 	externalURL string
+	urlExpiry   time.Duration
 }
 
 func NewS3Storage(config *BackendConfig) (*S3Storage, error) {
@@ -44,16 +53,90 @@ func NewS3Storage(config *BackendConfig) (*S3Storage, error) {
 		client:      client,
 		bucket:      config.S3Bucket,
 		externalURL: config.ExternalURL,
+		urlExpiry:   presignedURLExpiry(config.S3URLExpiry),
 	}, nil
 }
 
+// presignedURLExpiry falls back to the default for unset expiries and caps the rest at the S3 maximum
+func presignedURLExpiry(configured time.Duration) time.Duration {
+	if configured <= 0 {
+		return defaultPresignedURLExpiry
+	}
+	return min(configured, maxPresignedURLExpiry)
+}
+
 func (s *S3Storage) Store(ctx context.Context, path string, reader io.Reader) error {
 	_, err := s.client.PutObject(ctx, s.bucket, path, reader, -1, minio.PutObjectOptions{})
 	return err
 }
 
+// StoreMultipart streams each part to S3 as a multipart upload part, holding at most one
+// part in flight, and completes the object only after verify succeeds
+func (s *S3Storage) StoreMultipart(ctx context.Context, path string, partCount int, openPart PartOpener, verify func() error) error {
+	core := minio.Core{Client: s.client}
+
+	uploadID, err := core.NewMultipartUpload(ctx, s.bucket, path, minio.PutObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+
+	parts := make([]minio.CompletePart, 0, partCount)
+	for i := 0; i < partCount; i++ {
+		part, err := s.uploadPart(ctx, core, path, uploadID, i, openPart)
+		if err != nil {
+			s.abortMultipart(ctx, core, path, uploadID)
+			return err
+		}
+		parts = append(parts, part)
+	}
+
+	if err := verify(); err != nil {
+		s.abortMultipart(ctx, core, path, uploadID)
+		return err
+	}
+
+	if _, err := core.CompleteMultipartUpload(ctx, s.bucket, path, uploadID, parts, minio.PutObjectOptions{}); err != nil {
+		s.abortMultipart(ctx, core, path, uploadID)
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Storage) uploadPart(ctx context.Context, core minio.Core, path, uploadID string, index int, openPart PartOpener) (minio.CompletePart, error) {
+	reader, size, err := openPart(index)
+	if err != nil {
+		return minio.CompletePart{}, fmt.Errorf("failed to read part %d: %w", index, err)
+	}
+	defer reader.Close()
+
+	// S3 part numbers start at 1
+	uploaded, err := core.PutObjectPart(ctx, s.bucket, path, uploadID, index+1, reader, size, minio.PutObjectPartOptions{})
+	if err != nil {
+		return minio.CompletePart{}, fmt.Errorf("failed to upload part %d: %w", index, err)
+	}
+	return minio.CompletePart{PartNumber: uploaded.PartNumber, ETag: uploaded.ETag}, nil
+}
+
+func (s *S3Storage) abortMultipart(ctx context.Context, core minio.Core, path, uploadID string) {
+	if err := core.AbortMultipartUpload(ctx, s.bucket, path, uploadID); err != nil {
+		log.Warn().Err(err).Str("path", path).Msg("[STORAGE] Failed to abort multipart upload")
+	}
+}
+
 func (s *S3Storage) Get(ctx context.Context, path string) (io.ReadCloser, error) {
-	obj, err := s.client.GetObject(ctx, s.bucket, path, minio.GetObjectOptions{})
+	return s.getObject(ctx, path, minio.GetObjectOptions{})
+}
+
+func (s *S3Storage) GetRange(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetRange(offset, offset+length-1); err != nil {
+		return nil, fmt.Errorf("invalid range: %w", err)
+	}
+	return s.getObject(ctx, path, opts)
+}
+
+func (s *S3Storage) getObject(ctx context.Context, path string, opts minio.GetObjectOptions) (io.ReadCloser, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, path, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -86,8 +169,9 @@ func (s *S3Storage) Exists(ctx context.Context, path string) (bool, error) {
 	return true, nil
 }
 
+// GetURL returns a presigned GET URL, since objects in the bucket are not publicly readable
 func (s *S3Storage) GetURL(ctx context.Context, path string) (string, error) {
-	presignedURL, err := s.client.PresignedGetObject(ctx, s.bucket, path, time.Hour, nil)
+	presignedURL, err := s.client.PresignedGetObject(ctx, s.bucket, path, s.urlExpiry, nil)
 	if err != nil {
 		return "", err
 	}
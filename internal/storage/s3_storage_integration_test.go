@@ -0,0 +1,96 @@
+//go:build integration
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func getTestS3Storage(t *testing.T) *S3Storage {
+	endpoint := os.Getenv("TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "localhost:9000"
+	}
+
+	backend, err := NewS3Storage(&BackendConfig{
+		S3Endpoint:  endpoint,
+		S3Bucket:    "prappser-test",
+		S3AccessKey: "minioadmin",
+		S3SecretKey: "minioadmin",
+		S3Region:    "us-east-1",
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect to S3: %v", err)
+	}
+	return backend
+}
+
+func partOpener(parts [][]byte) PartOpener {
+	return func(index int) (io.ReadCloser, int64, error) {
+		return io.NopCloser(bytes.NewReader(parts[index])), int64(len(parts[index])), nil
+	}
+}
+
+func TestS3Storage_StoreMultipart_ShouldAssembleParts_Integration(t *testing.T) {
+	backend := getTestS3Storage(t)
+	ctx := context.Background()
+	path := "multipart/assembled.bin"
+	defer backend.Delete(ctx, path)
+
+	// given
+	parts := [][]byte{
+		bytes.Repeat([]byte("a"), MinMultipartPartSize),
+		bytes.Repeat([]byte("b"), MinMultipartPartSize),
+		[]byte("tail"),
+	}
+
+	// when
+	err := backend.StoreMultipart(ctx, path, len(parts), partOpener(parts), func() error { return nil })
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to store multipart object: %v", err)
+	}
+	reader, err := backend.Get(ctx, path)
+	if err != nil {
+		t.Fatalf("Failed to read object: %v", err)
+	}
+	defer reader.Close()
+	stored, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read object: %v", err)
+	}
+	if !bytes.Equal(stored, bytes.Join(parts, nil)) {
+		t.Errorf("Expected assembled object of %d bytes, got %d bytes", 2*MinMultipartPartSize+4, len(stored))
+	}
+}
+
+func TestS3Storage_StoreMultipart_ShouldAbortWhenVerificationFails_Integration(t *testing.T) {
+	backend := getTestS3Storage(t)
+	ctx := context.Background()
+	path := "multipart/rejected.bin"
+
+	// given
+	parts := [][]byte{bytes.Repeat([]byte("a"), MinMultipartPartSize), []byte("tail")}
+	verifyErr := errors.New("checksum mismatch")
+
+	// when
+	err := backend.StoreMultipart(ctx, path, len(parts), partOpener(parts), func() error { return verifyErr })
+
+	// then
+	if !errors.Is(err, verifyErr) {
+		t.Fatalf("Expected verification error, got %v", err)
+	}
+	exists, err := backend.Exists(ctx, path)
+	if err != nil {
+		t.Fatalf("Failed to check object: %v", err)
+	}
+	if exists {
+		t.Errorf("Expected aborted upload to leave no object")
+	}
+}
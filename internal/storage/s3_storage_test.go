@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestS3StorageGetURL_ShouldPresignWithConfiguredExpiry(t *testing.T) {
+	// given - presigning is computed locally, so no S3 server is needed
+	client, err := minio.New("s3.example.com", &minio.Options{
+		Creds:  credentials.NewStaticV4("access-key", "secret-key", ""),
+		Secure: true,
+		Region: "us-east-1",
+	})
+	assert.NoError(t, err)
+	backend := &S3Storage{client: client, bucket: "files", urlExpiry: presignedURLExpiry(15 * time.Minute)}
+
+	// when
+	fileURL, fileErr := backend.GetURL(context.Background(), "apps/app-1/file-1.jpg")
+	thumbURL, thumbErr := backend.GetURL(context.Background(), "apps/app-1/file-1"+thumbnailSuffix)
+
+	// then
+	assert.NoError(t, fileErr)
+	assert.NoError(t, thumbErr)
+	parsedFile, _ := url.Parse(fileURL)
+	parsedThumb, _ := url.Parse(thumbURL)
+	assert.Equal(t, "/files/apps/app-1/file-1.jpg", parsedFile.Path)
+	assert.Equal(t, "/files/apps/app-1/file-1_thumb.jpg", parsedThumb.Path)
+	assert.Equal(t, "900", parsedFile.Query().Get("X-Amz-Expires"))
+	assert.NotEmpty(t, parsedThumb.Query().Get("X-Amz-Signature"))
+}
+
+func TestPresignedURLExpiry_ShouldDefaultAndCapExpiry(t *testing.T) {
+	// when
+	unset := presignedURLExpiry(0)
+	tooLong := presignedURLExpiry(30 * 24 * time.Hour)
+
+	// then
+	assert.Equal(t, defaultPresignedURLExpiry, unset)
+	assert.Equal(t, maxPresignedURLExpiry, tooLong)
+}
+
+func TestLocalStorageGetURL_ShouldKeepServerRoutesForFilesAndThumbnails(t *testing.T) {
+	// given
+	backend := createTestLocalStorage(t, "http://localhost")
+
+	// when
+	fileURL, _ := backend.GetURL(context.Background(), "apps/app-1/file-1.jpg")
+	thumbURL, _ := backend.GetURL(context.Background(), "apps/app-1/file-1"+thumbnailSuffix)
+
+	// then
+	assert.Equal(t, "http://localhost/storage/file-1", fileURL)
+	assert.Equal(t, "http://localhost/storage/file-1/thumb", thumbURL)
+}
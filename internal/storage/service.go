@@ -13,19 +13,60 @@ import (
 	_ "image/png"
 	"io"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/disintegration/imaging"
 	"github.com/lib/pq"
+	"github.com/prappser/prappser_server/internal/clock"
 	"github.com/rs/zerolog/log"
+	_ "golang.org/x/image/webp"
 )
 
 const (
 	maxThumbnailWidth  = 300
 	maxThumbnailHeight = 300
 
+	// defaultMaxAvatarDimension bounds the width and height of stored avatars when no
+	// limit is configured
+	defaultMaxAvatarDimension = 256
+	avatarJPEGQuality         = 85
+
+	// maxAvatarSourceFactor bounds an uploaded avatar's width and height, as a multiple of
+	// the stored dimension, before it is decoded, so a small file declaring huge dimensions
+	// cannot make the decoder allocate gigabytes
+	maxAvatarSourceFactor = 16
+	// maxImagePixels bounds the pixel count of uploaded images decoded for thumbnails
+	maxImagePixels = 64 * 1024 * 1024
+
 	pqUniqueViolation = "23505"
+
+	// maxMultipartOverhead is the room allowed in an upload's Content-Length for the
+	// multipart boundaries, part headers and form fields around the file
+	maxMultipartOverhead = 64 * 1024
+
+	// abandonedUploadAge is how long a pending chunked upload counts against the per-user
+	// limit; older pending uploads are treated as abandoned.
+	abandonedUploadAge = 24 * time.Hour
+
+	// thumbnailSuffix replaces the extension of a file's storage path to name its thumbnail
+	thumbnailSuffix = "_thumb.jpg"
+
+	// maxBulkDeleteIDs caps how many files a single bulk delete request may target
+	maxBulkDeleteIDs = 100
+)
+
+var (
+	ErrTooManyPendingUploads = errors.New("too many pending chunked uploads")
+	ErrThumbnailNotSupported = errors.New("thumbnails can only be generated for ready images")
+	ErrInvalidBulkDelete     = errors.New("invalid bulk delete request")
+	ErrInvalidAvatar         = errors.New("avatar is not a supported image")
+	ErrUnsupportedFileType   = errors.New("unsupported file type")
+	ErrMissingChunks         = errors.New("missing chunks")
+	ErrInvalidChunk          = errors.New("invalid chunk")
+	ErrQuotaExceeded         = errors.New("application storage quota exceeded")
+	ErrContentTypeMismatch   = errors.New("file content does not match its content type")
 )
 
 var allowedContentTypes = map[string]bool{
@@ -38,22 +79,64 @@ var allowedContentTypes = map[string]bool{
 	"video/mov":  true,
 }
 
+// octetStreamContentType is what uploads of unknown file types end up as. It is only
+// accepted when the service is configured to allow it.
+const octetStreamContentType = "application/octet-stream"
+
+// Config holds the upload limits and settings of the storage service
+type Config struct {
+	// MaxFileSize bounds a single file; zero selects 500 MiB
+	MaxFileSize int64
+	// ChunkSize is the size of the parts chunked uploads are sent in
+	ChunkSize int64
+	// AppQuota bounds the bytes stored per application; zero means unlimited
+	AppQuota int64
+	// MaxPendingUploads bounds the unfinished chunked uploads per user; zero means unlimited
+	MaxPendingUploads int
+	// MaxAvatarDimension bounds the width and height of stored avatars; zero selects 256
+	MaxAvatarDimension int
+	// AllowOctetStream accepts uploads whose content type cannot be determined
+	AllowOctetStream bool
+	// ExternalURL is the public base URL download links are built on
+	ExternalURL string
+	// Clock tells the current time; nil selects clock.System
+	Clock clock.Clock
+}
+
 type Service struct {
-	repo        *Repository
-	backend     StorageBackend
-	maxFileSize int64
-	externalURL string
+	repo               *Repository
+	backend            StorageBackend
+	maxFileSize        int64
+	chunkSize          int64
+	appQuota           int64
+	maxPendingUploads  int
+	maxAvatarDimension int
+	allowOctetStream   bool
+	externalURL        string
+	clock              clock.Clock
 }
 
-func NewService(repo *Repository, backend StorageBackend, maxFileSize int64, externalURL string) *Service {
-	if maxFileSize <= 0 {
-		maxFileSize = 500 * 1024 * 1024
+func NewService(repo *Repository, backend StorageBackend, config Config) *Service {
+	if config.MaxFileSize <= 0 {
+		config.MaxFileSize = 500 * 1024 * 1024
+	}
+	if config.MaxAvatarDimension <= 0 {
+		config.MaxAvatarDimension = defaultMaxAvatarDimension
+	}
+	if config.Clock == nil {
+		config.Clock = clock.System
 	}
 	return &Service{
-		repo:        repo,
-		backend:     backend,
-		maxFileSize: maxFileSize,
-		externalURL: externalURL,
+		repo:               repo,
+		backend:            backend,
+		maxFileSize:        config.MaxFileSize,
+		chunkSize:          config.ChunkSize,
+		appQuota:           config.AppQuota,
+		maxPendingUploads:  config.MaxPendingUploads,
+		maxAvatarDimension: config.MaxAvatarDimension,
+		allowOctetStream:   config.AllowOctetStream,
+		externalURL:        config.ExternalURL,
+		clock:              config.Clock,
 	}
 }
 
@@ -61,9 +144,57 @@ func (s *Service) ExternalURL() string {
 	return s.externalURL
 }
 
+// UploadTooLarge reports whether a multipart upload declaring contentLength bytes cannot
+// hold a file within the maximum file size, so it can be refused before parsing the form
+func (s *Service) UploadTooLarge(contentLength int) bool {
+	return int64(contentLength) > s.maxFileSize+maxMultipartOverhead
+}
+
+// MaxRequestBodySize is the largest request body an upload needs: a whole file or a chunk,
+// whichever is larger, plus its multipart framing. The HTTP server refuses larger bodies
+// before reading them into memory.
+func (s *Service) MaxRequestBodySize() int {
+	return int(max(s.maxFileSize, s.chunkSize) + maxMultipartOverhead)
+}
+
+// CheckUploadQuota rejects an application upload declaring contentLength bytes when even
+// the file inside it, at its smallest, cannot fit in the application's remaining quota
+func (s *Service) CheckUploadQuota(appID *string, contentLength int) error {
+	return s.checkAppQuota(appID, int64(contentLength)-maxMultipartOverhead)
+}
+
+// checkContentType rejects content types that may not be stored. Files of unknown type
+// are named by their extension, and the error lists the accepted content types.
+func (s *Service) checkContentType(filename, contentType string) error {
+	if allowedContentTypes[contentType] || (s.allowOctetStream && contentType == octetStreamContentType) {
+		return nil
+	}
+
+	fileType := contentType
+	if ext := strings.ToLower(filepath.Ext(filename)); ext != "" && (contentType == "" || contentType == octetStreamContentType) {
+		fileType = ext
+	}
+	if fileType == "" {
+		fileType = "unknown"
+	}
+	return fmt.Errorf("%w: %s (allowed: %s)", ErrUnsupportedFileType, fileType, strings.Join(s.allowedContentTypeList(), ", "))
+}
+
+func (s *Service) allowedContentTypeList() []string {
+	types := make([]string, 0, len(allowedContentTypes)+1)
+	for contentType := range allowedContentTypes {
+		types = append(types, contentType)
+	}
+	if s.allowOctetStream {
+		types = append(types, octetStreamContentType)
+	}
+	sort.Strings(types)
+	return types
+}
+
 func (s *Service) Upload(ctx context.Context, appID *string, uploaderPublicKey string, req *UploadRequest, data io.Reader) (*Storage, error) {
-	if !allowedContentTypes[req.ContentType] {
-		return nil, fmt.Errorf("unsupported content type: %s", req.ContentType)
+	if err := s.checkContentType(req.Filename, req.ContentType); err != nil {
+		return nil, err
 	}
 
 	if req.SizeBytes > s.maxFileSize {
@@ -82,12 +213,20 @@ func (s *Service) Upload(ctx context.Context, appID *string, uploaderPublicKey s
 		return nil, fmt.Errorf("file too large: exceeds %d bytes", s.maxFileSize)
 	}
 
+	if err := checkSniffedContentType(req.ContentType, buf.Bytes()); err != nil {
+		return nil, err
+	}
+
 	checksum := hex.EncodeToString(hasher.Sum(nil))
 	if req.Checksum != "" && checksum != req.Checksum {
 		return nil, fmt.Errorf("checksum mismatch: expected %s, got %s", req.Checksum, checksum)
 	}
 
-	now := time.Now()
+	if err := s.checkAppQuota(appID, n); err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
 	storagePath := buildStoragePath(appID, req.ID, req.Filename, req.ContentType, now)
 
 	if err := s.backend.Store(ctx, storagePath, bytes.NewReader(buf.Bytes())); err != nil {
@@ -136,6 +275,71 @@ func (s *Service) Upload(ctx context.Context, appID *string, uploaderPublicKey s
 	return stored, nil
 }
 
+// UploadAvatar stores a user avatar re-encoded as a JPEG that fits within the configured
+// avatar dimension, so avatars stay small no matter what the client uploads. Data that
+// does not decode as an image is rejected with ErrInvalidAvatar.
+func (s *Service) UploadAvatar(ctx context.Context, uploaderPublicKey string, req *UploadRequest, data io.Reader) (*Storage, error) {
+	if req.SizeBytes > s.maxFileSize {
+		return nil, fmt.Errorf("file too large: %d bytes (max: %d)", req.SizeBytes, s.maxFileSize)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(data, s.maxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data: %w", err)
+	}
+	if int64(len(raw)) > s.maxFileSize {
+		return nil, fmt.Errorf("file too large: exceeds %d bytes", s.maxFileSize)
+	}
+
+	// The client checksums what it sent, not the re-encoded avatar
+	sum := sha256.Sum256(raw)
+	if err := verifyChecksum(req.Checksum, sum[:]); err != nil {
+		return nil, err
+	}
+
+	optimized, err := resizeAvatar(raw, s.maxAvatarDimension)
+	if err != nil {
+		return nil, err
+	}
+
+	avatarReq := *req
+	avatarReq.Filename = strings.TrimSuffix(req.Filename, filepath.Ext(req.Filename)) + ".jpg"
+	avatarReq.ContentType = "image/jpeg"
+	avatarReq.SizeBytes = int64(len(optimized))
+	avatarReq.Checksum = ""
+	return s.Upload(ctx, nil, uploaderPublicKey, &avatarReq, bytes.NewReader(optimized))
+}
+
+// resizeAvatar decodes an avatar, shrinks it to fit within maxDimension on both sides and
+// re-encodes it as JPEG. Smaller images keep their size but are still re-encoded. Images
+// larger than maxAvatarSourceFactor times maxDimension are rejected before decoding.
+func resizeAvatar(data []byte, maxDimension int) ([]byte, error) {
+	imgConfig, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAvatar, err)
+	}
+	maxSource := maxDimension * maxAvatarSourceFactor
+	if imgConfig.Width > maxSource || imgConfig.Height > maxSource {
+		return nil, fmt.Errorf("%w: image is %dx%d, maximum is %dx%d", ErrInvalidAvatar, imgConfig.Width, imgConfig.Height, maxSource, maxSource)
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAvatar, err)
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() > maxDimension || bounds.Dy() > maxDimension {
+		img = imaging.Fit(img, maxDimension, maxDimension, imaging.Lanczos)
+	}
+
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, img, imaging.JPEG, imaging.JPEGQuality(avatarJPEGQuality)); err != nil {
+		return nil, fmt.Errorf("failed to encode avatar: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
 func (s *Service) generateThumbnail(ctx context.Context, stored *Storage, data []byte) error {
 	img, err := imaging.Decode(bytes.NewReader(data))
 	if err != nil {
@@ -149,11 +353,10 @@ func (s *Service) generateThumbnail(ctx context.Context, stored *Storage, data [
 		return fmt.Errorf("failed to encode thumbnail: %w", err)
 	}
 
-	ext := "_thumb.jpg"
 	basePath := strings.TrimSuffix(stored.StoragePath, filepath.Ext(stored.StoragePath))
-	thumbnailPath := basePath + ext
+	thumbnailPath := basePath + thumbnailSuffix
 
-	if err := s.backend.Store(ctx, thumbnailPath, &thumbBuf); err != nil {
+	if err := s.backend.Store(ctx, thumbnailPath, bytes.NewReader(thumbBuf.Bytes())); err != nil {
 		return fmt.Errorf("failed to store thumbnail: %w", err)
 	}
 
@@ -174,7 +377,7 @@ func (s *Service) Get(ctx context.Context, id string) (*Storage, error) {
 func (s *Service) populateURLs(ctx context.Context, stored *Storage) {
 	stored.URL, _ = s.backend.GetURL(ctx, stored.StoragePath)
 	if stored.ThumbnailPath != "" {
-		stored.ThumbnailURL = stored.URL + "/thumb"
+		stored.ThumbnailURL, _ = s.backend.GetURL(ctx, stored.ThumbnailPath)
 	}
 }
 
@@ -192,6 +395,11 @@ func (s *Service) GetData(ctx context.Context, id string) (io.ReadCloser, *Stora
 	return reader, stored, nil
 }
 
+// GetDataRange opens length bytes of the file starting at offset
+func (s *Service) GetDataRange(ctx context.Context, stored *Storage, offset, length int64) (io.ReadCloser, error) {
+	return s.backend.GetRange(ctx, stored.StoragePath, offset, length)
+}
+
 func (s *Service) GetThumbnail(ctx context.Context, id string) (io.ReadCloser, *Storage, error) {
 	stored, err := s.repo.GetByID(id)
 	if err != nil {
@@ -210,14 +418,75 @@ func (s *Service) GetThumbnail(ctx context.Context, id string) (io.ReadCloser, *
 	return reader, stored, nil
 }
 
-func (s *Service) Delete(ctx context.Context, id, requestorPublicKey string) error {
+// RegenerateThumbnail re-reads a stored image and rebuilds its thumbnail and dimensions,
+// e.g. after generation failed at upload time.
+func (s *Service) RegenerateThumbnail(ctx context.Context, id string) (*Storage, error) {
+	stored, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkThumbnailSupported(stored); err != nil {
+		return nil, err
+	}
+
+	reader, err := s.backend.Get(ctx, stored.StoragePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, s.maxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	stored.Width = nil
+	stored.Height = nil
+	stored.ThumbnailPath = ""
+	s.processImage(ctx, stored, data)
+
+	if stored.Width == nil || stored.Height == nil {
+		return nil, fmt.Errorf("failed to decode image")
+	}
+	if err := s.repo.UpdateDimensions(id, *stored.Width, *stored.Height); err != nil {
+		return nil, fmt.Errorf("failed to update dimensions: %w", err)
+	}
+
+	if stored.ThumbnailPath == "" {
+		return nil, fmt.Errorf("failed to generate thumbnail")
+	}
+	if err := s.repo.UpdateThumbnail(id, stored.ThumbnailPath); err != nil {
+		return nil, fmt.Errorf("failed to update thumbnail: %w", err)
+	}
+
+	log.Info().Str("storageId", id).Msg("[STORAGE] Thumbnail regenerated")
+
+	s.populateURLs(ctx, stored)
+	return stored, nil
+}
+
+// checkThumbnailSupported only lets completed image uploads through to thumbnail generation
+func checkThumbnailSupported(stored *Storage) error {
+	if stored.Status != string(StorageStatusReady) {
+		return fmt.Errorf("%w: storage is in status %s", ErrThumbnailNotSupported, stored.Status)
+	}
+	if !strings.HasPrefix(stored.ContentType, "image/") {
+		return fmt.Errorf("%w: content type is %s", ErrThumbnailNotSupported, stored.ContentType)
+	}
+	return nil
+}
+
+// Delete removes a file. The uploader may always delete it; canDeleteAny lets application
+// owners and admins remove files uploaded by other members.
+func (s *Service) Delete(ctx context.Context, id, requestorPublicKey string, canDeleteAny bool) error {
 	stored, err := s.repo.GetByID(id)
 	if err != nil {
 		return err
 	}
 
-	if stored.UploaderPublicKey != requestorPublicKey {
-		return fmt.Errorf("not authorized to delete this file")
+	if err := checkDeletePermission(stored, requestorPublicKey, canDeleteAny); err != nil {
+		return err
 	}
 
 	if err := s.backend.Delete(ctx, stored.StoragePath); err != nil {
@@ -233,6 +502,99 @@ func (s *Service) Delete(ctx context.Context, id, requestorPublicKey string) err
 	return s.repo.Delete(id)
 }
 
+// checkDeletePermission allows the uploader, or anyone granted canDeleteAny, to delete an application
+// file. User-scoped files (avatars) are only ever deletable by their uploader.
+func checkDeletePermission(stored *Storage, requestorPublicKey string, canDeleteAny bool) error {
+	if stored.UploaderPublicKey == requestorPublicKey {
+		return nil
+	}
+	if canDeleteAny && stored.ApplicationID != nil {
+		return nil
+	}
+	return fmt.Errorf("not authorized to delete this file")
+}
+
+// BulkDelete deletes the given files of an application. Each file must belong to the application
+// and be deletable by the requestor (see checkDeletePermission). Records
+// of all permitted files are removed in a single transaction; backend objects are removed after.
+// The returned results follow the order of ids.
+func (s *Service) BulkDelete(ctx context.Context, appID, requestorPublicKey string, canDeleteAny bool, ids []string) ([]BulkDeleteResult, error) {
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: storageIds is required", ErrInvalidBulkDelete)
+	}
+	if len(ids) > maxBulkDeleteIDs {
+		return nil, fmt.Errorf("%w: at most %d storage IDs per request", ErrInvalidBulkDelete, maxBulkDeleteIDs)
+	}
+
+	records := make(map[string]*Storage, len(ids))
+	for _, id := range ids {
+		if stored, err := s.repo.GetByID(id); err == nil {
+			records[id] = stored
+		}
+	}
+
+	deletable, results := authorizeBulkDelete(appID, requestorPublicKey, canDeleteAny, ids, records)
+	if len(deletable) == 0 {
+		return results, nil
+	}
+
+	deletableIDs := make([]string, 0, len(deletable))
+	for _, stored := range deletable {
+		deletableIDs = append(deletableIDs, stored.ID)
+	}
+	if err := s.repo.DeleteMany(deletableIDs); err != nil {
+		return nil, fmt.Errorf("failed to delete storage records: %w", err)
+	}
+
+	for _, stored := range deletable {
+		if err := s.backend.Delete(ctx, stored.StoragePath); err != nil {
+			log.Warn().Err(err).Str("path", stored.StoragePath).Msg("Failed to delete storage file during bulk delete")
+		}
+		if stored.ThumbnailPath != "" {
+			if err := s.backend.Delete(ctx, stored.ThumbnailPath); err != nil {
+				log.Warn().Err(err).Str("path", stored.ThumbnailPath).Msg("Failed to delete thumbnail during bulk delete")
+			}
+		}
+	}
+
+	for i := range results {
+		if results[i].Error == "" {
+			results[i].Deleted = true
+		}
+	}
+
+	return results, nil
+}
+
+// authorizeBulkDelete splits requested IDs into the records the requestor may delete and a
+// result per ID, with an error recorded for every ID that will not be deleted.
+func authorizeBulkDelete(appID, requestorPublicKey string, canDeleteAny bool, ids []string, records map[string]*Storage) ([]*Storage, []BulkDeleteResult) {
+	var deletable []*Storage
+	results := make([]BulkDeleteResult, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+
+	for _, id := range ids {
+		result := BulkDeleteResult{ID: id}
+		stored, exists := records[id]
+
+		switch {
+		case seen[id]:
+			result.Error = "duplicate storage ID"
+		case !exists || stored.ApplicationID == nil || *stored.ApplicationID != appID:
+			result.Error = "storage not found"
+		case checkDeletePermission(stored, requestorPublicKey, canDeleteAny) != nil:
+			result.Error = "not authorized to delete this file"
+		default:
+			deletable = append(deletable, stored)
+		}
+
+		seen[id] = true
+		results = append(results, result)
+	}
+
+	return deletable, results
+}
+
 func (s *Service) CleanupApplicationStorage(ctx context.Context, appID string) error {
 	storageList, err := s.repo.GetByApplicationID(appID)
 	if err != nil {
@@ -253,16 +615,84 @@ func (s *Service) CleanupApplicationStorage(ctx context.Context, appID string) e
 	return nil
 }
 
+// CleanupOrphanedStorage deletes the files and records of applications that were deleted,
+// or never finished registering, more than gracePeriod ago. A file whose record cannot be
+// deleted is left for the next run and not counted.
+func (s *Service) CleanupOrphanedStorage(ctx context.Context, gracePeriod time.Duration) (*OrphanCleanupResult, error) {
+	cutoff := s.clock.Now().Add(-gracePeriod).Unix()
+	orphaned, err := s.repo.GetOrphaned(cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find orphaned storage: %w", err)
+	}
+
+	result := &OrphanCleanupResult{}
+	for _, stored := range orphaned {
+		if stored.Status == string(StorageStatusPending) {
+			s.deletePendingChunks(ctx, stored)
+		}
+
+		if err := s.backend.Delete(ctx, stored.StoragePath); err != nil {
+			log.Warn().Err(err).Str("path", stored.StoragePath).Msg("[STORAGE] Failed to delete orphaned file")
+		}
+		if stored.ThumbnailPath != "" {
+			if err := s.backend.Delete(ctx, stored.ThumbnailPath); err != nil {
+				log.Warn().Err(err).Str("path", stored.ThumbnailPath).Msg("[STORAGE] Failed to delete orphaned thumbnail")
+			}
+		}
+
+		if err := s.repo.Delete(stored.ID); err != nil {
+			log.Warn().Err(err).Str("storageId", stored.ID).Msg("[STORAGE] Failed to delete orphaned storage record")
+			continue
+		}
+		result.DeletedFiles++
+		result.ReclaimedBytes += stored.SizeBytes
+	}
+
+	return result, nil
+}
+
+// deletePendingChunks removes the chunks an abandoned chunked upload left behind
+func (s *Service) deletePendingChunks(ctx context.Context, stored *Storage) {
+	chunks, err := s.repo.GetChunks(stored.ID)
+	if err != nil {
+		log.Warn().Err(err).Str("storageId", stored.ID).Msg("[STORAGE] Failed to list chunks of orphaned upload")
+		return
+	}
+	for _, chunk := range chunks {
+		chunkPath := fmt.Sprintf("%s.chunk.%d", stored.StoragePath, chunk.ChunkIndex)
+		s.backend.Delete(ctx, chunkPath)
+	}
+	if err := s.repo.DeleteChunks(stored.ID); err != nil {
+		log.Warn().Err(err).Str("storageId", stored.ID).Msg("[STORAGE] Failed to delete chunks of orphaned upload")
+	}
+}
+
 func (s *Service) InitChunkedUpload(ctx context.Context, appID *string, uploaderPublicKey string, req *ChunkedUploadInitRequest) (*ChunkedUploadInitResponse, error) {
-	if !allowedContentTypes[req.ContentType] {
-		return nil, fmt.Errorf("unsupported content type: %s", req.ContentType)
+	if err := s.checkContentType(req.Filename, req.ContentType); err != nil {
+		return nil, err
 	}
 
 	if req.TotalSize > s.maxFileSize {
 		return nil, fmt.Errorf("file too large: %d bytes (max: %d)", req.TotalSize, s.maxFileSize)
 	}
 
-	now := time.Now()
+	// The declared size of a pending upload counts toward the quota from now on
+	if err := s.checkAppQuota(appID, req.TotalSize); err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+
+	if s.maxPendingUploads > 0 {
+		pending, err := s.repo.CountPendingByUploader(uploaderPublicKey, now.Add(-abandonedUploadAge).Unix())
+		if err != nil {
+			return nil, fmt.Errorf("failed to count pending uploads: %w", err)
+		}
+		if err := checkPendingUploadLimit(pending, s.maxPendingUploads); err != nil {
+			return nil, err
+		}
+	}
+
 	storagePath := buildStoragePath(appID, req.ID, req.Filename, req.ContentType, now)
 
 	stored := &Storage{
@@ -293,6 +723,34 @@ func (s *Service) InitChunkedUpload(ctx context.Context, appID *string, uploader
 	}, nil
 }
 
+// checkAppQuota rejects storing additionalBytes more for the application when its files,
+// pending chunked uploads included, would exceed the quota. User-scoped files have no quota.
+func (s *Service) checkAppQuota(appID *string, additionalBytes int64) error {
+	if s.appQuota <= 0 || appID == nil {
+		return nil
+	}
+	used, err := s.repo.GetUsedBytesByApplication(*appID)
+	if err != nil {
+		return fmt.Errorf("failed to get application storage usage: %w", err)
+	}
+	return checkQuota(used, additionalBytes, s.appQuota)
+}
+
+func checkQuota(used, additionalBytes, quota int64) error {
+	if additionalBytes > 0 && used+additionalBytes > quota {
+		return fmt.Errorf("%w: %d of %d bytes used, %d more requested", ErrQuotaExceeded, used, quota, additionalBytes)
+	}
+	return nil
+}
+
+// checkPendingUploadLimit rejects a new chunked upload when the user already has the maximum in progress
+func checkPendingUploadLimit(pending, maxPending int) error {
+	if pending >= maxPending {
+		return fmt.Errorf("%w: %d uploads in progress (max: %d)", ErrTooManyPendingUploads, pending, maxPending)
+	}
+	return nil
+}
+
 func (s *Service) UploadChunk(ctx context.Context, storageID string, chunkIndex int, data io.Reader) error {
 	stored, err := s.repo.GetByID(storageID)
 	if err != nil {
@@ -302,15 +760,32 @@ func (s *Service) UploadChunk(ctx context.Context, storageID string, chunkIndex
 	if stored.Status != string(StorageStatusPending) {
 		return fmt.Errorf("cannot upload chunks for storage in status: %s", stored.Status)
 	}
+	if err := checkChunkIndex(chunkIndex, stored.SizeBytes, s.chunkSize); err != nil {
+		return err
+	}
 
 	buf := &bytes.Buffer{}
 	hasher := sha256.New()
 	writer := io.MultiWriter(buf, hasher)
 
+	// Reading one byte past the chunk size is enough to tell an oversized chunk
+	if s.chunkSize > 0 {
+		data = io.LimitReader(data, s.chunkSize+1)
+	}
 	n, err := io.Copy(writer, data)
 	if err != nil {
 		return fmt.Errorf("failed to read chunk data: %w", err)
 	}
+	if err := checkChunkSize(n, s.chunkSize); err != nil {
+		return err
+	}
+
+	// The first chunk holds the leading bytes of the file
+	if chunkIndex == 0 {
+		if err := checkSniffedContentType(stored.ContentType, buf.Bytes()); err != nil {
+			return err
+		}
+	}
 
 	checksum := hex.EncodeToString(hasher.Sum(nil))
 
@@ -324,12 +799,99 @@ func (s *Service) UploadChunk(ctx context.Context, storageID string, chunkIndex
 		ChunkIndex: chunkIndex,
 		ChunkSize:  n,
 		Checksum:   checksum,
-		UploadedAt: time.Now().Unix(),
+		UploadedAt: s.clock.Now().Unix(),
 	}
 
 	return s.repo.CreateChunk(chunk)
 }
 
+// GetChunkedUploadProgress reports which chunks of a pending upload the server has received,
+// so a resuming client only re-sends the missing ones.
+func (s *Service) GetChunkedUploadProgress(ctx context.Context, storageID string) (*ChunkedUploadProgress, error) {
+	stored, err := s.repo.GetByID(storageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if stored.Status != string(StorageStatusPending) {
+		return nil, fmt.Errorf("no upload in progress for storage in status: %s", stored.Status)
+	}
+
+	chunks, err := s.repo.GetChunks(storageID)
+	if err != nil {
+		return nil, err
+	}
+
+	received := make([]int, 0, len(chunks))
+	for _, chunk := range chunks {
+		received = append(received, chunk.ChunkIndex)
+	}
+
+	return &ChunkedUploadProgress{
+		StorageID:      storageID,
+		ReceivedChunks: received,
+		MissingChunks:  missingChunks(chunks, stored.SizeBytes, s.chunkSize),
+		TotalChunks:    expectedChunkCount(stored.SizeBytes, s.chunkSize),
+		ChunkSize:      s.chunkSize,
+		TotalSize:      stored.SizeBytes,
+	}, nil
+}
+
+// expectedChunkCount returns how many chunks a file of totalSize splits into
+// checkChunkIndex rejects chunk indexes outside the upload's declared size, so chunks
+// cannot store more than the size counted toward the quota. Without a known chunk size
+// there is nothing to check against.
+func checkChunkIndex(chunkIndex int, totalSize, chunkSize int64) error {
+	if chunkSize <= 0 {
+		return nil
+	}
+	count := expectedChunkCount(totalSize, chunkSize)
+	if chunkIndex < 0 || chunkIndex >= count {
+		return fmt.Errorf("%w: index %d outside 0-%d", ErrInvalidChunk, chunkIndex, count-1)
+	}
+	return nil
+}
+
+// checkChunkSize rejects a chunk larger than the configured chunk size
+func checkChunkSize(size, chunkSize int64) error {
+	if chunkSize > 0 && size > chunkSize {
+		return fmt.Errorf("%w: chunk larger than %d bytes", ErrInvalidChunk, chunkSize)
+	}
+	return nil
+}
+
+func expectedChunkCount(totalSize, chunkSize int64) int {
+	if totalSize <= 0 || chunkSize <= 0 {
+		return 0
+	}
+	return int((totalSize + chunkSize - 1) / chunkSize)
+}
+
+// missingChunks returns the indexes a client still has to upload: gaps between the received
+// chunks, which are ordered by index, and the trailing chunks while fewer than totalSize
+// bytes have arrived
+func missingChunks(chunks []*StorageChunk, totalSize, chunkSize int64) []int {
+	missing := []int{}
+	next := 0
+	for _, chunk := range chunks {
+		for ; next < chunk.ChunkIndex; next++ {
+			missing = append(missing, next)
+		}
+		next = chunk.ChunkIndex + 1
+	}
+
+	if totalChunkSize(chunks) < totalSize {
+		for ; next < expectedChunkCount(totalSize, chunkSize); next++ {
+			missing = append(missing, next)
+		}
+		// Chunks larger than the server chunk size still leave the file short
+		if len(missing) == 0 {
+			missing = append(missing, next)
+		}
+	}
+	return missing
+}
+
 func (s *Service) CompleteChunkedUpload(ctx context.Context, storageID string) (*Storage, error) {
 	stored, err := s.repo.GetByID(storageID)
 	if err != nil {
@@ -346,18 +908,70 @@ func (s *Service) CompleteChunkedUpload(ctx context.Context, storageID string) (
 	}
 
 	if len(chunks) == 0 {
-		return nil, fmt.Errorf("no chunks uploaded")
+		return nil, fmt.Errorf("%w: no chunks uploaded", ErrMissingChunks)
+	}
+
+	// A failed completion leaves the chunks and the pending record untouched, so the client
+	// can upload what is missing and complete again
+	if missing := missingChunks(chunks, stored.SizeBytes, s.chunkSize); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: re-upload chunks %v and complete again", ErrMissingChunks, missing)
+	}
+
+	// Usage already includes the declared size, so only chunks beyond it are checked
+	if err := s.checkAppQuota(stored.ApplicationID, totalChunkSize(chunks)-stored.SizeBytes); err != nil {
+		return nil, err
+	}
+
+	// Images are decoded for dimensions and thumbnails, so they are always assembled in memory
+	var combined []byte
+	multipart, ok := s.backend.(MultipartBackend)
+	if ok && !strings.HasPrefix(stored.ContentType, "image/") && canAssembleMultipart(chunks) {
+		if err := s.assembleMultipart(ctx, stored, chunks, multipart); err != nil {
+			return nil, err
+		}
+	} else {
+		combined, err = s.assembleBuffered(ctx, stored, chunks)
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	if strings.HasPrefix(stored.ContentType, "image/") {
+		s.processImage(ctx, stored, combined)
+		if stored.Width != nil && stored.Height != nil {
+			s.repo.UpdateDimensions(storageID, *stored.Width, *stored.Height)
+		}
+		if stored.ThumbnailPath != "" {
+			s.repo.UpdateThumbnail(storageID, stored.ThumbnailPath)
+		}
+	}
+
+	stored.SizeBytes = totalChunkSize(chunks)
+	stored.Status = string(StorageStatusReady)
+	if err := s.repo.UpdateStatus(storageID, string(StorageStatusReady)); err != nil {
+		return nil, err
+	}
+
+	// Chunks are only dropped once the upload is ready, so a retry after any earlier
+	// failure still finds them
+	for _, chunk := range chunks {
+		chunkPath := fmt.Sprintf("%s.chunk.%d", stored.StoragePath, chunk.ChunkIndex)
+		s.backend.Delete(ctx, chunkPath)
+	}
+
+	s.repo.DeleteChunks(storageID)
+
+	s.populateURLs(ctx, stored)
+	return stored, nil
+}
+
+// assembleBuffered concatenates the chunks in memory and stores them as one file
+func (s *Service) assembleBuffered(ctx context.Context, stored *Storage, chunks []*StorageChunk) ([]byte, error) {
 	var combined bytes.Buffer
 	hasher := sha256.New()
 	writer := io.MultiWriter(&combined, hasher)
 
-	for i, chunk := range chunks {
-		if chunk.ChunkIndex != i {
-			return nil, fmt.Errorf("missing chunk at index %d", i)
-		}
-
+	for i := range chunks {
 		chunkPath := fmt.Sprintf("%s.chunk.%d", stored.StoragePath, i)
 		reader, err := s.backend.Get(ctx, chunkPath)
 		if err != nil {
@@ -371,40 +985,69 @@ func (s *Service) CompleteChunkedUpload(ctx context.Context, storageID string) (
 		reader.Close()
 	}
 
-	checksum := hex.EncodeToString(hasher.Sum(nil))
-	if stored.Checksum != "" && checksum != stored.Checksum {
-		return nil, fmt.Errorf("checksum mismatch: expected %s, got %s", stored.Checksum, checksum)
+	if err := verifyChecksum(stored.Checksum, hasher.Sum(nil)); err != nil {
+		return nil, err
 	}
 
 	if err := s.backend.Store(ctx, stored.StoragePath, bytes.NewReader(combined.Bytes())); err != nil {
 		return nil, fmt.Errorf("failed to store combined file: %w", err)
 	}
+	return combined.Bytes(), nil
+}
 
-	for _, chunk := range chunks {
-		chunkPath := fmt.Sprintf("%s.chunk.%d", stored.StoragePath, chunk.ChunkIndex)
-		s.backend.Delete(ctx, chunkPath)
+// assembleMultipart streams the chunks to the backend as parts of one object, hashing
+// them on the way through so the checksum is verified before the object is committed
+func (s *Service) assembleMultipart(ctx context.Context, stored *Storage, chunks []*StorageChunk, backend MultipartBackend) error {
+	hasher := sha256.New()
+
+	openPart := func(index int) (io.ReadCloser, int64, error) {
+		chunkPath := fmt.Sprintf("%s.chunk.%d", stored.StoragePath, index)
+		reader, err := s.backend.Get(ctx, chunkPath)
+		if err != nil {
+			return nil, 0, err
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(reader, hasher), reader}, chunks[index].ChunkSize, nil
+	}
+	verify := func() error {
+		return verifyChecksum(stored.Checksum, hasher.Sum(nil))
 	}
 
-	s.repo.DeleteChunks(storageID)
+	if err := backend.StoreMultipart(ctx, stored.StoragePath, len(chunks), openPart, verify); err != nil {
+		return fmt.Errorf("failed to store combined file: %w", err)
+	}
+	return nil
+}
 
-	if strings.HasPrefix(stored.ContentType, "image/") {
-		s.processImage(ctx, stored, combined.Bytes())
-		if stored.Width != nil && stored.Height != nil {
-			s.repo.UpdateDimensions(storageID, *stored.Width, *stored.Height)
-		}
-		if stored.ThumbnailPath != "" {
-			s.repo.UpdateThumbnail(storageID, stored.ThumbnailPath)
+// canAssembleMultipart reports whether every chunk but the last meets the minimum part size
+func canAssembleMultipart(chunks []*StorageChunk) bool {
+	if len(chunks) < 2 {
+		return false
+	}
+	for _, chunk := range chunks[:len(chunks)-1] {
+		if chunk.ChunkSize < MinMultipartPartSize {
+			return false
 		}
 	}
+	return true
+}
 
-	stored.SizeBytes = int64(combined.Len())
-	stored.Status = string(StorageStatusReady)
-	if err := s.repo.UpdateStatus(storageID, string(StorageStatusReady)); err != nil {
-		return nil, err
+func totalChunkSize(chunks []*StorageChunk) int64 {
+	var total int64
+	for _, chunk := range chunks {
+		total += chunk.ChunkSize
 	}
+	return total
+}
 
-	s.populateURLs(ctx, stored)
-	return stored, nil
+func verifyChecksum(expected string, sum []byte) error {
+	checksum := hex.EncodeToString(sum)
+	if expected != "" && checksum != expected {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", expected, checksum)
+	}
+	return nil
 }
 
 func buildStoragePath(appID *string, storageID, filename, contentType string, now time.Time) string {
@@ -421,18 +1064,24 @@ func buildStoragePath(appID *string, storageID, filename, contentType string, no
 	return fmt.Sprintf("%s/%s/%s/%s%s", prefix, year, month, storageID, ext)
 }
 
+// processImage records an uploaded image's dimensions, read from its header, and builds
+// its thumbnail. Images above maxImagePixels are not decoded and get no thumbnail.
 func (s *Service) processImage(ctx context.Context, stored *Storage, data []byte) {
-	img, _, err := image.Decode(bytes.NewReader(data))
+	imgConfig, _, err := image.DecodeConfig(bytes.NewReader(data))
 	if err != nil {
 		return
 	}
 
-	bounds := img.Bounds()
-	w := bounds.Dx()
-	h := bounds.Dy()
+	w := imgConfig.Width
+	h := imgConfig.Height
 	stored.Width = &w
 	stored.Height = &h
 
+	if int64(w)*int64(h) > maxImagePixels {
+		log.Warn().Str("storageId", stored.ID).Int("width", w).Int("height", h).Msg("Image too large to generate a thumbnail")
+		return
+	}
+
 	if err := s.generateThumbnail(ctx, stored, data); err != nil {
 		log.Warn().Err(err).Str("storageId", stored.ID).Msg("Failed to generate thumbnail")
 	}
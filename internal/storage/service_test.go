@@ -0,0 +1,466 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/prappser/prappser_server/internal/application"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPendingUploadLimit_ShouldAllowBelowLimit(t *testing.T) {
+	// when
+	err := checkPendingUploadLimit(2, 3)
+
+	// then
+	assert.NoError(t, err)
+}
+
+func TestCheckPendingUploadLimit_ShouldRejectAtLimit(t *testing.T) {
+	// when
+	err := checkPendingUploadLimit(3, 3)
+
+	// then
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, ErrTooManyPendingUploads))
+}
+
+func TestCheckPendingUploadLimit_ShouldRejectAboveLimit(t *testing.T) {
+	// when
+	err := checkPendingUploadLimit(5, 3)
+
+	// then
+	assert.True(t, errors.Is(err, ErrTooManyPendingUploads))
+}
+
+func TestCheckQuota_ShouldAllowUploadFillingQuota(t *testing.T) {
+	// when
+	err := checkQuota(60, 40, 100)
+
+	// then
+	assert.NoError(t, err)
+}
+
+func TestCheckQuota_ShouldRejectUploadOverQuota(t *testing.T) {
+	// when
+	err := checkQuota(60, 41, 100)
+
+	// then
+	assert.True(t, errors.Is(err, ErrQuotaExceeded))
+	assert.Contains(t, err.Error(), "application storage quota exceeded")
+}
+
+func TestMaxRequestBodySize_ShouldFitLargestFileOrChunk(t *testing.T) {
+	// given
+	fileLimited := NewService(nil, nil, Config{MaxFileSize: 10 * 1024 * 1024, ChunkSize: 5 * 1024 * 1024, ExternalURL: "http://localhost"})
+	chunkLimited := NewService(nil, nil, Config{MaxFileSize: 1024 * 1024, ChunkSize: 5 * 1024 * 1024, ExternalURL: "http://localhost"})
+
+	// then
+	assert.Equal(t, 10*1024*1024+maxMultipartOverhead, fileLimited.MaxRequestBodySize())
+	assert.Equal(t, 5*1024*1024+maxMultipartOverhead, chunkLimited.MaxRequestBodySize())
+}
+
+func TestExpectedChunkCount_ShouldRoundUpPartialChunk(t *testing.T) {
+	// when
+	count := expectedChunkCount(11, 5)
+
+	// then
+	assert.Equal(t, 3, count)
+}
+
+func TestExpectedChunkCount_ShouldHandleExactMultiple(t *testing.T) {
+	// when
+	count := expectedChunkCount(10, 5)
+
+	// then
+	assert.Equal(t, 2, count)
+}
+
+func TestExpectedChunkCount_ShouldReturnZeroForUnknownChunkSize(t *testing.T) {
+	// when
+	count := expectedChunkCount(10, 0)
+
+	// then
+	assert.Equal(t, 0, count)
+}
+
+func TestCheckChunkIndex_ShouldRejectIndexOutsideDeclaredSize(t *testing.T) {
+	// when
+	negativeErr := checkChunkIndex(-1, 11, 5)
+	beyondErr := checkChunkIndex(3, 11, 5)
+	lastErr := checkChunkIndex(2, 11, 5)
+
+	// then
+	assert.ErrorIs(t, negativeErr, ErrInvalidChunk)
+	assert.ErrorIs(t, beyondErr, ErrInvalidChunk)
+	assert.NoError(t, lastErr)
+}
+
+func TestCheckChunkSize_ShouldRejectChunkLargerThanChunkSize(t *testing.T) {
+	// when
+	oversizedErr := checkChunkSize(6, 5)
+	fullErr := checkChunkSize(5, 5)
+
+	// then
+	assert.ErrorIs(t, oversizedErr, ErrInvalidChunk)
+	assert.NoError(t, fullErr)
+}
+
+func TestCheckThumbnailSupported_ShouldAllowReadyImage(t *testing.T) {
+	// given
+	stored := &Storage{ContentType: "image/png", Status: string(StorageStatusReady)}
+
+	// when
+	err := checkThumbnailSupported(stored)
+
+	// then
+	assert.NoError(t, err)
+}
+
+func TestCheckThumbnailSupported_ShouldRejectPendingUpload(t *testing.T) {
+	// given
+	stored := &Storage{ContentType: "image/png", Status: string(StorageStatusPending)}
+
+	// when
+	err := checkThumbnailSupported(stored)
+
+	// then
+	assert.True(t, errors.Is(err, ErrThumbnailNotSupported))
+}
+
+func TestCheckThumbnailSupported_ShouldRejectVideo(t *testing.T) {
+	// given
+	stored := &Storage{ContentType: "video/mp4", Status: string(StorageStatusReady)}
+
+	// when
+	err := checkThumbnailSupported(stored)
+
+	// then
+	assert.True(t, errors.Is(err, ErrThumbnailNotSupported))
+}
+
+func createTestBulkRecords() map[string]*Storage {
+	appID := "app-1"
+	otherAppID := "app-2"
+	return map[string]*Storage{
+		"own-file":       {ID: "own-file", ApplicationID: &appID, UploaderPublicKey: "member-key"},
+		"other-file":     {ID: "other-file", ApplicationID: &appID, UploaderPublicKey: "other-key"},
+		"other-app-file": {ID: "other-app-file", ApplicationID: &otherAppID, UploaderPublicKey: "member-key"},
+	}
+}
+
+func TestAuthorizeBulkDelete_ShouldSplitAuthorizedAndUnauthorizedIDs(t *testing.T) {
+	// given
+	records := createTestBulkRecords()
+
+	// when
+	deletable, results := authorizeBulkDelete("app-1", "member-key", false, []string{"own-file", "other-file", "other-app-file", "missing"}, records)
+
+	// then
+	assert.Len(t, deletable, 1)
+	assert.Equal(t, "own-file", deletable[0].ID)
+	assert.Equal(t, []BulkDeleteResult{
+		{ID: "own-file"},
+		{ID: "other-file", Error: "not authorized to delete this file"},
+		{ID: "other-app-file", Error: "storage not found"},
+		{ID: "missing", Error: "storage not found"},
+	}, results)
+}
+
+func TestAuthorizeBulkDelete_ShouldLetOwnerDeleteAnyFileInApplication(t *testing.T) {
+	// given
+	records := createTestBulkRecords()
+
+	// when
+	deletable, results := authorizeBulkDelete("app-1", "owner-key", true, []string{"own-file", "other-file", "other-app-file"}, records)
+
+	// then
+	assert.Len(t, deletable, 2)
+	assert.Empty(t, results[0].Error)
+	assert.Empty(t, results[1].Error)
+	assert.Equal(t, "storage not found", results[2].Error)
+}
+
+func TestAuthorizeBulkDelete_ShouldRejectDuplicateIDs(t *testing.T) {
+	// given
+	records := createTestBulkRecords()
+
+	// when
+	deletable, results := authorizeBulkDelete("app-1", "member-key", false, []string{"own-file", "own-file"}, records)
+
+	// then
+	assert.Len(t, deletable, 1)
+	assert.Equal(t, "duplicate storage ID", results[1].Error)
+}
+
+func TestCheckDeletePermission_ShouldAllowUploader(t *testing.T) {
+	// given
+	appID := "app-1"
+	stored := &Storage{ApplicationID: &appID, UploaderPublicKey: "member-key"}
+
+	// when
+	err := checkDeletePermission(stored, "member-key", false)
+
+	// then
+	assert.NoError(t, err)
+}
+
+func TestCheckDeletePermission_ShouldAllowOwnerToDeleteMemberFile(t *testing.T) {
+	// given
+	appID := "app-1"
+	stored := &Storage{ApplicationID: &appID, UploaderPublicKey: "member-key"}
+
+	// when
+	err := checkDeletePermission(stored, "owner-key", canDeleteAnyFile(application.MemberRoleOwner))
+
+	// then
+	assert.NoError(t, err)
+}
+
+func TestCheckDeletePermission_ShouldForbidMemberDeletingAnotherMembersFile(t *testing.T) {
+	// given
+	appID := "app-1"
+	stored := &Storage{ApplicationID: &appID, UploaderPublicKey: "member-key"}
+
+	// when
+	err := checkDeletePermission(stored, "other-member-key", canDeleteAnyFile(application.MemberRoleMember))
+
+	// then
+	assert.Error(t, err)
+}
+
+func TestCheckDeletePermission_ShouldForbidOverrideForUserScopedFile(t *testing.T) {
+	// given
+	stored := &Storage{UploaderPublicKey: "member-key"}
+
+	// when
+	err := checkDeletePermission(stored, "owner-key", true)
+
+	// then
+	assert.Error(t, err)
+}
+
+func TestCanDeleteAnyFile_ShouldOnlyAllowOwnersAndAdmins(t *testing.T) {
+	// when / then
+	assert.True(t, canDeleteAnyFile(application.MemberRoleOwner))
+	assert.True(t, canDeleteAnyFile(application.MemberRoleAdmin))
+	assert.False(t, canDeleteAnyFile(application.MemberRoleMember))
+	assert.False(t, canDeleteAnyFile(application.MemberRoleViewer))
+}
+
+func createTestChunks(sizes ...int64) []*StorageChunk {
+	chunks := make([]*StorageChunk, len(sizes))
+	for i, size := range sizes {
+		chunks[i] = &StorageChunk{ChunkIndex: i, ChunkSize: size}
+	}
+	return chunks
+}
+
+func TestMissingChunks_ShouldReportGapBetweenReceivedChunks(t *testing.T) {
+	// given - chunk 1 of a 10 byte upload in 4 byte chunks never arrived
+	chunks := []*StorageChunk{{ChunkIndex: 0, ChunkSize: 4}, {ChunkIndex: 2, ChunkSize: 2}}
+
+	// when
+	missing := missingChunks(chunks, 10, 4)
+
+	// then
+	assert.Equal(t, []int{1}, missing)
+}
+
+func TestMissingChunks_ShouldReportTrailingChunksUntilTotalSize(t *testing.T) {
+	// when
+	missing := missingChunks(createTestChunks(4), 10, 4)
+
+	// then
+	assert.Equal(t, []int{1, 2}, missing)
+}
+
+func TestMissingChunks_ShouldReturnEmptyWhenComplete(t *testing.T) {
+	// when
+	missing := missingChunks(createTestChunks(4, 4, 2), 10, 4)
+
+	// then
+	assert.Empty(t, missing)
+}
+
+func TestCanAssembleMultipart_ShouldAllowSmallLastPart(t *testing.T) {
+	// when
+	result := canAssembleMultipart(createTestChunks(MinMultipartPartSize, MinMultipartPartSize, 10))
+
+	// then
+	assert.True(t, result)
+}
+
+func TestCanAssembleMultipart_ShouldRejectSmallIntermediatePart(t *testing.T) {
+	// when
+	result := canAssembleMultipart(createTestChunks(MinMultipartPartSize, 10, 10))
+
+	// then
+	assert.False(t, result)
+}
+
+func TestCanAssembleMultipart_ShouldRejectSingleChunk(t *testing.T) {
+	// when
+	result := canAssembleMultipart(createTestChunks(MinMultipartPartSize * 2))
+
+	// then
+	assert.False(t, result)
+}
+
+func TestVerifyChecksum_ShouldRejectMismatch(t *testing.T) {
+	// when
+	err := verifyChecksum("expected", []byte{0x01})
+
+	// then
+	assert.EqualError(t, err, "checksum mismatch: expected expected, got 01")
+}
+
+func createTestPNG(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		img.Set(x, 0, color.RGBA{R: 255, A: 255})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestResizeAvatar_ShouldFitOversizedImageWithinMaxDimension(t *testing.T) {
+	// given
+	data := createTestPNG(t, 1024, 512)
+
+	// when
+	resized, err := resizeAvatar(data, 256)
+
+	// then
+	assert.NoError(t, err)
+	img, err := jpeg.Decode(bytes.NewReader(resized))
+	assert.NoError(t, err)
+	assert.Equal(t, 256, img.Bounds().Dx())
+	assert.Equal(t, 128, img.Bounds().Dy())
+}
+
+func TestResizeAvatar_ShouldKeepSizeOfSmallImage(t *testing.T) {
+	// given
+	data := createTestPNG(t, 64, 48)
+
+	// when
+	resized, err := resizeAvatar(data, 256)
+
+	// then
+	assert.NoError(t, err)
+	img, err := jpeg.Decode(bytes.NewReader(resized))
+	assert.NoError(t, err)
+	assert.Equal(t, 64, img.Bounds().Dx())
+	assert.Equal(t, 48, img.Bounds().Dy())
+}
+
+func TestResizeAvatar_ShouldRejectNonImage(t *testing.T) {
+	// when
+	_, err := resizeAvatar([]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"), 256)
+
+	// then
+	assert.True(t, errors.Is(err, ErrInvalidAvatar))
+}
+
+func TestResizeAvatar_ShouldRejectHugeImageBeforeDecoding(t *testing.T) {
+	// given
+	data := createTestPNG(t, 100, 10)
+
+	// when
+	_, err := resizeAvatar(data, 4)
+
+	// then
+	assert.True(t, errors.Is(err, ErrInvalidAvatar))
+	assert.Contains(t, err.Error(), "image is 100x10, maximum is 64x64")
+}
+
+func TestProcessImage_ShouldSkipThumbnailOfHugeImage(t *testing.T) {
+	// given - a GIF header declaring 65535x65535 pixels without any pixel data
+	data := []byte("GIF89a\xff\xff\xff\xff\x00\x00\x00")
+	service := NewService(nil, nil, Config{ExternalURL: "http://localhost"})
+	stored := &Storage{ID: "storage-1"}
+
+	// when
+	service.processImage(context.Background(), stored, data)
+
+	// then
+	if assert.NotNil(t, stored.Width) && assert.NotNil(t, stored.Height) {
+		assert.Equal(t, 65535, *stored.Width)
+		assert.Equal(t, 65535, *stored.Height)
+	}
+	assert.Empty(t, stored.ThumbnailPath)
+}
+
+func TestUploadAvatar_ShouldRejectNonImageBeforeStoring(t *testing.T) {
+	// given
+	service := NewService(nil, nil, Config{MaxFileSize: 1024, ExternalURL: "http://localhost"})
+	req := &UploadRequest{ID: "avatar-1", Filename: "avatar.png", ContentType: "image/png", SizeBytes: 11}
+
+	// when
+	_, err := service.UploadAvatar(context.Background(), "user-key", req, strings.NewReader("not-an-image"))
+
+	// then
+	assert.True(t, errors.Is(err, ErrInvalidAvatar))
+}
+
+func TestUploadAvatar_ShouldRejectOversizedUpload(t *testing.T) {
+	// given
+	service := NewService(nil, nil, Config{MaxFileSize: 16, ExternalURL: "http://localhost"})
+	data := createTestPNG(t, 32, 32)
+	req := &UploadRequest{ID: "avatar-1", Filename: "avatar.png", ContentType: "image/png"}
+
+	// when
+	_, err := service.UploadAvatar(context.Background(), "user-key", req, bytes.NewReader(data))
+
+	// then
+	assert.ErrorContains(t, err, "file too large")
+}
+
+func TestUpload_ShouldNameUnknownExtensionAndListAllowedTypes(t *testing.T) {
+	// given
+	service := NewService(nil, nil, Config{MaxFileSize: 1024, ExternalURL: "http://localhost"})
+	req := &UploadRequest{ID: "file-1", Filename: "report.XYZ", ContentType: detectContentType("report.XYZ"), SizeBytes: 4}
+
+	// when
+	_, err := service.Upload(context.Background(), nil, "user-key", req, strings.NewReader("data"))
+
+	// then
+	assert.True(t, errors.Is(err, ErrUnsupportedFileType))
+	assert.ErrorContains(t, err, "unsupported file type: .xyz")
+	assert.ErrorContains(t, err, "image/png")
+	assert.NotContains(t, err.Error(), octetStreamContentType)
+}
+
+func TestCheckContentType_ShouldNameExplicitUnsupportedContentType(t *testing.T) {
+	// given
+	service := NewService(nil, nil, Config{MaxFileSize: 1024, ExternalURL: "http://localhost"})
+
+	// when
+	err := service.checkContentType("page.png", "text/html")
+
+	// then
+	assert.ErrorContains(t, err, "unsupported file type: text/html")
+}
+
+func TestCheckContentType_ShouldAllowOctetStreamWhenConfigured(t *testing.T) {
+	// given
+	service := NewService(nil, nil, Config{MaxFileSize: 1024, AllowOctetStream: true, ExternalURL: "http://localhost"})
+
+	// when
+	octetErr := service.checkContentType("report.xyz", octetStreamContentType)
+	htmlErr := service.checkContentType("page.html", "text/html")
+
+	// then
+	assert.NoError(t, octetErr)
+	assert.ErrorContains(t, htmlErr, octetStreamContentType)
+}
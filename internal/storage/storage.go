@@ -2,17 +2,35 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"time"
 )
 
 type StorageBackend interface {
 	Store(ctx context.Context, path string, reader io.Reader) error
 	Get(ctx context.Context, path string) (io.ReadCloser, error)
+	// GetRange reads length bytes of the file starting at offset
+	GetRange(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error)
 	Delete(ctx context.Context, path string) error
 	Exists(ctx context.Context, path string) (bool, error)
 	GetURL(ctx context.Context, path string) (string, error)
 }
 
+// MinMultipartPartSize is the smallest part S3 accepts for every part except the last
+const MinMultipartPartSize = 5 * 1024 * 1024
+
+// PartOpener opens the part at the given index and reports its size in bytes
+type PartOpener func(index int) (io.ReadCloser, int64, error)
+
+// MultipartBackend is implemented by backends that can assemble an object from parts
+// uploaded one at a time, so the server never holds the whole file in memory
+type MultipartBackend interface {
+	// StoreMultipart uploads partCount parts as a single object at path. verify runs once
+	// every part is uploaded; when it returns an error the upload is aborted.
+	StoreMultipart(ctx context.Context, path string, partCount int, openPart PartOpener, verify func() error) error
+}
+
 type StorageType string
 
 const (
@@ -22,6 +40,8 @@ const (
 
 type BackendConfig struct {
 	Type         StorageType
+	FallbackType StorageType
+	FailoverMode FailoverMode
 	LocalPath    string
 	S3Endpoint   string
 	S3Bucket     string
@@ -29,13 +49,28 @@ type BackendConfig struct {
 	S3SecretKey  string
 	S3Region     string
 	S3UseSSL     bool
+	S3URLExpiry  time.Duration
 	MaxFileSize  int64
 	ChunkSize    int64
 	ExternalURL  string
 }
 
+// NewBackend builds the configured backend, behind a FailoverStorage when FallbackType is set
 func NewBackend(config *BackendConfig) (StorageBackend, error) {
-	switch config.Type {
+	primary, err := newSingleBackend(config.Type, config)
+	if err != nil || config.FallbackType == "" {
+		return primary, err
+	}
+
+	secondary, err := newSingleBackend(config.FallbackType, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize fallback storage backend: %w", err)
+	}
+	return NewFailoverStorage(primary, secondary, config.FailoverMode), nil
+}
+
+func newSingleBackend(storageType StorageType, config *BackendConfig) (StorageBackend, error) {
+	switch storageType {
 	case StorageTypeS3:
 		return NewS3Storage(config)
 	default:
@@ -0,0 +1,52 @@
+package user
+
+import "container/list"
+
+// challengeEviction bounds the total number of stored challenges. Once the bound is
+// passed, challenges are evicted from the user who was issued one least recently. It is
+// guarded by UserEndpoints.challengesMu.
+type challengeEviction struct {
+	limit   int // zero means unbounded
+	total   int
+	order   *list.List // publicKeys, least recently issued first
+	entries map[string]*list.Element
+}
+
+func newChallengeEviction(limit int) *challengeEviction {
+	return &challengeEviction{
+		limit:   limit,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// touch marks the key as issued a challenge just now
+func (e *challengeEviction) touch(key string) {
+	if element, ok := e.entries[key]; ok {
+		e.order.MoveToBack(element)
+		return
+	}
+	e.entries[key] = e.order.PushBack(key)
+}
+
+// forget drops the key once it holds no challenges
+func (e *challengeEviction) forget(key string) {
+	if element, ok := e.entries[key]; ok {
+		e.order.Remove(element)
+		delete(e.entries, key)
+	}
+}
+
+// over reports whether more challenges are stored than allowed
+func (e *challengeEviction) over() bool {
+	return e.limit > 0 && e.total > e.limit
+}
+
+// oldest returns the key issued a challenge least recently
+func (e *challengeEviction) oldest() (string, bool) {
+	element := e.order.Front()
+	if element == nil {
+		return "", false
+	}
+	return element.Value.(string), true
+}
@@ -0,0 +1,62 @@
+package user
+
+import (
+	"sync"
+	"time"
+)
+
+// challengeRateLimiter limits how many challenges each user may request within a sliding
+// window. It is shared by all request goroutines.
+type challengeRateLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	window   time.Duration
+	requests map[string][]time.Time // publicKey -> request times, oldest first
+}
+
+func newChallengeRateLimiter(limit int, window time.Duration) *challengeRateLimiter {
+	return &challengeRateLimiter{
+		limit:    limit,
+		window:   window,
+		requests: make(map[string][]time.Time),
+	}
+}
+
+// allow records a request for the key if it is within the limit. When it is not, allow
+// returns false and how long until the oldest request leaves the window.
+func (l *challengeRateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	recent := l.recent(key, now)
+	if len(recent) >= l.limit {
+		l.requests[key] = recent
+		return false, recent[0].Add(l.window).Sub(now)
+	}
+	l.requests[key] = append(recent, now)
+	return true, 0
+}
+
+// sweep forgets keys without requests in the current window
+func (l *challengeRateLimiter) sweep(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key := range l.requests {
+		if recent := l.recent(key, now); len(recent) > 0 {
+			l.requests[key] = recent
+		} else {
+			delete(l.requests, key)
+		}
+	}
+}
+
+// recent returns the key's requests still inside the window; the caller holds mu
+func (l *challengeRateLimiter) recent(key string, now time.Time) []time.Time {
+	requests := l.requests[key]
+	cutoff := now.Add(-l.window)
+	for len(requests) > 0 && !requests[0].After(cutoff) {
+		requests = requests[1:]
+	}
+	return requests
+}
@@ -0,0 +1,42 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChallengeRateLimiter_ShouldRejectRequestsBeyondLimitWithinWindow(t *testing.T) {
+	// given
+	limiter := newChallengeRateLimiter(2, time.Minute)
+	now := time.Now()
+	limiter.allow("user-key", now)
+	limiter.allow("user-key", now.Add(10*time.Second))
+
+	// when
+	allowed, retryAfter := limiter.allow("user-key", now.Add(20*time.Second))
+	otherAllowed, _ := limiter.allow("other-key", now.Add(20*time.Second))
+	laterAllowed, _ := limiter.allow("user-key", now.Add(time.Minute+time.Second))
+
+	// then
+	assert.False(t, allowed)
+	assert.Equal(t, 40*time.Second, retryAfter)
+	assert.True(t, otherAllowed)
+	assert.True(t, laterAllowed)
+}
+
+func TestChallengeRateLimiter_SweepShouldForgetIdleKeys(t *testing.T) {
+	// given
+	limiter := newChallengeRateLimiter(5, time.Minute)
+	now := time.Now()
+	limiter.allow("idle-key", now)
+	limiter.allow("active-key", now.Add(50*time.Second))
+
+	// when
+	limiter.sweep(now.Add(90 * time.Second))
+
+	// then
+	assert.NotContains(t, limiter.requests, "idle-key")
+	assert.Contains(t, limiter.requests, "active-key")
+}
@@ -0,0 +1,44 @@
+package user
+
+import (
+	"sync"
+	"time"
+)
+
+// lastSeenThrottle limits last-seen writes to one per user per interval. Entries older than
+// the interval no longer throttle anything and are pruned once per interval.
+type lastSeenThrottle struct {
+	interval  time.Duration
+	mu        sync.Mutex
+	written   map[string]time.Time // publicKey -> time of the last write
+	lastPrune time.Time
+}
+
+func newLastSeenThrottle(interval time.Duration) *lastSeenThrottle {
+	return &lastSeenThrottle{
+		interval: interval,
+		written:  make(map[string]time.Time),
+	}
+}
+
+// due reports whether the last-seen time of the user should be written at now, recording
+// the write when it is
+func (t *lastSeenThrottle) due(publicKey string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if last, ok := t.written[publicKey]; ok && now.Sub(last) < t.interval {
+		return false
+	}
+	t.written[publicKey] = now
+
+	if now.Sub(t.lastPrune) >= t.interval {
+		for key, last := range t.written {
+			if now.Sub(last) >= t.interval {
+				delete(t.written, key)
+			}
+		}
+		t.lastPrune = now
+	}
+	return true
+}
@@ -53,8 +53,10 @@ func DecryptJWE(encryptedJWE string, masterPasswordMD5Hash string) (*RegisterJWE
 	return &registerJWEClaims, nil
 }
 
-// VerifyJWS verifies the JWT using the Ed25519 public key from its claims
-func VerifyJWS(signedJWT string, registrationTokenTTLSec int32) (*RegisterJWSClaims, error) {
+// VerifyJWS verifies the JWT using the Ed25519 public key from its claims. clockSkewSec
+// is allowed on both sides of the validity window, so a slightly late token is still
+// accepted but a token issued in the future cannot extend its own validity.
+func VerifyJWS(signedJWT string, registrationTokenTTLSec, clockSkewSec int32) (*RegisterJWSClaims, error) {
 	// Parse JWT without verification first to get claims
 	token, _, err := jwt.NewParser().ParseUnverified(signedJWT, jwt.MapClaims{})
 	if err != nil {
@@ -77,12 +79,16 @@ func VerifyJWS(signedJWT string, registrationTokenTTLSec int32) (*RegisterJWSCla
 		registerJWSClaims.IssuedAt = int64(iat)
 	}
 
-	// Check if the JWT is expired
+	// Check if the JWT is expired or issued ahead of the allowed skew
 	var timeNow = timeNowFunc()
 	var issuedAtTime = time.Unix(registerJWSClaims.IssuedAt, 0)
-	if issuedAtTime.Add(time.Duration(registrationTokenTTLSec) * time.Second).Before(timeNow) {
+	var skew = time.Duration(clockSkewSec) * time.Second
+	if issuedAtTime.Add(time.Duration(registrationTokenTTLSec)*time.Second + skew).Before(timeNow) {
 		return nil, fmt.Errorf("JWT has expired")
 	}
+	if issuedAtTime.After(timeNow.Add(skew)) {
+		return nil, fmt.Errorf("JWT issued in the future")
+	}
 
 	// Decode the Ed25519 public key from base64
 	publicKeyBytes, err := base64.StdEncoding.DecodeString(registerJWSClaims.PublicKey)
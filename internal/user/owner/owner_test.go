@@ -63,7 +63,7 @@ func TestVerifyJWS_ShouldVerifyValidly(t *testing.T) {
 	})
 
 	// when
-	actualClaims, err := VerifyJWS(signedJWT, 10)
+	actualClaims, err := VerifyJWS(signedJWT, 10, 0)
 
 	// then
 	assert.NoError(t, err)
@@ -86,7 +86,7 @@ func TestVerifyJWS_ShouldNotVerifyWhenExpired(t *testing.T) {
 	})
 
 	// when
-	actualClaims, err := VerifyJWS(signedJWT, 10)
+	actualClaims, err := VerifyJWS(signedJWT, 10, 0)
 
 	// then
 	assert.Error(t, err)
@@ -108,13 +108,89 @@ func TestVerifyJWS_ShouldNotVerifyWithWrongPublicKey(t *testing.T) {
 	})
 
 	// when
-	actualClaims, err := VerifyJWS(signedJWT, 10)
+	actualClaims, err := VerifyJWS(signedJWT, 10, 0)
 
 	// then
 	assert.Error(t, err)
 	assert.Nil(t, actualClaims)
 }
 
+func TestVerifyJWS_ShouldVerifyWhenExpiredWithinClockSkew(t *testing.T) {
+	// given
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	publicKeyBase64 := base64.StdEncoding.EncodeToString(publicKey)
+	issuedAt := time.Now().Unix()
+	signedJWT := generateEd25519JWT(privateKey, publicKeyBase64, "testuser", issuedAt)
+
+	SetTimeNowFunc(func() time.Time {
+		return time.Unix(issuedAt+15, 0) // exactly at TTL (10s) + skew (5s)
+	})
+
+	// when
+	actualClaims, err := VerifyJWS(signedJWT, 10, 5)
+
+	// then
+	assert.NoError(t, err)
+	assert.NotNil(t, actualClaims)
+}
+
+func TestVerifyJWS_ShouldNotVerifyWhenExpiredBeyondClockSkew(t *testing.T) {
+	// given
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	publicKeyBase64 := base64.StdEncoding.EncodeToString(publicKey)
+	issuedAt := time.Now().Unix()
+	signedJWT := generateEd25519JWT(privateKey, publicKeyBase64, "testuser", issuedAt)
+
+	SetTimeNowFunc(func() time.Time {
+		return time.Unix(issuedAt+16, 0) // one second past TTL (10s) + skew (5s)
+	})
+
+	// when
+	actualClaims, err := VerifyJWS(signedJWT, 10, 5)
+
+	// then
+	assert.EqualError(t, err, "JWT has expired")
+	assert.Nil(t, actualClaims)
+}
+
+func TestVerifyJWS_ShouldVerifyWhenIssuedInFutureWithinClockSkew(t *testing.T) {
+	// given
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	publicKeyBase64 := base64.StdEncoding.EncodeToString(publicKey)
+	issuedAt := time.Now().Unix()
+	signedJWT := generateEd25519JWT(privateKey, publicKeyBase64, "testuser", issuedAt)
+
+	SetTimeNowFunc(func() time.Time {
+		return time.Unix(issuedAt-5, 0) // client clock exactly skew (5s) ahead
+	})
+
+	// when
+	actualClaims, err := VerifyJWS(signedJWT, 10, 5)
+
+	// then
+	assert.NoError(t, err)
+	assert.NotNil(t, actualClaims)
+}
+
+func TestVerifyJWS_ShouldNotVerifyWhenIssuedInFutureBeyondClockSkew(t *testing.T) {
+	// given
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	publicKeyBase64 := base64.StdEncoding.EncodeToString(publicKey)
+	issuedAt := time.Now().Unix()
+	signedJWT := generateEd25519JWT(privateKey, publicKeyBase64, "testuser", issuedAt)
+
+	SetTimeNowFunc(func() time.Time {
+		return time.Unix(issuedAt-6, 0) // client clock one second beyond skew (5s) ahead
+	})
+
+	// when
+	actualClaims, err := VerifyJWS(signedJWT, 10, 5)
+
+	// then
+	assert.EqualError(t, err, "JWT issued in the future")
+	assert.Nil(t, actualClaims)
+}
+
 func TestExtractJWEFromAuthorizationHeader_ShouldExtractValidly(t *testing.T) {
 	// given
 	authHeader := "Bearer eyJlbmMiOiJBMTI4R0NNIiwiYWxnIjoiZGlyIn0..914U_1q7qRgmah7-.4MqUDKtqo1CH2Y2JcFdsrelwHBBFOK0FJKXGqjwKCXo1XVgm0CuHDh6sBaDKDuDjtYtNW8Mrx_n7NPhWpchbL-ejwI8LWcS4VTBM0sVqMxgzNTckW2vbEXKTnTQlpF_-MIfLFANr5EskLFuUIpeZTBN_KZ5bqeMn5Uwjth0CXrQ6ec_2nSRnK7yMIShCRTjSiOBUhrT-d0tHQjbKXMpqyQ4GQYVwQ5g-wRT7vsOpBBZmw5YpuSeL32ZD7Ltl_bnKAWkis55Uj2nu6oCZN-chqMDDetHjuHYGRSf4GbrGB2mJ_T7EzUjyHVrtAUS6ZYaLTglcrwH1TZ3dIv0oeexDECfrJjvAP_VcNXmgP5OB6_kS937wquuoNgnjTNnMvL9QGNfIbUW4tL7QcGyjJ4ynQcheWAIUPu9Y73Se-9ecDnAr_Tq83EKWUFFyhQb_lTxULtRQ5GqrC-vYeIXy63BsJqSUwr1hPNXP6Pm3_IJzwK4HtjyZWwQzxH3gBogDhP39MB5eRCkUPHaMyLyGZ1PYEUlrXabZp0BsxIDqK6sWlNj0JP63diHb8INLR7ysGD2SMOzsuxfhJtzCnK2SHK5hNOSXpcDYd1mWcKz1lh_iCPk--AYqGX27r2Doa06jFDqeMt31zrulDbwwQgW-_wKtY6VRk-Yb-M8_Dpy-qZFt1GzTObYCa9ovIFqDbt5hUPa_e2EYBesGoDHG4GXz_e4d4ACObJSSxCBt0s9ywgviw-7Oc6aRy6z8u_bqrY315kbQcRI4mFLgWPHgHDZOtEbJDxk-uXdg0DlrecMh2VP1Gfn3JUVCy3TUlc_f-grnQfUWgMrcI0c1jx49rzoVBx7sg0o1jzwQyozYRwKWekS2r-Xi3z1218yfwFNYImaaJgvjbooyvb_gMD3H2Gd6nktLS9hEpaUvJ4rhNiFNgsYuBSg4Pv6u9DPsZyeUDUcUBhX_oh931IVaZj81ZPkfSLuiHWwpNAkhA6KAqn0xl8MSBOMakcY6hZfDSGQwdBBIaCGq_ryRRTDP26Y8LeOt0Kny5qP-b7RcHsiR0gfvrrfqWBE9u9jiMvR5mOnGUKHegSjQpyrSbQO-y-GrX5a_r4_fPWNzyWHXhq88-KfqEegdSxEU4TR4ji2hD1ofhvCQFXstjT6B96YyLxN9855yUzZCjCSFbJ2MVpAnIoVlTiLtLBRTSqkfOI58S5XLw_Bgx98C6cD13XuTs30VnKf28_bDxkyuGqezrTVBjRSSjmKma8KzOpD_97ZO9EZkr2AT5MerN4-_gCbhxthL19HB-RnPHhMHf04KTLmfqojfLeljOobSRMpPkA.X-BZvdm7Nw48ckMrX2cWnA"
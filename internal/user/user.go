@@ -3,9 +3,16 @@ package user
 import (
 	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/goccy/go-json"
@@ -19,6 +26,12 @@ const (
 	headerAuthorization = "Authorization"
 	headerBearer        = "Bearer"
 
+	// maxChallengesPerUser is how many unexpired challenges a user may hold at once, so a
+	// rapid second challenge request does not invalidate the first
+	maxChallengesPerUser = 3
+
+	challengeSweepInterval = time.Minute
+
 	RoleOwner = "owner"
 )
 
@@ -28,6 +41,9 @@ type User struct {
 	Role            string  `json:"role"`
 	CreatedAt       int64   `json:"createdAt"`
 	AvatarStorageID *string `json:"avatarStorageId,omitempty"`
+	// LastSeenAt is when the user last made an authenticated request or WebSocket message,
+	// accurate to LastSeenThrottleSec; nil when never recorded
+	LastSeenAt *int64 `json:"lastSeenAt,omitempty"`
 }
 
 type UserRepository interface {
@@ -35,7 +51,26 @@ type UserRepository interface {
 	GetUserByPublicKey(publicKey string) (*User, error)
 	GetUserByUsername(username string) (*User, error)
 	UpdateUserRole(publicKey string, role string) error
+	UpdateUsername(publicKey string, username string) error
 	UpdateAvatarStorageID(publicKey string, avatarStorageID *string) error
+	UpdateLastSeen(publicKey string, seenAt int64) error
+	// DeleteOrphanedUsers removes non-owner users that are not a member of any application
+	// and were neither created nor last seen since the given unix time, returning the
+	// number removed
+	DeleteOrphanedUsers(inactiveSince int64) (int64, error)
+	// RevokeToken records a JWT ID as revoked until expiresAt
+	RevokeToken(jti string, expiresAt int64) error
+	IsTokenRevoked(jti string) (bool, error)
+	// DeleteExpiredRevokedTokens removes revocations whose token expired before the given unix time
+	DeleteExpiredRevokedTokens(expiredBefore int64) (int64, error)
+	CreateRefreshToken(tokenHash, publicKey string, expiresAt int64) error
+	// ConsumeRefreshToken deletes the refresh token and returns its owner and expiry,
+	// or an empty public key when the token does not exist
+	ConsumeRefreshToken(tokenHash string) (string, int64, error)
+	// DeleteRefreshTokensByPublicKey removes every refresh token issued to the user
+	DeleteRefreshTokensByPublicKey(publicKey string) error
+	// DeleteExpiredRefreshTokens removes refresh tokens that expired before the given unix time
+	DeleteExpiredRefreshTokens(expiredBefore int64) (int64, error)
 }
 
 type UserEndpoints struct {
@@ -44,15 +79,59 @@ type UserEndpoints struct {
 	privateKey     ed25519.PrivateKey
 	publicKey      ed25519.PublicKey
 	userService    *UserService
-	// Add challenge storage for verification
-	challenges map[string]challengeInfo
+	// Add challenge storage for verification, keyed by publicKey, oldest first
+	// fasthttp serves requests concurrently, so every access goes through challengesMu
+	challenges   map[string][]challengeInfo
+	challengesMu *sync.RWMutex
+	// challengeEviction bounds the challenges stored across all users
+	challengeEviction *challengeEviction
+	// challengeLimiter throttles challenge requests per user; nil disables it
+	challengeLimiter *challengeRateLimiter
+	// serverKeyResponse is the encoded server public key response, built once since the
+	// key is fixed for the lifetime of the endpoints
+	serverKeyResponse serverKeyResponse
+	// sweep controls the challenge sweep goroutine
+	sweep *challengeSweep
+}
+
+// challengeSweep signals the challenge sweep goroutine to stop and reports when it has
+type challengeSweep struct {
+	done     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+	started  atomic.Bool
+}
+
+type serverKeyResponse struct {
+	body []byte
+	etag string
 }
 
 type Config struct {
 	MasterPasswordMD5Hash   string
 	RegistrationTokenTTLSec int32
 	JWTExpirationHours      int
+	RefreshTokenTTLHours    int
 	ChallengeTTLSec         int
+	MaxAuthHeaderBytes      int
+	// ClockSkewSec is the clock difference tolerated between clients and the server when
+	// checking the issue time of signed auth and registration tokens
+	ClockSkewSec int
+	// ChallengeRateLimitPerMin is how many challenges a user may request per minute;
+	// zero disables the limit
+	ChallengeRateLimitPerMin int
+	// MaxStoredChallenges bounds the challenges held across all users, evicting those of
+	// the least recently active user first; zero leaves it unbounded
+	MaxStoredChallenges int
+	// LastSeenThrottleSec is the minimum time between two last-seen writes for the same
+	// user; zero writes on every request
+	LastSeenThrottleSec int
+	// ServerKeyCacheMaxAgeSec is how long clients may cache the server public key; zero
+	// makes them revalidate on every use
+	ServerKeyCacheMaxAgeSec int
+	// OrphanedUserGraceDays is how old a user without memberships must be before the
+	// cleanup scheduler removes it. Zero disables the cleanup.
+	OrphanedUserGraceDays int
 }
 
 // JWS claims for user authentication
@@ -76,8 +155,18 @@ type ChallengeResponse struct {
 }
 
 type LoginResponse struct {
-	Token     string `json:"token"`
-	ExpiresAt int64  `json:"expiresAt"`
+	Token                 string `json:"token"`
+	ExpiresAt             int64  `json:"expiresAt"`
+	RefreshToken          string `json:"refreshToken"`
+	RefreshTokenExpiresAt int64  `json:"refreshTokenExpiresAt"`
+}
+
+type UpdateProfileRequest struct {
+	Username string `json:"username"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
 }
 
 type challengeInfo struct {
@@ -88,13 +177,43 @@ type challengeInfo struct {
 var timeNowFunc = time.Now
 
 func NewEndpoints(userRepository UserRepository, config Config, privateKey ed25519.PrivateKey, publicKey ed25519.PublicKey, userService *UserService) *UserEndpoints {
+	var challengeLimiter *challengeRateLimiter
+	if config.ChallengeRateLimitPerMin > 0 {
+		challengeLimiter = newChallengeRateLimiter(config.ChallengeRateLimitPerMin, time.Minute)
+	}
+
 	return &UserEndpoints{
-		userRepository: userRepository,
-		config:         config,
-		privateKey:     privateKey,
-		publicKey:      publicKey,
-		userService:    userService,
-		challenges:     make(map[string]challengeInfo),
+		userRepository:   userRepository,
+		config:           config,
+		privateKey:       privateKey,
+		publicKey:        publicKey,
+		userService:      userService,
+		challenges:       make(map[string][]challengeInfo),
+		challengesMu:     &sync.RWMutex{},
+		challengeLimiter: challengeLimiter,
+
+		challengeEviction: newChallengeEviction(config.MaxStoredChallenges),
+
+		serverKeyResponse: newServerKeyResponse(publicKey),
+
+		sweep: &challengeSweep{
+			done:    make(chan struct{}),
+			stopped: make(chan struct{}),
+		},
+	}
+}
+
+// newServerKeyResponse encodes the server public key response with a validator derived
+// from its content, so a rotated key gets a new ETag
+func newServerKeyResponse(publicKey ed25519.PublicKey) serverKeyResponse {
+	body, _ := json.Marshal(map[string]string{
+		"publicKey": base64.StdEncoding.EncodeToString(publicKey),
+		"algorithm": "ed25519",
+	})
+	hash := sha256.Sum256(body)
+	return serverKeyResponse{
+		body: body,
+		etag: `"` + hex.EncodeToString(hash[:16]) + `"`,
 	}
 }
 
@@ -107,6 +226,12 @@ func (ue UserEndpoints) OwnerRegister(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
+	if ue.isAuthHeaderTooLarge(authHeader) {
+		log.Error().Int("size", len(authHeader)).Msg("Authorization header too large")
+		ctx.Error("Authorization header too large", fasthttp.StatusBadRequest)
+		return
+	}
+
 	jwe, err := owner.ExtractJWEFromAuthorizationHeader(string(authHeader))
 	if err != nil {
 		log.Error().Err(err).Msg("Invalid authorization header")
@@ -121,7 +246,7 @@ func (ue UserEndpoints) OwnerRegister(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	registerJWSClaims, err := owner.VerifyJWS(registerJWEClaims.JWS, ue.config.RegistrationTokenTTLSec)
+	registerJWSClaims, err := owner.VerifyJWS(registerJWEClaims.JWS, ue.config.RegistrationTokenTTLSec, int32(ue.config.ClockSkewSec))
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to verify JWS")
 		ctx.Error("Failed to verify JWS", fasthttp.StatusUnauthorized)
@@ -168,6 +293,11 @@ func (ue UserEndpoints) OwnerRegister(ctx *fasthttp.RequestCtx) {
 	}
 
 	err = ue.userRepository.CreateUser(newUser)
+	if errors.Is(err, ErrUsernameTaken) {
+		log.Error().Err(err).Msg("Owner username taken")
+		ctx.Error("Username is already taken", fasthttp.StatusConflict)
+		return
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create owner")
 		ctx.Error("Failed to create owner", fasthttp.StatusInternalServerError)
@@ -202,6 +332,15 @@ func (ue UserEndpoints) GetChallenge(ctx *fasthttp.RequestCtx) {
 
 	log.Debug().Str("username", user.Username).Str("publicKey", publicKeyStr[:min(50, len(publicKeyStr))]+"...").Msg("[CHALLENGE] User found, generating challenge")
 
+	if ue.challengeLimiter != nil {
+		if allowed, retryAfter := ue.challengeLimiter.allow(publicKeyStr, timeNowFunc()); !allowed {
+			log.Error().Str("publicKey", publicKeyStr[:min(50, len(publicKeyStr))]+"...").Msg("[CHALLENGE] Rate limit exceeded")
+			ctx.Error("Too many challenge requests", fasthttp.StatusTooManyRequests)
+			ctx.Response.Header.Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			return
+		}
+	}
+
 	// Generate random challenge
 	challenge, err := generateChallenge()
 	if err != nil {
@@ -210,13 +349,13 @@ func (ue UserEndpoints) GetChallenge(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	expiresAt := time.Now().Add(time.Duration(ue.config.ChallengeTTLSec) * time.Second)
+	expiresAt := timeNowFunc().Add(time.Duration(ue.config.ChallengeTTLSec) * time.Second)
 
 	// Store challenge for verification (keyed by publicKey)
-	ue.challenges[publicKeyStr] = challengeInfo{
+	ue.storeChallenge(publicKeyStr, challengeInfo{
 		challenge: challenge,
 		expiresAt: expiresAt,
-	}
+	})
 
 	log.Debug().Str("publicKey", publicKeyStr[:min(50, len(publicKeyStr))]+"...").Time("expiresAt", expiresAt).Msg("[CHALLENGE] Challenge generated and stored")
 
@@ -245,6 +384,12 @@ func (ue UserEndpoints) UserAuth(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
+	if ue.isAuthHeaderTooLarge(authHeader) {
+		log.Error().Int("size", len(authHeader)).Msg("[AUTH] Authorization header too large")
+		ctx.Error("Authorization header too large", fasthttp.StatusBadRequest)
+		return
+	}
+
 	jws, err := extractJWSFromAuthorizationHeader(string(authHeader))
 	if err != nil {
 		log.Error().Err(err).Msg("[AUTH] Invalid authorization header")
@@ -281,14 +426,23 @@ func (ue UserEndpoints) UserAuth(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	// Clean up used challenge (keyed by publicKey)
-	delete(ue.challenges, claims.PublicKey)
+	refreshToken, refreshExpiresAt, err := ue.userService.IssueRefreshToken(user)
+	if err != nil {
+		log.Error().Err(err).Msg("[AUTH] Failed to issue refresh token")
+		ctx.Error("Internal server error", fasthttp.StatusInternalServerError)
+		return
+	}
+
+	// Clean up the used challenge and any others issued to the user (keyed by publicKey)
+	ue.removeChallenges(claims.PublicKey)
 
 	log.Debug().Str("username", user.Username).Msg("[AUTH] Authentication successful")
 
 	response := LoginResponse{
-		Token:     token,
-		ExpiresAt: expiresAt,
+		Token:                 token,
+		ExpiresAt:             expiresAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshExpiresAt,
 	}
 
 	ctx.SetStatusCode(fasthttp.StatusOK)
@@ -296,6 +450,11 @@ func (ue UserEndpoints) UserAuth(ctx *fasthttp.RequestCtx) {
 	json.NewEncoder(ctx).Encode(response)
 }
 
+// isAuthHeaderTooLarge reports whether the header exceeds the configured limit (0 disables the check)
+func (ue UserEndpoints) isAuthHeaderTooLarge(authHeader []byte) bool {
+	return ue.config.MaxAuthHeaderBytes > 0 && len(authHeader) > ue.config.MaxAuthHeaderBytes
+}
+
 // VerifyJWT middleware for protecting routes
 func (ue UserEndpoints) VerifyJWT(ctx *fasthttp.RequestCtx) (*User, error) {
 	return ue.userService.ValidateJWTFromRequest(ctx)
@@ -339,13 +498,18 @@ func (ue UserEndpoints) verifyUserAuthJWS(signedJWT string, ttlSec int) (*userAu
 	publicKeyPrefix := claims.PublicKey[:min(50, len(claims.PublicKey))] + "..."
 	log.Debug().Str("publicKey", publicKeyPrefix).Msg("[VERIFY] JWT claims extracted")
 
-	// Check if the JWT is expired
+	// Check if the JWT is expired or issued ahead of the allowed skew
 	var timeNow = timeNowFunc()
 	var issuedAtTime = time.Unix(claims.IssuedAt, 0)
-	if issuedAtTime.Add(time.Duration(ttlSec) * time.Second).Before(timeNow) {
+	var skew = time.Duration(ue.config.ClockSkewSec) * time.Second
+	if issuedAtTime.Add(time.Duration(ttlSec)*time.Second + skew).Before(timeNow) {
 		log.Debug().Str("publicKey", publicKeyPrefix).Msg("[VERIFY] JWT has expired")
 		return nil, fmt.Errorf("JWT has expired")
 	}
+	if issuedAtTime.After(timeNow.Add(skew)) {
+		log.Debug().Str("publicKey", publicKeyPrefix).Msg("[VERIFY] JWT issued in the future")
+		return nil, fmt.Errorf("JWT issued in the future")
+	}
 
 	log.Debug().Str("publicKey", publicKeyPrefix).Msg("[VERIFY] Looking up user in database")
 	// 1. Get the user by public key (unique identifier)
@@ -399,14 +563,17 @@ func (ue UserEndpoints) verifyUserAuthJWS(signedJWT string, ttlSec int) (*userAu
 
 	log.Debug().Str("publicKey", publicKeyPrefix).Msg("[VERIFY] JWT signature verified, checking challenge")
 
-	// 5. Verify that the challenge matches what was issued (keyed by publicKey)
-	storedChallenge, exists := ue.challenges[claims.PublicKey]
+	// 5. Verify that the challenge matches one that was issued (keyed by publicKey)
+	ue.challengesMu.RLock()
+	storedChallenges, exists := ue.challenges[claims.PublicKey]
+	ue.challengesMu.RUnlock()
 	if !exists {
 		log.Error().Str("publicKey", publicKeyPrefix).Msg("[VERIFY] No challenge found for user")
 		return nil, fmt.Errorf("no challenge found for user")
 	}
 
-	if storedChallenge.challenge != claims.Challenge {
+	storedChallenge, found := findChallenge(storedChallenges, claims.Challenge)
+	if !found {
 		log.Error().Str("publicKey", publicKeyPrefix).Msg("[VERIFY] Challenge mismatch")
 		return nil, fmt.Errorf("challenge mismatch")
 	}
@@ -414,7 +581,7 @@ func (ue UserEndpoints) verifyUserAuthJWS(signedJWT string, ttlSec int) (*userAu
 	// Check if challenge has expired
 	if storedChallenge.expiresAt.Before(timeNow) {
 		log.Error().Str("publicKey", publicKeyPrefix).Msg("[VERIFY] Challenge has expired")
-		delete(ue.challenges, claims.PublicKey)
+		ue.storeChallenge(claims.PublicKey) // drops the expired challenge
 		return nil, fmt.Errorf("challenge has expired")
 	}
 
@@ -422,6 +589,122 @@ func (ue UserEndpoints) verifyUserAuthJWS(signedJWT string, ttlSec int) (*userAu
 	return &claims, nil
 }
 
+// storeChallenge adds challenges to the user's outstanding ones after dropping expired
+// ones, keeping only the newest maxChallengesPerUser
+func (ue UserEndpoints) storeChallenge(publicKey string, added ...challengeInfo) {
+	ue.challengesMu.Lock()
+	defer ue.challengesMu.Unlock()
+
+	ue.pruneChallenges(publicKey, timeNowFunc(), added...)
+}
+
+// pruneChallenges does the work of storeChallenge; the caller holds challengesMu
+func (ue UserEndpoints) pruneChallenges(publicKey string, now time.Time, added ...challengeInfo) {
+	kept := make([]challengeInfo, 0, maxChallengesPerUser)
+	for _, info := range append(ue.challenges[publicKey], added...) {
+		if !info.expiresAt.Before(now) {
+			kept = append(kept, info)
+		}
+	}
+	if len(kept) > maxChallengesPerUser {
+		kept = kept[len(kept)-maxChallengesPerUser:]
+	}
+
+	ue.challengeEviction.total += len(kept) - len(ue.challenges[publicKey])
+	if len(kept) == 0 {
+		delete(ue.challenges, publicKey)
+		ue.challengeEviction.forget(publicKey)
+		return
+	}
+	ue.challenges[publicKey] = kept
+
+	if len(added) > 0 {
+		ue.challengeEviction.touch(publicKey)
+		ue.evictChallenges()
+	}
+}
+
+// evictChallenges drops the oldest challenges of the least recently active users until
+// the store is within MaxStoredChallenges; the caller holds challengesMu
+func (ue UserEndpoints) evictChallenges() {
+	for ue.challengeEviction.over() {
+		publicKey, ok := ue.challengeEviction.oldest()
+		if !ok {
+			return
+		}
+		stored := ue.challenges[publicKey]
+		if len(stored) <= 1 {
+			delete(ue.challenges, publicKey)
+			ue.challengeEviction.forget(publicKey)
+		} else {
+			ue.challenges[publicKey] = stored[1:]
+		}
+		ue.challengeEviction.total--
+	}
+}
+
+// removeChallenges drops every challenge issued to the user
+func (ue UserEndpoints) removeChallenges(publicKey string) {
+	ue.challengesMu.Lock()
+	defer ue.challengesMu.Unlock()
+
+	ue.challengeEviction.total -= len(ue.challenges[publicKey])
+	delete(ue.challenges, publicKey)
+	ue.challengeEviction.forget(publicKey)
+}
+
+// StartChallengeSweep periodically removes expired challenges and idle rate limit state,
+// so users who never complete a login do not leak memory
+func (ue UserEndpoints) StartChallengeSweep() {
+	ue.sweep.started.Store(true)
+	go func() {
+		defer close(ue.sweep.stopped)
+
+		ticker := time.NewTicker(challengeSweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ue.sweepChallenges()
+			case <-ue.sweep.done:
+				return
+			}
+		}
+	}()
+}
+
+// StopChallengeSweep stops the challenge sweep and waits for its goroutine to exit. It is
+// safe to call more than once and before StartChallengeSweep.
+func (ue UserEndpoints) StopChallengeSweep() {
+	ue.sweep.stopOnce.Do(func() { close(ue.sweep.done) })
+	if ue.sweep.started.Load() {
+		<-ue.sweep.stopped
+	}
+}
+
+func (ue UserEndpoints) sweepChallenges() {
+	now := timeNowFunc()
+
+	ue.challengesMu.Lock()
+	for publicKey := range ue.challenges {
+		ue.pruneChallenges(publicKey, now)
+	}
+	ue.challengesMu.Unlock()
+
+	if ue.challengeLimiter != nil {
+		ue.challengeLimiter.sweep(now)
+	}
+}
+
+func findChallenge(challenges []challengeInfo, challenge string) (challengeInfo, bool) {
+	for _, info := range challenges {
+		if info.challenge == challenge {
+			return info, true
+		}
+	}
+	return challengeInfo{}, false
+}
+
 func generateChallenge() (string, error) {
 	bytes := make([]byte, 32)
 	_, err := rand.Read(bytes)
@@ -446,14 +729,102 @@ func (ue UserEndpoints) GetProfile(ctx *fasthttp.RequestCtx) {
 	json.NewEncoder(ctx).Encode(authenticatedUser)
 }
 
+// Refresh exchanges a refresh token for a new JWT and a new refresh token
+func (ue UserEndpoints) Refresh(ctx *fasthttp.RequestCtx) {
+	var req RefreshRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil || req.RefreshToken == "" {
+		log.Error().Err(err).Msg("[REFRESH] Missing refresh token")
+		ctx.Error("Refresh token is required", fasthttp.StatusBadRequest)
+		return
+	}
+
+	response, err := ue.userService.RefreshAccessToken(req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, ErrInvalidRefreshToken) {
+			log.Error().Err(err).Msg("[REFRESH] Invalid refresh token")
+			ctx.Error("Invalid refresh token", fasthttp.StatusUnauthorized)
+			return
+		}
+		log.Error().Err(err).Msg("[REFRESH] Failed to refresh token")
+		ctx.Error("Internal server error", fasthttp.StatusInternalServerError)
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(response)
+}
+
+// Logout revokes the bearer token used for the request so it stops working immediately
+func (ue UserEndpoints) Logout(ctx *fasthttp.RequestCtx) {
+	tokenString, err := extractJWTFromAuthorizationHeader(string(ctx.Request.Header.Peek(headerAuthorization)))
+	if err != nil {
+		log.Error().Err(err).Msg("[LOGOUT] Invalid authorization header")
+		ctx.Error("Invalid authorization header", fasthttp.StatusBadRequest)
+		return
+	}
+
+	if err := ue.userService.Logout(tokenString); err != nil {
+		log.Error().Err(err).Msg("[LOGOUT] Failed to revoke token")
+		ctx.Error("Failed to revoke token", fasthttp.StatusInternalServerError)
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusNoContent)
+}
+
+// UpdateProfile changes the authenticated user's username
+func (ue UserEndpoints) UpdateProfile(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("[PROFILE] Unauthorized")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		log.Error().Err(err).Msg("[PROFILE] Invalid request body")
+		ctx.Error("Invalid request body", fasthttp.StatusBadRequest)
+		return
+	}
+
+	updated, err := ue.userService.UpdateUsername(authenticatedUser.PublicKey, req.Username)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidUsername):
+			log.Error().Err(err).Msg("[PROFILE] Invalid username")
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+		case errors.Is(err, ErrUsernameTaken):
+			log.Error().Err(err).Msg("[PROFILE] Username taken")
+			ctx.Error("Username is already taken", fasthttp.StatusConflict)
+		default:
+			log.Error().Err(err).Msg("[PROFILE] Failed to update username")
+			ctx.Error("Failed to update username", fasthttp.StatusInternalServerError)
+		}
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(updated)
+}
+
 // GetServerPublicKey returns the server's Ed25519 public key for JWT verification
 func (ue UserEndpoints) GetServerPublicKey(ctx *fasthttp.RequestCtx) {
-	response := map[string]string{
-		"publicKey": base64.StdEncoding.EncodeToString(ue.publicKey),
-		"algorithm": "ed25519",
+	cacheControl := "no-cache"
+	if ue.config.ServerKeyCacheMaxAgeSec > 0 {
+		cacheControl = "public, max-age=" + strconv.Itoa(ue.config.ServerKeyCacheMaxAgeSec)
+	}
+	ctx.Response.Header.Set("Cache-Control", cacheControl)
+	ctx.Response.Header.Set("ETag", ue.serverKeyResponse.etag)
+
+	if ifNoneMatch := string(ctx.Request.Header.Peek("If-None-Match")); ifNoneMatch == ue.serverKeyResponse.etag || ifNoneMatch == "*" {
+		ctx.SetStatusCode(fasthttp.StatusNotModified)
+		return
 	}
 
 	ctx.SetStatusCode(fasthttp.StatusOK)
 	ctx.SetContentType("application/json")
-	json.NewEncoder(ctx).Encode(response)
+	ctx.SetBody(ue.serverKeyResponse.body)
 }
@@ -0,0 +1,160 @@
+package user
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// OrphanCleanupScheduler periodically removes users that no longer belong to any
+// application, such as members who left every application they joined
+type OrphanCleanupScheduler struct {
+	userService *UserService
+	graceDays   int
+	ticker      *time.Ticker
+	done        chan bool
+}
+
+// NewOrphanCleanupScheduler creates a new orphaned user cleanup scheduler
+func NewOrphanCleanupScheduler(userService *UserService, graceDays int) *OrphanCleanupScheduler {
+	return &OrphanCleanupScheduler{
+		userService: userService,
+		graceDays:   graceDays,
+		done:        make(chan bool),
+	}
+}
+
+// Start runs the cleanup once and then every 24 hours
+func (cs *OrphanCleanupScheduler) Start() {
+	log.Info().
+		Int("graceDays", cs.graceDays).
+		Msg("[USER] Orphaned user cleanup scheduler started")
+
+	cs.ticker = time.NewTicker(24 * time.Hour)
+	go func() {
+		cs.runCleanup()
+		cs.loop()
+	}()
+}
+
+// loop runs the cleanup task on a schedule
+func (cs *OrphanCleanupScheduler) loop() {
+	for {
+		select {
+		case <-cs.ticker.C:
+			cs.runCleanup()
+		case <-cs.done:
+			cs.ticker.Stop()
+			return
+		}
+	}
+}
+
+// runCleanup executes the cleanup task
+func (cs *OrphanCleanupScheduler) runCleanup() {
+	deletedCount, err := cs.userService.CleanupOrphanedUsers(cs.graceDays)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[USER] Failed to cleanup orphaned users")
+		return
+	}
+
+	log.Info().
+		Int64("deletedCount", deletedCount).
+		Msg("[USER] Orphaned user cleanup completed")
+}
+
+// Stop stops the cleanup scheduler
+func (cs *OrphanCleanupScheduler) Stop() {
+	log.Info().Msg("[USER] Stopping orphaned user cleanup scheduler")
+	if cs.ticker != nil {
+		cs.done <- true
+	}
+}
+
+// RunNow executes cleanup immediately
+func (cs *OrphanCleanupScheduler) RunNow() {
+	cs.runCleanup()
+}
+
+// RevokedTokenCleanupScheduler periodically removes revocations of tokens that have
+// expired, since an expired token is rejected regardless, and refresh tokens that can
+// no longer be exchanged
+type RevokedTokenCleanupScheduler struct {
+	userService *UserService
+	ticker      *time.Ticker
+	done        chan bool
+}
+
+// NewRevokedTokenCleanupScheduler creates a new revoked token cleanup scheduler
+func NewRevokedTokenCleanupScheduler(userService *UserService) *RevokedTokenCleanupScheduler {
+	return &RevokedTokenCleanupScheduler{
+		userService: userService,
+		done:        make(chan bool),
+	}
+}
+
+// Start runs the cleanup once and then every hour
+func (cs *RevokedTokenCleanupScheduler) Start() {
+	log.Info().Msg("[USER] Revoked token cleanup scheduler started")
+
+	cs.ticker = time.NewTicker(time.Hour)
+	go func() {
+		cs.runCleanup()
+		cs.loop()
+	}()
+}
+
+// loop runs the cleanup task on a schedule
+func (cs *RevokedTokenCleanupScheduler) loop() {
+	for {
+		select {
+		case <-cs.ticker.C:
+			cs.runCleanup()
+		case <-cs.done:
+			cs.ticker.Stop()
+			return
+		}
+	}
+}
+
+// runCleanup executes the cleanup task
+func (cs *RevokedTokenCleanupScheduler) runCleanup() {
+	deletedCount, err := cs.userService.CleanupRevokedTokens()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[USER] Failed to cleanup revoked tokens")
+		return
+	}
+
+	log.Info().
+		Int64("deletedCount", deletedCount).
+		Msg("[USER] Revoked token cleanup completed")
+
+	deletedRefreshCount, err := cs.userService.CleanupExpiredRefreshTokens()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[USER] Failed to cleanup expired refresh tokens")
+		return
+	}
+
+	log.Info().
+		Int64("deletedCount", deletedRefreshCount).
+		Msg("[USER] Expired refresh token cleanup completed")
+}
+
+// Stop stops the cleanup scheduler
+func (cs *RevokedTokenCleanupScheduler) Stop() {
+	log.Info().Msg("[USER] Stopping revoked token cleanup scheduler")
+	if cs.ticker != nil {
+		cs.done <- true
+	}
+}
+
+// RunNow executes cleanup immediately
+func (cs *RevokedTokenCleanupScheduler) RunNow() {
+	cs.runCleanup()
+}
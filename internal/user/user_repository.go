@@ -2,35 +2,63 @@ package user
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
+
+	"github.com/lib/pq"
 )
 
+const pqUniqueViolation = "23505"
+
+// queryer is the subset of *sql.DB and *sql.Tx the repository runs statements on
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 type userRepository struct {
-	db *sql.DB
+	db queryer
 }
 
 func NewUserRepository(db *sql.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
+// WithTx returns a repository that runs its statements within tx
+func (r *userRepository) WithTx(tx *sql.Tx) UserRepository {
+	return &userRepository{db: tx}
+}
+
+// CreateUser stores the user, returning ErrUsernameTaken when another user has the
+// username. The conflict is detected without failing the statement, so a transaction the
+// repository runs in stays usable.
 func (r *userRepository) CreateUser(user *User) error {
-	_, err := r.db.Exec(
-		"INSERT INTO users (public_key, username, role, created_at) VALUES ($1, $2, $3, $4)",
+	result, err := r.db.Exec(
+		"INSERT INTO users (public_key, username, role, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT (username) DO NOTHING",
 		user.PublicKey, user.Username, user.Role, user.CreatedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
 	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrUsernameTaken
+	}
 	return nil
 }
 
 func (r *userRepository) GetUserByPublicKey(publicKey string) (*User, error) {
 	var user User
 	var avatarStorageID sql.NullString
+	var lastSeenAt sql.NullInt64
 	err := r.db.QueryRow(
-		"SELECT public_key, username, role, created_at, avatar_storage_id FROM users WHERE public_key = $1",
+		"SELECT public_key, username, role, created_at, avatar_storage_id, last_seen_at FROM users WHERE public_key = $1",
 		publicKey,
-	).Scan(&user.PublicKey, &user.Username, &user.Role, &user.CreatedAt, &avatarStorageID)
+	).Scan(&user.PublicKey, &user.Username, &user.Role, &user.CreatedAt, &avatarStorageID, &lastSeenAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -41,16 +69,20 @@ func (r *userRepository) GetUserByPublicKey(publicKey string) (*User, error) {
 	if avatarStorageID.Valid {
 		user.AvatarStorageID = &avatarStorageID.String
 	}
+	if lastSeenAt.Valid {
+		user.LastSeenAt = &lastSeenAt.Int64
+	}
 	return &user, nil
 }
 
 func (r *userRepository) GetUserByUsername(username string) (*User, error) {
 	var user User
 	var avatarStorageID sql.NullString
+	var lastSeenAt sql.NullInt64
 	err := r.db.QueryRow(
-		"SELECT public_key, username, role, created_at, avatar_storage_id FROM users WHERE username = $1",
+		"SELECT public_key, username, role, created_at, avatar_storage_id, last_seen_at FROM users WHERE username = $1",
 		username,
-	).Scan(&user.PublicKey, &user.Username, &user.Role, &user.CreatedAt, &avatarStorageID)
+	).Scan(&user.PublicKey, &user.Username, &user.Role, &user.CreatedAt, &avatarStorageID, &lastSeenAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -61,6 +93,9 @@ func (r *userRepository) GetUserByUsername(username string) (*User, error) {
 	if avatarStorageID.Valid {
 		user.AvatarStorageID = &avatarStorageID.String
 	}
+	if lastSeenAt.Valid {
+		user.LastSeenAt = &lastSeenAt.Int64
+	}
 	return &user, nil
 }
 
@@ -75,6 +110,28 @@ func (r *userRepository) UpdateUserRole(publicKey string, role string) error {
 	return nil
 }
 
+func (r *userRepository) UpdateUsername(publicKey string, username string) error {
+	result, err := r.db.Exec(
+		"UPDATE users SET username = $1 WHERE public_key = $2",
+		username, publicKey,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation {
+			return ErrUsernameTaken
+		}
+		return fmt.Errorf("failed to update username: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("user with public key %s not found", publicKey)
+	}
+	return nil
+}
+
 func (r *userRepository) UpdateAvatarStorageID(publicKey string, avatarStorageID *string) error {
 	result, err := r.db.Exec(
 		"UPDATE users SET avatar_storage_id = $1 WHERE public_key = $2",
@@ -92,3 +149,98 @@ func (r *userRepository) UpdateAvatarStorageID(publicKey string, avatarStorageID
 	}
 	return nil
 }
+
+func (r *userRepository) UpdateLastSeen(publicKey string, seenAt int64) error {
+	_, err := r.db.Exec(
+		"UPDATE users SET last_seen_at = $1 WHERE public_key = $2",
+		seenAt, publicKey,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update last seen: %w", err)
+	}
+	return nil
+}
+
+func (r *userRepository) DeleteOrphanedUsers(inactiveSince int64) (int64, error) {
+	result, err := r.db.Exec(
+		`DELETE FROM users u
+		 WHERE u.role <> $1 AND GREATEST(u.created_at, COALESCE(u.last_seen_at, 0)) < $2
+		   AND NOT EXISTS (SELECT 1 FROM members m WHERE m.public_key = u.public_key)`,
+		RoleOwner, inactiveSince,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete orphaned users: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *userRepository) CreateRefreshToken(tokenHash, publicKey string, expiresAt int64) error {
+	_, err := r.db.Exec(
+		"INSERT INTO refresh_tokens (token_hash, public_key, expires_at, created_at) VALUES ($1, $2, $3, $4)",
+		tokenHash, publicKey, expiresAt, time.Now().Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+func (r *userRepository) ConsumeRefreshToken(tokenHash string) (string, int64, error) {
+	var publicKey string
+	var expiresAt int64
+	err := r.db.QueryRow(
+		"DELETE FROM refresh_tokens WHERE token_hash = $1 RETURNING public_key, expires_at",
+		tokenHash,
+	).Scan(&publicKey, &expiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", 0, nil
+		}
+		return "", 0, fmt.Errorf("failed to consume refresh token: %w", err)
+	}
+	return publicKey, expiresAt, nil
+}
+
+func (r *userRepository) DeleteRefreshTokensByPublicKey(publicKey string) error {
+	_, err := r.db.Exec("DELETE FROM refresh_tokens WHERE public_key = $1", publicKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete refresh tokens: %w", err)
+	}
+	return nil
+}
+
+func (r *userRepository) DeleteExpiredRefreshTokens(expiredBefore int64) (int64, error) {
+	result, err := r.db.Exec("DELETE FROM refresh_tokens WHERE expires_at < $1", expiredBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *userRepository) RevokeToken(jti string, expiresAt int64) error {
+	_, err := r.db.Exec(
+		"INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING",
+		jti, expiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+func (r *userRepository) IsTokenRevoked(jti string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = $1)", jti).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	return exists, nil
+}
+
+func (r *userRepository) DeleteExpiredRevokedTokens(expiredBefore int64) (int64, error) {
+	result, err := r.db.Exec("DELETE FROM revoked_tokens WHERE expires_at < $1", expiredBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired revoked tokens: %w", err)
+	}
+	return result.RowsAffected()
+}
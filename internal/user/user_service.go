@@ -2,19 +2,40 @@ package user
 
 import (
 	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 	"github.com/valyala/fasthttp"
 )
 
+var (
+	ErrTokenRevoked        = errors.New("token has been revoked")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrInvalidUsername     = errors.New("invalid username")
+	ErrUsernameTaken       = errors.New("username already taken")
+)
+
+const (
+	refreshTokenBytes = 32
+	maxUsernameLength = 64
+)
+
 type UserService struct {
 	userRepository UserRepository
 	config         Config
 	privateKey     ed25519.PrivateKey
 	publicKey      ed25519.PublicKey
+	lastSeen       *lastSeenThrottle
 }
 
 func NewUserService(userRepository UserRepository, config Config, privateKey ed25519.PrivateKey, publicKey ed25519.PublicKey) *UserService {
@@ -23,6 +44,19 @@ func NewUserService(userRepository UserRepository, config Config, privateKey ed2
 		config:         config,
 		privateKey:     privateKey,
 		publicKey:      publicKey,
+		lastSeen:       newLastSeenThrottle(time.Duration(config.LastSeenThrottleSec) * time.Second),
+	}
+}
+
+// MarkSeen records that the user is active now. Writes are throttled per user, so it is
+// cheap enough to call on every request.
+func (us *UserService) MarkSeen(publicKey string) {
+	now := time.Now()
+	if !us.lastSeen.due(publicKey, now) {
+		return
+	}
+	if err := us.userRepository.UpdateLastSeen(publicKey, now.Unix()); err != nil {
+		log.Warn().Err(err).Str("publicKey", publicKey[:min(20, len(publicKey))]+"...").Msg("[USER] Failed to update last seen")
 	}
 }
 
@@ -48,6 +82,7 @@ func (us *UserService) GenerateJWT(user *User) (string, int64, error) {
 		Username:      user.Username,
 		Role:          user.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			ExpiresAt: jwt.NewNumericDate(time.Unix(expiresAt, 0)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
@@ -63,26 +98,191 @@ func (us *UserService) GenerateJWT(user *User) (string, int64, error) {
 	return tokenString, expiresAt, nil
 }
 
-func (us *UserService) ValidateJWT(tokenString string) (*User, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
+// parseJWT verifies the token's signature and time claims. exp and nbf are checked with the
+// configured clock skew as leeway, and an iat further in the future than the skew is
+// rejected so tokens minted with a manipulated clock do not validate.
+func (us *UserService) parseJWT(tokenString string, claims *JWTClaims) (*jwt.Token, error) {
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
 		return us.publicKey, nil
-	})
+	}
+	leeway := time.Duration(us.config.ClockSkewSec) * time.Second
+	return jwt.ParseWithClaims(tokenString, claims, keyFunc, jwt.WithLeeway(leeway), jwt.WithIssuedAt())
+}
+
+func (us *UserService) ValidateJWT(tokenString string) (*User, error) {
+	token, err := us.parseJWT(tokenString, &JWTClaims{})
 
 	if err != nil {
 		return nil, err
 	}
 
 	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
+		if claims.ID != "" {
+			revoked, err := us.userRepository.IsTokenRevoked(claims.ID)
+			if err != nil {
+				return nil, err
+			}
+			if revoked {
+				return nil, ErrTokenRevoked
+			}
+		}
+
 		user, err := us.userRepository.GetUserByPublicKey(claims.UserPublicKey)
 		if err != nil {
 			return nil, err
 		}
+		// The user may have been removed by orphaned user cleanup after the token was issued
+		if user == nil {
+			return nil, fmt.Errorf("user no longer exists")
+		}
 		return user, nil
 	}
 
 	return nil, fmt.Errorf("invalid token")
 }
 
+// UpdateUsername changes the user's username after checking that no other user has it
+func (us *UserService) UpdateUsername(publicKey, username string) (*User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, fmt.Errorf("%w: username cannot be empty", ErrInvalidUsername)
+	}
+	if utf8.RuneCountInString(username) > maxUsernameLength {
+		return nil, fmt.Errorf("%w: username cannot be longer than %d characters", ErrInvalidUsername, maxUsernameLength)
+	}
+
+	existing, err := us.userRepository.GetUserByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.PublicKey != publicKey {
+		return nil, ErrUsernameTaken
+	}
+
+	if err := us.userRepository.UpdateUsername(publicKey, username); err != nil {
+		return nil, err
+	}
+	return us.userRepository.GetUserByPublicKey(publicKey)
+}
+
+// UserCreator stores new users
+type UserCreator interface {
+	CreateUser(user *User) error
+}
+
+// CreateMemberUser creates the account of a user the server adds as a member. Member names
+// need not be unique, so when another user already has the name, the start of the
+// member's public key is appended to it.
+func CreateMemberUser(users UserCreator, member *User) error {
+	err := users.CreateUser(member)
+	if errors.Is(err, ErrUsernameTaken) {
+		member.Username = member.Username + "-" + member.PublicKey[:min(8, len(member.PublicKey))]
+		err = users.CreateUser(member)
+	}
+	return err
+}
+
+// CleanupOrphanedUsers removes non-owner users without any application membership who
+// have not been active for graceDays. Activity is the account's creation or the user's
+// last authenticated request, so a member who leaves keeps the account for at least
+// graceDays after last using it.
+func (us *UserService) CleanupOrphanedUsers(graceDays int) (int64, error) {
+	cutoff := time.Now().Add(-time.Duration(graceDays) * 24 * time.Hour).Unix()
+	return us.userRepository.DeleteOrphanedUsers(cutoff)
+}
+
+// IssueRefreshToken creates a refresh token for the user. Only its hash is stored, so the
+// returned token cannot be recovered from the database.
+func (us *UserService) IssueRefreshToken(user *User) (string, int64, error) {
+	raw := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", 0, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(raw)
+	expiresAt := time.Now().Add(time.Duration(us.config.RefreshTokenTTLHours) * time.Hour).Unix()
+
+	if err := us.userRepository.CreateRefreshToken(hashRefreshToken(refreshToken), user.PublicKey, expiresAt); err != nil {
+		return "", 0, err
+	}
+	return refreshToken, expiresAt, nil
+}
+
+// RefreshAccessToken exchanges a refresh token for a new JWT. The refresh token is
+// consumed and replaced by a new one, so each refresh token works only once.
+func (us *UserService) RefreshAccessToken(refreshToken string) (*LoginResponse, error) {
+	publicKey, expiresAt, err := us.userRepository.ConsumeRefreshToken(hashRefreshToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if publicKey == "" {
+		return nil, fmt.Errorf("%w: unknown or already used", ErrInvalidRefreshToken)
+	}
+	if expiresAt < time.Now().Unix() {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidRefreshToken)
+	}
+
+	user, err := us.userRepository.GetUserByPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("%w: user no longer exists", ErrInvalidRefreshToken)
+	}
+
+	token, tokenExpiresAt, err := us.GenerateJWT(user)
+	if err != nil {
+		return nil, err
+	}
+	newRefreshToken, newRefreshExpiresAt, err := us.IssueRefreshToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		Token:                 token,
+		ExpiresAt:             tokenExpiresAt,
+		RefreshToken:          newRefreshToken,
+		RefreshTokenExpiresAt: newRefreshExpiresAt,
+	}, nil
+}
+
+func hashRefreshToken(refreshToken string) string {
+	hash := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(hash[:])
+}
+
+// Logout revokes the given token so it is rejected from now on, along with every
+// refresh token of its user so the session cannot be renewed. The revocation is kept
+// until the token would have expired on its own.
+func (us *UserService) Logout(tokenString string) error {
+	claims := &JWTClaims{}
+	_, err := us.parseJWT(tokenString, claims)
+	if err != nil {
+		return err
+	}
+	if claims.ID == "" {
+		return fmt.Errorf("token has no jti claim")
+	}
+	if claims.ExpiresAt == nil {
+		return fmt.Errorf("token has no exp claim")
+	}
+
+	if err := us.userRepository.RevokeToken(claims.ID, claims.ExpiresAt.Unix()); err != nil {
+		return err
+	}
+	return us.userRepository.DeleteRefreshTokensByPublicKey(claims.UserPublicKey)
+}
+
+// CleanupRevokedTokens removes revocations of tokens that have already expired
+func (us *UserService) CleanupRevokedTokens() (int64, error) {
+	return us.userRepository.DeleteExpiredRevokedTokens(time.Now().Unix())
+}
+
+// CleanupExpiredRefreshTokens removes refresh tokens that have already expired
+func (us *UserService) CleanupExpiredRefreshTokens() (int64, error) {
+	return us.userRepository.DeleteExpiredRefreshTokens(time.Now().Unix())
+}
+
 func extractJWTFromAuthorizationHeader(authHeader string) (string, error) {
 	parts := strings.Split(authHeader, " ")
 	if len(parts) != 2 || parts[0] != headerBearer {
@@ -1,24 +1,49 @@
 package user
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
 )
 
 // mockUserRepository for testing
 type mockUserRepository struct {
+	mu              sync.Mutex
 	users           map[string]*User
 	updateRoleCalls []struct {
 		publicKey string
 		role      string
 	}
+	deleteOrphanedBefore int64
+	revokedTokens        map[string]int64
+	deleteRevokedBefore  int64
+	refreshTokens        map[string]mockRefreshToken
+	deleteRefreshBefore  int64
+	getUserErr           error
+	getByUsernameErr     error
+	lastSeenUpdates      int
+}
+
+type mockRefreshToken struct {
+	publicKey string
+	expiresAt int64
 }
 
 func newMockUserRepository() *mockUserRepository {
 	return &mockUserRepository{
-		users:           make(map[string]*User),
+		users:         make(map[string]*User),
+		revokedTokens: make(map[string]int64),
+		refreshTokens: make(map[string]mockRefreshToken),
 		updateRoleCalls: []struct {
 			publicKey string
 			role      string
@@ -35,20 +60,22 @@ func (m *mockUserRepository) CreateUser(user *User) error {
 }
 
 func (m *mockUserRepository) GetUserByPublicKey(publicKey string) (*User, error) {
-	user, exists := m.users[publicKey]
-	if !exists {
-		return nil, fmt.Errorf("user not found")
+	if m.getUserErr != nil {
+		return nil, m.getUserErr
 	}
-	return user, nil
+	return m.users[publicKey], nil
 }
 
 func (m *mockUserRepository) GetUserByUsername(username string) (*User, error) {
+	if m.getByUsernameErr != nil {
+		return nil, m.getByUsernameErr
+	}
 	for _, user := range m.users {
 		if user.Username == username {
 			return user, nil
 		}
 	}
-	return nil, fmt.Errorf("user not found")
+	return nil, nil
 }
 
 func (m *mockUserRepository) UpdateUserRole(publicKey string, role string) error {
@@ -65,6 +92,88 @@ func (m *mockUserRepository) UpdateUserRole(publicKey string, role string) error
 	return nil
 }
 
+func (m *mockUserRepository) UpdateUsername(publicKey string, username string) error {
+	user, exists := m.users[publicKey]
+	if !exists {
+		return fmt.Errorf("user not found")
+	}
+	user.Username = username
+	return nil
+}
+
+func (m *mockUserRepository) UpdateAvatarStorageID(publicKey string, avatarStorageID *string) error {
+	user, exists := m.users[publicKey]
+	if !exists {
+		return fmt.Errorf("user not found")
+	}
+	user.AvatarStorageID = avatarStorageID
+	return nil
+}
+
+func (m *mockUserRepository) UpdateLastSeen(publicKey string, seenAt int64) error {
+	m.lastSeenUpdates++
+	user, exists := m.users[publicKey]
+	if !exists {
+		return fmt.Errorf("user not found")
+	}
+	user.LastSeenAt = &seenAt
+	return nil
+}
+
+func (m *mockUserRepository) DeleteOrphanedUsers(inactiveSince int64) (int64, error) {
+	m.deleteOrphanedBefore = inactiveSince
+	return 0, nil
+}
+
+func (m *mockUserRepository) RevokeToken(jti string, expiresAt int64) error {
+	m.revokedTokens[jti] = expiresAt
+	return nil
+}
+
+func (m *mockUserRepository) IsTokenRevoked(jti string) (bool, error) {
+	_, revoked := m.revokedTokens[jti]
+	return revoked, nil
+}
+
+func (m *mockUserRepository) DeleteExpiredRevokedTokens(expiredBefore int64) (int64, error) {
+	m.deleteRevokedBefore = expiredBefore
+	return 0, nil
+}
+
+func (m *mockUserRepository) CreateRefreshToken(tokenHash, publicKey string, expiresAt int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refreshTokens[tokenHash] = mockRefreshToken{publicKey: publicKey, expiresAt: expiresAt}
+	return nil
+}
+
+func (m *mockUserRepository) ConsumeRefreshToken(tokenHash string) (string, int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, exists := m.refreshTokens[tokenHash]
+	if !exists {
+		return "", 0, nil
+	}
+	delete(m.refreshTokens, tokenHash)
+	return token.publicKey, token.expiresAt, nil
+}
+
+func (m *mockUserRepository) DeleteRefreshTokensByPublicKey(publicKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for tokenHash, token := range m.refreshTokens {
+		if token.publicKey == publicKey {
+			delete(m.refreshTokens, tokenHash)
+		}
+	}
+	return nil
+}
+
+func (m *mockUserRepository) DeleteExpiredRefreshTokens(expiredBefore int64) (int64, error) {
+	m.deleteRefreshBefore = expiredBefore
+	return 0, nil
+}
+
 func TestGenerateChallenge_ShouldGenerateUniqueChallenge(t *testing.T) {
 	// when
 	challenge1, err1 := generateChallenge()
@@ -186,3 +295,702 @@ func TestUpdateUserRole_ShouldAllowMultipleRoleChanges(t *testing.T) {
 	assert.Equal(t, "member", finalUser.Role)
 	assert.Len(t, repo.updateRoleCalls, 2)
 }
+
+func TestOwnerRegister_ShouldRejectOversizedAuthorizationHeader(t *testing.T) {
+	// given
+	endpoints := NewEndpoints(nil, Config{MaxAuthHeaderBytes: 64}, nil, nil, nil)
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.Set(headerAuthorization, "Bearer "+strings.Repeat("a", 128))
+
+	// when
+	endpoints.OwnerRegister(ctx)
+
+	// then
+	assert.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+	assert.Contains(t, string(ctx.Response.Body()), "Authorization header too large")
+}
+
+func TestUserAuth_ShouldRejectOversizedAuthorizationHeader(t *testing.T) {
+	// given
+	endpoints := NewEndpoints(nil, Config{MaxAuthHeaderBytes: 64}, nil, nil, nil)
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.Set(headerAuthorization, "Bearer "+strings.Repeat("a", 128))
+
+	// when
+	endpoints.UserAuth(ctx)
+
+	// then
+	assert.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+	assert.Contains(t, string(ctx.Response.Body()), "Authorization header too large")
+}
+
+func TestUserAuth_ShouldAcceptHeaderWithinLimit(t *testing.T) {
+	// given
+	endpoints := NewEndpoints(nil, Config{MaxAuthHeaderBytes: 64}, nil, nil, nil)
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.Set(headerAuthorization, "InvalidFormat")
+
+	// when
+	endpoints.UserAuth(ctx)
+
+	// then
+	assert.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+	assert.Contains(t, string(ctx.Response.Body()), "Invalid authorization header")
+}
+
+func TestGetServerPublicKey_ShouldServeStableBodyWithCacheHeaders(t *testing.T) {
+	// given
+	serverPublicKey, _, _ := ed25519.GenerateKey(nil)
+	endpoints := NewEndpoints(nil, Config{ServerKeyCacheMaxAgeSec: 3600}, nil, serverPublicKey, nil)
+	first := &fasthttp.RequestCtx{}
+	second := &fasthttp.RequestCtx{}
+
+	// when
+	endpoints.GetServerPublicKey(first)
+	endpoints.GetServerPublicKey(second)
+
+	// then
+	assert.Equal(t, fasthttp.StatusOK, first.Response.StatusCode())
+	assert.Equal(t, "public, max-age=3600", string(first.Response.Header.Peek("Cache-Control")))
+	assert.NotEmpty(t, first.Response.Header.Peek("ETag"))
+	assert.Equal(t, first.Response.Body(), second.Response.Body())
+	assert.Equal(t, first.Response.Header.Peek("ETag"), second.Response.Header.Peek("ETag"))
+
+	var response map[string]string
+	assert.NoError(t, json.Unmarshal(first.Response.Body(), &response))
+	assert.Equal(t, base64.StdEncoding.EncodeToString(serverPublicKey), response["publicKey"])
+}
+
+func TestGetServerPublicKey_ShouldReturnNotModifiedForMatchingETag(t *testing.T) {
+	// given
+	serverPublicKey, _, _ := ed25519.GenerateKey(nil)
+	endpoints := NewEndpoints(nil, Config{}, nil, serverPublicKey, nil)
+	first := &fasthttp.RequestCtx{}
+	endpoints.GetServerPublicKey(first)
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.Set("If-None-Match", string(first.Response.Header.Peek("ETag")))
+
+	// when
+	endpoints.GetServerPublicKey(ctx)
+
+	// then
+	assert.Equal(t, fasthttp.StatusNotModified, ctx.Response.StatusCode())
+	assert.Equal(t, "no-cache", string(ctx.Response.Header.Peek("Cache-Control")))
+	assert.Empty(t, ctx.Response.Body())
+}
+
+func patchProfile(endpoints *UserEndpoints, authenticated *User, body string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("PATCH")
+	ctx.Request.SetBodyString(body)
+	ctx.SetUserValue("user", authenticated)
+	endpoints.UpdateProfile(ctx)
+	return ctx
+}
+
+func createProfileTestEndpoints() (*UserEndpoints, *mockUserRepository) {
+	repo := newMockUserRepository()
+	repo.users["alice-public-key"] = &User{PublicKey: "alice-public-key", Username: "alice", Role: "member"}
+	repo.users["bob-public-key"] = &User{PublicKey: "bob-public-key", Username: "bob", Role: "member"}
+	return NewEndpoints(repo, Config{}, nil, nil, NewUserService(repo, Config{}, nil, nil)), repo
+}
+
+func TestUpdateProfile_ShouldChangeUsername(t *testing.T) {
+	// given
+	endpoints, repo := createProfileTestEndpoints()
+
+	// when
+	ctx := patchProfile(endpoints, repo.users["alice-public-key"], `{"username": "  alicia "}`)
+
+	// then
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	var updated User
+	assert.NoError(t, json.Unmarshal(ctx.Response.Body(), &updated))
+	assert.Equal(t, "alicia", updated.Username)
+	assert.Equal(t, "alicia", repo.users["alice-public-key"].Username)
+}
+
+func TestUpdateProfile_ShouldRejectTakenUsername(t *testing.T) {
+	// given
+	endpoints, repo := createProfileTestEndpoints()
+
+	// when
+	ctx := patchProfile(endpoints, repo.users["alice-public-key"], `{"username": "bob"}`)
+
+	// then
+	assert.Equal(t, fasthttp.StatusConflict, ctx.Response.StatusCode())
+	assert.Equal(t, "alice", repo.users["alice-public-key"].Username)
+}
+
+func TestUpdateProfile_ShouldRejectInvalidUsernames(t *testing.T) {
+	// given
+	endpoints, repo := createProfileTestEndpoints()
+
+	for _, username := range []string{"", "   ", strings.Repeat("a", 65)} {
+		// when
+		ctx := patchProfile(endpoints, repo.users["alice-public-key"], fmt.Sprintf(`{"username": %q}`, username))
+
+		// then
+		assert.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode(), "username %q", username)
+	}
+	assert.Equal(t, "alice", repo.users["alice-public-key"].Username)
+}
+
+func TestUpdateProfile_ShouldFailWhenUsernameLookupFails(t *testing.T) {
+	// given
+	endpoints, repo := createProfileTestEndpoints()
+	repo.getByUsernameErr = errors.New("connection refused")
+
+	// when
+	ctx := patchProfile(endpoints, repo.users["alice-public-key"], `{"username": "alicia"}`)
+
+	// then
+	assert.Equal(t, fasthttp.StatusInternalServerError, ctx.Response.StatusCode())
+	assert.Equal(t, "alice", repo.users["alice-public-key"].Username)
+}
+
+// usernameCreator stores users, refusing usernames that are already taken
+type usernameCreator map[string]*User
+
+func (c usernameCreator) CreateUser(user *User) error {
+	if _, taken := c[user.Username]; taken {
+		return ErrUsernameTaken
+	}
+	copied := *user
+	c[user.Username] = &copied
+	return nil
+}
+
+func TestCreateMemberUser_ShouldDisambiguateTakenUsername(t *testing.T) {
+	// given
+	users := usernameCreator{"alice": {PublicKey: "first-public-key", Username: "alice"}}
+
+	// when
+	err := CreateMemberUser(users, &User{PublicKey: "second-public-key", Username: "alice", Role: "member"})
+
+	// then
+	assert.NoError(t, err)
+	if assert.Contains(t, users, "alice-second-p") {
+		assert.Equal(t, "second-public-key", users["alice-second-p"].PublicKey)
+	}
+}
+
+func TestCleanupOrphanedUsers_ShouldOnlyTargetUsersOlderThanGracePeriod(t *testing.T) {
+	// given
+	repo := newMockUserRepository()
+	service := NewUserService(repo, Config{}, nil, nil)
+	expectedCutoff := time.Now().Add(-30 * 24 * time.Hour).Unix()
+
+	// when
+	_, err := service.CleanupOrphanedUsers(30)
+
+	// then
+	assert.NoError(t, err)
+	assert.InDelta(t, expectedCutoff, repo.deleteOrphanedBefore, 5)
+}
+
+func TestMarkSeen_ShouldRecordLastSeenTime(t *testing.T) {
+	// given
+	repo := newMockUserRepository()
+	repo.users["alice-public-key"] = &User{PublicKey: "alice-public-key", Username: "alice"}
+	service := NewUserService(repo, Config{LastSeenThrottleSec: 300}, nil, nil)
+
+	// when
+	service.MarkSeen("alice-public-key")
+
+	// then
+	lastSeenAt := repo.users["alice-public-key"].LastSeenAt
+	if assert.NotNil(t, lastSeenAt) {
+		assert.InDelta(t, time.Now().Unix(), *lastSeenAt, 5)
+	}
+}
+
+func TestMarkSeen_ShouldThrottleWritesPerUser(t *testing.T) {
+	// given
+	repo := newMockUserRepository()
+	repo.users["alice-public-key"] = &User{PublicKey: "alice-public-key", Username: "alice"}
+	repo.users["bob-public-key"] = &User{PublicKey: "bob-public-key", Username: "bob"}
+	service := NewUserService(repo, Config{LastSeenThrottleSec: 300}, nil, nil)
+
+	// when
+	for i := 0; i < 10; i++ {
+		service.MarkSeen("alice-public-key")
+	}
+	service.MarkSeen("bob-public-key")
+
+	// then
+	assert.Equal(t, 2, repo.lastSeenUpdates)
+}
+
+func TestLastSeenThrottle_ShouldAllowWriteAfterInterval(t *testing.T) {
+	// given
+	throttle := newLastSeenThrottle(time.Minute)
+	start := time.Now()
+	throttle.due("alice-public-key", start)
+
+	// when
+	tooSoon := throttle.due("alice-public-key", start.Add(59*time.Second))
+	afterInterval := throttle.due("alice-public-key", start.Add(time.Minute))
+
+	// then
+	assert.False(t, tooSoon)
+	assert.True(t, afterInterval)
+}
+
+func TestGenerateJWT_ShouldIncludeUniqueJTI(t *testing.T) {
+	// given
+	serverPublicKey, serverPrivateKey, _ := ed25519.GenerateKey(nil)
+	service := NewUserService(newMockUserRepository(), Config{JWTExpirationHours: 1}, serverPrivateKey, serverPublicKey)
+	u := &User{PublicKey: "user-public-key-0123456789", Username: "alice", Role: "user"}
+
+	// when
+	token1, _, err1 := service.GenerateJWT(u)
+	token2, _, err2 := service.GenerateJWT(u)
+
+	// then
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	claims1 := &JWTClaims{}
+	claims2 := &JWTClaims{}
+	_, _, _ = jwt.NewParser().ParseUnverified(token1, claims1)
+	_, _, _ = jwt.NewParser().ParseUnverified(token2, claims2)
+	assert.NotEmpty(t, claims1.ID)
+	assert.NotEqual(t, claims1.ID, claims2.ID)
+}
+
+func signSessionJWT(privateKey ed25519.PrivateKey, publicKey string, issuedAt, notBefore time.Time) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, JWTClaims{
+		UserPublicKey: publicKey,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			NotBefore: jwt.NewNumericDate(notBefore),
+		},
+	})
+	signed, _ := token.SignedString(privateKey)
+	return signed
+}
+
+func TestValidateJWT_ShouldApplyClockSkewToIssuedAtAndNotBefore(t *testing.T) {
+	// given
+	serverPublicKey, serverPrivateKey, _ := ed25519.GenerateKey(nil)
+	repo := newMockUserRepository()
+	repo.users["user-public-key-0123456789"] = &User{PublicKey: "user-public-key-0123456789", Username: "alice"}
+	service := NewUserService(repo, Config{ClockSkewSec: 5}, serverPrivateKey, serverPublicKey)
+	now := time.Now()
+
+	// when
+	_, farFutureIssuedErr := service.ValidateJWT(signSessionJWT(serverPrivateKey, "user-public-key-0123456789", now.Add(time.Hour), now))
+	_, futureNotBeforeErr := service.ValidateJWT(signSessionJWT(serverPrivateKey, "user-public-key-0123456789", now, now.Add(time.Minute)))
+	_, withinSkewErr := service.ValidateJWT(signSessionJWT(serverPrivateKey, "user-public-key-0123456789", now.Add(3*time.Second), now.Add(3*time.Second)))
+
+	// then
+	assert.ErrorIs(t, farFutureIssuedErr, jwt.ErrTokenUsedBeforeIssued)
+	assert.ErrorIs(t, futureNotBeforeErr, jwt.ErrTokenNotValidYet)
+	assert.NoError(t, withinSkewErr)
+}
+
+func TestLogout_ShouldRejectRevokedTokenImmediately(t *testing.T) {
+	// given
+	serverPublicKey, serverPrivateKey, _ := ed25519.GenerateKey(nil)
+	repo := newMockUserRepository()
+	u := &User{PublicKey: "user-public-key-0123456789", Username: "alice", Role: "user"}
+	repo.users[u.PublicKey] = u
+	service := NewUserService(repo, Config{JWTExpirationHours: 1}, serverPrivateKey, serverPublicKey)
+	revokedToken, expiresAt, _ := service.GenerateJWT(u)
+	otherToken, _, _ := service.GenerateJWT(u)
+
+	// when
+	err := service.Logout(revokedToken)
+
+	// then
+	assert.NoError(t, err)
+	_, err = service.ValidateJWT(revokedToken)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+	_, err = service.ValidateJWT(otherToken)
+	assert.NoError(t, err)
+	assert.Len(t, repo.revokedTokens, 1)
+	for _, revokedUntil := range repo.revokedTokens {
+		assert.Equal(t, expiresAt, revokedUntil)
+	}
+}
+
+func TestLogoutEndpoint_ShouldRevokeBearerToken(t *testing.T) {
+	// given
+	serverPublicKey, serverPrivateKey, _ := ed25519.GenerateKey(nil)
+	repo := newMockUserRepository()
+	u := &User{PublicKey: "user-public-key-0123456789", Username: "alice", Role: "user"}
+	repo.users[u.PublicKey] = u
+	service := NewUserService(repo, Config{JWTExpirationHours: 1}, serverPrivateKey, serverPublicKey)
+	endpoints := NewEndpoints(repo, Config{}, serverPrivateKey, serverPublicKey, service)
+	token, _, _ := service.GenerateJWT(u)
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.Set(headerAuthorization, headerBearer+" "+token)
+
+	// when
+	endpoints.Logout(ctx)
+
+	// then
+	assert.Equal(t, fasthttp.StatusNoContent, ctx.Response.StatusCode())
+	_, err := service.ValidateJWTFromRequest(ctx)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+}
+
+func TestCleanupRevokedTokens_ShouldTargetExpiredRevocations(t *testing.T) {
+	// given
+	repo := newMockUserRepository()
+	service := NewUserService(repo, Config{}, nil, nil)
+
+	// when
+	_, err := service.CleanupRevokedTokens()
+
+	// then
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Now().Unix(), repo.deleteRevokedBefore, 5)
+}
+
+func TestCleanupExpiredRefreshTokens_ShouldTargetExpiredTokens(t *testing.T) {
+	// given
+	repo := newMockUserRepository()
+	service := NewUserService(repo, Config{}, nil, nil)
+
+	// when
+	_, err := service.CleanupExpiredRefreshTokens()
+
+	// then
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Now().Unix(), repo.deleteRefreshBefore, 5)
+}
+
+func TestLogout_ShouldRevokeRefreshTokensOfUser(t *testing.T) {
+	// given
+	service, repo, u := createRefreshTestService()
+	token, _, _ := service.GenerateJWT(u)
+	refreshToken, _, _ := service.IssueRefreshToken(u)
+	otherRefreshToken, _, _ := service.IssueRefreshToken(u)
+
+	// when
+	err := service.Logout(token)
+
+	// then
+	assert.NoError(t, err)
+	assert.Empty(t, repo.refreshTokens)
+	_, err = service.RefreshAccessToken(refreshToken)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	_, err = service.RefreshAccessToken(otherRefreshToken)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+}
+
+func createRefreshTestService() (*UserService, *mockUserRepository, *User) {
+	serverPublicKey, serverPrivateKey, _ := ed25519.GenerateKey(nil)
+	repo := newMockUserRepository()
+	u := &User{PublicKey: "user-public-key-0123456789", Username: "alice", Role: "user"}
+	repo.users[u.PublicKey] = u
+	service := NewUserService(repo, Config{JWTExpirationHours: 1, RefreshTokenTTLHours: 24}, serverPrivateKey, serverPublicKey)
+	return service, repo, u
+}
+
+func TestRefreshAccessToken_ShouldRotateRefreshToken(t *testing.T) {
+	// given
+	service, repo, u := createRefreshTestService()
+	refreshToken, _, _ := service.IssueRefreshToken(u)
+
+	// when
+	response, err := service.RefreshAccessToken(refreshToken)
+
+	// then
+	assert.NoError(t, err)
+	validated, err := service.ValidateJWT(response.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, u.PublicKey, validated.PublicKey)
+	assert.NotEqual(t, refreshToken, response.RefreshToken)
+	assert.Len(t, repo.refreshTokens, 1)
+	assert.NotContains(t, repo.refreshTokens, response.RefreshToken)
+
+	_, err = service.RefreshAccessToken(refreshToken)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	_, err = service.RefreshAccessToken(response.RefreshToken)
+	assert.NoError(t, err)
+}
+
+func TestRefreshAccessToken_ShouldRejectTokenOfDeletedUser(t *testing.T) {
+	// given
+	service, repo, u := createRefreshTestService()
+	refreshToken, _, _ := service.IssueRefreshToken(u)
+	delete(repo.users, u.PublicKey)
+
+	// when
+	response, err := service.RefreshAccessToken(refreshToken)
+
+	// then
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	assert.Nil(t, response)
+	assert.Empty(t, repo.refreshTokens)
+}
+
+func TestRefreshEndpoint_ShouldReturnInternalErrorWhenUserLookupFails(t *testing.T) {
+	// given
+	service, repo, u := createRefreshTestService()
+	refreshToken, _, _ := service.IssueRefreshToken(u)
+	repo.getUserErr = fmt.Errorf("failed to get user by public key: connection refused")
+	endpoints := NewEndpoints(repo, Config{}, nil, nil, service)
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetBodyString(`{"refreshToken":"` + refreshToken + `"}`)
+
+	// when
+	endpoints.Refresh(ctx)
+
+	// then
+	assert.Equal(t, fasthttp.StatusInternalServerError, ctx.Response.StatusCode())
+}
+
+func TestRefreshAccessToken_ShouldRejectExpiredToken(t *testing.T) {
+	// given
+	service, repo, u := createRefreshTestService()
+	refreshToken, _, _ := service.IssueRefreshToken(u)
+	for hash, token := range repo.refreshTokens {
+		token.expiresAt = time.Now().Add(-time.Minute).Unix()
+		repo.refreshTokens[hash] = token
+	}
+
+	// when
+	_, err := service.RefreshAccessToken(refreshToken)
+
+	// then
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+}
+
+func TestRefreshEndpoint_ShouldRejectUnknownRefreshToken(t *testing.T) {
+	// given
+	service, repo, _ := createRefreshTestService()
+	endpoints := NewEndpoints(repo, Config{}, nil, nil, service)
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetBodyString(`{"refreshToken":"unknown"}`)
+
+	// when
+	endpoints.Refresh(ctx)
+
+	// then
+	assert.Equal(t, fasthttp.StatusUnauthorized, ctx.Response.StatusCode())
+}
+
+func requestChallenge(t *testing.T, endpoints *UserEndpoints, publicKey string) string {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.QueryArgs().Set("publicKey", publicKey)
+	endpoints.GetChallenge(ctx)
+
+	var response ChallengeResponse
+	if err := json.Unmarshal(ctx.Response.Body(), &response); err != nil {
+		t.Fatalf("failed to decode challenge response: %v", err)
+	}
+	return response.Challenge
+}
+
+func signUserAuthJWS(privateKey ed25519.PrivateKey, publicKey, challenge string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{
+		"publicKey": publicKey,
+		"challenge": challenge,
+		"iat":       time.Now().Unix(),
+	})
+	signed, _ := token.SignedString(privateKey)
+	return signed
+}
+
+func TestVerifyUserAuthJWS_ShouldAcceptEitherOfTwoRapidChallenges(t *testing.T) {
+	// given
+	userPublicKey, userPrivateKey, _ := ed25519.GenerateKey(nil)
+	publicKey := base64.StdEncoding.EncodeToString(userPublicKey)
+	repo := newMockUserRepository()
+	repo.users[publicKey] = &User{PublicKey: publicKey, Username: "user"}
+	endpoints := NewEndpoints(repo, Config{ChallengeTTLSec: 300}, nil, nil, nil)
+
+	firstChallenge := requestChallenge(t, endpoints, publicKey)
+	secondChallenge := requestChallenge(t, endpoints, publicKey)
+
+	// when
+	firstClaims, firstErr := endpoints.verifyUserAuthJWS(signUserAuthJWS(userPrivateKey, publicKey, firstChallenge), 300)
+	secondClaims, secondErr := endpoints.verifyUserAuthJWS(signUserAuthJWS(userPrivateKey, publicKey, secondChallenge), 300)
+
+	// then
+	assert.NotEqual(t, firstChallenge, secondChallenge)
+	assert.NoError(t, firstErr)
+	assert.Equal(t, firstChallenge, firstClaims.Challenge)
+	assert.NoError(t, secondErr)
+	assert.Equal(t, secondChallenge, secondClaims.Challenge)
+}
+
+func TestVerifyUserAuthJWS_ShouldRejectUnissuedChallenge(t *testing.T) {
+	// given
+	userPublicKey, userPrivateKey, _ := ed25519.GenerateKey(nil)
+	publicKey := base64.StdEncoding.EncodeToString(userPublicKey)
+	repo := newMockUserRepository()
+	repo.users[publicKey] = &User{PublicKey: publicKey, Username: "user"}
+	endpoints := NewEndpoints(repo, Config{ChallengeTTLSec: 300}, nil, nil, nil)
+	requestChallenge(t, endpoints, publicKey)
+
+	// when
+	_, err := endpoints.verifyUserAuthJWS(signUserAuthJWS(userPrivateKey, publicKey, "forged-challenge"), 300)
+
+	// then
+	assert.EqualError(t, err, "challenge mismatch")
+}
+
+func TestGetChallenge_ShouldRejectRequestsOverRateLimitWithRetryAfter(t *testing.T) {
+	// given
+	repo := newMockUserRepository()
+	repo.users["user-public-key-0123456789"] = &User{PublicKey: "user-public-key-0123456789", Username: "user"}
+	endpoints := NewEndpoints(repo, Config{ChallengeTTLSec: 300, ChallengeRateLimitPerMin: 2}, nil, nil, nil)
+	requestChallenge(t, endpoints, "user-public-key-0123456789")
+	requestChallenge(t, endpoints, "user-public-key-0123456789")
+
+	// when
+	ctx := &fasthttp.RequestCtx{}
+	ctx.QueryArgs().Set("publicKey", "user-public-key-0123456789")
+	endpoints.GetChallenge(ctx)
+
+	// then
+	assert.Equal(t, fasthttp.StatusTooManyRequests, ctx.Response.StatusCode())
+	assert.Equal(t, "60", string(ctx.Response.Header.Peek("Retry-After")))
+	assert.Len(t, endpoints.challenges["user-public-key-0123456789"], 2)
+}
+
+func TestSweepChallenges_ShouldRemoveExpiredChallenges(t *testing.T) {
+	// given
+	endpoints := NewEndpoints(nil, Config{ChallengeRateLimitPerMin: 5}, nil, nil, nil)
+	now := time.Now()
+	endpoints.storeChallenge("expired-key", challengeInfo{challenge: "expired", expiresAt: now.Add(time.Second)})
+	endpoints.storeChallenge("active-key", challengeInfo{challenge: "active", expiresAt: now.Add(time.Hour)})
+	originalTimeNow := timeNowFunc
+	timeNowFunc = func() time.Time { return now.Add(time.Minute) }
+	defer func() { timeNowFunc = originalTimeNow }()
+
+	// when
+	endpoints.sweepChallenges()
+
+	// then
+	assert.NotContains(t, endpoints.challenges, "expired-key")
+	assert.Contains(t, endpoints.challenges, "active-key")
+}
+
+func TestStopChallengeSweep_ShouldStopStartedSweepAndAllowRepeatedCalls(t *testing.T) {
+	// given
+	endpoints := NewEndpoints(nil, Config{}, nil, nil, nil)
+	endpoints.StartChallengeSweep()
+
+	// when
+	endpoints.StopChallengeSweep()
+	endpoints.StopChallengeSweep()
+
+	// then
+	select {
+	case <-endpoints.sweep.stopped:
+	default:
+		t.Fatal("Expected the sweep goroutine to have exited")
+	}
+}
+
+func TestChallengeStore_ShouldHandleConcurrentChallengesAndLogins(t *testing.T) {
+	// given
+	serverPublicKey, serverPrivateKey, _ := ed25519.GenerateKey(nil)
+	repo := newMockUserRepository()
+	config := Config{ChallengeTTLSec: 300, JWTExpirationHours: 1}
+	service := NewUserService(repo, config, serverPrivateKey, serverPublicKey)
+	endpoints := NewEndpoints(repo, config, serverPrivateKey, serverPublicKey, service)
+
+	userKeys := make([]ed25519.PrivateKey, 20)
+	for i := range userKeys {
+		userPublicKey, userPrivateKey, _ := ed25519.GenerateKey(nil)
+		publicKey := base64.StdEncoding.EncodeToString(userPublicKey)
+		repo.users[publicKey] = &User{PublicKey: publicKey, Username: fmt.Sprintf("user-%d", i)}
+		userKeys[i] = userPrivateKey
+	}
+
+	// when
+	var wg sync.WaitGroup
+	statuses := make([]int, len(userKeys))
+	for i, userPrivateKey := range userKeys {
+		wg.Add(1)
+		go func(i int, userPrivateKey ed25519.PrivateKey) {
+			defer wg.Done()
+			publicKey := base64.StdEncoding.EncodeToString(userPrivateKey.Public().(ed25519.PublicKey))
+			requestChallenge(t, endpoints, publicKey)
+			challenge := requestChallenge(t, endpoints, publicKey)
+
+			ctx := &fasthttp.RequestCtx{}
+			ctx.Request.Header.Set(headerAuthorization, headerBearer+" "+signUserAuthJWS(userPrivateKey, publicKey, challenge))
+			endpoints.UserAuth(ctx)
+			statuses[i] = ctx.Response.StatusCode()
+		}(i, userPrivateKey)
+	}
+	wg.Wait()
+
+	// then
+	for i, status := range statuses {
+		assert.Equal(t, fasthttp.StatusOK, status, "login %d", i)
+	}
+	assert.Empty(t, endpoints.challenges)
+}
+
+func TestStoreChallenge_ShouldKeepOnlyNewestUnexpiredChallenges(t *testing.T) {
+	// given
+	endpoints := NewEndpoints(nil, Config{}, nil, nil, nil)
+	now := time.Now()
+	endpoints.storeChallenge("user-key", challengeInfo{challenge: "expired", expiresAt: now.Add(-time.Second)})
+
+	// when
+	for i := 1; i <= maxChallengesPerUser+1; i++ {
+		endpoints.storeChallenge("user-key", challengeInfo{challenge: fmt.Sprintf("challenge-%d", i), expiresAt: now.Add(time.Minute)})
+	}
+
+	// then
+	stored := endpoints.challenges["user-key"]
+	assert.Len(t, stored, maxChallengesPerUser)
+	_, expiredKept := findChallenge(stored, "expired")
+	_, oldestKept := findChallenge(stored, "challenge-1")
+	_, newestKept := findChallenge(stored, fmt.Sprintf("challenge-%d", maxChallengesPerUser+1))
+	assert.False(t, expiredKept)
+	assert.False(t, oldestKept)
+	assert.True(t, newestKept)
+}
+
+func TestStoreChallenge_ShouldNeverExceedMaxStoredChallenges(t *testing.T) {
+	// given
+	endpoints := NewEndpoints(nil, Config{MaxStoredChallenges: 5}, nil, nil, nil)
+	expiresAt := time.Now().Add(time.Minute)
+
+	// when
+	for i := 0; i < 50; i++ {
+		endpoints.storeChallenge(fmt.Sprintf("user-key-%d", i%20), challengeInfo{challenge: fmt.Sprintf("challenge-%d", i), expiresAt: expiresAt})
+
+		// then
+		stored := 0
+		for _, challenges := range endpoints.challenges {
+			stored += len(challenges)
+		}
+		assert.LessOrEqual(t, stored, 5)
+		assert.Equal(t, stored, endpoints.challengeEviction.total)
+	}
+	assert.Len(t, endpoints.challenges, 5)
+	assert.Contains(t, endpoints.challenges, "user-key-9", "most recently active user is kept")
+	assert.NotContains(t, endpoints.challenges, "user-key-4", "least recently active user is evicted")
+}
+
+func TestStoreChallenge_ShouldEvictOldestChallengeOfLeastRecentlyActiveUser(t *testing.T) {
+	// given
+	endpoints := NewEndpoints(nil, Config{MaxStoredChallenges: 3}, nil, nil, nil)
+	expiresAt := time.Now().Add(time.Minute)
+	endpoints.storeChallenge("first-key", challengeInfo{challenge: "first-1", expiresAt: expiresAt})
+	endpoints.storeChallenge("second-key", challengeInfo{challenge: "second-1", expiresAt: expiresAt})
+	endpoints.storeChallenge("first-key", challengeInfo{challenge: "first-2", expiresAt: expiresAt})
+
+	// when
+	endpoints.storeChallenge("third-key", challengeInfo{challenge: "third-1", expiresAt: expiresAt})
+	endpoints.removeChallenges("first-key")
+	endpoints.storeChallenge("fourth-key", challengeInfo{challenge: "fourth-1", expiresAt: expiresAt})
+
+	// then
+	assert.NotContains(t, endpoints.challenges, "second-key")
+	assert.Len(t, endpoints.challenges["third-key"], 1)
+	assert.Len(t, endpoints.challenges["fourth-key"], 1)
+	assert.Equal(t, 2, endpoints.challengeEviction.total)
+}
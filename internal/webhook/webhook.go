@@ -0,0 +1,173 @@
+package webhook
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// HeaderSignature carries the hex HMAC-SHA256 of the request body, prefixed with "sha256="
+	HeaderSignature = "X-Prappser-Signature"
+	// HeaderEventType carries the type of the delivered event
+	HeaderEventType = "X-Prappser-Event"
+	// HeaderEventID carries the ID of the delivered event, stable across retries
+	HeaderEventID = "X-Prappser-Event-Id"
+
+	signaturePrefix = "sha256="
+	// sealedSecretPrefix marks a secret encrypted at rest; secrets stored without it
+	// predate encryption and are used as they are
+	sealedSecretPrefix = "v1:"
+
+	DefaultMaxAttempts  = 5
+	DefaultRetryBackoff = time.Second
+	DefaultTimeout      = 10 * time.Second
+	DefaultWorkers      = 4
+	DefaultQueueSize    = 1024
+)
+
+var (
+	ErrNotApplicationOwner = errors.New("not the owner of this application")
+	ErrInvalidWebhook      = errors.New("invalid webhook")
+	ErrWebhookNotFound     = errors.New("webhook not found")
+	ErrForbiddenTarget     = errors.New("webhook target is a private network address")
+)
+
+// Webhook is an external URL notified of an application's events
+type Webhook struct {
+	ID                 string   `json:"id"`
+	ApplicationID      string   `json:"applicationId"`
+	URL                string   `json:"url"`
+	Secret             string   `json:"secret,omitempty"`
+	EventTypes         []string `json:"eventTypes"`
+	CreatedByPublicKey string   `json:"createdByPublicKey"`
+	CreatedAt          int64    `json:"createdAt"`
+}
+
+// Subscribes reports whether the webhook wants events of the given type.
+// A webhook without event types receives every event.
+func (w *Webhook) Subscribes(eventType string) bool {
+	if len(w.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range w.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// DeadLetter records a delivery that failed on every attempt
+type DeadLetter struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhookId"`
+	EventID   string `json:"eventId"`
+	Payload   string `json:"payload"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"lastError"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// CreateWebhookRequest represents the request body for POST /applications/{id}/webhooks.
+// When Secret is empty the server generates one and returns it once in the response.
+type CreateWebhookRequest struct {
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret,omitempty"`
+	EventTypes []string `json:"eventTypes,omitempty"`
+}
+
+// Config holds webhook delivery settings
+type Config struct {
+	// MaxAttempts is how many times a delivery is tried before it is dead-lettered
+	MaxAttempts int
+	// RetryBackoff is the wait before the first retry; it doubles on each further retry
+	RetryBackoff time.Duration
+	// Timeout bounds a single delivery request
+	Timeout time.Duration
+	// Workers is how many events are delivered concurrently
+	Workers int
+	// QueueSize bounds the events waiting for a worker; events beyond it are dropped
+	QueueSize int
+	// SecretKey derives the key webhook secrets are encrypted with at rest
+	SecretKey string
+	// AllowPrivateNetworks permits targets on loopback, private and link-local addresses,
+	// which are otherwise rejected so webhooks cannot reach internal services
+	AllowPrivateNetworks bool
+}
+
+// Sign returns the signature header value for body under secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature reports whether signature is the valid signature of body under secret.
+// Receivers use it to authenticate deliveries.
+func VerifySignature(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// secretCipher encrypts webhook secrets at rest with AES-GCM under a key derived from
+// the configured secret key
+type secretCipher struct {
+	aead cipher.AEAD
+}
+
+func newSecretCipher(secretKey string) (*secretCipher, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(secretKey), nil, []byte("prappser webhook secrets")), key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &secretCipher{aead: aead}, nil
+}
+
+// seal encrypts a secret for storage
+func (c *secretCipher) seal(secret string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(secret), nil)
+	return sealedSecretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts a stored secret. Secrets stored before encryption are returned as they are.
+func (c *secretCipher) open(stored string) (string, error) {
+	encoded, ok := strings.CutPrefix(stored, sealedSecretPrefix)
+	if !ok {
+		return stored, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", errors.New("sealed secret is too short")
+	}
+	secret, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"errors"
+
+	"github.com/goccy/go-json"
+	"github.com/prappser/prappser_server/internal/user"
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+)
+
+type WebhookEndpoints struct {
+	webhookService *WebhookService
+}
+
+func NewWebhookEndpoints(webhookService *WebhookService) *WebhookEndpoints {
+	return &WebhookEndpoints{
+		webhookService: webhookService,
+	}
+}
+
+// CreateWebhook handles POST /applications/{appID}/webhooks
+func (we *WebhookEndpoints) CreateWebhook(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	appID := ctx.UserValue("appID").(string)
+
+	var req CreateWebhookRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		ctx.Error("Invalid request body", fasthttp.StatusBadRequest)
+		return
+	}
+
+	webhook, err := we.webhookService.CreateWebhook(appID, authenticatedUser.PublicKey, req)
+	if err != nil {
+		log.Error().Err(err).Str("appID", appID).Msg("Failed to create webhook")
+		writeWebhookError(ctx, err, "Failed to create webhook")
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusCreated)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(webhook)
+}
+
+// ListWebhooks handles GET /applications/{appID}/webhooks
+func (we *WebhookEndpoints) ListWebhooks(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	appID := ctx.UserValue("appID").(string)
+
+	webhooks, err := we.webhookService.ListWebhooks(appID, authenticatedUser.PublicKey)
+	if err != nil {
+		log.Error().Err(err).Str("appID", appID).Msg("Failed to list webhooks")
+		writeWebhookError(ctx, err, "Failed to list webhooks")
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(map[string]interface{}{
+		"webhooks": webhooks,
+	})
+}
+
+// DeleteWebhook handles DELETE /applications/{appID}/webhooks/{webhookID}
+func (we *WebhookEndpoints) DeleteWebhook(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	appID := ctx.UserValue("appID").(string)
+	webhookID := ctx.UserValue("webhookID").(string)
+
+	if err := we.webhookService.DeleteWebhook(appID, webhookID, authenticatedUser.PublicKey); err != nil {
+		log.Error().Err(err).Str("webhookID", webhookID).Msg("Failed to delete webhook")
+		writeWebhookError(ctx, err, "Failed to delete webhook")
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusNoContent)
+}
+
+// ListDeadLetters handles GET /applications/{appID}/webhooks/{webhookID}/dead-letters
+func (we *WebhookEndpoints) ListDeadLetters(ctx *fasthttp.RequestCtx) {
+	authenticatedUser, ok := ctx.UserValue("user").(*user.User)
+	if !ok || authenticatedUser == nil {
+		log.Error().Msg("Failed to get authenticated user from context")
+		ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+		return
+	}
+
+	appID := ctx.UserValue("appID").(string)
+	webhookID := ctx.UserValue("webhookID").(string)
+
+	deadLetters, err := we.webhookService.ListDeadLetters(appID, webhookID, authenticatedUser.PublicKey)
+	if err != nil {
+		log.Error().Err(err).Str("webhookID", webhookID).Msg("Failed to list webhook dead letters")
+		writeWebhookError(ctx, err, "Failed to list dead letters")
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(map[string]interface{}{
+		"deadLetters": deadLetters,
+	})
+}
+
+func writeWebhookError(ctx *fasthttp.RequestCtx, err error, fallback string) {
+	switch {
+	case errors.Is(err, ErrNotApplicationOwner):
+		ctx.Error("Only the application owner can manage webhooks", fasthttp.StatusForbidden)
+	case errors.Is(err, ErrInvalidWebhook):
+		ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+	case errors.Is(err, ErrWebhookNotFound):
+		ctx.Error("Webhook not found", fasthttp.StatusNotFound)
+	default:
+		ctx.Error(fallback, fasthttp.StatusInternalServerError)
+	}
+}
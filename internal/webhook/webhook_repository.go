@@ -0,0 +1,181 @@
+package webhook
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// WebhookRepository defines the interface for webhook data access
+type WebhookRepository interface {
+	Create(webhook *Webhook) error
+	GetByID(id string) (*Webhook, error)
+	GetByApplicationID(appID string) ([]*Webhook, error)
+	Delete(id string) error
+	CreateDeadLetter(deadLetter *DeadLetter) error
+	GetDeadLetters(webhookID string) ([]*DeadLetter, error)
+}
+
+type webhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *webhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) Create(webhook *Webhook) error {
+	query := `
+		INSERT INTO webhooks (
+			id, application_id, url, secret, event_types, created_by_public_key, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.Exec(query,
+		webhook.ID,
+		webhook.ApplicationID,
+		webhook.URL,
+		webhook.Secret,
+		pq.Array(webhook.EventTypes),
+		webhook.CreatedByPublicKey,
+		webhook.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return nil
+}
+
+func (r *webhookRepository) GetByID(id string) (*Webhook, error) {
+	query := `
+		SELECT id, application_id, url, secret, event_types, created_by_public_key, created_at
+		FROM webhooks
+		WHERE id = $1
+	`
+
+	webhook := &Webhook{}
+	err := r.db.QueryRow(query, id).Scan(
+		&webhook.ID,
+		&webhook.ApplicationID,
+		&webhook.URL,
+		&webhook.Secret,
+		pq.Array(&webhook.EventTypes),
+		&webhook.CreatedByPublicKey,
+		&webhook.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+func (r *webhookRepository) GetByApplicationID(appID string) ([]*Webhook, error) {
+	query := `
+		SELECT id, application_id, url, secret, event_types, created_by_public_key, created_at
+		FROM webhooks
+		WHERE application_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(query, appID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var webhooks []*Webhook
+	for rows.Next() {
+		webhook := &Webhook{}
+		err := rows.Scan(
+			&webhook.ID,
+			&webhook.ApplicationID,
+			&webhook.URL,
+			&webhook.Secret,
+			pq.Array(&webhook.EventTypes),
+			&webhook.CreatedByPublicKey,
+			&webhook.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (r *webhookRepository) Delete(id string) error {
+	result, err := r.db.Exec("DELETE FROM webhooks WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (r *webhookRepository) CreateDeadLetter(deadLetter *DeadLetter) error {
+	query := `
+		INSERT INTO webhook_dead_letters (
+			id, webhook_id, event_id, payload, attempts, last_error, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.Exec(query,
+		deadLetter.ID,
+		deadLetter.WebhookID,
+		deadLetter.EventID,
+		deadLetter.Payload,
+		deadLetter.Attempts,
+		deadLetter.LastError,
+		deadLetter.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook dead letter: %w", err)
+	}
+	return nil
+}
+
+func (r *webhookRepository) GetDeadLetters(webhookID string) ([]*DeadLetter, error) {
+	query := `
+		SELECT id, webhook_id, event_id, payload, attempts, last_error, created_at
+		FROM webhook_dead_letters
+		WHERE webhook_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(query, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	var deadLetters []*DeadLetter
+	for rows.Next() {
+		deadLetter := &DeadLetter{}
+		err := rows.Scan(
+			&deadLetter.ID,
+			&deadLetter.WebhookID,
+			&deadLetter.EventID,
+			&deadLetter.Payload,
+			&deadLetter.Attempts,
+			&deadLetter.LastError,
+			&deadLetter.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook dead letter: %w", err)
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	return deadLetters, rows.Err()
+}
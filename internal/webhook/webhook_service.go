@@ -0,0 +1,448 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/prappser/prappser_server/internal/application"
+	"github.com/prappser/prappser_server/internal/event"
+	"github.com/rs/zerolog/log"
+)
+
+type WebhookService struct {
+	repo    WebhookRepository
+	appRepo application.ApplicationRepository
+	client  *http.Client
+	secrets *secretCipher
+	config  Config
+	// lookupIP resolves webhook hosts when they are registered
+	lookupIP func(ctx context.Context, network, host string) ([]net.IP, error)
+
+	queue    chan *event.Event
+	done     chan struct{}
+	stopOnce sync.Once
+	workers  sync.WaitGroup
+
+	// deliveryCtx is cancelled by Stop, aborting in-flight deliveries
+	deliveryCtx    context.Context
+	cancelDelivery context.CancelFunc
+}
+
+func NewWebhookService(repo WebhookRepository, appRepo application.ApplicationRepository, config Config) (*WebhookService, error) {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultMaxAttempts
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = DefaultRetryBackoff
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.Workers <= 0 {
+		config.Workers = DefaultWorkers
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultQueueSize
+	}
+
+	secrets, err := newSecretCipher(config.SecretKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize webhook secret encryption: %w", err)
+	}
+
+	deliveryCtx, cancelDelivery := context.WithCancel(context.Background())
+	return &WebhookService{
+		repo:           repo,
+		appRepo:        appRepo,
+		client:         newDeliveryClient(config),
+		secrets:        secrets,
+		config:         config,
+		lookupIP:       net.DefaultResolver.LookupIP,
+		queue:          make(chan *event.Event, config.QueueSize),
+		done:           make(chan struct{}),
+		deliveryCtx:    deliveryCtx,
+		cancelDelivery: cancelDelivery,
+	}, nil
+}
+
+// newDeliveryClient returns the HTTP client deliveries go through. It does not follow
+// redirects, and unless private networks are allowed it refuses to connect to private
+// addresses, which also catches hosts that resolved to a public address at registration.
+func newDeliveryClient(config Config) *http.Client {
+	dialer := &net.Dialer{Timeout: config.Timeout}
+	if !config.AllowPrivateNetworks {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivateAddress(ip) {
+				return fmt.Errorf("%w: %s", ErrForbiddenTarget, host)
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Timeout: config.Timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: config.Timeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Start launches the delivery workers
+func (s *WebhookService) Start() {
+	log.Info().
+		Int("workers", s.config.Workers).
+		Int("queueSize", s.config.QueueSize).
+		Msg("[WEBHOOK] Delivery workers started")
+
+	for i := 0; i < s.config.Workers; i++ {
+		s.workers.Add(1)
+		go s.work()
+	}
+}
+
+// work delivers queued events until the service is stopped
+func (s *WebhookService) work() {
+	defer s.workers.Done()
+	for {
+		select {
+		case ev := <-s.queue:
+			s.dispatch(ev)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Stop stops the delivery workers and waits for them to return. In-flight deliveries are
+// aborted and not retried, so they are dead-lettered; queued events that no worker picked
+// up are dropped. It is safe to call more than once and before Start.
+func (s *WebhookService) Stop() {
+	log.Info().Msg("[WEBHOOK] Stopping delivery workers")
+	s.stopOnce.Do(func() {
+		close(s.done)
+		s.cancelDelivery()
+	})
+	s.workers.Wait()
+}
+
+// CreateWebhook registers a webhook for the application. The returned webhook includes
+// its secret; later reads omit it.
+func (s *WebhookService) CreateWebhook(appID, requesterPublicKey string, req CreateWebhookRequest) (*Webhook, error) {
+	if err := s.verifyOwner(appID, requesterPublicKey); err != nil {
+		return nil, err
+	}
+
+	if err := s.validateWebhookURL(req.URL); err != nil {
+		return nil, err
+	}
+	for _, eventType := range req.EventTypes {
+		if eventType == "" {
+			return nil, fmt.Errorf("%w: event types must not be empty", ErrInvalidWebhook)
+		}
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		generated, err := generateSecret()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		secret = generated
+	}
+	sealedSecret, err := s.secrets.seal(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt webhook secret: %w", err)
+	}
+
+	webhook := &Webhook{
+		ID:                 uuid.New().String(),
+		ApplicationID:      appID,
+		URL:                req.URL,
+		Secret:             sealedSecret,
+		EventTypes:         req.EventTypes,
+		CreatedByPublicKey: requesterPublicKey,
+		CreatedAt:          time.Now().Unix(),
+	}
+	if webhook.EventTypes == nil {
+		webhook.EventTypes = []string{}
+	}
+
+	if err := s.repo.Create(webhook); err != nil {
+		return nil, err
+	}
+	webhook.Secret = secret
+
+	log.Info().
+		Str("webhookId", webhook.ID).
+		Str("applicationId", appID).
+		Msg("[WEBHOOK] Webhook created")
+
+	return webhook, nil
+}
+
+// ListWebhooks returns the application's webhooks without their secrets
+func (s *WebhookService) ListWebhooks(appID, requesterPublicKey string) ([]*Webhook, error) {
+	if err := s.verifyOwner(appID, requesterPublicKey); err != nil {
+		return nil, err
+	}
+
+	webhooks, err := s.repo.GetByApplicationID(appID)
+	if err != nil {
+		return nil, err
+	}
+	for _, webhook := range webhooks {
+		webhook.Secret = ""
+	}
+	if webhooks == nil {
+		webhooks = []*Webhook{}
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook removes one of the application's webhooks
+func (s *WebhookService) DeleteWebhook(appID, webhookID, requesterPublicKey string) error {
+	if _, err := s.getOwnedWebhook(appID, webhookID, requesterPublicKey); err != nil {
+		return err
+	}
+	return s.repo.Delete(webhookID)
+}
+
+// ListDeadLetters returns deliveries to the webhook that failed on every attempt
+func (s *WebhookService) ListDeadLetters(appID, webhookID, requesterPublicKey string) ([]*DeadLetter, error) {
+	if _, err := s.getOwnedWebhook(appID, webhookID, requesterPublicKey); err != nil {
+		return nil, err
+	}
+
+	deadLetters, err := s.repo.GetDeadLetters(webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if deadLetters == nil {
+		deadLetters = []*DeadLetter{}
+	}
+	return deadLetters, nil
+}
+
+// Dispatch queues an accepted application event for delivery to the application's
+// subscribed webhooks, so slow receivers never delay event acceptance. When the queue
+// is full the event is dropped rather than blocking the caller.
+func (s *WebhookService) Dispatch(ev *event.Event) {
+	if ev.ApplicationID == "" {
+		return
+	}
+	select {
+	case s.queue <- ev:
+	default:
+		log.Warn().
+			Str("eventId", ev.ID).
+			Str("applicationId", ev.ApplicationID).
+			Msg("[WEBHOOK] Delivery queue full, event dropped")
+	}
+}
+
+func (s *WebhookService) dispatch(ev *event.Event) {
+	webhooks, err := s.repo.GetByApplicationID(ev.ApplicationID)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("applicationId", ev.ApplicationID).
+			Msg("[WEBHOOK] Failed to load webhooks")
+		return
+	}
+
+	var payload []byte
+	for _, webhook := range webhooks {
+		if !webhook.Subscribes(string(ev.Type)) {
+			continue
+		}
+		if webhook.Secret, err = s.secrets.open(webhook.Secret); err != nil {
+			log.Error().Err(err).Str("webhookId", webhook.ID).Msg("[WEBHOOK] Failed to decrypt webhook secret")
+			continue
+		}
+		if payload == nil {
+			if payload, err = json.Marshal(ev); err != nil {
+				log.Error().Err(err).Str("eventId", ev.ID).Msg("[WEBHOOK] Failed to encode event")
+				return
+			}
+		}
+		s.deliverWithRetry(webhook, ev, payload)
+	}
+}
+
+// deliverWithRetry tries a delivery up to MaxAttempts times with exponential backoff and
+// records a dead letter when every attempt fails, or when Stop cuts the retries short
+func (s *WebhookService) deliverWithRetry(webhook *Webhook, ev *event.Event, payload []byte) {
+	var lastErr error
+	attempts := 0
+	backoff := s.config.RetryBackoff
+	for attempt := 1; attempt <= s.config.MaxAttempts; attempt++ {
+		attempts = attempt
+		if lastErr = s.deliver(webhook, ev, payload); lastErr == nil {
+			log.Debug().
+				Str("webhookId", webhook.ID).
+				Str("eventId", ev.ID).
+				Int("attempt", attempt).
+				Msg("[WEBHOOK] Delivered")
+			return
+		}
+
+		log.Warn().
+			Err(lastErr).
+			Str("webhookId", webhook.ID).
+			Str("eventId", ev.ID).
+			Int("attempt", attempt).
+			Msg("[WEBHOOK] Delivery failed")
+
+		if attempt == s.config.MaxAttempts || !s.waitForRetry(backoff) {
+			break
+		}
+		backoff *= 2
+	}
+
+	deadLetter := &DeadLetter{
+		ID:        uuid.New().String(),
+		WebhookID: webhook.ID,
+		EventID:   ev.ID,
+		Payload:   string(payload),
+		Attempts:  attempts,
+		LastError: lastErr.Error(),
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := s.repo.CreateDeadLetter(deadLetter); err != nil {
+		log.Error().
+			Err(err).
+			Str("webhookId", webhook.ID).
+			Str("eventId", ev.ID).
+			Msg("[WEBHOOK] Failed to record dead letter")
+		return
+	}
+
+	log.Error().
+		Str("webhookId", webhook.ID).
+		Str("eventId", ev.ID).
+		Msg("[WEBHOOK] Delivery dead-lettered after all attempts failed")
+}
+
+// waitForRetry waits out the backoff before the next attempt and reports false when Stop
+// interrupts the wait
+func (s *WebhookService) waitForRetry(backoff time.Duration) bool {
+	select {
+	case <-time.After(backoff):
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// deliver performs a single signed delivery attempt
+func (s *WebhookService) deliver(webhook *Webhook, ev *event.Event, payload []byte) error {
+	req, err := http.NewRequestWithContext(s.deliveryCtx, http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, payload))
+	req.Header.Set(HeaderEventType, string(ev.Type))
+	req.Header.Set(HeaderEventID, ev.ID)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *WebhookService) getOwnedWebhook(appID, webhookID, requesterPublicKey string) (*Webhook, error) {
+	if err := s.verifyOwner(appID, requesterPublicKey); err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.repo.GetByID(webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if webhook.ApplicationID != appID {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+// verifyOwner returns ErrNotApplicationOwner unless the public key belongs to the application's owner
+func (s *WebhookService) verifyOwner(appID, publicKey string) error {
+	if !application.HasMemberRole(s.appRepo, appID, publicKey, application.MemberRoleOwner) {
+		return ErrNotApplicationOwner
+	}
+	return nil
+}
+
+// validateWebhookURL checks that the URL is an absolute http or https URL and, unless
+// private networks are allowed, that its host resolves only to public addresses
+func (s *WebhookService) validateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	if s.config.AllowPrivateNetworks {
+		return nil
+	}
+
+	host := parsed.Hostname()
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+		defer cancel()
+		if ips, err = s.lookupIP(ctx, "ip", host); err != nil || len(ips) == 0 {
+			return fmt.Errorf("%w: url host %q does not resolve", ErrInvalidWebhook, host)
+		}
+	}
+	for _, ip := range ips {
+		if isPrivateAddress(ip) {
+			return fmt.Errorf("%w: url host %q resolves to a private network address", ErrInvalidWebhook, host)
+		}
+	}
+	return nil
+}
+
+// isPrivateAddress reports whether ip is loopback, private, link-local, multicast or
+// unspecified, none of which a webhook may target
+func isPrivateAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+func generateSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
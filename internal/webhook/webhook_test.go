@@ -0,0 +1,419 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prappser/prappser_server/internal/application"
+	"github.com/prappser/prappser_server/internal/event"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testAppID          = "test-app-id"
+	testOwnerPublicKey = "owner-public-key"
+	testMemberPubKey   = "member-public-key"
+)
+
+// mockWebhookRepository for testing
+type mockWebhookRepository struct {
+	webhooks    map[string]*Webhook
+	deadLetters []*DeadLetter
+}
+
+func newMockWebhookRepository() *mockWebhookRepository {
+	return &mockWebhookRepository{webhooks: make(map[string]*Webhook)}
+}
+
+func (m *mockWebhookRepository) Create(webhook *Webhook) error {
+	copied := *webhook
+	m.webhooks[webhook.ID] = &copied
+	return nil
+}
+
+func (m *mockWebhookRepository) GetByID(id string) (*Webhook, error) {
+	webhook, exists := m.webhooks[id]
+	if !exists {
+		return nil, ErrWebhookNotFound
+	}
+	copied := *webhook
+	return &copied, nil
+}
+
+func (m *mockWebhookRepository) GetByApplicationID(appID string) ([]*Webhook, error) {
+	var result []*Webhook
+	for _, webhook := range m.webhooks {
+		if webhook.ApplicationID == appID {
+			copied := *webhook
+			result = append(result, &copied)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockWebhookRepository) Delete(id string) error {
+	if _, exists := m.webhooks[id]; !exists {
+		return ErrWebhookNotFound
+	}
+	delete(m.webhooks, id)
+	return nil
+}
+
+func (m *mockWebhookRepository) CreateDeadLetter(deadLetter *DeadLetter) error {
+	m.deadLetters = append(m.deadLetters, deadLetter)
+	return nil
+}
+
+func (m *mockWebhookRepository) GetDeadLetters(webhookID string) ([]*DeadLetter, error) {
+	return m.deadLetters, nil
+}
+
+// receiver records deliveries and answers with the queued status codes, then 200
+type receiver struct {
+	mu        sync.Mutex
+	statuses  []int
+	bodies    [][]byte
+	headers   []http.Header
+	validSigs []bool
+}
+
+func (r *receiver) handler(secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.bodies = append(r.bodies, body)
+		r.headers = append(r.headers, req.Header.Clone())
+		r.validSigs = append(r.validSigs, VerifySignature(secret, body, req.Header.Get(HeaderSignature)))
+
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status = r.statuses[0]
+			r.statuses = r.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}
+}
+
+func createTestAppRepository() *application.MemoryRepository {
+	appRepo := application.NewMemoryRepository()
+	appRepo.CreateApplication(&application.Application{ID: testAppID, Name: "Test App"})
+	appRepo.CreateMember(&application.Member{ID: "owner-member", ApplicationID: testAppID, Name: "owner", Role: application.MemberRoleOwner, PublicKey: testOwnerPublicKey})
+	appRepo.CreateMember(&application.Member{ID: "regular-member", ApplicationID: testAppID, Name: "member", Role: application.MemberRoleMember, PublicKey: testMemberPubKey})
+	return appRepo
+}
+
+// createTestService returns a service that may target the loopback test servers
+func createTestService(repo *mockWebhookRepository) *WebhookService {
+	service, _ := NewWebhookService(repo, createTestAppRepository(), Config{MaxAttempts: 3, RetryBackoff: time.Millisecond, Timeout: time.Second, SecretKey: "test-master-password", AllowPrivateNetworks: true})
+	return service
+}
+
+// createGuardedTestService returns a service that rejects private targets and resolves
+// every host name to resolvedIP
+func createGuardedTestService(repo *mockWebhookRepository, resolvedIP string) *WebhookService {
+	service, _ := NewWebhookService(repo, createTestAppRepository(), Config{MaxAttempts: 1, RetryBackoff: time.Millisecond, Timeout: time.Second, SecretKey: "test-master-password"})
+	service.lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP(resolvedIP)}, nil
+	}
+	return service
+}
+
+func createTestEvent(eventType event.EventType) *event.Event {
+	return &event.Event{
+		ID:            "event-1",
+		ApplicationID: testAppID,
+		Type:          eventType,
+		Data:          map[string]interface{}{"applicationId": testAppID, "memberName": "Alice"},
+	}
+}
+
+func TestDispatch_ShouldDeliverSignedEvent(t *testing.T) {
+	// given
+	rec := &receiver{}
+	server := httptest.NewServer(rec.handler("shared-secret"))
+	defer server.Close()
+
+	repo := newMockWebhookRepository()
+	service := createTestService(repo)
+	_, err := service.CreateWebhook(testAppID, testOwnerPublicKey, CreateWebhookRequest{URL: server.URL, Secret: "shared-secret"})
+	assert.NoError(t, err)
+
+	// when
+	service.dispatch(createTestEvent(event.EventTypeMemberAdded))
+
+	// then
+	assert.Len(t, rec.bodies, 1)
+	assert.True(t, rec.validSigs[0])
+	assert.Contains(t, string(rec.bodies[0]), `"memberName":"Alice"`)
+	assert.Equal(t, "member_added", rec.headers[0].Get(HeaderEventType))
+	assert.Equal(t, "event-1", rec.headers[0].Get(HeaderEventID))
+	assert.Empty(t, repo.deadLetters)
+}
+
+func TestDispatch_ShouldRetryTransientFailures(t *testing.T) {
+	// given
+	rec := &receiver{statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable}}
+	server := httptest.NewServer(rec.handler("shared-secret"))
+	defer server.Close()
+
+	repo := newMockWebhookRepository()
+	service := createTestService(repo)
+	service.CreateWebhook(testAppID, testOwnerPublicKey, CreateWebhookRequest{URL: server.URL, Secret: "shared-secret"})
+
+	// when
+	service.dispatch(createTestEvent(event.EventTypeMemberAdded))
+
+	// then
+	assert.Len(t, rec.bodies, 3)
+	assert.Empty(t, repo.deadLetters)
+}
+
+func TestDispatch_ShouldDeadLetterAfterAllAttemptsFail(t *testing.T) {
+	// given
+	rec := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError}}
+	server := httptest.NewServer(rec.handler("shared-secret"))
+	defer server.Close()
+
+	repo := newMockWebhookRepository()
+	service := createTestService(repo)
+	webhook, _ := service.CreateWebhook(testAppID, testOwnerPublicKey, CreateWebhookRequest{URL: server.URL, Secret: "shared-secret"})
+
+	// when
+	service.dispatch(createTestEvent(event.EventTypeMemberAdded))
+
+	// then
+	assert.Len(t, rec.bodies, 3)
+	assert.Len(t, repo.deadLetters, 1)
+	assert.Equal(t, webhook.ID, repo.deadLetters[0].WebhookID)
+	assert.Equal(t, "event-1", repo.deadLetters[0].EventID)
+	assert.Equal(t, 3, repo.deadLetters[0].Attempts)
+	assert.Equal(t, "unexpected status 500", repo.deadLetters[0].LastError)
+}
+
+func TestDispatch_ShouldSkipUnsubscribedEventTypes(t *testing.T) {
+	// given
+	rec := &receiver{}
+	server := httptest.NewServer(rec.handler("shared-secret"))
+	defer server.Close()
+
+	repo := newMockWebhookRepository()
+	service := createTestService(repo)
+	service.CreateWebhook(testAppID, testOwnerPublicKey, CreateWebhookRequest{URL: server.URL, EventTypes: []string{"member_added"}})
+
+	// when
+	service.dispatch(createTestEvent(event.EventTypeComponentDataChanged))
+
+	// then
+	assert.Empty(t, rec.bodies)
+}
+
+func TestVerifySignature_ShouldRejectTamperedBody(t *testing.T) {
+	// given
+	signature := Sign("shared-secret", []byte(`{"id":"event-1"}`))
+
+	// then
+	assert.True(t, VerifySignature("shared-secret", []byte(`{"id":"event-1"}`), signature))
+	assert.False(t, VerifySignature("shared-secret", []byte(`{"id":"event-2"}`), signature))
+	assert.False(t, VerifySignature("other-secret", []byte(`{"id":"event-1"}`), signature))
+}
+
+func TestCreateWebhook_ShouldGenerateSecretWhenOmitted(t *testing.T) {
+	// given
+	repo := newMockWebhookRepository()
+	service := createTestService(repo)
+
+	// when
+	webhook, err := service.CreateWebhook(testAppID, testOwnerPublicKey, CreateWebhookRequest{URL: "https://hooks.example.com/prappser"})
+
+	// then
+	assert.NoError(t, err)
+	assert.Len(t, webhook.Secret, 64)
+	listed, _ := service.ListWebhooks(testAppID, testOwnerPublicKey)
+	assert.Empty(t, listed[0].Secret)
+}
+
+func TestCreateWebhook_ShouldRejectNonOwner(t *testing.T) {
+	// given
+	service := createTestService(newMockWebhookRepository())
+
+	// when
+	_, err := service.CreateWebhook(testAppID, testMemberPubKey, CreateWebhookRequest{URL: "https://hooks.example.com/prappser"})
+
+	// then
+	assert.True(t, errors.Is(err, ErrNotApplicationOwner))
+}
+
+func TestCreateWebhook_ShouldRejectNonHTTPURL(t *testing.T) {
+	// given
+	service := createTestService(newMockWebhookRepository())
+
+	// when
+	_, err := service.CreateWebhook(testAppID, testOwnerPublicKey, CreateWebhookRequest{URL: "ftp://hooks.example.com"})
+
+	// then
+	assert.True(t, errors.Is(err, ErrInvalidWebhook))
+}
+
+func TestDeleteWebhook_ShouldRejectWebhookOfAnotherApplication(t *testing.T) {
+	// given
+	repo := newMockWebhookRepository()
+	repo.Create(&Webhook{ID: "webhook-1", ApplicationID: "other-app", URL: "https://hooks.example.com"})
+	service := createTestService(repo)
+
+	// when
+	err := service.DeleteWebhook(testAppID, "webhook-1", testOwnerPublicKey)
+
+	// then
+	assert.True(t, errors.Is(err, ErrWebhookNotFound))
+	assert.Contains(t, repo.webhooks, "webhook-1")
+}
+
+func TestCreateWebhook_ShouldEncryptSecretAtRest(t *testing.T) {
+	// given
+	repo := newMockWebhookRepository()
+	service := createTestService(repo)
+
+	// when
+	webhook, err := service.CreateWebhook(testAppID, testOwnerPublicKey, CreateWebhookRequest{URL: "https://hooks.example.com/prappser", Secret: "shared-secret"})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "shared-secret", webhook.Secret)
+	stored := repo.webhooks[webhook.ID].Secret
+	assert.True(t, strings.HasPrefix(stored, sealedSecretPrefix))
+	assert.NotContains(t, stored, "shared-secret")
+}
+
+func TestCreateWebhook_ShouldRejectPrivateNetworkTargets(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		resolvedIP string
+	}{
+		{name: "loopback", url: "http://127.0.0.1:8080/hook", resolvedIP: "93.184.216.34"},
+		{name: "IPv6 loopback", url: "http://[::1]/hook", resolvedIP: "93.184.216.34"},
+		{name: "cloud metadata", url: "http://169.254.169.254/latest/meta-data", resolvedIP: "93.184.216.34"},
+		{name: "private range", url: "https://10.0.0.5/hook", resolvedIP: "93.184.216.34"},
+		{name: "host resolving to private address", url: "https://internal.example.com/hook", resolvedIP: "192.168.1.10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			service := createGuardedTestService(newMockWebhookRepository(), tt.resolvedIP)
+
+			// when
+			_, err := service.CreateWebhook(testAppID, testOwnerPublicKey, CreateWebhookRequest{URL: tt.url})
+
+			// then
+			assert.True(t, errors.Is(err, ErrInvalidWebhook))
+		})
+	}
+}
+
+func TestCreateWebhook_ShouldAcceptHostResolvingToPublicAddress(t *testing.T) {
+	// given
+	service := createGuardedTestService(newMockWebhookRepository(), "93.184.216.34")
+
+	// when
+	_, err := service.CreateWebhook(testAppID, testOwnerPublicKey, CreateWebhookRequest{URL: "https://hooks.example.com/prappser"})
+
+	// then
+	assert.NoError(t, err)
+}
+
+func TestDispatch_ShouldRefusePrivateAddressAtDialTime(t *testing.T) {
+	// given a webhook whose host now points at loopback
+	rec := &receiver{}
+	server := httptest.NewServer(rec.handler("shared-secret"))
+	defer server.Close()
+
+	repo := newMockWebhookRepository()
+	repo.Create(&Webhook{ID: "webhook-1", ApplicationID: testAppID, URL: server.URL, Secret: "shared-secret"})
+	service := createGuardedTestService(repo, "93.184.216.34")
+
+	// when
+	service.dispatch(createTestEvent(event.EventTypeMemberAdded))
+
+	// then
+	assert.Empty(t, rec.bodies)
+	assert.Len(t, repo.deadLetters, 1)
+	assert.Contains(t, repo.deadLetters[0].LastError, ErrForbiddenTarget.Error())
+}
+
+func TestDispatch_ShouldNotFollowRedirects(t *testing.T) {
+	// given
+	rec := &receiver{}
+	target := httptest.NewServer(rec.handler("shared-secret"))
+	defer target.Close()
+	redirector := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	defer redirector.Close()
+
+	repo := newMockWebhookRepository()
+	service := createTestService(repo)
+	service.CreateWebhook(testAppID, testOwnerPublicKey, CreateWebhookRequest{URL: redirector.URL, Secret: "shared-secret"})
+
+	// when
+	service.dispatch(createTestEvent(event.EventTypeMemberAdded))
+
+	// then
+	assert.Empty(t, rec.bodies)
+	assert.Len(t, repo.deadLetters, 1)
+	assert.Equal(t, "unexpected status 302", repo.deadLetters[0].LastError)
+}
+
+func TestStop_ShouldAbortRetriesOfInFlightDelivery(t *testing.T) {
+	// given - a receiver that always fails and a long retry backoff
+	rec := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError}}
+	server := httptest.NewServer(rec.handler("shared-secret"))
+	defer server.Close()
+
+	repo := newMockWebhookRepository()
+	service, _ := NewWebhookService(repo, createTestAppRepository(), Config{MaxAttempts: 3, RetryBackoff: time.Hour, Timeout: time.Second, SecretKey: "test-master-password", AllowPrivateNetworks: true})
+	service.CreateWebhook(testAppID, testOwnerPublicKey, CreateWebhookRequest{URL: server.URL, Secret: "shared-secret"})
+	service.Start()
+	service.Dispatch(createTestEvent(event.EventTypeMemberAdded))
+	assert.Eventually(t, func() bool {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return len(rec.bodies) == 1
+	}, time.Second, time.Millisecond)
+
+	// when
+	stopped := make(chan struct{})
+	go func() {
+		service.Stop()
+		close(stopped)
+	}()
+
+	// then
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop waited out the retry backoff")
+	}
+	assert.Len(t, repo.deadLetters, 1)
+	assert.Equal(t, 1, repo.deadLetters[0].Attempts)
+}
+
+func TestDispatch_ShouldDropEventsWhenQueueIsFull(t *testing.T) {
+	// given
+	service, _ := NewWebhookService(newMockWebhookRepository(), createTestAppRepository(), Config{QueueSize: 1})
+
+	// when
+	service.Dispatch(createTestEvent(event.EventTypeMemberAdded))
+	service.Dispatch(createTestEvent(event.EventTypeMemberRemoved))
+
+	// then
+	assert.Len(t, service.queue, 1)
+}
@@ -0,0 +1,77 @@
+package websocket
+
+import (
+	"github.com/prappser/prappser_server/internal/event"
+	"github.com/rs/zerolog/log"
+)
+
+// catchupPageSize is the most events sent in one catch-up frame, the same cap as a page of
+// GET /events
+const catchupPageSize = 500
+
+// catchUp answers a catchup message with one page of the application's events after
+// sinceEventID. While a page has more, the client asks for the next one with another
+// catchup from its last event, so a large backlog never fills the send buffer ahead of
+// live broadcasts. The last page subscribes the client, so live delivery resumes after
+// the backlog. Events accepted while subscribing are sent once more from the last
+// delivered event, so none fall between backlog and live delivery; clients drop the
+// duplicates by event ID.
+func (c *Client) catchUp(applicationID, sinceEventID string) {
+	if c.events == nil {
+		c.enqueue(&OutgoingMessage{
+			Type:  MessageTypeError,
+			Error: "catch-up is not available, poll GET /events instead",
+		})
+		return
+	}
+	if !c.canSubscribe(applicationID) {
+		return
+	}
+
+	cursor, hasMore, ok := c.sendEventsPage(applicationID, sinceEventID)
+	if !ok || hasMore || !c.subscribeOrReject(applicationID) {
+		return
+	}
+	c.sendEventsPage(applicationID, cursor)
+}
+
+// sendEventsPage sends a page of the application's events after sinceEventID and returns
+// the ID of the last event sent and whether more follow. It returns false when the page
+// could not be delivered, after telling the client why when it is still connected.
+func (c *Client) sendEventsPage(applicationID, sinceEventID string) (string, bool, bool) {
+	response, err := c.events.GetApplicationEventsSince(c.user.PublicKey, applicationID, sinceEventID, catchupPageSize)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("userPublicKey", c.user.PublicKey[:20]+"...").
+			Str("applicationId", applicationID).
+			Msg("[WS] Failed to load missed events")
+		c.enqueue(&OutgoingMessage{
+			Type:  MessageTypeError,
+			Error: "failed to load missed events",
+		})
+		return sinceEventID, false, false
+	}
+
+	if response.FullResyncRequired {
+		c.enqueue(&EventsMessage{
+			Type:               MessageTypeEvents,
+			Events:             []*event.Event{},
+			ApplicationID:      applicationID,
+			FullResyncRequired: true,
+			Reason:             response.Reason,
+		})
+		return sinceEventID, false, false
+	}
+
+	if len(response.Events) == 0 {
+		return sinceEventID, false, true
+	}
+	sent := c.enqueue(&EventsMessage{
+		Type:          MessageTypeEvents,
+		Events:        response.Events,
+		ApplicationID: applicationID,
+		HasMore:       response.HasMore,
+	})
+	return response.Events[len(response.Events)-1].ID, response.HasMore, sent
+}
@@ -1,10 +1,12 @@
 package websocket
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/fasthttp/websocket"
+	"github.com/prappser/prappser_server/internal/middleware"
 	"github.com/prappser/prappser_server/internal/user"
 	"github.com/rs/zerolog/log"
 )
@@ -24,6 +26,35 @@ type Client struct {
 	send          chan interface{}
 	subscriptions map[string]bool // applicationId -> subscribed
 	mu            sync.RWMutex
+
+	// writerDone is closed when WritePump exits, so nothing blocks on a send buffer that
+	// is no longer drained
+	writerDone chan struct{}
+
+	// origin is the web origin the connection was opened from, empty for native clients;
+	// origins decides which applications that origin may subscribe to
+	origin  string
+	origins *middleware.OriginPolicy
+
+	// memberships limits subscriptions to applications the user is a member of; nil
+	// disables the check
+	memberships MembershipSource
+
+	// events serves catchup messages; nil rejects them
+	events EventSource
+
+	// activity records that the user is active on every message they send; nil disables it
+	activity ActivityRecorder
+
+	// subscriptionWindowStart and subscriptionMessages count subscription messages in the
+	// current one minute window; only ReadPump touches them
+	subscriptionWindowStart time.Time
+	subscriptionMessages    int
+}
+
+// ActivityRecorder records when a user was last active
+type ActivityRecorder interface {
+	MarkSeen(publicKey string)
 }
 
 func NewClient(hub *Hub, conn *websocket.Conn, user *user.User) *Client {
@@ -33,11 +64,35 @@ func NewClient(hub *Hub, conn *websocket.Conn, user *user.User) *Client {
 		user:          user,
 		send:          make(chan interface{}, sendBufferSize),
 		subscriptions: make(map[string]bool),
+		writerDone:    make(chan struct{}),
+	}
+}
+
+// enqueue queues a reply for WritePump and reports whether it was queued. It waits while
+// the send buffer is full, but gives up once WritePump has exited, so ReadPump always gets
+// to unregister the client.
+func (c *Client) enqueue(message interface{}) bool {
+	select {
+	case c.send <- message:
+		return true
+	case <-c.writerDone:
+		return false
 	}
 }
 
-func (c *Client) Subscribe(applicationID string) {
+// Subscribe subscribes the client to the application. It returns false without
+// subscribing when the client already holds the maximum number of subscriptions.
+func (c *Client) Subscribe(applicationID string) bool {
 	c.mu.Lock()
+	if !c.subscriptions[applicationID] && len(c.subscriptions) >= c.hub.maxSubscriptions {
+		c.mu.Unlock()
+		log.Debug().
+			Str("userPublicKey", c.user.PublicKey[:20]+"...").
+			Str("applicationId", applicationID).
+			Int("maxSubscriptions", c.hub.maxSubscriptions).
+			Msg("[WS] Subscription rejected: client subscription limit reached")
+		return false
+	}
 	c.subscriptions[applicationID] = true
 	c.mu.Unlock()
 
@@ -47,6 +102,112 @@ func (c *Client) Subscribe(applicationID string) {
 		Str("userPublicKey", c.user.PublicKey[:20]+"...").
 		Str("applicationId", applicationID).
 		Msg("[WS] Client subscribed to application")
+	return true
+}
+
+// SubscribeToMemberApplications subscribes the client to every application its user is a
+// member of, skipping polling-only applications, and returns how many it subscribed to
+func (c *Client) SubscribeToMemberApplications(memberships MembershipSource) int {
+	appVersions, err := memberships.GetAppVersionsByMemberPublicKey(c.user.PublicKey)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("userPublicKey", c.user.PublicKey[:20]+"...").
+			Msg("[WS] Failed to load applications for auto-subscribe")
+		return 0
+	}
+
+	subscribed := 0
+	for appID := range appVersions {
+		if c.hub.IsPollingOnly(appID) || !c.originAllowsApplication(appID) {
+			continue
+		}
+		if !c.Subscribe(appID) {
+			log.Warn().
+				Str("userPublicKey", c.user.PublicKey[:20]+"...").
+				Int("maxSubscriptions", c.hub.maxSubscriptions).
+				Msg("[WS] Auto-subscribe stopped at the client subscription limit")
+			break
+		}
+		subscribed++
+	}
+	return subscribed
+}
+
+// canSubscribe reports whether the client may subscribe to the application, replying with
+// an error frame when it may not
+func (c *Client) canSubscribe(applicationID string) bool {
+	if c.hub.IsPollingOnly(applicationID) {
+		log.Debug().
+			Str("applicationId", applicationID).
+			Msg("[WS] Subscription rejected: application is polling-only")
+		c.enqueue(&OutgoingMessage{
+			Type:  MessageTypeError,
+			Error: "application is polling-only, poll GET /events instead",
+		})
+		return false
+	}
+	if !c.originAllowsApplication(applicationID) {
+		log.Debug().
+			Str("applicationId", applicationID).
+			Str("origin", c.origin).
+			Msg("[WS] Subscription rejected: origin not allowed for application")
+		c.enqueue(&OutgoingMessage{
+			Type:  MessageTypeError,
+			Error: "origin is not allowed for this application",
+		})
+		return false
+	}
+	if !c.isMember(applicationID) {
+		c.enqueue(&OutgoingMessage{
+			Type:  MessageTypeError,
+			Error: "not a member of this application",
+		})
+		return false
+	}
+	return true
+}
+
+// subscribeOrReject subscribes the client, replying with an error frame when it already
+// holds the maximum number of subscriptions
+func (c *Client) subscribeOrReject(applicationID string) bool {
+	if !c.Subscribe(applicationID) {
+		c.enqueue(&OutgoingMessage{
+			Type:  MessageTypeError,
+			Error: fmt.Sprintf("subscription limit of %d applications reached", c.hub.maxSubscriptions),
+		})
+		return false
+	}
+	return true
+}
+
+// isMember reports whether the client's user is a member of the application. Lookup
+// failures are treated as non-membership.
+func (c *Client) isMember(applicationID string) bool {
+	if c.memberships == nil {
+		return true
+	}
+	isMember, err := c.memberships.IsMember(applicationID, c.user.PublicKey)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("userPublicKey", c.user.PublicKey[:20]+"...").
+			Str("applicationId", applicationID).
+			Msg("[WS] Failed to check membership for subscription")
+		return false
+	}
+	if !isMember {
+		log.Debug().
+			Str("userPublicKey", c.user.PublicKey[:20]+"...").
+			Str("applicationId", applicationID).
+			Msg("[WS] Subscription rejected: not a member of application")
+	}
+	return isMember
+}
+
+// originAllowsApplication reports whether the connection's origin may use the application
+func (c *Client) originAllowsApplication(applicationID string) bool {
+	return c.origin == "" || c.origins == nil || c.origins.IsAllowedForApp(applicationID, c.origin)
 }
 
 func (c *Client) Unsubscribe(applicationID string) {
@@ -79,6 +240,32 @@ func (c *Client) GetSubscriptions() []string {
 	return subs
 }
 
+// allowSubscriptionMessage counts a subscription message against the per-minute limit and
+// reports whether it may be handled. Only the first message over the limit gets an error
+// reply, so a flooding client cannot fill its own send buffer.
+func (c *Client) allowSubscriptionMessage(now time.Time) bool {
+	if now.Sub(c.subscriptionWindowStart) >= time.Minute {
+		c.subscriptionWindowStart = now
+		c.subscriptionMessages = 0
+	}
+	c.subscriptionMessages++
+	if c.subscriptionMessages <= c.hub.subscriptionRateLimit {
+		return true
+	}
+
+	if c.subscriptionMessages == c.hub.subscriptionRateLimit+1 {
+		log.Debug().
+			Str("userPublicKey", c.user.PublicKey[:20]+"...").
+			Int("limit", c.hub.subscriptionRateLimit).
+			Msg("[WS] Subscription messages rate limited")
+		c.enqueue(&OutgoingMessage{
+			Type:  MessageTypeError,
+			Error: fmt.Sprintf("subscription rate limit of %d messages per minute reached", c.hub.subscriptionRateLimit),
+		})
+	}
+	return false
+}
+
 func (c *Client) ReadPump() {
 	defer func() {
 		c.hub.Unregister(c)
@@ -109,6 +296,9 @@ func (c *Client) ReadPump() {
 			return
 		}
 
+		if c.activity != nil {
+			c.activity.MarkSeen(c.user.PublicKey)
+		}
 		c.handleMessage(&msg)
 	}
 }
@@ -116,17 +306,27 @@ func (c *Client) ReadPump() {
 func (c *Client) handleMessage(msg *IncomingMessage) {
 	switch msg.Type {
 	case MessageTypeSubscribe:
-		if msg.ApplicationID != "" {
-			c.Subscribe(msg.ApplicationID)
+		// Re-subscribing is a no-op, so it skips the membership lookup and the hub
+		if msg.ApplicationID == "" || !c.allowSubscriptionMessage(time.Now()) || c.IsSubscribed(msg.ApplicationID) {
+			return
+		}
+		if !c.canSubscribe(msg.ApplicationID) {
+			return
+		}
+		c.subscribeOrReject(msg.ApplicationID)
+
+	case MessageTypeCatchup:
+		if msg.ApplicationID != "" && c.allowSubscriptionMessage(time.Now()) {
+			c.catchUp(msg.ApplicationID, msg.SinceEventID)
 		}
 
 	case MessageTypeUnsubscribe:
-		if msg.ApplicationID != "" {
+		if msg.ApplicationID != "" && c.allowSubscriptionMessage(time.Now()) && c.IsSubscribed(msg.ApplicationID) {
 			c.Unsubscribe(msg.ApplicationID)
 		}
 
 	case MessageTypePing:
-		c.send <- &OutgoingMessage{Type: MessageTypePong}
+		c.enqueue(&OutgoingMessage{Type: MessageTypePong})
 
 	default:
 		log.Debug().
@@ -139,6 +339,7 @@ func (c *Client) WritePump() {
 	ticker := time.NewTicker(pingInterval)
 	defer func() {
 		ticker.Stop()
+		close(c.writerDone)
 		c.conn.Close()
 	}()
 
@@ -4,6 +4,9 @@ import (
 	"strings"
 
 	"github.com/fasthttp/websocket"
+	"github.com/prappser/prappser_server/internal/application"
+	"github.com/prappser/prappser_server/internal/event"
+	"github.com/prappser/prappser_server/internal/middleware"
 	"github.com/prappser/prappser_server/internal/user"
 	"github.com/rs/zerolog/log"
 	"github.com/valyala/fasthttp"
@@ -11,20 +14,58 @@ import (
 
 var upgrader = websocket.FastHTTPUpgrader{
 	CheckOrigin: func(ctx *fasthttp.RequestCtx) bool {
-		// TODO: Configure for production - check allowed origins
+		// Origins are checked by Handler before upgrading, against the global and
+		// per-application allowlists
 		return true
 	},
 }
 
+// MembershipSource lists the applications a user is a member of and checks membership of
+// a single application
+type MembershipSource interface {
+	GetAppVersionsByMemberPublicKey(publicKey string) (map[string]application.AppVersionInfo, error)
+	IsMember(appID, publicKey string) (bool, error)
+}
+
+// EventSource pages through the stored events of an application for catch-up
+type EventSource interface {
+	GetApplicationEventsSince(userPublicKey, appID, sinceEventID string, limit int) (*event.EventsResponse, error)
+}
+
 type Handler struct {
 	hub         *Hub
 	userService *user.UserService
+	memberships MembershipSource
+	events      EventSource
+	origins     *middleware.OriginPolicy
 }
 
-func NewHandler(hub *Hub, userService *user.UserService) *Handler {
+func NewHandler(hub *Hub, userService *user.UserService, memberships MembershipSource, events EventSource, origins *middleware.OriginPolicy) *Handler {
 	return &Handler{
 		hub:         hub,
 		userService: userService,
+		memberships: memberships,
+		events:      events,
+		origins:     origins,
+	}
+}
+
+// isOriginAllowed accepts connections without an Origin header (native clients) and web
+// origins allowed globally or for at least one application
+func (h *Handler) isOriginAllowed(origin string) bool {
+	return origin == "" || h.origins == nil || h.origins.IsAllowedForAnyApp(origin)
+}
+
+// shouldAutoSubscribe follows the autoSubscribe handshake query parameter when present
+// and the hub configuration otherwise
+func (h *Handler) shouldAutoSubscribe(ctx *fasthttp.RequestCtx) bool {
+	switch string(ctx.QueryArgs().Peek("autoSubscribe")) {
+	case "true", "1":
+		return true
+	case "false", "0":
+		return false
+	default:
+		return h.hub.autoSubscribe
 	}
 }
 
@@ -52,8 +93,26 @@ func (h *Handler) HandleFastHTTP(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
+	origin := string(ctx.Request.Header.Peek("Origin"))
+	if !h.isOriginAllowed(origin) {
+		log.Debug().Str("origin", origin).Msg("[WS] Connection rejected: origin not allowed")
+		ctx.Error("Forbidden: origin not allowed", fasthttp.StatusForbidden)
+		return
+	}
+
+	autoSubscribe := h.shouldAutoSubscribe(ctx)
+
 	err = upgrader.Upgrade(ctx, func(conn *websocket.Conn) {
 		client := NewClient(h.hub, conn, authenticatedUser)
+		client.activity = h.userService
+		client.memberships = h.memberships
+		client.events = h.events
+		h.userService.MarkSeen(authenticatedUser.PublicKey)
+		// Globally allowed origins may use every application, so only others are tracked
+		if h.origins != nil && !h.origins.IsAllowed(origin) {
+			client.origin = origin
+			client.origins = h.origins
+		}
 		h.hub.Register(client)
 
 		// Send connected message
@@ -67,6 +126,14 @@ func (h *Handler) HandleFastHTTP(ctx *fasthttp.RequestCtx) {
 			Str("username", authenticatedUser.Username).
 			Msg("[WS] Client connected")
 
+		if autoSubscribe {
+			subscribed := client.SubscribeToMemberApplications(h.memberships)
+			log.Debug().
+				Str("userPublicKey", authenticatedUser.PublicKey[:20]+"...").
+				Int("applications", subscribed).
+				Msg("[WS] Client auto-subscribed to member applications")
+		}
+
 		// Start read and write pumps
 		go client.WritePump()
 		client.ReadPump() // Blocks until disconnect
@@ -2,11 +2,41 @@ package websocket
 
 import (
 	"sync"
+	"sync/atomic"
 
+	"github.com/prappser/prappser_server/internal/application"
 	"github.com/prappser/prappser_server/internal/event"
 	"github.com/rs/zerolog/log"
 )
 
+// DefaultBroadcastQueueSize is the number of pending broadcasts the hub buffers per queue
+const DefaultBroadcastQueueSize = 256
+
+// DefaultMaxSubscriptionsPerClient is how many applications a single connection may subscribe to
+const DefaultMaxSubscriptionsPerClient = 100
+
+// DefaultSubscriptionRateLimitPerMin is how many subscribe, unsubscribe and catchup messages
+// a single connection may send per minute
+const DefaultSubscriptionRateLimitPerMin = 60
+
+// Config holds hub queue settings
+type Config struct {
+	// BroadcastQueueSize is the buffer size of the application and user broadcast queues
+	BroadcastQueueSize int
+	// QueueHighWaterMark is the queue depth that triggers a warning. Zero uses 80% of the queue size.
+	QueueHighWaterMark int
+	// PollingOnlyApps are applications that get no broadcasts and reject subscriptions
+	PollingOnlyApps application.PollingOnlyApps
+	// AutoSubscribe subscribes new connections to all their member applications unless
+	// the client opts out in the handshake
+	AutoSubscribe bool
+	// MaxSubscriptionsPerClient bounds how many applications one connection may subscribe to
+	MaxSubscriptionsPerClient int
+	// SubscriptionRateLimitPerMin bounds how many subscription messages one connection may
+	// send per minute
+	SubscriptionRateLimitPerMin int
+}
+
 type Hub struct {
 	clients       map[*Client]bool
 	byUser        map[string][]*Client // publicKey -> clients
@@ -16,23 +46,80 @@ type Hub struct {
 	broadcast     chan *BroadcastMessage
 	userBroadcast chan *UserBroadcastMessage
 	mu            sync.RWMutex
+
+	// droppedBroadcasts counts broadcasts discarded because the hub could not keep up
+	droppedBroadcasts atomic.Int64
+
+	// highWaterMark is the queue depth at which a warning is logged; aboveHighWater
+	// makes sure the warning fires once per crossing instead of on every broadcast
+	highWaterMark  int
+	aboveHighWater atomic.Bool
+
+	pollingOnlyApps application.PollingOnlyApps
+	autoSubscribe   bool
+
+	// maxSubscriptions is the per-client subscription cap, bounding hub memory per connection
+	maxSubscriptions int
+
+	// subscriptionRateLimit is how many subscription messages a client may send per minute
+	subscriptionRateLimit int
+
+	// done is closed by Stop to end Run; stopped is closed once Run has returned
+	done     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+	running  atomic.Bool
 }
 
-func NewHub() *Hub {
+func NewHub(config Config) *Hub {
+	queueSize := config.BroadcastQueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultBroadcastQueueSize
+	}
+	highWaterMark := config.QueueHighWaterMark
+	if highWaterMark <= 0 || highWaterMark > queueSize {
+		highWaterMark = queueSize * 8 / 10
+	}
+	maxSubscriptions := config.MaxSubscriptionsPerClient
+	if maxSubscriptions <= 0 {
+		maxSubscriptions = DefaultMaxSubscriptionsPerClient
+	}
+	subscriptionRateLimit := config.SubscriptionRateLimitPerMin
+	if subscriptionRateLimit <= 0 {
+		subscriptionRateLimit = DefaultSubscriptionRateLimitPerMin
+	}
+
 	return &Hub{
 		clients:       make(map[*Client]bool),
 		byUser:        make(map[string][]*Client),
 		byApp:         make(map[string][]*Client),
 		register:      make(chan *Client),
 		unregister:    make(chan *Client),
-		broadcast:     make(chan *BroadcastMessage, 256),
-		userBroadcast: make(chan *UserBroadcastMessage, 256),
+		broadcast:     make(chan *BroadcastMessage, queueSize),
+		userBroadcast: make(chan *UserBroadcastMessage, queueSize),
+		highWaterMark: highWaterMark,
+
+		pollingOnlyApps: config.PollingOnlyApps,
+		autoSubscribe:   config.AutoSubscribe,
+
+		maxSubscriptions:      maxSubscriptions,
+		subscriptionRateLimit: subscriptionRateLimit,
+
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
 	}
 }
 
 func (h *Hub) Run() {
+	h.running.Store(true)
+	defer close(h.stopped)
+
 	for {
 		select {
+		case <-h.done:
+			log.Info().Msg("[WS] Hub stopped")
+			return
+
 		case client := <-h.register:
 			h.registerClient(client)
 
@@ -48,6 +135,15 @@ func (h *Hub) Run() {
 	}
 }
 
+// Stop ends Run and waits for it to return. Queued broadcasts are dropped; clients
+// recover them on their next sync. It is safe to call more than once.
+func (h *Hub) Stop() {
+	h.stopOnce.Do(func() { close(h.done) })
+	if h.running.Load() {
+		<-h.stopped
+	}
+}
+
 func (h *Hub) registerClient(client *Client) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -127,6 +223,32 @@ func (h *Hub) Subscribe(client *Client, applicationID string) {
 		Msg("[WS] Application subscription added")
 }
 
+// EvictUserFromApp unsubscribes every connection of the user from the application, so a
+// removed member receives no further broadcasts of it
+func (h *Hub) EvictUserFromApp(applicationID, userPublicKey string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	evicted := 0
+	for _, client := range h.byUser[userPublicKey] {
+		client.mu.Lock()
+		subscribed := client.subscriptions[applicationID]
+		delete(client.subscriptions, applicationID)
+		client.mu.Unlock()
+
+		if subscribed {
+			h.removeFromAppSubscribers(client, applicationID)
+			evicted++
+		}
+	}
+
+	log.Debug().
+		Str("applicationId", applicationID).
+		Str("userPublicKey", userPublicKey[:min(20, len(userPublicKey))]+"...").
+		Int("connections", evicted).
+		Msg("[WS] User evicted from application subscribers")
+}
+
 func (h *Hub) Unsubscribe(client *Client, applicationID string) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -210,27 +332,98 @@ func (h *Hub) broadcastToUser(msg *UserBroadcastMessage) {
 }
 
 func (h *Hub) Register(client *Client) {
-	h.register <- client
+	select {
+	case h.register <- client:
+	case <-h.done:
+	}
 }
 
+// Unregister removes the client; after Stop it returns without blocking so pumps of
+// remaining connections can still exit
 func (h *Hub) Unregister(client *Client) {
-	h.unregister <- client
+	select {
+	case h.unregister <- client:
+	case <-h.done:
+	}
 }
 
+// BroadcastToApplication queues an event for the application's subscribers.
+// It never blocks: when the hub is stalled and the queue is full the broadcast is
+// dropped, and clients recover the event on their next sync.
 func (h *Hub) BroadcastToApplication(applicationID string, ev *event.Event) {
-	h.broadcast <- &BroadcastMessage{
+	if h.IsPollingOnly(applicationID) {
+		log.Debug().
+			Str("applicationId", applicationID).
+			Str("eventId", ev.ID).
+			Msg("[WS] Application is polling-only, skipping broadcast")
+		return
+	}
+
+	select {
+	case h.broadcast <- &BroadcastMessage{
 		ApplicationID: applicationID,
 		Event:         ev,
+	}:
+		h.checkQueueDepth()
+	default:
+		h.droppedBroadcasts.Add(1)
+		log.Warn().
+			Str("applicationId", applicationID).
+			Str("eventId", ev.ID).
+			Msg("[WS] Broadcast queue full, dropping application broadcast")
 	}
 }
 
+// BroadcastToUser queues an event for the user's connected devices without blocking.
 func (h *Hub) BroadcastToUser(userPublicKey string, ev *event.Event) {
-	h.userBroadcast <- &UserBroadcastMessage{
+	select {
+	case h.userBroadcast <- &UserBroadcastMessage{
 		UserPublicKey: userPublicKey,
 		Event:         ev,
+	}:
+		h.checkQueueDepth()
+	default:
+		h.droppedBroadcasts.Add(1)
+		log.Warn().
+			Str("eventId", ev.ID).
+			Msg("[WS] Broadcast queue full, dropping user broadcast")
+	}
+}
+
+// checkQueueDepth warns once when either broadcast queue reaches the high-water mark
+// and re-arms the warning after the queues drain below it
+func (h *Hub) checkQueueDepth() {
+	depth := max(len(h.broadcast), len(h.userBroadcast))
+	if depth < h.highWaterMark {
+		h.aboveHighWater.Store(false)
+		return
+	}
+	if h.aboveHighWater.CompareAndSwap(false, true) {
+		log.Warn().
+			Int("applicationQueueDepth", len(h.broadcast)).
+			Int("userQueueDepth", len(h.userBroadcast)).
+			Int("highWaterMark", h.highWaterMark).
+			Int("queueSize", cap(h.broadcast)).
+			Msg("[WS] Broadcast queue depth crossed high-water mark")
 	}
 }
 
+// IsPollingOnly reports whether the application's clients must poll GET /events
+// instead of receiving broadcasts
+func (h *Hub) IsPollingOnly(applicationID string) bool {
+	return h.pollingOnlyApps.Contains(applicationID)
+}
+
+// QueueDepth returns the number of broadcasts waiting in the hub's queues
+func (h *Hub) QueueDepth() int {
+	return len(h.broadcast) + len(h.userBroadcast)
+}
+
+// DroppedBroadcasts returns how many broadcasts were dropped because the queue was full
+func (h *Hub) DroppedBroadcasts() int64 {
+	return h.droppedBroadcasts.Load()
+}
+
 func (h *Hub) GetStats() (totalClients, totalSubscriptions int) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -0,0 +1,457 @@
+package websocket
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prappser/prappser_server/internal/application"
+	"github.com/prappser/prappser_server/internal/event"
+	"github.com/prappser/prappser_server/internal/middleware"
+	"github.com/prappser/prappser_server/internal/user"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestBroadcastToApplication_ShouldNotBlockWhenQueueIsFull(t *testing.T) {
+	// given - a hub whose Run loop is stalled
+	hub := NewHub(Config{})
+	ev := &event.Event{ID: "event-1", ApplicationID: "app-1"}
+	for i := 0; i < cap(hub.broadcast); i++ {
+		hub.BroadcastToApplication("app-1", ev)
+	}
+
+	// when
+	done := make(chan struct{})
+	go func() {
+		hub.BroadcastToApplication("app-1", ev)
+		hub.BroadcastToUser("user-public-key", ev)
+		close(done)
+	}()
+
+	// then
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("broadcast blocked on a full queue")
+	}
+	assert.Equal(t, int64(1), hub.DroppedBroadcasts())
+}
+
+func TestBroadcastToUser_ShouldCountDroppedBroadcasts(t *testing.T) {
+	// given
+	hub := NewHub(Config{})
+	ev := &event.Event{ID: "event-1"}
+	for i := 0; i < cap(hub.userBroadcast); i++ {
+		hub.BroadcastToUser("user-public-key", ev)
+	}
+
+	// when
+	hub.BroadcastToUser("user-public-key", ev)
+	hub.BroadcastToUser("user-public-key", ev)
+
+	// then
+	assert.Equal(t, int64(2), hub.DroppedBroadcasts())
+	assert.Len(t, hub.userBroadcast, cap(hub.userBroadcast))
+}
+
+func TestBroadcastToApplication_ShouldWarnWhenQueueCrossesHighWaterMark(t *testing.T) {
+	// given
+	var buf bytes.Buffer
+	originalLogger := log.Logger
+	log.Logger = zerolog.New(&buf)
+	defer func() { log.Logger = originalLogger }()
+
+	hub := NewHub(Config{BroadcastQueueSize: 10, QueueHighWaterMark: 8})
+	ev := &event.Event{ID: "event-1", ApplicationID: "app-1"}
+	for i := 0; i < 7; i++ {
+		hub.BroadcastToApplication("app-1", ev)
+	}
+	assert.NotContains(t, buf.String(), "high-water mark")
+
+	// when
+	hub.BroadcastToApplication("app-1", ev)
+	hub.BroadcastToApplication("app-1", ev)
+
+	// then
+	assert.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("high-water mark")))
+	assert.Equal(t, 9, hub.QueueDepth())
+}
+
+func TestNewHub_ShouldUseConfiguredQueueSize(t *testing.T) {
+	// when
+	hub := NewHub(Config{BroadcastQueueSize: 32})
+
+	// then
+	assert.Equal(t, 32, cap(hub.broadcast))
+	assert.Equal(t, 32, cap(hub.userBroadcast))
+	assert.Equal(t, 25, hub.highWaterMark)
+}
+
+func TestBroadcastToApplication_ShouldSkipPollingOnlyApplications(t *testing.T) {
+	// given
+	hub := NewHub(Config{PollingOnlyApps: []string{"polling-app"}})
+
+	// when
+	hub.BroadcastToApplication("polling-app", &event.Event{ID: "event-1", ApplicationID: "polling-app"})
+	hub.BroadcastToApplication("realtime-app", &event.Event{ID: "event-2", ApplicationID: "realtime-app"})
+
+	// then
+	assert.Equal(t, 1, hub.QueueDepth())
+	assert.Equal(t, "realtime-app", (<-hub.broadcast).ApplicationID)
+	assert.Equal(t, int64(0), hub.DroppedBroadcasts())
+}
+
+func TestBroadcastToApplication_ShouldSkipEveryApplicationForWildcard(t *testing.T) {
+	// given
+	hub := NewHub(Config{PollingOnlyApps: []string{"*"}})
+
+	// when
+	hub.BroadcastToApplication("app-1", &event.Event{ID: "event-1", ApplicationID: "app-1"})
+
+	// then
+	assert.Equal(t, 0, hub.QueueDepth())
+	assert.True(t, hub.IsPollingOnly("any-app"))
+}
+
+func TestHandleMessage_ShouldRejectSubscriptionToPollingOnlyApplication(t *testing.T) {
+	// given
+	hub := NewHub(Config{PollingOnlyApps: []string{"polling-app"}})
+	client := NewClient(hub, nil, &user.User{PublicKey: "client-public-key-0123456789"})
+
+	// when
+	client.handleMessage(&IncomingMessage{Type: MessageTypeSubscribe, ApplicationID: "polling-app"})
+
+	// then
+	assert.False(t, client.IsSubscribed("polling-app"))
+	reply := (<-client.send).(*OutgoingMessage)
+	assert.Equal(t, MessageTypeError, reply.Type)
+}
+
+func TestHandleMessage_ShouldRejectSubscriptionBeyondClientLimit(t *testing.T) {
+	// given
+	hub := NewHub(Config{MaxSubscriptionsPerClient: 2})
+	client := NewClient(hub, nil, &user.User{PublicKey: "client-public-key-0123456789"})
+	client.handleMessage(&IncomingMessage{Type: MessageTypeSubscribe, ApplicationID: "app-1"})
+	client.handleMessage(&IncomingMessage{Type: MessageTypeSubscribe, ApplicationID: "app-2"})
+
+	// when
+	client.handleMessage(&IncomingMessage{Type: MessageTypeSubscribe, ApplicationID: "app-3"})
+	client.handleMessage(&IncomingMessage{Type: MessageTypeSubscribe, ApplicationID: "app-1"})
+
+	// then
+	assert.ElementsMatch(t, []string{"app-1", "app-2"}, client.GetSubscriptions())
+	assert.Empty(t, hub.byApp["app-3"])
+	assert.Len(t, client.send, 1)
+	reply := (<-client.send).(*OutgoingMessage)
+	assert.Equal(t, MessageTypeError, reply.Type)
+	assert.Contains(t, reply.Error, "subscription limit")
+}
+
+func TestHandleMessage_ShouldRateLimitSubscriptionFlood(t *testing.T) {
+	// given
+	hub := NewHub(Config{SubscriptionRateLimitPerMin: 3})
+	client := NewClient(hub, nil, &user.User{PublicKey: "client-public-key-0123456789"})
+
+	// when - a flood of subscribe and unsubscribe messages within one minute
+	for i := 0; i < 50; i++ {
+		client.handleMessage(&IncomingMessage{Type: MessageTypeSubscribe, ApplicationID: fmt.Sprintf("app-%d", i)})
+		client.handleMessage(&IncomingMessage{Type: MessageTypeUnsubscribe, ApplicationID: fmt.Sprintf("app-%d", i)})
+	}
+
+	// then - only the first three messages were handled and one error was sent
+	assert.Equal(t, []string{"app-1"}, client.GetSubscriptions())
+	assert.Len(t, client.send, 1)
+	reply := (<-client.send).(*OutgoingMessage)
+	assert.Contains(t, reply.Error, "rate limit")
+}
+
+func TestAllowSubscriptionMessage_ShouldResetAfterOneMinute(t *testing.T) {
+	// given
+	hub := NewHub(Config{SubscriptionRateLimitPerMin: 1})
+	client := NewClient(hub, nil, &user.User{PublicKey: "client-public-key-0123456789"})
+	start := time.Now()
+	client.allowSubscriptionMessage(start)
+
+	// when
+	limited := client.allowSubscriptionMessage(start.Add(30 * time.Second))
+	reset := client.allowSubscriptionMessage(start.Add(time.Minute))
+
+	// then
+	assert.False(t, limited)
+	assert.True(t, reset)
+}
+
+func TestHandleMessage_ShouldIgnoreRepeatedSubscribeWithoutMembershipLookup(t *testing.T) {
+	// given
+	hub := NewHub(Config{})
+	client := NewClient(hub, nil, &user.User{PublicKey: "client-public-key-0123456789"})
+	client.Subscribe("app-1")
+	memberships := &countingMembershipSource{}
+	client.memberships = memberships
+
+	// when
+	for i := 0; i < 5; i++ {
+		client.handleMessage(&IncomingMessage{Type: MessageTypeSubscribe, ApplicationID: "app-1"})
+	}
+
+	// then
+	assert.Zero(t, memberships.lookups)
+	assert.Len(t, hub.byApp["app-1"], 1)
+	assert.Empty(t, client.send)
+}
+
+func TestStop_ShouldEndRunAndUnblockUnregister(t *testing.T) {
+	// given
+	hub := NewHub(Config{})
+	go hub.Run()
+	client := NewClient(hub, nil, &user.User{PublicKey: "client-public-key-0123456789"})
+	hub.Register(client)
+
+	// when
+	done := make(chan struct{})
+	go func() {
+		hub.Stop()
+		hub.Unregister(client)
+		hub.Stop()
+		close(done)
+	}()
+
+	// then
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("hub did not stop")
+	}
+	select {
+	case <-hub.stopped:
+	default:
+		t.Fatal("Run did not return after Stop")
+	}
+}
+
+func TestHandleMessage_ShouldRejectSubscriptionFromOriginOfOtherApplication(t *testing.T) {
+	// given
+	hub := NewHub(Config{})
+	client := NewClient(hub, nil, &user.User{PublicKey: "client-public-key-0123456789"})
+	client.origin = "https://tenant-one.example"
+	client.origins = middleware.NewOriginPolicy([]string{"https://prappser.app"}, map[string][]string{"app-1": {"https://tenant-one.example"}})
+
+	// when
+	client.handleMessage(&IncomingMessage{Type: MessageTypeSubscribe, ApplicationID: "app-1"})
+	client.handleMessage(&IncomingMessage{Type: MessageTypeSubscribe, ApplicationID: "app-2"})
+
+	// then
+	assert.True(t, client.IsSubscribed("app-1"))
+	assert.False(t, client.IsSubscribed("app-2"))
+	reply := (<-client.send).(*OutgoingMessage)
+	assert.Equal(t, MessageTypeError, reply.Type)
+	assert.Contains(t, reply.Error, "origin")
+}
+
+func createAutoSubscribeRepository() *application.MemoryRepository {
+	appRepo := application.NewMemoryRepository()
+	for _, appID := range []string{"app-1", "app-2", "polling-app", "other-app"} {
+		appRepo.CreateApplication(&application.Application{ID: appID, Name: appID})
+	}
+	for _, appID := range []string{"app-1", "app-2", "polling-app"} {
+		appRepo.CreateMember(&application.Member{ID: "member-" + appID, ApplicationID: appID, Name: "member", Role: application.MemberRoleMember, PublicKey: "client-public-key-0123456789"})
+	}
+	appRepo.CreateMember(&application.Member{ID: "member-other", ApplicationID: "other-app", Name: "other", Role: application.MemberRoleMember, PublicKey: "other-public-key-0123456789"})
+	return appRepo
+}
+
+func TestSubscribeToMemberApplications_ShouldSubscribeToEveryMemberApplication(t *testing.T) {
+	// given
+	hub := NewHub(Config{AutoSubscribe: true, PollingOnlyApps: []string{"polling-app"}})
+	client := NewClient(hub, nil, &user.User{PublicKey: "client-public-key-0123456789"})
+
+	// when
+	subscribed := client.SubscribeToMemberApplications(createAutoSubscribeRepository())
+
+	// then
+	assert.Equal(t, 2, subscribed)
+	assert.ElementsMatch(t, []string{"app-1", "app-2"}, client.GetSubscriptions())
+	assert.Len(t, hub.byApp["app-1"], 1)
+	assert.Empty(t, hub.byApp["polling-app"])
+	assert.Empty(t, hub.byApp["other-app"])
+}
+
+func TestHandleMessage_ShouldRejectSubscriptionToApplicationOfOtherMembers(t *testing.T) {
+	// given
+	hub := NewHub(Config{})
+	go hub.Run()
+	defer hub.Stop()
+	client := NewClient(hub, nil, &user.User{PublicKey: "client-public-key-0123456789"})
+	client.memberships = createAutoSubscribeRepository()
+	hub.Register(client)
+
+	// when
+	client.handleMessage(&IncomingMessage{Type: MessageTypeSubscribe, ApplicationID: "other-app"})
+	client.handleMessage(&IncomingMessage{Type: MessageTypeSubscribe, ApplicationID: "app-1"})
+	hub.BroadcastToApplication("other-app", &event.Event{ID: "event-1", ApplicationID: "other-app"})
+	hub.BroadcastToApplication("app-1", &event.Event{ID: "event-2", ApplicationID: "app-1"})
+
+	// then
+	assert.False(t, client.IsSubscribed("other-app"))
+	assert.True(t, client.IsSubscribed("app-1"))
+	reply := (<-client.send).(*OutgoingMessage)
+	assert.Equal(t, MessageTypeError, reply.Type)
+	assert.Contains(t, reply.Error, "not a member")
+	select {
+	case delivered := <-client.send:
+		events := delivered.(*EventsMessage).Events
+		assert.Equal(t, "event-2", events[0].ID, "outsider must not receive other-app events")
+	case <-time.After(time.Second):
+		t.Fatal("member broadcast was not delivered")
+	}
+}
+
+func TestEvictUserFromApp_ShouldStopBroadcastsToRemovedMember(t *testing.T) {
+	// given
+	hub := NewHub(Config{})
+	go hub.Run()
+	defer hub.Stop()
+	removed := NewClient(hub, nil, &user.User{PublicKey: "removed-public-key-0123456789"})
+	remaining := NewClient(hub, nil, &user.User{PublicKey: "remaining-public-key-0123456789"})
+	for _, client := range []*Client{removed, remaining} {
+		hub.registerClient(client)
+		client.Subscribe("app-1")
+		client.Subscribe("app-2")
+	}
+
+	// when
+	hub.EvictUserFromApp("app-1", "removed-public-key-0123456789")
+	hub.BroadcastToApplication("app-1", &event.Event{ID: "event-1", ApplicationID: "app-1"})
+	hub.BroadcastToApplication("app-2", &event.Event{ID: "event-2", ApplicationID: "app-2"})
+
+	// then
+	assert.False(t, removed.IsSubscribed("app-1"))
+	assert.True(t, removed.IsSubscribed("app-2"))
+	receivedIDs := func(client *Client, count int) []string {
+		var ids []string
+		for i := 0; i < count; i++ {
+			select {
+			case message := <-client.send:
+				ids = append(ids, message.(*EventsMessage).Events[0].ID)
+			case <-time.After(time.Second):
+				t.Fatalf("expected %d broadcasts, got %v", count, ids)
+			}
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"event-1", "event-2"}, receivedIDs(remaining, 2))
+	assert.Equal(t, []string{"event-2"}, receivedIDs(removed, 1))
+}
+
+func TestShouldAutoSubscribe_ShouldFollowConfigUnlessHandshakeOverrides(t *testing.T) {
+	// given
+	enabled := NewHandler(NewHub(Config{AutoSubscribe: true}), nil, nil, nil, nil)
+	disabled := NewHandler(NewHub(Config{}), nil, nil, nil, nil)
+	handshake := func(query string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/ws?" + query)
+		return ctx
+	}
+
+	// then
+	assert.True(t, enabled.shouldAutoSubscribe(handshake("")))
+	assert.False(t, enabled.shouldAutoSubscribe(handshake("autoSubscribe=false")))
+	assert.False(t, disabled.shouldAutoSubscribe(handshake("")))
+	assert.True(t, disabled.shouldAutoSubscribe(handshake("autoSubscribe=true")))
+}
+
+type fakeEventSource map[string]*event.EventsResponse
+
+func (f fakeEventSource) GetApplicationEventsSince(userPublicKey, appID, sinceEventID string, limit int) (*event.EventsResponse, error) {
+	if response, ok := f[sinceEventID]; ok {
+		return response, nil
+	}
+	return &event.EventsResponse{Events: []*event.Event{}}, nil
+}
+
+func TestHandleMessage_ShouldSendOneCatchupPageAndSubscribeAfterTheLast(t *testing.T) {
+	// given - a backlog of two pages after event-0
+	hub := NewHub(Config{})
+	client := NewClient(hub, nil, &user.User{PublicKey: "client-public-key-0123456789"})
+	client.events = fakeEventSource{
+		"event-0": {Events: []*event.Event{{ID: "event-1"}, {ID: "event-2"}}, HasMore: true},
+		"event-2": {Events: []*event.Event{{ID: "event-3"}}},
+	}
+
+	// when
+	client.handleMessage(&IncomingMessage{Type: MessageTypeCatchup, ApplicationID: "app-1", SinceEventID: "event-0"})
+	first := (<-client.send).(*EventsMessage)
+	subscribedAfterFirst := client.IsSubscribed("app-1")
+	client.handleMessage(&IncomingMessage{Type: MessageTypeCatchup, ApplicationID: "app-1", SinceEventID: "event-2"})
+
+	// then
+	second := (<-client.send).(*EventsMessage)
+	assert.Equal(t, "app-1", first.ApplicationID)
+	assert.Len(t, first.Events, 2)
+	assert.True(t, first.HasMore)
+	assert.Empty(t, client.send, "a page with more must wait for the client to ask")
+	assert.False(t, subscribedAfterFirst)
+	assert.Equal(t, "event-3", second.Events[0].ID)
+	assert.False(t, second.HasMore)
+	assert.True(t, client.IsSubscribed("app-1"))
+}
+
+func TestHandleMessage_ShouldNotBlockOnFullSendBufferAfterWriterExited(t *testing.T) {
+	// given - a client whose WritePump is gone and whose buffer is full
+	hub := NewHub(Config{})
+	client := NewClient(hub, nil, &user.User{PublicKey: "client-public-key-0123456789"})
+	for i := 0; i < cap(client.send); i++ {
+		client.send <- &OutgoingMessage{Type: MessageTypePong}
+	}
+	close(client.writerDone)
+
+	// when
+	done := make(chan struct{})
+	go func() {
+		client.handleMessage(&IncomingMessage{Type: MessageTypePing})
+		close(done)
+	}()
+
+	// then
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reply blocked on a send buffer nobody drains")
+	}
+}
+
+func TestHandleMessage_ShouldRequireFullResyncWhenSinceEventIsGone(t *testing.T) {
+	// given
+	hub := NewHub(Config{})
+	client := NewClient(hub, nil, &user.User{PublicKey: "client-public-key-0123456789"})
+	client.events = fakeEventSource{
+		"expired-event": {FullResyncRequired: true, Reason: "Events expired or gap detected"},
+	}
+
+	// when
+	client.handleMessage(&IncomingMessage{Type: MessageTypeCatchup, ApplicationID: "app-1", SinceEventID: "expired-event"})
+
+	// then
+	reply := (<-client.send).(*EventsMessage)
+	assert.True(t, reply.FullResyncRequired)
+	assert.Equal(t, "Events expired or gap detected", reply.Reason)
+	assert.Empty(t, reply.Events)
+	assert.False(t, client.IsSubscribed("app-1"))
+}
+
+type countingMembershipSource struct {
+	lookups int
+}
+
+func (m *countingMembershipSource) GetAppVersionsByMemberPublicKey(publicKey string) (map[string]application.AppVersionInfo, error) {
+	return nil, nil
+}
+
+func (m *countingMembershipSource) IsMember(appID, publicKey string) (bool, error) {
+	m.lookups++
+	return true, nil
+}
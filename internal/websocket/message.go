@@ -12,11 +12,14 @@ const (
 	MessageTypePing        MessageType = "ping"
 	MessageTypePong        MessageType = "pong"
 	MessageTypeError       MessageType = "error"
+	MessageTypeCatchup     MessageType = "catchup"
 )
 
 type IncomingMessage struct {
 	Type          MessageType `json:"type"`
 	ApplicationID string      `json:"applicationId,omitempty"`
+	// SinceEventID is the last event a catchup client holds; empty fetches from the start
+	SinceEventID string `json:"sinceEventId,omitempty"`
 }
 
 type OutgoingMessage struct {
@@ -25,9 +28,15 @@ type OutgoingMessage struct {
 	Error  string      `json:"error,omitempty"`
 }
 
+// EventsMessage carries live events, or a page of missed events answering a catchup
+// message. Only catch-up pages set ApplicationID, HasMore and FullResyncRequired.
 type EventsMessage struct {
-	Type   MessageType    `json:"type"`
-	Events []*event.Event `json:"events"`
+	Type               MessageType    `json:"type"`
+	Events             []*event.Event `json:"events"`
+	ApplicationID      string         `json:"applicationId,omitempty"`
+	HasMore            bool           `json:"hasMore,omitempty"`
+	FullResyncRequired bool           `json:"fullResyncRequired,omitempty"`
+	Reason             string         `json:"reason,omitempty"`
 }
 
 type BroadcastMessage struct {
@@ -20,20 +20,27 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 
 	_ "github.com/lib/pq"
 	"github.com/prappser/prappser_server/internal"
+	"github.com/prappser/prappser_server/internal/apitoken"
 	"github.com/prappser/prappser_server/internal/application"
 	"github.com/prappser/prappser_server/internal/event"
 	"github.com/prappser/prappser_server/internal/health"
 	"github.com/prappser/prappser_server/internal/invitation"
 	"github.com/prappser/prappser_server/internal/keys"
+	"github.com/prappser/prappser_server/internal/middleware"
 	"github.com/prappser/prappser_server/internal/storage"
 	"github.com/prappser/prappser_server/internal/setup"
 	"github.com/prappser/prappser_server/internal/status"
 	"github.com/prappser/prappser_server/internal/user"
+	"github.com/prappser/prappser_server/internal/webhook"
 	"github.com/prappser/prappser_server/internal/websocket"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -41,6 +48,19 @@ import (
 )
 
 func initLogging() {
+	format := os.Getenv("LOG_FORMAT")
+	if format == "" {
+		format = "json"
+	}
+	logger := zerolog.New(newLogWriter(format, os.Stderr)).With().Timestamp().Logger()
+
+	sampling := os.Getenv("LOG_SAMPLING")
+	every, samplingErr := strconv.ParseUint(sampling, 10, 32)
+	if samplingErr == nil && every > 1 {
+		logger = logger.Sample(newDebugSampler(uint32(every)))
+	}
+	log.Logger = logger
+
 	level := os.Getenv("LOG_LEVEL")
 	if level == "" {
 		level = "info"
@@ -60,7 +80,49 @@ func initLogging() {
 		log.Warn().Str("level", level).Msg("Unknown log level, defaulting to info")
 	}
 
-	log.Info().Str("level", level).Msg("Logging initialized")
+	if sampling != "" && (samplingErr != nil || every == 0) {
+		log.Warn().Str("sampling", sampling).Msg("Invalid log sampling, logging every debug message")
+	}
+
+	log.Info().Str("level", level).Str("format", format).Msg("Logging initialized")
+}
+
+// newLogWriter returns the output for the configured LOG_FORMAT: a human-readable
+// console writer for "console", and zerolog's default JSON lines otherwise
+func newLogWriter(format string, out io.Writer) io.Writer {
+	if strings.ToLower(format) == "console" {
+		return zerolog.ConsoleWriter{Out: out, TimeFormat: "15:04:05"}
+	}
+	return out
+}
+
+// newDebugSampler keeps one of every N debug and trace messages while passing
+// info and above through unsampled, to bound high-volume debug logging in production
+func newDebugSampler(every uint32) zerolog.Sampler {
+	sampler := &zerolog.BasicSampler{N: every}
+	return zerolog.LevelSampler{TraceSampler: sampler, DebugSampler: sampler}
+}
+
+// checkSchemaVersion logs the database schema version at startup and returns an error
+// when it cannot serve this binary, in which case the server refuses all traffic
+func checkSchemaVersion(checker *internal.SchemaChecker) error {
+	schema, err := checker.SchemaStatus()
+	if err != nil {
+		log.Error().Err(err).Msg("[SCHEMA] Failed to read database schema version")
+		return err
+	}
+
+	log.Info().
+		Uint("version", schema.Version).
+		Uint("requiredVersion", schema.RequiredVersion).
+		Bool("dirty", schema.Dirty).
+		Msg("[SCHEMA] Database schema version")
+
+	if err := internal.CheckSchema(schema); err != nil {
+		log.Error().Err(err).Msg("[SCHEMA] Database schema not ready, refusing traffic")
+		return err
+	}
+	return nil
 }
 
 func main() {
@@ -78,6 +140,9 @@ func main() {
 		return
 	}
 
+	schemaChecker := internal.NewSchemaChecker(db)
+	schemaErr := checkSchemaVersion(schemaChecker)
+
 	keyRepo := keys.NewKeyRepository(db)
 	keyService := keys.NewKeyService(keyRepo, config.MasterPassword)
 	if err := keyService.Initialize(context.Background()); err != nil {
@@ -91,21 +156,36 @@ func main() {
 	userRepository := user.NewUserRepository(db)
 	userService := user.NewUserService(userRepository, config.Users, privateKey, publicKey)
 	userEndpoints := user.NewEndpoints(userRepository, config.Users, privateKey, publicKey, userService)
+	userEndpoints.StartChallengeSweep()
 	healthEndpoints := health.NewEndpoints("1.0.0")
 
 	appRepository := application.NewRepository(db)
 	storageRepo := storage.NewRepository(db)
-	statusEndpoints := status.NewEndpoints("1.0.0", config.Storage.MaxFileSize, config.Storage.ChunkSize, storageRepo)
 
-	wsHub := websocket.NewHub()
+	wsHub := websocket.NewHub(config.WebSocket)
 	go wsHub.Run()
 	log.Info().Msg("WebSocket hub started")
 
+	statusEndpoints := status.NewEndpoints("1.0.0", config.Storage.MaxFileSize, config.Storage.ChunkSize, storageRepo, wsHub, schemaChecker)
+
+	webhookRepository := webhook.NewWebhookRepository(db)
+	webhookService, err := webhook.NewWebhookService(webhookRepository, appRepository, config.Webhooks)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize webhook service")
+		return
+	}
+	webhookService.Start()
+	webhookEndpoints := webhook.NewWebhookEndpoints(webhookService)
+
 	eventRepository := event.NewEventRepository(db)
-	eventService := event.NewEventService(eventRepository, appRepository, wsHub)
+	eventService := event.NewEventService(eventRepository, appRepository, userRepository, wsHub, webhookService, config.Events)
 	eventEndpoints := event.NewEventEndpoints(eventService)
 
-	appService := application.NewApplicationService(appRepository)
+	apiTokenRepository := apitoken.NewAPITokenRepository(db)
+	apiTokenService := apitoken.NewAPITokenService(apiTokenRepository, appRepository, eventService)
+	apiTokenEndpoints := apitoken.NewAPITokenEndpoints(apiTokenService)
+
+	appService := application.NewApplicationService(appRepository, userRepository, eventService, config.Applications)
 	serverPublicKeyString := base64.StdEncoding.EncodeToString(publicKey)
 
 	appEndpoints := application.NewApplicationEndpoints(appService, serverPublicKeyString)
@@ -114,24 +194,39 @@ func main() {
 	cleanupScheduler.Start()
 	log.Info().Msg("Event cleanup scheduler started")
 
+	var orphanCleanupScheduler *user.OrphanCleanupScheduler
+	if config.Users.OrphanedUserGraceDays > 0 {
+		orphanCleanupScheduler = user.NewOrphanCleanupScheduler(userService, config.Users.OrphanedUserGraceDays)
+		orphanCleanupScheduler.Start()
+	}
+
+	revokedTokenCleanupScheduler := user.NewRevokedTokenCleanupScheduler(userService)
+	revokedTokenCleanupScheduler.Start()
+
 	invitationRepository := invitation.NewInvitationRepository(db)
-	invitationService := invitation.NewInvitationService(invitationRepository, privateKey, publicKey, appRepository, db, config.ExternalURL, userRepository, eventService)
+	invitationService := invitation.NewInvitationService(invitationRepository, privateKey, publicKey, appRepository, config.ExternalURL, userRepository, eventService, config.Invitations)
 	invitationEndpoints := invitation.NewInvitationEndpoints(invitationService)
 
-	setupEndpoints := setup.NewSetupEndpoints(db)
+	invitationCleanupScheduler := invitation.NewCleanupScheduler(invitationService)
+	invitationCleanupScheduler.Start()
+
+	setupEndpoints := setup.NewSetupEndpoints(db, config.Landing)
 
 	storageBackendConfig := &storage.BackendConfig{
-		Type:        storage.StorageType(config.Storage.StorageType),
-		LocalPath:   config.Storage.LocalPath,
-		S3Endpoint:  config.Storage.S3Endpoint,
-		S3Bucket:    config.Storage.S3Bucket,
-		S3AccessKey: config.Storage.S3AccessKey,
-		S3SecretKey: config.Storage.S3SecretKey,
-		S3Region:    config.Storage.S3Region,
-		S3UseSSL:    config.Storage.S3UseSSL,
-		MaxFileSize: config.Storage.MaxFileSize,
-		ChunkSize:   config.Storage.ChunkSize,
-		ExternalURL: config.ExternalURL,
+		Type:         storage.StorageType(config.Storage.StorageType),
+		FallbackType: storage.StorageType(config.Storage.FallbackType),
+		FailoverMode: storage.FailoverMode(config.Storage.FailoverMode),
+		LocalPath:    config.Storage.LocalPath,
+		S3Endpoint:   config.Storage.S3Endpoint,
+		S3Bucket:     config.Storage.S3Bucket,
+		S3AccessKey:  config.Storage.S3AccessKey,
+		S3SecretKey:  config.Storage.S3SecretKey,
+		S3Region:     config.Storage.S3Region,
+		S3UseSSL:     config.Storage.S3UseSSL,
+		S3URLExpiry:  config.Storage.S3URLExpiry,
+		MaxFileSize:  config.Storage.MaxFileSize,
+		ChunkSize:    config.Storage.ChunkSize,
+		ExternalURL:  config.ExternalURL,
 	}
 
 	storageBackend, err := storage.NewBackend(storageBackendConfig)
@@ -140,17 +235,66 @@ func main() {
 		return
 	}
 
-	storageService := storage.NewService(storageRepo, storageBackend, config.Storage.MaxFileSize, config.ExternalURL)
-	storageEndpoints := storage.NewEndpoints(storageService, appRepository, eventService, userRepository)
+	storageService := storage.NewService(storageRepo, storageBackend, storage.Config{
+		MaxFileSize:        config.Storage.MaxFileSize,
+		ChunkSize:          config.Storage.ChunkSize,
+		AppQuota:           config.Storage.AppQuota,
+		MaxPendingUploads:  config.Storage.MaxPendingUploadsPerUser,
+		MaxAvatarDimension: config.Storage.MaxAvatarDimension,
+		AllowOctetStream:   config.Storage.AllowOctetStream,
+		ExternalURL:        config.ExternalURL,
+	})
+	storageEndpoints := storage.NewEndpoints(storageService, appRepository, eventService, userRepository, config.Storage.MediaHeaders)
 	log.Info().Str("storageType", config.Storage.StorageType).Msg("Storage service initialized")
 
-	wsHandler := websocket.NewHandler(wsHub, userService)
+	var storageCleanupScheduler *storage.OrphanCleanupScheduler
+	if config.Storage.OrphanGracePeriod > 0 {
+		storageCleanupScheduler = storage.NewOrphanCleanupScheduler(storageService, config.Storage.OrphanGracePeriod)
+		storageCleanupScheduler.Start()
+	}
 
-	requestHandler := internal.NewRequestHandler(config, userEndpoints, statusEndpoints, healthEndpoints, userService, appEndpoints, invitationEndpoints, eventEndpoints, setupEndpoints, storageEndpoints, wsHandler)
+	originPolicy := middleware.NewOriginPolicy(config.AllowedOrigins, config.AppAllowedOrigins)
+	wsHandler := websocket.NewHandler(wsHub, userService, appRepository, eventService, originPolicy)
+
+	requestHandler := internal.NewRequestHandler(config, userEndpoints, statusEndpoints, healthEndpoints, userService, appEndpoints, invitationEndpoints, eventEndpoints, setupEndpoints, storageEndpoints, webhookEndpoints, apiTokenService, apiTokenEndpoints, wsHandler, originPolicy)
+	requestHandler = internal.SchemaGate(schemaErr, requestHandler)
 
 	serverAddr := fmt.Sprintf(":%s", config.Port)
-	log.Info().Str("addr", serverAddr).Msg("Starting HTTP server")
-	if err := fasthttp.ListenAndServe(serverAddr, requestHandler); err != nil {
-		log.Fatal().Err(err).Msg("Error starting HTTP server")
+	server := &fasthttp.Server{
+		Handler: requestHandler,
+		// Refuse bodies larger than any upload before they are buffered
+		MaxRequestBodySize: storageService.MaxRequestBodySize(),
+		// fasthttp reads a request's body before any handler runs, so the concurrency
+		// middleware alone can't stop a flood of uploads from being buffered. Capping the
+		// connections served at once bounds upload memory to this many request bodies.
+		Concurrency: config.MaxConcurrentRequests,
+	}
+	go func() {
+		log.Info().Str("addr", serverAddr).Msg("Starting HTTP server")
+		if err := server.ListenAndServe(serverAddr); err != nil {
+			log.Fatal().Err(err).Msg("Error starting HTTP server")
+		}
+	}()
+
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
+	sig := <-shutdown
+	log.Info().Str("signal", sig.String()).Msg("Shutting down")
+
+	if err := server.Shutdown(); err != nil {
+		log.Error().Err(err).Msg("Error shutting down HTTP server")
+	}
+	cleanupScheduler.Stop()
+	if orphanCleanupScheduler != nil {
+		orphanCleanupScheduler.Stop()
+	}
+	revokedTokenCleanupScheduler.Stop()
+	invitationCleanupScheduler.Stop()
+	if storageCleanupScheduler != nil {
+		storageCleanupScheduler.Stop()
 	}
+	userEndpoints.StopChallengeSweep()
+	webhookService.Stop()
+	wsHub.Stop()
+	log.Info().Msg("Shutdown complete")
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLogWriter_ShouldUseConsoleWriterForConsoleFormat(t *testing.T) {
+	// given
+	var out bytes.Buffer
+
+	// when
+	writer := newLogWriter("console", &out)
+
+	// then
+	assert.IsType(t, zerolog.ConsoleWriter{}, writer)
+}
+
+func TestNewLogWriter_ShouldWriteJSONByDefault(t *testing.T) {
+	// given
+	var out bytes.Buffer
+
+	// when
+	jsonWriter := newLogWriter("json", &out)
+	defaultWriter := newLogWriter("", &out)
+
+	// then
+	assert.Same(t, &out, jsonWriter)
+	assert.Same(t, &out, defaultWriter)
+}
+
+func TestNewDebugSampler_ShouldSampleDebugButKeepInfo(t *testing.T) {
+	// given
+	var out bytes.Buffer
+	logger := zerolog.New(&out).Level(zerolog.DebugLevel).Sample(newDebugSampler(10))
+
+	// when
+	for i := 0; i < 20; i++ {
+		logger.Debug().Msg("debug")
+		logger.Info().Msg("info")
+	}
+
+	// then
+	assert.Equal(t, 2, bytes.Count(out.Bytes(), []byte(`"level":"debug"`)))
+	assert.Equal(t, 20, bytes.Count(out.Bytes(), []byte(`"level":"info"`)))
+}